@@ -0,0 +1,102 @@
+// 作用：仅允许名单内对等节点的运行模式。
+// 功能：为联盟或许可网络提供独立于评分的准入控制：不在允许名单中的对等节点的入站流被立即重置，
+// 其 RPC 在任何处理之前被丢弃，本节点也不会向其打开流。允许名单可以是静态的，也可以由应用动态提供。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// PeerAllowlist 返回对等节点是否被允许与本节点交换 pubsub 消息。
+// 它会从多个协程并发调用，必须是线程安全的且不能阻塞。
+type PeerAllowlist func(pid peer.ID) bool
+
+// WithPeerAllowlist 是一个选项，只允许给定的对等节点与本节点交换 pubsub 消息
+// 参数:
+//   - ids: 允许的对等节点
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerAllowlist(ids []peer.ID) Option {
+	return func(ps *PubSub) error {
+		if len(ids) == 0 {
+			logger.Warnf("允许名单不能为空")
+			return fmt.Errorf("允许名单不能为空")
+		}
+		allowed := make(map[peer.ID]struct{}, len(ids))
+		for _, pid := range ids {
+			allowed[pid] = struct{}{}
+		}
+		ps.allowlist = func(pid peer.ID) bool {
+			_, ok := allowed[pid]
+			return ok
+		}
+		return nil
+	}
+}
+
+// WithPeerAllowlistFunc 是一个选项，由应用动态决定允许与本节点交换 pubsub 消息的对等节点。
+// 更新允许名单后应调用 RefreshPeerAllowlist，使被移除的对等节点立即被断开并离开所有主题和 mesh；
+// 未调用时它们在发送下一个 RPC 时被断开。重新加入的对等节点在下一次建立流时恢复通信。
+// 参数:
+//   - allowlist: 允许名单函数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerAllowlistFunc(allowlist PeerAllowlist) Option {
+	return func(ps *PubSub) error {
+		if allowlist == nil {
+			logger.Warnf("允许名单函数不能为空")
+			return fmt.Errorf("允许名单函数不能为空")
+		}
+		ps.allowlist = allowlist
+		return nil
+	}
+}
+
+// RefreshPeerAllowlist 在应用更新动态允许名单后重新检查已连接的对等节点，
+// 断开不再被允许的对等节点，使其立即离开所有主题和 mesh
+func (p *PubSub) RefreshPeerAllowlist() {
+	done := make(chan struct{})
+	refresh := func() {
+		defer close(done)
+		for pid := range p.peers {
+			if !p.peerAllowed(pid) {
+				logger.Debugf("peer %s 已被移出允许名单; 断开会话", pid)
+				p.evictPeer(pid)
+				p.resetInboundStream(pid)
+			}
+		}
+	}
+
+	select {
+	case p.eval <- refresh:
+		<-done
+	case <-p.ctx.Done():
+	}
+}
+
+// resetInboundStream 重置来自对等节点的入站流，使其不再向本节点发送 RPC
+// 参数:
+//   - pid: 对等节点
+func (p *PubSub) resetInboundStream(pid peer.ID) {
+	p.inboundStreamsMx.Lock()
+	defer p.inboundStreamsMx.Unlock()
+
+	if s, ok := p.inboundStreams[pid]; ok {
+		s.Reset()
+	}
+}
+
+// peerAllowed 返回对等节点是否在允许名单中，没有设置允许名单时总是返回 true
+// 参数:
+//   - pid: 对等节点
+//
+// 返回值:
+//   - bool: 是否允许
+func (p *PubSub) peerAllowed(pid peer.ID) bool {
+	return p.allowlist == nil || p.allowlist(pid)
+}
@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestPeerAllowlist 测试只接收允许名单中的对等节点的消息
+func TestPeerAllowlist(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithPeerAllowlist([]peer.ID{hosts[1].ID()})),
+		getPubsub(ctx, hosts[1]),
+		getPubsub(ctx, hosts[2]),
+	}
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[0], hosts[2])
+
+	sub, err := psubs[0].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := getTopics(psubs[1:], "foo")
+	time.Sleep(time.Second)
+
+	if err := topics[1].Publish(ctx, []byte("denied")); err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("allowed")
+	if err := topics[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+
+	if peers := psubs[0].ListPeers("foo"); len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("expected only the allowlisted peer, got %v", peers)
+	}
+}
+
+// TestPeerAllowlistFunc 测试动态的允许名单
+func TestPeerAllowlistFunc(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var allowed atomic.Bool
+	allowed.Store(true)
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithPeerAllowlistFunc(func(peer.ID) bool { return allowed.Load() })),
+		getPubsub(ctx, hosts[1]),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[0].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := getTopics(psubs[1:], "foo")
+	time.Sleep(time.Second)
+
+	msg := []byte("allowed")
+	if err := topics[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+
+	allowed.Store(false)
+	psubs[0].RefreshPeerAllowlist()
+
+	// 被移出允许名单的对等节点立即离开主题
+	if peers := psubs[0].ListPeers("foo"); len(peers) != 0 {
+		t.Fatalf("expected the removed peer to be dropped, got %v", peers)
+	}
+
+	if err := topics[0].Publish(ctx, []byte("denied")); err != nil {
+		t.Fatal(err)
+	}
+	rctx, rcancel := context.WithTimeout(ctx, time.Second)
+	defer rcancel()
+	if m, err := sub.Next(rctx); err == nil {
+		t.Fatalf("expected no message after removal from the allowlist, got %s", m.Data)
+	}
+
+}
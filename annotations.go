@@ -0,0 +1,111 @@
+// 作用：验证器注解。
+// 功能：允许验证器为消息附加带类型的注解，本地订阅者可以从投递的消息中读取，避免下游重复验证；
+// 只有显式标记为转发的注解才会随消息在网络上传播。
+
+package pubsub
+
+import (
+	"sync"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// Annotation 表示附加到消息上的注解
+type Annotation struct {
+	Key     string      // 注解的键，例如 "verified-by"
+	Value   interface{} // 注解的值；转发的注解值始终为 []byte
+	Forward bool        // 注解是否随消息在网络上转发
+}
+
+// messageAnnotations 保存消息的本地注解。
+// 异步验证器可能并发地附加注解，因此需要加锁。
+type messageAnnotations struct {
+	mx    sync.Mutex   // 保护注解的互斥锁
+	local []Annotation // 只在本地可见的注解
+}
+
+// annots 返回消息的注解。
+// 进入验证管道的消息在创建时就带有注解，因此并发的验证器无需额外同步；
+// 其他方式创建的消息（例如历史查询的结果）在第一次调用时创建注解。
+// 注解通过指针保存，使消息可以按值复制；复制出的消息与原消息共享本地注解。
+// 返回值:
+//   - *messageAnnotations: 消息的注解
+func (m *Message) annots() *messageAnnotations {
+	if m.annotations == nil {
+		m.annotations = new(messageAnnotations)
+	}
+	return m.annotations
+}
+
+// Annotate 为消息附加一个只在本地可见的注解，通常在验证器中调用。
+// 本地订阅者可以通过 Annotation 或 ListAnnotations 读取该注解，注解不会在网络上转发。
+// 参数:
+//   - key: 注解的键
+//   - value: 注解的值，可以是任意类型
+func (m *Message) Annotate(key string, value interface{}) {
+	a := m.annots()
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	a.local = append(a.local, Annotation{Key: key, Value: value})
+}
+
+// AnnotateForward 为消息附加一个随消息转发的注解，通常在验证器中调用。
+// 转发的注解写入消息的线上格式，不参与签名，因此接收方应将其视为来自上一跳的未认证信息；
+// 注解会增加消息大小，需要确保转发后的消息不超过最大消息大小。
+// 参数:
+//   - key: 注解的键
+//   - value: 注解的值
+func (m *Message) AnnotateForward(key string, value []byte) {
+	a := m.annots()
+	a.mx.Lock()
+	defer a.mx.Unlock()
+
+	m.Message.Annotations = append(m.Message.Annotations, &pb.Annotation{Key: key, Value: value})
+}
+
+// Annotation 返回给定键的最新注解值，先查找本地注解，再查找随消息转发的注解
+// 参数:
+//   - key: 注解的键
+//
+// 返回值:
+//   - interface{}: 注解的值
+//   - bool: 注解是否存在
+func (m *Message) Annotation(key string) (interface{}, bool) {
+	annots := m.annots()
+	annots.mx.Lock()
+	defer annots.mx.Unlock()
+
+	for i := len(annots.local) - 1; i >= 0; i-- {
+		if a := annots.local[i]; a.Key == key {
+			return a.Value, true
+		}
+	}
+
+	wire := m.GetAnnotations()
+	for i := len(wire) - 1; i >= 0; i-- {
+		if a := wire[i]; a.GetKey() == key {
+			return a.GetValue(), true
+		}
+	}
+
+	return nil, false
+}
+
+// ListAnnotations 返回消息的所有注解，包括本地注解以及随消息转发的注解（包括从上一跳收到的）
+// 返回值:
+//   - []Annotation: 注解列表
+func (m *Message) ListAnnotations() []Annotation {
+	annots := m.annots()
+	annots.mx.Lock()
+	defer annots.mx.Unlock()
+
+	wire := m.GetAnnotations()
+	out := make([]Annotation, 0, len(annots.local)+len(wire))
+	out = append(out, annots.local...)
+	for _, a := range wire {
+		out = append(out, Annotation{Key: a.GetKey(), Value: a.GetValue(), Forward: true})
+	}
+
+	return out
+}
@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestValidatorAnnotations 测试验证器注解对本地订阅者可见，且只有标记为转发的注解会在网络上传播
+func TestValidatorAnnotations(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	err := psubs[1].RegisterTopicValidator("foobar", func(ctx context.Context, from peer.ID, msg *Message) bool {
+		msg.Annotate("risk", 0.25)
+		msg.AnnotateForward("verified-by", []byte("schema-v3"))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+
+	// 0 - 1 - 2，节点 2 只能通过节点 1 收到消息
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+	time.Sleep(2 * time.Second)
+
+	if err := topics[0].Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := subs[1].Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	risk, ok := msg.Annotation("risk")
+	if !ok || risk.(float64) != 0.25 {
+		t.Fatalf("expected local risk annotation, got %v %v", risk, ok)
+	}
+	if len(msg.ListAnnotations()) != 2 {
+		t.Fatalf("expected 2 annotations, got %d", len(msg.ListAnnotations()))
+	}
+
+	msg, err = subs[2].Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := msg.Annotation("risk"); ok {
+		t.Fatal("local annotation must not be forwarded")
+	}
+	verified, ok := msg.Annotation("verified-by")
+	if !ok || !bytes.Equal(verified.([]byte), []byte("schema-v3")) {
+		t.Fatalf("expected forwarded annotation, got %v %v", verified, ok)
+	}
+	anns := msg.ListAnnotations()
+	if len(anns) != 1 || !anns[0].Forward {
+		t.Fatalf("expected one forwarded annotation, got %v", anns)
+	}
+}
@@ -0,0 +1,90 @@
+// 作用：发送者匿名模式。
+// 功能：发布的消息不携带来源和签名，消息 ID 基于随机序列号生成；可选地在转发前加入随机延迟，
+// 打乱消息的转发顺序，以抵抗流量分析。适用于消息覆盖网络等对隐私敏感的应用。
+
+package pubsub
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	mrand "math/rand"
+	"time"
+)
+
+// WithAnonymousPublishing 是一个选项，用于启用或禁用发送者匿名模式。
+// 启用后发布的消息不携带来源字段和签名，序列号随机生成，因此默认的消息 ID 不会泄露发布者；
+// 同时不再要求传入消息携带签名。网络中的所有节点都应启用此选项，否则匿名消息会被严格签名的节点拒绝。
+// 参数:
+//   - enabled: 是否启用匿名模式
+//
+// 返回值:
+//   - Option: 配置选项
+func WithAnonymousPublishing(enabled bool) Option {
+	return func(p *PubSub) error {
+		p.anonymous = enabled
+		if enabled {
+			p.signID = ""
+			p.signPolicy = LaxNoSign
+		}
+		return nil
+	}
+}
+
+// WithMixingDelay 是一个选项，用于在发布和转发消息前加入 [0, maxDelay) 范围内的随机延迟，
+// 使消息的转发时间和顺序与接收时间脱钩。延迟会增加消息传播的时延，应保持较小的值。
+// 参数:
+//   - maxDelay: 最大随机延迟
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMixingDelay(maxDelay time.Duration) Option {
+	return func(p *PubSub) error {
+		if maxDelay < 0 {
+			logger.Warnf("无效的混淆延迟: %s", maxDelay)
+			return fmt.Errorf("无效的混淆延迟: %s", maxDelay)
+		}
+		p.mixingDelay = maxDelay
+		return nil
+	}
+}
+
+// randomSeqno 返回一个随机序列号，用于匿名消息的消息 ID
+// 返回值:
+//   - []byte: 随机序列号
+func randomSeqno() []byte {
+	seqno := make([]byte, 8)
+	if _, err := rand.Read(seqno); err != nil {
+		// crypto/rand 不可用时退回到伪随机数
+		binary.BigEndian.PutUint64(seqno, mrand.Uint64())
+	}
+	return seqno
+}
+
+// routeMessage 通过路由器发布消息；超过跳数或存活时间限制的消息不再转发，处于 Dandelion 茎阶段的消息改为沿茎转发；如果设置了混淆延迟，则在随机延迟后再发布。
+// 只从 processLoop 调用。
+// 参数:
+//   - msg: 要发布的消息
+func (p *PubSub) routeMessage(msg *Message) {
+	if !p.forwardable(msg.Message, time.Now()) {
+		logger.Debugf("主题 %s 的消息超过跳数或存活时间限制; 不再转发", msg.GetTopic())
+		return
+	}
+
+	if p.stemMessage(msg) {
+		return
+	}
+
+	if p.mixingDelay <= 0 {
+		p.rt.Publish(msg)
+		return
+	}
+
+	delay := time.Duration(mrand.Int63n(int64(p.mixingDelay)))
+	time.AfterFunc(delay, func() {
+		select {
+		case p.eval <- func() { p.rt.Publish(msg) }:
+		case <-p.ctx.Done():
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// TestAnonymousPublishing 测试匿名模式下的消息不携带来源和签名，并使用随机序列号
+func TestAnonymousPublishing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts, WithAnonymousPublishing(true), WithMixingDelay(20*time.Millisecond))
+
+	topics := make([]*Topic, len(psubs))
+	subs := make([]*Subscription, len(psubs))
+	for i, ps := range psubs {
+		topic, err := ps.Join("anon")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics[i] = topic
+		subs[i], err = topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	var seqnos [][]byte
+	for i := 0; i < 2; i++ {
+		if err := topics[0].Publish(ctx, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := subs[1].Next(tctx)
+		tcancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.From != nil || msg.Signature != nil || msg.Key != nil {
+			t.Fatal("expected anonymous message to carry no source or signature")
+		}
+		if len(msg.Seqno) != 8 {
+			t.Fatalf("expected random seqno, got %x", msg.Seqno)
+		}
+		seqnos = append(seqnos, msg.Seqno)
+	}
+	if bytes.Equal(seqnos[0], seqnos[1]) {
+		t.Fatal("expected distinct seqnos for anonymous messages")
+	}
+	// 随机序列号不是递增的计数器
+	if bytes.Equal(seqnos[1], psubs[0].nextSeqno()) {
+		t.Fatal("expected seqno not to come from the counter")
+	}
+}
+
+// TestMixingDelayOption 测试混淆延迟选项的参数验证
+func TestMixingDelayOption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithMixingDelay(-time.Second)); err == nil {
+		t.Fatal("expected error for negative mixing delay")
+	}
+}
@@ -0,0 +1,469 @@
+// 作用：主题级的反熵状态同步。
+// 功能：节点周期性地随机选择主题成员，发送消息存储中最近一段窗口内的消息 ID 摘要（完整 ID 列表或布隆过滤器），
+// 对方返回摘要中缺少的消息。补发的消息按追赶补发处理：经过正常的验证和去重后投递，但不会再次转发。
+// 窗口比 IHAVE gossip 的历史长得多，使分区期间错过的消息在分区恢复后最终被投递。
+
+package pubsub
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+	"github.com/dep2p/go-dep2p/p2plib/msgio"
+)
+
+const (
+	// AntiEntropyID 是反熵摘要交换使用的协议 ID
+	AntiEntropyID = protocol.ID("/dep2p/pubsub/antientropy/1.0.0")
+
+	// DefaultAntiEntropyInterval 是两次反熵同步之间的默认间隔
+	DefaultAntiEntropyInterval = 30 * time.Second
+	// DefaultAntiEntropyWindow 是默认参与同步的每个主题最近消息数
+	DefaultAntiEntropyWindow = 1024
+)
+
+// 摘要类型
+const (
+	digestIDList = 0 // 完整的消息 ID 列表
+	digestBloom  = 1 // 加盐的布隆过滤器
+)
+
+const (
+	// bloomDigestSaltLen 是布隆过滤器摘要的盐长度
+	bloomDigestSaltLen = 8
+	// bloomDigestMaxHashes 是布隆过滤器摘要允许的最大哈希函数个数
+	bloomDigestMaxHashes = 64
+)
+
+// antiEntropyParams 是反熵同步的参数
+type antiEntropyParams struct {
+	interval time.Duration // 同步间隔
+	window   int           // 每个主题参与同步的最近消息数
+}
+
+// WithAntiEntropy 是一个选项，启用反熵同步：每隔 interval 对每个已加入的主题随机选择一个成员交换消息 ID 摘要，
+// 并拉取本地缺少的消息。需要通过 WithMessageStore 配置消息存储。
+// 参数:
+//   - interval: 同步间隔，为 0 时使用 DefaultAntiEntropyInterval
+//   - window: 每个主题参与同步的最近消息数，为 0 时使用 DefaultAntiEntropyWindow
+//
+// 返回值:
+//   - Option: 配置选项
+func WithAntiEntropy(interval time.Duration, window int) Option {
+	return func(p *PubSub) error {
+		if interval < 0 || window < 0 {
+			logger.Warnf("无效的反熵参数: 间隔 %s, 窗口 %d", interval, window)
+			return fmt.Errorf("无效的反熵参数: 间隔 %s, 窗口 %d", interval, window)
+		}
+		if interval == 0 {
+			interval = DefaultAntiEntropyInterval
+		}
+		if window == 0 {
+			window = DefaultAntiEntropyWindow
+		}
+		p.antiEntropy = &antiEntropyParams{interval: interval, window: window}
+		return nil
+	}
+}
+
+// WithAntiEntropyBloomDigest 是一个选项，反熵同步时发送布隆过滤器而不是完整的消息 ID 列表。
+// 每个消息 ID 只占约 -log2(fpRate)/ln2 位，远小于 ID 本身；对方误认为本地已有的消息会在这一轮被漏掉，
+// 但每次同步使用新的随机盐，漏掉的消息会在之后的同步中以很高的概率补齐。
+// 参数:
+//   - fpRate: 假阳性率，取值范围为 (0, 1)
+//
+// 返回值:
+//   - Option: 配置选项
+func WithAntiEntropyBloomDigest(fpRate float64) Option {
+	return func(p *PubSub) error {
+		if !(fpRate > 0 && fpRate < 1) {
+			logger.Warnf("无效的布隆过滤器假阳性率: %f", fpRate)
+			return fmt.Errorf("无效的布隆过滤器假阳性率: %f", fpRate)
+		}
+		p.antiEntropyFPRate = fpRate
+		return nil
+	}
+}
+
+// Reconcile 与主题成员交换消息 ID 摘要，并将对方返回的本地缺少的消息注入验证管道。
+// 如果没有指定对等节点，则依次尝试当前主题中已连接的对等节点，直到有一个成功返回。
+// 参数:
+//   - ctx: 上下文
+//   - peers: 可选的目标对等节点列表
+//
+// 返回值:
+//   - int: 收到的消息数量
+//   - error: 错误信息，如果有的话
+func (t *Topic) Reconcile(ctx context.Context, peers ...peer.ID) (int, error) {
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
+		return 0, ErrTopicClosed
+	}
+	if t.p.store == nil {
+		logger.Warnf("反熵同步需要消息存储")
+		return 0, fmt.Errorf("反熵同步需要消息存储")
+	}
+
+	if len(peers) == 0 {
+		peers = t.p.ListPeers(t.topic)
+	}
+	if len(peers) == 0 {
+		return 0, fmt.Errorf("主题 %s 没有可以同步的对等节点", t.topic)
+	}
+
+	var lastErr error
+	for _, pid := range peers {
+		msgs, err := t.p.requestReconcile(ctx, pid, t.topic)
+		if err == nil {
+			t.p.injectCatchUp(pid, msgs)
+			return len(msgs), nil
+		}
+		logger.Debugf("与 %s 同步主题 %s 失败: %s", pid, t.topic, err)
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+	return 0, lastErr
+}
+
+// antiEntropyLoop 周期性地对每个已加入的主题与一个随机成员同步
+func (p *PubSub) antiEntropyLoop() {
+	ticker := time.NewTicker(p.antiEntropy.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.antiEntropyRound()
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// antiEntropyRound 对每个已加入的主题与一个随机成员同步一次
+func (p *PubSub) antiEntropyRound() {
+	res := make(chan []*Topic, 1)
+	select {
+	case p.eval <- func() {
+		topics := make([]*Topic, 0, len(p.myTopics))
+		for _, t := range p.myTopics {
+			topics = append(topics, t)
+		}
+		res <- topics
+	}:
+	case <-p.ctx.Done():
+		return
+	}
+
+	var topics []*Topic
+	select {
+	case topics = <-res:
+	case <-p.ctx.Done():
+		return
+	}
+
+	for _, t := range topics {
+		peers := p.ListPeers(t.topic)
+		if len(peers) == 0 {
+			continue
+		}
+		pid := peers[rand.Intn(len(peers))]
+		n, err := t.Reconcile(p.ctx, pid)
+		if err != nil {
+			logger.Debugf("主题 %s 的反熵同步失败: %s", t.topic, err)
+			continue
+		}
+		if n > 0 {
+			logger.Debugf("主题 %s 的反熵同步从 %s 收到 %d 条消息", t.topic, pid, n)
+		}
+	}
+}
+
+// antiEntropyWindow 返回每个主题参与同步的最近消息数
+// 返回值:
+//   - int: 窗口大小
+func (p *PubSub) antiEntropyWindow() int {
+	if p.antiEntropy != nil {
+		return p.antiEntropy.window
+	}
+	return DefaultAntiEntropyWindow
+}
+
+// localDigest 返回本地存储中主题最近消息的 ID 摘要
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - []byte: 编码后的摘要
+//   - error: 错误信息
+func (p *PubSub) localDigest(topic string) ([]byte, error) {
+	stored, err := p.store.Since(topic, "", p.antiEntropyWindow())
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(stored))
+	for _, sm := range stored {
+		ids = append(ids, sm.ID)
+	}
+	if p.antiEntropyFPRate > 0 {
+		return encodeBloomDigest(ids, p.antiEntropyFPRate)
+	}
+	return encodeIDList(ids), nil
+}
+
+// requestReconcile 向对等节点发送主题的消息 ID 摘要，并读取对方返回的本地缺少的消息
+// 参数:
+//   - ctx: 上下文
+//   - pid: 对等节点 ID
+//   - topic: 主题
+//
+// 返回值:
+//   - []*pb.Message: 本地缺少的消息
+//   - error: 错误信息
+func (p *PubSub) requestReconcile(ctx context.Context, pid peer.ID, topic string) ([]*pb.Message, error) {
+	digest, err := p.localDigest(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CatchUpTimeout)
+	defer cancel()
+
+	s, err := p.host.NewStream(ctx, pid, p.prefixed(AntiEntropyID))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	w := msgio.NewVarintWriter(s)
+	if err := w.WriteMsg([]byte(topic)); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if err := w.WriteMsg(digest); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite() // 请求已发送完毕
+
+	var msgs []*pb.Message
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	for len(msgs) < DefaultCatchUpLimit {
+		buf, err := r.ReadMsg()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Reset()
+			return nil, err
+		}
+
+		pmsg := new(pb.Message)
+		err = pmsg.Unmarshal(buf)
+		r.ReleaseMsg(buf)
+		if err != nil {
+			s.Reset()
+			return nil, fmt.Errorf("无效的反熵消息: %w", err)
+		}
+		if pmsg.GetTopic() != topic {
+			s.Reset()
+			return nil, fmt.Errorf("对等节点 %s 返回了其他主题 %s 的消息", pid, pmsg.GetTopic())
+		}
+		msgs = append(msgs, pmsg)
+	}
+
+	return msgs, nil
+}
+
+// handleAntiEntropyStream 处理反熵摘要交换流，返回对方摘要中缺少的消息
+// 参数:
+//   - s: 网络流
+func (p *PubSub) handleAntiEntropyStream(s network.Stream) {
+	defer s.Close()
+
+	s.SetDeadline(time.Now().Add(CatchUpTimeout))
+
+	from := s.Conn().RemotePeer()
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	var req [2][]byte // 主题和摘要
+	for i := range req {
+		buf, err := r.ReadMsg()
+		if err != nil {
+			logger.Debugf("从 %s 读取反熵摘要失败: %s", from, err)
+			s.Reset()
+			return
+		}
+		req[i] = append([]byte(nil), buf...)
+		r.ReleaseMsg(buf)
+	}
+	topic := string(req[0])
+
+	if p.store == nil || !p.servesTopic(from, topic) {
+		s.Reset()
+		return
+	}
+	has, err := decodeDigest(req[1])
+	if err != nil {
+		logger.Debugf("来自 %s 的反熵摘要无效: %s", from, err)
+		s.Reset()
+		return
+	}
+	stored, err := p.store.Since(topic, "", p.antiEntropyWindow())
+	if err != nil {
+		logger.Debugf("读取主题 %s 的存储消息失败: %s", topic, err)
+		s.Reset()
+		return
+	}
+
+	w := msgio.NewVarintWriter(s)
+	sent := 0
+	for _, sm := range stored {
+		if sent >= DefaultCatchUpLimit {
+			break
+		}
+		if has(sm.ID) || !visibleTo(sm.Message, from) {
+			continue
+		}
+		buf, err := sm.Message.Marshal()
+		if err != nil || len(buf) > p.maxMessageSize {
+			continue
+		}
+		if err := w.WriteMsg(buf); err != nil {
+			logger.Debugf("向 %s 写入反熵消息失败: %s", from, err)
+			s.Reset()
+			return
+		}
+		sent++
+	}
+}
+
+// encodeIDList 将消息 ID 列表编码为摘要。
+// 格式：摘要类型 | 每个 ID 的长度（uvarint）和内容。
+// 参数:
+//   - ids: 消息 ID 列表
+//
+// 返回值:
+//   - []byte: 编码后的摘要
+func encodeIDList(ids []string) []byte {
+	out := []byte{digestIDList}
+	for _, id := range ids {
+		out = binary.AppendUvarint(out, uint64(len(id)))
+		out = append(out, id...)
+	}
+	return out
+}
+
+// encodeBloomDigest 将消息 ID 列表编码为加盐的布隆过滤器摘要。
+// 格式：摘要类型 | 盐 | 位数（uvarint）| 哈希函数个数（uvarint）| 位数组（每 8 字节一个小端序字）。
+// 参数:
+//   - ids: 消息 ID 列表
+//   - fpRate: 假阳性率
+//
+// 返回值:
+//   - []byte: 编码后的摘要
+//   - error: 错误信息
+func encodeBloomDigest(ids []string, fpRate float64) ([]byte, error) {
+	salt := make([]byte, bloomDigestSaltLen)
+	if _, err := crand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	bf := newBloomFilter(len(ids), fpRate)
+	for _, id := range ids {
+		bf.Add(string(salt) + id)
+	}
+
+	out := append([]byte{digestBloom}, salt...)
+	out = binary.AppendUvarint(out, bf.m)
+	out = binary.AppendUvarint(out, uint64(bf.k))
+	for _, word := range bf.bits {
+		out = binary.LittleEndian.AppendUint64(out, word)
+	}
+	return out, nil
+}
+
+// decodeDigest 解码摘要
+// 参数:
+//   - data: 编码后的摘要
+//
+// 返回值:
+//   - func(string) bool: 判断对方是否已有消息的函数
+//   - error: 错误信息
+func decodeDigest(data []byte) (func(string) bool, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("空的摘要")
+	}
+
+	switch data[0] {
+	case digestIDList:
+		ids := make(map[string]struct{})
+		data = data[1:]
+		for len(data) > 0 {
+			n, k := binary.Uvarint(data)
+			if k <= 0 || uint64(len(data)-k) < n {
+				return nil, fmt.Errorf("摘要被截断")
+			}
+			ids[string(data[k:k+int(n)])] = struct{}{}
+			data = data[k+int(n):]
+		}
+		return func(id string) bool {
+			_, ok := ids[id]
+			return ok
+		}, nil
+
+	case digestBloom:
+		data = data[1:]
+		if len(data) < bloomDigestSaltLen {
+			return nil, fmt.Errorf("摘要被截断")
+		}
+		salt := string(data[:bloomDigestSaltLen])
+		data = data[bloomDigestSaltLen:]
+
+		m, k1 := binary.Uvarint(data)
+		if k1 <= 0 {
+			return nil, fmt.Errorf("摘要被截断")
+		}
+		k, k2 := binary.Uvarint(data[k1:])
+		if k2 <= 0 {
+			return nil, fmt.Errorf("摘要被截断")
+		}
+		data = data[k1+k2:]
+		if m == 0 || k == 0 || k > bloomDigestMaxHashes || uint64(len(data)) != (m+63)/64*8 {
+			return nil, fmt.Errorf("无效的布隆过滤器参数: 位数 %d, 哈希函数个数 %d", m, k)
+		}
+
+		bf := &bloomFilter{
+			bits: make([]uint64, len(data)/8),
+			m:    m,
+			k:    int(k),
+		}
+		for i := range bf.bits {
+			bf.bits[i] = binary.LittleEndian.Uint64(data[i*8:])
+		}
+		return func(id string) bool {
+			return bf.Has(salt + id)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("不支持的摘要类型 %d", data[0])
+	}
+}
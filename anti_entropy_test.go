@@ -0,0 +1,192 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAntiEntropyDigest(t *testing.T) {
+	ids := []string{"a", "bb", string(make([]byte, 300))}
+	has, err := decodeDigest(encodeIDList(ids))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if !has(id) {
+			t.Fatalf("expected digest to contain %q", id)
+		}
+	}
+	if has("c") {
+		t.Fatal("unexpected id in digest")
+	}
+
+	if _, err := decodeDigest(encodeIDList(ids)[:5]); err == nil {
+		t.Fatal("expected error decoding truncated digest")
+	}
+	if _, err := decodeDigest([]byte{0xff}); err == nil {
+		t.Fatal("expected error decoding unknown digest kind")
+	}
+}
+
+func TestAntiEntropyBloomDigest(t *testing.T) {
+	var ids []string
+	for i := 0; i < 1000; i++ {
+		ids = append(ids, fmt.Sprintf("peer-%d", i))
+	}
+	digest, err := encodeBloomDigest(ids, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full := encodeIDList(ids); len(digest)*4 > len(full) {
+		t.Fatalf("bloom digest too large: %d bytes, id list is %d bytes", len(digest), len(full))
+	}
+
+	has, err := decodeDigest(digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range ids {
+		if !has(id) {
+			t.Fatalf("expected digest to contain %q", id)
+		}
+	}
+	fp := 0
+	for i := 0; i < 1000; i++ {
+		if has(fmt.Sprintf("missing-%d", i)) {
+			fp++
+		}
+	}
+	if fp > 50 {
+		t.Fatalf("too many false positives: %d", fp)
+	}
+
+	if _, err := decodeDigest(digest[:len(digest)-1]); err == nil {
+		t.Fatal("expected error decoding truncated digest")
+	}
+
+	// 每个摘要使用不同的盐
+	other, err := encodeBloomDigest(ids, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(other) == string(digest) {
+		t.Fatal("expected digests to use different salts")
+	}
+
+	hosts := getDefaultHosts(t, 1)
+	for _, fpRate := range []float64{0, 1, -0.5} {
+		if _, err := NewFloodSub(context.Background(), hosts[0], WithAntiEntropyBloomDigest(fpRate)); err == nil {
+			t.Fatalf("expected error for false positive rate %f", fpRate)
+		}
+	}
+}
+
+func TestAntiEntropyRequiresStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithAntiEntropy(time.Second, 0)); err == nil {
+		t.Fatal("expected error enabling anti-entropy without a message store")
+	}
+}
+
+func TestAntiEntropyAfterPartition(t *testing.T) {
+	testAntiEntropyAfterPartition(t)
+}
+
+func TestAntiEntropyAfterPartitionBloom(t *testing.T) {
+	testAntiEntropyAfterPartition(t, WithAntiEntropyBloomDigest(0.001))
+}
+
+func testAntiEntropyAfterPartition(t *testing.T, opts ...Option) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	var psubs []*PubSub
+	for _, h := range hosts {
+		store, err := NewFileMessageStore(t.TempDir(), 64, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		opts := append([]Option{WithMessageStore(store), WithAntiEntropy(200*time.Millisecond, 0)}, opts...)
+		psubs = append(psubs, getPubsub(ctx, h, opts...))
+	}
+
+	topics := getTopics(psubs, "foobar")
+	var subs []*Subscription
+	for _, topic := range topics {
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// 分区期间两个节点各自发布消息
+	for i, topic := range topics {
+		for j := 0; j < 3; j++ {
+			msg := []byte(fmt.Sprintf("node%d-msg%d", i, j))
+			if err := topic.Publish(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+			assertReceive(t, subs[i], msg)
+		}
+	}
+
+	// 分区恢复后反熵同步补齐双方缺少的消息
+	connect(t, hosts[0], hosts[1])
+	for i, sub := range subs {
+		other := 1 - i
+		for j := 0; j < 3; j++ {
+			assertReceive(t, sub, []byte(fmt.Sprintf("node%d-msg%d", other, j)))
+		}
+	}
+
+	// 已经同步的消息不会被重复投递
+	for _, sub := range subs {
+		assertNeverReceives(t, sub, time.Second)
+	}
+}
+
+func TestAntiEntropyRequiresSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	var psubs []*PubSub
+	for _, h := range hosts {
+		store, err := NewFileMessageStore(t.TempDir(), 16, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		psubs = append(psubs, getPubsub(ctx, h, WithMessageStore(store)))
+	}
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if err := topic.Publish(ctx, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("secret"))
+
+	// 未订阅主题的节点通过反熵摘要也得不到存储的消息
+	msgs, _ := psubs[1].requestReconcile(ctx, hosts[0].ID(), "foobar")
+	if len(msgs) != 0 {
+		t.Fatalf("unsubscribed peer reconciled %d messages", len(msgs))
+	}
+}
@@ -0,0 +1,538 @@
+// 作用：门限消息证明。
+// 功能：订阅者用自己的密钥对收到的消息共同签名，并将证明发布到伴随主题；收集者按授权签名者集合验证证明，
+// 收到同一消息的 k 个签名者的证明后组装为证书，为应用程序提供轻量级 BFT 广播确认的基础组件。
+
+package pubsub
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+const (
+	// AttestationTopicSuffix 是伴随主题名称的后缀
+	AttestationTopicSuffix = "/attestations"
+
+	// DefaultAttestationTimeout 是收集一条消息的证明的默认时间，超时后丢弃已收到的证明
+	DefaultAttestationTimeout = 2 * time.Minute
+	// DefaultAttestationMaxPending 是同时收集证明的消息数上限
+	DefaultAttestationMaxPending = 1024
+
+	// attestationVersion 是证明封装的格式版本
+	attestationVersion = 1
+	// attestationPrefix 是证明签名的前缀
+	attestationPrefix = "dep2p-pubsub-attest:"
+)
+
+// ErrAttestationCollectorClosed 表示证明收集者已关闭
+var ErrAttestationCollectorClosed = errors.New("证明收集者已关闭")
+
+// AttestationTopic 返回主题的伴随主题名称，证明在伴随主题上发布
+// 参数:
+//   - topic: 被证明消息所在的主题
+//
+// 返回值:
+//   - string: 伴随主题名称
+func AttestationTopic(topic string) string {
+	return topic + AttestationTopicSuffix
+}
+
+// Attestation 是一个签名者对一条消息的证明
+type Attestation struct {
+	Topic     string  // 被证明消息所在的主题
+	MsgID     string  // 被证明消息的 ID
+	Digest    []byte  // 被证明消息数据的 SHA-256 摘要
+	Signer    peer.ID // 签名者
+	Signature []byte  // 签名
+}
+
+// AttestationCertificate 是一条消息的门限证明，包含至少 Threshold 个授权签名者的签名
+type AttestationCertificate struct {
+	Topic      string             // 被证明消息所在的主题
+	MsgID      string             // 被证明消息的 ID
+	Digest     []byte             // 被证明消息数据的 SHA-256 摘要
+	Threshold  int                // 门限
+	Signatures map[peer.ID][]byte // 签名者到签名的映射
+}
+
+// Attestor 对收到的消息签名，并在伴随主题上发布证明
+type Attestor struct {
+	t   *Topic         // 伴随主题
+	key crypto.PrivKey // 签名密钥
+}
+
+// NewAttestor 创建证明者
+// 参数:
+//   - t: 伴随主题句柄，通常为 AttestationTopic(topic) 的句柄
+//   - key: 签名密钥，收集者按其公钥识别签名者
+//
+// 返回值:
+//   - *Attestor: 证明者
+//   - error: 错误信息，如果有的话
+func NewAttestor(t *Topic, key crypto.PrivKey) (*Attestor, error) {
+	if t == nil {
+		logger.Warnf("伴随主题不能为空")
+		return nil, fmt.Errorf("伴随主题不能为空")
+	}
+	if key == nil {
+		logger.Warnf("证明签名密钥不能为空")
+		return nil, fmt.Errorf("证明签名密钥不能为空")
+	}
+	return &Attestor{t: t, key: key}, nil
+}
+
+// Attest 对消息签名并在伴随主题上发布证明。应用程序应在自行确认消息有效之后调用。
+// 参数:
+//   - ctx: 上下文
+//   - msg: 收到的消息
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (a *Attestor) Attest(ctx context.Context, msg *Message) error {
+	if msg == nil || msg.ID == "" {
+		logger.Warnf("被证明的消息必须有消息 ID")
+		return fmt.Errorf("被证明的消息必须有消息 ID")
+	}
+	digest := sha256.Sum256(msg.GetData())
+	topic := msg.GetTopic()
+
+	sig, err := a.key.Sign(attestationSigningBytes(topic, msg.ID, digest[:]))
+	if err != nil {
+		logger.Warnf("签署消息 %s 的证明失败: %s", msg.ID, err)
+		return err
+	}
+	pub, err := crypto.MarshalPublicKey(a.key.GetPublic())
+	if err != nil {
+		return err
+	}
+
+	return a.t.Publish(ctx, encodeAttestation(topic, msg.ID, digest[:], pub, sig))
+}
+
+// AttestationCollector 从伴随主题收集证明，在达到门限时组装证书
+type AttestationCollector struct {
+	sub       *Subscription
+	signers   map[peer.ID]crypto.PubKey // 授权签名者
+	threshold int
+	timeout   time.Duration
+
+	ctx    context.Context
+	cancel func()
+	certs  chan *AttestationCertificate // 已完成的证书
+
+	mx      sync.Mutex
+	pending map[string]*attestationState              // 正在收集的消息，按主题和消息 ID 索引
+	done    map[string]*attestationDone               // 已完成的证书
+	waiters map[string][]chan *AttestationCertificate // 等待证书的调用方
+}
+
+// attestationDone 是已完成的证书，保留到超时以便 Wait 查询并忽略多余的证明
+type attestationDone struct {
+	cert *AttestationCertificate
+	at   time.Time
+}
+
+// attestationState 是一条消息正在收集的证明，按摘要分组，使不同数据的证明不会混在一起
+type attestationState struct {
+	start   time.Time
+	digests map[string]map[peer.ID][]byte
+}
+
+// NewAttestationCollector 创建证明收集者并订阅伴随主题
+// 参数:
+//   - t: 伴随主题句柄
+//   - signers: 授权签名者的公钥
+//   - threshold: 组装证书需要的签名者数 k
+//
+// 返回值:
+//   - *AttestationCollector: 证明收集者
+//   - error: 错误信息，如果有的话
+func NewAttestationCollector(t *Topic, signers []crypto.PubKey, threshold int) (*AttestationCollector, error) {
+	if t == nil {
+		logger.Warnf("伴随主题不能为空")
+		return nil, fmt.Errorf("伴随主题不能为空")
+	}
+	if threshold <= 0 || threshold > len(signers) {
+		logger.Warnf("无效的证明门限: %d/%d", threshold, len(signers))
+		return nil, fmt.Errorf("无效的证明门限: %d/%d", threshold, len(signers))
+	}
+
+	keys := make(map[peer.ID]crypto.PubKey, len(signers))
+	for _, key := range signers {
+		if key == nil {
+			logger.Warnf("签名者公钥不能为空")
+			return nil, fmt.Errorf("签名者公钥不能为空")
+		}
+		id, err := peer.IDFromPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = key
+	}
+	if threshold > len(keys) {
+		logger.Warnf("无效的证明门限: %d/%d", threshold, len(keys))
+		return nil, fmt.Errorf("无效的证明门限: %d/%d", threshold, len(keys))
+	}
+
+	sub, err := t.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(t.p.ctx)
+	c := &AttestationCollector{
+		sub:       sub,
+		signers:   keys,
+		threshold: threshold,
+		timeout:   DefaultAttestationTimeout,
+		ctx:       ctx,
+		cancel:    cancel,
+		certs:     make(chan *AttestationCertificate, 32),
+		pending:   make(map[string]*attestationState),
+		done:      make(map[string]*attestationDone),
+		waiters:   make(map[string][]chan *AttestationCertificate),
+	}
+	go c.loop()
+	return c, nil
+}
+
+// Next 返回下一个组装完成的证书
+// 参数:
+//   - ctx: 上下文
+//
+// 返回值:
+//   - *AttestationCertificate: 证书
+//   - error: 错误信息，如果有的话
+func (c *AttestationCollector) Next(ctx context.Context) (*AttestationCertificate, error) {
+	select {
+	case cert := <-c.certs:
+		return cert, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrAttestationCollectorClosed
+	}
+}
+
+// Wait 等待消息的证书
+// 参数:
+//   - ctx: 上下文
+//   - topic: 被证明消息所在的主题
+//   - msgID: 被证明消息的 ID
+//
+// 返回值:
+//   - *AttestationCertificate: 证书
+//   - error: 错误信息，如果有的话
+func (c *AttestationCollector) Wait(ctx context.Context, topic, msgID string) (*AttestationCertificate, error) {
+	key := attestationKey(topic, msgID)
+
+	c.mx.Lock()
+	if d, ok := c.done[key]; ok {
+		c.mx.Unlock()
+		return d.cert, nil
+	}
+	ch := make(chan *AttestationCertificate, 1)
+	c.waiters[key] = append(c.waiters[key], ch)
+	c.mx.Unlock()
+
+	select {
+	case cert := <-ch:
+		return cert, nil
+	case <-ctx.Done():
+		c.removeWaiter(key, ch)
+		return nil, ctx.Err()
+	case <-c.ctx.Done():
+		return nil, ErrAttestationCollectorClosed
+	}
+}
+
+// removeWaiter 移除放弃等待的调用方
+// 参数:
+//   - key: 索引键
+//   - ch: 调用方的通知通道
+func (c *AttestationCollector) removeWaiter(key string, ch chan *AttestationCertificate) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	waiters := c.waiters[key]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(c.waiters, key)
+	} else {
+		c.waiters[key] = waiters
+	}
+}
+
+// Close 取消订阅伴随主题并停止收集
+func (c *AttestationCollector) Close() {
+	c.cancel()
+	c.sub.Cancel()
+}
+
+// loop 读取伴随主题上的证明
+func (c *AttestationCollector) loop() {
+	for {
+		msg, err := c.sub.Next(c.ctx)
+		if err != nil {
+			return
+		}
+		att, err := decodeAttestation(msg.GetData())
+		if err != nil {
+			logger.Debugf("忽略来自 %s 的无效证明: %s", msg.ReceivedFrom, err)
+			continue
+		}
+		if cert := c.add(att, time.Now()); cert != nil {
+			select {
+			case c.certs <- cert:
+			default:
+				logger.Debugf("证书队列已满；丢弃消息 %s 的证书通知", cert.MsgID)
+			}
+		}
+	}
+}
+
+// add 验证并记录一个证明，达到门限时返回证书
+// 参数:
+//   - att: 证明
+//   - now: 当前时间
+//
+// 返回值:
+//   - *AttestationCertificate: 证书，尚未达到门限时为 nil
+func (c *AttestationCollector) add(att *Attestation, now time.Time) *AttestationCertificate {
+	key, ok := c.signers[att.Signer]
+	if !ok {
+		logger.Debugf("忽略未授权签名者 %s 的证明", att.Signer)
+		return nil
+	}
+	if valid, err := key.Verify(attestationSigningBytes(att.Topic, att.MsgID, att.Digest), att.Signature); err != nil || !valid {
+		logger.Debugf("忽略签名者 %s 的无效证明", att.Signer)
+		return nil
+	}
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	c.expire(now)
+
+	msgKey := attestationKey(att.Topic, att.MsgID)
+	if _, ok := c.done[msgKey]; ok {
+		return nil
+	}
+	st, ok := c.pending[msgKey]
+	if !ok {
+		if len(c.pending) >= DefaultAttestationMaxPending {
+			c.evictOldest()
+		}
+		st = &attestationState{start: now, digests: make(map[string]map[peer.ID][]byte)}
+		c.pending[msgKey] = st
+	}
+	sigs, ok := st.digests[string(att.Digest)]
+	if !ok {
+		sigs = make(map[peer.ID][]byte)
+		st.digests[string(att.Digest)] = sigs
+	}
+	sigs[att.Signer] = att.Signature
+	if len(sigs) < c.threshold {
+		return nil
+	}
+
+	cert := &AttestationCertificate{
+		Topic:      att.Topic,
+		MsgID:      att.MsgID,
+		Digest:     att.Digest,
+		Threshold:  c.threshold,
+		Signatures: sigs,
+	}
+	delete(c.pending, msgKey)
+	c.done[msgKey] = &attestationDone{cert: cert, at: now}
+	for _, ch := range c.waiters[msgKey] {
+		ch <- cert
+	}
+	delete(c.waiters, msgKey)
+	return cert
+}
+
+// expire 丢弃超时的收集状态和证书。调用方必须持有 c.mx
+// 参数:
+//   - now: 当前时间
+func (c *AttestationCollector) expire(now time.Time) {
+	for key, st := range c.pending {
+		if now.Sub(st.start) > c.timeout {
+			delete(c.pending, key)
+		}
+	}
+	for key, d := range c.done {
+		if now.Sub(d.at) > c.timeout {
+			delete(c.done, key)
+		}
+	}
+}
+
+// evictOldest 丢弃最早开始收集的消息。调用方必须持有 c.mx
+func (c *AttestationCollector) evictOldest() {
+	var oldest string
+	var start time.Time
+	for key, st := range c.pending {
+		if start.IsZero() || st.start.Before(start) {
+			oldest, start = key, st.start
+		}
+	}
+	delete(c.pending, oldest)
+}
+
+// Verify 验证证书包含至少 threshold 个不同的给定签名者的有效签名，重复的签名者只计一次
+// 参数:
+//   - signers: 授权签名者的公钥
+//   - threshold: 门限
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (cert *AttestationCertificate) Verify(signers []crypto.PubKey, threshold int) error {
+	data := attestationSigningBytes(cert.Topic, cert.MsgID, cert.Digest)
+	valid := 0
+	counted := make(map[peer.ID]struct{}, len(signers))
+	for _, key := range signers {
+		id, err := peer.IDFromPublicKey(key)
+		if err != nil {
+			continue
+		}
+		if _, dup := counted[id]; dup {
+			continue
+		}
+		counted[id] = struct{}{}
+		sig, ok := cert.Signatures[id]
+		if !ok {
+			continue
+		}
+		if ok, err := key.Verify(data, sig); err == nil && ok {
+			valid++
+		}
+	}
+	if valid < threshold {
+		return fmt.Errorf("消息 %s 的证书只有 %d 个有效签名，需要 %d 个", cert.MsgID, valid, threshold)
+	}
+	return nil
+}
+
+// attestationKey 返回证明的索引键
+// 参数:
+//   - topic: 主题
+//   - msgID: 消息 ID
+//
+// 返回值:
+//   - string: 索引键
+func attestationKey(topic, msgID string) string {
+	return topic + "\x00" + msgID
+}
+
+// attestationSigningBytes 返回证明签名的字节
+// 参数:
+//   - topic: 主题
+//   - msgID: 消息 ID
+//   - digest: 消息数据摘要
+//
+// 返回值:
+//   - []byte: 签名字节
+func attestationSigningBytes(topic, msgID string, digest []byte) []byte {
+	out := []byte(attestationPrefix)
+	out = binary.AppendUvarint(out, uint64(len(topic)))
+	out = append(out, topic...)
+	out = binary.AppendUvarint(out, uint64(len(msgID)))
+	out = append(out, msgID...)
+	return append(out, digest...)
+}
+
+// encodeAttestation 编码证明。
+// 格式：版本 | 主题长度（uvarint）| 主题 | 消息 ID 长度（uvarint）| 消息 ID | 摘要（32 字节）| 公钥长度（uvarint）| 公钥 | 签名。
+// 参数:
+//   - topic: 主题
+//   - msgID: 消息 ID
+//   - digest: 消息数据摘要
+//   - pub: 编码后的签名者公钥
+//   - sig: 签名
+//
+// 返回值:
+//   - []byte: 编码后的证明
+func encodeAttestation(topic, msgID string, digest, pub, sig []byte) []byte {
+	out := []byte{attestationVersion}
+	out = binary.AppendUvarint(out, uint64(len(topic)))
+	out = append(out, topic...)
+	out = binary.AppendUvarint(out, uint64(len(msgID)))
+	out = append(out, msgID...)
+	out = append(out, digest...)
+	out = binary.AppendUvarint(out, uint64(len(pub)))
+	out = append(out, pub...)
+	return append(out, sig...)
+}
+
+// decodeAttestation 解码证明
+// 参数:
+//   - data: 编码后的证明
+//
+// 返回值:
+//   - *Attestation: 证明
+//   - error: 错误信息，如果有的话
+func decodeAttestation(data []byte) (*Attestation, error) {
+	if len(data) == 0 || data[0] != attestationVersion {
+		return nil, fmt.Errorf("不支持的证明版本")
+	}
+	data = data[1:]
+
+	field := func() ([]byte, error) {
+		n, k := binary.Uvarint(data)
+		if k <= 0 || uint64(len(data)-k) < n {
+			return nil, fmt.Errorf("证明被截断")
+		}
+		out := data[k : k+int(n)]
+		data = data[k+int(n):]
+		return out, nil
+	}
+
+	topic, err := field()
+	if err != nil {
+		return nil, err
+	}
+	msgID, err := field()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < sha256.Size {
+		return nil, fmt.Errorf("证明被截断")
+	}
+	digest := data[:sha256.Size]
+	data = data[sha256.Size:]
+	pub, err := field()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := crypto.UnmarshalPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := peer.IDFromPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Attestation{
+		Topic:     string(topic),
+		MsgID:     string(msgID),
+		Digest:    digest,
+		Signer:    signer,
+		Signature: data,
+	}, nil
+}
@@ -0,0 +1,128 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+)
+
+// TestAttestation 测试订阅者共同签名后收集者组装 2-of-3 证书
+func TestAttestation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getPubsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	const topic = "foobar"
+	companion := getTopics(psubs, AttestationTopic(topic))
+
+	var privs []crypto.PrivKey
+	var pubs []crypto.PubKey
+	for i := 0; i < 3; i++ {
+		priv, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		privs = append(privs, priv)
+		pubs = append(pubs, pub)
+	}
+
+	collector, err := NewAttestationCollector(companion[0], pubs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer collector.Close()
+
+	// 节点 1 使用未授权的密钥，节点 2、3 是授权签名者
+	rogue, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := []crypto.PrivKey{rogue, privs[0], privs[1]}
+
+	topics := getTopics(psubs, topic)
+	for i := 1; i < 4; i++ {
+		sub, err := topics[i].Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		attestor, err := NewAttestor(companion[i], keys[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+			attestor.Attest(ctx, msg)
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	data := []byte("attest me")
+	if err := topics[0].Publish(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	wctx, wcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer wcancel()
+	cert, err := collector.Next(wctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Topic != topic || len(cert.Signatures) != 2 {
+		t.Fatalf("unexpected certificate for %s with %d signatures", cert.Topic, len(cert.Signatures))
+	}
+	digest := sha256.Sum256(data)
+	if !bytes.Equal(cert.Digest, digest[:]) {
+		t.Fatal("certificate digest does not match the message data")
+	}
+	if err := cert.Verify(pubs, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cert.Verify(pubs[2:], 1); err == nil {
+		t.Fatal("expected verification to fail without the signers")
+	}
+	if err := cert.Verify([]crypto.PubKey{pubs[0], pubs[0]}, 2); err == nil {
+		t.Fatal("expected a repeated signer to be counted once")
+	}
+
+	waited, err := collector.Wait(wctx, topic, cert.MsgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited != cert {
+		t.Fatal("expected Wait to return the completed certificate")
+	}
+}
+
+// TestAttestationCollectorThreshold 测试无效的门限
+func TestAttestationCollectorThreshold(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0])
+	topic, err := ps.Join(AttestationTopic("foobar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAttestationCollector(topic, []crypto.PubKey{pub}, 2); err == nil {
+		t.Fatal("expected error for a threshold above the number of signers")
+	}
+	if _, err := NewAttestationCollector(topic, []crypto.PubKey{pub, pub}, 2); err == nil {
+		t.Fatal("expected error for duplicate signers")
+	}
+}
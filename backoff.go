@@ -1,11 +1,13 @@
 // 作用：实现对等节点的退避（backoff）机制，用于处理消息发布失败时的重试策略。
-// 功能：管理对等节点的退避时间和逻辑，确保在消息发布失败后不会立即重试，而是等待一段时间再重试。
+// 功能：管理对等节点的退避时间和逻辑，确保在消息发布失败后不会立即重试，而是等待一段时间再重试；
+// 等待时间由可替换的 BackoffStrategy 计算，同一策略也用于主题发现的重试。
 
 package pubsub
 
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -24,6 +26,91 @@ const (
 	MaxBackoffAttempts     = 4                      // 最大退避尝试次数
 )
 
+// BackoffStrategy 计算同一目标连续失败后下一次重试前的等待时间。
+// 策略本身不保存状态，可以在多个对等节点和主题之间共享，必须是线程安全的。
+type BackoffStrategy interface {
+	// Delay 返回第 attempt 次重试（从 1 开始）前的等待时间
+	Delay(attempt int) time.Duration
+}
+
+// ExponentialBackoff 是带随机抖动的指数退避策略：第一次重试等待 Min，之后每次乘以 Multiplier
+// 并附加不超过 Jitter 的随机抖动，最长不超过 Max
+type ExponentialBackoff struct {
+	Min        time.Duration // 第一次重试前的等待时间
+	Max        time.Duration // 等待时间上限
+	Multiplier float64       // 每次重试的等待时间倍增因子，小于 1 时按 1 处理
+	Jitter     time.Duration // 从第二次重试起附加的最大随机抖动
+}
+
+// 确保 ExponentialBackoff 实现了 BackoffStrategy 接口
+var _ BackoffStrategy = (*ExponentialBackoff)(nil)
+
+// NewExponentialBackoff 创建带随机抖动的指数退避策略，倍增因子为 BackoffMultiplier，抖动为等待下限的十分之一
+// 参数:
+//   - min: 第一次重试前的等待时间
+//   - max: 等待时间上限
+//
+// 返回值:
+//   - *ExponentialBackoff: 指数退避策略
+func NewExponentialBackoff(min, max time.Duration) *ExponentialBackoff {
+	return &ExponentialBackoff{
+		Min:        min,
+		Max:        max,
+		Multiplier: BackoffMultiplier,
+		Jitter:     min / 10,
+	}
+}
+
+// Delay 返回第 attempt 次重试前的等待时间
+// 参数:
+//   - attempt: 重试次数，从 1 开始
+//
+// 返回值:
+//   - time.Duration: 等待时间
+func (eb *ExponentialBackoff) Delay(attempt int) time.Duration {
+	if attempt <= 1 {
+		return eb.Min
+	}
+
+	mult := math.Max(eb.Multiplier, 1)
+	d := float64(eb.Min) * math.Pow(mult, float64(attempt-1))
+	if eb.Jitter > 0 {
+		d += float64(rand.Int63n(int64(eb.Jitter)))
+	}
+	if d > float64(eb.Max) { // 同时处理溢出为正无穷的情况
+		return eb.Max
+	}
+	return time.Duration(d)
+}
+
+// defaultReconnectBackoff 是重新连接已断开对等节点的默认退避策略
+var defaultReconnectBackoff = &ExponentialBackoff{
+	Min:        MinBackoffDelay,
+	Max:        MaxBackoffDelay,
+	Multiplier: BackoffMultiplier,
+	Jitter:     MaxBackoffJitterCoff * time.Millisecond,
+}
+
+// WithDiscoveryBackoff 是一个选项，设置主题发现重试和对等节点重新连接使用的退避策略：
+// 断开后仍然连接的对等节点重新建立流、未通过 WithDiscoveryBootstrap 指定其他策略的主题查找、
+// 失败的主题广告以及 gossipsub 直接对等节点的重新连接都按该策略等待。
+// 参数:
+//   - strategy: 退避策略
+//
+// 返回值:
+//   - Option: 配置选项
+func WithDiscoveryBackoff(strategy BackoffStrategy) Option {
+	return func(p *PubSub) error {
+		if strategy == nil {
+			logger.Warnf("退避策略不能为空")
+			return fmt.Errorf("退避策略不能为空")
+		}
+		p.backoffStrategy = strategy
+		p.deadPeerBackoff.strategy = strategy
+		return nil
+	}
+}
+
 // backoffHistory 结构体记录了每个节点的退避历史
 type backoffHistory struct {
 	duration  time.Duration // 当前的退避持续时间
@@ -38,6 +125,7 @@ type backoff struct {
 	ct          int                         // 触发清理的大小阈值
 	ci          time.Duration               // 清理间隔
 	maxAttempts int                         // 最大退避尝试次数
+	strategy    BackoffStrategy             // 计算每次重试的等待时间
 }
 
 // newBackoff 函数创建并初始化一个新的 backoff 实例
@@ -57,6 +145,7 @@ func newBackoff(ctx context.Context, sizeThreshold int, cleanupInterval time.Dur
 		ci:          cleanupInterval,                   // 设置清理间隔
 		maxAttempts: maxAttempts,                       // 设置最大尝试次数
 		info:        make(map[peer.ID]*backoffHistory), // 初始化信息映射
+		strategy:    defaultReconnectBackoff,           // 默认的退避策略
 	}
 
 	rand.Seed(time.Now().UnixNano()) // 设置随机种子，用于退避时间的抖动
@@ -88,15 +177,8 @@ func (b *backoff) updateAndGet(id peer.ID) (time.Duration, error) {
 		logger.Errorf("对等节点 %s 已达到最大退避尝试次数", id)        // 记录错误
 		return 0, fmt.Errorf("对等节点 %s 已达到最大退避尝试次数", id) // 返回错误
 
-	case h.duration < MinBackoffDelay: // 如果当前退避时间小于最小退避延迟
-		h.duration = MinBackoffDelay // 设置为最小退避延迟
-
-	case h.duration < MaxBackoffDelay: // 如果当前退避时间小于最大退避延迟
-		jitter := rand.Intn(MaxBackoffJitterCoff)                                              // 生成抖动时间
-		h.duration = (BackoffMultiplier * h.duration) + time.Duration(jitter)*time.Millisecond // 计算新的退避时间
-		if h.duration > MaxBackoffDelay || h.duration < 0 {                                    // 检查退避时间是否超出范围
-			h.duration = MaxBackoffDelay // 超出范围则设置为最大退避延迟
-		}
+	default: // 按退避策略计算下一次的退避时间
+		h.duration = b.strategy.Delay(h.attempts)
 	}
 
 	h.attempts += 1          // 增加尝试次数
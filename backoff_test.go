@@ -123,3 +123,74 @@ func TestBackoff_Clean(t *testing.T) {
 		t.Fatalf("info map size mismatch, expected: %d, got: %d", 1, len(b.info))
 	}
 }
+
+func TestExponentialBackoff(t *testing.T) {
+	eb := &ExponentialBackoff{
+		Min:        100 * time.Millisecond,
+		Max:        time.Second,
+		Multiplier: 2,
+		Jitter:     10 * time.Millisecond,
+	}
+
+	if d := eb.Delay(1); d != eb.Min {
+		t.Fatalf("expected first delay %v, got %v", eb.Min, d)
+	}
+	for attempt := 2; attempt <= 4; attempt++ {
+		base := eb.Min * time.Duration(1<<(attempt-1))
+		if d := eb.Delay(attempt); d < base || d >= base+eb.Jitter {
+			t.Fatalf("attempt %d: expected delay in [%v, %v), got %v", attempt, base, base+eb.Jitter, d)
+		}
+	}
+	for _, attempt := range []int{5, 100, math.MaxInt32} {
+		if d := eb.Delay(attempt); d != eb.Max {
+			t.Fatalf("attempt %d: expected delay capped at %v, got %v", attempt, eb.Max, d)
+		}
+	}
+}
+
+type recordingBackoff struct {
+	attempts []int
+}
+
+func (rb *recordingBackoff) Delay(attempt int) time.Duration {
+	rb.attempts = append(rb.attempts, attempt)
+	return time.Duration(attempt) * time.Second
+}
+
+func TestBackoff_Strategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rb := &recordingBackoff{}
+	b := newBackoff(ctx, 10, time.Minute, 3)
+	b.strategy = rb
+
+	id := peer.ID("peer-1")
+	for i, expected := range []time.Duration{0, time.Second, 2 * time.Second} {
+		got, err := b.updateAndGet(id)
+		if err != nil {
+			t.Fatalf("unexpected error on attempt %d: %s", i, err)
+		}
+		if got != expected {
+			t.Fatalf("attempt %d: expected %v, got %v", i, expected, got)
+		}
+	}
+	if _, err := b.updateAndGet(id); err == nil {
+		t.Fatal("expected an error for going beyond threshold but got nil")
+	}
+	if len(rb.attempts) != 2 || rb.attempts[0] != 1 || rb.attempts[1] != 2 {
+		t.Fatalf("unexpected strategy attempts: %v", rb.attempts)
+	}
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithDiscoveryBackoff(nil)); err == nil {
+		t.Fatal("expected error for nil backoff strategy")
+	}
+	ps, err := NewFloodSub(ctx, hosts[0], WithDiscoveryBackoff(rb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ps.deadPeerBackoff.strategy != rb {
+		t.Fatal("expected dead peer backoff to use the configured strategy")
+	}
+}
@@ -0,0 +1,202 @@
+// 作用：出站带宽整形。
+// 功能：以全局和每个主题的字节令牌桶限制转发流量。超出预算时，发往该对等节点的转发消息和 IHAVE gossip 被丢弃，
+// 不会重新发送；对等节点仍可以从网格中的其他对等节点收到消息。
+// 本地发布的消息、兑现已发出 IHAVE 的 IWANT 回复、订阅信息以及 GRAFT/PRUNE/IWANT 等控制消息不受限制。
+
+package pubsub
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// Rate 表示字节速率限制
+type Rate struct {
+	BytesPerSec float64 // 每秒允许的字节数
+	Burst       int     // 令牌桶的容量，不能小于最大消息大小；为 0 时取每秒字节数与最大消息大小中的较大者
+}
+
+// bandwidthShaper 记录出站带宽的令牌桶。只从 processLoop 访问。
+type bandwidthShaper struct {
+	global   Rate            // 全局速率，BytesPerSec 为 0 表示不限制
+	perTopic map[string]Rate // 每个主题的速率
+
+	globalBucket tokenBucket
+	topicBuckets map[string]*tokenBucket
+}
+
+// WithBandwidthLimit 是一个选项，用于限制转发流量的出站带宽。
+// 参数:
+//   - global: 全局速率，BytesPerSec 为 0 表示不限制全局带宽
+//   - perTopic: 每个主题的速率，可以为 nil
+//
+// 返回值:
+//   - Option: 配置选项
+func WithBandwidthLimit(global Rate, perTopic map[string]Rate) Option {
+	return func(p *PubSub) error {
+		if global.BytesPerSec < 0 || global.Burst < 0 {
+			logger.Warnf("无效的全局带宽限制: %v", global)
+			return fmt.Errorf("无效的全局带宽限制: %v", global)
+		}
+		for topic, r := range perTopic {
+			if r.BytesPerSec <= 0 || r.Burst < 0 {
+				logger.Warnf("主题 %s 的带宽限制无效: %v", topic, r)
+				return fmt.Errorf("主题 %s 的带宽限制无效: %v", topic, r)
+			}
+		}
+		if global.BytesPerSec == 0 && len(perTopic) == 0 {
+			logger.Warnf("至少需要设置一个带宽限制")
+			return fmt.Errorf("至少需要设置一个带宽限制")
+		}
+
+		bs := &bandwidthShaper{
+			global:       global,
+			perTopic:     make(map[string]Rate, len(perTopic)),
+			topicBuckets: make(map[string]*tokenBucket, len(perTopic)),
+		}
+		for topic, r := range perTopic {
+			bs.perTopic[topic] = r
+			bs.topicBuckets[topic] = new(tokenBucket)
+		}
+		p.bandwidth = bs
+		return nil
+	}
+}
+
+// check 检查令牌桶容量能否容纳最大的消息，否则这样的消息永远无法转发。nil 整形器总是返回 nil
+// 参数:
+//   - maxMessageSize: 允许的最大消息大小
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (bs *bandwidthShaper) check(maxMessageSize int) error {
+	if bs == nil {
+		return nil
+	}
+	if bs.global.Burst > 0 && bs.global.Burst < maxMessageSize {
+		logger.Warnf("全局带宽限制的令牌桶容量 %d 小于最大消息大小 %d", bs.global.Burst, maxMessageSize)
+		return fmt.Errorf("全局带宽限制的令牌桶容量 %d 小于最大消息大小 %d", bs.global.Burst, maxMessageSize)
+	}
+	for topic, r := range bs.perTopic {
+		if r.Burst > 0 && r.Burst < maxMessageSize {
+			logger.Warnf("主题 %s 的带宽限制的令牌桶容量 %d 小于最大消息大小 %d", topic, r.Burst, maxMessageSize)
+			return fmt.Errorf("主题 %s 的带宽限制的令牌桶容量 %d 小于最大消息大小 %d", topic, r.Burst, maxMessageSize)
+		}
+	}
+	return nil
+}
+
+// burst 返回速率的令牌桶容量
+// 参数:
+//   - r: 速率
+//   - maxMessageSize: 允许的最大消息大小
+//
+// 返回值:
+//   - float64: 令牌桶容量
+func (r Rate) burst(maxMessageSize int) float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return math.Max(r.BytesPerSec, float64(maxMessageSize))
+}
+
+// allow 同时从全局和主题的令牌桶中消耗 n 个字节令牌，任一不足时都不消耗。nil 整形器总是返回 true。
+// 参数:
+//   - topic: 主题
+//   - n: 字节数
+//   - maxMessageSize: 允许的最大消息大小
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否在预算之内
+func (bs *bandwidthShaper) allow(topic string, n, maxMessageSize int, now time.Time) bool {
+	if bs == nil {
+		return true
+	}
+
+	tb, limited := bs.topicBuckets[topic]
+	if limited {
+		r := bs.perTopic[topic]
+		if !tb.take(float64(n), r.BytesPerSec, r.burst(maxMessageSize), now) {
+			return false
+		}
+	}
+	if bs.global.BytesPerSec > 0 && !bs.globalBucket.take(float64(n), bs.global.BytesPerSec, bs.global.burst(maxMessageSize), now) {
+		if limited {
+			tb.tokens += float64(n) // 退还主题令牌
+		}
+		return false
+	}
+	return true
+}
+
+// allowForward 返回转发的消息是否在带宽预算之内。本地发布的消息总是允许，
+// 按接收者而不是 From 判断，匿名发布或使用主题发布密钥的消息同样视为本地消息
+// 参数:
+//   - msg: 消息
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否允许发送
+func (p *PubSub) allowForward(msg *Message, now time.Time) bool {
+	if p.bandwidth == nil || msg.ReceivedFrom == p.host.ID() {
+		return true
+	}
+	return p.bandwidth.allow(msg.GetTopic(), msg.Size(), p.maxMessageSize, now)
+}
+
+// shapeRPC 从出站 RPC 中丢弃超出带宽预算的转发消息和 IHAVE gossip，控制消息和订阅信息保持不变。
+// 标记为不整形的 RPC（本地发布的消息和 IWANT 回复）中的消息不受限制，捎带的 gossip 仍然受限。
+// 需要修改时返回 RPC 的副本；没有整形器时原样返回。
+// 参数:
+//   - out: 出站 RPC
+//
+// 返回值:
+//   - *RPC: 整形后的 RPC
+//   - bool: RPC 是否仍有内容需要发送
+func (p *PubSub) shapeRPC(out *RPC) (*RPC, bool) {
+	if p.bandwidth == nil {
+		return out, true
+	}
+	now := time.Now()
+
+	publish := out.GetPublish()
+	dropped := 0
+	if !out.unshaped {
+		publish = nil
+		for _, msg := range out.GetPublish() {
+			if p.bandwidth.allow(msg.GetTopic(), msg.Size(), p.maxMessageSize, now) {
+				publish = append(publish, msg)
+			} else {
+				dropped++
+			}
+		}
+	}
+
+	var ihave []*pb.ControlIHave
+	for _, ih := range out.GetControl().GetIhave() {
+		if p.bandwidth.allow(ih.GetTopicID(), ih.Size(), p.maxMessageSize, now) {
+			ihave = append(ihave, ih)
+		} else {
+			dropped++
+		}
+	}
+
+	if dropped == 0 {
+		return out, true
+	}
+	logger.Debugf("超出出站带宽预算，丢弃 %d 条消息和 gossip", dropped)
+
+	out = copyRPC(out)
+	out.Publish = publish
+	if out.Control != nil {
+		out.Control.Ihave = ihave
+	}
+	ctl := out.GetControl()
+	empty := len(out.Publish) == 0 && len(out.Stem) == 0 && len(out.Subscriptions) == 0 &&
+		len(ctl.GetIhave()) == 0 && len(ctl.GetIwant()) == 0 && len(ctl.GetGraft()) == 0 && len(ctl.GetPrune()) == 0
+	return out, !empty
+}
@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestBandwidthShaper 测试全局和主题的令牌桶，以及任一不足时不消耗令牌
+func TestBandwidthShaper(t *testing.T) {
+	p := &PubSub{maxMessageSize: 100}
+	if err := WithBandwidthLimit(Rate{}, nil)(p); err == nil {
+		t.Fatal("expected error without any limit")
+	}
+	if err := WithBandwidthLimit(Rate{}, map[string]Rate{"foo": {}})(p); err == nil {
+		t.Fatal("expected error for a zero topic rate")
+	}
+	if err := WithBandwidthLimit(Rate{BytesPerSec: 100, Burst: 300}, map[string]Rate{"foo": {BytesPerSec: 10, Burst: 100}})(p); err != nil {
+		t.Fatal(err)
+	}
+	bs := p.bandwidth
+	now := time.Now()
+
+	if !bs.allow("foo", 100, p.maxMessageSize, now) {
+		t.Fatal("expected the topic burst to be available")
+	}
+	if bs.allow("foo", 10, p.maxMessageSize, now) {
+		t.Fatal("expected the topic budget to be exhausted")
+	}
+	if !bs.allow("bar", 200, p.maxMessageSize, now) {
+		t.Fatal("expected the remaining global budget to be available")
+	}
+	// 全局预算不足时退还主题令牌
+	now = now.Add(time.Second)
+	if !bs.allow("bar", 100, p.maxMessageSize, now) {
+		t.Fatal("expected the refilled global budget to be available")
+	}
+	if bs.allow("foo", 10, p.maxMessageSize, now) {
+		t.Fatal("expected the global budget to be exhausted")
+	}
+	now = now.Add(time.Second)
+	if !bs.allow("foo", 20, p.maxMessageSize, now) {
+		t.Fatal("expected the refunded topic tokens to be available")
+	}
+}
+
+// TestShapeRPC 测试超出预算的转发消息和 gossip 被丢弃，而本地消息、IWANT 回复和控制消息保留
+func TestShapeRPC(t *testing.T) {
+	hosts := getDefaultHosts(t, 1)
+	p := &PubSub{host: hosts[0], maxMessageSize: 1000}
+	if err := WithBandwidthLimit(Rate{BytesPerSec: 1, Burst: 1}, nil)(p); err != nil {
+		t.Fatal(err)
+	}
+
+	forwarded := &pb.Message{From: []byte("other"), Topic: "foo", Data: make([]byte, 100)}
+	out := &RPC{RPC: pb.RPC{
+		Publish: []*pb.Message{forwarded},
+		Control: &pb.ControlMessage{
+			Ihave: []*pb.ControlIHave{{TopicID: "foo", MessageIDs: []string{"a", "b"}}},
+			Graft: []*pb.ControlGraft{{TopicID: "foo"}},
+		},
+	}}
+
+	shaped, ok := p.shapeRPC(out)
+	if !ok {
+		t.Fatal("expected the RPC to still have content")
+	}
+	if len(shaped.Publish) != 0 {
+		t.Fatal("expected the forwarded message to be dropped")
+	}
+	if len(shaped.Control.Ihave) != 0 || len(shaped.Control.Graft) != 1 {
+		t.Fatal("expected gossip to be dropped and control to be kept")
+	}
+	if len(out.Publish) != 1 || len(out.Control.Ihave) != 1 {
+		t.Fatal("expected the original RPC to be unchanged")
+	}
+
+	if _, ok := p.shapeRPC(&RPC{RPC: pb.RPC{Publish: []*pb.Message{forwarded}}}); ok {
+		t.Fatal("expected an RPC with only dropped messages to be empty")
+	}
+
+	// 本地发布的消息和 IWANT 回复不受限制
+	unshaped := &RPC{RPC: pb.RPC{Publish: []*pb.Message{forwarded}}, unshaped: true}
+	if shaped, ok := p.shapeRPC(unshaped); !ok || shaped != unshaped {
+		t.Fatal("expected an unshaped RPC to be sent unchanged")
+	}
+
+	// 按接收者判断本地消息，匿名发布的消息没有 From
+	anon := &Message{Message: &pb.Message{Topic: "foo", Data: make([]byte, 100)}, ReceivedFrom: hosts[0].ID()}
+	if !p.allowForward(anon, time.Now()) {
+		t.Fatal("expected a locally published anonymous message to be allowed")
+	}
+	if p.allowForward(&Message{Message: forwarded, ReceivedFrom: "other"}, time.Now()) {
+		t.Fatal("expected a forwarded message to be limited")
+	}
+}
+
+// TestBandwidthBurstCheck 测试令牌桶容量小于最大消息大小的配置被拒绝
+func TestBandwidthBurstCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], WithBandwidthLimit(Rate{BytesPerSec: 1 << 20, Burst: 1024}, nil)); err == nil {
+		t.Fatal("expected error for a global burst below the max message size")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithBandwidthLimit(Rate{}, map[string]Rate{"foo": {BytesPerSec: 1 << 20, Burst: 1024}})); err == nil {
+		t.Fatal("expected error for a topic burst below the max message size")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithMaxMessageSize(1024), WithBandwidthLimit(Rate{BytesPerSec: 1 << 20, Burst: 1024}, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,9 +1,14 @@
 // 作用：实现对等节点的黑名单机制。
-// 功能：管理被黑名单的对等节点，防止与不可信或恶意节点的通信。
+// 功能：管理被黑名单的对等节点，防止与不可信或恶意节点的通信；
+// 支持移除和列出的黑名单可以通过 UnblacklistPeer 和 ListBlacklisted 在运行时管理，与评分机制相互独立。
 
 package pubsub
 
 import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/dep2p/go-dep2p/core/peer"
@@ -16,6 +21,16 @@ type Blacklist interface {
 	Contains(peer.ID) bool // 检查节点是否在黑名单中
 }
 
+// BlacklistManager 是 Blacklist 的可选扩展接口，支持从黑名单中移除和列出对等节点
+type BlacklistManager interface {
+	Blacklist
+	Remove(peer.ID) bool // 将节点从黑名单中移除，节点不在黑名单中时返回 false
+	List() []peer.ID     // 列出黑名单中的节点
+}
+
+// ErrBlacklistNotManageable 在黑名单实现不支持移除或列出节点时返回
+var ErrBlacklistNotManageable = errors.New("黑名单不支持移除或列出节点")
+
 // MapBlacklist 是一种使用map实现的黑名单
 type MapBlacklist map[peer.ID]struct{}
 
@@ -46,6 +61,29 @@ func (b MapBlacklist) Contains(p peer.ID) bool {
 	return ok     // 返回查找结果
 }
 
+// Remove 将节点从 MapBlacklist 中移除
+// 参数:
+//   - p: 需要移除的节点ID
+//
+// 返回值:
+//   - bool: 节点是否在黑名单中
+func (b MapBlacklist) Remove(p peer.ID) bool {
+	_, ok := b[p]
+	delete(b, p)
+	return ok
+}
+
+// List 列出 MapBlacklist 中的节点
+// 返回值:
+//   - []peer.ID: 黑名单中的节点
+func (b MapBlacklist) List() []peer.ID {
+	out := make([]peer.ID, 0, len(b))
+	for p := range b {
+		out = append(out, p)
+	}
+	return out
+}
+
 // TimeCachedBlacklist 是一种使用时间缓存实现的黑名单
 type TimeCachedBlacklist struct {
 	tc timecache.TimeCache // 时间缓存实例
@@ -87,3 +125,242 @@ func (b *TimeCachedBlacklist) Add(p peer.ID) bool {
 func (b *TimeCachedBlacklist) Contains(p peer.ID) bool {
 	return b.tc.Has(p.String()) // 在时间缓存中查找节点ID的字符串表示
 }
+
+// DecayingBlacklist 是一种条目随时间过期、容量有限的黑名单。
+// 超出容量时丢弃最早加入的条目；再次加入已存在的节点会刷新其过期时间。
+// 与 PubSub 一起使用时，条目过期或被丢弃后与 UnblacklistPeer 一样重新建立与仍然连接的节点的 pubsub 流。
+// 过期的条目只由周期性的清理移除，以便每个离开黑名单的节点都会被重新建立流。
+type DecayingBlacklist struct {
+	mx       sync.Mutex
+	ttl      time.Duration
+	capacity int
+	order    *list.List                // 按加入时间排列的条目，最早的在前
+	entries  map[peer.ID]*list.Element // 节点到条目的索引
+	evicted  []peer.ID                 // 超出容量被丢弃、尚未交给清理的节点
+}
+
+// decayingEntry 是 DecayingBlacklist 的条目
+type decayingEntry struct {
+	p       peer.ID
+	expires time.Time
+}
+
+// NewDecayingBlacklist 创建一个条目随时间过期、容量有限的黑名单
+// 参数:
+//   - ttl: 条目的过期时间
+//   - capacity: 最多记录的节点数
+//
+// 返回值:
+//   - *DecayingBlacklist: 初始化后的 DecayingBlacklist 实例
+//   - error: 如果有错误发生则返回错误
+func NewDecayingBlacklist(ttl time.Duration, capacity int) (*DecayingBlacklist, error) {
+	if ttl <= 0 || capacity <= 0 {
+		logger.Warnf("黑名单的过期时间和容量必须为正数")
+		return nil, fmt.Errorf("黑名单的过期时间和容量必须为正数")
+	}
+	return &DecayingBlacklist{
+		ttl:      ttl,
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[peer.ID]*list.Element),
+	}, nil
+}
+
+// Add 将节点添加到 DecayingBlacklist 中，已存在时刷新过期时间
+// 参数:
+//   - p: 需要添加到黑名单的节点ID
+//
+// 返回值:
+//   - bool: 节点是否是新加入的
+func (b *DecayingBlacklist) Add(p peer.ID) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := time.Now()
+	if e, ok := b.entries[p]; ok {
+		entry := e.Value.(*decayingEntry)
+		expired := !now.Before(entry.expires)
+		entry.expires = now.Add(b.ttl)
+		b.order.MoveToBack(e)
+		return expired
+	}
+	if b.order.Len() >= b.capacity {
+		oldest := b.order.Front()
+		b.order.Remove(oldest)
+		delete(b.entries, oldest.Value.(*decayingEntry).p)
+		b.evicted = append(b.evicted, oldest.Value.(*decayingEntry).p)
+	}
+	b.entries[p] = b.order.PushBack(&decayingEntry{p: p, expires: now.Add(b.ttl)})
+	return true
+}
+
+// Contains 检查节点是否在 DecayingBlacklist 中且未过期
+// 参数:
+//   - p: 需要检查的节点ID
+//
+// 返回值:
+//   - bool: 节点是否在黑名单中
+func (b *DecayingBlacklist) Contains(p peer.ID) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	e, ok := b.entries[p]
+	return ok && time.Now().Before(e.Value.(*decayingEntry).expires)
+}
+
+// Remove 将节点从 DecayingBlacklist 中移除
+// 参数:
+//   - p: 需要移除的节点ID
+//
+// 返回值:
+//   - bool: 节点是否在黑名单中
+func (b *DecayingBlacklist) Remove(p peer.ID) bool {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	e, ok := b.entries[p]
+	if !ok {
+		return false
+	}
+	b.order.Remove(e)
+	delete(b.entries, p)
+	return time.Now().Before(e.Value.(*decayingEntry).expires)
+}
+
+// List 列出 DecayingBlacklist 中未过期的节点，按加入时间从早到晚排列
+// 返回值:
+//   - []peer.ID: 黑名单中的节点
+func (b *DecayingBlacklist) List() []peer.ID {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	now := time.Now()
+	out := make([]peer.ID, 0, b.order.Len())
+	for e := b.order.Front(); e != nil; e = e.Next() {
+		if entry := e.Value.(*decayingEntry); now.Before(entry.expires) {
+			out = append(out, entry.p)
+		}
+	}
+	return out
+}
+
+// expire 丢弃已过期的条目。调用方必须持有锁
+// 参数:
+//   - now: 当前时间
+//
+// 返回值:
+//   - []peer.ID: 过期的节点
+func (b *DecayingBlacklist) expire(now time.Time) []peer.ID {
+	var expired []peer.ID
+	for e := b.order.Front(); e != nil; {
+		next := e.Next()
+		if entry := e.Value.(*decayingEntry); !now.Before(entry.expires) {
+			b.order.Remove(e)
+			delete(b.entries, entry.p)
+			expired = append(expired, entry.p)
+		}
+		e = next
+	}
+	return expired
+}
+
+// sweep 丢弃已过期的条目，返回过期的节点和上次清理后因超出容量被丢弃的节点
+// 参数:
+//   - now: 当前时间
+//
+// 返回值:
+//   - []peer.ID: 离开黑名单的节点
+func (b *DecayingBlacklist) sweep(now time.Time) []peer.ID {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+
+	released := b.expire(now)
+	for _, p := range b.evicted {
+		if _, ok := b.entries[p]; !ok { // 丢弃后又被重新加入的节点仍在黑名单中
+			released = append(released, p)
+		}
+	}
+	b.evicted = nil
+	return released
+}
+
+// decayBlacklistLoop 周期性地清理 DecayingBlacklist 中过期的条目，并与 UnblacklistPeer 一样重新建立与过期或被丢弃的节点的 pubsub 流
+// 参数:
+//   - b: 黑名单
+func (p *PubSub) decayBlacklistLoop(b *DecayingBlacklist) {
+	interval := b.ttl
+	if interval > time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			for _, pid := range b.sweep(now) {
+				logger.Infof("节点 %s 已离开黑名单", pid)
+				p.notifyNewPeer(pid) // 如果仍然连接，重新建立流
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// UnblacklistPeer 将一个对等节点从黑名单中移除；如果仍与其保持连接，会重新建立 pubsub 流。
+// 参数:
+//   - pid: 对等节点 ID
+//
+// 返回值:
+//   - error: 黑名单不支持移除时返回 ErrBlacklistNotManageable
+func (p *PubSub) UnblacklistPeer(pid peer.ID) error {
+	out := make(chan error, 1)
+	remove := func() {
+		bm, ok := p.blacklist.(BlacklistManager)
+		if !ok {
+			out <- ErrBlacklistNotManageable
+			return
+		}
+		if bm.Remove(pid) {
+			logger.Infof("将节点 %s 移出黑名单", pid)
+			p.notifyNewPeer(pid) // 如果仍然连接，重新建立流
+		}
+		out <- nil
+	}
+
+	select {
+	case p.eval <- remove:
+		return <-out
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// ListBlacklisted 列出黑名单中的对等节点
+// 返回值:
+//   - []peer.ID: 黑名单中的对等节点
+//   - error: 黑名单不支持列出时返回 ErrBlacklistNotManageable
+func (p *PubSub) ListBlacklisted() ([]peer.ID, error) {
+	type result struct {
+		peers []peer.ID
+		err   error
+	}
+	out := make(chan result, 1)
+	get := func() {
+		bm, ok := p.blacklist.(BlacklistManager)
+		if !ok {
+			out <- result{err: ErrBlacklistNotManageable}
+			return
+		}
+		out <- result{peers: bm.List()}
+	}
+
+	select {
+	case p.eval <- get:
+		res := <-out
+		return res.peers, res.err
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
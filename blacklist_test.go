@@ -0,0 +1,215 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestBlacklistManagement 测试在运行时列入、列出和移出黑名单
+func TestBlacklistManagement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[0].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topics := getTopics(psubs[1:], "foo")
+	time.Sleep(time.Second)
+
+	psubs[0].BlacklistPeer(hosts[1].ID())
+	peers, err := psubs[0].ListBlacklisted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("expected the blacklisted peer to be listed, got %v", peers)
+	}
+
+	if err := topics[0].Publish(ctx, []byte("blocked")); err != nil {
+		t.Fatal(err)
+	}
+	rctx, rcancel := context.WithTimeout(ctx, time.Second)
+	defer rcancel()
+	if m, err := sub.Next(rctx); err == nil {
+		t.Fatalf("expected no message from a blacklisted peer, got %s", m.Data)
+	}
+
+	if err := psubs[0].UnblacklistPeer(hosts[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if peers, _ := psubs[0].ListBlacklisted(); len(peers) != 0 {
+		t.Fatalf("expected an empty blacklist, got %v", peers)
+	}
+	time.Sleep(2 * time.Second)
+
+	msg := []byte("allowed")
+	if err := topics[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+}
+
+// TestBlacklistNotManageable 测试不支持移除的黑名单实现
+func TestBlacklistNotManageable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b, err := NewTimeCachedBlacklist(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0], WithBlacklist(b))
+
+	if err := ps.UnblacklistPeer("peer"); !errors.Is(err, ErrBlacklistNotManageable) {
+		t.Fatalf("expected ErrBlacklistNotManageable, got %v", err)
+	}
+	if _, err := ps.ListBlacklisted(); !errors.Is(err, ErrBlacklistNotManageable) {
+		t.Fatalf("expected ErrBlacklistNotManageable, got %v", err)
+	}
+}
+
+// TestDecayingBlacklist 测试条目过期和按容量丢弃最早的条目
+func TestDecayingBlacklist(t *testing.T) {
+	b, err := NewDecayingBlacklist(200*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, c, d := peer.ID("a"), peer.ID("c"), peer.ID("d")
+	if !b.Add(a) || b.Add(a) {
+		t.Fatal("expected only the first add to be new")
+	}
+	b.Add(c)
+	b.Add(d)
+	if b.Contains(a) {
+		t.Fatal("expected the oldest entry to be evicted")
+	}
+	if !b.Contains(c) || !b.Contains(d) {
+		t.Fatal("expected recent entries to be kept")
+	}
+	if !b.Remove(c) || b.Contains(c) {
+		t.Fatal("expected the removed entry to be gone")
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if b.Contains(d) {
+		t.Fatal("expected the entry to expire")
+	}
+	if peers := b.List(); len(peers) != 0 {
+		t.Fatalf("expected no entries after expiry, got %v", peers)
+	}
+
+	if _, err := NewDecayingBlacklist(0, 1); err == nil {
+		t.Fatal("expected an error for a zero ttl")
+	}
+}
+
+// TestDecayingBlacklistSweep 测试被丢弃和过期的节点都由清理返回，以便重新建立流
+func TestDecayingBlacklistSweep(t *testing.T) {
+	b, err := NewDecayingBlacklist(200*time.Millisecond, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, c := peer.ID("a"), peer.ID("c")
+	b.Add(a)
+	b.Add(c)
+	if released := b.sweep(time.Now()); len(released) != 1 || released[0] != a {
+		t.Fatalf("expected the evicted peer to be released, got %v", released)
+	}
+
+	// List 和 Add 不移除过期的条目，过期的节点只由清理返回
+	b, err = NewDecayingBlacklist(200*time.Millisecond, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Add(a)
+	time.Sleep(300 * time.Millisecond)
+	if peers := b.List(); len(peers) != 0 {
+		t.Fatalf("expected no live entries, got %v", peers)
+	}
+	b.Add(c)
+	if released := b.sweep(time.Now()); len(released) != 1 || released[0] != a {
+		t.Fatalf("expected the expired peer to be released, got %v", released)
+	}
+}
+
+// TestBlacklistClearsPeerState 测试列入黑名单时清除对等节点的订阅计数和令牌桶
+func TestBlacklistClearsPeerState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	ps0 := getPubsub(ctx, hosts[0], WithPeerRateLimit(100, 0))
+	ps1 := getPubsub(ctx, hosts[1])
+
+	_ = mustSubscribe(t, ps1, "foo")
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	state := func() (known bool, subs int, bucket bool) {
+		ready := make(chan struct{})
+		ps0.eval <- func() {
+			_, known = ps0.peers[hosts[1].ID()]
+			subs = ps0.peerSubs[hosts[1].ID()]
+			_, bucket = ps0.rateLimit.buckets[hosts[1].ID()]
+			close(ready)
+		}
+		<-ready
+		return
+	}
+
+	if known, subs, bucket := state(); !known || subs != 1 || !bucket {
+		t.Fatalf("expected tracked peer state, got known=%v subs=%d bucket=%v", known, subs, bucket)
+	}
+
+	ps0.BlacklistPeer(hosts[1].ID())
+	if known, subs, bucket := state(); known || subs != 0 || bucket {
+		t.Fatalf("expected cleared peer state, got known=%v subs=%d bucket=%v", known, subs, bucket)
+	}
+}
+
+// TestDecayingBlacklistRepeers 测试衰减黑名单条目过期后重新建立与仍然连接的节点的 pubsub 流
+func TestDecayingBlacklistRepeers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b, err := NewDecayingBlacklist(500*time.Millisecond, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := getDefaultHosts(t, 2)
+	ps0 := getPubsub(ctx, hosts[0], WithBlacklist(b))
+	_ = getPubsub(ctx, hosts[1])
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	known := func() bool {
+		res := make(chan bool, 1)
+		ps0.eval <- func() {
+			_, ok := ps0.peers[hosts[1].ID()]
+			res <- ok
+		}
+		return <-res
+	}
+
+	ps0.BlacklistPeer(hosts[1].ID())
+	if known() {
+		t.Fatal("expected the blacklisted peer to be removed")
+	}
+
+	time.Sleep(2 * time.Second)
+	if !known() {
+		t.Fatal("expected the peer to be re-added after the blacklist entry expired")
+	}
+}
@@ -0,0 +1,143 @@
+// 作用：布隆过滤器。
+// 功能：以固定大小的位数组近似记录字符串集合，查询可能出现假阳性但不会出现假阴性；
+// 哈希只依赖于输入，相同参数的过滤器在不同节点上结果一致。
+
+package pubsub
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// bloomFilter 是一个布隆过滤器，不是线程安全的
+type bloomFilter struct {
+	bits []uint64 // 位数组
+	m    uint64   // 位数
+	k    int      // 哈希函数个数
+	n    int      // 已添加的元素个数
+}
+
+// newBloomFilter 创建一个按容量和假阳性率确定大小的布隆过滤器
+// 参数:
+//   - capacity: 预期的元素个数
+//   - fpRate: 达到容量时的假阳性率
+//
+// 返回值:
+//   - *bloomFilter: 布隆过滤器
+func newBloomFilter(capacity int, fpRate float64) *bloomFilter {
+	if capacity < 1 {
+		capacity = 1
+	}
+	m := uint64(math.Ceil(-float64(capacity) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := int(math.Round(float64(m) / float64(capacity) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// bloomHashes 返回元素的两个基础哈希值，第 i 个哈希为 h1 + i*h2
+// 参数:
+//   - s: 元素
+//
+// 返回值:
+//   - uint64: 第一个哈希值
+//   - uint64: 第二个哈希值
+func bloomHashes(s string) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write([]byte(s))
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[8+i])
+	}
+	return h1, h2 | 1 // 第二个哈希为奇数，避免所有哈希落在同一位
+}
+
+// Add 将元素加入过滤器
+// 参数:
+//   - s: 元素
+func (bf *bloomFilter) Add(s string) {
+	h1, h2 := bloomHashes(s)
+	for i := 0; i < bf.k; i++ {
+		idx := (h1 + uint64(i)*h2) % bf.m
+		bf.bits[idx/64] |= 1 << (idx % 64)
+	}
+	bf.n++
+}
+
+// Has 返回元素是否可能在过滤器中
+// 参数:
+//   - s: 元素
+//
+// 返回值:
+//   - bool: 元素不在过滤器中时返回 false；返回 true 时元素可能在过滤器中
+func (bf *bloomFilter) Has(s string) bool {
+	h1, h2 := bloomHashes(s)
+	for i := 0; i < bf.k; i++ {
+		idx := (h1 + uint64(i)*h2) % bf.m
+		if bf.bits[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Len 返回已添加的元素个数
+// 返回值:
+//   - int: 元素个数
+func (bf *bloomFilter) Len() int {
+	return bf.n
+}
+
+// rotatingBloom 由当前和上一代两个布隆过滤器组成，当前过滤器达到容量后替换上一代，从而只记录最近的元素
+type rotatingBloom struct {
+	capacity int
+	fpRate   float64
+	cur      *bloomFilter
+	prev     *bloomFilter
+}
+
+// newRotatingBloom 创建一个轮换的布隆过滤器
+// 参数:
+//   - capacity: 每一代的容量
+//   - fpRate: 每一代的假阳性率
+//
+// 返回值:
+//   - *rotatingBloom: 轮换的布隆过滤器
+func newRotatingBloom(capacity int, fpRate float64) *rotatingBloom {
+	return &rotatingBloom{
+		capacity: capacity,
+		fpRate:   fpRate,
+		cur:      newBloomFilter(capacity, fpRate),
+	}
+}
+
+// Add 将元素加入当前一代，必要时轮换
+// 参数:
+//   - s: 元素
+func (rb *rotatingBloom) Add(s string) {
+	if rb.cur.Len() >= rb.capacity {
+		rb.prev = rb.cur
+		rb.cur = newBloomFilter(rb.capacity, rb.fpRate)
+	}
+	rb.cur.Add(s)
+}
+
+// Has 返回元素是否可能在最近的两代中
+// 参数:
+//   - s: 元素
+//
+// 返回值:
+//   - bool: 元素是否可能存在
+func (rb *rotatingBloom) Has(s string) bool {
+	return rb.cur.Has(s) || (rb.prev != nil && rb.prev.Has(s))
+}
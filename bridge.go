@@ -0,0 +1,187 @@
+// 作用：在两个 PubSub 实例之间桥接主题。
+// 功能：网关节点订阅一侧网络中的主题，将通过验证的消息重新发布到另一侧网络中映射的主题，反之亦然；
+// 重新发布的消息附带桥接来源标记，桥接器丢弃带有自身标记的消息，从而避免消息在网络之间循环。
+
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// bridgeOriginKey 是桥接来源标记的转发注解键，值为经过的桥接器 ID
+const bridgeOriginKey = "dep2p-bridge-origin"
+
+// Bridge 在两个 PubSub 实例之间双向转发主题消息
+type Bridge struct {
+	id []byte // 桥接器 ID，用于标记经过本桥接器的消息
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	topics []*Topic        // 桥接器加入的主题
+	subs   []*Subscription // 桥接器的订阅
+
+	closeOnce sync.Once
+}
+
+// NewBridge 创建一个桥接器，在 psA 的主题和 psB 中映射的主题之间双向转发通过验证的消息。
+// 桥接器会在两侧加入并订阅映射的主题，因此这些主题不能已被应用程序加入。
+// 转发的消息由桥接节点重新发布，原始的来源和签名不会保留；同一节点本地发布的消息不会被转发。
+// 参数:
+//   - psA: 一侧的 PubSub 实例
+//   - psB: 另一侧的 PubSub 实例
+//   - topicMap: psA 中的主题到 psB 中的主题的映射
+//
+// 返回值:
+//   - *Bridge: 桥接器
+//   - error: 错误信息，如果有的话
+func NewBridge(psA, psB *PubSub, topicMap map[string]string) (*Bridge, error) {
+	if psA == nil || psB == nil || psA == psB {
+		logger.Warnf("桥接需要两个不同的 PubSub 实例")
+		return nil, fmt.Errorf("桥接需要两个不同的 PubSub 实例")
+	}
+	if len(topicMap) == 0 {
+		logger.Warnf("桥接的主题映射不能为空")
+		return nil, fmt.Errorf("桥接的主题映射不能为空")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bridge{
+		id:     id,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	for topicA, topicB := range topicMap {
+		if topicA == "" || topicB == "" {
+			b.Close()
+			logger.Warnf("桥接的主题不能为空")
+			return nil, fmt.Errorf("桥接的主题不能为空")
+		}
+
+		ta, subA, err := b.join(psA, topicA)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		tb, subB, err := b.join(psB, topicB)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+
+		b.wg.Add(2)
+		go b.relay(subA, tb)
+		go b.relay(subB, ta)
+	}
+
+	return b, nil
+}
+
+// join 加入并订阅主题
+// 参数:
+//   - ps: PubSub 实例
+//   - topic: 主题
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - *Subscription: 订阅
+//   - error: 错误信息，如果有的话
+func (b *Bridge) join(ps *PubSub, topic string) (*Topic, *Subscription, error) {
+	t, err := ps.Join(topic)
+	if err != nil {
+		logger.Warnf("桥接加入主题 %s 失败: %s", topic, err)
+		return nil, nil, fmt.Errorf("桥接加入主题 %s 失败: %w", topic, err)
+	}
+	b.topics = append(b.topics, t)
+
+	sub, err := t.Subscribe()
+	if err != nil {
+		logger.Warnf("桥接订阅主题 %s 失败: %s", topic, err)
+		return nil, nil, fmt.Errorf("桥接订阅主题 %s 失败: %w", topic, err)
+	}
+	b.subs = append(b.subs, sub)
+
+	return t, sub, nil
+}
+
+// relay 将订阅收到的消息转发到目标主题，直到桥接器关闭
+// 参数:
+//   - sub: 来源订阅
+//   - dst: 目标主题
+func (b *Bridge) relay(sub *Subscription, dst *Topic) {
+	defer b.wg.Done()
+
+	for {
+		msg, err := sub.Next(b.ctx)
+		if err != nil {
+			return
+		}
+		if b.relayed(msg) {
+			logger.Debugf("丢弃经过本桥接器的消息 %s，避免循环", msg.ID)
+			continue
+		}
+
+		// 保留其他桥接器的来源标记，以便检测经过多个桥接器的循环
+		var opts []PubOpt
+		for _, anno := range msg.GetAnnotations() {
+			if anno.GetKey() == bridgeOriginKey {
+				opts = append(opts, WithForwardAnnotation(bridgeOriginKey, anno.GetValue()))
+			}
+		}
+		opts = append(opts, WithForwardAnnotation(bridgeOriginKey, b.id))
+
+		if err := dst.Publish(b.ctx, msg.GetData(), opts...); err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, ErrTopicClosed) {
+				return
+			}
+			logger.Warnf("桥接转发消息 %s 到主题 %s 失败: %s", msg.ID, dst.String(), err)
+		}
+	}
+}
+
+// relayed 检查消息是否已经经过本桥接器
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - bool: 消息是否带有本桥接器的来源标记
+func (b *Bridge) relayed(msg *Message) bool {
+	for _, anno := range msg.GetAnnotations() {
+		if anno.GetKey() == bridgeOriginKey && bytes.Equal(anno.GetValue(), b.id) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close 停止桥接，取消订阅并关闭桥接器加入的主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (b *Bridge) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.cancel()
+		for _, sub := range b.subs {
+			sub.Cancel()
+		}
+		b.wg.Wait()
+		for _, t := range b.topics {
+			if cerr := t.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+	})
+	return err
+}
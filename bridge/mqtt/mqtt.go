@@ -0,0 +1,602 @@
+// 作用：MQTT 桥接适配器。
+// 功能：将 MQTT 主题与发布订阅主题双向映射，使物联网设备可以通过 MQTT 代理向网络输入数据。
+// 适配器在两个方向上都使用 QoS 0 语义（最多一次）：断开连接期间的消息会被丢弃，连接会自动重连；
+// 超过最大负载大小的消息在两个方向上都会被丢弃。
+
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	logging "github.com/dep2p/log"
+	"github.com/dep2p/pubsub"
+)
+
+var logger = logging.Logger("pubsub-mqtt")
+
+// 默认配置
+const (
+	defaultKeepAlive      = 30 * time.Second
+	defaultReconnectDelay = 2 * time.Second
+	defaultDialTimeout    = 10 * time.Second
+	echoTTL               = 10 * time.Second
+)
+
+// Direction 表示映射的转发方向
+type Direction int
+
+const (
+	// Both 双向转发
+	Both Direction = iota
+	// ToPubSub 只将 MQTT 消息转发到发布订阅主题
+	ToPubSub
+	// ToMQTT 只将发布订阅消息转发到 MQTT 主题
+	ToMQTT
+)
+
+// Mapping 描述一个 MQTT 主题与发布订阅主题之间的映射
+type Mapping struct {
+	MQTTTopic   string    // MQTT 主题；只有 ToPubSub 方向可以使用 + 和 # 通配符
+	PubSubTopic string    // 发布订阅主题
+	Direction   Direction // 转发方向，默认双向
+}
+
+// Option 是适配器的配置选项
+type Option func(a *Adapter) error
+
+// WithClientID 是一个选项，用于设置 MQTT 客户端 ID，默认随机生成
+// 参数:
+//   - id: 客户端 ID
+//
+// 返回值:
+//   - Option: 配置选项
+func WithClientID(id string) Option {
+	return func(a *Adapter) error {
+		if id == "" {
+			logger.Warnf("MQTT 客户端 ID 不能为空")
+			return fmt.Errorf("MQTT 客户端 ID 不能为空")
+		}
+		a.clientID = id
+		return nil
+	}
+}
+
+// WithMaxPayloadSize 是一个选项，用于设置转发消息的最大负载大小，默认为 pubsub.DefaultMaxMessageSize
+// 参数:
+//   - size: 最大负载大小（字节）
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMaxPayloadSize(size int) Option {
+	return func(a *Adapter) error {
+		if size <= 0 || size > maxRemainingBytes {
+			logger.Warnf("无效的最大负载大小: %d", size)
+			return fmt.Errorf("无效的最大负载大小: %d", size)
+		}
+		a.maxPayload = size
+		return nil
+	}
+}
+
+// WithKeepAlive 是一个选项，用于设置 MQTT 保活间隔，默认 30 秒
+// 参数:
+//   - interval: 保活间隔
+//
+// 返回值:
+//   - Option: 配置选项
+func WithKeepAlive(interval time.Duration) Option {
+	return func(a *Adapter) error {
+		if interval < time.Second || interval > 0xffff*time.Second {
+			logger.Warnf("无效的保活间隔: %s", interval)
+			return fmt.Errorf("无效的保活间隔: %s", interval)
+		}
+		a.keepAlive = interval
+		return nil
+	}
+}
+
+// WithTLSConfig 是一个选项，用于设置 ssl:// 和 tls:// 代理地址使用的 TLS 配置
+// 参数:
+//   - cfg: TLS 配置
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(a *Adapter) error {
+		a.tlsConfig = cfg
+		return nil
+	}
+}
+
+// Adapter 在 MQTT 代理和发布订阅网络之间转发消息
+type Adapter struct {
+	ps         *pubsub.PubSub
+	broker     *url.URL
+	mappings   []Mapping
+	clientID   string
+	maxPayload int
+	keepAlive  time.Duration
+	tlsConfig  *tls.Config
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	topics map[string]*pubsub.Topic // 适配器加入的发布订阅主题
+	subs   []*pubsub.Subscription   // 适配器的发布订阅订阅
+
+	connMx sync.Mutex
+	conn   net.Conn // 当前的 MQTT 连接，断开时为 nil
+
+	echoMx sync.Mutex
+	echoes map[string]*echo // 适配器发布到 MQTT 的消息，用于丢弃代理回送的副本
+}
+
+// echo 记录一个等待代理回送的消息
+type echo struct {
+	count   int
+	expires time.Time
+}
+
+// New 创建 MQTT 桥接适配器并开始转发。
+// 代理地址支持 tcp://、mqtt://、ssl://、tls:// 和 mqtts:// 协议，用户名和密码可以放在地址中。
+// 适配器会加入映射的发布订阅主题，因此这些主题不能已被应用程序加入。
+// 参数:
+//   - ps: PubSub 实例
+//   - brokerURL: MQTT 代理地址，例如 "tcp://localhost:1883"
+//   - mappings: 主题映射
+//   - opts: 配置选项
+//
+// 返回值:
+//   - *Adapter: 适配器
+//   - error: 错误信息，如果有的话
+func New(ps *pubsub.PubSub, brokerURL string, mappings []Mapping, opts ...Option) (*Adapter, error) {
+	if ps == nil {
+		logger.Warnf("PubSub 实例不能为空")
+		return nil, fmt.Errorf("PubSub 实例不能为空")
+	}
+	broker, err := url.Parse(brokerURL)
+	if err != nil || broker.Host == "" {
+		logger.Warnf("无效的 MQTT 代理地址: %s", brokerURL)
+		return nil, fmt.Errorf("无效的 MQTT 代理地址: %s", brokerURL)
+	}
+	switch broker.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts":
+	default:
+		logger.Warnf("不支持的 MQTT 代理协议: %s", broker.Scheme)
+		return nil, fmt.Errorf("不支持的 MQTT 代理协议: %s", broker.Scheme)
+	}
+	if len(mappings) == 0 {
+		logger.Warnf("MQTT 主题映射不能为空")
+		return nil, fmt.Errorf("MQTT 主题映射不能为空")
+	}
+	for _, m := range mappings {
+		if m.MQTTTopic == "" || m.PubSubTopic == "" {
+			logger.Warnf("MQTT 主题映射中的主题不能为空")
+			return nil, fmt.Errorf("MQTT 主题映射中的主题不能为空")
+		}
+		if m.Direction != ToPubSub && hasWildcard(m.MQTTTopic) {
+			logger.Warnf("只有 ToPubSub 方向的映射可以使用通配符: %s", m.MQTTTopic)
+			return nil, fmt.Errorf("只有 ToPubSub 方向的映射可以使用通配符: %s", m.MQTTTopic)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &Adapter{
+		ps:         ps,
+		broker:     broker,
+		mappings:   mappings,
+		maxPayload: pubsub.DefaultMaxMessageSize,
+		keepAlive:  defaultKeepAlive,
+		ctx:        ctx,
+		cancel:     cancel,
+		topics:     make(map[string]*pubsub.Topic),
+		echoes:     make(map[string]*echo),
+	}
+	for _, opt := range opts {
+		if err := opt(a); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	if a.clientID == "" {
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			cancel()
+			return nil, err
+		}
+		a.clientID = "dep2p-" + hex.EncodeToString(id)
+	}
+
+	for _, m := range mappings {
+		t, ok := a.topics[m.PubSubTopic]
+		if !ok {
+			if t, err = ps.Join(m.PubSubTopic); err != nil {
+				a.Close()
+				logger.Warnf("MQTT 适配器加入主题 %s 失败: %s", m.PubSubTopic, err)
+				return nil, fmt.Errorf("MQTT 适配器加入主题 %s 失败: %w", m.PubSubTopic, err)
+			}
+			a.topics[m.PubSubTopic] = t
+		}
+		if m.Direction == ToPubSub {
+			continue
+		}
+
+		sub, err := t.Subscribe()
+		if err != nil {
+			a.Close()
+			logger.Warnf("MQTT 适配器订阅主题 %s 失败: %s", m.PubSubTopic, err)
+			return nil, fmt.Errorf("MQTT 适配器订阅主题 %s 失败: %w", m.PubSubTopic, err)
+		}
+		a.subs = append(a.subs, sub)
+		a.wg.Add(1)
+		go a.forwardToMQTT(sub, m.MQTTTopic)
+	}
+
+	a.wg.Add(1)
+	go a.run()
+
+	return a, nil
+}
+
+// Close 断开 MQTT 连接，停止转发并关闭适配器加入的发布订阅主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (a *Adapter) Close() error {
+	a.cancel()
+	for _, sub := range a.subs {
+		sub.Cancel()
+	}
+
+	a.connMx.Lock()
+	if a.conn != nil {
+		if pkt, err := encodePacket(packetDisconnect, 0, nil); err == nil {
+			a.conn.SetWriteDeadline(time.Now().Add(defaultDialTimeout))
+			a.conn.Write(pkt)
+		}
+		a.conn.Close()
+	}
+	a.connMx.Unlock()
+
+	a.wg.Wait()
+
+	var err error
+	for _, t := range a.topics {
+		if cerr := t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// run 维护与代理的连接，断开后自动重连，直到适配器关闭
+func (a *Adapter) run() {
+	defer a.wg.Done()
+
+	for {
+		err := a.session()
+		if a.ctx.Err() != nil {
+			return
+		}
+		logger.Warnf("MQTT 连接 %s 断开: %s", a.broker.Host, err)
+
+		select {
+		case <-time.After(defaultReconnectDelay):
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// session 建立一个 MQTT 会话并读取消息，直到连接断开
+// 返回值:
+//   - error: 断开的原因
+func (a *Adapter) session() error {
+	conn, err := a.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// 握手
+	pkt, err := connectPacket(a.clientID, a.broker.User.Username(), a.password(), uint16(a.keepAlive/time.Second))
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(defaultDialTimeout))
+	if _, err := conn.Write(pkt); err != nil {
+		return err
+	}
+	ack, err := readPacket(r, a.maxPacket())
+	if err != nil {
+		return err
+	}
+	if ack.typ != packetConnack || len(ack.body) != 2 {
+		return errMalformedPacket
+	}
+	if code := ack.body[1]; code != 0 {
+		return fmt.Errorf("MQTT 代理拒绝连接: 返回码 %d", code)
+	}
+
+	// 订阅转发到发布订阅的主题
+	var filters []string
+	for _, m := range a.mappings {
+		if m.Direction != ToMQTT {
+			filters = append(filters, m.MQTTTopic)
+		}
+	}
+	if len(filters) > 0 {
+		if pkt, err = subscribePacket(1, filters); err != nil {
+			return err
+		}
+		if _, err := conn.Write(pkt); err != nil {
+			return err
+		}
+	}
+	conn.SetDeadline(time.Time{})
+
+	a.connMx.Lock()
+	if a.ctx.Err() != nil {
+		a.connMx.Unlock()
+		return a.ctx.Err()
+	}
+	a.conn = conn
+	a.connMx.Unlock()
+	defer func() {
+		a.connMx.Lock()
+		a.conn = nil
+		a.connMx.Unlock()
+	}()
+	logger.Infof("已连接到 MQTT 代理 %s", a.broker.Host)
+
+	// 保活
+	done := make(chan struct{})
+	defer close(done)
+	go a.ping(done)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(a.keepAlive * 3 / 2))
+		p, err := readPacket(r, a.maxPacket())
+		if err != nil {
+			return err
+		}
+
+		switch p.typ {
+		case packetPublish:
+			a.handlePublish(p)
+		case packetSuback:
+			for _, code := range p.body[min(2, len(p.body)):] {
+				if code == 0x80 {
+					logger.Warnf("MQTT 代理拒绝了订阅")
+				}
+			}
+		case packetPingresp:
+		default:
+			logger.Debugf("忽略 MQTT 报文类型 %d", p.typ)
+		}
+	}
+}
+
+// dial 连接到代理
+// 返回值:
+//   - net.Conn: 连接
+//   - error: 错误信息，如果有的话
+func (a *Adapter) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: defaultDialTimeout}
+	switch a.broker.Scheme {
+	case "ssl", "tls", "mqtts":
+		cfg := a.tlsConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: a.broker.Hostname()}
+		}
+		return tls.DialWithDialer(d, "tcp", a.broker.Host, cfg)
+	default:
+		return d.DialContext(a.ctx, "tcp", a.broker.Host)
+	}
+}
+
+// maxPacket 返回从代理接受的报文的最大剩余长度
+// 返回值:
+//   - int: 最大剩余长度
+func (a *Adapter) maxPacket() int {
+	return min(a.maxPayload+maxPublishOverhead, maxRemainingBytes)
+}
+
+// password 返回代理地址中的密码
+func (a *Adapter) password() string {
+	pw, _ := a.broker.User.Password()
+	return pw
+}
+
+// ping 定期发送 PINGREQ，直到会话结束
+// 参数:
+//   - done: 会话结束通知
+func (a *Adapter) ping(done <-chan struct{}) {
+	ticker := time.NewTicker(a.keepAlive / 2)
+	defer ticker.Stop()
+
+	pkt, _ := encodePacket(packetPingreq, 0, nil)
+	for {
+		select {
+		case <-ticker.C:
+			if err := a.write(pkt); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// write 向当前连接写入报文
+// 参数:
+//   - pkt: 报文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (a *Adapter) write(pkt []byte) error {
+	a.connMx.Lock()
+	defer a.connMx.Unlock()
+
+	if a.conn == nil {
+		return errNotConnected
+	}
+	a.conn.SetWriteDeadline(time.Now().Add(defaultDialTimeout))
+	_, err := a.conn.Write(pkt)
+	return err
+}
+
+// errNotConnected 表示当前没有连接到代理
+var errNotConnected = errors.New("未连接到 MQTT 代理")
+
+// handlePublish 将代理投递的消息转发到映射的发布订阅主题
+// 参数:
+//   - p: PUBLISH 报文
+func (a *Adapter) handlePublish(p *packet) {
+	topic, id, payload, err := parsePublish(p)
+	if err != nil {
+		logger.Debugf("丢弃格式错误的 MQTT 消息: %s", err)
+		return
+	}
+	if qos := (p.flags >> 1) & 0x03; qos == 1 {
+		// 订阅使用 QoS 0，但仍确认代理以更高 QoS 投递的消息
+		if ack, err := encodePacket(packetPuback, 0, []byte{byte(id >> 8), byte(id)}); err == nil {
+			a.write(ack)
+		}
+	}
+
+	if a.consumeEcho(topic, payload) {
+		return
+	}
+	if len(payload) > a.maxPayload {
+		logger.Debugf("丢弃超过最大负载大小的 MQTT 消息: 主题 %s，%d 字节", topic, len(payload))
+		return
+	}
+
+	for _, m := range a.mappings {
+		if m.Direction == ToMQTT || !matchTopic(m.MQTTTopic, topic) {
+			continue
+		}
+		if err := a.topics[m.PubSubTopic].Publish(a.ctx, payload); err != nil {
+			logger.Debugf("转发 MQTT 消息到主题 %s 失败: %s", m.PubSubTopic, err)
+		}
+	}
+}
+
+// forwardToMQTT 将发布订阅消息转发到 MQTT 主题，直到订阅被取消
+// 参数:
+//   - sub: 发布订阅订阅
+//   - topic: MQTT 主题
+func (a *Adapter) forwardToMQTT(sub *pubsub.Subscription, topic string) {
+	defer a.wg.Done()
+
+	for {
+		msg, err := sub.Next(a.ctx)
+		if err != nil {
+			return
+		}
+		payload := msg.GetData()
+		if len(payload) > a.maxPayload {
+			logger.Debugf("丢弃超过最大负载大小的消息 %s: %d 字节", msg.ID, len(payload))
+			continue
+		}
+
+		pkt, err := publishPacket(topic, payload)
+		if err != nil {
+			continue
+		}
+		a.expectEcho(topic, payload)
+		if err := a.write(pkt); err != nil {
+			// QoS 0：断开连接期间的消息直接丢弃
+			a.consumeEcho(topic, payload)
+			logger.Debugf("转发消息 %s 到 MQTT 主题 %s 失败: %s", msg.ID, topic, err)
+		}
+	}
+}
+
+// echoKey 返回消息回送记录的键
+func echoKey(topic string, payload []byte) string {
+	h := sha256.New()
+	h.Write([]byte(topic))
+	h.Write([]byte{0})
+	h.Write(payload)
+	return string(h.Sum(nil))
+}
+
+// expectEcho 记录一个发布到 MQTT 的消息；如果适配器也订阅了该主题，代理会把它回送回来
+// 参数:
+//   - topic: MQTT 主题
+//   - payload: 有效载荷
+func (a *Adapter) expectEcho(topic string, payload []byte) {
+	if !a.subscribed(topic) {
+		return
+	}
+
+	a.echoMx.Lock()
+	defer a.echoMx.Unlock()
+
+	now := time.Now()
+	for k, e := range a.echoes {
+		if now.After(e.expires) {
+			delete(a.echoes, k)
+		}
+	}
+	key := echoKey(topic, payload)
+	e, ok := a.echoes[key]
+	if !ok {
+		e = &echo{}
+		a.echoes[key] = e
+	}
+	e.count++
+	e.expires = now.Add(echoTTL)
+}
+
+// consumeEcho 检查并消耗一个回送记录
+// 参数:
+//   - topic: MQTT 主题
+//   - payload: 有效载荷
+//
+// 返回值:
+//   - bool: 消息是否是适配器自己发布的回送
+func (a *Adapter) consumeEcho(topic string, payload []byte) bool {
+	a.echoMx.Lock()
+	defer a.echoMx.Unlock()
+
+	key := echoKey(topic, payload)
+	e, ok := a.echoes[key]
+	if !ok {
+		return false
+	}
+	e.count--
+	if e.count <= 0 {
+		delete(a.echoes, key)
+	}
+	return true
+}
+
+// subscribed 检查适配器是否订阅了 MQTT 主题
+// 参数:
+//   - topic: MQTT 主题
+//
+// 返回值:
+//   - bool: 是否订阅
+func (a *Adapter) subscribed(topic string) bool {
+	for _, m := range a.mappings {
+		if m.Direction != ToMQTT && matchTopic(m.MQTTTopic, topic) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,324 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p"
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/pubsub"
+)
+
+// fakeBroker 是一个只支持 QoS 0 的最小 MQTT 代理，会把消息回送给订阅了该主题的发布者
+type fakeBroker struct {
+	l net.Listener
+
+	mx      sync.Mutex
+	clients map[net.Conn][]string
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{l: l, clients: make(map[net.Conn][]string)}
+	t.Cleanup(func() { l.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) url() string {
+	return "tcp://" + b.l.Addr().String()
+}
+
+func (b *fakeBroker) serve() {
+	for {
+		conn, err := b.l.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeBroker) handle(conn net.Conn) {
+	defer func() {
+		b.mx.Lock()
+		delete(b.clients, conn)
+		b.mx.Unlock()
+		conn.Close()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(r, maxRemainingBytes)
+		if err != nil {
+			return
+		}
+		switch p.typ {
+		case packetConnect:
+			b.mx.Lock()
+			b.clients[conn] = nil
+			b.mx.Unlock()
+			ack, _ := encodePacket(packetConnack, 0, []byte{0, 0})
+			conn.Write(ack)
+		case packetSubscribe:
+			rest := p.body[2:]
+			var codes []byte
+			for len(rest) > 0 {
+				filter, r, err := readString(rest)
+				if err != nil {
+					return
+				}
+				rest = r[1:]
+				b.mx.Lock()
+				b.clients[conn] = append(b.clients[conn], filter)
+				b.mx.Unlock()
+				codes = append(codes, 0)
+			}
+			ack, _ := encodePacket(packetSuback, 0, append(p.body[:2:2], codes...))
+			conn.Write(ack)
+		case packetPublish:
+			topic, _, payload, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			pkt, _ := publishPacket(topic, payload)
+			b.mx.Lock()
+			for c, filters := range b.clients {
+				for _, f := range filters {
+					if matchTopic(f, topic) {
+						c.Write(pkt)
+						break
+					}
+				}
+			}
+			b.mx.Unlock()
+		case packetPingreq:
+			resp, _ := encodePacket(packetPingresp, 0, nil)
+			conn.Write(resp)
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+// device 是连接到代理的 MQTT 客户端
+type device struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func newDevice(t *testing.T, b *fakeBroker, filters ...string) *device {
+	conn, err := net.Dial("tcp", b.l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	d := &device{conn: conn, r: bufio.NewReader(conn)}
+
+	pkt, _ := connectPacket("device", "", "", 60)
+	conn.Write(pkt)
+	if p := d.read(t); p.typ != packetConnack {
+		t.Fatalf("expected CONNACK, got %d", p.typ)
+	}
+	if len(filters) > 0 {
+		pkt, _ = subscribePacket(1, filters)
+		conn.Write(pkt)
+		if p := d.read(t); p.typ != packetSuback {
+			t.Fatalf("expected SUBACK, got %d", p.typ)
+		}
+	}
+	return d
+}
+
+func (d *device) read(t *testing.T) *packet {
+	d.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	p, err := readPacket(d.r, maxRemainingBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func (d *device) publish(topic string, payload []byte) {
+	pkt, _ := publishPacket(topic, payload)
+	d.conn.Write(pkt)
+}
+
+// TestMatchTopic 测试 MQTT 主题过滤器匹配
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		match         bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+/c", "a/b/c", true},
+		{"a/+/c", "a/b/d", false},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"a/b", "a/b/c", false},
+		{"a/b/c", "a/b", false},
+	}
+	for _, c := range cases {
+		if got := matchTopic(c.filter, c.topic); got != c.match {
+			t.Errorf("matchTopic(%q, %q) = %v, expected %v", c.filter, c.topic, got, c.match)
+		}
+	}
+}
+
+// TestPacketRoundTrip 测试报文编解码
+func TestPacketRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte{7}, 300)
+	pkt, err := publishPacket("a/b", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(pkt)), maxRemainingBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, _, data, err := parsePublish(p)
+	if err != nil || topic != "a/b" || !bytes.Equal(data, payload) {
+		t.Fatalf("unexpected round trip: %q %v", topic, err)
+	}
+
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0xff})), maxRemainingBytes); err != errMalformedPacket {
+		t.Fatalf("expected malformed remaining length, got %v", err)
+	}
+
+	// 声明的剩余长度超过上限时在读取报文体之前拒绝
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0xff, 0xff, 0xff, 0x7f})), 1024); !errors.Is(err, errPacketTooLarge) {
+		t.Fatalf("expected oversized packet to be rejected, got %v", err)
+	}
+	// 报文体不完整
+	if _, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0x05, 0x00})), 1024); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected truncated packet to fail, got %v", err)
+	}
+
+	// 超过 65535 字节的字符串无法编码
+	if _, err := publishPacket(strings.Repeat("a", maxStringBytes+1), nil); err == nil {
+		t.Fatal("expected overlong topic to be rejected")
+	}
+}
+
+// TestAdapter 测试 MQTT 与发布订阅之间的双向转发
+func TestAdapter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var hosts []host.Host
+	var psubs []*pubsub.PubSub
+	for i := 0; i < 2; i++ {
+		h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.Close() })
+		ps, err := pubsub.NewFloodSub(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+		psubs = append(psubs, ps)
+	}
+	if err := hosts[1].Connect(ctx, hosts[0].Peerstore().PeerInfo(hosts[0].ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	broker := newFakeBroker(t)
+	if _, err := New(psubs[0], broker.url(), []Mapping{{MQTTTopic: "a/#", PubSubTopic: "x"}}); err == nil {
+		t.Fatal("expected error for bidirectional wildcard mapping")
+	}
+
+	adapter, err := New(psubs[0], broker.url(), []Mapping{
+		{MQTTTopic: "sensors/+/temp", PubSubTopic: "temp", Direction: ToPubSub},
+		{MQTTTopic: "cmd", PubSubTopic: "commands"},
+	}, WithMaxPayloadSize(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adapter.Close()
+
+	temp, err := psubs[1].Join("temp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tempSub, err := temp.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commands, err := psubs[1].Join("commands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdSub, err := commands.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dev := newDevice(t, broker, "cmd")
+	time.Sleep(time.Second)
+
+	next := func(sub *pubsub.Subscription) string {
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer tcancel()
+		msg, err := sub.Next(tctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(msg.Data)
+	}
+	expectNone := func(sub *pubsub.Subscription) {
+		tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer tcancel()
+		if msg, err := sub.Next(tctx); err == nil {
+			t.Fatalf("expected no message, got %q", msg.Data)
+		}
+	}
+
+	// MQTT 到发布订阅，包括通配符映射和负载大小限制
+	dev.publish("sensors/1/temp", []byte("21.5"))
+	if data := next(tempSub); data != "21.5" {
+		t.Fatalf("expected MQTT message to be bridged, got %q", data)
+	}
+	dev.publish("sensors/2/temp", bytes.Repeat([]byte("9"), 32))
+	expectNone(tempSub)
+
+	// 发布订阅到 MQTT，代理回送的副本不会被转发回网络
+	if err := commands.Publish(ctx, []byte("reboot")); err != nil {
+		t.Fatal(err)
+	}
+	p := dev.read(t)
+	topic, _, payload, err := parsePublish(p)
+	if err != nil || topic != "cmd" || string(payload) != "reboot" {
+		t.Fatalf("expected pubsub message to be bridged to MQTT, got %q %q %v", topic, payload, err)
+	}
+	expectNone(cmdSub)
+
+	// 双向映射的 MQTT 到发布订阅
+	dev.publish("cmd", []byte("status"))
+	if data := next(cmdSub); data != "status" {
+		t.Fatalf("expected MQTT command to be bridged, got %q", data)
+	}
+
+	if err := adapter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[0].Join("temp"); err != nil {
+		t.Fatalf("expected adapter topic to be released: %s", err)
+	}
+}
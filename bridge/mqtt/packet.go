@@ -0,0 +1,288 @@
+// 作用：MQTT 3.1.1 报文编解码。
+// 功能：实现适配器所需的最小报文子集（CONNECT、SUBSCRIBE、QoS 0 PUBLISH、PING 和 DISCONNECT）以及主题过滤器匹配。
+
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MQTT 3.1.1 控制报文类型
+const (
+	packetConnect     byte = 1
+	packetConnack     byte = 2
+	packetPublish     byte = 3
+	packetPuback      byte = 4
+	packetSubscribe   byte = 8
+	packetSuback      byte = 9
+	packetPingreq     byte = 12
+	packetPingresp    byte = 13
+	packetDisconnect  byte = 14
+	protocolLevel311  byte = 4
+	maxRemainingBytes      = 268435455
+	maxStringBytes         = 65535
+
+	// maxPublishOverhead 是 PUBLISH 报文中除有效载荷之外的最大长度：主题和报文标识符
+	maxPublishOverhead = 2 + maxStringBytes + 2
+)
+
+var (
+	// errMalformedPacket 表示报文格式错误
+	errMalformedPacket = errors.New("MQTT 报文格式错误")
+
+	// errPacketTooLarge 表示报文超过允许的最大长度
+	errPacketTooLarge = errors.New("MQTT 报文过大")
+)
+
+// packet 是一个解码后的 MQTT 控制报文
+type packet struct {
+	typ   byte   // 报文类型
+	flags byte   // 固定头中的标志位
+	body  []byte // 可变头和有效载荷
+}
+
+// readPacket 从连接中读取一个控制报文，剩余长度超过 max 的报文在分配内存之前被拒绝
+// 参数:
+//   - r: 读取器
+//   - max: 允许的最大剩余长度
+//
+// 返回值:
+//   - *packet: 控制报文
+//   - error: 错误信息，如果有的话
+func readPacket(r *bufio.Reader, max int) (*packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var length, shift int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return nil, errMalformedPacket
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		shift += 7
+	}
+
+	if length > max {
+		return nil, fmt.Errorf("%w: %d 字节", errPacketTooLarge, length)
+	}
+
+	// 按实际收到的数据增长缓冲区，而不是按声明的长度预先分配
+	body, err := io.ReadAll(io.LimitReader(r, int64(length)))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return &packet{typ: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// encodePacket 编码一个控制报文
+// 参数:
+//   - typ: 报文类型
+//   - flags: 固定头中的标志位
+//   - body: 可变头和有效载荷
+//
+// 返回值:
+//   - []byte: 编码后的报文
+//   - error: 错误信息，如果有的话
+func encodePacket(typ, flags byte, body []byte) ([]byte, error) {
+	if len(body) > maxRemainingBytes {
+		return nil, fmt.Errorf("MQTT 报文过大: %d 字节", len(body))
+	}
+
+	out := make([]byte, 0, 5+len(body))
+	out = append(out, typ<<4|flags)
+	n := len(body)
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(out, body...), nil
+}
+
+// appendString 追加一个带 2 字节长度前缀的字符串
+// 参数:
+//   - b: 数据
+//   - s: 字符串
+//
+// 返回值:
+//   - []byte: 追加后的数据
+//   - error: 字符串超过 65535 字节时返回错误
+func appendString(b []byte, s string) ([]byte, error) {
+	if len(s) > maxStringBytes {
+		return nil, fmt.Errorf("MQTT 字符串过长: %d 字节", len(s))
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...), nil
+}
+
+// readString 读取一个带 2 字节长度前缀的字符串
+// 参数:
+//   - b: 数据
+//
+// 返回值:
+//   - string: 字符串
+//   - []byte: 剩余数据
+//   - error: 错误信息，如果有的话
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errMalformedPacket
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errMalformedPacket
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// connectPacket 编码 CONNECT 报文，总是使用清除会话
+// 参数:
+//   - clientID: 客户端 ID
+//   - username: 用户名，为空时不发送
+//   - password: 密码，为空时不发送
+//   - keepAlive: 保活间隔（秒）
+//
+// 返回值:
+//   - []byte: 编码后的报文
+//   - error: 错误信息，如果有的话
+func connectPacket(clientID, username, password string, keepAlive uint16) ([]byte, error) {
+	flags := byte(0x02)
+	if username != "" {
+		flags |= 0x80
+	}
+	if password != "" {
+		flags |= 0x40
+	}
+
+	body, _ := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body, err := appendString(body, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if username != "" {
+		if body, err = appendString(body, username); err != nil {
+			return nil, err
+		}
+	}
+	if password != "" {
+		if body, err = appendString(body, password); err != nil {
+			return nil, err
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+// subscribePacket 编码 QoS 0 的 SUBSCRIBE 报文
+// 参数:
+//   - id: 报文标识符
+//   - filters: 主题过滤器
+//
+// 返回值:
+//   - []byte: 编码后的报文
+//   - error: 错误信息，如果有的话
+func subscribePacket(id uint16, filters []string) ([]byte, error) {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, f := range filters {
+		var err error
+		if body, err = appendString(body, f); err != nil {
+			return nil, err
+		}
+		body = append(body, 0) // QoS 0
+	}
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// publishPacket 编码 QoS 0 的 PUBLISH 报文
+// 参数:
+//   - topic: 主题
+//   - payload: 有效载荷
+//
+// 返回值:
+//   - []byte: 编码后的报文
+//   - error: 错误信息，如果有的话
+func publishPacket(topic string, payload []byte) ([]byte, error) {
+	body, err := appendString(nil, topic)
+	if err != nil {
+		return nil, err
+	}
+	return encodePacket(packetPublish, 0, append(body, payload...))
+}
+
+// parsePublish 解析 PUBLISH 报文
+// 参数:
+//   - p: 控制报文
+//
+// 返回值:
+//   - string: 主题
+//   - uint16: 报文标识符，QoS 0 时为 0
+//   - []byte: 有效载荷
+//   - error: 错误信息，如果有的话
+func parsePublish(p *packet) (string, uint16, []byte, error) {
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	var id uint16
+	if qos := (p.flags >> 1) & 0x03; qos > 0 {
+		if len(rest) < 2 {
+			return "", 0, nil, errMalformedPacket
+		}
+		id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	return topic, id, rest, nil
+}
+
+// matchTopic 检查主题是否匹配 MQTT 主题过滤器，支持 + 和 # 通配符
+// 参数:
+//   - filter: 主题过滤器
+//   - topic: 主题
+//
+// 返回值:
+//   - bool: 是否匹配
+func matchTopic(filter, topic string) bool {
+	for {
+		fl, frest, fmore := strings.Cut(filter, "/")
+		if fl == "#" {
+			return true
+		}
+		tl, trest, tmore := strings.Cut(topic, "/")
+		if fl != "+" && fl != tl {
+			return false
+		}
+		if !fmore || !tmore {
+			// 过滤器 "a/#" 也匹配 "a"
+			return fmore == tmore || (fmore && frest == "#")
+		}
+		filter, topic = frest, trest
+	}
+}
+
+// hasWildcard 检查主题过滤器是否包含通配符
+func hasWildcard(filter string) bool {
+	return strings.ContainsAny(filter, "+#")
+}
@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestBridge 测试桥接器在两个网络之间双向转发消息，并且不会产生循环
+func TestBridge(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 网络 A: hosts[0] <-> hosts[1]；网络 B: hosts[2] <-> hosts[3]
+	// hosts[1] 和 hosts[2] 是同一个网关上的两个实例
+	hosts := getDefaultHosts(t, 4)
+	psubs := getGossipsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[2], hosts[3])
+
+	bridge, err := NewBridge(psubs[1], psubs[2], map[string]string{"chat-a": "chat-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	topicA, err := psubs[0].Join("chat-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subA, err := topicA.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	topicB, err := psubs[3].Join("chat-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subB, err := topicB.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+
+	next := func(sub *Subscription) *Message {
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer tcancel()
+		msg, err := sub.Next(tctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+	expectNone := func(sub *Subscription) {
+		tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer tcancel()
+		if msg, err := sub.Next(tctx); err == nil {
+			t.Fatalf("expected no further messages, got %q", msg.Data)
+		}
+	}
+
+	if err := topicA.Publish(ctx, []byte("from-a")); err != nil {
+		t.Fatal(err)
+	}
+	msg := next(subB)
+	if string(msg.Data) != "from-a" {
+		t.Fatalf("expected bridged message, got %q", msg.Data)
+	}
+	if !bridge.relayed(msg) {
+		t.Fatal("expected bridged message to carry the origin tag")
+	}
+
+	if err := topicB.Publish(ctx, []byte("from-b")); err != nil {
+		t.Fatal(err)
+	}
+	if msg := next(subA); string(msg.Data) != "from-b" {
+		t.Fatalf("expected bridged message, got %q", msg.Data)
+	}
+
+	// 消息不会被桥接回来源网络
+	expectNone(subA)
+	expectNone(subB)
+
+	if err := bridge.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭后桥接器加入的主题可以被重新加入
+	if _, err := psubs[1].Join("chat-a"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestBridgeLoopPrevention 测试桥接器丢弃带有自身来源标记的消息
+func TestBridgeLoopPrevention(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	if _, err := NewBridge(psubs[0], psubs[0], map[string]string{"a": "b"}); err == nil {
+		t.Fatal("expected error for bridging an instance to itself")
+	}
+	if _, err := NewBridge(psubs[0], psubs[1], nil); err == nil {
+		t.Fatal("expected error for empty topic map")
+	}
+
+	bridge, err := NewBridge(psubs[0], psubs[1], map[string]string{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bridge.Close()
+
+	msg := &Message{Message: &pb.Message{Annotations: []*pb.Annotation{{Key: bridgeOriginKey, Value: bridge.id}}}}
+	if !bridge.relayed(msg) {
+		t.Fatal("expected message tagged by this bridge to be detected")
+	}
+	msg = &Message{Message: &pb.Message{Annotations: []*pb.Annotation{{Key: bridgeOriginKey, Value: []byte("other")}}}}
+	if bridge.relayed(msg) {
+		t.Fatal("expected message tagged by another bridge to be relayed")
+	}
+}
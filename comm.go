@@ -5,12 +5,11 @@ package pubsub
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"io"
+	"sync/atomic"
 	"time"
 
-	"github.com/dep2p/go-dep2p/multiformats/varint"
-	pool "github.com/dep2p/go-dep2p/p2plib/buffer/pool"
 	logging "github.com/dep2p/log"
 	pb "github.com/dep2p/pubsub/pb"
 	"github.com/gogo/protobuf/proto"
@@ -55,7 +54,17 @@ func (p *PubSub) getHelloPacket() *RPC {
 // 参数:
 //   - s: 新建的网络流
 func (p *PubSub) handleNewStream(s network.Stream) {
-	peer := s.Conn().RemotePeer() // 获取远端节点的ID
+	defer track(&p.goroutines.inbound)()
+
+	peer := s.Conn().RemotePeer()               // 获取远端节点的ID
+	path := s.Conn().RemoteMultiaddr().String() // 获取流所在连接的远端地址
+
+	// 重置不在允许名单中的节点的流
+	if !p.peerAllowed(peer) {
+		logger.Debugf("重置不在允许名单中的节点 %s 的流", peer)
+		s.Reset()
+		return
+	}
 
 	// 处理重复的入站流
 	p.inboundStreamsMx.Lock()            // 加锁保护对入站流映射的访问
@@ -75,12 +84,15 @@ func (p *PubSub) handleNewStream(s network.Stream) {
 		p.inboundStreamsMx.Unlock() // 解锁
 	}()
 
-	r := msgio.NewVarintReaderSize(s, p.maxMessageSize) // 创建带有变长整数读取器的消息读取器，读取消息时自动处理消息大小
+	r := msgio.NewVarintReaderSize(s, p.transmissionLimit()) // 创建带有变长整数读取器的消息读取器，读取消息时自动处理消息大小
 	for {
 		msgbytes, err := r.ReadMsg() // 从流中读取消息
 		if err != nil {
 			r.ReleaseMsg(msgbytes) // 释放消息缓冲区
-			if err != io.EOF {     // 如果不是正常的流结束错误
+			if errors.Is(err, msgio.ErrMsgTooLarge) {
+				p.rejectOversizedRPC(peer) // 超出最大传输大小的 RPC 计入惩罚
+			}
+			if err != io.EOF { // 如果不是正常的流结束错误
 				s.Reset()                                                       // 重置流
 				logger.Debugf("从 %s 读取 RPC 失败: %s", s.Conn().RemotePeer(), err) // 记录读取错误
 			} else {
@@ -103,6 +115,9 @@ func (p *PubSub) handleNewStream(s network.Stream) {
 		}
 
 		rpc.from = peer // 设置消息的来源节点ID
+		rpc.path = path // 设置接收消息的连接地址
+		rpc.proto = p.routerProtocol(s.Protocol())
+		rpc.received = time.Now()
 		select {
 		case p.incoming <- rpc: // 将RPC消息发送到incoming通道
 		case <-p.ctx.Done(): // 如果上下文完成，意味着PubSub停止工作
@@ -143,7 +158,7 @@ func (p *PubSub) notifyPeerDead(pid peer.ID) {
 //   - outgoing: 发往新节点的RPC消息通道
 func (p *PubSub) handleNewPeer(ctx context.Context, pid peer.ID, outgoing <-chan *RPC) {
 	// 尝试建立到新节点的流连接
-	s, err := p.host.NewStream(p.ctx, pid, p.rt.Protocols()...)
+	s, err := p.host.NewStream(p.ctx, pid, p.protocols()...)
 	if err != nil {
 		logger.Debugf("打开新流到对等节点失败: %s", err)
 
@@ -158,9 +173,10 @@ func (p *PubSub) handleNewPeer(ctx context.Context, pid peer.ID, outgoing <-chan
 	}
 
 	// 启动协程处理发送消息到新节点
-	go handleSendingMessages(ctx, s, outgoing)
+	retired := new(atomic.Bool)
+	go p.handleSendingMessages(ctx, s, retired, outgoing)
 	// 启动协程处理节点死亡事件
-	go p.handlePeerDead(s)
+	go p.handlePeerDead(s, retired)
 
 	// 将新建立的流发送到newPeerStream通道
 	select {
@@ -188,7 +204,10 @@ func (p *PubSub) handleNewPeerWithBackoff(ctx context.Context, pid peer.ID, back
 // handlePeerDead 方法处理节点死亡事件
 // 参数:
 //   - s: 网络流
-func (p *PubSub) handlePeerDead(s network.Stream) {
+//   - retired: 流被写入方主动关闭或替换时设置，此时不通知节点死亡
+func (p *PubSub) handlePeerDead(s network.Stream, retired *atomic.Bool) {
+	defer track(&p.goroutines.watchers)()
+
 	pid := s.Conn().RemotePeer() // 获取远端节点ID
 
 	_, err := s.Read([]byte{0})
@@ -196,7 +215,10 @@ func (p *PubSub) handlePeerDead(s network.Stream) {
 		logger.Debugf("从 %s 收到意外消息", pid)
 	}
 
-	s.Reset()             // 重置流
+	s.Reset() // 重置流
+	if retired.Load() {
+		return // 写入方已经关闭或替换了流
+	}
 	p.notifyPeerDead(pid) // 通知节点死亡
 }
 
@@ -204,26 +226,16 @@ func (p *PubSub) handlePeerDead(s network.Stream) {
 // 参数:
 //   - ctx: 上下文
 //   - s: 网络流
+//   - retired: 流的退役标记，与 handlePeerDead 共享
 //   - outgoing: 发往节点的RPC消息通道
-func handleSendingMessages(ctx context.Context, s network.Stream, outgoing <-chan *RPC) {
-	// 定义内部函数 writeRpc 用于写入RPC消息
-	writeRpc := func(rpc *RPC) error {
-		size := uint64(rpc.Size()) // 获取RPC消息的大小
+func (p *PubSub) handleSendingMessages(ctx context.Context, s network.Stream, retired *atomic.Bool, outgoing <-chan *RPC) {
+	defer track(&p.goroutines.outbound)()
 
-		buf := pool.Get(varint.UvarintSize(size) + int(size)) // 从池中获取缓冲区
-		defer pool.Put(buf)                                   // 使用完毕后将缓冲区放回池中
+	w := &streamWriter{p: p, pid: s.Conn().RemotePeer(), proto: s.Protocol(), s: s, retired: retired}
+	defer w.close() // 函数结束时关闭流
 
-		n := binary.PutUvarint(buf, size) // 将消息大小编码为变长整数并写入缓冲区
-		_, err := rpc.MarshalTo(buf[n:])  // 将RPC消息序列化到缓冲区
-		if err != nil {
-			return err // 如果序列化出错，返回错误
-		}
-
-		_, err = s.Write(buf) // 将缓冲区中的数据写入网络流
-		return err            // 返回写入操作的错误（如果有）
-	}
-
-	defer s.Close() // 函数结束时关闭流
+	idle := w.idleTimer()
+	defer idle.Stop()
 	for {
 		select {
 		case rpc, ok := <-outgoing: // 从 outgoing 通道接收RPC消息
@@ -231,12 +243,20 @@ func handleSendingMessages(ctx context.Context, s network.Stream, outgoing <-cha
 				return
 			}
 
-			err := writeRpc(rpc) // 调用 writeRpc 写入RPC消息
+			var err error
+			for _, frame := range p.splitRPC(rpc, w.pid) { // 超出最大传输大小的 RPC 拆分为多个帧
+				if err = w.write(ctx, frame); err != nil { // 写入RPC消息，按流策略重试
+					break
+				}
+			}
+			p.releaseRPC(rpc) // 释放 RPC 占用的内存预算
 			if err != nil {
-				s.Reset()                                                    // 如果写入失败，重置流
-				logger.Debugf("写入消息到 %s 失败: %s", s.Conn().RemotePeer(), err) // 记录写入错误
+				w.fail(err) // 写入永久失败，重置流并通知节点死亡
 				return
 			}
+			w.resetIdle(idle)
+		case <-idle.C: // 出站流空闲超时
+			w.retire()
 		case <-ctx.Done(): // 如果上下文完成
 			return
 		}
@@ -0,0 +1,158 @@
+// 作用：Dandelion 茎/绒毛两阶段发布。
+// 功能：启用后主题的消息先沿随机选择的单条路径（茎）逐跳转发，每一跳以一定概率转入正常的洪泛或 gossip 传播（绒毛），
+// 使观察者难以根据首次收到消息的位置推断发布者。茎阶段的节点在超时内未看到消息被传播时自行传播，防止消息在茎上丢失。
+// 要求启用 WithAnonymousPublishing：否则来源字段和签名会暴露发布者，而且路由器不会把消息传播回来源节点，
+// 发布者看不到消息被正常传播，总会在超时后自行传播。
+
+package pubsub
+
+import (
+	"bytes"
+	"fmt"
+	mrand "math/rand"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// dandelionParams 是主题的 Dandelion 参数
+type dandelionParams struct {
+	stemProbability float64       // 收到茎消息后继续沿茎转发的概率
+	stemTimeout     time.Duration // 茎阶段的消息在该时间内未被传播时自行传播
+}
+
+// WithDandelion 是一个主题选项，用于启用 Dandelion 茎/绒毛两阶段发布。
+// 本地发布的消息先转发给一个随机的主题对等节点，收到茎消息的节点以 stemProbability 的概率继续转发给另一个随机对等节点，
+// 否则按路由器正常传播。转发茎消息的节点在 stemTimeout 内未收到正常传播的消息时自行传播。
+// 未启用该选项的节点收到茎消息时直接正常传播。要求 PubSub 启用 WithAnonymousPublishing。
+// 参数:
+//   - stemProbability: 继续沿茎转发的概率，取值范围 [0, 1)
+//   - stemTimeout: 茎阶段的超时时间
+//
+// 返回值:
+//   - TopicOpt: 主题选项
+func WithDandelion(stemProbability float64, stemTimeout time.Duration) TopicOpt {
+	return func(t *Topic) error {
+		if stemProbability < 0 || stemProbability >= 1 {
+			logger.Warnf("茎转发概率必须在 [0, 1) 范围内: %f", stemProbability)
+			return fmt.Errorf("茎转发概率必须在 [0, 1) 范围内: %f", stemProbability)
+		}
+		if stemTimeout <= 0 {
+			logger.Warnf("茎阶段超时时间必须为正数")
+			return fmt.Errorf("茎阶段超时时间必须为正数")
+		}
+		if !t.p.anonymous {
+			logger.Warnf("Dandelion 发布需要启用匿名发布")
+			return fmt.Errorf("Dandelion 发布需要启用匿名发布")
+		}
+		t.dandelion = &dandelionParams{stemProbability: stemProbability, stemTimeout: stemTimeout}
+		return nil
+	}
+}
+
+// stemMessage 在消息处于茎阶段时将其转发给一个随机的对等节点。只从 processLoop 调用。
+// 参数:
+//   - msg: 要转发的消息
+//
+// 返回值:
+//   - bool: 消息是否沿茎转发，为 false 时应正常传播
+func (p *PubSub) stemMessage(msg *Message) bool {
+	topic := msg.GetTopic()
+	t, ok := p.myTopics[topic]
+	if !ok || t.dandelion == nil {
+		return false
+	}
+
+	local := msg.ReceivedFrom == p.host.ID()
+	if !local && (!msg.stem || mrand.Float64() >= t.dandelion.stemProbability) {
+		return false
+	}
+
+	var candidates []peer.ID
+	for pid := range p.topics[topic] {
+		if pid == msg.ReceivedFrom || pid == peer.ID(msg.GetFrom()) {
+			continue
+		}
+		if _, ok := p.peers[pid]; ok {
+			candidates = append(candidates, pid)
+		}
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+	pid := candidates[mrand.Intn(len(candidates))]
+
+	out := &RPC{RPC: pb.RPC{Stem: []*pb.Message{msg.Message}}}
+	if !p.reserveRPC(out) {
+		logger.Debugf("丢弃发往 %s 的茎消息: 内存预算不足", pid)
+		p.tracer.DropRPC(out, pid)
+		return false
+	}
+	select {
+	case p.peers[pid] <- out:
+		p.tracer.SendRPC(out, pid)
+	default:
+		p.releaseRPC(out)
+		logger.Debugf("丢弃发往 %s 的茎消息: 队列已满", pid)
+		p.tracer.DropRPC(out, pid)
+		return false
+	}
+
+	id := p.idGen.ID(msg)
+	p.stems[id] = msg
+	time.AfterFunc(t.dandelion.stemTimeout, func() {
+		select {
+		case p.eval <- func() { p.stemTimeout(id, msg) }:
+		case <-p.ctx.Done():
+		}
+	})
+	return true
+}
+
+// stemTimeout 在茎阶段超时后自行传播仍未被传播的消息。只从 processLoop 调用。
+// 参数:
+//   - id: 消息 ID
+//   - msg: 消息
+func (p *PubSub) stemTimeout(id string, msg *Message) {
+	if _, ok := p.stems[id]; !ok {
+		return
+	}
+	delete(p.stems, id)
+	logger.Debugf("茎消息 %s 超时未被传播，自行传播", msg.GetTopic())
+	p.rt.Publish(msg)
+}
+
+// observeFluff 记录正常传播回来的茎阶段消息，使其不再需要在超时后自行传播。只从 processLoop 调用。
+// 茎阶段的消息已经被标记为已看到，正常传播的副本会作为重复消息到达，不经过验证管道；
+// 因此只有与沿茎转发的消息完全相同（包括签名）的副本才被接受，伪造的同 ID 消息不能取消自行传播。
+// 参数:
+//   - id: 消息 ID
+//   - msg: 正常传播的消息
+func (p *PubSub) observeFluff(id string, msg *Message) {
+	if len(p.stems) == 0 || msg.stem {
+		return
+	}
+	stemmed, ok := p.stems[id]
+	if !ok || !sameMessage(stemmed.Message, msg.Message) {
+		return
+	}
+	delete(p.stems, id)
+}
+
+// sameMessage 判断两条消息的内容、来源和签名是否完全相同，不比较逐跳变化的字段
+// 参数:
+//   - a: 第一条消息
+//   - b: 第二条消息
+//
+// 返回值:
+//   - bool: 两条消息是否相同
+func sameMessage(a, b *pb.Message) bool {
+	return a.GetTopic() == b.GetTopic() &&
+		bytes.Equal(a.GetFrom(), b.GetFrom()) &&
+		bytes.Equal(a.GetSeqno(), b.GetSeqno()) &&
+		bytes.Equal(a.GetData(), b.GetData()) &&
+		bytes.Equal(a.GetSignature(), b.GetSignature()) &&
+		bytes.Equal(a.GetKey(), b.GetKey())
+}
@@ -0,0 +1,199 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// sendRecorder 记录发出的茎消息和正常消息的低级追踪器
+type sendRecorder struct {
+	RawTracer // 只接收 SendRPC 事件
+
+	mx        sync.Mutex
+	stemTo    []peer.ID
+	published int
+}
+
+// SendRPC 实现 RawTracer 接口
+func (r *sendRecorder) SendRPC(rpc *RPC, p peer.ID) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if len(rpc.GetStem()) > 0 {
+		r.stemTo = append(r.stemTo, p)
+	}
+	r.published += len(rpc.GetPublish())
+}
+
+// counts 返回茎消息的接收者和正常消息数
+func (r *sendRecorder) counts() ([]peer.ID, int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]peer.ID(nil), r.stemTo...), r.published
+}
+
+// TestDandelion 测试本地发布的消息先沿茎转发给一个对等节点，再由茎上的节点正常传播
+func TestDandelion(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	rec := &sendRecorder{}
+	psubs := []*PubSub{getPubsub(ctx, hosts[0], WithAnonymousPublishing(true), WithRawTracer(rec, FilterEvents(SendRPC)))}
+	psubs = append(psubs, getPubsubs(ctx, hosts[1:], WithAnonymousPublishing(true))...)
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("dandelion", WithDandelion(0, time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	if err := topics[0].Publish(ctx, []byte("stem")); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range subs[1:] {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != "stem" {
+			t.Fatalf("unexpected message: %s", msg.Data)
+		}
+	}
+
+	stemTo, published := rec.counts()
+	if len(stemTo) != 1 {
+		t.Fatalf("expected the message to be stemmed to exactly one peer, got %v", stemTo)
+	}
+	if published != 0 {
+		t.Fatalf("expected the publisher not to flood the message, got %d published", published)
+	}
+
+	if _, err := psubs[0].Join("invalid", WithDandelion(1, time.Second)); err == nil {
+		t.Fatal("expected error for stem probability 1")
+	}
+	if _, err := psubs[0].Join("invalid", WithDandelion(0.5, 0)); err == nil {
+		t.Fatal("expected error for non-positive stem timeout")
+	}
+	signed := getPubsub(ctx, getDefaultHosts(t, 1)[0])
+	if _, err := signed.Join("dandelion", WithDandelion(0, time.Second)); err == nil {
+		t.Fatal("expected error without anonymous publishing")
+	}
+}
+
+// TestDandelionStemTimeout 测试茎阶段的消息超时未被传播时由发布者自行传播
+func TestDandelionStemTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	rec := &sendRecorder{}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithAnonymousPublishing(true), WithRawTracer(rec, FilterEvents(SendRPC))),
+		getPubsub(ctx, hosts[1], WithAnonymousPublishing(true)),
+	}
+
+	topic, err := psubs[0].Join("dandelion", WithDandelion(0, 300*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("dandelion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if err := topic.Publish(ctx, []byte("stem")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 唯一的对等节点不会把消息传播回发布者，超时后发布者自行传播
+	if stemTo, published := rec.counts(); len(stemTo) != 1 || published != 0 {
+		t.Fatalf("expected a single stem send before the timeout, got %v stems and %d published", stemTo, published)
+	}
+	time.Sleep(600 * time.Millisecond)
+	if _, published := rec.counts(); published != 1 {
+		t.Fatalf("expected the publisher to fluff the message after the stem timeout, got %d published", published)
+	}
+}
+
+// TestDandelionForgedFluff 测试正常传播回来的相同消息会取消茎阶段消息的自行传播，而伪造的同 ID 消息不能
+func TestDandelionForgedFluff(t *testing.T) {
+	for _, forge := range []bool{false, true} {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		hosts := getDefaultHosts(t, 2)
+		rec := &sendRecorder{}
+		ps := getGossipsub(ctx, hosts[0], WithAnonymousPublishing(true), WithRawTracer(rec, FilterEvents(SendRPC)))
+
+		// 模拟的茎节点不传播收到的茎消息，只把相同或伪造的副本发回发布者
+		newMockGS(ctx, t, hosts[1], func(writeMsg func(*pb.RPC), irpc *pb.RPC) {
+			for _, sub := range irpc.GetSubscriptions() {
+				if sub.GetSubscribe() {
+					writeMsg(&pb.RPC{
+						Subscriptions: []*pb.RPC_SubOpts{{Subscribe: sub.Subscribe, Topicid: sub.Topicid}},
+					})
+				}
+			}
+			for _, msg := range irpc.GetStem() {
+				copied := *msg
+				copied.Hops++
+				if forge {
+					copied.Data = []byte("forged")
+				}
+				writeMsg(&pb.RPC{Publish: []*pb.Message{&copied}})
+			}
+		})
+
+		topic, err := ps.Join("dandelion", WithDandelion(0, 300*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		connect(t, hosts[0], hosts[1])
+		time.Sleep(time.Second)
+
+		if err := topic.Publish(ctx, []byte("stem")); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Second)
+
+		stemTo, published := rec.counts()
+		if len(stemTo) != 1 {
+			t.Fatalf("expected the message to be stemmed to the mock peer, got %v", stemTo)
+		}
+		if forge && published != 1 {
+			t.Fatalf("expected a forged copy not to cancel the stem timeout, got %d published", published)
+		}
+		if !forge && published != 0 {
+			t.Fatalf("expected an identical fluffed copy to cancel the stem timeout, got %d published", published)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,193 @@
+// 作用：内部队列和 goroutine 诊断。
+// 功能：提供各内部队列深度、各子系统 goroutine 数、出站队列阻塞的对等节点和心跳调度延迟的快照，
+// 并可在事件循环、验证管道或心跳停滞超过阈值时记录日志，帮助定位性能问题出在验证、路由还是事件循环。
+
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// 诊断快照中各子系统 goroutine 的名称
+const (
+	GoroutineEventLoop         = "event-loop"         // 事件循环
+	GoroutineValidationWorkers = "validation-workers" // 同步验证工作线程
+	GoroutineValidation        = "validation"         // 正在进行的异步验证和主题验证器
+	GoroutineInboundStreams    = "inbound-streams"    // 读取入站流的 goroutine
+	GoroutineOutboundStreams   = "outbound-streams"   // 写入出站流的 goroutine
+	GoroutinePeerWatchers      = "peer-watchers"      // 检测对等节点断开的 goroutine
+)
+
+// Diagnostics 是 PubSub 内部状态的快照
+type Diagnostics struct {
+	EventLoopLatency  time.Duration   // 诊断请求在进入事件循环前等待的时间
+	IncomingQueue     int             // 等待事件循环处理的入站 RPC 数
+	SendQueue         int             // 等待事件循环转发的已验证消息数
+	ValidateQueue     int             // 验证管道前端排队的消息数
+	PeerQueues        map[peer.ID]int // 每个对等节点出站队列中的 RPC 数
+	BlockedPeers      []peer.ID       // 出站队列已满的对等节点，发往它们的 RPC 会被丢弃
+	Goroutines        map[string]int  // 每个子系统的 goroutine 数，键为 Goroutine* 常量
+	HeartbeatLatency  time.Duration   // 最近一次 gossipsub 心跳相对定时器触发的延迟，其他路由器为 0
+	HeartbeatDuration time.Duration   // 最近一次 gossipsub 心跳的执行时间，其他路由器为 0
+}
+
+// goroutineCounters 统计在事件循环之外运行的每个对等节点的 goroutine
+type goroutineCounters struct {
+	inbound  atomic.Int64 // 读取入站流
+	outbound atomic.Int64 // 写入出站流
+	watchers atomic.Int64 // 检测对等节点断开
+}
+
+// track 将计数器加一，并返回在 goroutine 退出时调用的减一函数
+// 参数:
+//   - c: 计数器
+//
+// 返回值:
+//   - func(): 减一函数
+func track(c *atomic.Int64) func() {
+	c.Add(1)
+	return func() { c.Add(-1) }
+}
+
+// WithStallWarning 是一个选项，用于在内部循环停滞超过阈值时记录警告日志：
+// 事件循环在阈值内未处理探测请求、验证队列已满，或 gossipsub 心跳晚于定时器触发超过阈值。
+// 参数:
+//   - threshold: 停滞阈值
+//
+// 返回值:
+//   - Option: 配置选项
+func WithStallWarning(threshold time.Duration) Option {
+	return func(p *PubSub) error {
+		if threshold <= 0 {
+			logger.Warnf("停滞阈值必须为正数")
+			return fmt.Errorf("停滞阈值必须为正数")
+		}
+		p.stallThreshold = threshold
+		return nil
+	}
+}
+
+// Diagnostics 返回内部队列、goroutine 和心跳的诊断快照
+// 返回值:
+//   - *Diagnostics: 诊断快照，PubSub 已关闭时返回 nil
+func (p *PubSub) Diagnostics() *Diagnostics {
+	requested := time.Now()
+	out := make(chan *Diagnostics, 1)
+	get := func() {
+		d := &Diagnostics{
+			EventLoopLatency: time.Since(requested),
+			IncomingQueue:    len(p.incoming),
+			SendQueue:        len(p.sendMsg),
+			ValidateQueue:    len(p.val.validateQ),
+			PeerQueues:       make(map[peer.ID]int, len(p.peers)),
+			Goroutines: map[string]int{
+				GoroutineEventLoop:         1,
+				GoroutineValidationWorkers: p.val.validateWorkers,
+				GoroutineValidation:        p.val.activeValidations(),
+				GoroutineInboundStreams:    int(p.goroutines.inbound.Load()),
+				GoroutineOutboundStreams:   int(p.goroutines.outbound.Load()),
+				GoroutinePeerWatchers:      int(p.goroutines.watchers.Load()),
+			},
+		}
+		for pid, ch := range p.peers {
+			d.PeerQueues[pid] = len(ch)
+			if len(ch) == cap(ch) {
+				d.BlockedPeers = append(d.BlockedPeers, pid)
+			}
+		}
+		sort.Slice(d.BlockedPeers, func(i, j int) bool { return d.BlockedPeers[i] < d.BlockedPeers[j] })
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			d.HeartbeatLatency = gs.heartbeatLatency
+			d.HeartbeatDuration = gs.heartbeatDuration
+		}
+		out <- d
+	}
+
+	select {
+	case p.eval <- get:
+		return <-out
+	case <-p.ctx.Done():
+		return nil
+	}
+}
+
+// activeValidations 返回正在进行的异步验证和主题验证器 goroutine 数
+// 返回值:
+//   - int: goroutine 数
+func (v *validation) activeValidations() int {
+	v.mx.Lock()
+	defer v.mx.Unlock()
+
+	n := len(v.validateThrottle)
+	for _, val := range v.topicVals {
+		n += len(val.validateThrottle)
+	}
+	return n
+}
+
+// watchStalls 周期性地探测事件循环和验证队列，停滞超过阈值时记录警告
+func (p *PubSub) watchStalls() {
+	ticker := time.NewTicker(p.stallThreshold)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+
+		if n := len(p.val.validateQ); n == cap(p.val.validateQ) {
+			logger.Warnf("验证队列已满 (%d)，新消息将被丢弃", n)
+		}
+
+		start := time.Now()
+		done := make(chan struct{})
+		eval := p.eval
+		timeout := time.NewTimer(p.stallThreshold)
+		stalled := false
+	probe:
+		for {
+			select {
+			case eval <- func() { close(done) }:
+				eval = nil // 探测已进入事件循环，等待其执行
+			case <-done:
+				break probe
+			case <-timeout.C:
+				stalled = true
+				logger.Warnf("事件循环停滞超过 %s，入站队列 %d，转发队列 %d，验证队列 %d",
+					p.stallThreshold, len(p.incoming), len(p.sendMsg), len(p.val.validateQ))
+			case <-p.ctx.Done():
+				timeout.Stop()
+				return
+			}
+		}
+		timeout.Stop()
+		if stalled {
+			logger.Warnf("事件循环在停滞 %s 后恢复", time.Since(start))
+		}
+	}
+}
+
+// scheduledHeartbeat 返回在定时器于 tick 触发后执行的心跳函数，并记录心跳的调度延迟和执行时间
+// 参数:
+//   - tick: 定时器触发的时间
+//
+// 返回值:
+//   - func(): 在事件循环中执行的心跳函数
+func (gs *GossipSubRouter) scheduledHeartbeat(tick time.Time) func() {
+	return func() {
+		start := time.Now()
+		gs.heartbeatLatency = start.Sub(tick)
+		if t := gs.p.stallThreshold; t > 0 && gs.heartbeatLatency > t {
+			logger.Warnf("心跳比预定时间晚 %s 执行", gs.heartbeatLatency)
+		}
+		gs.heartbeat()
+		gs.heartbeatDuration = time.Since(start)
+	}
+}
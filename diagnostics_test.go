@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestDiagnostics 测试诊断快照中的队列、goroutine 和心跳信息
+func TestDiagnostics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts, WithStallWarning(50*time.Millisecond))
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("diag"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	d := psubs[0].Diagnostics()
+	if d == nil {
+		t.Fatal("expected diagnostics")
+	}
+	if _, ok := d.PeerQueues[hosts[1].ID()]; !ok {
+		t.Fatalf("expected an outbound queue for the remote peer, got %v", d.PeerQueues)
+	}
+	if len(d.BlockedPeers) != 0 {
+		t.Fatalf("expected no blocked peers, got %v", d.BlockedPeers)
+	}
+	for _, name := range []string{GoroutineInboundStreams, GoroutineOutboundStreams, GoroutinePeerWatchers} {
+		if d.Goroutines[name] != 1 {
+			t.Fatalf("expected 1 %s goroutine, got %d", name, d.Goroutines[name])
+		}
+	}
+	if d.Goroutines[GoroutineEventLoop] != 1 || d.Goroutines[GoroutineValidationWorkers] == 0 {
+		t.Fatalf("unexpected goroutine counts: %v", d.Goroutines)
+	}
+	if d.HeartbeatDuration == 0 {
+		t.Fatal("expected the heartbeat to have run")
+	}
+
+	// 阻塞事件循环后，诊断请求的等待时间反映停滞
+	blocked := make(chan struct{})
+	go func() {
+		psubs[0].eval <- func() {
+			close(blocked)
+			time.Sleep(300 * time.Millisecond)
+		}
+	}()
+	<-blocked
+	d = psubs[0].Diagnostics()
+	if d.EventLoopLatency < 200*time.Millisecond {
+		t.Fatalf("expected the event loop latency to reflect the stall, got %s", d.EventLoopLatency)
+	}
+
+	// 关闭连接后对等节点的 goroutine 退出
+	hosts[1].Close()
+	time.Sleep(time.Second)
+	d = psubs[0].Diagnostics()
+	for _, name := range []string{GoroutineInboundStreams, GoroutineOutboundStreams, GoroutinePeerWatchers} {
+		if d.Goroutines[name] != 0 {
+			t.Fatalf("expected no %s goroutines after disconnect, got %d", name, d.Goroutines[name])
+		}
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithStallWarning(0)); err == nil {
+		t.Fatal("expected error for non-positive stall threshold")
+	}
+}
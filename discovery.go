@@ -5,6 +5,7 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"math/rand"
 	"time"
 
@@ -31,8 +32,11 @@ type DiscoverOpt func(*discoverOptions) error
 
 // discoverOptions 是发现配置的选项
 type discoverOptions struct {
-	connFactory BackoffConnectorFactory // 退避连接器工厂
-	opts        []discovery.Option      // 发现选项
+	connFactory  BackoffConnectorFactory // 退避连接器工厂
+	opts         []discovery.Option      // 发现选项
+	minPeers     int                     // 主题对等节点数低于该值时查找对等节点，为 0 时使用路由器的默认值
+	pollInterval time.Duration           // 检查主题对等节点数的间隔
+	backoff      BackoffStrategy         // 同一主题连续查找之间的退避策略，为 nil 时每次检查都查找
 }
 
 // defaultDiscoverOptions 返回默认的发现选项
@@ -44,6 +48,7 @@ func defaultDiscoverOptions() *discoverOptions {
 	dialTimeout := time.Minute * 2                      // 设置拨号超时时间为2分钟
 
 	discoverOpts := &discoverOptions{ // 初始化discoverOptions结构体
+		pollInterval: DiscoveryPollInterval,
 		// connFactory是一个函数，用于创建新的BackoffConnector
 		connFactory: func(host host.Host) (*discimpl.BackoffConnector, error) {
 			// 创建一个新的指数退避策略
@@ -75,12 +80,21 @@ type discover struct {
 	// advertising 跟踪正在广告的主题
 	advertising map[string]context.CancelFunc
 
+	// paused 跟踪广告暂停期间需要广告的主题，为 nil 时广告未暂停
+	paused map[string]struct{}
+
 	// discoverQ 处理持续的对等节点发现
 	discoverQ chan *discoverReq
 
 	// ongoing 跟踪正在进行的发现请求
 	ongoing map[string]struct{}
 
+	// findAttempts 跟踪对等节点不足的主题的连续查找次数，只在事件循环中访问
+	findAttempts map[string]int
+
+	// nextFind 跟踪对等节点不足的主题下一次允许查找的时间，只在事件循环中访问
+	nextFind map[string]time.Time
+
 	// done 处理发现请求的完成
 	done chan string
 
@@ -122,7 +136,14 @@ func (d *discover) Start(p *PubSub, opts ...DiscoverOpt) error {
 	d.advertising = make(map[string]context.CancelFunc) // 初始化广告映射，用于取消广告
 	d.discoverQ = make(chan *discoverReq, 32)           // 初始化发现请求通道，缓冲区大小为32
 	d.ongoing = make(map[string]struct{})               // 初始化进行中的请求映射
-	d.done = make(chan string)                          // 初始化完成通道，用于通知完成的发现请求
+	d.findAttempts = make(map[string]int)
+	d.nextFind = make(map[string]time.Time)
+
+	// 没有通过 WithDiscoveryBootstrap 指定查找退避时使用 WithDiscoveryBackoff 的策略
+	if d.options.backoff == nil {
+		d.options.backoff = p.backoffStrategy
+	}
+	d.done = make(chan string) // 初始化完成通道，用于通知完成的发现请求
 
 	conn, err := d.options.connFactory(p.host) // 使用连接器工厂创建连接器
 	if err != nil {                            // 如果创建连接器时出错
@@ -150,8 +171,8 @@ func (d *discover) pollTimer() {
 		return
 	}
 
-	ticker := time.NewTicker(DiscoveryPollInterval) // 创建新的定时器
-	defer ticker.Stop()                             // 函数结束时停止定时器
+	ticker := time.NewTicker(d.options.pollInterval) // 创建新的定时器
+	defer ticker.Stop()                              // 函数结束时停止定时器
 
 	for {
 		select {
@@ -167,11 +188,34 @@ func (d *discover) pollTimer() {
 	}
 }
 
-// requestDiscovery 发送发现请求
+// requestDiscovery 为对等节点不足的主题发送发现请求。
+// 配置了退避策略时，同一主题的连续查找之间按退避策略等待，主题的对等节点充足后退避重置。
 func (d *discover) requestDiscovery() {
+	now := time.Now()
 	for t := range d.p.myTopics { // 遍历所有主题
-		if !d.p.rt.EnoughPeers(t, 0) { // 检查是否有足够的对等节点
-			d.discoverQ <- &discoverReq{topic: t, done: make(chan struct{}, 1)} // 发送发现请求
+		ns, ok := d.namespace(t)
+		if !ok || d.p.rt.EnoughPeers(t, d.options.minPeers) { // 检查是否启用发现以及是否有足够的对等节点
+			delete(d.findAttempts, t)
+			delete(d.nextFind, t)
+			continue
+		}
+
+		if d.options.backoff != nil {
+			if now.Before(d.nextFind[t]) {
+				continue // 仍在退避中
+			}
+			d.findAttempts[t]++
+			d.nextFind[t] = now.Add(d.options.backoff.Delay(d.findAttempts[t]))
+		}
+
+		d.discoverQ <- &discoverReq{topic: t, ns: ns, done: make(chan struct{}, 1)} // 发送发现请求
+	}
+
+	// 清理已离开的主题的退避状态
+	for t := range d.findAttempts {
+		if _, ok := d.p.myTopics[t]; !ok {
+			delete(d.findAttempts, t)
+			delete(d.nextFind, t)
 		}
 	}
 }
@@ -191,7 +235,7 @@ func (d *discover) discoverLoop() {
 			d.ongoing[topic] = struct{}{} // 将该主题标记为正在进行发现请求
 
 			go func() {
-				d.handleDiscovery(d.p.ctx, topic, discover.opts) // 启动协程处理发现请求
+				d.handleDiscovery(d.p.ctx, discover.ns, discover.opts) // 启动协程处理发现请求
 				select {
 				case d.done <- topic: // 将已完成的主题发送到done通道
 				case <-d.p.ctx.Done(): // 如果上下文完成，退出
@@ -206,6 +250,20 @@ func (d *discover) discoverLoop() {
 	}
 }
 
+// namespace 返回主题在发现服务中使用的命名空间。namespace 只在事件循环中调用。
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - string: 命名空间
+//   - bool: 主题是否启用了发现
+func (d *discover) namespace(topic string) (string, bool) {
+	if t, ok := d.p.myTopics[topic]; ok {
+		return t.discoveryNamespace()
+	}
+	return topic, true
+}
+
 // Advertise 在发现服务中广告该节点对某个主题的兴趣。Advertise 不是线程安全的。
 // 参数:
 //   - topic: 主题
@@ -214,6 +272,16 @@ func (d *discover) Advertise(topic string) {
 		return
 	}
 
+	ns, ok := d.namespace(topic)
+	if !ok { // 主题禁用了发现
+		return
+	}
+
+	if d.paused != nil { // 如果广告已暂停，记录主题，恢复时再广告
+		d.paused[topic] = struct{}{}
+		return
+	}
+
 	advertisingCtx, cancel := context.WithCancel(d.p.ctx) // 创建带有取消功能的上下文
 
 	if _, ok := d.advertising[topic]; ok { // 如果该主题已经在广告中
@@ -223,11 +291,13 @@ func (d *discover) Advertise(topic string) {
 	d.advertising[topic] = cancel // 将取消函数存储到广告映射中
 
 	go func() { // 启动一个新的协程处理广告过程
-		next, err := d.discovery.Advertise(advertisingCtx, topic) // 在发现服务中广告该主题
-		if err != nil {                                           // 如果广告过程中出现错误
+		failures := 0                                          // 连续广告失败的次数
+		next, err := d.discovery.Advertise(advertisingCtx, ns) // 在发现服务中广告该主题
+		if err != nil {                                        // 如果广告过程中出现错误
 			logger.Warnf("bootstrap: 为主题 %s 提供集合点时发生错误: %s", topic, err.Error()) // 记录警告日志
-			if next == 0 {                                                       // 如果下一次广告间隔为0
-				next = discoveryAdvertiseRetryInterval // 使用默认的广告重试间隔
+			failures++
+			if next == 0 { // 如果下一次广告间隔为0
+				next = d.advertiseRetry(failures) // 使用广告重试间隔
 			}
 		}
 
@@ -237,12 +307,15 @@ func (d *discover) Advertise(topic string) {
 		for advertisingCtx.Err() == nil { // 当广告上下文未结束时循环
 			select {
 			case <-t.C: // 定时器触发
-				next, err = d.discovery.Advertise(advertisingCtx, topic) // 再次尝试广告该主题
-				if err != nil {                                          // 如果再次广告过程中出现错误
+				next, err = d.discovery.Advertise(advertisingCtx, ns) // 再次尝试广告该主题
+				if err != nil {                                       // 如果再次广告过程中出现错误
 					logger.Warnf("提供对等节点发现服务失败: %s", err.Error()) // 记录警告日志
-					if next == 0 {                                // 如果下一次广告间隔为0
-						next = discoveryAdvertiseRetryInterval // 使用默认的广告重试间隔
+					failures++
+					if next == 0 { // 如果下一次广告间隔为0
+						next = d.advertiseRetry(failures) // 使用广告重试间隔
 					}
+				} else {
+					failures = 0
 				}
 				t.Reset(next) // 重置定时器，根据下一次广告间隔
 			case <-advertisingCtx.Done(): // 如果广告上下文结束
@@ -252,6 +325,19 @@ func (d *discover) Advertise(topic string) {
 	}()
 }
 
+// advertiseRetry 返回广告失败后下一次重试前的等待时间
+// 参数:
+//   - failures: 连续失败的次数
+//
+// 返回值:
+//   - time.Duration: 等待时间
+func (d *discover) advertiseRetry(failures int) time.Duration {
+	if d.p.backoffStrategy != nil {
+		return d.p.backoffStrategy.Delay(failures)
+	}
+	return discoveryAdvertiseRetryInterval
+}
+
 // StopAdvertise 停止广告该节点对某个主题的兴趣。StopAdvertise 不是线程安全的。
 // 参数:
 //   - topic: 主题
@@ -264,19 +350,49 @@ func (d *discover) StopAdvertise(topic string) {
 		advertiseCancel()            // 调用取消广告的函数
 		delete(d.advertising, topic) // 从广告映射中删除该主题
 	}
+	delete(d.paused, topic) // 从暂停的主题中删除该主题
+}
+
+// pauseAdvertising 暂停所有主题的广告，暂停期间新的广告请求被记录下来。pauseAdvertising 不是线程安全的。
+func (d *discover) pauseAdvertising() {
+	if d.discovery == nil || d.paused != nil { // 如果发现服务为空或广告已暂停，直接返回
+		return
+	}
+
+	d.paused = make(map[string]struct{})
+	for topic, advertiseCancel := range d.advertising {
+		advertiseCancel()            // 取消主题的广告
+		delete(d.advertising, topic) // 从广告映射中删除该主题
+		d.paused[topic] = struct{}{} // 记录主题，恢复时再广告
+	}
+}
+
+// resumeAdvertising 恢复暂停期间需要广告的主题的广告。resumeAdvertising 不是线程安全的。
+func (d *discover) resumeAdvertising() {
+	if d.paused == nil { // 如果广告未暂停，直接返回
+		return
+	}
+
+	paused := d.paused
+	d.paused = nil
+	for topic := range paused {
+		d.Advertise(topic) // 重新广告该主题
+	}
 }
 
 // Discover 搜索对某个主题感兴趣的其他对等节点
 // 参数:
 //   - topic: 主题
+//   - ns: 主题在发现服务中使用的命名空间
 //   - opts: 可选的发现选项
-func (d *discover) Discover(topic string, opts ...discovery.Option) {
+func (d *discover) Discover(topic, ns string, opts ...discovery.Option) {
 	if d.discovery == nil {
 		return
 	}
 
 	d.discoverQ <- &discoverReq{
 		topic,                  // 设置主题
+		ns,                     // 设置命名空间
 		opts,                   // 设置发现选项
 		make(chan struct{}, 1), // 初始化完成通道
 	} // 发送发现请求
@@ -286,12 +402,13 @@ func (d *discover) Discover(topic string, opts ...discovery.Option) {
 // 参数:
 //   - ctx: 上下文
 //   - topic: 主题
+//   - ns: 主题在发现服务中使用的命名空间
 //   - ready: 路由器准备情况检查函数
 //   - opts: 可选的发现选项
 //
 // 返回值:
 //   - bool: 是否引导成功
-func (d *discover) Bootstrap(ctx context.Context, topic string, ready RouterReady, opts ...discovery.Option) bool {
+func (d *discover) Bootstrap(ctx context.Context, topic, ns string, ready RouterReady, opts ...discovery.Option) bool {
 	if d.discovery == nil { // 如果发现服务为空，直接返回成功
 		return true
 	}
@@ -320,7 +437,7 @@ func (d *discover) Bootstrap(ctx context.Context, topic string, ready RouterRead
 		}
 
 		// 如果未准备好，发现更多对等节点
-		disc := &discoverReq{topic, opts, make(chan struct{}, 1)} // 创建一个新的发现请求
+		disc := &discoverReq{topic, ns, opts, make(chan struct{}, 1)} // 创建一个新的发现请求
 		select {
 		case d.discoverQ <- disc: // 发送发现请求到发现请求通道
 		case <-d.p.ctx.Done(): // 如果上下文被取消，返回false
@@ -351,13 +468,13 @@ func (d *discover) Bootstrap(ctx context.Context, topic string, ready RouterRead
 // handleDiscovery 处理发现过程
 // 参数:
 //   - ctx: 上下文
-//   - topic: 主题
+//   - ns: 主题在发现服务中使用的命名空间
 //   - opts: 发现选项
-func (d *discover) handleDiscovery(ctx context.Context, topic string, opts []discovery.Option) {
+func (d *discover) handleDiscovery(ctx context.Context, ns string, opts []discovery.Option) {
 	discoverCtx, cancel := context.WithTimeout(ctx, time.Second*90) // 创建带有10秒超时的上下文
 	defer cancel()                                                  // 函数结束时取消上下文
 
-	peerCh, err := d.discovery.FindPeers(discoverCtx, topic, opts...) // 使用发现服务查找对应主题的对等节点
+	peerCh, err := d.discovery.FindPeers(discoverCtx, ns, opts...) // 使用发现服务查找对应主题的对等节点
 	if err != nil {
 		logger.Debugf("发现对等节点失败: %v", err) // 记录发现错误
 		return
@@ -369,6 +486,7 @@ func (d *discover) handleDiscovery(ctx context.Context, topic string, opts []dis
 // discoverReq 表示发现请求
 type discoverReq struct {
 	topic string             // topic 表示发现请求的主题
+	ns    string             // ns 表示主题在发现服务中使用的命名空间
 	opts  []discovery.Option // opts 表示发现请求的选项列表
 	done  chan struct{}      // done 是一个信号通道，用于通知发现请求完成
 }
@@ -418,6 +536,33 @@ func WithDiscoveryOpts(opts ...discovery.Option) DiscoverOpt {
 	}
 }
 
+// WithDiscoveryBootstrap 配置已加入主题的自动引导：每隔 interval 检查一次每个已加入主题的对等节点数，
+// 低于 minPeers 时通过发现服务查找对等节点，同一主题的连续查找之间按 backoff 退避策略等待。
+// 订阅或中继的主题始终会被广告，并在广告过期前重新广告。
+// 参数:
+//   - minPeers: 主题的最少对等节点数，为 0 时使用路由器的默认值（例如 gossipsub 的 Dlo）
+//   - interval: 检查主题对等节点数的间隔
+//   - backoff: 查找退避策略，为 nil 时使用 WithDiscoveryBackoff 的策略，两者都未设置时每次检查都查找
+//
+// 返回值:
+//   - DiscoverOpt: 配置发现选项的函数，用于设置发现配置
+func WithDiscoveryBootstrap(minPeers int, interval time.Duration, backoff BackoffStrategy) DiscoverOpt {
+	return func(d *discoverOptions) error {
+		if minPeers < 0 {
+			logger.Warnf("最少对等节点数不能为负数")
+			return fmt.Errorf("最少对等节点数不能为负数")
+		}
+		if interval <= 0 {
+			logger.Warnf("发现检查间隔必须为正数")
+			return fmt.Errorf("发现检查间隔必须为正数")
+		}
+		d.minPeers = minPeers
+		d.pollInterval = interval
+		d.backoff = backoff
+		return nil
+	}
+}
+
 // BackoffConnectorFactory 创建一个附加到给定主机的 BackoffConnector
 type BackoffConnectorFactory func(host host.Host) (*discimpl.BackoffConnector, error)
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -304,6 +305,128 @@ func TestGossipSubDiscoveryAfterBootstrap(t *testing.T) {
 	}
 }
 
+// countingDiscovery 统计 FindPeers 调用次数的发现客户端
+type countingDiscovery struct {
+	mockDiscoveryClient
+	finds atomic.Int32
+}
+
+// FindPeers 查找对等节点并计数
+func (d *countingDiscovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	d.finds.Add(1)
+	return d.mockDiscoveryClient.FindPeers(ctx, ns, opts...)
+}
+
+// TestDiscoveryBootstrap 测试已加入主题的对等节点不足时按退避策略查找对等节点，充足后停止查找
+func TestDiscoveryBootstrap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topic = "bootstrap"
+	server := newDiscoveryServer()
+	discOpts := []discovery.Option{discovery.Limit(10), discovery.TTL(time.Minute)}
+	hosts := getDefaultHosts(t, 2)
+
+	disc := &countingDiscovery{mockDiscoveryClient: mockDiscoveryClient{hosts[0], server}}
+	ps0 := getPubsub(ctx, hosts[0], WithDiscovery(disc, WithDiscoveryOpts(discOpts...),
+		WithDiscoveryBootstrap(1, 50*time.Millisecond, &ExponentialBackoff{Min: 300 * time.Millisecond, Max: 300 * time.Millisecond})))
+	if _, err := ps0.Join(topic); err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有其他对等节点时按退避间隔而不是检查间隔查找
+	time.Sleep(time.Second)
+	if n := disc.finds.Load(); n < 2 || n > 5 {
+		t.Fatalf("expected finds to be spaced by the backoff, got %d finds", n)
+	}
+
+	// 另一个节点订阅并广告主题后，自动连接并停止查找
+	ps1 := getPubsub(ctx, hosts[1], WithDiscovery(&mockDiscoveryClient{hosts[1], server}, WithDiscoveryOpts(discOpts...)))
+	topic1, err := ps1.Join(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic1.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ps0.ListPeers(topic)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the topic to be bootstrapped through discovery")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond)
+	n := disc.finds.Load()
+	time.Sleep(time.Second)
+	if m := disc.finds.Load(); m != n {
+		t.Fatalf("expected no finds once the topic has enough peers, got %d more", m-n)
+	}
+
+	if _, err := NewFloodSub(ctx, hosts[0], WithDiscovery(disc, WithDiscoveryBootstrap(1, 0, nil))); err == nil {
+		t.Fatal("expected error for non-positive interval")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithDiscovery(disc, WithDiscoveryBootstrap(-1, time.Second, nil))); err == nil {
+		t.Fatal("expected error for negative minimum peers")
+	}
+}
+
+// TestTopicDiscovery 测试主题可以禁用发现或使用自定义的发现命名空间
+func TestTopicDiscovery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := newDiscoveryServer()
+	discOpts := []discovery.Option{discovery.Limit(10), discovery.TTL(time.Minute)}
+	hosts := getDefaultHosts(t, 2)
+
+	var psubs []*PubSub
+	var hashed []*Topic
+	for _, h := range hosts {
+		ps := getPubsub(ctx, h, WithDiscovery(&mockDiscoveryClient{h, server}, WithDiscoveryOpts(discOpts...)))
+		secret, err := ps.Join("secret", WithTopicDiscovery(false, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := secret.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topic, err := ps.Join("hashed", WithTopicDiscovery(true, "c2VjcmV0"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+		hashed = append(hashed, topic)
+	}
+
+	// 使用自定义命名空间的主题通过发现连接对等节点
+	for _, topic := range hashed {
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(psubs[0].ListPeers("hashed")) == 0 || len(psubs[1].ListPeers("hashed")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the peers to discover each other through the custom namespace")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	for _, h := range hosts {
+		if !server.hasPeerRecord("floodsub:c2VjcmV0", h.ID()) {
+			t.Fatalf("expected host %s to be advertised under the custom namespace", h.ID())
+		}
+		if server.hasPeerRecord("floodsub:hashed", h.ID()) {
+			t.Fatal("expected the topic name not to be advertised")
+		}
+		if server.hasPeerRecord("floodsub:secret", h.ID()) {
+			t.Fatal("expected the topic with discovery disabled not to be advertised")
+		}
+	}
+}
+
 // waitUntilGossipsubMeshCount 等待直到 gossipsub 的 mesh 达到指定的计数
 func waitUntilGossipsubMeshCount(ps *PubSub, topic string, count int) {
 	done := false
@@ -319,3 +442,25 @@ func waitUntilGossipsubMeshCount(ps *PubSub, topic string, count int) {
 		}
 	}
 }
+
+func TestDiscoveryBackoffStrategy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server := newDiscoveryServer()
+	hosts := getDefaultHosts(t, 1)
+
+	// 没有通过 WithDiscoveryBootstrap 指定退避时按 WithDiscoveryBackoff 的策略查找
+	disc := &countingDiscovery{mockDiscoveryClient: mockDiscoveryClient{hosts[0], server}}
+	ps := getPubsub(ctx, hosts[0],
+		WithDiscovery(disc, WithDiscoveryBootstrap(1, 50*time.Millisecond, nil)),
+		WithDiscoveryBackoff(&ExponentialBackoff{Min: 300 * time.Millisecond, Max: 300 * time.Millisecond}))
+	if _, err := ps.Join("backoff"); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(time.Second)
+	if n := disc.finds.Load(); n < 2 || n > 5 {
+		t.Fatalf("expected finds to be spaced by the backoff strategy, got %d finds", n)
+	}
+}
@@ -0,0 +1,241 @@
+// 作用：主题排空。
+// 功能：支持滚动重启时协调地离开主题：停止本地发布，在宽限期内继续承担转发职责，
+// 分批向网格对等节点发送携带原因的 PRUNE，最后离开主题。
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// PruneReasonDraining 是排空主题时 PRUNE 消息中携带的原因
+const PruneReasonDraining = "draining"
+
+var (
+	// TopicDrainGracePeriod 是排空主题的默认宽限期；如果上下文的截止时间更早，则以截止时间为准
+	TopicDrainGracePeriod = 30 * time.Second
+
+	// ErrTopicDraining 表示主题正在排空，不再接受本地发布
+	ErrTopicDraining = errors.New("主题正在排空，不再接受发布")
+)
+
+// Drain 协调地离开主题，用于滚动重启。
+// 调用后主题立即停止接受本地发布，但在宽限期内继续转发消息；
+// 同时在宽限期内错开时间逐个向网格对等节点发送原因为 PruneReasonDraining 的 PRUNE，
+// 使其有时间寻找替代节点。宽限期结束后取消主题的所有订阅和中继并关闭主题。
+// 如果上下文提前取消，剩余的网格对等节点会被立即 PRUNE。
+// 参数:
+//   - ctx: 上下文，其截止时间会缩短宽限期
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) Drain(ctx context.Context) error {
+	t.mux.Lock()
+	if t.closed {
+		t.mux.Unlock()
+		return ErrTopicClosed
+	}
+	if t.draining {
+		t.mux.Unlock()
+		return ErrTopicDraining
+	}
+	t.draining = true
+	t.mux.Unlock()
+
+	grace := TopicDrainGracePeriod
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline); d < grace {
+			grace = d
+		}
+	}
+
+	peers, err := t.p.startDrain(t.topic)
+	if err != nil {
+		t.abortDrain()
+		return err
+	}
+
+	// 将 PRUNE 均匀地分布在宽限期内，最后一个 PRUNE 之后仍保留一个间隔用于转发
+	interval := grace / time.Duration(len(peers)+1)
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for i, pid := range peers {
+		select {
+		case <-timer.C:
+			timer.Reset(interval)
+		case <-ctx.Done():
+			t.p.drainPeers(t.topic, peers[i:])
+			return t.finishDrain()
+		case <-t.p.ctx.Done():
+			t.abortDrain()
+			return t.p.ctx.Err()
+		}
+		t.p.drainPeers(t.topic, []peer.ID{pid})
+	}
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	case <-t.p.ctx.Done():
+		t.abortDrain()
+		return t.p.ctx.Err()
+	}
+
+	return t.finishDrain()
+}
+
+// finishDrain 取消主题的所有订阅和中继并关闭主题，失败时恢复主题的排空状态
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) finishDrain() error {
+	if err := t.p.leaveTopic(t.topic); err != nil {
+		t.abortDrain()
+		return err
+	}
+	if err := t.Close(); err != nil {
+		t.abortDrain() // 主题仍然打开，恢复接受发布
+		return fmt.Errorf("排空后关闭主题失败: %w", err)
+	}
+	return nil
+}
+
+// abortDrain 在排空失败时清除主题和路由器中的排空状态，使主题恢复接受发布并可以再次排空
+func (t *Topic) abortDrain() {
+	t.mux.Lock()
+	t.draining = false
+	t.mux.Unlock()
+
+	reset := func() {
+		if gs, ok := t.p.rt.(*GossipSubRouter); ok {
+			delete(gs.draining, t.topic)
+		}
+	}
+
+	select {
+	case t.p.eval <- reset:
+	case <-t.p.ctx.Done():
+	}
+}
+
+// leaveTopic 取消主题的所有订阅和中继，从而宣布不再订阅并离开主题
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) leaveTopic(topic string) error {
+	done := make(chan struct{})
+	leave := func() {
+		defer close(done)
+		p.handleLeaveTopic(topic)
+	}
+
+	select {
+	case p.eval <- leave:
+		<-done
+		return nil
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// handleLeaveTopic 取消主题的所有订阅和中继。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+func (p *PubSub) handleLeaveTopic(topic string) {
+	for sub := range p.mySubs[topic] {
+		p.handleRemoveSubscription(sub)
+	}
+	if p.myRelays[topic] > 0 {
+		p.myRelays[topic] = 1 // 一次性移除所有中继引用
+		p.handleRemoveRelay(topic)
+	}
+}
+
+// startDrain 将主题标记为排空状态，并返回当前的网格对等节点。
+// 只有 gossipsub 路由器维护网格，其他路由器返回空列表。
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - []peer.ID: 网格对等节点列表
+//   - error: 错误信息，如果有的话
+func (p *PubSub) startDrain(topic string) ([]peer.ID, error) {
+	out := make(chan []peer.ID, 1)
+	start := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- nil
+			return
+		}
+		gs.draining[topic] = struct{}{}
+		peers := make([]peer.ID, 0, len(gs.mesh[topic]))
+		for pid := range gs.mesh[topic] {
+			peers = append(peers, pid)
+		}
+		out <- peers
+	}
+
+	select {
+	case p.eval <- start:
+		return <-out, nil
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
+// drainPeers 向给定的网格对等节点发送排空 PRUNE
+// 参数:
+//   - topic: 主题
+//   - peers: 对等节点列表
+func (p *PubSub) drainPeers(topic string, peers []peer.ID) {
+	prune := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			return
+		}
+		for _, pid := range peers {
+			gs.drainPeer(pid, topic)
+		}
+	}
+
+	select {
+	case p.eval <- prune:
+	case <-p.ctx.Done():
+	}
+}
+
+// drainPeer 将对等节点移出排空主题的网格，并发送携带原因的 PRUNE。
+// 只从 processLoop 调用。
+// 参数:
+//   - p: 对等节点 ID
+//   - topic: 主题
+func (gs *GossipSubRouter) drainPeer(p peer.ID, topic string) {
+	peers, ok := gs.mesh[topic]
+	if !ok {
+		return
+	}
+	if _, inMesh := peers[p]; !inMesh {
+		return // 对等节点可能已经离开或 PRUNE 了我们
+	}
+
+	logger.Debugf("排空: 从网格中移除对等节点 %s 到主题 %s", p, topic)
+	gs.tracer.Prune(p, topic)
+	gs.p.notifyTopicEvent(topic, MeshPruned, p)
+	delete(peers, p)
+
+	prune := gs.makePrune(p, topic, gs.doPX, true)
+	prune.Reason = PruneReasonDraining
+	out := rpcWithControl(nil, nil, nil, nil, []*pb.ControlPrune{prune})
+	gs.sendRPC(p, out)
+
+	gs.addBackoff(p, topic, true)
+}
@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestTopicDrain 测试排空主题时停止发布、向网格对等节点发送 PRUNE 并最终离开主题
+func TestTopicDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(2 * time.Second)
+
+	drainCtx, drainCancel := context.WithTimeout(ctx, 2*time.Second)
+	defer drainCancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- topics[0].Drain(drainCtx)
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if err := topics[0].Publish(ctx, []byte("late")); err != ErrTopicDraining {
+		t.Fatalf("expected ErrTopicDraining, got %v", err)
+	}
+	if err := topics[0].Drain(ctx); err != ErrTopicDraining {
+		t.Fatalf("expected ErrTopicDraining for concurrent drain, got %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := topics[0].Publish(ctx, []byte("closed")); err != ErrTopicClosed {
+		t.Fatalf("expected ErrTopicClosed after drain, got %v", err)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	for i := 1; i < len(psubs); i++ {
+		// 对等节点应当收到了 PRUNE 并遵守回退
+		if psubs[i].BackoffUntil("foobar", hosts[0].ID()).IsZero() {
+			t.Fatalf("expected peer %d to back off from draining node", i)
+		}
+		for _, pid := range psubs[i].ListPeers("foobar") {
+			if pid == hosts[0].ID() {
+				t.Fatalf("expected peer %d to see drained node leave the topic", i)
+			}
+		}
+	}
+}
+
+// TestTopicDrainCloseError 测试排空后无法关闭主题时返回错误并恢复主题的排空状态
+func TestTopicDrainCloseError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 活跃的事件处理程序使主题无法关闭
+	evts, err := topic.EventHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer evts.Cancel()
+
+	drainCtx, drainCancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer drainCancel()
+	if err := topic.Drain(drainCtx); err == nil {
+		t.Fatal("expected an error when the drained topic cannot be closed")
+	}
+
+	if err := topic.Publish(ctx, []byte("after")); err != nil {
+		t.Fatalf("expected the topic to accept publishes again, got %v", err)
+	}
+	draining := make(chan bool, 1)
+	ps.eval <- func() {
+		_, ok := ps.rt.(*GossipSubRouter).draining["foobar"]
+		draining <- ok
+	}
+	if <-draining {
+		t.Fatal("expected the router draining state to be cleared")
+	}
+}
@@ -0,0 +1,222 @@
+// 作用：重复交付统计。
+// 功能：按主题统计首次交付和重复收到的消息数、冗余比，以及携带发布时间的消息从发布到首次交付的延迟分布，
+// 通过 Topic.Stats 提供给运维人员，用于判断是否需要减小网格度数或启用 IDONTWANT，无需自定义追踪器。
+
+package pubsub
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// dupStatsSamples 是每个主题保留的最近延迟样本数
+const dupStatsSamples = 1024
+
+// TopicStats 是主题的重复交付统计
+type TopicStats struct {
+	Delivered  uint64 // 首次交付的消息数
+	Duplicates uint64 // 重复收到的消息数
+	// RedundancyRatio 是每条交付的消息平均收到的副本数，即 (Delivered+Duplicates)/Delivered；没有交付时为 0
+	RedundancyRatio float64
+	// FirstDeliveryLatency 是从发布时间到首次交付的延迟分布，只统计其他节点发布的携带发布时间的消息
+	FirstDeliveryLatency LatencyStats
+}
+
+// LatencyStats 是最近延迟样本的分布
+type LatencyStats struct {
+	Count int           // 样本数
+	Min   time.Duration // 最小值
+	Max   time.Duration // 最大值
+	Mean  time.Duration // 平均值
+	P50   time.Duration // 中位数
+	P90   time.Duration // 第 90 百分位数
+	P99   time.Duration // 第 99 百分位数
+}
+
+// topicDupStats 是一个主题的统计计数
+type topicDupStats struct {
+	delivered  uint64
+	duplicates uint64
+	latencies  []time.Duration // 最近的延迟样本，环形缓冲区
+	next       int             // 下一个样本写入的位置
+}
+
+// dupStats 是按主题统计重复交付的内部追踪器
+type dupStats struct {
+	sync.Mutex
+	self   peer.ID
+	topics map[string]*topicDupStats
+}
+
+// newDupStats 创建重复交付统计追踪器
+// 参数:
+//   - self: 本节点 ID，本地发布的消息不统计延迟
+//
+// 返回值:
+//   - *dupStats: 统计追踪器
+func newDupStats(self peer.ID) *dupStats {
+	return &dupStats{
+		self:   self,
+		topics: make(map[string]*topicDupStats),
+	}
+}
+
+// topic 返回主题的统计计数，不存在时创建。调用方必须持有锁
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - *topicDupStats: 统计计数
+func (ds *dupStats) topic(topic string) *topicDupStats {
+	ts, ok := ds.topics[topic]
+	if !ok {
+		ts = &topicDupStats{}
+		ds.topics[topic] = ts
+	}
+	return ts
+}
+
+// stats 返回主题的统计
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - TopicStats: 主题的统计
+func (ds *dupStats) stats(topic string) TopicStats {
+	ds.Lock()
+	defer ds.Unlock()
+
+	ts, ok := ds.topics[topic]
+	if !ok {
+		return TopicStats{}
+	}
+	out := TopicStats{
+		Delivered:            ts.delivered,
+		Duplicates:           ts.duplicates,
+		FirstDeliveryLatency: latencyStats(ts.latencies),
+	}
+	if ts.delivered > 0 {
+		out.RedundancyRatio = float64(ts.delivered+ts.duplicates) / float64(ts.delivered)
+	}
+	return out
+}
+
+// latencyStats 计算延迟样本的分布
+// 参数:
+//   - samples: 延迟样本
+//
+// 返回值:
+//   - LatencyStats: 延迟分布
+func latencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	pct := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1))]
+	}
+	return LatencyStats{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  sum / time.Duration(len(sorted)),
+		P50:   pct(0.5),
+		P90:   pct(0.9),
+		P99:   pct(0.99),
+	}
+}
+
+// Stats 返回主题的重复交付统计，统计从 PubSub 创建开始累计
+// 返回值:
+//   - TopicStats: 主题的统计
+func (t *Topic) Stats() TopicStats {
+	return t.p.dupStats.stats(t.topic)
+}
+
+// DeliverMessage 实现 RawTracer 接口
+func (ds *dupStats) DeliverMessage(msg *Message) {
+	ds.Lock()
+	defer ds.Unlock()
+
+	ts := ds.topic(msg.GetTopic())
+	ts.delivered++
+
+	if msg.ReceivedFrom == ds.self {
+		return
+	}
+	published, ok := msg.Timestamp()
+	if !ok {
+		return
+	}
+	latency := time.Since(published)
+	if latency < 0 {
+		latency = 0 // 时钟偏差
+	}
+	if len(ts.latencies) < dupStatsSamples {
+		ts.latencies = append(ts.latencies, latency)
+		return
+	}
+	ts.latencies[ts.next] = latency
+	ts.next = (ts.next + 1) % dupStatsSamples
+}
+
+// DuplicateMessage 实现 RawTracer 接口
+func (ds *dupStats) DuplicateMessage(msg *Message) {
+	ds.Lock()
+	defer ds.Unlock()
+	ds.topic(msg.GetTopic()).duplicates++
+}
+
+// AddPeer 实现 RawTracer 接口
+func (ds *dupStats) AddPeer(p peer.ID, proto protocol.ID) {}
+
+// RemovePeer 实现 RawTracer 接口
+func (ds *dupStats) RemovePeer(p peer.ID) {}
+
+// Join 实现 RawTracer 接口
+func (ds *dupStats) Join(topic string) {}
+
+// Leave 实现 RawTracer 接口，离开主题时丢弃其统计
+func (ds *dupStats) Leave(topic string) {
+	ds.Lock()
+	defer ds.Unlock()
+	delete(ds.topics, topic)
+}
+
+// Graft 实现 RawTracer 接口
+func (ds *dupStats) Graft(p peer.ID, topic string) {}
+
+// Prune 实现 RawTracer 接口
+func (ds *dupStats) Prune(p peer.ID, topic string) {}
+
+// ValidateMessage 实现 RawTracer 接口
+func (ds *dupStats) ValidateMessage(msg *Message) {}
+
+// RejectMessage 实现 RawTracer 接口
+func (ds *dupStats) RejectMessage(msg *Message, reason string) {}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (ds *dupStats) ThrottlePeer(p peer.ID) {}
+
+// RecvRPC 实现 RawTracer 接口
+func (ds *dupStats) RecvRPC(rpc *RPC) {}
+
+// SendRPC 实现 RawTracer 接口
+func (ds *dupStats) SendRPC(rpc *RPC, p peer.ID) {}
+
+// DropRPC 实现 RawTracer 接口
+func (ds *dupStats) DropRPC(rpc *RPC, p peer.ID) {}
+
+// UndeliverableMessage 实现 RawTracer 接口
+func (ds *dupStats) UndeliverableMessage(msg *Message) {}
@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestTopicStats 测试主题统计首次交付、重复和首次交付延迟
+func TestTopicStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getPubsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	topics := getTopics(psubs, "foo")
+	var subs []*Subscription
+	for _, topic := range topics[1:] {
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	time.Sleep(time.Second)
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		msg := []byte(fmt.Sprintf("msg-%d", i))
+		if err := topics[0].Publish(ctx, msg, WithTimestamp()); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs {
+			assertReceive(t, sub, msg)
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	var duplicates uint64
+	for _, topic := range topics[1:] {
+		stats := topic.Stats()
+		if stats.Delivered != count {
+			t.Fatalf("expected %d deliveries, got %d", count, stats.Delivered)
+		}
+		if stats.FirstDeliveryLatency.Count != count {
+			t.Fatalf("expected %d latency samples, got %d", count, stats.FirstDeliveryLatency.Count)
+		}
+		if stats.RedundancyRatio < 1 {
+			t.Fatalf("expected a redundancy ratio of at least 1, got %f", stats.RedundancyRatio)
+		}
+		duplicates += stats.Duplicates
+	}
+	if duplicates == 0 {
+		t.Fatal("expected the full mesh to produce duplicates")
+	}
+
+	// 本地发布的消息不统计延迟
+	if stats := topics[0].Stats(); stats.FirstDeliveryLatency.Count != 0 {
+		t.Fatalf("expected no latency samples for local messages, got %d", stats.FirstDeliveryLatency.Count)
+	}
+
+	// 离开主题后丢弃其统计
+	subs[0].Cancel()
+	time.Sleep(100 * time.Millisecond)
+	if stats := topics[1].Stats(); stats.Delivered != 0 {
+		t.Fatalf("expected stats to be dropped after leaving, got %d deliveries", stats.Delivered)
+	}
+}
+
+// TestLatencyStats 测试延迟分布的计算
+func TestLatencyStats(t *testing.T) {
+	var samples []time.Duration
+	for i := 100; i >= 1; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	stats := latencyStats(samples)
+	if stats.Count != 100 || stats.Min != time.Millisecond || stats.Max != 100*time.Millisecond {
+		t.Fatalf("unexpected bounds: %+v", stats)
+	}
+	if stats.P50 != 50*time.Millisecond || stats.P90 != 90*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Fatalf("unexpected percentiles: %+v", stats)
+	}
+	if stats.Mean != 50500*time.Microsecond {
+		t.Fatalf("unexpected mean: %s", stats.Mean)
+	}
+}
@@ -0,0 +1,101 @@
+// 作用：重复消息容忍度。
+// 功能：为有意高冗余的主题（例如告警）配置每条消息允许的重复次数，
+// 在容忍度之内的重复交付不会被对等节点门控计为重复，迟到的网格交付也仍然计入评分。
+
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// duplicateTolerance 按主题记录每条消息的重复次数
+type duplicateTolerance struct {
+	sync.Mutex
+
+	limits  map[string]int // 每个主题每条消息容忍的重复次数
+	cur     map[string]int // 当前周期内每条消息的重复次数
+	prev    map[string]int // 上一周期内每条消息的重复次数
+	rotated time.Time      // 上次轮换的时间
+}
+
+// WithDuplicateTolerance 为主题设置每条消息容忍的重复次数。
+// 每条消息的前 n 次重复交付被视为预期内的冗余：对等节点门控不会将其计为重复，
+// 即使超出 MeshMessageDeliveriesWindow 也会计入网格消息交付评分。
+// 参数:
+//   - topic: 主题
+//   - n: 每条消息容忍的重复次数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithDuplicateTolerance(topic string, n int) Option {
+	return func(p *PubSub) error {
+		if topic == "" {
+			logger.Warnf("主题不能为空")
+			return fmt.Errorf("主题不能为空")
+		}
+		if n <= 0 {
+			logger.Warnf("重复容忍次数必须大于 0")
+			return fmt.Errorf("重复容忍次数必须大于 0")
+		}
+
+		if p.dupTolerance == nil {
+			p.dupTolerance = &duplicateTolerance{
+				limits: make(map[string]int),
+				cur:    make(map[string]int),
+				prev:   make(map[string]int),
+			}
+		}
+		p.dupTolerance.limits[topic] = n
+		return nil
+	}
+}
+
+// tolerate 记录一次重复交付，并返回它是否在主题的容忍度之内。
+// 计数每隔 ttl 轮换一次，因此每条消息的计数最多保留两个周期。nil 容忍度总是返回 false。
+// 参数:
+//   - topic: 主题
+//   - id: 消息 ID
+//   - ttl: 计数的保留周期
+//
+// 返回值:
+//   - bool: 是否在容忍度之内
+func (d *duplicateTolerance) tolerate(topic, id string, ttl time.Duration) bool {
+	if d == nil {
+		return false
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	limit, ok := d.limits[topic]
+	if !ok {
+		return false
+	}
+
+	now := time.Now()
+	if now.Sub(d.rotated) > ttl {
+		d.prev = d.cur
+		d.cur = make(map[string]int)
+		d.rotated = now
+	}
+
+	count, ok := d.cur[id]
+	if !ok {
+		count = d.prev[id]
+	}
+	count++
+	d.cur[id] = count
+
+	return count <= limit
+}
+
+// traceDuplicate 标记重复消息是否在容忍度之内，并通知追踪器
+// 参数:
+//   - id: 消息 ID
+//   - msg: 重复的消息
+func (p *PubSub) traceDuplicate(id string, msg *Message) {
+	msg.toleratedDuplicate = p.dupTolerance.tolerate(msg.GetTopic(), id, p.seenMsgTTL)
+	p.tracer.DuplicateMessage(msg)
+}
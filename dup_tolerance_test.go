@@ -0,0 +1,80 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestDuplicateTolerance 测试按主题的重复消息计数与周期轮换
+func TestDuplicateTolerance(t *testing.T) {
+	p := &PubSub{}
+	if err := WithDuplicateTolerance("alerts", 2)(p); err != nil {
+		t.Fatal(err)
+	}
+	if err := WithDuplicateTolerance("alerts", 0)(p); err == nil {
+		t.Fatal("expected error for zero tolerance")
+	}
+	if err := WithDuplicateTolerance("", 1)(p); err == nil {
+		t.Fatal("expected error for empty topic")
+	}
+
+	d := p.dupTolerance
+	ttl := time.Hour
+
+	// 前两次重复在容忍度之内，第三次超出
+	for i := 0; i < 2; i++ {
+		if !d.tolerate("alerts", "m1", ttl) {
+			t.Fatalf("expected duplicate %d to be tolerated", i+1)
+		}
+	}
+	if d.tolerate("alerts", "m1", ttl) {
+		t.Fatal("expected third duplicate not to be tolerated")
+	}
+
+	// 其他消息和其他主题独立计数
+	if !d.tolerate("alerts", "m2", ttl) {
+		t.Fatal("expected duplicate of another message to be tolerated")
+	}
+	if d.tolerate("chat", "m3", ttl) {
+		t.Fatal("expected no tolerance for unconfigured topic")
+	}
+
+	// nil 容忍度总是返回 false
+	var none *duplicateTolerance
+	if none.tolerate("alerts", "m1", ttl) {
+		t.Fatal("expected nil tolerance to reject")
+	}
+
+	// 计数在两个周期之后被清除
+	for i := 0; i < 2; i++ {
+		time.Sleep(time.Millisecond)
+		d.tolerate("alerts", "m9", 0)
+	}
+	if !d.tolerate("alerts", "m1", ttl) {
+		t.Fatal("expected counts to expire after rotation")
+	}
+}
+
+// TestDuplicateTolerancePeerGater 测试容忍度之内的重复不被对等节点门控计数
+func TestDuplicateTolerancePeerGater(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	peerA := peer.ID("A")
+	pg := newPeerGater(ctx, nil, NewPeerGaterParams(.1, .9, .999))
+	pg.getIP = func(p peer.ID) string { return "1.2.3.4" }
+	pg.AddPeer(peerA, "")
+
+	pg.DuplicateMessage(&Message{ReceivedFrom: peerA, toleratedDuplicate: true})
+	if st := pg.getPeerStats(peerA); st.duplicate != 0 {
+		t.Fatalf("expected tolerated duplicate not to be counted, got %f", st.duplicate)
+	}
+
+	pg.DuplicateMessage(&Message{ReceivedFrom: peerA})
+	if st := pg.getPeerStats(peerA); st.duplicate != 1 {
+		t.Fatalf("expected duplicate to be counted, got %f", st.duplicate)
+	}
+}
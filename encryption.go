@@ -0,0 +1,240 @@
+// 作用：私有主题的负载加密。
+// 功能：为主题提供可选的对称加密封装：发布前用当前密钥加密负载，投递时按封装中的密钥 ID 解密，
+// 从而支持密钥轮换；转发节点只看到密文，无需持有密钥。
+
+package pubsub
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// encryptionVersion 是加密封装的格式版本
+const encryptionVersion = 1
+
+// ErrNotEncrypted 表示负载不是有效的加密封装
+var ErrNotEncrypted = errors.New("负载不是有效的加密封装")
+
+// KeyProvider 为加密主题提供对称密钥。密钥长度必须为 16、24 或 32 字节（AES-128/192/256）。
+// 解密在事件循环中进行，Key 不能阻塞。
+type KeyProvider interface {
+	// CurrentKey 返回加密新消息使用的密钥及其 ID
+	CurrentKey(topic string) (keyID string, key []byte, err error)
+	// Key 根据密钥 ID 返回解密使用的密钥，用于解密在密钥轮换之前发布的消息
+	Key(topic string, keyID string) ([]byte, error)
+}
+
+// EnableEncryption 为主题启用负载加密；传入 nil 则禁用。
+// 启用后发布的负载会被加密，订阅者收到的消息会被解密；无法解密的消息不会投递给订阅者。
+// 所有成员必须使用相同的密钥提供者配置。主题关闭后密钥提供者会被自动移除。
+// 参数:
+//   - keyProvider: 密钥提供者
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) EnableEncryption(keyProvider KeyProvider) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	t.p.setKeyProvider(t.topic, keyProvider)
+	return nil
+}
+
+// setKeyProvider 设置主题的密钥提供者
+// 参数:
+//   - topic: 主题
+//   - keyProvider: 密钥提供者，为 nil 时移除
+func (p *PubSub) setKeyProvider(topic string, keyProvider KeyProvider) {
+	p.keyProvidersMx.Lock()
+	defer p.keyProvidersMx.Unlock()
+
+	if keyProvider == nil {
+		delete(p.keyProviders, topic)
+		return
+	}
+	p.keyProviders[topic] = keyProvider
+}
+
+// getKeyProvider 获取主题的密钥提供者
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - KeyProvider: 密钥提供者，不存在时为 nil
+func (p *PubSub) getKeyProvider(topic string) KeyProvider {
+	p.keyProvidersMx.RLock()
+	defer p.keyProvidersMx.RUnlock()
+
+	return p.keyProviders[topic]
+}
+
+// encryptPayload 如果主题启用了加密，则用当前密钥加密负载
+// 参数:
+//   - topic: 主题
+//   - data: 负载
+//
+// 返回值:
+//   - []byte: 加密后的负载，未启用加密时原样返回
+//   - error: 错误信息，如果有的话
+func (p *PubSub) encryptPayload(topic string, data []byte) ([]byte, error) {
+	kp := p.getKeyProvider(topic)
+	if kp == nil {
+		return data, nil
+	}
+
+	keyID, key, err := kp.CurrentKey(topic)
+	if err != nil {
+		logger.Warnf("获取主题 %s 的加密密钥失败: %s", topic, err)
+		return nil, fmt.Errorf("获取主题 %s 的加密密钥失败: %w", topic, err)
+	}
+	return sealPayload(topic, keyID, key, data)
+}
+
+// decryptMessage 如果主题启用了加密，则返回负载解密后的消息副本；原消息保持不变，以便继续转发密文。
+// 副本保留原消息的全部本地状态（例如回环投递、茎阶段和接收信息）。
+// 只从 processLoop 调用。
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - *Message: 可以投递的消息
+//   - bool: 消息是否可以投递
+func (p *PubSub) decryptMessage(msg *Message) (*Message, bool) {
+	topic := msg.GetTopic()
+	kp := p.getKeyProvider(topic)
+	if kp == nil {
+		return msg, true
+	}
+
+	keyID, err := payloadKeyID(msg.GetData())
+	if err != nil {
+		logger.Debugf("丢弃主题 %s 中无法解析的加密消息 %s: %s", topic, msg.ID, err)
+		return nil, false
+	}
+	key, err := kp.Key(topic, keyID)
+	if err != nil {
+		logger.Debugf("丢弃主题 %s 中密钥 %s 不可用的加密消息 %s: %s", topic, keyID, msg.ID, err)
+		return nil, false
+	}
+	data, err := openPayload(topic, key, msg.GetData())
+	if err != nil {
+		logger.Debugf("丢弃主题 %s 中无法解密的消息 %s: %s", topic, msg.ID, err)
+		return nil, false
+	}
+
+	pmsg := *msg.Message
+	pmsg.Data = data
+	out := *msg
+	out.Message = &pmsg
+	return &out, true
+}
+
+// sealPayload 用 AES-GCM 加密负载，并以主题作为附加数据。
+// 封装格式：版本（1 字节）| 密钥 ID 长度（uvarint）| 密钥 ID | nonce | 密文。
+// 参数:
+//   - topic: 主题
+//   - keyID: 密钥 ID
+//   - key: 密钥
+//   - data: 负载
+//
+// 返回值:
+//   - []byte: 加密封装
+//   - error: 错误信息，如果有的话
+func sealPayload(topic, keyID string, key, data []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 1+binary.MaxVarintLen64, 1+binary.MaxVarintLen64+len(keyID)+aead.NonceSize()+len(data)+aead.Overhead())
+	header[0] = encryptionVersion
+	n := binary.PutUvarint(header[1:], uint64(len(keyID)))
+	out := append(header[:1+n], keyID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, []byte(topic)), nil
+}
+
+// payloadKeyID 解析加密封装中的密钥 ID
+// 参数:
+//   - payload: 加密封装
+//
+// 返回值:
+//   - string: 密钥 ID
+//   - error: 错误信息，如果有的话
+func payloadKeyID(payload []byte) (string, error) {
+	keyID, _, err := splitPayload(payload)
+	return keyID, err
+}
+
+// openPayload 解密加密封装
+// 参数:
+//   - topic: 主题
+//   - key: 密钥
+//   - payload: 加密封装
+//
+// 返回值:
+//   - []byte: 负载
+//   - error: 错误信息，如果有的话
+func openPayload(topic string, key, payload []byte) ([]byte, error) {
+	_, sealed, err := splitPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrNotEncrypted
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(topic))
+}
+
+// splitPayload 将加密封装拆分为密钥 ID 和 nonce 加密文
+// 参数:
+//   - payload: 加密封装
+//
+// 返回值:
+//   - string: 密钥 ID
+//   - []byte: nonce 和密文
+//   - error: 错误信息，如果有的话
+func splitPayload(payload []byte) (string, []byte, error) {
+	if len(payload) < 2 || payload[0] != encryptionVersion {
+		return "", nil, ErrNotEncrypted
+	}
+	l, n := binary.Uvarint(payload[1:])
+	if n <= 0 || l > uint64(len(payload)-1-n) {
+		return "", nil, ErrNotEncrypted
+	}
+	start := 1 + n
+	end := start + int(l)
+	return string(payload[start:end]), payload[end:], nil
+}
+
+// newAEAD 用密钥创建 AES-GCM
+// 参数:
+//   - key: 密钥
+//
+// 返回值:
+//   - cipher.AEAD: AES-GCM
+//   - error: 错误信息，如果有的话
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
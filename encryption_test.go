@@ -0,0 +1,186 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// staticKeyProvider 按密钥 ID 保存密钥的测试密钥提供者
+type staticKeyProvider struct {
+	mx      sync.Mutex
+	current string
+	keys    map[string][]byte
+}
+
+// CurrentKey 实现 KeyProvider 接口
+func (kp *staticKeyProvider) CurrentKey(topic string) (string, []byte, error) {
+	kp.mx.Lock()
+	defer kp.mx.Unlock()
+	return kp.current, kp.keys[kp.current], nil
+}
+
+// Key 实现 KeyProvider 接口
+func (kp *staticKeyProvider) Key(topic string, keyID string) ([]byte, error) {
+	kp.mx.Lock()
+	defer kp.mx.Unlock()
+	key, ok := kp.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", keyID)
+	}
+	return key, nil
+}
+
+func (kp *staticKeyProvider) rotate(keyID string, key []byte) {
+	kp.mx.Lock()
+	defer kp.mx.Unlock()
+	kp.current = keyID
+	kp.keys[keyID] = key
+}
+
+// TestPayloadEnvelope 测试加密封装的编解码
+func TestPayloadEnvelope(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	sealed, err := sealPayload("topic", "k1", key, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("hello")) {
+		t.Fatal("expected payload to be encrypted")
+	}
+
+	keyID, err := payloadKeyID(sealed)
+	if err != nil || keyID != "k1" {
+		t.Fatalf("expected key ID k1, got %q (%v)", keyID, err)
+	}
+	data, err := openPayload("topic", key, sealed)
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected decrypted payload, got %q (%v)", data, err)
+	}
+
+	// 主题作为附加数据，不能在其他主题中解密
+	if _, err := openPayload("other", key, sealed); err == nil {
+		t.Fatal("expected decryption to fail for another topic")
+	}
+	if _, err := openPayload("topic", bytes.Repeat([]byte{2}, 32), sealed); err == nil {
+		t.Fatal("expected decryption to fail with the wrong key")
+	}
+	if _, err := payloadKeyID([]byte("plain")); err != ErrNotEncrypted {
+		t.Fatalf("expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+// TestTopicEncryption 测试加密主题的发布、投递和密钥轮换
+func TestTopicEncryption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getPubsubs(ctx, hosts)
+
+	kp := &staticKeyProvider{current: "k1", keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+	topics := make([]*Topic, len(psubs))
+	subs := make([]*Subscription, len(psubs))
+	for i, ps := range psubs {
+		topic, err := ps.Join("private")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics[i] = topic
+		subs[i], err = topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 第三个节点只转发，不持有密钥
+	for _, topic := range topics[:2] {
+		if err := topic.EnableEncryption(kp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connect(t, hosts[0], hosts[2])
+	connect(t, hosts[2], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	next := func(sub *Subscription) []byte {
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer tcancel()
+		msg, err := sub.Next(tctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return msg.Data
+	}
+
+	if err := topics[0].Publish(ctx, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	if data := next(subs[1]); string(data) != "secret" {
+		t.Fatalf("expected decrypted payload, got %q", data)
+	}
+	if data := next(subs[2]); bytes.Contains(data, []byte("secret")) {
+		t.Fatal("expected relay without the key to see only ciphertext")
+	}
+
+	// 轮换密钥后，旧密钥加密的消息和新消息都能解密
+	kp.rotate("k2", bytes.Repeat([]byte{2}, 32))
+	if err := topics[0].Publish(ctx, []byte("rotated")); err != nil {
+		t.Fatal(err)
+	}
+	if data := next(subs[1]); string(data) != "rotated" {
+		t.Fatalf("expected decrypted payload after rotation, got %q", data)
+	}
+	next(subs[2])
+
+	// 无法解密的消息不会投递
+	if err := topics[2].Publish(ctx, []byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, []byte("after")); err != nil {
+		t.Fatal(err)
+	}
+	if data := next(subs[1]); string(data) != "after" {
+		t.Fatalf("expected undecryptable message to be dropped, got %q", data)
+	}
+
+	// 关闭主题后移除密钥提供者
+	subs[0].Cancel()
+	if err := topics[0].Close(); err != nil {
+		t.Fatal(err)
+	}
+	if psubs[0].getKeyProvider("private") != nil {
+		t.Fatal("expected key provider to be removed on close")
+	}
+}
+
+// TestTopicEncryptionLocalDelivery 测试开启加密后，选择投递给自己的本地发布仍然投递给本节点的订阅者
+func TestTopicEncryptionLocalDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	psubs := getPubsubs(ctx, hosts)
+
+	topic, err := psubs[0].Join("private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kp := &staticKeyProvider{current: "k1", keys: map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}}
+	if err := topic.EnableEncryption(kp); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 解密后的副本保留回环投递标记，本节点发布的消息仍然投递给自己的订阅者
+	if err := topic.Publish(ctx, []byte("secret"), WithDeliverToSelf(true)); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("secret"))
+}
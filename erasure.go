@@ -0,0 +1,322 @@
+// 作用：大消息的纠删码传播。
+// 功能：将大消息编码为 k 个数据分片和 m 个校验分片，分别作为普通消息在主题上发布；
+// gossipsub 路由器把每个分片只发给首跳对等节点中的一部分，不同分片走不同的对等节点，再由网格转发给其余节点。
+// 订阅者收到任意 k 个分片即可重组原始消息，其余分片被忽略，从而降低整块大消息在网格中重复传播的开销，
+// 并容忍部分分片丢失。
+
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+const (
+	// DefaultErasureReassemblyTimeout 是等待一条消息的足够分片的默认时间，超时后丢弃已收到的分片
+	DefaultErasureReassemblyTimeout = 30 * time.Second
+	// DefaultErasureMaxPending 是每个订阅同时重组的消息数上限
+	DefaultErasureMaxPending = 64
+)
+
+const (
+	erasureVersion    = 1  // 分片封装的格式版本
+	erasureDigestSize = 16 // 原始数据摘要的长度，同时用作消息标识
+	// erasureHeaderSize 是分片封装头部的长度: 版本、摘要、k、m、分片序号、原始长度
+	erasureHeaderSize = 1 + erasureDigestSize + 1 + 1 + 1 + 4
+)
+
+// ErasureTopic 以纠删码分片的形式在主题上发布和接收大消息，由 NewErasureTopic 创建
+type ErasureTopic struct {
+	t     *Topic
+	codec *rsCodec
+}
+
+// NewErasureTopic 创建纠删码主题，发布的消息被编码为 dataShards 个数据分片和 parityShards 个校验分片。
+// 订阅者按分片中携带的参数重组，因此发布者和订阅者的分片数可以不同。
+// 参数:
+//   - t: 主题句柄
+//   - dataShards: 数据分片数 k，重组需要的分片数
+//   - parityShards: 校验分片数 m，可以容忍丢失的分片数
+//
+// 返回值:
+//   - *ErasureTopic: 纠删码主题
+//   - error: 错误信息，如果有的话
+func NewErasureTopic(t *Topic, dataShards, parityShards int) (*ErasureTopic, error) {
+	if t == nil {
+		logger.Warnf("主题不能为空")
+		return nil, fmt.Errorf("主题不能为空")
+	}
+	if dataShards <= 0 || parityShards < 0 || dataShards+parityShards > 255 {
+		logger.Warnf("无效的分片数: %d 个数据分片, %d 个校验分片", dataShards, parityShards)
+		return nil, fmt.Errorf("无效的分片数: %d 个数据分片, %d 个校验分片", dataShards, parityShards)
+	}
+
+	codec, err := newRSCodec(dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+	return &ErasureTopic{t: t, codec: codec}, nil
+}
+
+// Publish 将数据编码为分片并逐个发布到主题。使用 gossipsub 路由器时，每个分片只发给一部分首跳对等节点，
+// 不同分片分散到不同的对等节点上。
+// 参数:
+//   - ctx: 上下文
+//   - data: 原始数据
+//   - opts: 发布每个分片使用的发布选项
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (et *ErasureTopic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error {
+	digest := sha256.Sum256(data)
+	for i, shard := range et.codec.encode(data) {
+		payload := make([]byte, erasureHeaderSize, erasureHeaderSize+len(shard))
+		payload[0] = erasureVersion
+		copy(payload[1:], digest[:erasureDigestSize])
+		payload[1+erasureDigestSize] = byte(et.codec.k)
+		payload[2+erasureDigestSize] = byte(et.codec.m)
+		payload[3+erasureDigestSize] = byte(i)
+		binary.BigEndian.PutUint32(payload[4+erasureDigestSize:], uint32(len(data)))
+		payload = append(payload, shard...)
+
+		shardOpts := append(opts[:len(opts):len(opts)], withErasureShard(i, et.codec.k+et.codec.m))
+		if err := et.t.Publish(ctx, payload, shardOpts...); err != nil {
+			logger.Warnf("发布主题 %s 的第 %d 个分片失败: %s", et.t.topic, i, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// erasureShard 是本地发布的纠删码分片在首跳对等节点中的位置
+type erasureShard struct {
+	index int // 分片序号
+	count int // 分片总数，为 0 时不是纠删码分片
+}
+
+// withErasureShard 标记发布的消息是纠删码分片
+// 参数:
+//   - index: 分片序号
+//   - count: 分片总数
+//
+// 返回值:
+//   - PubOpt: 发布选项
+func withErasureShard(index, count int) PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.shard = erasureShard{index: index, count: count}
+		return nil
+	}
+}
+
+// firstHop 从首跳对等节点中选出接收该分片的对等节点。
+// 对等节点按 ID 排序后轮流分配：对等节点多于分片时每个分片发给互不相交的一组对等节点，
+// 否则每个对等节点接收若干个不同的分片，因此每个首跳对等节点都至少收到一个分片。
+// 参数:
+//   - peers: 首跳对等节点
+//
+// 返回值:
+//   - []peer.ID: 接收该分片的对等节点
+func (s erasureShard) firstHop(peers []peer.ID) []peer.ID {
+	if s.count == 0 || len(peers) == 0 {
+		return peers
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i] < peers[j] })
+	if len(peers) < s.count {
+		return []peer.ID{peers[s.index%len(peers)]}
+	}
+	var out []peer.ID
+	for i := s.index; i < len(peers); i += s.count {
+		out = append(out, peers[i])
+	}
+	return out
+}
+
+// Subscribe 订阅主题，返回的订阅投递重组后的消息
+// 参数:
+//   - opts: 订阅选项
+//
+// 返回值:
+//   - *ErasureSubscription: 纠删码订阅
+//   - error: 错误信息，如果有的话
+func (et *ErasureTopic) Subscribe(opts ...SubOpt) (*ErasureSubscription, error) {
+	sub, err := et.t.Subscribe(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ErasureSubscription{
+		sub:     sub,
+		timeout: DefaultErasureReassemblyTimeout,
+		pending: make(map[erasureKey]*erasureObject),
+		done:    make(map[erasureKey]time.Time),
+	}, nil
+}
+
+// erasureKey 标识一条正在重组的消息
+type erasureKey struct {
+	from   peer.ID
+	digest [erasureDigestSize]byte
+}
+
+// erasureObject 是正在重组的消息
+type erasureObject struct {
+	k, m   int
+	length int
+	first  *Message       // 收到的第一个分片，重组后的消息沿用其元信息
+	shards map[int][]byte // 按分片序号索引的分片
+	start  time.Time      // 收到第一个分片的时间
+}
+
+// ErasureSubscription 重组纠删码分片并投递原始消息
+type ErasureSubscription struct {
+	sub     *Subscription
+	timeout time.Duration
+
+	mx      sync.Mutex
+	pending map[erasureKey]*erasureObject
+	done    map[erasureKey]time.Time // 已重组的消息，用于忽略多余的分片
+}
+
+// Next 返回下一条重组完成的消息，无效或多余的分片被忽略
+// 参数:
+//   - ctx: 上下文
+//
+// 返回值:
+//   - *Message: 重组后的消息，Data 为原始数据
+//   - error: 错误信息，如果有的话
+func (es *ErasureSubscription) Next(ctx context.Context) (*Message, error) {
+	for {
+		msg, err := es.sub.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if out := es.add(msg, time.Now()); out != nil {
+			return out, nil
+		}
+	}
+}
+
+// Cancel 取消订阅并丢弃尚未重组的分片
+func (es *ErasureSubscription) Cancel() {
+	es.sub.Cancel()
+
+	es.mx.Lock()
+	defer es.mx.Unlock()
+	es.pending = make(map[erasureKey]*erasureObject)
+}
+
+// add 记录一个分片，收到足够的分片时返回重组后的消息
+// 参数:
+//   - msg: 分片消息
+//   - now: 当前时间
+//
+// 返回值:
+//   - *Message: 重组后的消息，尚未完成时为 nil
+func (es *ErasureSubscription) add(msg *Message, now time.Time) *Message {
+	es.mx.Lock()
+	defer es.mx.Unlock()
+
+	es.expire(now)
+
+	payload := msg.GetData()
+	if len(payload) < erasureHeaderSize || payload[0] != erasureVersion {
+		logger.Debugf("忽略主题 %s 上的无效分片 %s", es.sub.topic, msg.ID)
+		return nil
+	}
+	key := erasureKey{from: msg.GetFrom()}
+	copy(key.digest[:], payload[1:])
+	k := int(payload[1+erasureDigestSize])
+	m := int(payload[2+erasureDigestSize])
+	idx := int(payload[3+erasureDigestSize])
+	length := int(binary.BigEndian.Uint32(payload[4+erasureDigestSize:]))
+	shard := payload[erasureHeaderSize:]
+
+	if _, ok := es.done[key]; ok {
+		return nil
+	}
+	if k == 0 || k+m > 255 || idx >= k+m || length > k*len(shard) {
+		logger.Debugf("忽略主题 %s 上参数无效的分片 %s", es.sub.topic, msg.ID)
+		return nil
+	}
+
+	obj, ok := es.pending[key]
+	if !ok {
+		if len(es.pending) >= DefaultErasureMaxPending {
+			es.evictOldest()
+		}
+		obj = &erasureObject{k: k, m: m, length: length, first: msg, shards: make(map[int][]byte), start: now}
+		es.pending[key] = obj
+	}
+	if obj.k != k || obj.m != m || obj.length != length {
+		logger.Debugf("忽略主题 %s 上参数不一致的分片 %s", es.sub.topic, msg.ID)
+		return nil
+	}
+	obj.shards[idx] = shard
+	if len(obj.shards) < obj.k {
+		return nil
+	}
+
+	delete(es.pending, key)
+	es.done[key] = now
+
+	codec, err := newRSCodec(obj.k, obj.m)
+	if err != nil {
+		return nil
+	}
+	data, err := codec.reconstruct(obj.shards, obj.length)
+	if err != nil {
+		logger.Debugf("重组主题 %s 的消息失败: %s", es.sub.topic, err)
+		return nil
+	}
+	if digest := sha256.Sum256(data); !bytes.Equal(digest[:erasureDigestSize], key.digest[:]) {
+		logger.Debugf("重组的主题 %s 的消息摘要不匹配", es.sub.topic)
+		return nil
+	}
+
+	first := obj.first
+	pmsg := *first.Message
+	pmsg.Data = data
+	return &Message{
+		Message:      &pmsg,
+		ID:           first.ID,
+		ReceivedFrom: first.ReceivedFrom,
+		Local:        first.Local,
+		receivedPath: first.receivedPath,
+	}
+}
+
+// expire 丢弃超时的重组状态。调用方必须持有 es.mx
+// 参数:
+//   - now: 当前时间
+func (es *ErasureSubscription) expire(now time.Time) {
+	for key, obj := range es.pending {
+		if now.Sub(obj.start) > es.timeout {
+			logger.Debugf("主题 %s 的消息重组超时，已收到 %d/%d 个分片", es.sub.topic, len(obj.shards), obj.k)
+			delete(es.pending, key)
+		}
+	}
+	for key, at := range es.done {
+		if now.Sub(at) > es.timeout {
+			delete(es.done, key)
+		}
+	}
+}
+
+// evictOldest 丢弃最早开始重组的消息。调用方必须持有 es.mx
+func (es *ErasureSubscription) evictOldest() {
+	var oldest erasureKey
+	var start time.Time
+	for key, obj := range es.pending {
+		if start.IsZero() || obj.start.Before(start) {
+			oldest, start = key, obj.start
+		}
+	}
+	delete(es.pending, oldest)
+}
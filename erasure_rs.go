@@ -0,0 +1,217 @@
+// 作用：纠删码的里德-所罗门编解码。
+// 功能：在 GF(2^8) 上实现系统里德-所罗门码，编码矩阵的上半部分为单位矩阵、下半部分为柯西矩阵，
+// 因此任意 k 行构成的子矩阵都可逆，可以从任意 k 个分片恢复原始数据。
+
+package pubsub
+
+import "fmt"
+
+// gfExp 和 gfLog 是 GF(2^8) 的指数表和对数表，本原多项式为 x^8+x^4+x^3+x^2+1
+var (
+	gfExp [512]byte
+	gfLog [256]byte
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = byte(i)
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11d
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// gfMul 返回 GF(2^8) 中的乘积
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+// gfInv 返回 GF(2^8) 中非零元素的乘法逆元
+func gfInv(a byte) byte {
+	return gfExp[255-int(gfLog[a])]
+}
+
+// rsCodec 是 k 个数据分片、m 个校验分片的系统里德-所罗门码
+type rsCodec struct {
+	k, m   int
+	matrix [][]byte // (k+m)×k 的编码矩阵
+}
+
+// newRSCodec 创建里德-所罗门编解码器
+// 参数:
+//   - k: 数据分片数
+//   - m: 校验分片数
+//
+// 返回值:
+//   - *rsCodec: 编解码器
+//   - error: 错误信息，如果有的话
+func newRSCodec(k, m int) (*rsCodec, error) {
+	if k <= 0 || m < 0 || k+m > 256 {
+		return nil, fmt.Errorf("无效的分片数: %d 个数据分片, %d 个校验分片", k, m)
+	}
+
+	matrix := make([][]byte, k+m)
+	for i := 0; i < k; i++ {
+		matrix[i] = make([]byte, k)
+		matrix[i][i] = 1
+	}
+	// 柯西矩阵 1/(x_i+y_j)，x_i = k+i 与 y_j = j 互不相同
+	for i := 0; i < m; i++ {
+		matrix[k+i] = make([]byte, k)
+		for j := 0; j < k; j++ {
+			matrix[k+i][j] = gfInv(byte(k+i) ^ byte(j))
+		}
+	}
+	return &rsCodec{k: k, m: m, matrix: matrix}, nil
+}
+
+// encode 将数据填充后切分为 k 个数据分片，并计算 m 个校验分片
+// 参数:
+//   - data: 原始数据
+//
+// 返回值:
+//   - [][]byte: k+m 个等长的分片
+func (c *rsCodec) encode(data []byte) [][]byte {
+	size := (len(data) + c.k - 1) / c.k
+	if size == 0 {
+		size = 1
+	}
+
+	shards := make([][]byte, c.k+c.m)
+	for i := 0; i < c.k; i++ {
+		shards[i] = make([]byte, size)
+		if start := i * size; start < len(data) {
+			copy(shards[i], data[start:])
+		}
+	}
+	for i := c.k; i < c.k+c.m; i++ {
+		shards[i] = make([]byte, size)
+		for j := 0; j < c.k; j++ {
+			gfMulAdd(shards[i], shards[j], c.matrix[i][j])
+		}
+	}
+	return shards
+}
+
+// reconstruct 从至少 k 个分片恢复原始数据
+// 参数:
+//   - shards: 按分片序号索引的分片，缺失的分片为 nil
+//   - length: 原始数据长度
+//
+// 返回值:
+//   - []byte: 原始数据
+//   - error: 错误信息，如果有的话
+func (c *rsCodec) reconstruct(shards map[int][]byte, length int) ([]byte, error) {
+	if len(shards) < c.k {
+		return nil, fmt.Errorf("分片不足: 需要 %d 个, 只有 %d 个", c.k, len(shards))
+	}
+
+	// 选择 k 个分片，优先使用数据分片
+	rows := make([]int, 0, c.k)
+	for i := 0; i < c.k+c.m && len(rows) < c.k; i++ {
+		if _, ok := shards[i]; ok {
+			rows = append(rows, i)
+		}
+	}
+	if len(rows) < c.k {
+		return nil, fmt.Errorf("分片序号超出范围")
+	}
+	size := len(shards[rows[0]])
+
+	sub := make([][]byte, c.k)
+	for i, r := range rows {
+		if len(shards[r]) != size {
+			return nil, fmt.Errorf("分片长度不一致")
+		}
+		sub[i] = append([]byte(nil), c.matrix[r]...)
+	}
+	inv, err := gfInvertMatrix(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, c.k*size)
+	for i := 0; i < c.k; i++ {
+		if s, ok := shards[i]; ok {
+			out = append(out, s...)
+			continue
+		}
+		block := make([]byte, size)
+		for j, r := range rows {
+			gfMulAdd(block, shards[r], inv[i][j])
+		}
+		out = append(out, block...)
+	}
+	if length > len(out) {
+		return nil, fmt.Errorf("原始数据长度 %d 超过分片总长度 %d", length, len(out))
+	}
+	return out[:length], nil
+}
+
+// gfMulAdd 计算 dst += c*src
+func gfMulAdd(dst, src []byte, c byte) {
+	if c == 0 {
+		return
+	}
+	for i, b := range src {
+		dst[i] ^= gfMul(c, b)
+	}
+}
+
+// gfInvertMatrix 用高斯-约当消元求 GF(2^8) 上方阵的逆矩阵，会修改输入矩阵
+// 参数:
+//   - a: 方阵
+//
+// 返回值:
+//   - [][]byte: 逆矩阵
+//   - error: 矩阵不可逆时返回错误
+func gfInvertMatrix(a [][]byte) ([][]byte, error) {
+	n := len(a)
+	inv := make([][]byte, n)
+	for i := range inv {
+		inv[i] = make([]byte, n)
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for r := col; r < n; r++ {
+			if a[r][col] != 0 {
+				pivot = r
+				break
+			}
+		}
+		if pivot < 0 {
+			return nil, fmt.Errorf("矩阵不可逆")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		if f := gfInv(a[col][col]); f != 1 {
+			for j := 0; j < n; j++ {
+				a[col][j] = gfMul(a[col][j], f)
+				inv[col][j] = gfMul(inv[col][j], f)
+			}
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for j := 0; j < n; j++ {
+				a[r][j] ^= gfMul(f, a[col][j])
+				inv[r][j] ^= gfMul(f, inv[col][j])
+			}
+		}
+	}
+	return inv, nil
+}
@@ -0,0 +1,218 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestReedSolomon 测试从任意 k 个分片恢复原始数据
+func TestReedSolomon(t *testing.T) {
+	codec, err := newRSCodec(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 1001)
+	rand.Read(data)
+	shards := codec.encode(data)
+	if len(shards) != 7 {
+		t.Fatalf("expected 7 shards, got %d", len(shards))
+	}
+
+	// 遍历所有 4 个分片的组合
+	for mask := 0; mask < 1<<7; mask++ {
+		available := make(map[int][]byte)
+		for i := 0; i < 7; i++ {
+			if mask&(1<<i) != 0 {
+				available[i] = shards[i]
+			}
+		}
+		if len(available) != 4 {
+			continue
+		}
+		got, err := codec.reconstruct(available, len(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("unexpected data reconstructed from shards %b", mask)
+		}
+	}
+
+	if _, err := codec.reconstruct(map[int][]byte{0: shards[1], 5: shards[5]}, len(data)); err == nil {
+		t.Fatal("expected error with too few shards")
+	}
+	if _, err := newRSCodec(200, 57); err == nil {
+		t.Fatal("expected error for too many shards")
+	}
+}
+
+// TestErasureTopic 测试分片丢失时仍能重组大消息，且每条消息只投递一次
+func TestErasureTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	if _, err := NewErasureTopic(topics[0], 0, 2); err == nil {
+		t.Fatal("expected error for zero data shards")
+	}
+
+	pub, err := NewErasureTopic(topics[0], 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv, err := NewErasureTopic(topics[1], 4, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := recv.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	data := make([]byte, 100*1024)
+	rand.Read(data)
+	if err := pub.Publish(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(msg.Data, data) || msg.GetFrom() != hosts[0].ID() {
+		t.Fatal("unexpected reassembled message")
+	}
+
+	nctx, ncancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer ncancel()
+	if _, err := sub.Next(nctx); err == nil {
+		t.Fatal("expected the remaining shards to be ignored")
+	}
+}
+
+// TestErasureShardFirstHop 测试分片在首跳对等节点中的分配
+func TestErasureShardFirstHop(t *testing.T) {
+	peers := []peer.ID{"a", "b", "c", "d"}
+
+	// 分片多于对等节点时每个分片只发给一个对等节点，每个对等节点都收到分片
+	got := make(map[peer.ID]int)
+	for i := 0; i < 6; i++ {
+		hop := erasureShard{index: i, count: 6}.firstHop(append([]peer.ID(nil), peers...))
+		if len(hop) != 1 {
+			t.Fatalf("expected shard %d to go to one peer, got %v", i, hop)
+		}
+		got[hop[0]]++
+	}
+	if len(got) != len(peers) {
+		t.Fatalf("expected every peer to receive a shard, got %v", got)
+	}
+
+	// 对等节点多于分片时每个分片发给互不相交的一组对等节点
+	seen := make(map[peer.ID]int)
+	for i := 0; i < 2; i++ {
+		hop := erasureShard{index: i, count: 2}.firstHop(append([]peer.ID(nil), peers...))
+		if len(hop) != 2 {
+			t.Fatalf("expected shard %d to go to two peers, got %v", i, hop)
+		}
+		for _, p := range hop {
+			seen[p]++
+		}
+	}
+	for _, p := range peers {
+		if seen[p] != 1 {
+			t.Fatalf("expected peer %s to receive exactly one shard, got %d", p, seen[p])
+		}
+	}
+
+	// 普通消息发给所有对等节点
+	if hop := (erasureShard{}).firstHop(peers); len(hop) != len(peers) {
+		t.Fatalf("expected a regular message to go to every peer, got %v", hop)
+	}
+}
+
+// TestErasureTopicSpread 测试发布者把分片分散到不同的对等节点，且订阅者在丢失 m 个分片时仍能重组
+func TestErasureTopicSpread(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const k, m = 4, 2
+	hosts := getDefaultHosts(t, 5)
+	rec := &sendRecorder{}
+	psubs := []*PubSub{getGossipsub(ctx, hosts[0], WithRawTracer(rec, FilterEvents(SendRPC)))}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:])...)
+
+	var subs []*ErasureSubscription
+	var pub *ErasureTopic
+	for i, ps := range psubs {
+		topic, err := ps.Join("blocks")
+		if err != nil {
+			t.Fatal(err)
+		}
+		et, err := NewErasureTopic(topic, k, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			pub = et
+			continue
+		}
+
+		// 每个订阅者都丢弃前 m 个分片
+		err = ps.RegisterTopicValidator("blocks", func(ctx context.Context, p peer.ID, msg *Message) ValidationResult {
+			if int(msg.GetData()[3+erasureDigestSize]) < m {
+				return ValidationIgnore
+			}
+			return ValidationAccept
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := et.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	connectAll(t, hosts)
+	time.Sleep(2 * time.Second)
+
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+	if err := pub.Publish(ctx, data); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sub := range subs {
+		nctx, ncancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(nctx)
+		ncancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(msg.Data, data) {
+			t.Fatal("unexpected reassembled message")
+		}
+	}
+
+	// 每个分片只发给一部分首跳对等节点，而不是发给所有对等节点
+	if _, published := rec.counts(); published >= (k+m)*len(subs) {
+		t.Fatalf("expected shards to be spread across first-hop peers, sent %d copies", published)
+	}
+}
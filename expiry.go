@@ -0,0 +1,89 @@
+// 作用：基于时间的消息过期验证。
+// 功能：发布者可以在消息元信息中写入发布时间，发布时间随消息签名；
+// 内置的过期验证器拒绝缺少发布时间或发布时间超出时钟偏差容忍范围的未来消息，忽略过旧的消息。
+// 被拒绝的消息会使转发它的对等节点受到评分惩罚，而过旧的消息可能只是传播延迟造成的，不做惩罚。
+
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// WithTimestamp 在消息元信息中写入当前时间作为发布时间，发布时间参与签名
+// 返回值:
+//   - PubOpt: 发布选项
+func WithTimestamp() PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.timestamp = true
+		return nil
+	}
+}
+
+// stampTimestamp 在消息元信息中写入发布时间，必须在签名之前调用
+// 参数:
+//   - m: 消息
+//   - now: 当前时间
+func stampTimestamp(m *pb.Message, now time.Time) {
+	if m.Metadata == nil {
+		m.Metadata = &pb.MessageMetadata{}
+	}
+	m.Metadata.Timestamp = now.UnixNano()
+}
+
+// Timestamp 返回消息元信息中的发布时间
+// 返回值:
+//   - time.Time: 发布时间
+//   - bool: 消息是否携带发布时间
+func (m *Message) Timestamp() (time.Time, bool) {
+	ts := m.GetMetadata().GetTimestamp()
+	if ts == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ts), true
+}
+
+// ExpiryValidator 返回一个按发布时间验证消息的验证器，发布者需要使用 WithTimestamp 发布消息。
+// 缺少发布时间或发布时间晚于当前时间 maxSkew 以上的消息被拒绝；
+// 发布时间早于当前时间 maxAge+maxSkew 以上的消息被忽略。
+// 参数:
+//   - maxAge: 消息的最大存活时间
+//   - maxSkew: 容忍的时钟偏差
+//
+// 返回值:
+//   - ValidatorEx: 扩展验证器
+func ExpiryValidator(maxAge, maxSkew time.Duration) ValidatorEx {
+	return func(_ context.Context, _ peer.ID, m *Message) ValidationResult {
+		return validateExpiry(m, maxAge, maxSkew, time.Now())
+	}
+}
+
+// validateExpiry 按发布时间验证消息
+// 参数:
+//   - m: 消息
+//   - maxAge: 消息的最大存活时间
+//   - maxSkew: 容忍的时钟偏差
+//   - now: 当前时间
+//
+// 返回值:
+//   - ValidationResult: 验证结果
+func validateExpiry(m *Message, maxAge, maxSkew time.Duration, now time.Time) ValidationResult {
+	ts, ok := m.Timestamp()
+	if !ok {
+		logger.Debugf("主题 %s 的消息缺少发布时间", m.GetTopic())
+		return ValidationReject
+	}
+	if ts.After(now.Add(maxSkew)) {
+		logger.Debugf("主题 %s 的消息发布时间 %s 超出时钟偏差容忍范围", m.GetTopic(), ts)
+		return ValidationReject
+	}
+	if now.Sub(ts) > maxAge+maxSkew {
+		logger.Debugf("主题 %s 的消息已过期，发布时间 %s", m.GetTopic(), ts)
+		return ValidationIgnore
+	}
+	return ValidationAccept
+}
@@ -0,0 +1,66 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestExpiryValidator 测试过期验证器接受带有发布时间的消息，拒绝缺少发布时间的消息
+func TestExpiryValidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	if err := psubs[1].RegisterTopicValidator("foo", ExpiryValidator(time.Minute, time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	topics := getTopics(psubs[:1], "foo")
+	if err := topics[0].Publish(ctx, []byte("untimed")); err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("timed")
+	if err := topics[0].Publish(ctx, msg, WithTimestamp()); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+}
+
+// TestValidateExpiry 测试发布时间的检查和时钟偏差容忍
+func TestValidateExpiry(t *testing.T) {
+	now := time.Now()
+	msgAt := func(ts time.Time) *Message {
+		m := &Message{Message: &pb.Message{}}
+		stampTimestamp(m.Message, ts)
+		return m
+	}
+
+	cases := []struct {
+		name string
+		msg  *Message
+		want ValidationResult
+	}{
+		{"missing", &Message{Message: &pb.Message{}}, ValidationReject},
+		{"fresh", msgAt(now.Add(-time.Second)), ValidationAccept},
+		{"within skew", msgAt(now.Add(time.Second)), ValidationAccept},
+		{"future", msgAt(now.Add(time.Minute)), ValidationReject},
+		{"stale within skew", msgAt(now.Add(-time.Minute - time.Second)), ValidationAccept},
+		{"stale", msgAt(now.Add(-2 * time.Minute)), ValidationIgnore},
+	}
+	for _, c := range cases {
+		if res := validateExpiry(c.msg, time.Minute, 2*time.Second, now); res != c.want {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, res)
+		}
+	}
+}
@@ -5,6 +5,7 @@ package pubsub
 
 import (
 	"context"
+	"time"
 
 	"github.com/dep2p/go-dep2p/core/host"
 	"github.com/dep2p/go-dep2p/core/peer"
@@ -52,6 +53,8 @@ type FloodSubRouter struct {
 	p         *PubSub       // 关联的PubSub实例
 	protocols []protocol.ID // 支持的协议列表
 	tracer    *pubsubTracer // 追踪器
+	dedup     *floodDedup   // 每对等节点的去重器，为 nil 时不去重
+	gate      *peerGater    // 对等节点门控，为 nil 时接受所有消息
 }
 
 // Protocols 返回FloodSubRouter支持的协议列表
@@ -82,6 +85,7 @@ func (fs *FloodSubRouter) AddPeer(p peer.ID, proto protocol.ID) {
 //   - p: 对等节点ID
 func (fs *FloodSubRouter) RemovePeer(p peer.ID) {
 	fs.tracer.RemovePeer(p) // 在追踪器中移除对等节点
+	fs.dedup.remove(p)      // 丢弃对等节点的去重记录
 }
 
 // EnoughPeers 检查是否有足够的对等节点来支持特定主题。
@@ -113,18 +117,26 @@ func (fs *FloodSubRouter) EnoughPeers(topic string, suggested int) bool {
 
 // AcceptFrom 决定是否接受来自特定对等节点的消息
 // 参数:
-//   - peer: 对等节点ID
+//   - p: 对等节点ID
 //
 // 返回值:
 //   - AcceptStatus: 接受状态
-func (fs *FloodSubRouter) AcceptFrom(peer.ID) AcceptStatus {
-	return AcceptAll // 接受所有消息
+func (fs *FloodSubRouter) AcceptFrom(p peer.ID) AcceptStatus {
+	return fs.gate.AcceptFrom(p) // 由对等节点门控决定，未启用时接受所有消息
 }
 
 // HandleRPC 处理接收到的RPC消息
 // 参数:
 //   - rpc: RPC消息
-func (fs *FloodSubRouter) HandleRPC(rpc *RPC) {}
+func (fs *FloodSubRouter) HandleRPC(rpc *RPC) {
+	if fs.dedup == nil {
+		return
+	}
+	// 记录对等节点发来的消息，包括重复的消息，之后不再向其转发
+	for _, pmsg := range rpc.GetPublish() {
+		fs.dedup.add(rpc.from, fs.p.idGen.RawID(pmsg))
+	}
+}
 
 // Publish 发布消息到主题
 // 参数:
@@ -135,6 +147,11 @@ func (fs *FloodSubRouter) Publish(msg *Message) {
 
 	out := rpcWithMessages(msg.Message) // 将消息打包成RPC
 
+	var id string
+	if fs.dedup != nil {
+		id = fs.p.idGen.ID(msg) // 获取消息 ID 用于去重
+	}
+
 	// 遍历订阅了该主题的对等节点
 	for pid := range fs.p.topics[topic] {
 		// 如果节点是消息发送者或消息来源节点，跳过该节点
@@ -149,12 +166,33 @@ func (fs *FloodSubRouter) Publish(msg *Message) {
 			continue
 		}
 
+		// 如果对等节点最近已经拥有该消息，跳过该节点
+		if fs.dedup.has(pid, id) {
+			continue
+		}
+
+		// 如果超出出站带宽预算，丢弃转发的消息
+		if !fs.p.allowForward(msg, time.Now()) {
+			logger.Debugf("丢弃消息到对等节点 %s: 超出出站带宽预算", pid)
+			fs.tracer.DropRPC(out, pid) // 追踪丢弃的RPC消息
+			continue
+		}
+
+		// 如果内存预算不足，丢弃消息
+		if !fs.p.reserveRPC(out) {
+			logger.Infof("丢弃消息到对等节点 %s: 内存预算不足", pid)
+			fs.tracer.DropRPC(out, pid) // 追踪丢弃的RPC消息
+			continue
+		}
+
 		// 尝试向对等节点发送消息
 		select {
 		case mch <- out: // 发送消息到对等节点
 			fs.tracer.SendRPC(out, pid) // 追踪发送的RPC消息
+			fs.dedup.add(pid, id)       // 记录已发送给对等节点
 		default:
 			// 如果消息队列已满，丢弃消息
+			fs.p.releaseRPC(out)                    // 释放预留的内存预算
 			logger.Infof("丢弃消息到对等节点 %s: 队列已满", pid) // 队列已满，丢弃消息
 			fs.tracer.DropRPC(out, pid)             // 追踪丢弃的RPC消息
 		}
@@ -0,0 +1,120 @@
+// 作用：FloodSub 的每对等节点去重。
+// 功能：为每个对等节点维护最近从其收到和向其发送的消息 ID 的布隆过滤器，转发时跳过已经拥有该消息的对等节点，
+// 减少小规模泛洪网络中的重复消息。布隆过滤器只作为快速提示，命中后再由同样按代轮换的精确 ID 集合确认，
+// 因此假阳性不会使消息漏转发给尚未拥有它的对等节点。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+const (
+	// DefaultFloodSubDedupCapacity 是每个对等节点的去重过滤器每一代记录的消息数
+	DefaultFloodSubDedupCapacity = 1024
+	// FloodSubDedupFPRate 是去重过滤器的假阳性率
+	FloodSubDedupFPRate = 0.001
+)
+
+// WithFloodSubDedup 是一个选项，启用 FloodSub 的每对等节点去重
+// 参数:
+//   - capacity: 每个对等节点记录的最近消息数，为 0 时使用 DefaultFloodSubDedupCapacity
+//
+// 返回值:
+//   - Option: 配置选项
+func WithFloodSubDedup(capacity int) Option {
+	return func(ps *PubSub) error {
+		fs, ok := ps.rt.(*FloodSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 floodsub")
+			return fmt.Errorf("pubsub 路由器不是 floodsub")
+		}
+		if capacity < 0 {
+			logger.Warnf("去重容量不能为负数")
+			return fmt.Errorf("去重容量不能为负数")
+		}
+		if capacity == 0 {
+			capacity = DefaultFloodSubDedupCapacity
+		}
+		fs.dedup = &floodDedup{
+			capacity: capacity,
+			peers:    make(map[peer.ID]*dedupPeer),
+		}
+		return nil
+	}
+}
+
+// floodDedup 记录每个对等节点最近拥有的消息。只从 processLoop 访问。
+type floodDedup struct {
+	capacity int
+	peers    map[peer.ID]*dedupPeer
+}
+
+// dedupPeer 是单个对等节点的去重记录：布隆过滤器用于快速排除，精确集合用于确认命中
+type dedupPeer struct {
+	bloom *rotatingBloom
+	cur   map[string]struct{}
+	prev  map[string]struct{}
+}
+
+// add 记录对等节点拥有消息。nil 去重器不做任何事
+// 参数:
+//   - p: 对等节点
+//   - id: 消息 ID
+func (d *floodDedup) add(p peer.ID, id string) {
+	if d == nil {
+		return
+	}
+	dp, ok := d.peers[p]
+	if !ok {
+		dp = &dedupPeer{
+			bloom: newRotatingBloom(d.capacity, FloodSubDedupFPRate),
+			cur:   make(map[string]struct{}),
+		}
+		d.peers[p] = dp
+	}
+	if _, ok := dp.cur[id]; ok {
+		return
+	}
+	dp.bloom.Add(id)
+	if len(dp.cur) >= d.capacity {
+		dp.prev = dp.cur
+		dp.cur = make(map[string]struct{})
+	}
+	dp.cur[id] = struct{}{}
+}
+
+// has 返回对等节点是否确实已经拥有消息。布隆过滤器未命中时直接返回 false，
+// 命中时由精确集合确认，避免假阳性导致漏转发。nil 去重器总是返回 false
+// 参数:
+//   - p: 对等节点
+//   - id: 消息 ID
+//
+// 返回值:
+//   - bool: 对等节点是否已经拥有消息
+func (d *floodDedup) has(p peer.ID, id string) bool {
+	if d == nil {
+		return false
+	}
+	dp, ok := d.peers[p]
+	if !ok || !dp.bloom.Has(id) {
+		return false
+	}
+	if _, ok := dp.cur[id]; ok {
+		return true
+	}
+	_, ok = dp.prev[id]
+	return ok
+}
+
+// remove 丢弃对等节点的记录
+// 参数:
+//   - p: 对等节点
+func (d *floodDedup) remove(p peer.ID) {
+	if d == nil {
+		return
+	}
+	delete(d.peers, p)
+}
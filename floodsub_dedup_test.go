@@ -0,0 +1,150 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestBloomFilter 测试布隆过滤器没有假阴性，假阳性率接近设定值，并按容量轮换
+func TestBloomFilter(t *testing.T) {
+	bf := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		bf.Add(fmt.Sprintf("msg-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !bf.Has(fmt.Sprintf("msg-%d", i)) {
+			t.Fatalf("false negative for msg-%d", i)
+		}
+	}
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		if bf.Has(fmt.Sprintf("other-%d", i)) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Fatalf("too many false positives: %d/10000", fp)
+	}
+
+	rb := newRotatingBloom(10, 0.01)
+	for i := 0; i < 25; i++ {
+		rb.Add(fmt.Sprintf("msg-%d", i))
+	}
+	if rb.Has("msg-0") {
+		t.Fatal("expected the oldest generation to be discarded")
+	}
+	if !rb.Has("msg-15") || !rb.Has("msg-24") {
+		t.Fatal("expected recent messages to be remembered")
+	}
+}
+
+// TestFloodSubDedup 测试启用去重的 FloodSub 跳过已经拥有消息的对等节点，并且消息仍能送达
+func TestFloodSubDedup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getPubsubs(ctx, hosts, WithFloodSubDedup(0))
+	connectAll(t, hosts)
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	time.Sleep(time.Second)
+
+	topics := getTopics(psubs[:1], "foo")
+	for i := 0; i < 10; i++ {
+		msg := []byte(fmt.Sprintf("msg-%d", i))
+		if err := topics[0].Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs {
+			assertReceive(t, sub, msg)
+		}
+	}
+
+	// 已经记录的消息不再转发给同一个对等节点
+	fs := psubs[1].rt.(*FloodSubRouter)
+	done := make(chan bool)
+	psubs[1].eval <- func() {
+		pid := peer.ID("peer")
+		fs.dedup.add(pid, "id")
+		done <- fs.dedup.has(pid, "id") && !fs.dedup.has(pid, "other")
+	}
+	if !<-done {
+		t.Fatal("expected the dedup filter to track the recorded message")
+	}
+}
+
+// TestFloodSubDedupInvalid 测试去重选项只能用于 FloodSub
+func TestFloodSubDedupInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithFloodSubDedup(0)); err == nil {
+		t.Fatal("expected an error for a non-floodsub router")
+	}
+}
+
+// TestFloodSubDedupFalsePositive 测试布隆过滤器假阳性不会使消息漏转发给尚未拥有它的对等节点
+func TestFloodSubDedupFalsePositive(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	rec := &sendRecorder{}
+	psubs := []*PubSub{getPubsub(ctx, hosts[0], WithFloodSubDedup(0), WithRawTracer(rec, FilterEvents(SendRPC)))}
+	psubs = append(psubs, getPubsubs(ctx, hosts[1:], WithFloodSubDedup(0))...)
+	connectAll(t, hosts)
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	time.Sleep(time.Second)
+
+	// 将每个对等节点的布隆过滤器置满，使所有查询都命中
+	fs := psubs[0].rt.(*FloodSubRouter)
+	done := make(chan struct{})
+	psubs[0].eval <- func() {
+		for _, h := range hosts[1:] {
+			fs.dedup.add(h.ID(), "seed")
+			dp := fs.dedup.peers[h.ID()]
+			for i := range dp.bloom.cur.bits {
+				dp.bloom.cur.bits[i] = ^uint64(0)
+			}
+		}
+		close(done)
+	}
+	<-done
+
+	topics := getTopics(psubs[:1], "foo")
+	const count = 5
+	for i := 0; i < count; i++ {
+		msg := []byte(fmt.Sprintf("msg-%d", i))
+		if err := topics[0].Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs[1:] {
+			assertReceive(t, sub, msg)
+		}
+	}
+
+	if _, published := rec.counts(); published != count*2 {
+		t.Fatalf("expected %d forwards, got %d", count*2, published)
+	}
+}
@@ -0,0 +1,561 @@
+// 作用：面向非 dep2p 客户端的 WebSocket/HTTP 网关。
+// 功能：将 JSON 帧转换为主题消息，使浏览器和非 dep2p 服务无需运行完整节点即可通过 WebSocket 或 HTTP SSE
+// 发布和订阅主题。
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	logging "github.com/dep2p/log"
+	"github.com/dep2p/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+var logger = logging.Logger("pubsub-gateway")
+
+// 帧类型
+const (
+	FrameSubscribe   = "subscribe"   // 客户端订阅主题
+	FrameUnsubscribe = "unsubscribe" // 客户端取消订阅主题
+	FramePublish     = "publish"     // 客户端发布消息
+	FrameMessage     = "message"     // 网关投递消息
+	FrameError       = "error"       // 网关返回错误
+)
+
+// maxPublishSize 是 HTTP 发布请求体的最大大小
+const maxPublishSize = 4 << 20
+
+// DefaultMaxTopics 是网关默认最多加入的主题数量
+const DefaultMaxTopics = 64
+
+// Frame 是网关与客户端之间交换的 JSON 帧，Data 在 JSON 中以 base64 编码
+type Frame struct {
+	Type  string `json:"type"`            // 帧类型
+	Topic string `json:"topic,omitempty"` // 主题
+	Data  []byte `json:"data,omitempty"`  // 消息数据
+	ID    string `json:"id,omitempty"`    // 消息 ID，仅用于投递的消息
+	From  string `json:"from,omitempty"`  // 消息来源，仅用于投递的消息
+	Error string `json:"error,omitempty"` // 错误信息，仅用于错误帧
+}
+
+// Option 是网关的配置选项
+type Option func(g *Gateway) error
+
+// WithCheckOrigin 是一个选项，用于设置 WebSocket 握手的来源检查函数。
+// 默认只接受同源或不带 Origin 头的请求。
+// 参数:
+//   - check: 来源检查函数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithCheckOrigin(check func(r *http.Request) bool) Option {
+	return func(g *Gateway) error {
+		if check == nil {
+			logger.Warnf("来源检查函数不能为空")
+			return fmt.Errorf("来源检查函数不能为空")
+		}
+		g.upgrader.CheckOrigin = check
+		return nil
+	}
+}
+
+// WithTopicAllowlist 是一个选项，只允许客户端通过网关订阅和发布给定的主题。
+// 默认允许所有主题，但加入的主题数量受 WithMaxTopics 限制。
+// 参数:
+//   - topics: 允许的主题
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTopicAllowlist(topics ...string) Option {
+	return func(g *Gateway) error {
+		if len(topics) == 0 {
+			logger.Warnf("主题允许名单不能为空")
+			return fmt.Errorf("主题允许名单不能为空")
+		}
+		g.allowed = make(map[string]struct{}, len(topics))
+		for _, topic := range topics {
+			g.allowed[topic] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithMaxTopics 是一个选项，用于设置网关最多加入的主题数量，默认为 DefaultMaxTopics
+// 参数:
+//   - n: 最多加入的主题数量
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMaxTopics(n int) Option {
+	return func(g *Gateway) error {
+		if n <= 0 {
+			logger.Warnf("最大主题数量必须为正数")
+			return fmt.Errorf("最大主题数量必须为正数")
+		}
+		g.maxTopics = n
+		return nil
+	}
+}
+
+// Gateway 通过 WebSocket 和 HTTP SSE 暴露发布订阅
+//
+// 端点:
+//   - GET /ws: WebSocket，客户端发送 subscribe、unsubscribe 和 publish 帧，网关发送 message 和 error 帧
+//   - GET /subscribe?topic=<topic>: SSE，每条消息作为一个 message 事件，数据为 JSON 帧
+//   - POST /publish: 请求体为 publish 帧
+//
+// 网关与本地应用程序共享 PubSub 实例，但需要独占网关使用的主题句柄；
+// 网关自身发布的消息不会投递给同一网关上的订阅者。
+type Gateway struct {
+	ps       *pubsub.PubSub
+	upgrader websocket.Upgrader
+	server   *http.Server
+	listener net.Listener
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup // 服务和活动的客户端处理器
+
+	allowed   map[string]struct{} // 允许的主题，为 nil 时允许所有主题
+	maxTopics int                 // 最多加入的主题数量
+
+	mx     sync.Mutex
+	topics map[string]*pubsub.Topic // 网关加入的主题
+}
+
+// New 创建网关并在给定地址上开始监听
+// 参数:
+//   - ps: PubSub 实例
+//   - addr: 监听地址，例如 ":8080"
+//   - opts: 配置选项
+//
+// 返回值:
+//   - *Gateway: 网关
+//   - error: 错误信息，如果有的话
+func New(ps *pubsub.PubSub, addr string, opts ...Option) (*Gateway, error) {
+	if ps == nil {
+		logger.Warnf("PubSub 实例不能为空")
+		return nil, fmt.Errorf("PubSub 实例不能为空")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &Gateway{
+		ps:        ps,
+		ctx:       ctx,
+		cancel:    cancel,
+		maxTopics: DefaultMaxTopics,
+		topics:    make(map[string]*pubsub.Topic),
+	}
+	for _, opt := range opts {
+		if err := opt(g); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		cancel()
+		logger.Warnf("网关监听 %s 失败: %s", addr, err)
+		return nil, fmt.Errorf("网关监听 %s 失败: %w", addr, err)
+	}
+	g.listener = l
+	g.server = &http.Server{
+		Handler:           g.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := g.server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Warnf("网关服务出错: %s", err)
+		}
+	}()
+
+	return g, nil
+}
+
+// Addr 返回网关的监听地址
+// 返回值:
+//   - net.Addr: 监听地址
+func (g *Gateway) Addr() net.Addr {
+	return g.listener.Addr()
+}
+
+// Handler 返回网关的 HTTP 处理器，可以挂载到应用程序自己的 HTTP 服务上
+// 返回值:
+//   - http.Handler: HTTP 处理器
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws", g.handleWebSocket)
+	mux.HandleFunc("GET /subscribe", g.handleSSE)
+	mux.HandleFunc("POST /publish", g.handlePublish)
+	return mux
+}
+
+// Close 关闭网关，断开所有客户端并关闭网关加入的主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (g *Gateway) Close() error {
+	g.mx.Lock()
+	g.cancel()
+	g.mx.Unlock()
+
+	err := g.server.Close()
+	g.wg.Wait()
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+	for name, t := range g.topics {
+		if cerr := t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(g.topics, name)
+	}
+	return err
+}
+
+// track 登记一个活动的客户端处理器，网关关闭后返回 false
+// 返回值:
+//   - bool: 是否登记成功
+func (g *Gateway) track() bool {
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if g.ctx.Err() != nil {
+		return false
+	}
+	g.wg.Add(1)
+	return true
+}
+
+// requestContext 返回一个在请求结束或网关关闭时取消的上下文，
+// 使挂载到应用程序 HTTP 服务上的处理器也会随网关关闭而退出
+// 参数:
+//   - r: 请求
+//
+// 返回值:
+//   - context.Context: 上下文
+//   - context.CancelFunc: 取消函数
+func (g *Gateway) requestContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(g.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// topic 返回主题句柄，首次使用时加入主题；主题不在允许名单中或加入的主题数量已达到上限时返回错误
+// 参数:
+//   - name: 主题
+//
+// 返回值:
+//   - *pubsub.Topic: 主题句柄
+//   - error: 错误信息，如果有的话
+func (g *Gateway) topic(name string) (*pubsub.Topic, error) {
+	if name == "" {
+		return nil, fmt.Errorf("主题不能为空")
+	}
+	if g.allowed != nil {
+		if _, ok := g.allowed[name]; !ok {
+			return nil, fmt.Errorf("主题 %s 不在允许名单中", name)
+		}
+	}
+
+	g.mx.Lock()
+	defer g.mx.Unlock()
+
+	if t, ok := g.topics[name]; ok {
+		return t, nil
+	}
+	if len(g.topics) >= g.maxTopics {
+		return nil, fmt.Errorf("网关加入的主题数量已达到上限 %d", g.maxTopics)
+	}
+	t, err := g.ps.Join(name)
+	if err != nil {
+		logger.Warnf("网关加入主题 %s 失败: %s", name, err)
+		return nil, fmt.Errorf("网关加入主题 %s 失败: %w", name, err)
+	}
+	g.topics[name] = t
+	return t, nil
+}
+
+// subscribe 订阅主题
+// 参数:
+//   - name: 主题
+//
+// 返回值:
+//   - *pubsub.Subscription: 订阅
+//   - error: 错误信息，如果有的话
+func (g *Gateway) subscribe(name string) (*pubsub.Subscription, error) {
+	t, err := g.topic(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Subscribe()
+}
+
+// publish 发布消息
+// 参数:
+//   - ctx: 上下文
+//   - f: publish 帧
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (g *Gateway) publish(ctx context.Context, f *Frame) error {
+	t, err := g.topic(f.Topic)
+	if err != nil {
+		return err
+	}
+	return t.Publish(ctx, f.Data)
+}
+
+// messageFrame 将消息转换为 message 帧
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - *Frame: message 帧
+func messageFrame(msg *pubsub.Message) *Frame {
+	f := &Frame{
+		Type:  FrameMessage,
+		Topic: msg.GetTopic(),
+		Data:  msg.GetData(),
+		ID:    msg.ID,
+	}
+	if from := msg.GetFrom(); from != "" {
+		f.From = from.String()
+	}
+	return f
+}
+
+// handlePublish 处理 HTTP 发布请求
+func (g *Gateway) handlePublish(w http.ResponseWriter, r *http.Request) {
+	var f Frame
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPublishSize)).Decode(&f); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("无效的发布帧: %w", err))
+		return
+	}
+	if err := g.publish(r.Context(), &f); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSSE 通过 SSE 向客户端推送主题消息
+func (g *Gateway) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !g.track() {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("网关已关闭"))
+		return
+	}
+	defer g.wg.Done()
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("响应不支持流式传输"))
+		return
+	}
+	sub, err := g.subscribe(r.URL.Query().Get("topic"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer sub.Cancel()
+
+	ctx, cancel := g.requestContext(r)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return
+		}
+		data, err := json.Marshal(messageFrame(msg))
+		if err != nil {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", FrameMessage, data); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleWebSocket 处理 WebSocket 客户端
+func (g *Gateway) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !g.track() {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("网关已关闭"))
+		return
+	}
+	defer g.wg.Done()
+
+	conn, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		logger.Debugf("WebSocket 握手失败: %s", err)
+		return
+	}
+
+	c := &wsClient{
+		g:    g,
+		conn: conn,
+		subs: make(map[string]*pubsub.Subscription),
+	}
+	c.ctx, c.cancel = g.requestContext(r)
+	c.serve()
+}
+
+// writeError 以 error 帧返回 HTTP 错误
+// 参数:
+//   - w: 响应
+//   - status: HTTP 状态码
+//   - err: 错误
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&Frame{Type: FrameError, Error: err.Error()})
+}
+
+// wsClient 表示一个 WebSocket 客户端连接
+type wsClient struct {
+	g      *Gateway
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	writeMx sync.Mutex // 保护并发写入
+
+	mx   sync.Mutex
+	subs map[string]*pubsub.Subscription // 客户端的订阅
+}
+
+// serve 读取客户端帧直到连接关闭，然后取消客户端的所有订阅
+func (c *wsClient) serve() {
+	defer func() {
+		c.cancel()
+		c.mx.Lock()
+		for _, sub := range c.subs {
+			sub.Cancel()
+		}
+		c.mx.Unlock()
+		c.wg.Wait()
+		c.conn.Close()
+	}()
+
+	// 网关关闭时断开连接
+	go func() {
+		<-c.ctx.Done()
+		c.conn.Close()
+	}()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var f Frame
+		if err := json.Unmarshal(data, &f); err != nil {
+			c.writeError(fmt.Errorf("无效的帧: %w", err))
+			continue
+		}
+		if err := c.handleFrame(&f); err != nil {
+			c.writeError(err)
+		}
+	}
+}
+
+// handleFrame 处理客户端帧
+// 参数:
+//   - f: 客户端帧
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (c *wsClient) handleFrame(f *Frame) error {
+	switch f.Type {
+	case FramePublish:
+		return c.g.publish(c.ctx, f)
+
+	case FrameSubscribe:
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		if _, ok := c.subs[f.Topic]; ok {
+			return nil
+		}
+		sub, err := c.g.subscribe(f.Topic)
+		if err != nil {
+			return err
+		}
+		c.subs[f.Topic] = sub
+		c.wg.Add(1)
+		go c.deliver(sub)
+		return nil
+
+	case FrameUnsubscribe:
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		if sub, ok := c.subs[f.Topic]; ok {
+			sub.Cancel()
+			delete(c.subs, f.Topic)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("未知的帧类型: %s", f.Type)
+	}
+}
+
+// deliver 将订阅的消息投递给客户端，直到订阅被取消
+// 参数:
+//   - sub: 订阅
+func (c *wsClient) deliver(sub *pubsub.Subscription) {
+	defer c.wg.Done()
+
+	for {
+		msg, err := sub.Next(c.ctx)
+		if err != nil {
+			return
+		}
+		if err := c.write(messageFrame(msg)); err != nil {
+			c.cancel()
+			return
+		}
+	}
+}
+
+// write 向客户端发送帧
+// 参数:
+//   - f: 帧
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (c *wsClient) write(f *Frame) error {
+	c.writeMx.Lock()
+	defer c.writeMx.Unlock()
+	return c.conn.WriteJSON(f)
+}
+
+// writeError 向客户端发送 error 帧
+// 参数:
+//   - err: 错误
+func (c *wsClient) writeError(err error) {
+	if werr := c.write(&Frame{Type: FrameError, Error: err.Error()}); werr != nil {
+		logger.Debugf("发送错误帧失败: %s", werr)
+	}
+}
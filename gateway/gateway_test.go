@@ -0,0 +1,222 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p"
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/pubsub"
+	"github.com/gorilla/websocket"
+)
+
+// getNetwork 创建两个相连的 floodsub 节点
+func getNetwork(t *testing.T, ctx context.Context) ([]host.Host, []*pubsub.PubSub) {
+	var hosts []host.Host
+	var psubs []*pubsub.PubSub
+	for i := 0; i < 2; i++ {
+		h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.Close() })
+		ps, err := pubsub.NewFloodSub(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+		psubs = append(psubs, ps)
+	}
+	if err := hosts[1].Connect(ctx, hosts[0].Peerstore().PeerInfo(hosts[0].ID())); err != nil {
+		t.Fatal(err)
+	}
+	return hosts, psubs
+}
+
+// TestGateway 测试通过 WebSocket、SSE 和 HTTP 发布订阅
+func TestGateway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, psubs := getNetwork(t, ctx)
+	gw, err := New(psubs[0], "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gw.Close()
+	base := "http://" + gw.Addr().String()
+
+	topic, err := psubs[1].Join("chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// WebSocket 客户端订阅
+	ws, _, err := websocket.DefaultDialer.Dial("ws://"+gw.Addr().String()+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	if err := ws.WriteJSON(&Frame{Type: FrameSubscribe, Topic: "chat"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// SSE 客户端订阅
+	resp, err := http.Get(base + "/subscribe?topic=chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected event stream, got %q", ct)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+
+	// 远端节点发布的消息投递给两个客户端
+	if err := topic.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var f Frame
+	if err := ws.ReadJSON(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != FrameMessage || f.Topic != "chat" || string(f.Data) != "hello" || f.From != hosts[1].ID().String() {
+		t.Fatalf("unexpected websocket frame: %+v", f)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var data string
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data: ") {
+			data = strings.TrimPrefix(line, "data: ")
+			break
+		}
+	}
+	if err := json.Unmarshal([]byte(data), &f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != FrameMessage || string(f.Data) != "hello" {
+		t.Fatalf("unexpected SSE frame: %+v", f)
+	}
+
+	next := func() string {
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		defer tcancel()
+		msg, err := sub.Next(tctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(msg.Data)
+	}
+
+	// 通过 WebSocket 发布
+	if err := ws.WriteJSON(&Frame{Type: FramePublish, Topic: "chat", Data: []byte("from-ws")}); err != nil {
+		t.Fatal(err)
+	}
+	if data := next(); data != "from-ws" {
+		t.Fatalf("expected websocket publication, got %q", data)
+	}
+
+	// 通过 HTTP 发布
+	body, _ := json.Marshal(&Frame{Type: FramePublish, Topic: "chat", Data: []byte("from-http")})
+	presp, err := http.Post(base+"/publish", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	presp.Body.Close()
+	if presp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", presp.StatusCode)
+	}
+	if data := next(); data != "from-http" {
+		t.Fatalf("expected HTTP publication, got %q", data)
+	}
+
+	// 无效的帧返回错误帧
+	if err := ws.WriteMessage(websocket.TextMessage, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.ReadJSON(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != FrameError {
+		t.Fatalf("expected error frame, got %+v", f)
+	}
+	if err := ws.WriteJSON(&Frame{Type: FramePublish}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.ReadJSON(&f); err != nil {
+		t.Fatal(err)
+	}
+	if f.Type != FrameError {
+		t.Fatalf("expected error frame for missing topic, got %+v", f)
+	}
+
+	presp, err = http.Post(base+"/publish", "application/json", strings.NewReader("not json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	presp.Body.Close()
+	if presp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", presp.StatusCode)
+	}
+
+	// 关闭网关会断开客户端并释放主题
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[0].Join("chat"); err != nil {
+		t.Fatalf("expected gateway topic to be released: %s", err)
+	}
+}
+
+// TestGatewayTopicLimits 测试网关的主题允许名单和主题数量上限
+func TestGatewayTopicLimits(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, psubs := getNetwork(t, ctx)
+	if _, err := New(psubs[0], "127.0.0.1:0", WithMaxTopics(0)); err == nil {
+		t.Fatal("expected error for a non-positive topic limit")
+	}
+	gw, err := New(psubs[0], "127.0.0.1:0", WithTopicAllowlist("a", "b", "c"), WithMaxTopics(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gw.Close()
+	base := "http://" + gw.Addr().String()
+
+	publish := func(topic string) int {
+		body, _ := json.Marshal(&Frame{Type: FramePublish, Topic: topic, Data: []byte("data")})
+		resp, err := http.Post(base+"/publish", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := publish("x"); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a topic outside the allowlist, got %d", status)
+	}
+	for _, topic := range []string{"a", "b", "a"} {
+		if status := publish(topic); status != http.StatusNoContent {
+			t.Fatalf("expected 204 for topic %s, got %d", topic, status)
+		}
+	}
+	if status := publish("c"); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 once the topic limit is reached, got %d", status)
+	}
+}
@@ -26,6 +26,12 @@ type gossipTracer struct {
 	// 每个对等节点的承诺；对于每个对等节点，我们跟踪承诺的消息ID。
 	// 这个索引允许我们在对等节点被限制时快速取消承诺。
 	peerPromises map[peer.ID]map[string]struct{}
+
+	// 我们发出的所有 IWANT 请求；对于每个消息ID，跟踪向每个对等节点请求的时间。
+	// 与承诺不同，这里跟踪全部请求，用于计算 IWANT 响应评分。
+	iwants map[string]map[peer.ID]time.Time
+	// 自上次统计以来，每个对等节点及时响应的 IWANT 请求数量
+	iwantFulfilled map[peer.ID]int
 }
 
 // newGossipTracer 创建一个新的 gossipTracer 实例
@@ -33,9 +39,11 @@ type gossipTracer struct {
 //   - *gossipTracer: 新创建的 gossipTracer 实例
 func newGossipTracer() *gossipTracer {
 	return &gossipTracer{
-		idGen:        newMsgIdGenerator(),                    // 初始化消息ID生成器
-		promises:     make(map[string]map[peer.ID]time.Time), // 初始化 promises 映射
-		peerPromises: make(map[peer.ID]map[string]struct{}),  // 初始化 peerPromises 映射
+		idGen:          newMsgIdGenerator(),                    // 初始化消息ID生成器
+		promises:       make(map[string]map[peer.ID]time.Time), // 初始化 promises 映射
+		peerPromises:   make(map[peer.ID]map[string]struct{}),  // 初始化 peerPromises 映射
+		iwants:         make(map[string]map[peer.ID]time.Time), // 初始化 iwants 映射
+		iwantFulfilled: make(map[peer.ID]int),                  // 初始化 iwantFulfilled 映射
 	}
 }
 
@@ -68,6 +76,19 @@ func (gt *gossipTracer) AddPromise(p peer.ID, msgIDs []string) {
 	gt.Lock() // 加锁以确保并发安全
 	defer gt.Unlock()
 
+	// 记录全部 IWANT 请求，用于计算 IWANT 响应评分
+	now := time.Now()
+	for _, id := range msgIDs {
+		iwants, ok := gt.iwants[id]
+		if !ok {
+			iwants = make(map[peer.ID]time.Time)
+			gt.iwants[id] = iwants
+		}
+		if _, ok := iwants[p]; !ok {
+			iwants[p] = now
+		}
+	}
+
 	// 获取该消息ID的承诺映射
 	promises, ok := gt.promises[mid]
 	if !ok {
@@ -138,6 +159,43 @@ func (gt *gossipTracer) GetBrokenPromises() map[peer.ID]int {
 	return res // 返回违约承诺的映射
 }
 
+// GetIWantFulfillment 返回自上次调用以来每个对等节点及时响应和未响应的 IWANT 请求数量
+// 返回值:
+//   - map[peer.ID]int: 每个对等节点及时响应的请求数量
+//   - map[peer.ID]int: 每个对等节点超过跟进时间仍未响应的请求数量
+func (gt *gossipTracer) GetIWantFulfillment() (map[peer.ID]int, map[peer.ID]int) {
+	// 如果跟踪器为空，则返回 nil
+	if gt == nil {
+		return nil, nil
+	}
+
+	gt.Lock() // 加锁以确保并发安全
+	defer gt.Unlock()
+
+	fulfilled := gt.iwantFulfilled
+	gt.iwantFulfilled = make(map[peer.ID]int)
+
+	var unfulfilled map[peer.ID]int
+	now := time.Now()
+	for mid, iwants := range gt.iwants {
+		for p, requested := range iwants {
+			if now.Sub(requested) > gt.followUpTime {
+				if unfulfilled == nil {
+					unfulfilled = make(map[peer.ID]int)
+				}
+				unfulfilled[p]++
+				delete(iwants, p)
+			}
+		}
+
+		if len(iwants) == 0 {
+			delete(gt.iwants, mid)
+		}
+	}
+
+	return fulfilled, unfulfilled
+}
+
 // fulfillPromise 方法履行消息的承诺
 // 参数:
 //   - msg: 消息
@@ -147,6 +205,14 @@ func (gt *gossipTracer) fulfillPromise(msg *Message) {
 	gt.Lock() // 加锁以确保并发安全
 	defer gt.Unlock()
 
+	// 如果消息来自我们请求过的对等节点，并且在跟进时间内到达，则记为及时响应
+	if iwants, ok := gt.iwants[mid]; ok {
+		if requested, ok := iwants[msg.ReceivedFrom]; ok && time.Since(requested) <= gt.followUpTime {
+			gt.iwantFulfilled[msg.ReceivedFrom]++
+		}
+		delete(gt.iwants, mid) // 消息已收到，其他对等节点无需再响应
+	}
+
 	promises, ok := gt.promises[mid] // 查找该消息的承诺映射
 	if !ok {
 		return // 如果没有找到承诺，则直接返回
@@ -213,6 +279,14 @@ func (gt *gossipTracer) ThrottlePeer(p peer.ID) {
 	gt.Lock() // 加锁以确保并发安全
 	defer gt.Unlock()
 
+	// 被限制的对等节点的消息不会被验证，因此不计入未响应的 IWANT 请求
+	for mid, iwants := range gt.iwants {
+		delete(iwants, p)
+		if len(iwants) == 0 {
+			delete(gt.iwants, mid)
+		}
+	}
+
 	peerPromises, ok := gt.peerPromises[p] // 获取对等节点的消息ID集合映射
 	if !ok {
 		return // 如果对等节点没有承诺，则直接返回
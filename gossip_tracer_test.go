@@ -108,3 +108,54 @@ func TestNoBrokenPromises(t *testing.T) {
 		t.Fatal("expected empty peerPromises map")
 	}
 }
+
+func TestIWantFulfillment(t *testing.T) {
+	// 测试 IWANT 请求的及时响应和未响应是否被正确统计
+	gt := newGossipTracer()
+	gt.followUpTime = 100 * time.Millisecond
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	// 创建10个测试消息
+	var msgs []*pb.Message
+	var mids []string
+	for i := 0; i < 10; i++ {
+		m := makeTestMessage(i)
+		m.From = []byte(peerA)
+		msgs = append(msgs, m)
+		mids = append(mids, DefaultMsgIdFn(m))
+	}
+
+	// 向两个对等节点请求全部消息
+	gt.AddPromise(peerA, mids)
+	gt.AddPromise(peerB, mids)
+
+	// peerA 响应前5条消息
+	for _, m := range msgs[:5] {
+		gt.ValidateMessage(&Message{Message: m, ReceivedFrom: peerA})
+	}
+
+	time.Sleep(gt.followUpTime + time.Millisecond)
+
+	fulfilled, unfulfilled := gt.GetIWantFulfillment()
+	if fulfilled[peerA] != 5 {
+		t.Fatalf("expected 5 fulfilled IWANTs from A, got %d", fulfilled[peerA])
+	}
+	if fulfilled[peerB] != 0 {
+		t.Fatalf("expected 0 fulfilled IWANTs from B, got %d", fulfilled[peerB])
+	}
+	// 剩余5条消息两个对等节点都未响应
+	if unfulfilled[peerA] != 5 || unfulfilled[peerB] != 5 {
+		t.Fatalf("expected 5 unfulfilled IWANTs from A and B, got %d and %d", unfulfilled[peerA], unfulfilled[peerB])
+	}
+
+	// 统计后计数器被重置
+	fulfilled, unfulfilled = gt.GetIWantFulfillment()
+	if len(fulfilled) != 0 || len(unfulfilled) != 0 {
+		t.Fatal("expected counters to be reset")
+	}
+	if len(gt.iwants) != 0 {
+		t.Fatal("expected empty iwants map")
+	}
+}
@@ -212,6 +212,10 @@ type GossipSubParams struct {
 	// MaxIHaveMessages 是在一个心跳内从节点接受的 IHAVE 消息的最大数量。
 	MaxIHaveMessages int
 
+	// MaxIWantLength 是在一个心跳内跟进单个节点的 IHAVE 广告、通过 IWANT 请求的最大消息数量。
+	// 为 0 时使用 MaxIHaveLength。
+	MaxIWantLength int
+
 	// IWantFollowupTime 是在 IHAVE 广告后等待通过 IWANT 请求的消息的时间。
 	// 如果在此窗口内未收到消息，则声明违反承诺，路由器可能会应用行为惩罚。
 	IWantFollowupTime time.Duration
@@ -228,7 +232,7 @@ type GossipSubParams struct {
 //   - error: 错误信息
 func NewGossipSub(ctx context.Context, h host.Host, opts ...Option) (*PubSub, error) {
 	rt := DefaultGossipSubRouter(h)
-	opts = append(opts, WithRawTracer(rt.tagTracer))
+	opts = append(opts, withInternalRawTracer(rt.tagTracer))
 	return NewGossipSubWithRouter(ctx, h, rt, opts...)
 }
 
@@ -255,23 +259,31 @@ func NewGossipSubWithRouter(ctx context.Context, h host.Host, rt PubSubRouter, o
 func DefaultGossipSubRouter(h host.Host) *GossipSubRouter {
 	params := DefaultGossipSubParams()
 	return &GossipSubRouter{
-		peers:     make(map[peer.ID]protocol.ID),
-		mesh:      make(map[string]map[peer.ID]struct{}),
-		fanout:    make(map[string]map[peer.ID]struct{}),
-		lastpub:   make(map[string]int64),
-		gossip:    make(map[peer.ID][]*pb.ControlIHave),
-		control:   make(map[peer.ID]*pb.ControlMessage),
-		backoff:   make(map[string]map[peer.ID]time.Time),
-		peerhave:  make(map[peer.ID]int),
-		iasked:    make(map[peer.ID]int),
-		outbound:  make(map[peer.ID]bool),
-		connect:   make(chan connectInfo, params.MaxPendingConnections),
-		cab:       pstoremem.NewAddrBook(),
-		mcache:    NewMessageCache(params.HistoryGossip, params.HistoryLength),
-		protos:    GossipSubDefaultProtocols,
-		feature:   GossipSubDefaultFeatures,
-		tagTracer: newTagTracer(h.ConnManager()),
-		params:    params,
+		peers:          make(map[peer.ID]protocol.ID),
+		mesh:           make(map[string]map[peer.ID]struct{}),
+		fanout:         make(map[string]map[peer.ID]struct{}),
+		lastpub:        make(map[string]int64),
+		gossip:         make(map[peer.ID][]*pb.ControlIHave),
+		control:        make(map[peer.ID]*pb.ControlMessage),
+		backoff:        make(map[string]map[peer.ID]time.Time),
+		directAttempts: make(map[peer.ID]int),
+		directNext:     make(map[peer.ID]time.Time),
+		draining:       make(map[string]struct{}),
+		peerhave:       make(map[peer.ID]int),
+		iasked:         make(map[peer.ID]int),
+		iwantPending:   make(map[string]*iwantRequest),
+		iwantCancelled: make(map[string]time.Time),
+		iwantPenalty:   1,
+		handoffMesh:    make(map[string][]peer.ID),
+		outbound:       make(map[peer.ID]bool),
+		connect:        make(chan connectInfo, params.MaxPendingConnections),
+		cab:            pstoremem.NewAddrBook(),
+		mcache:         NewMessageCache(params.HistoryGossip, params.HistoryLength),
+		protos:         GossipSubDefaultProtocols,
+		feature:        GossipSubDefaultFeatures,
+		tagTracer:      newTagTracer(h.ConnManager()),
+		params:         params,
+		heartbeatReset: make(chan time.Duration, 1),
 	}
 }
 
@@ -351,12 +363,13 @@ func WithPeerScore(params *PeerScoreParams, thresholds *PeerScoreThresholds) Opt
 
 		// 挂钩追踪器
 		if ps.tracer != nil { // 如果 pubsub 中已有追踪器，则添加新的追踪器。
-			ps.tracer.raw = append(ps.tracer.raw, gs.score, gs.gossipTracer) // 将 score 和 gossipTracer 添加到追踪器列表中。
+			ps.tracer.addInternalRaw(gs.score, gs.gossipTracer) // 将 score 和 gossipTracer 添加到追踪器列表中。
 		} else { // 如果没有现有的追踪器。
 			ps.tracer = &pubsubTracer{ // 创建一个新的 pubsubTracer 实例。
-				raw:   []RawTracer{gs.score, gs.gossipTracer}, // 初始化追踪器列表。
-				pid:   ps.host.ID(),                           // 设置主机 ID。
-				idGen: ps.idGen,                               // 设置 ID 生成器。
+				raw:      []RawTracer{gs.score, gs.gossipTracer}, // 初始化追踪器列表。
+				internal: []RawTracer{gs.score, gs.gossipTracer}, // 路由器内部使用的追踪器。
+				pid:      ps.host.ID(),                           // 设置主机 ID。
+				idGen:    ps.idGen,                               // 设置 ID 生成器。
 			}
 		}
 
@@ -452,6 +465,223 @@ func WithDirectConnectTicks(t uint64) Option {
 	}
 }
 
+// WithPruneBackoff 是一个 gossipsub 路由器选项，用于设置修剪对等节点后的回退时间。
+// 较短的回退时间可以加快网格的自我修复，较长的回退时间可以抑制 GRAFT/PRUNE 风暴。
+// 注意：该选项需要在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - d: time.Duration 类型，表示修剪回退时间，必须大于 0。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithPruneBackoff(d time.Duration) Option {
+	return func(ps *PubSub) error { // 返回的函数，接收 *PubSub 类型参数并返回 error。
+		gs, ok := ps.rt.(*GossipSubRouter) // 类型断言，检查路由器是否为 GossipSubRouter 类型。
+		if !ok {                           // 如果断言失败，表示路由器不是 gossipsub 类型。
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")      // 返回错误，说明当前路由器不是 gossipsub。
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型") // 返回错误，说明当前路由器不是 gossipsub。
+		}
+		if d <= 0 {
+			logger.Warnf("修剪回退时间必须大于 0: %v", d)
+			return fmt.Errorf("修剪回退时间必须大于 0: %v", d)
+		}
+		gs.params.PruneBackoff = d // 设置修剪回退时间。
+		return nil                 // 返回 nil，表示没有错误。
+	}
+}
+
+// WithUnsubscribeBackoff 是一个 gossipsub 路由器选项，用于设置取消订阅主题时使用的回退时间。
+// 注意：该选项需要在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - d: time.Duration 类型，表示取消订阅回退时间，必须大于 0。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithUnsubscribeBackoff(d time.Duration) Option {
+	return func(ps *PubSub) error { // 返回的函数，接收 *PubSub 类型参数并返回 error。
+		gs, ok := ps.rt.(*GossipSubRouter) // 类型断言，检查路由器是否为 GossipSubRouter 类型。
+		if !ok {                           // 如果断言失败，表示路由器不是 gossipsub 类型。
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")      // 返回错误，说明当前路由器不是 gossipsub。
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型") // 返回错误，说明当前路由器不是 gossipsub。
+		}
+		if d <= 0 {
+			logger.Warnf("取消订阅回退时间必须大于 0: %v", d)
+			return fmt.Errorf("取消订阅回退时间必须大于 0: %v", d)
+		}
+		gs.params.UnsubscribeBackoff = d // 设置取消订阅回退时间。
+		return nil                       // 返回 nil，表示没有错误。
+	}
+}
+
+// WithOpportunisticGraftTicks 是一个 gossipsub 路由器选项，用于设置尝试机会性 GRAFT 的心跳滴答间隔。
+// 间隔越小，低中位评分的网格刷新得越积极。
+// 注意：该选项需要在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - ticks: uint64 类型，表示心跳滴答次数，必须大于 0。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithOpportunisticGraftTicks(ticks uint64) Option {
+	return func(ps *PubSub) error { // 返回的函数，接收 *PubSub 类型参数并返回 error。
+		gs, ok := ps.rt.(*GossipSubRouter) // 类型断言，检查路由器是否为 GossipSubRouter 类型。
+		if !ok {                           // 如果断言失败，表示路由器不是 gossipsub 类型。
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")      // 返回错误，说明当前路由器不是 gossipsub。
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型") // 返回错误，说明当前路由器不是 gossipsub。
+		}
+		if ticks == 0 {
+			logger.Warnf("机会性 GRAFT 心跳滴答次数必须大于 0")
+			return fmt.Errorf("机会性 GRAFT 心跳滴答次数必须大于 0")
+		}
+		gs.params.OpportunisticGraftTicks = ticks // 设置机会性 GRAFT 的心跳滴答间隔。
+		return nil                                // 返回 nil，表示没有错误。
+	}
+}
+
+// WithOpportunisticGraftPeers 是一个 gossipsub 路由器选项，用于设置每次机会性 GRAFT 的对等节点数量。
+// 注意：该选项需要在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - n: int 类型，表示对等节点数量，必须大于 0。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithOpportunisticGraftPeers(n int) Option {
+	return func(ps *PubSub) error { // 返回的函数，接收 *PubSub 类型参数并返回 error。
+		gs, ok := ps.rt.(*GossipSubRouter) // 类型断言，检查路由器是否为 GossipSubRouter 类型。
+		if !ok {                           // 如果断言失败，表示路由器不是 gossipsub 类型。
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")      // 返回错误，说明当前路由器不是 gossipsub。
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型") // 返回错误，说明当前路由器不是 gossipsub。
+		}
+		if n <= 0 {
+			logger.Warnf("机会性 GRAFT 对等节点数量必须大于 0: %d", n)
+			return fmt.Errorf("机会性 GRAFT 对等节点数量必须大于 0: %d", n)
+		}
+		gs.params.OpportunisticGraftPeers = n // 设置机会性 GRAFT 的对等节点数量。
+		return nil                            // 返回 nil，表示没有错误。
+	}
+}
+
+// WithGraftRampUp 限制新启动节点主动 GRAFT 网格对等节点的速度。
+// 在启动后的前 ticks 个心跳内（包括第一次心跳之前加入的主题），所有主题每个心跳合计最多主动 GRAFT limit 个对等节点，
+// 网格在多个心跳内逐步增长到 D，从而平滑大型节点重启后在大量主题上立即 GRAFT 造成的连接和带宽尖峰。
+// 对等节点主动发起的 GRAFT 不受限制。
+// 参数:
+//   - limit: int 类型，爬坡期内每个心跳的 GRAFT 上限。
+//   - ticks: uint64 类型，爬坡期的心跳数。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithGraftRampUp(limit int, ticks uint64) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if limit <= 0 {
+			logger.Warnf("每个心跳的 GRAFT 上限必须大于 0: %d", limit)
+			return fmt.Errorf("每个心跳的 GRAFT 上限必须大于 0: %d", limit)
+		}
+		if ticks == 0 {
+			logger.Warnf("爬坡期的心跳数必须大于 0")
+			return fmt.Errorf("爬坡期的心跳数必须大于 0")
+		}
+		gs.graftRampLimit = limit
+		gs.graftRampTicks = ticks
+		gs.graftBudget = limit // 第一次心跳之前加入主题时使用的配额
+		return nil
+	}
+}
+
+// WithGossipHistory 设置消息缓存的窗口大小。
+// 慢速网络上可以增大历史窗口以减少 IWANT 未命中，快速网络上可以减小窗口以避免过度保留消息。
+// 注意：必须在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - gossipWindow: int 类型，发送 IHAVE 时包含的历史心跳数（HistoryGossip）。
+//   - historyWindow: int 类型，消息缓存保留的历史心跳数（HistoryLength），不能小于 gossipWindow。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithGossipHistory(gossipWindow, historyWindow int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if gossipWindow <= 0 {
+			logger.Warnf("gossip 窗口必须大于 0: %d", gossipWindow)
+			return fmt.Errorf("gossip 窗口必须大于 0: %d", gossipWindow)
+		}
+		if historyWindow < gossipWindow {
+			logger.Warnf("历史窗口 (%d) 不能小于 gossip 窗口 (%d)", historyWindow, gossipWindow)
+			return fmt.Errorf("历史窗口 (%d) 不能小于 gossip 窗口 (%d)", historyWindow, gossipWindow)
+		}
+		gs.params.HistoryGossip = gossipWindow
+		gs.params.HistoryLength = historyWindow
+		gs.mcache = NewMessageCache(gossipWindow, historyWindow)
+		return nil
+	}
+}
+
+// WithIHaveBudget 设置每个对等节点的 IHAVE/IWANT 预算。
+// 注意：必须在 WithGossipSubParams 之后应用，否则会被覆盖。
+// 参数:
+//   - maxIHaveMessages: int 类型，一个心跳内从单个对等节点接受的 IHAVE 消息数量上限（MaxIHaveMessages）。
+//   - maxIWantLength: int 类型，一个心跳内向单个对等节点请求的消息数量上限（MaxIWantLength）。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithIHaveBudget(maxIHaveMessages, maxIWantLength int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if maxIHaveMessages <= 0 {
+			logger.Warnf("IHAVE 消息数量上限必须大于 0: %d", maxIHaveMessages)
+			return fmt.Errorf("IHAVE 消息数量上限必须大于 0: %d", maxIHaveMessages)
+		}
+		if maxIWantLength <= 0 {
+			logger.Warnf("IWANT 请求数量上限必须大于 0: %d", maxIWantLength)
+			return fmt.Errorf("IWANT 请求数量上限必须大于 0: %d", maxIWantLength)
+		}
+		gs.params.MaxIHaveMessages = maxIHaveMessages
+		gs.params.MaxIWantLength = maxIWantLength
+		return nil
+	}
+}
+
+// WithIWantFollowup 设置 IWANT 跟进窗口和跟进失败的行为惩罚。
+// 高延迟链路上可以增大窗口，避免合法但迟到的响应被记为违约；
+// 每次跟进失败会向对等节点的 BehaviourPenalty 计入 penalty 次惩罚，penalty 为 0 时不惩罚。
+// 注意：必须在 WithGossipSubParams 之后应用，否则窗口会被覆盖。
+// 参数:
+//   - window: time.Duration 类型，IHAVE 广告后等待 IWANT 响应的时间（IWantFollowupTime）。
+//   - penalty: int 类型，每次跟进失败计入的行为惩罚次数。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithIWantFollowup(window time.Duration, penalty int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if window <= 0 {
+			logger.Warnf("IWANT 跟进窗口必须大于 0: %v", window)
+			return fmt.Errorf("IWANT 跟进窗口必须大于 0: %v", window)
+		}
+		if penalty < 0 {
+			logger.Warnf("IWANT 跟进惩罚不能为负数: %d", penalty)
+			return fmt.Errorf("IWANT 跟进惩罚不能为负数: %d", penalty)
+		}
+		gs.params.IWantFollowupTime = window
+		gs.iwantPenalty = penalty
+		return nil
+	}
+}
+
 // WithGossipSubParams 是一个 gossipsub 路由器选项，允许在实例化 gossipsub 路由器时设置自定义配置。
 // 参数:
 //   - cfg: GossipSubParams 类型，表示 gossipsub 参数配置。
@@ -491,6 +721,7 @@ type GossipSubRouter struct {
 	iasked   map[peer.ID]int                  // 在最后一个心跳中我们从对等节点请求的消息数量
 	outbound map[peer.ID]bool                 // 连接方向缓存，标记具有出站连接的对等节点
 	backoff  map[string]map[peer.ID]time.Time // 修剪回退
+	draining map[string]struct{}              // 正在排空的主题
 	connect  chan connectInfo                 // px 连接请求
 	cab      peerstore.AddrBook               // 地址簿
 
@@ -533,6 +764,55 @@ type GossipSubRouter struct {
 
 	// 从开始的心跳滴答数；这允许我们摊销一些资源清理操作，例如回退清理。
 	heartbeatTicks uint64
+
+	// 最近一次心跳的调度延迟和执行时间，用于诊断
+	heartbeatLatency  time.Duration
+	heartbeatDuration time.Duration
+
+	// 运行时修改心跳间隔的请求，由心跳计时器协程接收
+	heartbeatReset chan time.Duration
+
+	// 启动后的网格增长限制：在前 graftRampTicks 个心跳内，每个心跳最多主动 GRAFT graftRampLimit 个对等节点。
+	graftRampLimit int    // 爬坡期内每个心跳的 GRAFT 上限，0 表示不限制
+	graftRampTicks uint64 // 爬坡期的心跳数
+	graftBudget    int    // 当前心跳剩余的 GRAFT 配额
+
+	// IWANT 服务的消息缓存命中统计
+	iwantHits   uint64 // 在消息缓存中找到的 IWANT 请求消息数
+	iwantMisses uint64 // 消息缓存中已不存在的 IWANT 请求消息数
+
+	// 进行中和已取消的 IWANT 请求
+	iwantPending   map[string]*iwantRequest // 按消息 ID 记录进行中的 IWANT 请求
+	iwantCancelled map[string]time.Time     // 按消息 ID 记录被取消的 IWANT 请求及取消时间
+
+	// 每次 IWANT 跟进失败计入的行为惩罚次数，0 表示不惩罚
+	iwantPenalty int
+
+	// 防 spam 硬化，为 nil 时不启用
+	hardening *spamHardening
+
+	// 混合路由阈值：主题的对等节点数低于该值时洪泛，0 表示不启用
+	hybridThreshold int
+
+	// 选择 GRAFT 对等节点时往返时间的权重，0 表示不考虑延迟
+	latencyWeight float64
+
+	// 网格成员的 ASN 和区域多样性约束，为 nil 时不启用
+	diversity *meshDiversity
+
+	// 在连接管理器中受保护的网格出站对等节点，为 nil 时不保护
+	outboundProtected map[peer.ID]struct{}
+
+	// 进程交接前每个主题的网格对等节点，在重新加入主题时优先使用
+	handoffMesh map[string][]peer.ID
+
+	// 配置了退避策略时，直接对等节点连续重新连接的次数和下一次允许重新连接的时间
+	directAttempts map[peer.ID]int
+	directNext     map[peer.ID]time.Time
+
+	// 运行时停止的子系统
+	gossipStopped bool // 停止发出 gossip
+	pxStopped     bool // 停止处理收到的 PX
 }
 
 // connectInfo 是连接信息结构体。
@@ -566,6 +846,7 @@ func (gs *GossipSubRouter) Attach(p *PubSub) {
 
 	// 开始使用与 PubSub 相同的消息 ID 函数来缓存消息。
 	gs.mcache.SetMsgIdFn(p.idGen.ID)
+	gs.mcache.budget = p.memBudget
 
 	// 启动心跳
 	go gs.heartbeatTimer()
@@ -662,7 +943,7 @@ loop: // 标签，用于在找到符合条件的连接时跳出循环。
 		if stat.Direction == network.DirOutbound { // 如果连接方向为出站。
 			// 仅在连接具有 pubsub 流时计算连接
 			for _, s := range c.GetStreams() { // 遍历连接中的所有流。
-				if s.Protocol() == proto { // 如果流的协议与指定协议匹配。
+				if gs.p.routerProtocol(s.Protocol()) == proto { // 如果流的协议与指定协议匹配。
 					outbound = true // 设置 outbound 为 true，表示此连接为出站连接。
 					break loop      // 跳出循环，不再检查其他连接。
 				}
@@ -670,17 +951,22 @@ loop: // 标签，用于在找到符合条件的连接时跳出循环。
 		}
 	}
 	gs.outbound[p] = outbound // 将连接方向信息记录到 outbound 映射中。
+
+	gs.hardening.addPeer(gs, p) // 检查对等节点所在 IP 上的对等节点 ID 抖动。
 }
 
 // RemovePeer 移除对等节点。
 // 参数:
 //   - p: peer.ID 类型，表示对等节点的 ID。
 func (gs *GossipSubRouter) RemovePeer(p peer.ID) {
-	logger.Debugf("对等节点下线: %s", p)  // 记录移除对等节点的调试信息。
-	gs.tracer.RemovePeer(p)         // 调用 tracer 的 RemovePeer 方法，记录对等节点的移除信息。
-	delete(gs.peers, p)             // 从 peers 映射中删除对等节点。
-	for _, peers := range gs.mesh { // 遍历所有 mesh 主题的对等节点集合。
-		delete(peers, p) // 从每个主题的对等节点集合中删除指定对等节点。
+	logger.Debugf("对等节点下线: %s", p)      // 记录移除对等节点的调试信息。
+	gs.tracer.RemovePeer(p)             // 调用 tracer 的 RemovePeer 方法，记录对等节点的移除信息。
+	delete(gs.peers, p)                 // 从 peers 映射中删除对等节点。
+	for topic, peers := range gs.mesh { // 遍历所有 mesh 主题的对等节点集合。
+		if _, ok := peers[p]; ok {
+			gs.p.notifyTopicEvent(topic, MeshPruned, p) // 通知主题事件处理程序。
+			delete(peers, p)                            // 从每个主题的对等节点集合中删除指定对等节点。
+		}
 	}
 	for _, peers := range gs.fanout { // 遍历所有 fanout 主题的对等节点集合。
 		delete(peers, p) // 从每个主题的对等节点集合中删除指定对等节点。
@@ -765,6 +1051,7 @@ func (gs *GossipSubRouter) HandleRPC(rpc *RPC) {
 	}
 
 	out := rpcWithControl(ihave, nil, iwant, nil, prune) // 构造带有控制消息的 RPC 消息。
+	out.unshaped = true                                  // IWANT 回复兑现已发出的 IHAVE，丢弃会被对方按未兑现的承诺惩罚
 	gs.sendRPC(rpc.from, out)                            // 发送 RPC 消息到发送者。
 }
 
@@ -789,12 +1076,13 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		logger.Debugf("IHAVE: 对等节点 %s 在心跳间隔内广告了太多消息 (%d); 忽略", p, gs.peerhave[p]) // 记录忽略消息的调试信息。
 		return nil                                                                // 返回空列表。
 	}
-	if gs.iasked[p] >= gs.params.MaxIHaveLength { // 如果已请求的消息数量超过了限制，忽略此消息。
+	maxIWant := gs.maxIWantLength()
+	if gs.iasked[p] >= maxIWant { // 如果已请求的消息数量超过了限制，忽略此消息。
 		logger.Debugf("IHAVE: 对等节点 %s 已经广告了太多消息 (%d); 忽略", p, gs.iasked[p]) // 记录忽略消息的调试信息。
 		return nil                                                          // 返回空列表。
 	}
 
-	iwant := make(map[string]struct{})     // 创建一个空的 map，用于存储请求的消息 ID。
+	iwant := make(map[string]string)       // 创建一个空的 map，用于存储请求的消息 ID 及其主题。
 	for _, ihave := range ctl.GetIhave() { // 遍历 IHAVE 控制消息中的消息 ID 列表。
 		topic := ihave.GetTopicID() // 获取消息所属的主题 ID。
 		_, ok := gs.mesh[topic]     // 检查主题是否在 mesh 中。
@@ -817,7 +1105,10 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			if gs.p.seenMessage(mid) { // 如果消息 ID 已被看到，跳过此消息。
 				continue
 			}
-			iwant[mid] = struct{}{} // 将消息 ID 添加到请求列表中。
+			if _, ok := gs.iwantCancelled[mid]; ok { // 如果请求已被应用取消，跳过此消息。
+				continue
+			}
+			iwant[mid] = topic // 将消息 ID 添加到请求列表中。
 		}
 	}
 
@@ -825,9 +1116,9 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		return nil
 	}
 
-	iask := len(iwant)                                // 获取需要请求的消息数量。
-	if iask+gs.iasked[p] > gs.params.MaxIHaveLength { // 如果请求的消息数量超过了限制，截断请求列表。
-		iask = gs.params.MaxIHaveLength - gs.iasked[p]
+	iask := len(iwant)                // 获取需要请求的消息数量。
+	if iask+gs.iasked[p] > maxIWant { // 如果请求的消息数量超过了限制，截断请求列表。
+		iask = maxIWant - gs.iasked[p]
 	}
 
 	logger.Debugf("IHAVE: 向对等节点 %s 请求 %d 条消息中的 %d 条", p, len(iwant), iask) // 记录请求消息的调试信息。
@@ -845,10 +1136,21 @@ func (gs *GossipSubRouter) handleIHave(p peer.ID, ctl *pb.ControlMessage) []*pb.
 	gs.iasked[p] += iask       // 更新已请求消息的计数器。
 
 	gs.gossipTracer.AddPromise(p, iwantlst) // 将请求的消息 ID 添加到 gossip 追踪器的承诺列表中。
+	gs.trackIWant(p, iwantlst, iwant)       // 记录进行中的 IWANT 请求。
 
 	return []*pb.ControlIWant{{MessageIDs: iwantlst}} // 返回构造的 IWANT 控制消息列表。
 }
 
+// maxIWantLength 返回在一个心跳内向单个对等节点请求的最大消息数量。
+// 返回值:
+//   - int: 最大消息数量。
+func (gs *GossipSubRouter) maxIWantLength() int {
+	if gs.params.MaxIWantLength > 0 {
+		return gs.params.MaxIWantLength
+	}
+	return gs.params.MaxIHaveLength
+}
+
 // handleIWant 处理 IWANT 控制消息。
 // 参数:
 //   - p: peer.ID 类型，表示对等节点的 ID。
@@ -869,8 +1171,10 @@ func (gs *GossipSubRouter) handleIWant(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		for _, mid := range iwant.GetMessageIDs() { // 遍历每个消息 ID。
 			msg, count, ok := gs.mcache.GetForPeer(mid, p) // 从消息缓存中获取对应的消息及其请求计数。
 			if !ok {                                       // 如果消息不在缓存中，跳过此消息。
+				gs.iwantMisses++ // 记录缓存未命中。
 				continue
 			}
+			gs.iwantHits++ // 记录缓存命中。
 
 			if !gs.p.peerFilter(p, msg.GetTopic()) { // 如果对等节点不符合主题的过滤条件，跳过此消息。
 				continue
@@ -928,12 +1232,29 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue // 跳过此节点。
 		}
 
+		// 检查对等节点是否有权加入主题。
+		if !gs.p.authorized(topic, p) {
+			logger.Debugf("GRAFT: 对等节点 %s 无权加入主题 %s", p, topic)
+			gs.p.penalizeUnauthorized(p)   // 可选的行为惩罚。
+			doPX = false                   // 禁用 PX。
+			gs.addBackoff(p, topic, false) // 添加或刷新回退时间。
+			prune = append(prune, topic)   // 将主题添加到 PRUNE 列表中。
+			continue                       // 跳过此节点。
+		}
+
 		// 检查是否已在网格中；如果是则不做任何操作（可能存在并发 grafting）。
 		_, inMesh := peers[p] // 检查对等节点是否已在网格中。
 		if inMesh {           // 如果对等节点已在网格中。
 			continue // 跳过此节点。
 		}
 
+		// 正在排空的主题不再接受新的网格链接。
+		if _, draining := gs.draining[topic]; draining {
+			prune = append(prune, topic) // 将主题添加到 PRUNE 列表中。
+			doPX = false                 // 禁用 PX。
+			continue                     // 跳过此节点。
+		}
+
 		// 我们不会对直接对等节点进行 GRAFT；如果发生这种情况，请大声抱怨。
 		_, direct := gs.direct[p] // 检查对等节点是否为直接对等节点。
 		if direct {               // 如果对等节点是直接对等节点。
@@ -950,14 +1271,14 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 		if backoff && now.Before(expire) {      // 如果对等节点处于回退期，并且当前时间在回退时间之前。
 			logger.Debugf("GRAFT: 忽略回退的对等节点 %s", p) // 记录调试信息，忽略此对等节点的 GRAFT 请求。
 			// 添加行为惩罚。
-			gs.score.AddPenalty(p, 1) // 对该对等节点添加惩罚。
+			gs.penalize(p, 1+gs.hardening.backoffPenalty(), PenaltyGraftBackoff) // 对该对等节点添加惩罚。
 			// 不进行 PX。
 			doPX = false // 禁用 PX。
 			// 检查 flood 截止点——GRAFT 是否来得太快？
 			floodCutoff := expire.Add(gs.params.GraftFloodThreshold - gs.params.PruneBackoff) // 计算 flood 截止时间。
 			if now.Before(floodCutoff) {                                                      // 如果当前时间在 flood 截止时间之前。
 				// 额外惩罚。
-				gs.score.AddPenalty(p, 1) // 对该对等节点添加额外的惩罚。
+				gs.penalize(p, 1, PenaltyGraftFlood) // 对该对等节点添加额外的惩罚。
 			}
 			// 刷新回退。
 			gs.addBackoff(p, topic, false) // 刷新回退时间。
@@ -965,6 +1286,16 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue                       // 跳过此节点。
 		}
 
+		// 防 spam 硬化：限制 GRAFT 速率。
+		if !gs.hardening.allowGraft(p, topic, now) {
+			logger.Debugf("GRAFT: 对等节点 %s 在主题 %s 上 GRAFT 过于频繁", p, topic)
+			gs.penalize(p, 1, PenaltyGraftRate) // 添加行为惩罚。
+			doPX = false                        // 禁用 PX。
+			gs.addBackoff(p, topic, false)      // 添加或刷新回退时间。
+			prune = append(prune, topic)        // 将主题添加到 PRUNE 列表中。
+			continue                            // 跳过此节点。
+		}
+
 		// 检查评分。
 		if score < 0 { // 如果对等节点的评分为负。
 			// 我们不会 GRAFT 评分为负的对等节点。
@@ -986,8 +1317,17 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 			continue                       // 跳过此节点。
 		}
 
+		// 检查网格多样性约束；来自同一 ASN 或区域的对等节点已达上限时拒绝。
+		if !gs.diversity.admit(gs.diversity.counts(peers), p) {
+			logger.Debugf("GRAFT: 对等节点 %s 所在的 ASN 或区域在主题 %s 的网格中已达上限", p, topic)
+			prune = append(prune, topic)   // 将主题添加到 PRUNE 列表中。
+			gs.addBackoff(p, topic, false) // 添加或刷新回退时间。
+			continue                       // 跳过此节点。
+		}
+
 		logger.Debugf("GRAFT: 从对等节点 %s 添加网格链接到主题 %s", p, topic) // 记录调试信息，添加对等节点到网格中。
 		gs.tracer.Graft(p, topic)                               // 记录 GRAFT 操作。
+		gs.p.notifyTopicEvent(topic, MeshGrafted, p)            // 通知主题事件处理程序。
 		peers[p] = struct{}{}                                   // 将对等节点添加到网格中。
 	}
 
@@ -997,7 +1337,11 @@ func (gs *GossipSubRouter) handleGraft(p peer.ID, ctl *pb.ControlMessage) []*pb.
 
 	cprune := make([]*pb.ControlPrune, 0, len(prune)) // 创建一个空的 PRUNE 控制消息列表。
 	for _, topic := range prune {                     // 遍历所有需要 PRUNE 的主题。
-		cprune = append(cprune, gs.makePrune(p, topic, doPX, false)) // 为每个主题创建 PRUNE 控制消息并添加到列表中。
+		cp := gs.makePrune(p, topic, doPX, false) // 为每个主题创建 PRUNE 控制消息。
+		if _, draining := gs.draining[topic]; draining {
+			cp.Reason = PruneReasonDraining // 告知对等节点我们即将离开。
+		}
+		cprune = append(cprune, cp) // 将 PRUNE 控制消息添加到列表中。
 	}
 
 	return cprune // 返回 PRUNE 控制消息列表。
@@ -1017,9 +1361,14 @@ func (gs *GossipSubRouter) handlePrune(p peer.ID, ctl *pb.ControlMessage) {
 			continue // 跳过此节点。
 		}
 
-		logger.Debugf("PRUNE: 从网格中移除对等节点 %s 到主题 %s", p, topic) // 记录调试信息，从网格中移除对等节点。
-		gs.tracer.Prune(p, topic)                              // 记录 PRUNE 操作。
-		delete(peers, p)                                       // 从网格中删除对等节点。
+		if reason := prune.GetReason(); reason != "" {
+			logger.Debugf("PRUNE: 从网格中移除对等节点 %s 到主题 %s [原因 = %s]", p, topic, reason) // 记录调试信息，包含 PRUNE 原因。
+		} else {
+			logger.Debugf("PRUNE: 从网格中移除对等节点 %s 到主题 %s", p, topic) // 记录调试信息，从网格中移除对等节点。
+		}
+		gs.tracer.Prune(p, topic)                   // 记录 PRUNE 操作。
+		gs.p.notifyTopicEvent(topic, MeshPruned, p) // 通知主题事件处理程序。
+		delete(peers, p)                            // 从网格中删除对等节点。
 		// 对等节点是否指定了回退时间？如果是，请遵守。
 		backoff := prune.GetBackoff() // 获取 PRUNE 消息中的回退时间。
 		if backoff > 0 {              // 如果回退时间大于 0。
@@ -1035,6 +1384,10 @@ func (gs *GossipSubRouter) handlePrune(p peer.ID, ctl *pb.ControlMessage) {
 				logger.Debugf("PRUNE: 忽略评分不足的对等节点 %s 的 PX [score = %f, topic = %s]", p, score, topic) // 记录调试信息，忽略该对等节点的 PX。
 				continue                                                                              // 跳过此节点。
 			}
+			if gs.pxStopped { // 如果 PX 子系统已停止。
+				logger.Debugf("PRUNE: PX 已停止; 忽略对等节点 %s 的 PX [topic = %s]", p, topic) // 记录调试信息，忽略该对等节点的 PX。
+				continue                                                              // 跳过此节点。
+			}
 
 			gs.pxConnect(px) // 连接 PX 对等节点。
 		}
@@ -1161,7 +1514,8 @@ func (gs *GossipSubRouter) connector() {
 // 参数:
 //   - msg: *Message 类型，表示要发布的消息。
 func (gs *GossipSubRouter) Publish(msg *Message) {
-	gs.mcache.Put(msg) // 将消息放入消息缓存中。
+	gs.mcache.Put(msg)   // 将消息放入消息缓存中。
+	gs.untrackIWant(msg) // 消息已到达，移除对应的 IWANT 请求。
 
 	from := msg.ReceivedFrom // 获取消息的发送者。
 	topic := msg.GetTopic()  // 获取消息所属的主题。
@@ -1174,7 +1528,7 @@ func (gs *GossipSubRouter) Publish(msg *Message) {
 		return // 返回，结束函数执行。
 	}
 
-	if gs.floodPublish && from == gs.p.host.ID() { // 如果启用了洪水发布，并且消息发送者是自己。
+	if (gs.floodPublish && from == gs.p.host.ID()) || gs.hybridFlood(topic) { // 如果启用了洪水发布并且消息发送者是自己，或者主题处于混合路由的洪泛模式。
 		for p := range tmap { // 遍历主题中的所有对等节点。
 			_, direct := gs.direct[p]                               // 检查对等节点是否为直接对等节点。
 			if direct || gs.score.Score(p) >= gs.publishThreshold { // 如果是直接对等节点，或对等节点评分高于发布阈值。
@@ -1222,12 +1576,22 @@ func (gs *GossipSubRouter) Publish(msg *Message) {
 		}
 	}
 
-	out := rpcWithMessages(msg.Message) // 构造包含消息的 RPC 消息。
-	for pid := range tosend {           // 遍历需要发送消息的对等节点集合。
+	peers := make([]peer.ID, 0, len(tosend))
+	for pid := range tosend { // 遍历需要发送消息的对等节点集合。
 		if pid == from || pid == peer.ID(msg.GetFrom()) { // 如果对等节点是消息的发送者。
 			continue // 跳过此节点。
 		}
+		peers = append(peers, pid)
+	}
+
+	// 本地发布的纠删码分片只发给一部分首跳对等节点，由网格转发给其余节点
+	if from == gs.p.host.ID() {
+		peers = msg.shard.firstHop(peers)
+	}
 
+	out := rpcWithMessages(msg.Message)   // 构造包含消息的 RPC 消息。
+	out.unshaped = from == gs.p.host.ID() // 本地发布的消息不受带宽整形限制
+	for _, pid := range peers {
 		gs.sendRPC(pid, out) // 发送 RPC 消息到对等节点。
 	}
 }
@@ -1257,7 +1621,7 @@ func (gs *GossipSubRouter) Join(topic string) {
 
 		if len(gmap) < gs.params.D { // 如果 fanout 对等节点数量少于 D。
 			// 我们需要更多的对等节点；急切地进行，因为这会在下一个心跳中修复。
-			more := gs.getPeers(topic, gs.params.D-len(gmap), func(p peer.ID) bool { // 获取更多符合条件的对等节点。
+			more := gs.getGraftPeers(topic, gmap, gs.params.D-len(gmap), func(p peer.ID) bool { // 获取更多符合条件的对等节点。
 				// 过滤掉当前对等节点、直接对等节点、我们正在回避的对等节点以及评分为负的对等节点。
 				_, inMesh := gmap[p]                                              // 检查对等节点是否已在 fanout 集合中。
 				_, direct := gs.direct[p]                                         // 检查对等节点是否为直接对等节点。
@@ -1272,20 +1636,35 @@ func (gs *GossipSubRouter) Join(topic string) {
 		delete(gs.fanout, topic)  // 从 fanout 集合中删除该主题。
 		delete(gs.lastpub, topic) // 删除最后发布时间记录。
 	} else {
-		backoff := gs.backoff[topic]                                    // 获取该主题的回退映射。
-		peers := gs.getPeers(topic, gs.params.D, func(p peer.ID) bool { // 获取符合条件的对等节点列表。
+		backoff := gs.backoff[topic] // 获取该主题的回退映射。
+		filter := func(p peer.ID) bool {
 			// 过滤掉直接对等节点、我们正在回避的对等节点和评分为负的对等节点。
 			_, direct := gs.direct[p]                              // 检查对等节点是否为直接对等节点。
 			_, doBackOff := backoff[p]                             // 检查对等节点是否处于回退期。
 			return !direct && !doBackOff && gs.score.Score(p) >= 0 // 返回是否符合条件。
-		})
-		gmap = peerListToMap(peers) // 将对等节点列表转换为映射。
-		gs.mesh[topic] = gmap       // 将映射添加到网格集合中。
+		}
+		// 优先恢复进程交接前的网格对等节点。
+		gmap = peerListToMap(gs.handoffMeshPeers(topic, nil, gs.params.D, filter))
+		if len(gmap) < gs.params.D {
+			peers := gs.getGraftPeers(topic, gmap, gs.params.D-len(gmap), func(p peer.ID) bool { // 获取符合条件的对等节点列表。
+				_, inMesh := gmap[p]
+				return !inMesh && filter(p)
+			})
+			for _, p := range peers {
+				gmap[p] = struct{}{} // 将对等节点添加到映射中。
+			}
+		}
+		gs.mesh[topic] = gmap // 将映射添加到网格集合中。
 	}
 
 	for p := range gmap { // 遍历网格对等节点集合。
+		if !gs.allowGraft() { // 启动爬坡期内的 GRAFT 配额已用完，剩余的对等节点由心跳逐步补充。
+			delete(gmap, p)
+			continue
+		}
 		logger.Debugf("添加网格链接到对等节点 %s 到主题 %s", p, topic) // 记录调试信息，添加对等节点到网格中。
 		gs.tracer.Graft(p, topic)                        // 记录 GRAFT 操作。
+		gs.p.notifyTopicEvent(topic, MeshGrafted, p)     // 通知主题事件处理程序。
 		gs.sendGraft(p, topic)                           // 发送 GRAFT 消息。
 	}
 }
@@ -1302,11 +1681,15 @@ func (gs *GossipSubRouter) Leave(topic string) {
 	logger.Debugf("离开主题 %s", topic) // 记录调试信息，离开主题。
 	gs.tracer.Leave(topic)          // 记录离开操作。
 
-	delete(gs.mesh, topic) // 从网格集合中删除该主题。
+	delete(gs.mesh, topic)        // 从网格集合中删除该主题。
+	delete(gs.draining, topic)    // 清除排空状态。
+	delete(gs.handoffMesh, topic) // 清除未使用的交接记录。
+	gs.cancelTopicIWants(topic)   // 取消该主题进行中的 IWANT 请求。
 
 	for p := range gmap { // 遍历网格对等节点集合。
 		logger.Debugf("从网格中移除对等节点 %s 到主题 %s", p, topic) // 记录调试信息，从网格中移除对等节点。
 		gs.tracer.Prune(p, topic)                       // 记录 PRUNE 操作。
+		gs.p.notifyTopicEvent(topic, MeshPruned, p)     // 通知主题事件处理程序。
 		gs.sendPrune(p, topic, true)                    // 发送 PRUNE 消息。
 		// 添加回退，以防我们在回退期结束前重新加入该主题时急切地重新 GRAFT 该对等节点。
 		gs.addBackoff(p, topic, true) // 添加回退时间。
@@ -1323,6 +1706,20 @@ func (gs *GossipSubRouter) sendGraft(p peer.ID, topic string) {
 	gs.sendRPC(p, out)                               // 发送 RPC 消息到对等节点。
 }
 
+// allowGraft 检查启动爬坡期内是否还有主动 GRAFT 的配额，如果有则消耗一个。
+// 返回值:
+//   - bool: 是否允许 GRAFT。
+func (gs *GossipSubRouter) allowGraft() bool {
+	if gs.graftRampLimit <= 0 || gs.heartbeatTicks > gs.graftRampTicks { // 未启用或爬坡期已结束。
+		return true
+	}
+	if gs.graftBudget <= 0 { // 当前心跳的配额已用完。
+		return false
+	}
+	gs.graftBudget-- // 消耗一个配额。
+	return true
+}
+
 // sendPrune 发送 PRUNE 消息。
 // 参数:
 //   - p: peer.ID 类型，对等节点 ID。
@@ -1367,19 +1764,26 @@ func (gs *GossipSubRouter) sendRPC(p peer.ID, out *RPC) {
 		return // 返回，结束函数执行。
 	}
 
-	// 如果我们低于最大消息大小，继续发送。
-	if out.Size() < gs.p.maxMessageSize { // 如果 RPC 消息的大小小于最大消息大小。
+	// 丢弃超出出站带宽预算的转发消息和 gossip。
+	out, ok = gs.p.shapeRPC(out)
+	if !ok { // 如果没有剩余内容需要发送。
+		return
+	}
+
+	// 如果我们低于最大传输大小，继续发送。
+	limit := gs.p.transmissionLimit()
+	if out.Size() < limit { // 如果 RPC 消息的大小小于最大传输大小。
 		gs.doSendRPC(out, p, mch) // 发送 RPC 消息到对等节点。
 		return                    // 返回，结束函数执行。
 	}
 
-	// 可能将 RPC 拆分为多个低于最大消息大小的 RPC。
-	outRPCs := appendOrMergeRPC(nil, gs.p.maxMessageSize, *out) // 将 RPC 消息拆分为多个小于最大消息大小的消息。
-	for _, rpc := range outRPCs {                               // 遍历拆分后的 RPC 消息。
-		if rpc.Size() > gs.p.maxMessageSize { // 如果拆分后的 RPC 消息仍然大于最大消息大小。
+	// 可能将 RPC 拆分为多个低于最大传输大小的 RPC。
+	outRPCs := appendOrMergeRPC(nil, limit, *out) // 将 RPC 消息拆分为多个小于最大传输大小的消息。
+	for _, rpc := range outRPCs {                 // 遍历拆分后的 RPC 消息。
+		if rpc.Size() > limit { // 如果拆分后的 RPC 消息仍然大于最大传输大小。
 			// 这仅在单个消息/控制大于 maxMessageSize 时发生。
-			gs.doDropRPC(out, p, fmt.Sprintf("丢弃超大消息. 大小: %d, 限制: %d. (超过 %d 字节)", rpc.Size(), gs.p.maxMessageSize, rpc.Size()-gs.p.maxMessageSize)) // 丢弃超大消息，并记录调试信息。
-			continue                                                                                                                                 // 跳过此消息。
+			gs.doDropRPC(out, p, fmt.Sprintf("丢弃超大消息. 大小: %d, 限制: %d. (超过 %d 字节)", rpc.Size(), limit, rpc.Size()-limit)) // 丢弃超大消息，并记录调试信息。
+			continue                                                                                                     // 跳过此消息。
 		}
 		gs.doSendRPC(rpc, p, mch) // 发送拆分后的 RPC 消息到对等节点。
 	}
@@ -1406,10 +1810,16 @@ func (gs *GossipSubRouter) doDropRPC(rpc *RPC, p peer.ID, reason string) {
 //   - p: peer.ID 类型，对等节点 ID。
 //   - mch: chan *RPC 类型，表示 RPC 消息通道。
 func (gs *GossipSubRouter) doSendRPC(rpc *RPC, p peer.ID, mch chan *RPC) {
+	if !gs.p.reserveRPC(rpc) { // 如果内存预算不足。
+		gs.doDropRPC(rpc, p, "内存预算不足") // 丢弃消息并说明原因。
+		return
+	}
+
 	select {
 	case mch <- rpc: // 将 RPC 消息发送到对等节点的消息通道中。
 		gs.tracer.SendRPC(rpc, p) // 记录发送 RPC 消息的操作。
 	default: // 如果消息通道已满。
+		gs.p.releaseRPC(rpc)         // 释放预留的内存预算。
 		gs.doDropRPC(rpc, p, "队列已满") // 丢弃消息并说明原因。
 	}
 }
@@ -1454,6 +1864,16 @@ func appendOrMergeRPC(slice []*RPC, limit int, elems ...RPC) []*RPC {
 			}
 		}
 
+		// 合并/附加茎阶段的消息。
+		for _, msg := range elem.GetStem() { // 遍历所有茎阶段的消息。
+			if lastRPC.Stem = append(lastRPC.Stem, msg); lastRPC.Size() > limit { // 尝试将消息添加到最后一个 RPC 中，并检查是否超过限制。
+				lastRPC.Stem = lastRPC.Stem[:len(lastRPC.Stem)-1] // 如果超过限制，将消息从最后一个 RPC 中移除。
+				lastRPC = &RPC{RPC: pb.RPC{}, from: elem.from}    // 创建一个新的 RPC。
+				lastRPC.Stem = append(lastRPC.Stem, msg)          // 将消息添加到新的 RPC 中。
+				out = append(out, lastRPC)                        // 将新的 RPC 添加到切片中。
+			}
+		}
+
 		// 合并/附加订阅。
 		for _, sub := range elem.GetSubscriptions() { // 遍历所有订阅。
 			if lastRPC.Subscriptions = append(lastRPC.Subscriptions, sub); lastRPC.Size() > limit { // 尝试将订阅添加到最后一个 RPC 中，并检查是否超过限制。
@@ -1561,12 +1981,14 @@ func (gs *GossipSubRouter) heartbeatTimer() {
 
 	for {
 		select {
-		case <-ticker.C: // 每当定时器触发。
+		case tick := <-ticker.C: // 每当定时器触发。
 			select {
-			case gs.p.eval <- gs.heartbeat: // 将心跳操作发送到评估通道。
+			case gs.p.eval <- gs.scheduledHeartbeat(tick): // 将心跳操作发送到评估通道。
 			case <-gs.p.ctx.Done(): // 检查上下文是否已取消。
 				return // 如果上下文已取消，返回结束函数。
 			}
+		case interval := <-gs.heartbeatReset: // 心跳间隔在运行时被修改。
+			ticker.Reset(interval)
 		case <-gs.p.ctx.Done(): // 检查上下文是否已取消。
 			return // 如果上下文已取消，返回结束函数。
 		}
@@ -1587,9 +2009,13 @@ func (gs *GossipSubRouter) heartbeat() {
 
 	gs.heartbeatTicks++ // 增加心跳计数。
 
+	// 重置启动爬坡期内的 GRAFT 配额。
+	gs.graftBudget = gs.graftRampLimit
+
 	tograft := make(map[peer.ID][]string) // 创建一个映射，用于存储需要 GRAFT 的对等节点和主题。
 	toprune := make(map[peer.ID][]string) // 创建一个映射，用于存储需要 PRUNE 的对等节点和主题。
 	noPX := make(map[peer.ID]bool)        // 创建一个映射，用于标记不进行 PX 的对等节点。
+	dout := gs.outboundQuota()            // 实际执行的出站配额。
 
 	// 清理过期的回退。
 	gs.clearBackoff()
@@ -1597,6 +2023,14 @@ func (gs *GossipSubRouter) heartbeat() {
 	// 清理 iasked 计数器。
 	gs.clearIHaveCounters()
 
+	// 清理过期的 IWANT 请求。
+	gs.expireIWants()
+
+	// 清理防 spam 硬化的过期记录，与回退一样每 15 个心跳清理一次。
+	if gs.heartbeatTicks%15 == 0 {
+		gs.hardening.gc(start)
+	}
+
 	// 应用 IWANT 请求惩罚。
 	gs.applyIwantPenalties()
 
@@ -1617,21 +2051,32 @@ func (gs *GossipSubRouter) heartbeat() {
 	// 维护我们加入的主题的网格。
 	for topic, peers := range gs.mesh {
 		prunePeer := func(p peer.ID) {
-			gs.tracer.Prune(p, topic)          // 记录 PRUNE 操作。
-			delete(peers, p)                   // 从网格中移除该对等节点。
-			gs.addBackoff(p, topic, false)     // 为该对等节点添加回退时间。
-			topics := toprune[p]               // 获取该对等节点的 PRUNE 主题列表。
-			toprune[p] = append(topics, topic) // 将主题添加到 PRUNE 列表中。
+			gs.tracer.Prune(p, topic)                   // 记录 PRUNE 操作。
+			gs.p.notifyTopicEvent(topic, MeshPruned, p) // 通知主题事件处理程序。
+			delete(peers, p)                            // 从网格中移除该对等节点。
+			gs.addBackoff(p, topic, false)              // 为该对等节点添加回退时间。
+			topics := toprune[p]                        // 获取该对等节点的 PRUNE 主题列表。
+			toprune[p] = append(topics, topic)          // 将主题添加到 PRUNE 列表中。
 		}
 
 		graftPeer := func(p peer.ID) {
+			if !gs.allowGraft() { // 启动爬坡期内的 GRAFT 配额已用完，留到下一个心跳。
+				return
+			}
 			logger.Debugf("添加网格链接到对等节点 %s 到主题 %s", p, topic) // 记录调试信息，添加该对等节点到网格中。
 			gs.tracer.Graft(p, topic)                        // 记录 GRAFT 操作。
+			gs.p.notifyTopicEvent(topic, MeshGrafted, p)     // 通知主题事件处理程序。
 			peers[p] = struct{}{}                            // 将该对等节点添加到网格中。
 			topics := tograft[p]                             // 获取该对等节点的 GRAFT 主题列表。
 			tograft[p] = append(topics, topic)               // 将主题添加到 GRAFT 列表中。
 		}
 
+		// 正在排空的主题不再维护网格，只继续发送 gossip，网格对等节点由 Drain 逐步 PRUNE。
+		if _, draining := gs.draining[topic]; draining {
+			gs.emitGossip(topic, peers) // 发送 gossip 消息。
+			continue
+		}
+
 		// 删除所有评分为负的对等节点，不进行 PX。
 		for p := range peers {
 			if score(p) < 0 { // 如果对等节点评分为负。
@@ -1641,11 +2086,26 @@ func (gs *GossipSubRouter) heartbeat() {
 			}
 		}
 
+		// 加入主题后的第一个心跳再尝试恢复进程交接前的网格对等节点，之后清除交接记录。
+		if _, ok := gs.handoffMesh[topic]; ok {
+			backoff := gs.backoff[topic]
+			plst := gs.handoffMeshPeers(topic, peers, gs.params.D-len(peers), func(p peer.ID) bool {
+				_, inMesh := peers[p]
+				_, doBackoff := backoff[p]
+				_, direct := gs.direct[p]
+				return !inMesh && !doBackoff && !direct && score(p) >= 0
+			})
+			for _, p := range plst {
+				graftPeer(p)
+			}
+			delete(gs.handoffMesh, topic)
+		}
+
 		// 我们有足够的对等节点吗？
 		if l := len(peers); l < gs.params.Dlo { // 如果网格中的对等节点少于下限。
 			backoff := gs.backoff[topic] // 获取该主题的回退映射。
 			ineed := gs.params.D - l     // 计算需要添加的对等节点数量。
-			plst := gs.getPeers(topic, ineed, func(p peer.ID) bool {
+			plst := gs.getGraftPeers(topic, peers, ineed, func(p peer.ID) bool {
 				// 过滤掉当前对等节点和直接对等节点、我们正在回避的对等节点以及评分为负的对等节点。
 				_, inMesh := peers[p]
 				_, doBackoff := backoff[p]
@@ -1681,7 +2141,7 @@ func (gs *GossipSubRouter) heartbeat() {
 			}
 
 			// 如果少于 D_out，从随机选择中冒出一些出站对等节点。
-			if outbound < gs.params.Dout {
+			if outbound < dout {
 				rotate := func(i int) {
 					// 向右旋转 plst 并将第 i 个对等节点放在前面。
 					p := plst[i]
@@ -1704,7 +2164,7 @@ func (gs *GossipSubRouter) heartbeat() {
 				}
 
 				// 现在将选择之外的足够出站对等节点冒到前面。
-				ineed := gs.params.Dout - outbound
+				ineed := dout - outbound
 				for i := gs.params.D; i < len(plst) && ineed > 0; i++ {
 					p := plst[i]
 					if gs.outbound[p] {
@@ -1732,10 +2192,10 @@ func (gs *GossipSubRouter) heartbeat() {
 			}
 
 			// 如果少于 D_out，选择一些具有出站连接的对等节点并进行 graft。
-			if outbound < gs.params.Dout {
-				ineed := gs.params.Dout - outbound
+			if outbound < dout {
+				ineed := dout - outbound
 				backoff := gs.backoff[topic]
-				plst := gs.getPeers(topic, ineed, func(p peer.ID) bool {
+				plst := gs.getGraftPeers(topic, peers, ineed, func(p peer.ID) bool {
 					// 过滤掉当前对等节点和直接对等节点、我们正在回避的对等节点以及评分为负的对等节点。
 					_, inMesh := peers[p]
 					_, doBackoff := backoff[p]
@@ -1767,13 +2227,17 @@ func (gs *GossipSubRouter) heartbeat() {
 			// 如果中位评分低于阈值，选择一个更好的对等节点（如果有）并进行 GRAFT。
 			if medianScore < gs.opportunisticGraftThreshold {
 				backoff := gs.backoff[topic]
-				plst = gs.getPeers(topic, gs.params.OpportunisticGraftPeers, func(p peer.ID) bool {
+				plst = gs.getGraftPeers(topic, peers, gs.params.OpportunisticGraftPeers, func(p peer.ID) bool {
 					_, inMesh := peers[p]
 					_, doBackoff := backoff[p]
 					_, direct := gs.direct[p]
 					return !inMesh && !doBackoff && !direct && score(p) > medianScore
 				})
 
+				if len(plst) > 0 {
+					gs.tracer.OpportunisticGraft(topic, medianScore, plst) // 记录机会性 GRAFT 事件。
+				}
+
 				for _, p := range plst {
 					logger.Debugf("机会性 GRAFT 对等节点 %s 到主题 %s", p, topic) // 记录调试信息，GRAFT 该对等节点。
 					graftPeer(p)                                        // 执行 GRAFT 操作。
@@ -1826,6 +2290,9 @@ func (gs *GossipSubRouter) heartbeat() {
 	// 发送合并的 GRAFT/PRUNE 消息（将捎带 gossip）。
 	gs.sendGraftPrune(tograft, toprune, noPX)
 
+	// 更新连接管理器中受保护的网格出站连接。
+	gs.protectOutboundMesh()
+
 	// 刷新所有未捎带的挂起 gossip。
 	gs.flush()
 
@@ -1849,8 +2316,22 @@ func (gs *GossipSubRouter) clearIHaveCounters() {
 // applyIwantPenalties 应用 IWANT 请求惩罚。
 func (gs *GossipSubRouter) applyIwantPenalties() {
 	for p, count := range gs.gossipTracer.GetBrokenPromises() { // 遍历所有未遵守 IWANT 请求的对等节点。
-		logger.Infof("对等节点 %s 未遵守 %d 次 IWANT 请求; 添加惩罚", p, count) // 记录信息，说明哪个对等节点未遵守 IWANT 请求以及对应的次数。
-		gs.score.AddPenalty(p, count)                             // 根据未遵守的次数为该对等节点添加惩罚分。
+		if gs.iwantPenalty <= 0 { // 未启用跟进失败惩罚。
+			continue
+		}
+		logger.Infof("对等节点 %s 未遵守 %d 次 IWANT 请求; 添加惩罚", p, count)   // 记录信息，说明哪个对等节点未遵守 IWANT 请求以及对应的次数。
+		gs.penalize(p, count*gs.iwantPenalty, PenaltyBrokenPromise) // 根据未遵守的次数为该对等节点添加行为惩罚。
+	}
+
+	// 记录 IWANT 请求的响应情况，用于 IWANT 响应评分。
+	fulfilled, unfulfilled := gs.gossipTracer.GetIWantFulfillment()
+	for p, count := range fulfilled {
+		gs.score.AddIWantFulfillment(p, count, unfulfilled[p])
+	}
+	for p, count := range unfulfilled {
+		if _, ok := fulfilled[p]; !ok {
+			gs.score.AddIWantFulfillment(p, 0, count)
+		}
 	}
 }
 
@@ -1882,12 +2363,25 @@ func (gs *GossipSubRouter) directConnect() {
 		return
 	}
 
+	now := time.Now()
 	var toconnect []peer.ID    // 初始化一个对等节点 ID 列表，用于存储需要连接的对等节点。
 	for p := range gs.direct { // 遍历所有直接对等节点。
 		_, connected := gs.peers[p] // 检查该对等节点是否已连接。
-		if !connected {             // 如果未连接。
-			toconnect = append(toconnect, p) // 将该对等节点添加到待连接列表中。
+		if connected {
+			delete(gs.directAttempts, p) // 已连接，重置退避
+			delete(gs.directNext, p)
+			continue
 		}
+
+		// 配置了退避策略时，连续的重新连接之间按策略等待
+		if strategy := gs.p.backoffStrategy; strategy != nil {
+			if now.Before(gs.directNext[p]) {
+				continue
+			}
+			gs.directAttempts[p]++
+			gs.directNext[p] = now.Add(strategy.Delay(gs.directAttempts[p]))
+		}
+		toconnect = append(toconnect, p) // 将该对等节点添加到待连接列表中。
 	}
 
 	if len(toconnect) > 0 { // 如果有待连接的对等节点。
@@ -1944,6 +2438,10 @@ func (gs *GossipSubRouter) sendGraftPrune(tograft, toprune map[peer.ID][]string,
 //   - topic: string 类型，表示主题名称。
 //   - exclude: map[peer.ID]struct{} 类型，表示排除的对等节点。
 func (gs *GossipSubRouter) emitGossip(topic string, exclude map[peer.ID]struct{}) {
+	if gs.gossipStopped || gs.hybridFlood(topic) { // 如果 gossip 子系统已停止，或者主题处于洪泛模式。
+		return // 不发出 gossip。
+	}
+
 	mids := gs.mcache.GetGossipIDs(topic) // 获取该主题的消息 ID 列表。
 	if len(mids) == 0 {                   // 如果没有消息 ID。
 		return // 直接返回，不进行 gossip。
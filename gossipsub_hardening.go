@@ -0,0 +1,217 @@
+// 作用：gossipsub 防 spam 硬化。
+// 功能：针对无许可部署中的网格抖动攻击，限制每个对等节点每个主题的 GRAFT 速率，
+// 加重对回退期内 GRAFT 的惩罚，并跟踪同一 IP 上快速更换的对等节点 ID，将其计入行为惩罚。
+
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	manet "github.com/dep2p/go-dep2p/multiformats/multiaddr/net"
+)
+
+// HardeningParams 包含 gossipsub 防 spam 硬化参数
+type HardeningParams struct {
+	// GraftRateLimit 是在 GraftRateWindow 内从单个对等节点接受的同一主题 GRAFT 数量上限，
+	// 超出的 GRAFT 会被 PRUNE 并计入一次行为惩罚。
+	GraftRateLimit  int
+	GraftRateWindow time.Duration
+
+	// GraftBackoffPenalty 是对回退期内 GRAFT 额外计入的行为惩罚次数，
+	// 在路由器原有的惩罚之上叠加。
+	GraftBackoffPenalty int
+
+	// ChurnThreshold 是在 ChurnWindow 内同一 IP 上允许出现的不同对等节点 ID 数量，
+	// 超出后每个新的对等节点 ID 计入 ChurnPenalty 次行为惩罚。
+	ChurnThreshold int
+	ChurnWindow    time.Duration
+	ChurnPenalty   int
+}
+
+// DefaultHardeningParams 返回默认的防 spam 硬化参数
+// 返回值:
+//   - HardeningParams: 默认参数
+func DefaultHardeningParams() HardeningParams {
+	return HardeningParams{
+		GraftRateLimit:      4,
+		GraftRateWindow:     time.Minute,
+		GraftBackoffPenalty: 1,
+		ChurnThreshold:      8,
+		ChurnWindow:         10 * time.Minute,
+		ChurnPenalty:        1,
+	}
+}
+
+// validate 验证防 spam 硬化参数
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *HardeningParams) validate() error {
+	if p.GraftRateLimit <= 0 || p.GraftRateWindow <= 0 {
+		logger.Warnf("无效的 GRAFT 速率限制: %d/%v", p.GraftRateLimit, p.GraftRateWindow)
+		return fmt.Errorf("无效的 GRAFT 速率限制: %d/%v", p.GraftRateLimit, p.GraftRateWindow)
+	}
+	if p.GraftBackoffPenalty < 0 {
+		logger.Warnf("无效的回退期 GRAFT 惩罚: %d", p.GraftBackoffPenalty)
+		return fmt.Errorf("无效的回退期 GRAFT 惩罚: %d", p.GraftBackoffPenalty)
+	}
+	if p.ChurnThreshold <= 0 || p.ChurnWindow <= 0 || p.ChurnPenalty < 0 {
+		logger.Warnf("无效的对等节点 ID 抖动参数: %d/%v/%d", p.ChurnThreshold, p.ChurnWindow, p.ChurnPenalty)
+		return fmt.Errorf("无效的对等节点 ID 抖动参数: %d/%v/%d", p.ChurnThreshold, p.ChurnWindow, p.ChurnPenalty)
+	}
+	return nil
+}
+
+// spamHardening 记录防 spam 硬化所需的状态。只从 processLoop 访问。
+type spamHardening struct {
+	params HardeningParams
+
+	grafts  map[peer.ID]map[string][]time.Time // 每个对等节点每个主题最近的 GRAFT 时间，断开连接后保留到窗口过期
+	ipPeers map[string]map[peer.ID]time.Time   // 每个 IP 上最近出现的对等节点 ID 及时间
+}
+
+// WithSpamHardening 是一个 gossipsub 路由器选项，用于启用防 spam 硬化。
+// 参数:
+//   - params: HardeningParams 类型，表示硬化参数。
+//
+// 返回值:
+//   - Option: 返回一个 Option 类型的函数，用于配置 gossipsub 路由器。
+func WithSpamHardening(params HardeningParams) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if err := params.validate(); err != nil {
+			return err
+		}
+		gs.hardening = &spamHardening{
+			params:  params,
+			grafts:  make(map[peer.ID]map[string][]time.Time),
+			ipPeers: make(map[string]map[peer.ID]time.Time),
+		}
+		return nil
+	}
+}
+
+// allowGraft 记录一次 GRAFT，并检查是否超出速率限制。未启用硬化时总是允许。
+// 参数:
+//   - p: 对等节点 ID
+//   - topic: 主题
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否允许
+func (h *spamHardening) allowGraft(p peer.ID, topic string, now time.Time) bool {
+	if h == nil {
+		return true
+	}
+
+	topics, ok := h.grafts[p]
+	if !ok {
+		topics = make(map[string][]time.Time)
+		h.grafts[p] = topics
+	}
+
+	recent := topics[topic][:0]
+	for _, t := range topics[topic] {
+		if now.Sub(t) <= h.params.GraftRateWindow {
+			recent = append(recent, t)
+		}
+	}
+	recent = append(recent, now)
+	topics[topic] = recent
+
+	return len(recent) <= h.params.GraftRateLimit
+}
+
+// backoffPenalty 返回对回退期内 GRAFT 额外计入的行为惩罚次数。未启用硬化时为 0。
+// 返回值:
+//   - int: 惩罚次数
+func (h *spamHardening) backoffPenalty() int {
+	if h == nil {
+		return 0
+	}
+	return h.params.GraftBackoffPenalty
+}
+
+// observePeer 记录对等节点出现在给定 IP 上，并返回需要计入的抖动惩罚次数。
+// 参数:
+//   - ip: IP 地址
+//   - p: 对等节点 ID
+//   - now: 当前时间
+//
+// 返回值:
+//   - int: 惩罚次数
+func (h *spamHardening) observePeer(ip string, p peer.ID, now time.Time) int {
+	peers, ok := h.ipPeers[ip]
+	if !ok {
+		peers = make(map[peer.ID]time.Time)
+		h.ipPeers[ip] = peers
+	}
+	for pid, seen := range peers {
+		if now.Sub(seen) > h.params.ChurnWindow {
+			delete(peers, pid)
+		}
+	}
+	peers[p] = now
+
+	if len(peers) > h.params.ChurnThreshold {
+		return h.params.ChurnPenalty
+	}
+	return 0
+}
+
+// addPeer 在对等节点上线时检查其所在 IP 上的对等节点 ID 抖动，并计入行为惩罚。
+// 参数:
+//   - gs: gossipsub 路由器
+//   - p: 对等节点 ID
+func (h *spamHardening) addPeer(gs *GossipSubRouter, p peer.ID) {
+	if h == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, c := range gs.p.host.Network().ConnsToPeer(p) {
+		ip, err := manet.ToIP(c.RemoteMultiaddr())
+		if err != nil || ip.IsLoopback() {
+			continue
+		}
+		if penalty := h.observePeer(ip.String(), p, now); penalty > 0 {
+			logger.Debugf("对等节点 %s 所在的 IP %s 上对等节点 ID 更换过快; 添加惩罚", p, ip)
+			gs.penalize(p, penalty, PenaltyPeerIDChurn)
+		}
+	}
+}
+
+// gc 清理过期的 GRAFT 和 IP 记录。对等节点断开连接时不清理其 GRAFT 记录，
+// 与分数保留一样，避免通过断开重连重置 GRAFT 速率限制。
+// 参数:
+//   - now: 当前时间
+func (h *spamHardening) gc(now time.Time) {
+	if h == nil {
+		return
+	}
+	for p, topics := range h.grafts {
+		for topic, times := range topics {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) > h.params.GraftRateWindow {
+				delete(topics, topic)
+			}
+		}
+		if len(topics) == 0 {
+			delete(h.grafts, p)
+		}
+	}
+	for ip, peers := range h.ipPeers {
+		for pid, seen := range peers {
+			if now.Sub(seen) > h.params.ChurnWindow {
+				delete(peers, pid)
+			}
+		}
+		if len(peers) == 0 {
+			delete(h.ipPeers, ip)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestSpamHardeningGraftRate 测试 GRAFT 速率限制
+func TestSpamHardeningGraftRate(t *testing.T) {
+	params := DefaultHardeningParams()
+	params.GraftRateLimit = 2
+	params.GraftRateWindow = time.Minute
+	h := &spamHardening{
+		params:  params,
+		grafts:  make(map[peer.ID]map[string][]time.Time),
+		ipPeers: make(map[string]map[peer.ID]time.Time),
+	}
+
+	now := time.Now()
+	pid := peer.ID("A")
+	if !h.allowGraft(pid, "test", now) || !h.allowGraft(pid, "test", now.Add(time.Second)) {
+		t.Fatal("expected first two GRAFTs to be allowed")
+	}
+	if h.allowGraft(pid, "test", now.Add(2*time.Second)) {
+		t.Fatal("expected third GRAFT within the window to be rejected")
+	}
+	if !h.allowGraft(pid, "other", now.Add(2*time.Second)) {
+		t.Fatal("expected GRAFT on another topic to be allowed")
+	}
+	// 窗口过去之后重新允许
+	if !h.allowGraft(pid, "test", now.Add(2*time.Minute)) {
+		t.Fatal("expected GRAFT after the window to be allowed")
+	}
+
+	// 断开重连不会重置 GRAFT 记录，窗口过期后由 gc 清理
+	h.gc(now.Add(2 * time.Minute))
+	if !h.allowGraft(pid, "test", now.Add(2*time.Minute+time.Second)) {
+		t.Fatal("expected second GRAFT after the window to be allowed")
+	}
+	h.gc(now.Add(2*time.Minute + time.Second))
+	if h.allowGraft(pid, "test", now.Add(2*time.Minute+2*time.Second)) {
+		t.Fatal("expected GRAFT history to survive until the window expires")
+	}
+	h.gc(now.Add(5 * time.Minute))
+	if len(h.grafts) != 0 {
+		t.Fatalf("expected expired GRAFT history to be collected, got %d peers", len(h.grafts))
+	}
+
+	// 未启用硬化时总是允许，且没有额外惩罚
+	var none *spamHardening
+	if !none.allowGraft(pid, "test", now) || none.backoffPenalty() != 0 {
+		t.Fatal("expected disabled hardening to be a no-op")
+	}
+}
+
+// TestSpamHardeningChurn 测试同一 IP 上对等节点 ID 抖动的检测
+func TestSpamHardeningChurn(t *testing.T) {
+	params := DefaultHardeningParams()
+	params.ChurnThreshold = 3
+	params.ChurnWindow = time.Minute
+	params.ChurnPenalty = 2
+	h := &spamHardening{
+		params:  params,
+		grafts:  make(map[peer.ID]map[string][]time.Time),
+		ipPeers: make(map[string]map[peer.ID]time.Time),
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if penalty := h.observePeer("1.2.3.4", peer.ID(fmt.Sprintf("peer-%d", i)), now); penalty != 0 {
+			t.Fatalf("expected no penalty for peer %d, got %d", i, penalty)
+		}
+	}
+	if penalty := h.observePeer("1.2.3.4", peer.ID("peer-3"), now); penalty != 2 {
+		t.Fatalf("expected churn penalty 2, got %d", penalty)
+	}
+	// 其他 IP 不受影响
+	if penalty := h.observePeer("5.6.7.8", peer.ID("peer-4"), now); penalty != 0 {
+		t.Fatalf("expected no penalty on another IP, got %d", penalty)
+	}
+
+	// 窗口过去之后旧的对等节点 ID 不再计数
+	if penalty := h.observePeer("1.2.3.4", peer.ID("peer-5"), now.Add(2*time.Minute)); penalty != 0 {
+		t.Fatalf("expected no penalty after the window, got %d", penalty)
+	}
+	h.gc(now.Add(4 * time.Minute))
+	if len(h.ipPeers) != 0 {
+		t.Fatalf("expected stale IP records to be collected, got %d", len(h.ipPeers))
+	}
+}
+
+// TestSpamHardeningOption 测试硬化选项的参数验证
+func TestSpamHardeningOption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	ps := getGossipsub(ctx, hosts[0], WithSpamHardening(DefaultHardeningParams()))
+	if ps.rt.(*GossipSubRouter).hardening == nil {
+		t.Fatal("expected hardening to be enabled")
+	}
+
+	params := DefaultHardeningParams()
+	params.GraftRateLimit = 0
+	if _, err := NewGossipSub(ctx, hosts[1], WithSpamHardening(params)); err == nil {
+		t.Error("expected error for zero GRAFT rate limit")
+	}
+}
@@ -954,6 +954,84 @@ func TestGossipsubPruneBackoffTime(t *testing.T) {
 	}
 }
 
+// TestGossipsubBackoffOptions 测试 WithPruneBackoff/WithUnsubscribeBackoff 选项以及 BackoffUntil 接口。
+//
+// 参数：
+//   - t: *testing.T 用于报告测试结果和错误。
+//
+// 功能描述：
+//  1. 使用自定义的修剪和取消订阅回退时间创建两个 GossipSub 实例。
+//  2. 两个节点加入 "foobar" 主题并建立网格。
+//  3. 节点 0 离开主题，验证双方记录的回退时间与取消订阅回退时间一致。
+//  4. 验证非 gossipsub 路由器拒绝这些选项。
+func TestGossipsubBackoffOptions(t *testing.T) {
+	// 创建一个可取消的上下文
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // 确保在函数结束时取消上下文，释放资源
+
+	hosts := getDefaultHosts(t, 2)
+
+	unsubBackoff := 30 * time.Second
+	psubs := getGossipsubs(ctx, hosts,
+		WithPruneBackoff(2*time.Minute),
+		WithUnsubscribeBackoff(unsubBackoff))
+
+	rt := psubs[0].rt.(*GossipSubRouter)
+	if rt.params.PruneBackoff != 2*time.Minute {
+		t.Fatalf("expected prune backoff %v, got %v", 2*time.Minute, rt.params.PruneBackoff)
+	}
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	// 回退尚未建立
+	if until := psubs[0].BackoffUntil("foobar", hosts[1].ID()); !until.IsZero() {
+		t.Fatalf("expected no backoff, got %v", until)
+	}
+
+	connect(t, hosts[0], hosts[1])
+
+	// 等待心跳建立网格
+	time.Sleep(2 * time.Second)
+
+	leaveTime := time.Now()
+	subs[0].Cancel() // 取消最后一个订阅会使节点 0 离开主题
+
+	// 等待 PRUNE 传播
+	time.Sleep(time.Second)
+
+	for i, pid := range []peer.ID{hosts[1].ID(), hosts[0].ID()} {
+		until := psubs[i].BackoffUntil("foobar", pid)
+		if until.IsZero() {
+			t.Fatalf("expected backoff for peer %s on node %d", pid, i)
+		}
+		slack := until.Sub(leaveTime) - unsubBackoff
+		if slack < -time.Second || slack > 2*time.Second {
+			t.Fatalf("backoff on node %d should be equal to unsubscribe backoff (with some slack), was off by %v", i, slack)
+		}
+	}
+
+	// 非 gossipsub 路由器不支持回退选项
+	fhosts := getDefaultHosts(t, 2)
+	if _, err := NewFloodSub(ctx, fhosts[0], WithPruneBackoff(time.Minute)); err == nil {
+		t.Fatal("expected error when applying WithPruneBackoff to floodsub")
+	}
+	fps := getPubsub(ctx, fhosts[1])
+	if until := fps.BackoffUntil("foobar", hosts[0].ID()); !until.IsZero() {
+		t.Fatalf("expected zero backoff for floodsub, got %v", until)
+	}
+}
+
 // TestGossipsubGraft 测试 GossipSub 的 GRAFT 操作，确保节点可以通过 GRAFT 操作重新加入消息传播网络。
 //
 // 参数：
@@ -2241,6 +2319,226 @@ func TestGossipsubCustomParams(t *testing.T) {
 	}
 }
 
+// TestGossipsubOpportunisticGraftOptions 测试机会性 GRAFT 参数选项。
+//
+// 参数：
+//   - t: *testing.T 用于报告测试结果和错误。
+func TestGossipsubOpportunisticGraftOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts[:1],
+		WithOpportunisticGraftTicks(5),
+		WithOpportunisticGraftPeers(4))
+
+	rt := psubs[0].rt.(*GossipSubRouter)
+	if rt.params.OpportunisticGraftTicks != 5 {
+		t.Errorf("expected OpportunisticGraftTicks 5, got %d", rt.params.OpportunisticGraftTicks)
+	}
+	if rt.params.OpportunisticGraftPeers != 4 {
+		t.Errorf("expected OpportunisticGraftPeers 4, got %d", rt.params.OpportunisticGraftPeers)
+	}
+
+	// 非法参数会被拒绝
+	if _, err := NewGossipSub(ctx, hosts[1], WithOpportunisticGraftTicks(0)); err == nil {
+		t.Error("expected error for zero OpportunisticGraftTicks")
+	}
+	if _, err := NewGossipSub(ctx, hosts[2], WithOpportunisticGraftPeers(0)); err == nil {
+		t.Error("expected error for zero OpportunisticGraftPeers")
+	}
+}
+
+// graftSendCounter 统计发送的 GRAFT 数量的事件追踪器
+type graftSendCounter struct {
+	mx     sync.Mutex
+	grafts int
+}
+
+// Trace 实现 EventTracer 接口
+func (c *graftSendCounter) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() != pb.TraceEvent_SEND_RPC {
+		return
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.grafts += len(evt.GetSendRPC().GetMeta().GetControl().GetGraft())
+}
+
+// count 返回已发送的 GRAFT 数量
+func (c *graftSendCounter) count() int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.grafts
+}
+
+// TestGossipsubGraftRampUp 测试启动爬坡期内每个心跳主动 GRAFT 的数量受到限制
+func TestGossipsubGraftRampUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 9)
+	counter := &graftSendCounter{}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithGraftRampUp(1, 1000), WithEventTracer(counter)),
+	}
+	psubs = append(psubs, getGossipsubs(ctx, hosts[1:7])...)
+
+	for _, ps := range psubs[1:] {
+		if _, err := ps.Subscribe("foobar"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, h := range hosts[1:7] {
+		connect(t, hosts[0], h)
+	}
+	time.Sleep(time.Second)
+
+	if _, err := psubs[0].Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	// 没有限制时加入主题会立即 GRAFT D 个对等节点
+	if n := counter.count(); n < 1 || n > 2 {
+		t.Fatalf("expected 1 or 2 grafts during ramp up, got %d", n)
+	}
+
+	// 爬坡期结束后不再限制
+	rt := psubs[0].rt.(*GossipSubRouter)
+	done := make(chan bool, 1)
+	psubs[0].eval <- func() {
+		rt.graftRampTicks = 0
+		rt.graftBudget = 0
+		done <- rt.allowGraft()
+	}
+	if !<-done {
+		t.Fatal("expected grafts to be unlimited after ramp up")
+	}
+
+	// 非法参数会被拒绝
+	if _, err := NewGossipSub(ctx, hosts[7], WithGraftRampUp(0, 10)); err == nil {
+		t.Error("expected error for zero graft limit")
+	}
+	if _, err := NewGossipSub(ctx, hosts[8], WithGraftRampUp(1, 0)); err == nil {
+		t.Error("expected error for zero ramp ticks")
+	}
+}
+
+// TestGossipsubGossipHistory 测试消息缓存窗口配置以及 IWANT 命中统计
+func TestGossipsubGossipHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getGossipsubs(ctx, hosts[:2], WithGossipHistory(4, 10))
+
+	rt := psubs[0].rt.(*GossipSubRouter)
+	if rt.params.HistoryGossip != 4 || rt.params.HistoryLength != 10 {
+		t.Fatalf("expected history 4/10, got %d/%d", rt.params.HistoryGossip, rt.params.HistoryLength)
+	}
+	if len(rt.mcache.history) != 10 || rt.mcache.gossip != 4 {
+		t.Fatal("expected message cache to be resized")
+	}
+
+	msg := &Message{Message: &pb.Message{
+		Data:  []byte("hello"),
+		Topic: "foobar",
+		From:  []byte(hosts[0].ID()),
+		Seqno: []byte{0, 0, 0, 0, 0, 0, 0, 1},
+	}}
+
+	done := make(chan struct{})
+	psubs[0].eval <- func() {
+		defer close(done)
+		rt.mcache.Put(msg)
+		rt.handleIWant(hosts[1].ID(), &pb.ControlMessage{
+			Iwant: []*pb.ControlIWant{{MessageIDs: []string{psubs[0].idGen.ID(msg), "missing"}}},
+		})
+	}
+	<-done
+
+	stats := psubs[0].CacheStats()
+	if stats.Messages != 1 || stats.IWantHits != 1 || stats.IWantMisses != 1 {
+		t.Fatalf("unexpected cache stats %+v", stats)
+	}
+
+	// 非法参数会被拒绝
+	if _, err := NewGossipSub(ctx, hosts[2], WithGossipHistory(0, 5)); err == nil {
+		t.Error("expected error for zero gossip window")
+	}
+	if _, err := NewGossipSub(ctx, hosts[3], WithGossipHistory(5, 4)); err == nil {
+		t.Error("expected error for history window smaller than gossip window")
+	}
+}
+
+// TestGossipsubIHaveBudget 测试 IHAVE/IWANT 预算和 IWANT 跟进配置
+func TestGossipsubIHaveBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 5)
+	const maxIHave, maxIWant = 2, 3
+	ps := getGossipsub(ctx, hosts[0], WithIHaveBudget(maxIHave, maxIWant), WithIWantFollowup(10*time.Second, 0))
+	rt := ps.rt.(*GossipSubRouter)
+	if rt.params.IWantFollowupTime != 10*time.Second || rt.iwantPenalty != 0 {
+		t.Fatalf("unexpected followup config %v/%d", rt.params.IWantFollowupTime, rt.iwantPenalty)
+	}
+
+	topic, err := ps.Join("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	ihave := func(p peer.ID, mids ...string) []string {
+		out := make(chan []*pb.ControlIWant, 1)
+		ps.eval <- func() {
+			out <- rt.handleIHave(p, &pb.ControlMessage{
+				Ihave: []*pb.ControlIHave{{TopicID: "test", MessageIDs: mids}},
+			})
+		}
+		iwant := <-out
+		if len(iwant) == 0 {
+			return nil
+		}
+		return iwant[0].MessageIDs
+	}
+
+	// IWANT 预算：单个 IHAVE 被截断到 maxIWant 条，预算用尽后不再跟进
+	a := hosts[1].ID()
+	if got := ihave(a, "a1", "a2", "a3", "a4", "a5"); len(got) != maxIWant {
+		t.Fatalf("expected IWANT for exactly %d messages, got %v", maxIWant, got)
+	}
+	if got := ihave(a, "a6"); got != nil {
+		t.Fatalf("expected IHAVE beyond the IWANT budget to be ignored, got %v", got)
+	}
+
+	// IHAVE 预算：IWANT 预算未用尽时，第 maxIHave+1 个 IHAVE 被忽略
+	b := hosts[2].ID()
+	for i := 1; i <= maxIHave; i++ {
+		if got := ihave(b, fmt.Sprintf("b%d", i)); len(got) != 1 {
+			t.Fatalf("expected IHAVE %d within the budget to be followed, got %v", i, got)
+		}
+	}
+	if got := ihave(b, "b3"); got != nil {
+		t.Fatalf("expected IHAVE %d to exceed the IHAVE budget, got %v", maxIHave+1, got)
+	}
+
+	// 非法参数会被拒绝
+	if _, err := NewGossipSub(ctx, hosts[2], WithIHaveBudget(0, 10)); err == nil {
+		t.Error("expected error for zero IHAVE budget")
+	}
+	if _, err := NewGossipSub(ctx, hosts[3], WithIHaveBudget(10, 0)); err == nil {
+		t.Error("expected error for zero IWANT budget")
+	}
+	if _, err := NewGossipSub(ctx, hosts[4], WithIWantFollowup(time.Second, -1)); err == nil {
+		t.Error("expected error for negative followup penalty")
+	}
+}
+
 // TestGossipsubNegativeScore 测试对负分 peer 的处理。
 //
 // 参数：
@@ -0,0 +1,201 @@
+// 作用：回调式订阅。
+// 功能：作为 Subscription.Next 拉取模式的替代，由工作池并发调用消息处理函数，
+// 恢复处理函数中的 panic，并在订阅意外结束时自动重新订阅；关闭时等待正在执行的处理函数返回。
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// handlerResubscribeDelay 是订阅意外结束后重新订阅前的等待时间
+const handlerResubscribeDelay = time.Second
+
+// MessageHandler 处理订阅收到的消息，返回的错误只会被记录
+type MessageHandler func(msg *Message) error
+
+// handlerOptions 是回调式订阅的选项
+type handlerOptions struct {
+	concurrency int      // 并发调用处理函数的工作协程数
+	subOpts     []SubOpt // 创建订阅时使用的订阅选项
+}
+
+// HandlerOpt 是回调式订阅的选项
+type HandlerOpt func(o *handlerOptions) error
+
+// WithHandlerConcurrency 设置并发调用处理函数的工作协程数，默认为 1，即按收到顺序依次处理
+// 参数:
+//   - n: 工作协程数
+//
+// 返回值:
+//   - HandlerOpt: 回调式订阅选项
+func WithHandlerConcurrency(n int) HandlerOpt {
+	return func(o *handlerOptions) error {
+		if n <= 0 {
+			logger.Warnf("处理函数的并发数必须为正数")
+			return fmt.Errorf("处理函数的并发数必须为正数")
+		}
+		o.concurrency = n
+		return nil
+	}
+}
+
+// WithHandlerSubOpts 设置创建（以及重新创建）订阅时使用的订阅选项
+// 参数:
+//   - opts: 订阅选项
+//
+// 返回值:
+//   - HandlerOpt: 回调式订阅选项
+func WithHandlerSubOpts(opts ...SubOpt) HandlerOpt {
+	return func(o *handlerOptions) error {
+		o.subOpts = append(o.subOpts, opts...)
+		return nil
+	}
+}
+
+// HandlerSubscription 是回调式订阅，由 Topic.SubscribeHandler 创建
+type HandlerSubscription struct {
+	t       *Topic
+	handler MessageHandler
+	opts    *handlerOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // 接收循环和所有工作协程退出后关闭
+}
+
+// SubscribeHandler 订阅主题，并用工作池调用 handler 处理收到的消息。
+// 上下文取消或调用 Close 后停止接收消息，并等待正在执行的处理函数返回；
+// 订阅意外结束（例如被排空或停止子系统取消）时自动重新订阅，直到主题关闭。
+// 参数:
+//   - ctx: 上下文，取消后停止订阅
+//   - handler: 消息处理函数
+//   - opts: 回调式订阅选项
+//
+// 返回值:
+//   - *HandlerSubscription: 回调式订阅
+//   - error: 错误信息，如果有的话
+func (t *Topic) SubscribeHandler(ctx context.Context, handler MessageHandler, opts ...HandlerOpt) (*HandlerSubscription, error) {
+	if handler == nil {
+		logger.Warnf("消息处理函数不能为空")
+		return nil, fmt.Errorf("消息处理函数不能为空")
+	}
+
+	o := &handlerOptions{concurrency: 1}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, err := t.Subscribe(o.subOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	hctx, cancel := context.WithCancel(ctx)
+	hs := &HandlerSubscription{
+		t:       t,
+		handler: handler,
+		opts:    o,
+		ctx:     hctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go hs.run(sub)
+
+	return hs, nil
+}
+
+// Close 停止接收消息，并等待正在执行的处理函数返回
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (hs *HandlerSubscription) Close() error {
+	hs.cancel()
+	<-hs.done
+	return nil
+}
+
+// Done 返回在回调式订阅完全停止后关闭的通道
+// 返回值:
+//   - <-chan struct{}: 停止通知通道
+func (hs *HandlerSubscription) Done() <-chan struct{} {
+	return hs.done
+}
+
+// run 接收消息并分发给工作协程，订阅意外结束时重新订阅
+// 参数:
+//   - sub: 初始订阅
+func (hs *HandlerSubscription) run(sub *Subscription) {
+	defer close(hs.done)
+
+	jobs := make(chan *Message)
+	var wg sync.WaitGroup
+	for i := 0; i < hs.opts.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				hs.invoke(msg)
+			}
+		}()
+	}
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
+	for {
+		msg, err := sub.Next(hs.ctx)
+		if err == nil {
+			select {
+			case jobs <- msg:
+			case <-hs.ctx.Done():
+			}
+			continue
+		}
+
+		if hs.ctx.Err() != nil {
+			sub.Cancel()
+			return
+		}
+
+		// 订阅意外结束，等待后重新订阅
+		logger.Debugf("主题 %s 的回调式订阅结束: %s; 重新订阅", hs.t.topic, err)
+		for {
+			select {
+			case <-time.After(handlerResubscribeDelay):
+			case <-hs.ctx.Done():
+				return
+			}
+
+			sub, err = hs.t.Subscribe(hs.opts.subOpts...)
+			if err == nil {
+				break
+			}
+			if errors.Is(err, ErrTopicClosed) || hs.t.p.ctx.Err() != nil {
+				logger.Debugf("无法重新订阅主题 %s: %s", hs.t.topic, err)
+				return
+			}
+		}
+	}
+}
+
+// invoke 调用处理函数，并恢复其中的 panic
+// 参数:
+//   - msg: 消息
+func (hs *HandlerSubscription) invoke(msg *Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("主题 %s 的消息处理函数 panic: %v", hs.t.topic, r)
+		}
+	}()
+
+	if err := hs.handler(msg); err != nil {
+		logger.Debugf("处理主题 %s 的消息 %s 失败: %s", hs.t.topic, msg.ID, err)
+	}
+}
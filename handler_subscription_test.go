@@ -0,0 +1,89 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSubscribeHandler 测试回调式订阅并发处理消息、恢复 panic，以及关闭后不再调用处理函数
+func TestSubscribeHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	if _, err := topics[1].SubscribeHandler(ctx, nil); err == nil {
+		t.Fatal("expected error for a nil handler")
+	}
+	if _, err := topics[1].SubscribeHandler(ctx, func(*Message) error { return nil }, WithHandlerConcurrency(0)); err == nil {
+		t.Fatal("expected error for a non-positive concurrency")
+	}
+
+	var handled, active, maxActive atomic.Int32
+	hs, err := topics[1].SubscribeHandler(ctx, func(msg *Message) error {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		handled.Add(1)
+		if string(msg.Data) == "msg0" {
+			panic("boom")
+		}
+		return fmt.Errorf("handler error")
+	}, WithHandlerConcurrency(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	for i := 0; i < 8; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second)
+
+	if n := handled.Load(); n != 8 {
+		t.Fatalf("expected 8 handled messages, got %d", n)
+	}
+	if n := maxActive.Load(); n < 2 || n > 4 {
+		t.Fatalf("expected between 2 and 4 concurrent handlers, got %d", n)
+	}
+
+	if err := hs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-hs.Done():
+	default:
+		t.Fatal("expected the handler subscription to be done after close")
+	}
+
+	if err := topics[0].Publish(ctx, []byte("late")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if n := handled.Load(); n != 8 {
+		t.Fatalf("expected no handler calls after close, got %d", n)
+	}
+}
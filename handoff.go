@@ -0,0 +1,183 @@
+// 作用：进程间订阅交接。
+// 功能：在零停机升级时，旧进程导出主题状态（订阅、中继、网格和回退），
+// 继承主机连接的新进程（在运行时支持的情况下，例如 socket activation）导入该状态，
+// 以便在应用重新订阅时优先恢复原来的网格并遵守原有的回退。状态带有显式版本，版本不匹配时拒绝导入。
+
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// HandoffVersion 是当前交接状态的版本
+const HandoffVersion = 1
+
+// ErrHandoffVersion 表示交接状态的版本与当前版本不匹配
+var ErrHandoffVersion = errors.New("交接状态版本不匹配")
+
+// HandoffState 是进程间交接的主题状态
+type HandoffState struct {
+	Version int            `json:"version"` // 交接状态版本
+	Topics  []HandoffTopic `json:"topics"`  // 主题状态列表
+}
+
+// HandoffTopic 是单个主题的交接状态
+type HandoffTopic struct {
+	Topic         string                `json:"topic"`             // 主题
+	Subscriptions int                   `json:"subscriptions"`     // 本地订阅数量
+	Relays        int                   `json:"relays"`            // 本地中继数量
+	Mesh          []peer.ID             `json:"mesh,omitempty"`    // 网格对等节点
+	Backoff       map[peer.ID]time.Time `json:"backoff,omitempty"` // 对等节点的回退到期时间
+}
+
+// DecodeHandoffState 解码交接状态，版本不匹配时返回 ErrHandoffVersion。
+// 新进程可以据此得知需要重新订阅的主题。
+// 参数:
+//   - blob: 交接状态数据
+//
+// 返回值:
+//   - *HandoffState: 交接状态
+//   - error: 错误信息，如果有的话
+func DecodeHandoffState(blob []byte) (*HandoffState, error) {
+	var version struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(blob, &version); err != nil {
+		logger.Warnf("解码交接状态失败: %s", err)
+		return nil, fmt.Errorf("解码交接状态失败: %w", err)
+	}
+	if version.Version != HandoffVersion {
+		logger.Warnf("交接状态版本 %d 与当前版本 %d 不匹配", version.Version, HandoffVersion)
+		return nil, fmt.Errorf("%w: %d != %d", ErrHandoffVersion, version.Version, HandoffVersion)
+	}
+
+	state := new(HandoffState)
+	if err := json.Unmarshal(blob, state); err != nil {
+		logger.Warnf("解码交接状态失败: %s", err)
+		return nil, fmt.Errorf("解码交接状态失败: %w", err)
+	}
+	return state, nil
+}
+
+// ExportHandoffState 导出当前的主题状态，用于交接给替换进程。
+// 返回值:
+//   - []byte: 交接状态数据
+//   - error: 错误信息，如果有的话
+func (p *PubSub) ExportHandoffState() ([]byte, error) {
+	out := make(chan *HandoffState, 1)
+	export := func() {
+		state := &HandoffState{Version: HandoffVersion}
+		gs, _ := p.rt.(*GossipSubRouter)
+		now := time.Now()
+
+		for topic := range p.myTopics {
+			ht := HandoffTopic{
+				Topic:         topic,
+				Subscriptions: len(p.mySubs[topic]),
+				Relays:        p.myRelays[topic],
+			}
+			if gs != nil {
+				for pid := range gs.mesh[topic] {
+					ht.Mesh = append(ht.Mesh, pid)
+				}
+				for pid, expire := range gs.backoff[topic] {
+					if expire.After(now) {
+						if ht.Backoff == nil {
+							ht.Backoff = make(map[peer.ID]time.Time)
+						}
+						ht.Backoff[pid] = expire
+					}
+				}
+			}
+			state.Topics = append(state.Topics, ht)
+		}
+		out <- state
+	}
+
+	select {
+	case p.eval <- export:
+		return json.Marshal(<-out)
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+}
+
+// WithHandoffState 是一个选项，用于导入旧进程通过 ExportHandoffState 导出的状态。
+// 导入时立即恢复仍未到期的回退；应用重新订阅主题时，gossipsub 路由器会优先将原来的网格对等节点加入网格。
+// 版本不匹配时返回 ErrHandoffVersion。
+// 参数:
+//   - blob: 交接状态数据
+//
+// 返回值:
+//   - Option: 配置选项
+func WithHandoffState(blob []byte) Option {
+	return func(p *PubSub) error {
+		state, err := DecodeHandoffState(blob)
+		if err != nil {
+			return err
+		}
+
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			return nil // 其他路由器没有需要恢复的网格状态
+		}
+
+		now := time.Now()
+		for _, ht := range state.Topics {
+			if len(ht.Mesh) > 0 {
+				gs.handoffMesh[ht.Topic] = ht.Mesh
+			}
+			for pid, expire := range ht.Backoff {
+				if !expire.After(now) {
+					continue
+				}
+				backoff, ok := gs.backoff[ht.Topic]
+				if !ok {
+					backoff = make(map[peer.ID]time.Time)
+					gs.backoff[ht.Topic] = backoff
+				}
+				backoff[pid] = expire
+			}
+		}
+		return nil
+	}
+}
+
+// handoffMeshPeers 返回主题交接前的网格中仍然符合条件的对等节点，最多 count 个。
+// 加入主题时原来的网格对等节点可能还没有重新宣布订阅，因此交接记录保留到加入后的第一个心跳，由心跳再尝试一次后清除。
+// 与 getGraftPeers 一样遵守网格多样性约束。只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+//   - members: 当前的网格成员
+//   - count: 最多返回的对等节点数量
+//   - filter: 对等节点过滤函数
+//
+// 返回值:
+//   - []peer.ID: 对等节点列表
+func (gs *GossipSubRouter) handoffMeshPeers(topic string, members map[peer.ID]struct{}, count int, filter func(peer.ID) bool) []peer.ID {
+	mesh, ok := gs.handoffMesh[topic]
+	if !ok || count <= 0 {
+		return nil
+	}
+
+	dc := gs.diversity.counts(members)
+	tmap := gs.p.topics[topic]
+	peers := make([]peer.ID, 0, len(mesh))
+	for _, pid := range mesh {
+		if len(peers) >= count {
+			break
+		}
+		if _, ok := tmap[pid]; !ok {
+			continue // 对等节点尚未连接或未订阅该主题
+		}
+		if gs.feature(GossipSubFeatureMesh, gs.peers[pid]) && filter(pid) && gs.p.peerFilter(pid, topic) && gs.diversity.admit(dc, pid) {
+			peers = append(peers, pid)
+		}
+	}
+	return peers
+}
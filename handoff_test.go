@@ -0,0 +1,120 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestHandoffState 测试交接状态的导出、导入和版本检查
+func TestHandoffState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getGossipsubs(ctx, hosts[:2])
+	connect(t, hosts[0], hosts[1])
+
+	for _, ps := range psubs {
+		topic, err := ps.Join("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 等待网格建立
+	time.Sleep(2 * time.Second)
+
+	blob, err := psubs[0].ExportHandoffState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	state, err := DecodeHandoffState(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Topics) != 1 {
+		t.Fatalf("expected 1 topic, got %d", len(state.Topics))
+	}
+	ht := state.Topics[0]
+	if ht.Topic != "test" || ht.Subscriptions != 1 {
+		t.Fatalf("unexpected topic state %+v", ht)
+	}
+	if len(ht.Mesh) != 1 || ht.Mesh[0] != hosts[1].ID() {
+		t.Fatalf("expected mesh to contain the connected peer, got %v", ht.Mesh)
+	}
+
+	// 新进程导入状态后记住原来的网格
+	ps := getGossipsub(ctx, hosts[2], WithHandoffState(blob))
+	gs := ps.rt.(*GossipSubRouter)
+	done := make(chan struct{})
+	ps.eval <- func() {
+		defer close(done)
+		if mesh := gs.handoffMesh["test"]; len(mesh) != 1 || mesh[0] != hosts[1].ID() {
+			t.Errorf("expected handoff mesh to be restored, got %v", mesh)
+		}
+	}
+	<-done
+
+	// 版本不匹配时拒绝导入
+	_, err = DecodeHandoffState([]byte(`{"version":2,"topics":[]}`))
+	if !errors.Is(err, ErrHandoffVersion) {
+		t.Fatalf("expected version mismatch error, got %v", err)
+	}
+	if _, err := NewGossipSub(ctx, hosts[3], WithHandoffState([]byte(`{"version":0}`))); err == nil {
+		t.Fatal("expected error for mismatched handoff version")
+	}
+	if _, err := DecodeHandoffState([]byte("garbage")); err == nil {
+		t.Fatal("expected error for malformed handoff state")
+	}
+}
+
+// TestHandoffMeshRetry 测试加入主题时原来的网格对等节点尚未重新订阅，交接记录保留到第一个心跳再尝试恢复
+func TestHandoffMeshRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	pid := hosts[1].ID()
+	blob, err := json.Marshal(&HandoffState{
+		Version: HandoffVersion,
+		Topics:  []HandoffTopic{{Topic: "test", Subscriptions: 1, Mesh: []peer.ID{pid}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := getGossipsub(ctx, hosts[0], WithHandoffState(blob))
+	gs := ps.rt.(*GossipSubRouter)
+	done := make(chan struct{})
+	ps.eval <- func() {
+		defer close(done)
+
+		gs.Join("test")
+		if _, ok := gs.mesh["test"][pid]; ok {
+			t.Error("expected the unsubscribed handoff peer to stay out of the mesh")
+		}
+		if _, ok := gs.handoffMesh["test"]; !ok {
+			t.Error("expected the handoff mesh to be kept until the next heartbeat")
+		}
+
+		// 原来的网格对等节点重新宣布订阅后，由心跳恢复到网格中
+		gs.peers[pid] = GossipSubID_v11
+		ps.topics["test"] = map[peer.ID]struct{}{pid: {}}
+		gs.heartbeat()
+		if _, ok := gs.mesh["test"][pid]; !ok {
+			t.Error("expected the handoff peer to be grafted on the heartbeat")
+		}
+		if _, ok := gs.handoffMesh["test"]; ok {
+			t.Error("expected the handoff mesh to be cleared after the heartbeat")
+		}
+	}
+	<-done
+}
@@ -0,0 +1,109 @@
+// 作用：消息头部。
+// 功能：为消息附加应用定义的键值头部（内容类型、模式版本、应用头部等），
+// 头部随消息签名，并在发布和接收时按条目数和总字节数限制大小，超出限制的消息被拒绝。
+
+package pubsub
+
+import "fmt"
+
+const (
+	// DefaultMaxHeaders 是默认允许的最大头部条目数
+	DefaultMaxHeaders = 32
+	// DefaultMaxHeadersSize 是默认允许的头部键值总字节数
+	DefaultMaxHeadersSize = 4 * 1024
+)
+
+// 常用的头部键
+const (
+	HeaderContentType   = "content-type"   // 内容类型
+	HeaderSchemaVersion = "schema-version" // 模式版本
+)
+
+// WithMaxHeaders 设置消息头部的最大条目数和键值总字节数，适用于发布和接收的消息
+// 参数:
+//   - entries: 最大头部条目数
+//   - size: 头部键值总字节数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMaxHeaders(entries, size int) Option {
+	return func(ps *PubSub) error {
+		if entries <= 0 || size <= 0 {
+			logger.Warnf("头部限制必须为正数")
+			return fmt.Errorf("头部限制必须为正数")
+		}
+		ps.maxHeaders = entries
+		ps.maxHeadersSize = size
+		return nil
+	}
+}
+
+// WithHeader 为消息附加一个头部，头部参与签名，接收方可以通过 Message.Header 读取
+// 参数:
+//   - key: 头部的键，不能为空
+//   - value: 头部的值
+//
+// 返回值:
+//   - PubOpt: 发布选项
+func WithHeader(key, value string) PubOpt {
+	return func(pub *PublishOptions) error {
+		if key == "" {
+			logger.Warnf("头部的键不能为空")
+			return fmt.Errorf("头部的键不能为空")
+		}
+		if pub.headers == nil {
+			pub.headers = make(map[string]string)
+		}
+		pub.headers[key] = value
+		return nil
+	}
+}
+
+// WithHeaders 为消息附加多个头部，与已有的同名头部冲突时覆盖
+// 参数:
+//   - headers: 头部键值
+//
+// 返回值:
+//   - PubOpt: 发布选项
+func WithHeaders(headers map[string]string) PubOpt {
+	return func(pub *PublishOptions) error {
+		for k, v := range headers {
+			if err := WithHeader(k, v)(pub); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Header 返回消息的头部值，完整的头部可以通过 GetHeaders 读取，但不应修改
+// 参数:
+//   - key: 头部的键
+//
+// 返回值:
+//   - string: 头部的值，不存在时为空
+//   - bool: 头部是否存在
+func (m *Message) Header(key string) (string, bool) {
+	v, ok := m.GetHeaders()[key]
+	return v, ok
+}
+
+// checkHeaders 检查头部是否超出限制
+// 参数:
+//   - headers: 头部键值
+//
+// 返回值:
+//   - error: 超出限制时返回错误
+func (p *PubSub) checkHeaders(headers map[string]string) error {
+	if len(headers) > p.maxHeaders {
+		return fmt.Errorf("头部条目数 %d 超过限制 %d", len(headers), p.maxHeaders)
+	}
+	size := 0
+	for k, v := range headers {
+		size += len(k) + len(v)
+	}
+	if size > p.maxHeadersSize {
+		return fmt.Errorf("头部大小 %d 超过限制 %d", size, p.maxHeadersSize)
+	}
+	return nil
+}
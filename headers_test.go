@@ -0,0 +1,126 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestMessageHeaders 测试头部随消息发布和接收，以及超出限制的头部被拒绝
+func TestMessageHeaders(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts, WithMaxHeaders(2, 64))
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Publish(ctx, []byte("hello"), WithHeader("", "x")); err == nil {
+		t.Fatal("expected error for an empty header key")
+	}
+	if err := topic.Publish(ctx, []byte("hello"), WithHeaders(map[string]string{"a": "1", "b": "2", "c": "3"})); err == nil {
+		t.Fatal("expected error for too many headers")
+	}
+	if err := topic.Publish(ctx, []byte("hello"), WithHeader("a", strings.Repeat("x", 64))); err == nil {
+		t.Fatal("expected error for oversized headers")
+	}
+
+	err = topic.Publish(ctx, []byte("hello"), WithHeader(HeaderContentType, "text/plain"), WithHeader(HeaderSchemaVersion, "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := msg.Header(HeaderContentType); !ok || v != "text/plain" {
+		t.Fatalf("unexpected content type header %q", v)
+	}
+	if v, _ := msg.Header(HeaderSchemaVersion); v != "2" {
+		t.Fatalf("unexpected schema version header %q", v)
+	}
+	if _, ok := msg.Header("missing"); ok {
+		t.Fatal("expected missing header to be absent")
+	}
+}
+
+// TestHeadersSigned 测试头部参与签名，序列化与键顺序无关
+func TestHeadersSigned(t *testing.T) {
+	privk, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(privk.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := pb.Message{
+		Data:    []byte("abc"),
+		Topic:   "foo",
+		From:    []byte(id),
+		Seqno:   []byte("123"),
+		Headers: map[string]string{"a": "1", "b": "2", "c": "3"},
+	}
+	if err := signMessage(id, privk, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	// 往返序列化后签名仍然有效
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded pb.Message
+	if err := decoded.Unmarshal(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyMessageSignature(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded.Headers["b"] = "tampered"
+	if err := verifyMessageSignature(&decoded); err == nil {
+		t.Fatal("expected tampered headers to fail signature verification")
+	}
+}
+
+// TestHeadersStableMarshal 测试头部按键排序序列化，多次序列化得到相同的字节
+func TestHeadersStableMarshal(t *testing.T) {
+	m := pb.Message{Topic: "foo", Headers: make(map[string]string)}
+	for i := 0; i < 32; i++ {
+		m.Headers[fmt.Sprintf("k%02d", i)] = fmt.Sprintf("v%d", i)
+	}
+
+	first, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		data, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, first) {
+			t.Fatal("expected headers to marshal to the same bytes every time")
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// 作用：主题历史消息。
+// 功能：允许进程内的新订阅者读取主题最近经过验证的消息，而不必等待新的流量。
+// 配置了消息存储时从存储读取，否则从 gossipsub 的消息缓存读取。
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoHistory 表示既没有消息存储，路由器也不维护消息缓存，无法提供历史消息
+var ErrNoHistory = errors.New("没有可以提供历史消息的消息存储或消息缓存")
+
+// History 按收到顺序返回主题在 since 之后收到的最近 limit 条经过验证的消息。
+// 配置了 WithMessageStore 时从消息存储读取，只包含已加入主题的消息，且不包含消息的转发者；
+// 否则从 gossipsub 的消息缓存读取，只包含消息缓存窗口内的消息。加密主题返回解密后的消息。
+// 参数:
+//   - ctx: 上下文
+//   - since: 起始时间，零值表示不限制
+//   - limit: 最大消息数，小于等于 0 表示不限制
+//
+// 返回值:
+//   - []*Message: 消息列表
+//   - error: 错误信息，如果有的话
+func (t *Topic) History(ctx context.Context, since time.Time, limit int) ([]*Message, error) {
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
+		return nil, ErrTopicClosed
+	}
+
+	type result struct {
+		msgs []*Message
+		err  error
+	}
+	out := make(chan result, 1)
+	get := func() {
+		msgs, err := t.p.history(t.topic, since)
+		if limit > 0 && len(msgs) > limit {
+			msgs = msgs[len(msgs)-limit:]
+		}
+
+		// 加密主题只返回解密后的副本
+		decrypted := make([]*Message, 0, len(msgs))
+		for _, msg := range msgs {
+			if msg, ok := t.p.decryptMessage(msg); ok {
+				decrypted = append(decrypted, msg)
+			}
+		}
+		out <- result{decrypted, err}
+	}
+
+	select {
+	case t.p.eval <- get:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.p.ctx.Done():
+		return nil, t.p.ctx.Err()
+	}
+
+	res := <-out
+	return res.msgs, res.err
+}
+
+// history 返回主题在 since 之后收到的消息。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+//   - since: 起始时间
+//
+// 返回值:
+//   - []*Message: 消息列表
+//   - error: 错误信息
+func (p *PubSub) history(topic string, since time.Time) ([]*Message, error) {
+	if p.store != nil {
+		stored, err := p.store.Since(topic, "", 0)
+		if err != nil {
+			return nil, err
+		}
+		var msgs []*Message
+		for _, sm := range stored {
+			if sm.Received.Before(since) {
+				continue
+			}
+			msgs = append(msgs, &Message{Message: sm.Message, ID: sm.ID})
+		}
+		return msgs, nil
+	}
+
+	gs, ok := p.rt.(*GossipSubRouter) // 只有 gossipsub 路由器维护消息缓存
+	if !ok {
+		return nil, ErrNoHistory
+	}
+	return gs.mcache.History(topic, since), nil
+}
@@ -0,0 +1,71 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestTopicHistory 测试从消息缓存读取主题历史消息，以及没有消息缓存时返回 ErrNoHistory
+func TestTopicHistory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts[:2])
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	for i := 0; i < 5; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		assertReceive(t, subs[1], []byte(fmt.Sprintf("msg%d", i)))
+	}
+
+	msgs, err := topics[1].History(ctx, time.Time{}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(msgs))
+	}
+	for i, msg := range msgs {
+		if want := fmt.Sprintf("msg%d", i+2); string(msg.Data) != want {
+			t.Fatalf("expected %q at %d, got %q", want, i, msg.Data)
+		}
+	}
+
+	msgs, err = topics[1].History(ctx, time.Now().Add(time.Minute), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 0 {
+		t.Fatalf("expected no messages in the future, got %d", len(msgs))
+	}
+
+	topic, err := getPubsub(ctx, hosts[2]).Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.History(ctx, time.Time{}, 0); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("expected ErrNoHistory without a message cache, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// 作用：混合路由模式。
+// 功能：gossipsub 路由器按主题在洪泛和网格之间切换：主题的对等节点数低于阈值时，消息直接洪泛给所有订阅的对等节点，
+// 且不再发出 IHAVE gossip；达到阈值后恢复基于网格的转发和 gossip。网格在两种模式下都照常维护，因此切换是无缝的。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/host"
+)
+
+// DefaultHybridThreshold 是 NewHybridSub 使用的默认阈值，与默认的网格上限 Dhi 相同
+var DefaultHybridThreshold = GossipSubDhi
+
+// NewHybridSub 返回一个使用混合路由模式的 gossipsub PubSub 对象，阈值为 DefaultHybridThreshold，
+// 可以通过 WithHybridThreshold 修改
+// 参数:
+//   - ctx: 上下文
+//   - h: 主机
+//   - opts: 选项
+//
+// 返回值:
+//   - *PubSub: PubSub 对象
+//   - error: 错误信息，如果有的话
+func NewHybridSub(ctx context.Context, h host.Host, opts ...Option) (*PubSub, error) {
+	opts = append([]Option{WithHybridThreshold(DefaultHybridThreshold)}, opts...)
+	return NewGossipSub(ctx, h, opts...)
+}
+
+// WithHybridThreshold 是一个 gossipsub 路由器选项，主题的对等节点数低于 n 时洪泛该主题的消息，达到 n 时使用网格转发
+// 参数:
+//   - n: 切换到网格转发的对等节点数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithHybridThreshold(n int) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 gossipsub")
+			return fmt.Errorf("pubsub 路由器不是 gossipsub")
+		}
+		if n <= 0 {
+			logger.Warnf("混合路由阈值必须为正数")
+			return fmt.Errorf("混合路由阈值必须为正数")
+		}
+
+		gs.hybridThreshold = n
+		return nil
+	}
+}
+
+// hybridFlood 返回主题当前是否处于洪泛模式
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - bool: 主题的对等节点数低于混合路由阈值时返回 true
+func (gs *GossipSubRouter) hybridFlood(topic string) bool {
+	return gs.hybridThreshold > 0 && len(gs.p.topics[topic]) < gs.hybridThreshold
+}
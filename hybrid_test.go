@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestHybridFlood 测试主题在对等节点数达到阈值时从洪泛切换到网格
+func TestHybridFlood(t *testing.T) {
+	gs := &GossipSubRouter{}
+	ps := &PubSub{rt: gs, topics: make(map[string]map[peer.ID]struct{})}
+	gs.p = ps
+
+	if err := WithHybridThreshold(0)(ps); err == nil {
+		t.Fatal("expected error for a non-positive threshold")
+	}
+	if gs.hybridFlood("foo") {
+		t.Fatal("expected no flooding without a threshold")
+	}
+	if err := WithHybridThreshold(3)(ps); err != nil {
+		t.Fatal(err)
+	}
+
+	ps.topics["foo"] = make(map[peer.ID]struct{})
+	for i := 0; i < 3; i++ {
+		if !gs.hybridFlood("foo") {
+			t.Fatalf("expected flooding with %d peers", i)
+		}
+		ps.topics["foo"][peer.ID(fmt.Sprint(i))] = struct{}{}
+	}
+	if gs.hybridFlood("foo") {
+		t.Fatal("expected mesh routing once the threshold is reached")
+	}
+}
+
+// TestHybridSubDelivery 测试混合路由模式下的小规模主题消息投递
+func TestHybridSubDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	var psubs []*PubSub
+	for _, h := range hosts {
+		ps, err := NewHybridSub(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+	connectAll(t, hosts)
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+	time.Sleep(time.Second)
+
+	for i, topic := range topics {
+		msg := []byte(fmt.Sprintf("message %d", i))
+		if err := topic.Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range subs {
+			assertReceive(t, sub, msg)
+		}
+	}
+}
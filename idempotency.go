@@ -0,0 +1,172 @@
+// 作用：幂等令牌与令牌日志。
+// 功能：支持发布者为消息附加幂等令牌，并提供可插拔的本地令牌日志，便于下游实现恰好一次处理。
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTokenCommitted 表示幂等令牌已经提交过
+var ErrTokenCommitted = errors.New("幂等令牌已提交")
+
+// TokenJournal 是幂等令牌日志接口，用于记录已经处理过的令牌。
+// 实现可以将令牌持久化到数据库中，并在 Commit 中与业务副作用放在同一个事务里提交，
+// 从而保证令牌与副作用的原子性。
+type TokenJournal interface {
+	// Contains 返回给定主题下的令牌是否已经提交。
+	// 该方法在验证管道中调用而不是在事件循环中调用，实现可以访问持久化存储，但会占用验证工作协程。
+	Contains(topic, token string) (bool, error)
+
+	// Commit 执行副作用并原子地提交令牌。
+	// 如果令牌已经提交，应返回 ErrTokenCommitted 且不执行副作用；
+	// 如果副作用返回错误，则不能提交令牌。
+	Commit(topic, token string, effect func() error) error
+}
+
+// MemoryTokenJournal 是基于内存的令牌日志实现，适用于测试或不需要持久化的场景
+type MemoryTokenJournal struct {
+	mx     sync.Mutex                     // 保护 tokens 的互斥锁
+	tokens map[string]map[string]struct{} // 主题 -> 已提交的令牌集合
+}
+
+// NewMemoryTokenJournal 创建一个新的内存令牌日志
+// 返回值:
+//   - *MemoryTokenJournal: 内存令牌日志实例
+func NewMemoryTokenJournal() *MemoryTokenJournal {
+	return &MemoryTokenJournal{
+		tokens: make(map[string]map[string]struct{}),
+	}
+}
+
+// Contains 返回给定主题下的令牌是否已经提交
+// 参数:
+//   - topic: 主题
+//   - token: 幂等令牌
+//
+// 返回值:
+//   - bool: 令牌是否已提交
+//   - error: 错误信息
+func (j *MemoryTokenJournal) Contains(topic, token string) (bool, error) {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	_, ok := j.tokens[topic][token]
+	return ok, nil
+}
+
+// Commit 执行副作用并提交令牌
+// 参数:
+//   - topic: 主题
+//   - token: 幂等令牌
+//   - effect: 需要与令牌一起提交的副作用，可以为 nil
+//
+// 返回值:
+//   - error: 令牌已提交时返回 ErrTokenCommitted，副作用失败时返回其错误
+func (j *MemoryTokenJournal) Commit(topic, token string, effect func() error) error {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+
+	tokens, ok := j.tokens[topic]
+	if !ok {
+		tokens = make(map[string]struct{})
+		j.tokens[topic] = tokens
+	}
+
+	if _, ok := tokens[token]; ok {
+		return ErrTokenCommitted
+	}
+
+	if effect != nil {
+		if err := effect(); err != nil {
+			return err // 副作用失败，不提交令牌
+		}
+	}
+
+	tokens[token] = struct{}{}
+	return nil
+}
+
+// WithTokenJournal 设置幂等令牌日志。
+// 设置后，携带已提交令牌的消息将不再投递给订阅者，
+// 并且可以通过 PubSub.CommitMessage 原子地提交令牌与副作用。
+// 参数:
+//   - journal: 令牌日志
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTokenJournal(journal TokenJournal) Option {
+	return func(p *PubSub) error {
+		if journal == nil {
+			logger.Warnf("令牌日志不能为空")
+			return fmt.Errorf("令牌日志不能为空")
+		}
+		p.tokenJournal = journal
+		return nil
+	}
+}
+
+// IdempotencyToken 返回消息携带的幂等令牌，如果没有则返回空字符串
+// 返回值:
+//   - string: 幂等令牌
+func (m *Message) IdempotencyToken() string {
+	return m.GetMetadata().GetIdempotencyToken()
+}
+
+// CommitMessage 执行消息对应的副作用，并在令牌日志中原子地提交消息的幂等令牌。
+// 如果消息没有携带令牌，则直接执行副作用。
+// 参数:
+//   - msg: 已接收的消息
+//   - effect: 处理消息产生的副作用
+//
+// 返回值:
+//   - error: 令牌已提交时返回 ErrTokenCommitted，未配置令牌日志或副作用失败时返回错误
+func (p *PubSub) CommitMessage(msg *Message, effect func() error) error {
+	token := msg.IdempotencyToken()
+	if token == "" {
+		if effect == nil {
+			return nil
+		}
+		return effect()
+	}
+
+	if p.tokenJournal == nil {
+		logger.Warnf("未配置令牌日志")
+		return fmt.Errorf("未配置令牌日志")
+	}
+
+	return p.tokenJournal.Commit(msg.GetTopic(), token, effect)
+}
+
+// needsTokenCheck 返回消息是否携带幂等令牌且配置了令牌日志，这样的消息需要经过验证管道查询令牌日志
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - bool: 是否需要查询令牌日志
+func (p *PubSub) needsTokenCheck(msg *Message) bool {
+	return p.tokenJournal != nil && msg.IdempotencyToken() != ""
+}
+
+// tokenCommitted 返回消息携带的幂等令牌是否已经提交。
+// 在验证管道中调用，不在 processLoop 中调用。
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - bool: 令牌是否已提交
+func (p *PubSub) tokenCommitted(msg *Message) bool {
+	if !p.needsTokenCheck(msg) {
+		return false
+	}
+
+	ok, err := p.tokenJournal.Contains(msg.GetTopic(), msg.IdempotencyToken())
+	if err != nil {
+		logger.Warnf("查询令牌日志失败: %s", err)
+		return false // 查询失败时仍然投递，由 CommitMessage 保证恰好一次
+	}
+
+	return ok
+}
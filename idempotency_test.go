@@ -0,0 +1,164 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestMemoryTokenJournal 测试内存令牌日志的提交语义
+func TestMemoryTokenJournal(t *testing.T) {
+	j := NewMemoryTokenJournal()
+
+	ok, err := j.Contains("foo", "t1")
+	if err != nil || ok {
+		t.Fatalf("expected token to be absent, got %v %v", ok, err)
+	}
+
+	// 副作用失败时不应提交令牌
+	effectErr := errors.New("boom")
+	if err := j.Commit("foo", "t1", func() error { return effectErr }); err != effectErr {
+		t.Fatalf("expected effect error, got %v", err)
+	}
+	if ok, _ := j.Contains("foo", "t1"); ok {
+		t.Fatal("token should not be committed after failed effect")
+	}
+
+	calls := 0
+	effect := func() error {
+		calls++
+		return nil
+	}
+	if err := j.Commit("foo", "t1", effect); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Commit("foo", "t1", effect); err != ErrTokenCommitted {
+		t.Fatalf("expected ErrTokenCommitted, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected effect to run once, ran %d times", calls)
+	}
+
+	// 令牌按主题隔离
+	if ok, _ := j.Contains("bar", "t1"); ok {
+		t.Fatal("token should be scoped by topic")
+	}
+}
+
+// TestIdempotencyTokenDelivery 测试幂等令牌的传播以及已提交令牌的过滤
+func TestIdempotencyTokenDelivery(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	journal := NewMemoryTokenJournal()
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1], WithTokenJournal(journal)),
+	}
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	if err := topic.Publish(ctx, []byte("first"), WithIdempotencyToken("t1")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.IdempotencyToken() != "t1" {
+		t.Fatalf("expected token t1, got %q", msg.IdempotencyToken())
+	}
+	if err := psubs[1].CommitMessage(msg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := psubs[1].CommitMessage(msg, nil); err != ErrTokenCommitted {
+		t.Fatalf("expected ErrTokenCommitted, got %v", err)
+	}
+
+	// 相同令牌的重发不应再投递
+	if err := topic.Publish(ctx, []byte("retry"), WithIdempotencyToken("t1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Publish(ctx, []byte("second"), WithIdempotencyToken("t2")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err = sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "second" {
+		t.Fatalf("expected message with token t2, got %q", msg.Data)
+	}
+
+	if err := topic.Publish(ctx, []byte("bad"), WithIdempotencyToken("")); err == nil {
+		t.Fatal("expected error for empty token")
+	}
+}
+
+// loopProbeJournal 在 Contains 中检查自己是否在事件循环中被调用
+type loopProbeJournal struct {
+	*MemoryTokenJournal
+
+	ps     atomic.Pointer[PubSub]
+	onLoop atomic.Bool
+}
+
+// Contains 实现 TokenJournal 接口，在事件循环中调用时无法提交求值函数
+func (j *loopProbeJournal) Contains(topic, token string) (bool, error) {
+	select {
+	case j.ps.Load().eval <- func() {}:
+	case <-time.After(time.Second):
+		j.onLoop.Store(true)
+	}
+	return j.MemoryTokenJournal.Contains(topic, token)
+}
+
+// TestTokenJournalOffLoop 测试令牌日志在验证管道中查询，不阻塞事件循环
+func TestTokenJournalOffLoop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	journal := &loopProbeJournal{MemoryTokenJournal: NewMemoryTokenJournal()}
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1], WithTokenJournal(journal)),
+	}
+	journal.ps.Store(psubs[1])
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	if err := topic.Publish(ctx, []byte("first"), WithIdempotencyToken("t1")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("first"))
+
+	if journal.onLoop.Load() {
+		t.Fatal("expected the token journal to be queried off the event loop")
+	}
+}
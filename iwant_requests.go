@@ -0,0 +1,215 @@
+// 作用：跟踪进行中的 IWANT 请求。
+// 功能：记录通过 IWANT 获取中的消息 ID（请求时长、重试次数、被请求的对等节点），
+// 支持应用查询和取消这些请求，并在离开主题时自动取消，避免应用不再关心的消息继续占用带宽。
+
+package pubsub
+
+import (
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// IWantRequest 描述一个进行中的 IWANT 请求
+type IWantRequest struct {
+	MsgID   string        // 消息 ID
+	Topic   string        // 广告该消息的主题
+	Age     time.Duration // 距第一次请求的时长
+	Retries int           // 第一次请求之后再次向其他对等节点请求的次数
+	Peers   []peer.ID     // 被请求过的对等节点
+}
+
+// iwantRequest 是路由器内部记录的进行中的 IWANT 请求
+type iwantRequest struct {
+	topic   string               // 主题
+	first   time.Time            // 第一次请求的时间
+	last    time.Time            // 最近一次请求的时间
+	retries int                  // 重试次数
+	peers   map[peer.ID]struct{} // 被请求过的对等节点
+}
+
+// trackIWant 记录向对等节点请求的消息 ID。
+// 只从 processLoop 调用。
+// 参数:
+//   - p: 对等节点 ID
+//   - mids: 请求的消息 ID 列表
+//   - topics: 消息 ID 到主题的映射
+func (gs *GossipSubRouter) trackIWant(p peer.ID, mids []string, topics map[string]string) {
+	now := time.Now()
+	for _, mid := range mids {
+		req, ok := gs.iwantPending[mid]
+		if !ok {
+			req = &iwantRequest{
+				topic: topics[mid],
+				first: now,
+				peers: make(map[peer.ID]struct{}),
+			}
+			gs.iwantPending[mid] = req
+		} else if _, asked := req.peers[p]; !asked {
+			req.retries++
+		}
+		req.last = now
+		req.peers[p] = struct{}{}
+	}
+}
+
+// untrackIWant 在消息到达后移除对应的 IWANT 请求。
+// 只从 processLoop 调用。
+// 参数:
+//   - msg: 到达的消息
+func (gs *GossipSubRouter) untrackIWant(msg *Message) {
+	if len(gs.iwantPending) == 0 {
+		return
+	}
+	delete(gs.iwantPending, gs.p.idGen.ID(msg))
+}
+
+// cancelIWants 取消给定的 IWANT 请求；在已见消息缓存的存活时间内不再跟进这些消息的 IHAVE 广告。
+// 只从 processLoop 调用。
+// 参数:
+//   - mids: 消息 ID 列表
+//
+// 返回值:
+//   - int: 实际取消的进行中请求数
+func (gs *GossipSubRouter) cancelIWants(mids []string) int {
+	now := time.Now()
+	cancelled := 0
+	for _, mid := range mids {
+		if _, ok := gs.iwantPending[mid]; ok {
+			delete(gs.iwantPending, mid)
+			cancelled++
+		}
+		gs.iwantCancelled[mid] = now
+	}
+	gs.gossipTracer.cancelPromises(mids)
+	return cancelled
+}
+
+// cancelTopicIWants 取消主题的所有进行中的 IWANT 请求。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+func (gs *GossipSubRouter) cancelTopicIWants(topic string) {
+	var mids []string
+	for mid, req := range gs.iwantPending {
+		if req.topic == topic {
+			mids = append(mids, mid)
+		}
+	}
+	if len(mids) > 0 {
+		logger.Debugf("离开主题 %s: 取消 %d 个进行中的 IWANT 请求", topic, len(mids))
+		gs.cancelIWants(mids)
+	}
+}
+
+// expireIWants 清理超过跟进时间仍未响应的请求和过期的取消记录。
+// 只从 heartbeat 调用。
+func (gs *GossipSubRouter) expireIWants() {
+	now := time.Now()
+	for mid, req := range gs.iwantPending {
+		if now.Sub(req.last) > gs.params.IWantFollowupTime {
+			delete(gs.iwantPending, mid)
+		}
+	}
+	for mid, cancelled := range gs.iwantCancelled {
+		if now.Sub(cancelled) > gs.p.seenMsgTTL {
+			delete(gs.iwantCancelled, mid)
+		}
+	}
+}
+
+// cancelPromises 取消给定消息的承诺和 IWANT 响应跟踪，已取消的请求不再惩罚对等节点
+// 参数:
+//   - mids: 消息 ID 列表
+func (gt *gossipTracer) cancelPromises(mids []string) {
+	if gt == nil {
+		return
+	}
+
+	gt.Lock()
+	defer gt.Unlock()
+
+	for _, mid := range mids {
+		delete(gt.iwants, mid)
+
+		promises, ok := gt.promises[mid]
+		if !ok {
+			continue
+		}
+		delete(gt.promises, mid)
+
+		for p := range promises {
+			peerPromises := gt.peerPromises[p]
+			delete(peerPromises, mid)
+			if len(peerPromises) == 0 {
+				delete(gt.peerPromises, p)
+			}
+		}
+	}
+}
+
+// PendingIWants 返回当前通过 IWANT 获取中的消息。
+// 只有 gossipsub 路由器发送 IWANT，其他路由器返回空列表。
+// 返回值:
+//   - []IWantRequest: 进行中的 IWANT 请求列表
+func (p *PubSub) PendingIWants() []IWantRequest {
+	out := make(chan []IWantRequest, 1)
+	list := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- nil
+			return
+		}
+
+		now := time.Now()
+		res := make([]IWantRequest, 0, len(gs.iwantPending))
+		for mid, req := range gs.iwantPending {
+			peers := make([]peer.ID, 0, len(req.peers))
+			for pid := range req.peers {
+				peers = append(peers, pid)
+			}
+			res = append(res, IWantRequest{
+				MsgID:   mid,
+				Topic:   req.topic,
+				Age:     now.Sub(req.first),
+				Retries: req.retries,
+				Peers:   peers,
+			})
+		}
+		out <- res
+	}
+
+	select {
+	case p.eval <- list:
+		return <-out
+	case <-p.ctx.Done():
+		return nil
+	}
+}
+
+// CancelIWants 取消给定消息的 IWANT 请求。
+// 取消后不再向其他对等节点请求这些消息，也不会因对等节点未响应而惩罚它们；
+// 如果消息随后仍然到达，会被正常处理。
+// 参数:
+//   - mids: 消息 ID 列表
+//
+// 返回值:
+//   - int: 实际取消的进行中请求数
+func (p *PubSub) CancelIWants(mids ...string) int {
+	out := make(chan int, 1)
+	cancel := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- 0
+			return
+		}
+		out <- gs.cancelIWants(mids)
+	}
+
+	select {
+	case p.eval <- cancel:
+		return <-out
+	case <-p.ctx.Done():
+		return 0
+	}
+}
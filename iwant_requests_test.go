@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestPendingIWants 测试进行中的 IWANT 请求的查询、取消以及离开主题时的自动取消
+func TestPendingIWants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	gs := ps.rt.(*GossipSubRouter)
+
+	topic, err := ps.Join("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟两个对等节点广告同一批消息
+	ihave := func(p peer.ID, mids ...string) {
+		done := make(chan struct{})
+		ps.eval <- func() {
+			defer close(done)
+			gs.handleIHave(p, &pb.ControlMessage{
+				Ihave: []*pb.ControlIHave{{TopicID: "test", MessageIDs: mids}},
+			})
+		}
+		<-done
+	}
+	ihave(peer.ID("A"), "m1", "m2")
+	ihave(peer.ID("B"), "m1")
+
+	reqs := ps.PendingIWants()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 pending IWANTs, got %d", len(reqs))
+	}
+	for _, req := range reqs {
+		if req.Topic != "test" {
+			t.Fatalf("expected topic test, got %s", req.Topic)
+		}
+		switch req.MsgID {
+		case "m1":
+			if req.Retries != 1 || len(req.Peers) != 2 {
+				t.Fatalf("expected m1 to be retried once from 2 peers, got %d retries from %d peers", req.Retries, len(req.Peers))
+			}
+		case "m2":
+			if req.Retries != 0 || len(req.Peers) != 1 {
+				t.Fatalf("expected m2 to be requested once, got %d retries from %d peers", req.Retries, len(req.Peers))
+			}
+		}
+	}
+
+	// 取消后不再跟进该消息的 IHAVE
+	if n := ps.CancelIWants("m1", "unknown"); n != 1 {
+		t.Fatalf("expected 1 cancelled IWANT, got %d", n)
+	}
+	ihave(peer.ID("C"), "m1")
+	reqs = ps.PendingIWants()
+	if len(reqs) != 1 || reqs[0].MsgID != "m2" {
+		t.Fatalf("expected only m2 to be pending, got %v", reqs)
+	}
+
+	// 离开主题时自动取消
+	sub.Cancel()
+	for i := 0; len(ps.PendingIWants()) != 0; i++ {
+		if i == 100 {
+			t.Fatal("expected no pending IWANTs after leaving the topic")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
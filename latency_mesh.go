@@ -0,0 +1,99 @@
+// 作用：延迟感知的网格选择。
+// 功能：选择要 GRAFT 的对等节点时，除评分外还按主机测得的往返时间（延迟指数移动平均）加权，
+// 优先选择延迟较低的对等节点，为实时应用构建低延迟的网格。
+
+package pubsub
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// WithLatencyAwareMesh 是一个 gossipsub 路由器选项，选择 GRAFT 的对等节点时按 评分 - weight × 往返时间（秒）排序。
+// 尚未测得延迟的对等节点按其他候选节点的平均延迟计算。
+// 参数:
+//   - weight: 每秒往返时间扣除的分值，必须为正数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithLatencyAwareMesh(weight float64) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 gossipsub")
+			return fmt.Errorf("pubsub 路由器不是 gossipsub")
+		}
+		if weight <= 0 {
+			logger.Warnf("延迟权重必须为正数")
+			return fmt.Errorf("延迟权重必须为正数")
+		}
+
+		gs.latencyWeight = weight
+		return nil
+	}
+}
+
+// getGraftPeers 选择最多 count 个要 GRAFT 的对等节点。未启用延迟感知时随机选择，
+// 否则按评分和往返时间的加权值从高到低选择；启用网格多样性约束时跳过所在 ASN 或区域已达上限的对等节点。
+// 参数:
+//   - topic: 主题
+//   - members: 当前的网格成员
+//   - count: 需要的对等节点数量
+//   - filter: 过滤函数
+//
+// 返回值:
+//   - []peer.ID: 对等节点列表
+func (gs *GossipSubRouter) getGraftPeers(topic string, members map[peer.ID]struct{}, count int, filter func(peer.ID) bool) []peer.ID {
+	dc := gs.diversity.counts(members)
+	if gs.latencyWeight == 0 && dc == nil {
+		return gs.getPeers(topic, count, filter)
+	}
+
+	peers := gs.getPeers(topic, 0, filter) // 已经打乱，相同加权值的对等节点随机排列
+	if gs.latencyWeight > 0 {
+		gs.sortByLatency(peers)
+	}
+
+	selected := make([]peer.ID, 0, count)
+	for _, p := range peers {
+		if count > 0 && len(selected) >= count {
+			break
+		}
+		if gs.diversity.admit(dc, p) {
+			selected = append(selected, p)
+		}
+	}
+	return selected
+}
+
+// sortByLatency 按 评分 - 权重 × 往返时间 从高到低排序对等节点
+// 参数:
+//   - peers: 对等节点列表
+func (gs *GossipSubRouter) sortByLatency(peers []peer.ID) {
+	rtt := make(map[peer.ID]float64, len(peers))
+	var total float64
+	for _, p := range peers {
+		if d := gs.p.host.Peerstore().LatencyEWMA(p); d > 0 {
+			rtt[p] = d.Seconds()
+			total += d.Seconds()
+		}
+	}
+	unknown := 0.0
+	if len(rtt) > 0 {
+		unknown = total / float64(len(rtt))
+	}
+
+	value := make(map[peer.ID]float64, len(peers))
+	for _, p := range peers {
+		d, ok := rtt[p]
+		if !ok {
+			d = unknown
+		}
+		value[p] = gs.score.Score(p) - gs.latencyWeight*d
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		return value[peers[i]] > value[peers[j]]
+	})
+}
@@ -0,0 +1,46 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestLatencyAwareMesh 测试 GRAFT 候选按往返时间排序
+func TestLatencyAwareMesh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	if err := WithLatencyAwareMesh(0)(&PubSub{rt: &GossipSubRouter{}}); err == nil {
+		t.Fatal("expected error for a non-positive weight")
+	}
+	ps := getGossipsub(ctx, hosts[0], WithLatencyAwareMesh(100))
+
+	latencies := map[peer.ID]time.Duration{
+		hosts[1].ID(): 300 * time.Millisecond,
+		hosts[2].ID(): 10 * time.Millisecond,
+		hosts[3].ID(): 50 * time.Millisecond,
+	}
+	for pid, d := range latencies {
+		hosts[0].Peerstore().RecordLatency(pid, d)
+	}
+
+	res := make(chan []peer.ID, 1)
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		tmap := make(map[peer.ID]struct{})
+		for pid := range latencies {
+			tmap[pid] = struct{}{}
+			gs.peers[pid] = GossipSubID_v11
+		}
+		ps.topics["foo"] = tmap
+		res <- gs.getGraftPeers("foo", nil, 2, func(peer.ID) bool { return true })
+	}
+	peers := <-res
+	if len(peers) != 2 || peers[0] != hosts[2].ID() || peers[1] != hosts[3].ID() {
+		t.Fatalf("expected the two lowest-latency peers, got %v", peers)
+	}
+}
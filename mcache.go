@@ -5,6 +5,7 @@ package pubsub
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dep2p/go-dep2p/core/peer"
 )
@@ -37,6 +38,7 @@ type MessageCache struct {
 	history [][]CacheEntry             // 历史缓存
 	gossip  int                        // gossip 插槽数量
 	msgID   func(*Message) string      // 消息 ID 生成函数
+	budget  *memoryBudget              // 内存预算，为 nil 时不限制
 }
 
 // SetMsgIdFn 设置消息 ID 生成函数。
@@ -48,17 +50,29 @@ func (mc *MessageCache) SetMsgIdFn(msgID func(*Message) string) {
 
 // CacheEntry 表示消息缓存条目。
 type CacheEntry struct {
-	mid   string // 消息 ID
-	topic string // 主题名称
+	mid      string    // 消息 ID
+	topic    string    // 主题名称
+	received time.Time // 放入缓存的时间
 }
 
 // Put 将消息放入缓存。
 // 参数:
 //   - msg: 要放入缓存的消息
 func (mc *MessageCache) Put(msg *Message) {
-	mid := mc.msgID(msg)                                                               // 生成消息 ID
-	mc.msgs[mid] = msg                                                                 // 将消息存储到消息映射中
-	mc.history[0] = append(mc.history[0], CacheEntry{mid: mid, topic: msg.GetTopic()}) // 将缓存条目添加到历史的第一个插槽中
+	mid := mc.msgID(msg) // 生成消息 ID
+	if mc.budget != nil {
+		if old, ok := mc.msgs[mid]; ok {
+			mc.budget.release(int64(old.Size())) // 替换已缓存的消息时先释放其占用的内存预算
+		}
+		if !mc.budget.reserve(int64(msg.Size()), memClassGossip) {
+			// 内存预算不足时不再缓存消息，该消息不会出现在 gossip 中
+			delete(mc.msgs, mid)
+			delete(mc.peertx, mid)
+			return
+		}
+	}
+	mc.msgs[mid] = msg                                                                                       // 将消息存储到消息映射中
+	mc.history[0] = append(mc.history[0], CacheEntry{mid: mid, topic: msg.GetTopic(), received: time.Now()}) // 将缓存条目添加到历史的第一个插槽中
 }
 
 // Get 从缓存中获取消息。
@@ -116,10 +130,35 @@ func (mc *MessageCache) GetGossipIDs(topic string) []string {
 	return mids
 }
 
+// History 按放入顺序返回缓存中给定主题在 since 之后放入的消息。
+// 参数:
+//   - topic: 主题名称
+//   - since: 起始时间
+//
+// 返回值:
+//   - []*Message: 消息列表
+func (mc *MessageCache) History(topic string, since time.Time) []*Message {
+	var msgs []*Message
+	for i := len(mc.history) - 1; i >= 0; i-- {
+		for _, entry := range mc.history[i] {
+			if entry.topic != topic || entry.received.Before(since) {
+				continue
+			}
+			if msg, ok := mc.msgs[entry.mid]; ok {
+				msgs = append(msgs, msg)
+			}
+		}
+	}
+	return msgs
+}
+
 // Shift 移动缓存窗口，丢弃最旧的插槽并腾出空间存储新的消息。
 func (mc *MessageCache) Shift() {
 	last := mc.history[len(mc.history)-1] // 获取最旧的插槽
 	for _, entry := range last {
+		if msg, ok := mc.msgs[entry.mid]; ok && mc.budget != nil {
+			mc.budget.release(int64(msg.Size())) // 释放消息占用的内存预算
+		}
 		delete(mc.msgs, entry.mid)   // 从消息映射中删除消息
 		delete(mc.peertx, entry.mid) // 从对等节点事务映射中删除消息
 	}
@@ -0,0 +1,166 @@
+// 作用：内存预算。
+// 功能：为消息缓存、已见消息缓存、验证队列和出站队列提供共享的字节预算，
+// 在预算紧张时优先削减低优先级的负载，使嵌入式部署（移动端、IoT）拥有可预测的内存上限。
+
+package pubsub
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/dep2p/pubsub/timecache"
+)
+
+// memClass 表示内存预算的负载类别，类别越低越先被削减
+type memClass int
+
+const (
+	// memClassGossip 是消息缓存中用于 gossip 的消息，优先级最低
+	memClassGossip memClass = iota
+	// memClassValidation 是等待验证的入站消息
+	memClassValidation
+	// memClassOutbound 是等待发送的出站 RPC，优先级最高
+	memClassOutbound
+)
+
+var (
+	// memClassShare 是各类别负载可以使用的预算比例：
+	// 当总使用量超过该比例时，对应类别的新负载会被丢弃
+	memClassShare = [...]float64{
+		memClassGossip:     0.5,
+		memClassValidation: 0.8,
+		memClassOutbound:   1.0,
+	}
+
+	// MemoryBudgetSeenShare 是内存预算中划分给已见消息缓存的比例
+	MemoryBudgetSeenShare = 0.125
+
+	// MemoryBudgetSeenEntrySize 是估算的单个已见消息缓存条目占用的字节数
+	MemoryBudgetSeenEntrySize = int64(128)
+)
+
+// memoryBudget 是共享的内存预算
+type memoryBudget struct {
+	limit int64        // 共享预算上限（字节）
+	used  atomic.Int64 // 已使用的字节数
+}
+
+// reserve 为给定类别的负载预留内存。nil 预算总是成功。
+// 参数:
+//   - n: 字节数
+//   - class: 负载类别
+//
+// 返回值:
+//   - bool: 是否预留成功
+func (b *memoryBudget) reserve(n int64, class memClass) bool {
+	if b == nil {
+		return true
+	}
+
+	max := int64(float64(b.limit) * memClassShare[class])
+	for {
+		used := b.used.Load()
+		if used+n > max {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+n) {
+			return true
+		}
+	}
+}
+
+// release 释放之前预留的内存。nil 预算不做任何操作。
+// 参数:
+//   - n: 字节数
+func (b *memoryBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+	b.used.Add(-n)
+}
+
+// WithMemoryBudget 为 PubSub 设置全局内存预算。
+// 消息缓存、验证队列和出站队列共享预算，已见消息缓存（除非通过 WithSeenCache 自定义）
+// 使用按 MemoryBudgetSeenShare 划分的固定容量。预算紧张时首先停止缓存 gossip 消息，
+// 然后丢弃入站消息，最后丢弃出站 RPC。
+// 参数:
+//   - bytes: 预算字节数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMemoryBudget(bytes int64) Option {
+	return func(p *PubSub) error {
+		if bytes <= 0 {
+			logger.Warnf("内存预算必须大于 0")
+			return fmt.Errorf("内存预算必须大于 0")
+		}
+
+		seen := int64(float64(bytes) * MemoryBudgetSeenShare)
+		p.memBudget = &memoryBudget{limit: bytes - seen}
+		p.memSeenCapacity = int(seen / MemoryBudgetSeenEntrySize)
+		return nil
+	}
+}
+
+// MemoryUsage 返回内存预算中当前已使用的字节数；未设置内存预算时返回 0
+// 返回值:
+//   - int64: 已使用的字节数
+func (p *PubSub) MemoryUsage() int64 {
+	if p.memBudget == nil {
+		return 0
+	}
+	return p.memBudget.used.Load()
+}
+
+// newBudgetedSeenCache 创建容量受内存预算限制的已见消息缓存
+// 返回值:
+//   - SeenCache: 已见消息缓存
+func (p *PubSub) newBudgetedSeenCache() SeenCache {
+	return timecache.NewBoundedTimeCache(p.seenMsgStrategy, p.seenMsgTTL, p.memSeenCapacity)
+}
+
+// reserveRPC 在 RPC 进入出站队列前为其预留内存预算
+// 参数:
+//   - rpc: 要发送的 RPC
+//
+// 返回值:
+//   - bool: 是否预留成功
+func (p *PubSub) reserveRPC(rpc *RPC) bool {
+	if p.memBudget == nil {
+		return true
+	}
+	if !p.memBudget.reserve(int64(rpc.Size()), memClassOutbound) {
+		return false
+	}
+	if !rpc.budgeted {
+		rpc.budgeted = true // 共享的 RPC 在第一次入队之前标记
+	}
+	return true
+}
+
+// releaseRPC 在 RPC 离开出站队列后释放其内存预算
+// 参数:
+//   - rpc: 已发送或丢弃的 RPC
+func (p *PubSub) releaseRPC(rpc *RPC) {
+	if rpc.budgeted {
+		p.memBudget.release(int64(rpc.Size()))
+	}
+}
+
+// releaseQueued 释放出站队列中剩余 RPC 的内存预算。
+// 只从 processLoop 在关闭队列之前调用。
+// 参数:
+//   - ch: 出站队列
+func (p *PubSub) releaseQueued(ch chan *RPC) {
+	if p.memBudget == nil {
+		return
+	}
+	for {
+		select {
+		case rpc := <-ch:
+			p.releaseRPC(rpc)
+		default:
+			return
+		}
+	}
+}
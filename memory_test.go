@@ -0,0 +1,118 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestMemoryBudgetReserve 测试内存预算按类别优先级削减负载
+func TestMemoryBudgetReserve(t *testing.T) {
+	b := &memoryBudget{limit: 100}
+
+	if !b.reserve(50, memClassGossip) {
+		t.Fatal("expected gossip reservation within share to succeed")
+	}
+	if b.reserve(1, memClassGossip) {
+		t.Fatal("expected gossip reservation beyond share to fail")
+	}
+	if !b.reserve(30, memClassValidation) {
+		t.Fatal("expected validation reservation within share to succeed")
+	}
+	if b.reserve(1, memClassValidation) {
+		t.Fatal("expected validation reservation beyond share to fail")
+	}
+	if !b.reserve(20, memClassOutbound) {
+		t.Fatal("expected outbound reservation within budget to succeed")
+	}
+	if b.reserve(1, memClassOutbound) {
+		t.Fatal("expected outbound reservation beyond budget to fail")
+	}
+
+	b.release(100)
+	if b.used.Load() != 0 {
+		t.Fatalf("expected budget to be fully released, got %d", b.used.Load())
+	}
+
+	// nil 预算不做限制
+	var nb *memoryBudget
+	if !nb.reserve(1<<40, memClassGossip) {
+		t.Fatal("expected nil budget to accept everything")
+	}
+	nb.release(1)
+}
+
+// TestMessageCacheBudgetReplace 测试替换和移出消息缓存的条目时按实际缓存的消息释放内存预算
+func TestMessageCacheBudgetReplace(t *testing.T) {
+	mc := NewMessageCache(1, 2)
+	mc.budget = &memoryBudget{limit: 1 << 20}
+	mc.SetMsgIdFn(func(*Message) string { return "same" })
+
+	small := &Message{Message: makeTestMessage(1)}
+	large := &Message{Message: makeTestMessage(2)}
+	large.Data = make([]byte, 1024)
+
+	mc.Put(small)
+	mc.Put(large)
+	if used := mc.budget.used.Load(); used != int64(large.Size()) {
+		t.Fatalf("expected budget to account only the replacement, got %d want %d", used, large.Size())
+	}
+
+	mc.Shift()
+	mc.Shift()
+	if used := mc.budget.used.Load(); used != 0 {
+		t.Fatalf("expected budget to be fully released after shifting, got %d", used)
+	}
+}
+
+// TestWithMemoryBudget 测试设置内存预算后内存使用量保持在预算范围内
+func TestWithMemoryBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const budget = 64 * 1024
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithMemoryBudget(budget)),
+		getGossipsub(ctx, hosts[1]),
+	}
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	data := make([]byte, 1024)
+	for i := 0; i < 100; i++ {
+		if err := topic.Publish(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+		if used := psubs[0].MemoryUsage(); used > budget {
+			t.Fatalf("memory usage %d exceeds budget %d", used, budget)
+		}
+	}
+
+	if _, err := sub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 消息缓存中保留的消息计入预算
+	if psubs[0].MemoryUsage() == 0 {
+		t.Fatal("expected message cache to be accounted")
+	}
+	if psubs[1].MemoryUsage() != 0 {
+		t.Fatal("expected no accounting without a budget")
+	}
+
+	if err := WithMemoryBudget(0)(&PubSub{}); err == nil {
+		t.Fatal("expected error for non-positive budget")
+	}
+}
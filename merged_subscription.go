@@ -0,0 +1,212 @@
+// 作用：多主题合并订阅。
+// 功能：把多个主题的订阅投递到同一个通道，应用通过一个 Next 读取所有主题的消息，
+// 并用消息的主题区分来源，避免为每个主题分别维护协程和通道。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// mergedBufferSize 是合并订阅中每个主题占用的通道缓冲区大小
+const mergedBufferSize = 32
+
+// MergedSubscription 是多个主题的合并订阅，由 PubSub.SubscribeMany 创建
+type MergedSubscription struct {
+	p    *PubSub       // PubSub 实例
+	ch   chan *Message // 所有订阅共享的消息通道
+	live atomic.Int32  // 尚未释放的引用数，全部释放后关闭共享通道
+	err  error         // 最后一个引用释放时的错误
+
+	mx        sync.Mutex      // 保护以下字段
+	subs      []*Subscription // 每个主题的订阅
+	cancelled bool            // 是否已经取消
+}
+
+// SubscribeMany 订阅多个主题，并把所有主题的消息合并到一个订阅中。
+// 尚未加入的主题会被自动加入；任一主题订阅失败时取消已经创建的订阅。
+// 参数:
+//   - topics: 要订阅的主题
+//
+// 返回值:
+//   - *MergedSubscription: 合并订阅
+//   - error: 错误信息，如果有的话
+func (p *PubSub) SubscribeMany(topics []string) (*MergedSubscription, error) {
+	seen := make(map[string]struct{}, len(topics))
+	var unique []string
+	for _, topic := range topics {
+		if _, ok := seen[topic]; ok {
+			continue
+		}
+		seen[topic] = struct{}{}
+		unique = append(unique, topic)
+	}
+	if len(unique) == 0 {
+		logger.Warnf("合并订阅至少需要一个主题")
+		return nil, fmt.Errorf("合并订阅至少需要一个主题")
+	}
+
+	ms := newMergedSubscription(p, mergedBufferSize*len(unique))
+	for _, topic := range unique {
+		if err := ms.add(topic); err != nil {
+			logger.Warnf("合并订阅主题 %s 失败: %s", topic, err)
+			ms.Cancel()
+			return nil, err
+		}
+	}
+
+	return ms, nil
+}
+
+// newMergedSubscription 创建没有任何主题的合并订阅
+// 参数:
+//   - p: PubSub 实例
+//   - size: 共享通道的缓冲区大小
+//
+// 返回值:
+//   - *MergedSubscription: 合并订阅
+func newMergedSubscription(p *PubSub, size int) *MergedSubscription {
+	return &MergedSubscription{
+		p:  p,
+		ch: make(chan *Message, size),
+	}
+}
+
+// add 加入并订阅主题，使其消息投递到共享通道
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (ms *MergedSubscription) add(topic string) error {
+	t, _, err := ms.p.tryJoin(topic)
+	if err != nil {
+		return err
+	}
+
+	// 在订阅注册之前持有一个引用，避免共享通道在此期间被关闭
+	ms.mx.Lock()
+	if ms.cancelled {
+		ms.mx.Unlock()
+		return ErrSubscriptionCancelled
+	}
+	ms.live.Add(1)
+	ms.mx.Unlock()
+
+	sub, err := t.Subscribe(withMerged(ms))
+	if err != nil {
+		ms.unref(err)
+		return err
+	}
+
+	ms.mx.Lock()
+	cancelled := ms.cancelled
+	if !cancelled {
+		ms.subs = append(ms.subs, sub)
+	}
+	ms.mx.Unlock()
+
+	if cancelled {
+		sub.Cancel()
+		return ErrSubscriptionCancelled
+	}
+	return nil
+}
+
+// withMerged 是一个内部订阅选项，使订阅投递到合并订阅的共享通道
+// 参数:
+//   - ms: 合并订阅
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func withMerged(ms *MergedSubscription) SubOpt {
+	return func(sub *Subscription) error {
+		sub.ch = ms.ch
+		sub.merged = ms
+		return nil
+	}
+}
+
+// Topics 返回合并订阅包含的主题
+// 返回值:
+//   - []string: 主题列表
+func (ms *MergedSubscription) Topics() []string {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	topics := make([]string, 0, len(ms.subs))
+	for _, sub := range ms.subs {
+		topics = append(topics, sub.topic)
+	}
+	return topics
+}
+
+// Next 返回任一主题的下一条消息，消息所属的主题由 msg.GetTopic() 给出。
+// 所有主题的订阅都关闭后返回最后一个订阅的错误。
+// 参数:
+//   - ctx: 上下文，用于取消操作
+//
+// 返回值:
+//   - *Message: 下一条消息
+//   - error: 错误信息，如果有的话
+func (ms *MergedSubscription) Next(ctx context.Context) (*Message, error) {
+	select {
+	case msg, ok := <-ms.ch:
+		if !ok {
+			return nil, ms.err
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Dropped 返回所有主题因缓冲区溢出丢弃的消息总数
+// 返回值:
+//   - uint64: 丢弃的消息数
+func (ms *MergedSubscription) Dropped() uint64 {
+	ms.mx.Lock()
+	defer ms.mx.Unlock()
+
+	var n uint64
+	for _, sub := range ms.subs {
+		n += sub.Dropped()
+	}
+	return n
+}
+
+// Cancel 取消所有主题的订阅
+func (ms *MergedSubscription) Cancel() {
+	ms.mx.Lock()
+	ms.cancelled = true
+	subs := ms.subs
+	ms.mx.Unlock()
+
+	for _, sub := range subs {
+		sub.Cancel()
+	}
+}
+
+// unref 在事件循环中释放一个引用
+// 参数:
+//   - err: 如果这是最后一个引用，Next 返回的错误
+func (ms *MergedSubscription) unref(err error) {
+	select {
+	case ms.p.eval <- func() { ms.release(err) }:
+	case <-ms.p.ctx.Done():
+	}
+}
+
+// release 释放一个引用，最后一个引用释放后关闭共享通道。
+// 只从 processLoop 调用。
+// 参数:
+//   - err: 如果这是最后一个引用，Next 返回的错误
+func (ms *MergedSubscription) release(err error) {
+	if ms.live.Add(-1) == 0 {
+		ms.err = err
+		close(ms.ch)
+	}
+}
@@ -0,0 +1,65 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSubscribeMany 测试合并订阅收到所有主题的消息，并在取消后关闭
+func TestSubscribeMany(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	if _, err := psubs[1].SubscribeMany(nil); err == nil {
+		t.Fatal("expected error for no topics")
+	}
+
+	ms, err := psubs[1].SubscribeMany([]string{"a", "b", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topics := ms.Topics(); len(topics) != 2 {
+		t.Fatalf("expected 2 topics, got %v", topics)
+	}
+
+	var topics []*Topic
+	for _, name := range []string{"a", "b"} {
+		topic, err := psubs[0].Join(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	for _, topic := range topics {
+		if err := topic.Publish(ctx, []byte("hello "+topic.String())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		msg, err := ms.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[msg.GetTopic()] = string(msg.Data)
+	}
+	for _, name := range []string{"a", "b"} {
+		if got[name] != "hello "+name {
+			t.Fatalf("expected message for topic %s, got %q", name, got[name])
+		}
+	}
+
+	ms.Cancel()
+	if _, err := ms.Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected ErrSubscriptionCancelled after cancel, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// 作用：网格成员的地理和自治系统多样性约束。
+// 功能：通过可插拔的 PeerLocator 解析对等节点所在的自治系统（ASN）和区域，限制网格中来自同一 ASN 或区域的对等节点数，
+// 在主动 GRAFT 和接受 GRAFT 时生效，避免网格被单一网络运营商或地区的对等节点占据，提高抗分区能力。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// PeerLocation 是对等节点的网络位置
+type PeerLocation struct {
+	ASN    uint32 // 自治系统号，0 表示未知
+	Region string // 区域，空字符串表示未知
+}
+
+// PeerLocator 解析对等节点的网络位置。在事件循环中调用，应当从缓存中快速返回。
+type PeerLocator interface {
+	// Locate 返回对等节点的位置，无法解析时返回 false
+	Locate(p peer.ID) (PeerLocation, bool)
+}
+
+// PeerLocatorFunc 将函数适配为 PeerLocator
+type PeerLocatorFunc func(p peer.ID) (PeerLocation, bool)
+
+// Locate 实现 PeerLocator 接口
+func (f PeerLocatorFunc) Locate(p peer.ID) (PeerLocation, bool) { return f(p) }
+
+// meshDiversity 是网格多样性约束
+type meshDiversity struct {
+	locator      PeerLocator
+	maxPerASN    int // 每个 ASN 的网格对等节点上限，0 表示不限制
+	maxPerRegion int // 每个区域的网格对等节点上限，0 表示不限制
+}
+
+// meshDiversityOption 获取或创建 gossipsub 路由器的网格多样性约束
+func meshDiversityOption(ps *PubSub) (*meshDiversity, error) {
+	gs, ok := ps.rt.(*GossipSubRouter)
+	if !ok {
+		logger.Warnf("pubsub 路由器不是 gossipsub")
+		return nil, fmt.Errorf("pubsub 路由器不是 gossipsub")
+	}
+	if gs.diversity == nil {
+		gs.diversity = new(meshDiversity)
+	}
+	return gs.diversity, nil
+}
+
+// WithMeshDiversity 是一个 gossipsub 路由器选项，限制每个主题网格中来自同一 ASN 的对等节点数。
+// 需要同时通过 WithPeerLocator 设置位置解析器，无法解析位置的对等节点不受限制。
+// 参数:
+//   - maxPerASN: 每个 ASN 的网格对等节点上限
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMeshDiversity(maxPerASN int) Option {
+	return func(ps *PubSub) error {
+		if maxPerASN <= 0 {
+			logger.Warnf("每个 ASN 的网格对等节点上限必须为正数")
+			return fmt.Errorf("每个 ASN 的网格对等节点上限必须为正数")
+		}
+		md, err := meshDiversityOption(ps)
+		if err != nil {
+			return err
+		}
+		md.maxPerASN = maxPerASN
+		return nil
+	}
+}
+
+// WithMeshRegionDiversity 是一个 gossipsub 路由器选项，限制每个主题网格中来自同一区域的对等节点数
+// 参数:
+//   - maxPerRegion: 每个区域的网格对等节点上限
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMeshRegionDiversity(maxPerRegion int) Option {
+	return func(ps *PubSub) error {
+		if maxPerRegion <= 0 {
+			logger.Warnf("每个区域的网格对等节点上限必须为正数")
+			return fmt.Errorf("每个区域的网格对等节点上限必须为正数")
+		}
+		md, err := meshDiversityOption(ps)
+		if err != nil {
+			return err
+		}
+		md.maxPerRegion = maxPerRegion
+		return nil
+	}
+}
+
+// WithPeerLocator 是一个 gossipsub 路由器选项，设置网格多样性约束使用的位置解析器
+// 参数:
+//   - locator: 位置解析器
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerLocator(locator PeerLocator) Option {
+	return func(ps *PubSub) error {
+		if locator == nil {
+			logger.Warnf("位置解析器不能为空")
+			return fmt.Errorf("位置解析器不能为空")
+		}
+		md, err := meshDiversityOption(ps)
+		if err != nil {
+			return err
+		}
+		md.locator = locator
+		return nil
+	}
+}
+
+// diversityCounts 记录网格中每个 ASN 和区域的对等节点数
+type diversityCounts struct {
+	asn    map[uint32]int
+	region map[string]int
+}
+
+// counts 统计网格成员的位置分布，约束未启用时返回 nil
+// 参数:
+//   - members: 网格成员
+//
+// 返回值:
+//   - *diversityCounts: 位置分布
+func (md *meshDiversity) counts(members map[peer.ID]struct{}) *diversityCounts {
+	if md == nil || md.locator == nil {
+		return nil
+	}
+	dc := &diversityCounts{asn: make(map[uint32]int), region: make(map[string]int)}
+	for p := range members {
+		if loc, ok := md.locator.Locate(p); ok {
+			dc.add(loc)
+		}
+	}
+	return dc
+}
+
+// add 将位置计入分布
+func (dc *diversityCounts) add(loc PeerLocation) {
+	if loc.ASN != 0 {
+		dc.asn[loc.ASN]++
+	}
+	if loc.Region != "" {
+		dc.region[loc.Region]++
+	}
+}
+
+// admit 检查对等节点加入网格后是否仍满足约束，满足时将其计入分布
+// 参数:
+//   - dc: 当前网格的位置分布，为 nil 时总是允许
+//   - p: 对等节点
+//
+// 返回值:
+//   - bool: 是否允许加入
+func (md *meshDiversity) admit(dc *diversityCounts, p peer.ID) bool {
+	if dc == nil {
+		return true
+	}
+	loc, ok := md.locator.Locate(p)
+	if !ok {
+		return true
+	}
+	if md.maxPerASN > 0 && loc.ASN != 0 && dc.asn[loc.ASN] >= md.maxPerASN {
+		return false
+	}
+	if md.maxPerRegion > 0 && loc.Region != "" && dc.region[loc.Region] >= md.maxPerRegion {
+		return false
+	}
+	dc.add(loc)
+	return true
+}
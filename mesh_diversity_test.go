@@ -0,0 +1,98 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestMeshDiversity 测试 GRAFT 候选受每个 ASN 的上限约束
+func TestMeshDiversity(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 5)
+	asn := map[peer.ID]uint32{
+		hosts[1].ID(): 64500,
+		hosts[2].ID(): 64500,
+		hosts[3].ID(): 64500,
+		hosts[4].ID(): 64501,
+	}
+	locator := PeerLocatorFunc(func(p peer.ID) (PeerLocation, bool) {
+		a, ok := asn[p]
+		return PeerLocation{ASN: a}, ok
+	})
+
+	if err := WithMeshDiversity(0)(&PubSub{rt: &GossipSubRouter{}}); err == nil {
+		t.Fatal("expected error for a non-positive limit")
+	}
+	ps := getGossipsub(ctx, hosts[0], WithMeshDiversity(1), WithPeerLocator(locator))
+
+	res := make(chan []peer.ID, 1)
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		tmap := make(map[peer.ID]struct{})
+		for pid := range asn {
+			tmap[pid] = struct{}{}
+			gs.peers[pid] = GossipSubID_v11
+		}
+		ps.topics["foo"] = tmap
+		res <- gs.getGraftPeers("foo", nil, 4, func(peer.ID) bool { return true })
+	}
+	peers := <-res
+
+	seen := make(map[uint32]int)
+	for _, p := range peers {
+		seen[asn[p]]++
+	}
+	if len(peers) != 2 || seen[64500] != 1 || seen[64501] != 1 {
+		t.Fatalf("expected one peer per ASN, got %v", peers)
+	}
+
+	// 网格中已有该 ASN 的对等节点时拒绝同一 ASN 的 GRAFT
+	md := &meshDiversity{locator: locator, maxPerASN: 1}
+	members := map[peer.ID]struct{}{hosts[1].ID(): {}}
+	if md.admit(md.counts(members), hosts[2].ID()) {
+		t.Fatal("expected a second peer from the same ASN to be rejected")
+	}
+	if !md.admit(md.counts(members), hosts[4].ID()) {
+		t.Fatal("expected a peer from another ASN to be admitted")
+	}
+}
+
+// TestMeshDiversityHandoff 测试恢复进程交接前的网格对等节点时同样受每个 ASN 的上限约束
+func TestMeshDiversityHandoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	asn := map[peer.ID]uint32{
+		hosts[1].ID(): 64500,
+		hosts[2].ID(): 64500,
+		hosts[3].ID(): 64501,
+	}
+	locator := PeerLocatorFunc(func(p peer.ID) (PeerLocation, bool) {
+		a, ok := asn[p]
+		return PeerLocation{ASN: a}, ok
+	})
+	ps := getGossipsub(ctx, hosts[0], WithMeshDiversity(1), WithPeerLocator(locator))
+
+	res := make(chan []peer.ID, 1)
+	ps.eval <- func() {
+		gs := ps.rt.(*GossipSubRouter)
+		tmap := make(map[peer.ID]struct{})
+		for pid := range asn {
+			tmap[pid] = struct{}{}
+			gs.peers[pid] = GossipSubID_v11
+		}
+		ps.topics["foo"] = tmap
+		gs.handoffMesh["foo"] = []peer.ID{hosts[1].ID(), hosts[2].ID(), hosts[3].ID()}
+		res <- gs.handoffMeshPeers("foo", nil, 3, func(peer.ID) bool { return true })
+	}
+	peers := <-res
+
+	if len(peers) != 2 || peers[0] != hosts[1].ID() || peers[1] != hosts[3].ID() {
+		t.Fatalf("expected one handoff peer per ASN, got %v", peers)
+	}
+}
@@ -0,0 +1,48 @@
+// 作用：订阅的消息过滤。
+// 功能：在事件循环中、放入订阅通道之前用订阅提供的谓词过滤消息，
+// 使高流量主题可以廉价地按发送者或载荷前缀等条件过滤，而不必先经过一次通道传递。
+
+package pubsub
+
+import "fmt"
+
+// MessageFilter 判断消息是否投递给订阅者，返回 false 时丢弃消息。
+// 过滤函数在事件循环中调用，必须快速返回且不能阻塞。
+type MessageFilter func(msg *Message) bool
+
+// WithFilter 是一个订阅选项，只向订阅者投递过滤函数接受的消息
+// 参数:
+//   - filter: 消息过滤函数
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func WithFilter(filter MessageFilter) SubOpt {
+	return func(sub *Subscription) error {
+		if filter == nil {
+			logger.Warnf("消息过滤函数不能为空")
+			return fmt.Errorf("消息过滤函数不能为空")
+		}
+		sub.filter = filter
+		return nil
+	}
+}
+
+// accept 判断订阅是否接受消息，过滤函数 panic 时视为不接受
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - bool: 是否投递给订阅者
+func (sub *Subscription) accept(msg *Message) (ok bool) {
+	if sub.filter == nil {
+		return true
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("主题 %s 的消息过滤函数 panic: %v", sub.topic, r)
+			ok = false
+		}
+	}()
+	return sub.filter(msg)
+}
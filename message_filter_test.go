@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSubscriptionFilter 测试订阅只收到过滤函数接受的消息，过滤函数 panic 时丢弃消息
+func TestSubscriptionFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	if _, err := topics[1].Subscribe(WithFilter(nil)); err == nil {
+		t.Fatal("expected error for a nil filter")
+	}
+
+	sub, err := topics[1].Subscribe(WithFilter(func(msg *Message) bool {
+		return bytes.HasPrefix(msg.Data, []byte("keep"))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	panicky, err := topics[1].Subscribe(WithFilter(func(msg *Message) bool {
+		panic("boom")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	for _, data := range []string{"drop-1", "keep-1", "drop-2", "keep-2"} {
+		if err := topics[0].Publish(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, want := range []string{"keep-1", "keep-2"} {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != want {
+			t.Fatalf("expected %q, got %q", want, msg.Data)
+		}
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer tcancel()
+	if msg, err := panicky.Next(tctx); err == nil {
+		t.Fatalf("expected no message for a panicking filter, got %q", msg.Data)
+	}
+}
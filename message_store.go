@@ -0,0 +1,396 @@
+// 作用：消息存储与追赶补发。
+// 功能：MessageStore 保留已加入主题的最近消息；重新加入主题的节点通过追赶协议向主题成员发送最后看到的消息 ID（游标），
+// 成员返回游标之后保存的消息。补发的消息经过正常的签名验证、验证器和去重后投递给订阅者，但不会再次转发。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+	"github.com/dep2p/go-dep2p/p2plib/msgio"
+)
+
+const (
+	// CatchUpID 是追赶补发请求/响应使用的协议 ID
+	CatchUpID = protocol.ID("/dep2p/pubsub/catchup/1.0.0")
+
+	// DefaultCatchUpLimit 是单次追赶补发返回的最大消息数量
+	DefaultCatchUpLimit = 256
+
+	// storeQueueSize 是等待写入消息存储的消息队列的容量
+	storeQueueSize = 1024
+)
+
+// CatchUpTimeout 是单次追赶补发请求的超时时间，也是订阅后等待主题成员出现的最长时间
+var CatchUpTimeout = 10 * time.Second
+
+// StoredMessage 是消息存储中保存的一条消息
+type StoredMessage struct {
+	ID       string      // 消息 ID
+	Message  *pb.Message // 原始消息，加密主题保存的是密文
+	Received time.Time   // 本地收到消息的时间
+}
+
+// MessageStore 是可插拔的消息存储接口，用于保留已加入主题的最近消息。
+// Put 由专用协程按投递顺序调用，不阻塞事件循环；Since 会被并发调用，实现必须是并发安全的。
+type MessageStore interface {
+	// Put 保存一条经过验证的消息，超出保留范围的旧消息由实现自行淘汰
+	Put(msg *StoredMessage) error
+
+	// Since 按保存顺序返回主题中游标之后保存的至多 limit 条消息。
+	// 游标为空或不在存储中时返回最新的 limit 条消息；limit 小于等于 0 表示不限制。
+	Since(topic, cursor string, limit int) ([]*StoredMessage, error)
+
+	// Close 释放存储资源
+	Close() error
+}
+
+// WithMessageStore 使用消息存储保留已加入主题的消息，并为其他节点的追赶补发请求提供服务。
+// PubSub 不会关闭存储，存储的生命周期由应用管理。
+// 参数:
+//   - store: 消息存储
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMessageStore(store MessageStore) Option {
+	return func(p *PubSub) error {
+		if store == nil {
+			logger.Warnf("消息存储不能为空")
+			return fmt.Errorf("消息存储不能为空")
+		}
+		p.store = store
+		p.storeQueue = make(chan *StoredMessage, storeQueueSize)
+		return nil
+	}
+}
+
+// WithCatchUp 是一个订阅选项，订阅建立后等待主题出现成员，并向成员请求最后看到的消息之后的消息
+func WithCatchUp() SubOpt {
+	return func(sub *Subscription) error {
+		sub.catchUp = true
+		return nil
+	}
+}
+
+// CatchUp 以本地存储中主题的最后一条消息为游标，向主题成员请求之后的消息，并将其注入验证管道。
+// 如果没有指定对等节点，则依次尝试当前主题中已连接的对等节点，直到有一个成功返回。
+// 参数:
+//   - ctx: 上下文
+//   - peers: 可选的目标对等节点列表
+//
+// 返回值:
+//   - int: 收到的消息数量，其中已经看到过的消息会在去重时丢弃
+//   - error: 错误信息，如果有的话
+func (t *Topic) CatchUp(ctx context.Context, peers ...peer.ID) (int, error) {
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
+		return 0, ErrTopicClosed
+	}
+
+	if len(peers) == 0 {
+		peers = t.p.ListPeers(t.topic)
+	}
+	if len(peers) == 0 {
+		return 0, fmt.Errorf("主题 %s 没有可以请求追赶补发的对等节点", t.topic)
+	}
+
+	cursor := t.p.storeCursor(t.topic)
+
+	var lastErr error
+	for _, pid := range peers {
+		msgs, err := t.p.requestCatchUp(ctx, pid, t.topic, cursor)
+		if err == nil {
+			t.p.injectCatchUp(pid, msgs)
+			return len(msgs), nil
+		}
+		logger.Debugf("从 %s 请求主题 %s 的追赶补发失败: %s", pid, t.topic, err)
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+	}
+
+	return 0, lastErr
+}
+
+// catchUpOnJoin 等待主题出现成员后请求追赶补发
+func (t *Topic) catchUpOnJoin() {
+	ctx, cancel := context.WithTimeout(t.p.ctx, CatchUpTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	for len(t.p.ListPeers(t.topic)) == 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logger.Debugf("主题 %s 没有成员，放弃追赶补发", t.topic)
+			return
+		}
+	}
+
+	n, err := t.CatchUp(ctx)
+	if err != nil {
+		logger.Debugf("主题 %s 的追赶补发失败: %s", t.topic, err)
+		return
+	}
+	logger.Debugf("主题 %s 追赶补发收到 %d 条消息", t.topic, n)
+}
+
+// storeMessage 将经过验证的消息交给 storeLoop 保存到消息存储，只保存已加入主题的消息。
+// 队列已满时丢弃该消息，不阻塞事件循环。只从 processLoop 调用。
+// 参数:
+//   - msg: 经过验证的消息
+func (p *PubSub) storeMessage(msg *Message) {
+	if p.store == nil {
+		return
+	}
+	if _, ok := p.myTopics[msg.GetTopic()]; !ok {
+		return
+	}
+
+	select {
+	case p.storeQueue <- &StoredMessage{ID: msg.ID, Message: msg.Message, Received: time.Now()}:
+	default:
+		logger.Warnf("消息存储队列已满; 丢弃主题 %s 的消息 %s", msg.GetTopic(), msg.ID)
+	}
+}
+
+// storeLoop 依次将队列中的消息写入消息存储，PubSub 停止时写完已入队的消息后退出
+func (p *PubSub) storeLoop() {
+	put := func(sm *StoredMessage) {
+		if err := p.store.Put(sm); err != nil {
+			logger.Warnf("保存主题 %s 的消息 %s 失败: %s", sm.Message.GetTopic(), sm.ID, err)
+		}
+	}
+
+	for {
+		select {
+		case sm := <-p.storeQueue:
+			put(sm)
+		case <-p.ctx.Done():
+			for {
+				select {
+				case sm := <-p.storeQueue:
+					put(sm)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// storeCursor 返回本地存储中主题最后一条消息的 ID，没有存储或消息时返回空字符串
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - string: 游标
+func (p *PubSub) storeCursor(topic string) string {
+	if p.store == nil {
+		return ""
+	}
+
+	last, err := p.store.Since(topic, "", 1)
+	if err != nil || len(last) == 0 {
+		return ""
+	}
+	return last[0].ID
+}
+
+// injectCatchUp 将补发的消息推送到验证管道，消息按来自 pid 处理但不会再次转发
+// 参数:
+//   - pid: 补发消息的对等节点
+//   - msgs: 补发的消息
+func (p *PubSub) injectCatchUp(pid peer.ID, msgs []*pb.Message) {
+	if len(msgs) == 0 {
+		return
+	}
+
+	select {
+	case p.eval <- func() {
+		for _, pmsg := range msgs {
+			if !p.subscribedToMsg(pmsg) {
+				continue
+			}
+			p.pushMsg(&Message{Message: pmsg, ReceivedFrom: pid, catchUp: true, annotations: new(messageAnnotations)})
+		}
+	}:
+	case <-p.ctx.Done():
+	}
+}
+
+// requestCatchUp 通过追赶协议向对等节点请求主题中游标之后的消息
+// 参数:
+//   - ctx: 上下文
+//   - pid: 对等节点 ID
+//   - topic: 主题
+//   - cursor: 最后看到的消息 ID
+//
+// 返回值:
+//   - []*pb.Message: 补发的消息
+//   - error: 错误信息
+func (p *PubSub) requestCatchUp(ctx context.Context, pid peer.ID, topic, cursor string) ([]*pb.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, CatchUpTimeout)
+	defer cancel()
+
+	s, err := p.host.NewStream(ctx, pid, p.prefixed(CatchUpID))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	w := msgio.NewVarintWriter(s)
+	if err := w.WriteMsg([]byte(topic)); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	if err := w.WriteMsg([]byte(cursor)); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite() // 请求已发送完毕
+
+	var msgs []*pb.Message
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	for len(msgs) < DefaultCatchUpLimit {
+		buf, err := r.ReadMsg()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			s.Reset()
+			return nil, err
+		}
+
+		pmsg := new(pb.Message)
+		err = pmsg.Unmarshal(buf)
+		r.ReleaseMsg(buf)
+		if err != nil {
+			s.Reset()
+			return nil, fmt.Errorf("无效的追赶补发消息: %w", err)
+		}
+		if pmsg.GetTopic() != topic {
+			s.Reset()
+			return nil, fmt.Errorf("对等节点 %s 补发了其他主题 %s 的消息", pid, pmsg.GetTopic())
+		}
+		msgs = append(msgs, pmsg)
+	}
+
+	return msgs, nil
+}
+
+// handleCatchUpStream 处理追赶补发请求流
+// 参数:
+//   - s: 网络流
+func (p *PubSub) handleCatchUpStream(s network.Stream) {
+	defer s.Close()
+
+	s.SetDeadline(time.Now().Add(CatchUpTimeout))
+
+	from := s.Conn().RemotePeer()
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	var req [2]string // 主题和游标
+	for i := range req {
+		buf, err := r.ReadMsg()
+		if err != nil {
+			logger.Debugf("从 %s 读取追赶补发请求失败: %s", from, err)
+			s.Reset()
+			return
+		}
+		req[i] = string(buf)
+		r.ReleaseMsg(buf)
+	}
+	topic, cursor := req[0], req[1]
+
+	if p.store == nil || !p.servesTopic(from, topic) {
+		s.Reset()
+		return
+	}
+	stored, err := p.store.Since(topic, cursor, DefaultCatchUpLimit)
+	if err != nil {
+		logger.Debugf("读取主题 %s 的存储消息失败: %s", topic, err)
+		s.Reset()
+		return
+	}
+
+	w := msgio.NewVarintWriter(s)
+	for _, sm := range stored {
+		if !visibleTo(sm.Message, from) {
+			continue
+		}
+		buf, err := sm.Message.Marshal()
+		if err != nil || len(buf) > p.maxMessageSize {
+			continue
+		}
+		if err := w.WriteMsg(buf); err != nil {
+			logger.Debugf("向 %s 写入追赶补发消息失败: %s", from, err)
+			s.Reset()
+			return
+		}
+	}
+}
+
+// servesTopic 检查是否可以向对等节点提供主题的存储消息：对等节点必须在允许名单中、未被列入黑名单、
+// 通过主题授权并且已经订阅了该主题
+// 参数:
+//   - pid: 对等节点 ID
+//   - topic: 主题
+//
+// 返回值:
+//   - bool: 是否可以提供
+func (p *PubSub) servesTopic(pid peer.ID, topic string) bool {
+	res := make(chan bool, 1)
+	select {
+	case p.eval <- func() {
+		_, subscribed := p.topics[topic][pid]
+		res <- subscribed && p.peerAllowed(pid) && !p.blacklist.Contains(pid) && p.authorized(topic, pid)
+	}:
+	case <-p.ctx.Done():
+		return false
+	}
+
+	select {
+	case ok := <-res:
+		return ok
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// visibleTo 检查消息是否可以发给对等节点，指定了目标节点的消息只发给其目标节点
+// 参数:
+//   - msg: 消息
+//   - pid: 对等节点 ID
+//
+// 返回值:
+//   - bool: 是否可以发送
+func visibleTo(msg *pb.Message, pid peer.ID) bool {
+	targets := msg.GetTargets()
+	if len(targets) == 0 {
+		return true
+	}
+	for _, target := range targets {
+		if peer.ID(target.GetPeerId()) == pid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,406 @@
+// 作用：基于文件的消息存储。
+// 功能：每个主题一个追加写入的日志文件，内存中保留最近消息的索引；打开时从日志恢复，
+// 超出容量或过期的消息从索引中淘汰，日志中的淘汰记录超过容量后重写日志。
+// 日志文件以十六进制编码的主题命名，编码后超出文件名长度限制的主题改用主题的 SHA-256 摘要命名，
+// 打开时从日志记录中的消息恢复主题。
+
+package pubsub
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+const (
+	// fileStoreExt 是主题日志文件的扩展名
+	fileStoreExt = ".log"
+
+	// fileStoreMaxRecord 是单条日志记录的最大字节数，用于识别损坏的记录
+	fileStoreMaxRecord = 64 << 20
+
+	// fileStoreHashPrefix 是以主题摘要命名的日志文件的前缀
+	fileStoreHashPrefix = "sha256-"
+
+	// fileStoreMaxName 是大多数文件系统允许的最大文件名字节数
+	fileStoreMaxName = 255
+)
+
+// FileMessageStore 是基于文件的消息存储，每个主题保留最近 capacity 条消息
+type FileMessageStore struct {
+	dir      string        // 存储目录
+	capacity int           // 每个主题保留的最大消息数
+	ttl      time.Duration // 消息的保留时间，为 0 时不过期
+
+	mx     sync.Mutex
+	topics map[string]*fileTopicLog // 主题到日志的映射
+	closed bool
+}
+
+// fileTopicLog 是一个主题的日志文件和内存索引
+type fileTopicLog struct {
+	path    string           // 日志文件路径
+	f       *os.File         // 日志文件
+	entries []*StoredMessage // 保留的消息，按保存顺序排列
+	records int              // 日志文件中的记录数，包括已淘汰的记录
+}
+
+// 确保 FileMessageStore 实现了 MessageStore 接口
+var _ MessageStore = (*FileMessageStore)(nil)
+
+// NewFileMessageStore 创建基于文件的消息存储，并从目录中已有的日志恢复消息
+// 参数:
+//   - dir: 存储目录，不存在时创建
+//   - capacity: 每个主题保留的最大消息数
+//   - ttl: 消息的保留时间，为 0 时不过期
+//
+// 返回值:
+//   - *FileMessageStore: 消息存储
+//   - error: 错误信息
+func NewFileMessageStore(dir string, capacity int, ttl time.Duration) (*FileMessageStore, error) {
+	if capacity <= 0 {
+		logger.Warnf("消息存储的容量必须为正数")
+		return nil, fmt.Errorf("消息存储的容量必须为正数")
+	}
+	if ttl < 0 {
+		logger.Warnf("消息存储的保留时间不能为负数")
+		return nil, fmt.Errorf("消息存储的保留时间不能为负数")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileMessageStore{
+		dir:      dir,
+		capacity: capacity,
+		ttl:      ttl,
+		topics:   make(map[string]*fileTopicLog),
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileStoreExt))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		base := strings.TrimSuffix(filepath.Base(path), fileStoreExt)
+		hashed := strings.HasPrefix(base, fileStoreHashPrefix)
+		name, err := hex.DecodeString(strings.TrimPrefix(base, fileStoreHashPrefix))
+		if err != nil {
+			continue // 不是主题日志
+		}
+		tl, err := s.openLog(path)
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		if hashed {
+			// 摘要无法还原主题，从保留的消息中恢复；没有保留的消息时等到下次写入再打开
+			if len(tl.entries) == 0 {
+				tl.f.Close()
+				continue
+			}
+			name = []byte(tl.entries[0].Message.GetTopic())
+		}
+		s.topics[string(name)] = tl
+	}
+
+	return s, nil
+}
+
+// Put 将消息追加到主题日志
+// 参数:
+//   - msg: 要保存的消息
+//
+// 返回值:
+//   - error: 错误信息
+func (s *FileMessageStore) Put(msg *StoredMessage) error {
+	buf, err := msg.Message.Marshal()
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return fmt.Errorf("消息存储已关闭")
+	}
+
+	topic := msg.Message.GetTopic()
+	tl, ok := s.topics[topic]
+	if !ok {
+		tl, err = s.openLog(filepath.Join(s.dir, topicLogName(topic)))
+		if err != nil {
+			return err
+		}
+		s.topics[topic] = tl
+	}
+
+	if _, err := tl.f.Write(encodeStoredMessage(msg.ID, msg.Received, buf)); err != nil {
+		return err
+	}
+	tl.records++
+	tl.entries = append(tl.entries, msg)
+	s.prune(tl, time.Now())
+
+	// 淘汰的记录超过容量时重写日志
+	if tl.records-len(tl.entries) > s.capacity {
+		return s.compact(tl)
+	}
+	return nil
+}
+
+// Since 按保存顺序返回主题中游标之后保存的至多 limit 条消息
+// 参数:
+//   - topic: 主题
+//   - cursor: 最后看到的消息 ID
+//   - limit: 最大消息数，小于等于 0 表示不限制
+//
+// 返回值:
+//   - []*StoredMessage: 消息列表
+//   - error: 错误信息
+func (s *FileMessageStore) Since(topic, cursor string, limit int) ([]*StoredMessage, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	tl, ok := s.topics[topic]
+	if !ok {
+		return nil, nil
+	}
+	s.prune(tl, time.Now())
+
+	start := -1
+	if cursor != "" {
+		for i := len(tl.entries) - 1; i >= 0; i-- {
+			if tl.entries[i].ID == cursor {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	entries := tl.entries
+	switch {
+	case start >= 0:
+		entries = entries[start:]
+		if limit > 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+	case limit > 0 && len(entries) > limit:
+		entries = entries[len(entries)-limit:]
+	}
+
+	return append([]*StoredMessage(nil), entries...), nil
+}
+
+// Close 关闭所有主题日志
+// 返回值:
+//   - error: 错误信息
+func (s *FileMessageStore) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+
+	var err error
+	for _, tl := range s.topics {
+		if cerr := tl.f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// openLog 打开主题日志并恢复其中的消息，末尾不完整的记录会被截断
+// 参数:
+//   - path: 日志文件路径
+//
+// 返回值:
+//   - *fileTopicLog: 主题日志
+//   - error: 错误信息
+func (s *FileMessageStore) openLog(path string) (*fileTopicLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	tl := &fileTopicLog{path: path, f: f}
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		sm, n, err := readStoredMessage(r)
+		if err != nil {
+			if err != io.EOF {
+				logger.Warnf("截断日志 %s 中不完整的记录: %s", path, err)
+			}
+			break
+		}
+		offset += int64(n)
+		tl.records++
+		tl.entries = append(tl.entries, sm)
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	s.prune(tl, time.Now())
+	return tl, nil
+}
+
+// topicLogName 返回主题日志的文件名。十六进制编码的主题连同重写日志时的临时文件后缀超出文件名长度限制时，
+// 使用主题的 SHA-256 摘要命名
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - string: 文件名
+func topicLogName(topic string) string {
+	name := hex.EncodeToString([]byte(topic)) + fileStoreExt
+	if len(name)+len(".tmp") <= fileStoreMaxName {
+		return name
+	}
+	sum := sha256.Sum256([]byte(topic))
+	return fileStoreHashPrefix + hex.EncodeToString(sum[:]) + fileStoreExt
+}
+
+// prune 从索引中淘汰过期和超出容量的消息
+// 参数:
+//   - tl: 主题日志
+//   - now: 当前时间
+func (s *FileMessageStore) prune(tl *fileTopicLog, now time.Time) {
+	drop := 0
+	if len(tl.entries) > s.capacity {
+		drop = len(tl.entries) - s.capacity
+	}
+	if s.ttl > 0 {
+		for drop < len(tl.entries) && now.Sub(tl.entries[drop].Received) > s.ttl {
+			drop++
+		}
+	}
+	if drop > 0 {
+		tl.entries = append([]*StoredMessage(nil), tl.entries[drop:]...)
+	}
+}
+
+// compact 只用保留的消息重写主题日志
+// 参数:
+//   - tl: 主题日志
+//
+// 返回值:
+//   - error: 错误信息
+func (s *FileMessageStore) compact(tl *fileTopicLog) error {
+	tmp := tl.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, sm := range tl.entries {
+		buf, err := sm.Message.Marshal()
+		if err == nil {
+			_, err = w.Write(encodeStoredMessage(sm.ID, sm.Received, buf))
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, tl.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	tl.f.Close()
+	tl.f, err = os.OpenFile(tl.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	tl.records = len(tl.entries)
+	return nil
+}
+
+// encodeStoredMessage 编码日志记录。
+// 记录格式：记录长度（uvarint）| 收到时间（8 字节纳秒）| 消息 ID 长度（uvarint）| 消息 ID | 消息。
+// 参数:
+//   - id: 消息 ID
+//   - received: 收到时间
+//   - msg: 编码后的消息
+//
+// 返回值:
+//   - []byte: 日志记录
+func encodeStoredMessage(id string, received time.Time, msg []byte) []byte {
+	body := make([]byte, 8, 8+binary.MaxVarintLen64+len(id)+len(msg))
+	binary.BigEndian.PutUint64(body, uint64(received.UnixNano()))
+	body = binary.AppendUvarint(body, uint64(len(id)))
+	body = append(body, id...)
+	body = append(body, msg...)
+
+	out := binary.AppendUvarint(nil, uint64(len(body)))
+	return append(out, body...)
+}
+
+// readStoredMessage 读取一条日志记录
+// 参数:
+//   - r: 日志读取器
+//
+// 返回值:
+//   - *StoredMessage: 消息
+//   - int: 记录占用的字节数
+//   - error: 没有更多记录时返回 io.EOF
+func readStoredMessage(r *bufio.Reader) (*StoredMessage, int, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if size > fileStoreMaxRecord {
+		return nil, 0, fmt.Errorf("记录过大")
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	if len(body) < 8 {
+		return nil, 0, fmt.Errorf("记录过短")
+	}
+	received := time.Unix(0, int64(binary.BigEndian.Uint64(body)))
+	idLen, n := binary.Uvarint(body[8:])
+	if n <= 0 || uint64(len(body)-8-n) < idLen {
+		return nil, 0, fmt.Errorf("无效的消息 ID 长度")
+	}
+	id := string(body[8+n : 8+n+int(idLen)])
+
+	pmsg := new(pb.Message)
+	if err := pmsg.Unmarshal(body[8+n+int(idLen):]); err != nil {
+		return nil, 0, err
+	}
+
+	return &StoredMessage{ID: id, Message: pmsg, Received: received}, len(binary.AppendUvarint(nil, size)) + len(body), nil
+}
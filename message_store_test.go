@@ -0,0 +1,238 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestFileMessageStore 测试文件消息存储的游标查询、容量淘汰和重新打开后的恢复
+func TestFileMessageStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileMessageStore(dir, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		err := store.Put(&StoredMessage{
+			ID:       fmt.Sprintf("m%d", i),
+			Message:  &pb.Message{Topic: "foobar", Data: []byte(fmt.Sprintf("data%d", i))},
+			Received: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(s *FileMessageStore, cursor string, limit int, want ...string) {
+		t.Helper()
+		got, err := s.Since("foobar", cursor, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d messages after %q, got %d", len(want), cursor, len(got))
+		}
+		for i, sm := range got {
+			if sm.ID != want[i] || string(sm.Message.Data) != "data"+want[i][1:] {
+				t.Fatalf("expected message %s at %d, got %s", want[i], i, sm.ID)
+			}
+		}
+	}
+
+	check(store, "", 0, "m7", "m8", "m9")
+	check(store, "", 1, "m9")
+	check(store, "m7", 0, "m8", "m9")
+	check(store, "m8", 1, "m9")
+	check(store, "m9", 0)
+	check(store, "m0", 2, "m8", "m9") // 已淘汰的游标返回最新的消息
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = NewFileMessageStore(dir, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	check(store, "", 0, "m7", "m8", "m9")
+
+	if got, _ := store.Since("other", "", 0); len(got) != 0 {
+		t.Fatalf("expected no messages for an unknown topic, got %d", len(got))
+	}
+}
+
+// TestFileMessageStoreLongTopic 测试编码后超出文件名长度限制的主题使用摘要命名，并在重新打开后恢复
+func TestFileMessageStoreLongTopic(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileMessageStore(dir, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic := strings.Repeat("t", 200)
+	err = store.Put(&StoredMessage{ID: "m0", Message: &pb.Message{Topic: topic, Data: []byte("data")}, Received: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if len(f.Name()) > fileStoreMaxName {
+			t.Fatalf("file name too long: %d bytes", len(f.Name()))
+		}
+	}
+
+	store, err = NewFileMessageStore(dir, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	got, err := store.Since(topic, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].ID != "m0" {
+		t.Fatalf("expected the stored message to be recovered, got %d messages", len(got))
+	}
+}
+
+// TestTopicCatchUp 测试重新加入的节点从主题成员的消息存储追赶补发消息，且重复补发的消息不再投递
+func TestTopicCatchUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	var psubs []*PubSub
+	for _, h := range hosts[:2] {
+		store, err := NewFileMessageStore(t.TempDir(), 16, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer store.Close()
+		psubs = append(psubs, getPubsub(ctx, h, WithMessageStore(store)))
+	}
+	psubs = append(psubs, getPubsub(ctx, hosts[2]))
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	for _, topic := range topics[:2] {
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	for i := 0; i < 3; i++ {
+		if err := topics[1].Publish(ctx, []byte(fmt.Sprintf("msg%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		assertReceive(t, subs[0], []byte(fmt.Sprintf("msg%d", i)))
+	}
+
+	// 节点 2 错过了消息，之后只连接节点 0
+	sub, err := topics[2].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[2], hosts[0])
+	time.Sleep(time.Second)
+
+	n, err := topics[2].CatchUp(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 caught up messages, got %d", n)
+	}
+	for i := 0; i < 3; i++ {
+		assertReceive(t, sub, []byte(fmt.Sprintf("msg%d", i)))
+	}
+
+	// 重复补发的消息在去重时丢弃
+	if _, err := topics[2].CatchUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	nctx, ncancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer ncancel()
+	if msg, err := sub.Next(nctx); err == nil {
+		t.Fatalf("unexpected redelivery of %q", msg.Data)
+	}
+}
+
+// TestCatchUpRequiresSubscription 测试只向订阅了主题的对等节点提供追赶补发
+func TestCatchUpRequiresSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	store, err := NewFileMessageStore(t.TempDir(), 16, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithMessageStore(store)),
+		getPubsub(ctx, hosts[1]),
+	}
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := topic.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if err := topic.Publish(ctx, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("secret"))
+
+	// 未订阅主题的节点得不到存储的消息
+	msgs, _ := psubs[1].requestCatchUp(ctx, hosts[0].ID(), "foobar", "")
+	if len(msgs) != 0 {
+		t.Fatalf("unsubscribed peer caught up %d messages", len(msgs))
+	}
+
+	if _, err := psubs[1].Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	msgs, err = psubs[1].requestCatchUp(ctx, hosts[0].ID(), "foobar", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 caught up message, got %d", len(msgs))
+	}
+}
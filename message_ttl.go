@@ -0,0 +1,89 @@
+// 作用：消息的跳数和存活时间限制。
+// 功能：发布者在消息中写入最大跳数和过期时间，二者随消息签名；接收节点逐跳递增携带跳数限制的消息的跳数。
+// 转发时超过跳数限制或已过期的消息不再传播，但仍投递给本地订阅者，避免陈旧或传播过远的消息在有损网络中继续扩散。
+
+package pubsub
+
+import (
+	"fmt"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// messageTTL 是本节点的消息跳数和存活时间限制
+type messageTTL struct {
+	maxHops int           // 最大跳数，为 0 时不限制
+	maxAge  time.Duration // 本节点发布的消息的存活时间，为 0 时不过期
+}
+
+// WithMessageTTL 是一个选项，限制消息传播的跳数和存活时间。
+// 本节点发布的消息携带这两个限制，所有节点在转发时执行消息携带的限制；
+// 此外本节点不转发跳数达到 maxHops 的任何消息。
+// 跳数不参与签名，逐跳变化，因此自定义的消息 ID 函数不应依赖完整的消息编码。
+// 不认识跳数字段的旧版本节点会将其计入签名验证：maxHops 大于 0 时本节点会递增所有转发消息的跳数，
+// 只应在所有节点都支持跳数时设置；未设置时只递增发布者写入了跳数限制的消息的跳数。
+// 参数:
+//   - maxHops: 最大跳数，为 0 时不限制
+//   - maxAge: 消息的存活时间，为 0 时不过期
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMessageTTL(maxHops int, maxAge time.Duration) Option {
+	return func(ps *PubSub) error {
+		if maxHops < 0 || maxAge < 0 {
+			logger.Warnf("最大跳数和存活时间不能为负数")
+			return fmt.Errorf("最大跳数和存活时间不能为负数")
+		}
+		if maxHops == 0 && maxAge == 0 {
+			logger.Warnf("至少需要设置最大跳数或存活时间")
+			return fmt.Errorf("至少需要设置最大跳数或存活时间")
+		}
+		ps.ttl = messageTTL{maxHops: maxHops, maxAge: maxAge}
+		return nil
+	}
+}
+
+// stampTTL 在本地发布的消息中写入跳数和存活时间限制，必须在签名之前调用
+// 参数:
+//   - m: 消息
+//   - now: 当前时间
+func (p *PubSub) stampTTL(m *pb.Message, now time.Time) {
+	if p.ttl.maxHops > 0 {
+		m.HopLimit = uint32(p.ttl.maxHops)
+	}
+	if p.ttl.maxAge > 0 {
+		m.Expires = now.Add(p.ttl.maxAge).UnixNano()
+	}
+}
+
+// countHop 记录消息经过了一跳。只有消息携带跳数限制或本节点配置了最大跳数时才递增跳数，
+// 其他消息的跳数保持为 0，使其编码与旧版本节点验证的签名内容一致
+// 参数:
+//   - m: 消息
+func (p *PubSub) countHop(m *pb.Message) {
+	if m.GetHopLimit() > 0 || p.ttl.maxHops > 0 {
+		m.Hops++
+	}
+}
+
+// forwardable 返回消息是否仍在跳数和存活时间限制之内，可以继续转发
+// 参数:
+//   - m: 消息
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否可以转发
+func (p *PubSub) forwardable(m *pb.Message, now time.Time) bool {
+	hops := m.GetHops()
+	if limit := m.GetHopLimit(); limit > 0 && hops >= limit {
+		return false
+	}
+	if p.ttl.maxHops > 0 && hops >= uint32(p.ttl.maxHops) {
+		return false
+	}
+	if expires := m.GetExpires(); expires > 0 && now.UnixNano() > expires {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestMessageTTLHops 测试超过跳数限制的消息不再被转发，但仍投递给本地订阅者
+func TestMessageTTLHops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithMessageTTL(1, 0)),
+		getPubsub(ctx, hosts[1]),
+		getPubsub(ctx, hosts[2]),
+	}
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	sub1, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub2, err := psubs[2].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	topics := getTopics(psubs[:1], "foo")
+	msg := []byte("hello")
+	if err := topics[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub1, msg)
+
+	rctx, rcancel := context.WithTimeout(ctx, time.Second)
+	defer rcancel()
+	if m, err := sub2.Next(rctx); err == nil {
+		t.Fatalf("expected the message to stop after one hop, got %s", m.Data)
+	}
+}
+
+// TestMessageTTLForwardable 测试跳数、本地跳数限制和过期时间的检查
+func TestMessageTTLForwardable(t *testing.T) {
+	now := time.Now()
+	p := &PubSub{}
+
+	if !p.forwardable(&pb.Message{Hops: 5}, now) {
+		t.Fatal("expected a message without limits to be forwardable")
+	}
+	if p.forwardable(&pb.Message{Hops: 2, HopLimit: 2}, now) {
+		t.Fatal("expected a message at its hop limit not to be forwardable")
+	}
+	if p.forwardable(&pb.Message{Expires: now.Add(-time.Second).UnixNano()}, now) {
+		t.Fatal("expected an expired message not to be forwardable")
+	}
+	if !p.forwardable(&pb.Message{Expires: now.Add(time.Second).UnixNano()}, now) {
+		t.Fatal("expected an unexpired message to be forwardable")
+	}
+
+	p.ttl = messageTTL{maxHops: 3}
+	if p.forwardable(&pb.Message{Hops: 3, HopLimit: 10}, now) {
+		t.Fatal("expected the local hop limit to apply")
+	}
+
+	p.ttl = messageTTL{maxHops: 4, maxAge: time.Minute}
+	m := &pb.Message{}
+	p.stampTTL(m, now)
+	if m.HopLimit != 4 || m.Expires != now.Add(time.Minute).UnixNano() {
+		t.Fatalf("unexpected limits: %d %d", m.HopLimit, m.Expires)
+	}
+}
+
+// verifyBaselineSignature 按不认识跳数和注解字段的旧版本节点的方式验证签名：
+// 未知字段原样保留在编码中，只清除签名和公钥
+func verifyBaselineSignature(m *pb.Message) error {
+	pubk, err := messagePubKey(m)
+	if err != nil {
+		return err
+	}
+	xm := *m
+	xm.Signature = nil
+	xm.Key = nil
+	bytes, err := xm.Marshal()
+	if err != nil {
+		return err
+	}
+	valid, err := pubk.Verify(withSignPrefix(bytes), m.Signature)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// TestMessageHopsBaselineSignature 测试没有跳数限制的转发消息仍能通过旧版本节点的签名验证
+func TestMessageHopsBaselineSignature(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	errs := make(chan error, 1)
+	err := psubs[2].RegisterTopicValidator("foo", func(ctx context.Context, from peer.ID, msg *Message) bool {
+		err := verifyBaselineSignature(msg.Message)
+		select {
+		case errs <- err:
+		default:
+		}
+		return err == nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := psubs[1].Subscribe("foo"); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[2].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	msg := []byte("hello")
+	if err := getTopics(psubs[:1], "foo")[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+	if err := <-errs; err != nil {
+		t.Fatalf("expected forwarded message to verify on a baseline peer: %s", err)
+	}
+}
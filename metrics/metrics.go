@@ -0,0 +1,244 @@
+// 作用：Prometheus 指标导出。
+// 功能：通过 RawTracer 统计消息投递、拒绝、重复、验证延迟和 RPC 流量，并在抓取时采集网格大小和对等节点分数分布。
+
+package metrics
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+	logging "github.com/dep2p/log"
+	"github.com/dep2p/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var logger = logging.Logger("pubsub-metrics")
+
+// namespace 是所有指标名称的前缀
+const namespace = "pubsub"
+
+// maxPending 是等待验证结果的消息数上限，超过后丢弃最早的记录以限制内存
+const maxPending = 16384
+
+// scoreBuckets 是对等节点分数分布的桶边界
+var scoreBuckets = []float64{-1000, -100, -10, -1, 0, 1, 10, 100, 1000}
+
+// WithPrometheusMetrics 是一个选项，用于将发布订阅指标注册到给定的 Prometheus 注册器。
+// 参数:
+//   - reg: Prometheus 注册器
+//
+// 返回值:
+//   - pubsub.Option: 配置选项
+func WithPrometheusMetrics(reg prometheus.Registerer) pubsub.Option {
+	return func(ps *pubsub.PubSub) error {
+		if reg == nil {
+			logger.Warnf("Prometheus 注册器不能为空")
+			return fmt.Errorf("Prometheus 注册器不能为空")
+		}
+		t := NewTracer(ps)
+		for _, c := range t.collectors() {
+			if err := reg.Register(c); err != nil {
+				logger.Warnf("注册 Prometheus 指标失败: %s", err)
+				return fmt.Errorf("注册 Prometheus 指标失败: %w", err)
+			}
+		}
+		return pubsub.WithRawTracer(t)(ps)
+	}
+}
+
+// Tracer 是导出 Prometheus 指标的低级追踪器
+type Tracer struct {
+	ps *pubsub.PubSub
+
+	delivered  *prometheus.CounterVec
+	rejected   *prometheus.CounterVec
+	duplicated *prometheus.CounterVec
+	validation *prometheus.HistogramVec
+	rpcBytes   *prometheus.CounterVec
+
+	meshPeers  *prometheus.Desc
+	peerScores *prometheus.Desc
+
+	mx      sync.Mutex
+	pending map[string]time.Time // 进入验证管道的消息 ID 及其时间
+}
+
+var _ pubsub.RawTracer = (*Tracer)(nil)
+
+// NewTracer 创建指标追踪器，应用程序可通过 pubsub.WithRawTracer 安装并自行注册 Collectors 返回的收集器
+// 参数:
+//   - ps: 用于采集网格大小和对等节点分数的 PubSub 实例
+//
+// 返回值:
+//   - *Tracer: 指标追踪器
+func NewTracer(ps *pubsub.PubSub) *Tracer {
+	return &Tracer{
+		ps: ps,
+		delivered: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_delivered_total",
+			Help:      "投递给订阅者的消息数",
+		}, []string{"topic"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_rejected_total",
+			Help:      "被拒绝或忽略的消息数",
+		}, []string{"topic", "reason"}),
+		duplicated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_duplicated_total",
+			Help:      "被丢弃的重复消息数",
+		}, []string{"topic"}),
+		validation: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "validation_duration_seconds",
+			Help:      "消息从进入验证管道到投递或拒绝的耗时",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+		}, []string{"topic"}),
+		rpcBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rpc_bytes_total",
+			Help:      "收发的 RPC 字节数",
+		}, []string{"direction"}),
+		meshPeers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "mesh_peers"),
+			"每个主题网格中的对等节点数",
+			[]string{"topic"}, nil),
+		peerScores: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "peer_scores"),
+			"对等节点分数分布",
+			nil, nil),
+		pending: make(map[string]time.Time),
+	}
+}
+
+// Collectors 返回追踪器的 Prometheus 收集器
+// 返回值:
+//   - []prometheus.Collector: 收集器
+func (t *Tracer) Collectors() []prometheus.Collector {
+	return t.collectors()
+}
+
+// collectors 返回追踪器的 Prometheus 收集器
+func (t *Tracer) collectors() []prometheus.Collector {
+	return []prometheus.Collector{t.delivered, t.rejected, t.duplicated, t.validation, t.rpcBytes, (*stateCollector)(t)}
+}
+
+// observe 记录消息的验证耗时
+// 参数:
+//   - msg: 离开验证管道的消息
+func (t *Tracer) observe(msg *pubsub.Message) {
+	t.mx.Lock()
+	start, ok := t.pending[msg.ID]
+	delete(t.pending, msg.ID)
+	t.mx.Unlock()
+
+	if ok {
+		t.validation.WithLabelValues(msg.GetTopic()).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ValidateMessage 实现 RawTracer 接口
+func (t *Tracer) ValidateMessage(msg *pubsub.Message) {
+	now := time.Now()
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if len(t.pending) >= maxPending {
+		var oldest string
+		var oldestAt time.Time
+		for id, at := range t.pending {
+			if oldest == "" || at.Before(oldestAt) {
+				oldest, oldestAt = id, at
+			}
+		}
+		delete(t.pending, oldest)
+	}
+	t.pending[msg.ID] = now
+}
+
+// DeliverMessage 实现 RawTracer 接口
+func (t *Tracer) DeliverMessage(msg *pubsub.Message) {
+	t.observe(msg)
+	t.delivered.WithLabelValues(msg.GetTopic()).Inc()
+}
+
+// RejectMessage 实现 RawTracer 接口
+func (t *Tracer) RejectMessage(msg *pubsub.Message, reason string) {
+	t.observe(msg)
+	t.rejected.WithLabelValues(msg.GetTopic(), reason).Inc()
+}
+
+// DuplicateMessage 实现 RawTracer 接口
+func (t *Tracer) DuplicateMessage(msg *pubsub.Message) {
+	t.duplicated.WithLabelValues(msg.GetTopic()).Inc()
+}
+
+// RecvRPC 实现 RawTracer 接口
+func (t *Tracer) RecvRPC(rpc *pubsub.RPC) {
+	t.rpcBytes.WithLabelValues("in").Add(float64(rpc.Size()))
+}
+
+// SendRPC 实现 RawTracer 接口
+func (t *Tracer) SendRPC(rpc *pubsub.RPC, p peer.ID) {
+	t.rpcBytes.WithLabelValues("out").Add(float64(rpc.Size()))
+}
+
+// AddPeer 实现 RawTracer 接口
+func (t *Tracer) AddPeer(p peer.ID, proto protocol.ID) {}
+
+// RemovePeer 实现 RawTracer 接口
+func (t *Tracer) RemovePeer(p peer.ID) {}
+
+// Join 实现 RawTracer 接口
+func (t *Tracer) Join(topic string) {}
+
+// Leave 实现 RawTracer 接口
+func (t *Tracer) Leave(topic string) {}
+
+// Graft 实现 RawTracer 接口
+func (t *Tracer) Graft(p peer.ID, topic string) {}
+
+// Prune 实现 RawTracer 接口
+func (t *Tracer) Prune(p peer.ID, topic string) {}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (t *Tracer) ThrottlePeer(p peer.ID) {}
+
+// DropRPC 实现 RawTracer 接口
+func (t *Tracer) DropRPC(rpc *pubsub.RPC, p peer.ID) {}
+
+// UndeliverableMessage 实现 RawTracer 接口
+func (t *Tracer) UndeliverableMessage(msg *pubsub.Message) {}
+
+// stateCollector 在抓取时从 PubSub 读取网格大小和对等节点分数
+type stateCollector Tracer
+
+// Describe 实现 prometheus.Collector 接口
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.meshPeers
+	ch <- c.peerScores
+}
+
+// Collect 实现 prometheus.Collector 接口
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, topic := range c.ps.GetTopics() {
+		ch <- prometheus.MustNewConstMetric(c.meshPeers, prometheus.GaugeValue, float64(len(c.ps.MeshPeers(topic))), topic)
+	}
+
+	scores := c.ps.PeerScores()
+	buckets := make(map[float64]uint64, len(scoreBuckets))
+	var sum float64
+	for _, score := range scores {
+		sum += score
+		for _, b := range scoreBuckets {
+			if score <= b {
+				buckets[b]++
+			}
+		}
+	}
+	ch <- prometheus.MustNewConstHistogram(c.peerScores, uint64(len(scores)), sum, buckets)
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p"
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/pubsub"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gather 按名称返回注册器中的指标族
+func gather(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]*dto.MetricFamily)
+	for _, mf := range mfs {
+		out[mf.GetName()] = mf
+	}
+	return out
+}
+
+// TestPrometheusMetrics 测试投递、重复、网格和 RPC 指标的导出
+func TestPrometheusMetrics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reg := prometheus.NewRegistry()
+	var hosts []host.Host
+	var psubs []*pubsub.PubSub
+	for i := 0; i < 2; i++ {
+		h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.Close() })
+		var opts []pubsub.Option
+		if i == 0 {
+			opts = append(opts, WithPrometheusMetrics(reg))
+		}
+		ps, err := pubsub.NewGossipSub(ctx, h, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+		psubs = append(psubs, ps)
+	}
+	if err := hosts[1].Connect(ctx, hosts[0].Peerstore().PeerInfo(hosts[0].ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	var subs []*pubsub.Subscription
+	var topics []*pubsub.Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+	time.Sleep(2 * time.Second)
+
+	for i := 0; i < 3; i++ {
+		if err := topics[1].Publish(ctx, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+		_, err := subs[0].Next(tctx)
+		tcancel()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mfs := gather(t, reg)
+	delivered := mfs["pubsub_messages_delivered_total"]
+	if delivered == nil || delivered.Metric[0].GetCounter().GetValue() != 3 {
+		t.Fatalf("expected 3 delivered messages, got %v", delivered)
+	}
+	validation := mfs["pubsub_validation_duration_seconds"]
+	if validation == nil || validation.Metric[0].GetHistogram().GetSampleCount() != 3 {
+		t.Fatalf("expected 3 validation samples, got %v", validation)
+	}
+	mesh := mfs["pubsub_mesh_peers"]
+	if mesh == nil || len(mesh.Metric) != 1 || mesh.Metric[0].GetGauge().GetValue() != 1 {
+		t.Fatalf("expected one mesh peer, got %v", mesh)
+	}
+	rpc := mfs["pubsub_rpc_bytes_total"]
+	if rpc == nil || len(rpc.Metric) != 2 {
+		t.Fatalf("expected inbound and outbound RPC bytes, got %v", rpc)
+	}
+	for _, m := range rpc.Metric {
+		if m.GetCounter().GetValue() == 0 {
+			t.Fatalf("expected non-zero RPC bytes, got %v", m)
+		}
+	}
+	if scores := mfs["pubsub_peer_scores"]; scores == nil || scores.Metric[0].GetHistogram().GetSampleCount() != 0 {
+		t.Fatalf("expected an empty score distribution without scoring, got %v", scores)
+	}
+
+	// 同一注册器不能注册两次
+	h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if _, err := pubsub.NewGossipSub(ctx, h, WithPrometheusMetrics(reg)); err == nil {
+		t.Fatal("expected duplicate registration to fail")
+	}
+}
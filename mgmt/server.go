@@ -0,0 +1,306 @@
+// 作用：运行中的 PubSub 节点的 gRPC 管理服务。
+// 功能：暴露主题、对等节点、分数、网格状态的查询以及发布和订阅流，使运维人员无需链接自定义代码即可远程管理无界面节点。
+
+package mgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	logging "github.com/dep2p/log"
+	"github.com/dep2p/pubsub"
+	"github.com/dep2p/pubsub/mgmt/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var logger = logging.Logger("pubsub-mgmt")
+
+// Server 实现 pb.ManagementServer。
+// 发布和订阅使用服务自己加入的主题句柄，因此这些主题不能已被应用程序加入。
+type Server struct {
+	pb.UnimplementedManagementServer
+
+	ps *pubsub.PubSub
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	streams sync.WaitGroup // 活动的订阅流
+
+	mx     sync.Mutex
+	topics map[string]*pubsub.Topic // 服务加入的主题
+	grpc   *grpc.Server             // Start 创建的 gRPC 服务
+	closed bool
+}
+
+var _ pb.ManagementServer = (*Server)(nil)
+
+// NewServer 创建管理服务
+// 参数:
+//   - ps: PubSub 实例
+//
+// 返回值:
+//   - *Server: 管理服务
+func NewServer(ps *pubsub.PubSub) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		ps:     ps,
+		ctx:    ctx,
+		cancel: cancel,
+		topics: make(map[string]*pubsub.Topic),
+	}
+}
+
+// Register 将管理服务注册到应用程序自己的 gRPC 服务上
+// 参数:
+//   - gs: gRPC 服务
+func (s *Server) Register(gs *grpc.Server) {
+	pb.RegisterManagementServer(gs, s)
+}
+
+// Start 在给定地址上启动一个只包含管理服务的 gRPC 服务
+// 参数:
+//   - addr: 监听地址，例如 "127.0.0.1:9090"
+//   - opts: gRPC 服务选项，例如 TLS 凭据和认证拦截器
+//
+// 返回值:
+//   - net.Addr: 监听地址
+//   - error: 错误信息，如果有的话
+func (s *Server) Start(addr string, opts ...grpc.ServerOption) (net.Addr, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return nil, errServerClosed
+	}
+	if s.grpc != nil {
+		logger.Warnf("管理服务已经启动")
+		return nil, fmt.Errorf("管理服务已经启动")
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		logger.Warnf("管理服务监听 %s 失败: %s", addr, err)
+		return nil, fmt.Errorf("管理服务监听 %s 失败: %w", addr, err)
+	}
+	s.grpc = grpc.NewServer(opts...)
+	s.Register(s.grpc)
+
+	go func() {
+		if err := s.grpc.Serve(l); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Warnf("管理服务出错: %s", err)
+		}
+	}()
+	return l.Addr(), nil
+}
+
+// Close 结束所有订阅流，停止 Start 启动的 gRPC 服务，并关闭服务加入的主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (s *Server) Close() error {
+	s.mx.Lock()
+	s.closed = true
+	s.cancel()
+	gs := s.grpc
+	s.mx.Unlock()
+
+	if gs != nil {
+		gs.Stop()
+	}
+	s.streams.Wait()
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	var err error
+	for name, t := range s.topics {
+		if cerr := t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.topics, name)
+	}
+	return err
+}
+
+// errServerClosed 表示管理服务已关闭
+var errServerClosed = errors.New("管理服务已关闭")
+
+// topic 返回主题句柄，首次使用时加入主题
+// 参数:
+//   - name: 主题
+//
+// 返回值:
+//   - *pubsub.Topic: 主题句柄
+//   - error: gRPC 状态错误
+func (s *Server) topic(name string) (*pubsub.Topic, error) {
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "主题不能为空")
+	}
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	if s.closed {
+		return nil, status.Error(codes.Unavailable, errServerClosed.Error())
+	}
+	if t, ok := s.topics[name]; ok {
+		return t, nil
+	}
+	t, err := s.ps.Join(name)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "加入主题 %s 失败: %s", name, err)
+	}
+	s.topics[name] = t
+	return t, nil
+}
+
+// ListTopics 实现 pb.ManagementServer 接口
+func (s *Server) ListTopics(ctx context.Context, req *pb.ListTopicsRequest) (*pb.ListTopicsResponse, error) {
+	topics := s.ps.GetTopics()
+	sort.Strings(topics)
+	return &pb.ListTopicsResponse{Topics: topics}, nil
+}
+
+// ListPeers 实现 pb.ManagementServer 接口
+func (s *Server) ListPeers(ctx context.Context, req *pb.ListPeersRequest) (*pb.ListPeersResponse, error) {
+	resp := &pb.ListPeersResponse{}
+	for _, pid := range s.ps.ListPeers(req.GetTopic()) {
+		resp.Peers = append(resp.Peers, pid.String())
+	}
+	sort.Strings(resp.Peers)
+	return resp, nil
+}
+
+// PeerScores 实现 pb.ManagementServer 接口
+func (s *Server) PeerScores(ctx context.Context, req *pb.PeerScoresRequest) (*pb.PeerScoresResponse, error) {
+	resp := &pb.PeerScoresResponse{}
+	for pid, score := range s.ps.PeerScores() {
+		resp.Scores = append(resp.Scores, &pb.PeerScore{Peer: pid.String(), Score: score})
+	}
+	sort.Slice(resp.Scores, func(i, j int) bool { return resp.Scores[i].Peer < resp.Scores[j].Peer })
+	return resp, nil
+}
+
+// MeshState 实现 pb.ManagementServer 接口
+func (s *Server) MeshState(ctx context.Context, req *pb.MeshStateRequest) (*pb.MeshStateResponse, error) {
+	topics := []string{req.GetTopic()}
+	if req.GetTopic() == "" {
+		topics = s.ps.GetTopics()
+		sort.Strings(topics)
+	}
+
+	resp := &pb.MeshStateResponse{}
+	for _, topic := range topics {
+		tm := &pb.TopicMesh{Topic: topic}
+		for _, pid := range s.ps.MeshPeers(topic) {
+			tm.Peers = append(tm.Peers, pid.String())
+		}
+		sort.Strings(tm.Peers)
+		resp.Topics = append(resp.Topics, tm)
+	}
+	return resp, nil
+}
+
+// Publish 实现 pb.ManagementServer 接口
+func (s *Server) Publish(ctx context.Context, req *pb.PublishRequest) (*pb.PublishResponse, error) {
+	t, err := s.topic(req.GetTopic())
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Publish(ctx, req.GetData()); err != nil {
+		return nil, status.Errorf(codes.Internal, "发布消息失败: %s", err)
+	}
+	return &pb.PublishResponse{}, nil
+}
+
+// Subscribe 实现 pb.ManagementServer 接口
+func (s *Server) Subscribe(req *pb.SubscribeRequest, stream pb.Management_SubscribeServer) error {
+	t, err := s.topic(req.GetTopic())
+	if err != nil {
+		return err
+	}
+
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		return status.Error(codes.Unavailable, errServerClosed.Error())
+	}
+	s.streams.Add(1)
+	s.mx.Unlock()
+	defer s.streams.Done()
+
+	sub, err := t.Subscribe()
+	if err != nil {
+		return status.Errorf(codes.Internal, "订阅主题 %s 失败: %s", req.GetTopic(), err)
+	}
+	defer sub.Cancel()
+
+	// 流在客户端断开或服务关闭时结束
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	for {
+		msg, err := sub.Next(ctx)
+		if err != nil {
+			return status.FromContextError(err).Err()
+		}
+		out := &pb.Message{
+			Id:    msg.ID,
+			Topic: msg.GetTopic(),
+			Data:  msg.GetData(),
+		}
+		if from := msg.GetFrom(); from != "" {
+			out.From = from.String()
+		}
+		if err := stream.Send(out); err != nil {
+			return err
+		}
+	}
+}
+
+// subsystems 是可以通过管理服务控制的子系统
+var subsystems = []pubsub.Subsystem{
+	pubsub.SubsystemGossip,
+	pubsub.SubsystemPX,
+	pubsub.SubsystemDiscovery,
+	pubsub.SubsystemTracing,
+	pubsub.SubsystemMetrics,
+}
+
+// ListSubsystems 实现 pb.ManagementServer 接口
+func (s *Server) ListSubsystems(ctx context.Context, req *pb.ListSubsystemsRequest) (*pb.ListSubsystemsResponse, error) {
+	resp := &pb.ListSubsystemsResponse{}
+	for _, sub := range subsystems {
+		resp.Subsystems = append(resp.Subsystems, &pb.SubsystemState{
+			Name:    sub.String(),
+			Running: !s.ps.SubsystemStopped(sub),
+		})
+	}
+	return resp, nil
+}
+
+// SetSubsystem 实现 pb.ManagementServer 接口
+func (s *Server) SetSubsystem(ctx context.Context, req *pb.SetSubsystemRequest) (*pb.SetSubsystemResponse, error) {
+	for _, sub := range subsystems {
+		if sub.String() != req.GetName() {
+			continue
+		}
+		var err error
+		if req.GetRunning() {
+			err = s.ps.StartSubsystem(sub)
+		} else {
+			err = s.ps.StopSubsystem(sub)
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.FailedPrecondition, "设置子系统 %s 失败: %s", sub, err)
+		}
+		return &pb.SetSubsystemResponse{}, nil
+	}
+	return nil, status.Errorf(codes.InvalidArgument, "未知的子系统: %s", req.GetName())
+}
@@ -0,0 +1,222 @@
+package mgmt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p"
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/pubsub"
+	"github.com/dep2p/pubsub/mgmt/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// TestManagementServer 测试通过 gRPC 管理运行中的节点
+func TestManagementServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var hosts []host.Host
+	var psubs []*pubsub.PubSub
+	for i := 0; i < 2; i++ {
+		h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.Close() })
+		hosts = append(hosts, h)
+	}
+	for _, h := range hosts {
+		ps, err := pubsub.NewGossipSub(ctx, h, pubsub.WithPeerScore(
+			&pubsub.PeerScoreParams{
+				AppSpecificScore: func(p peer.ID) float64 {
+					if p == hosts[1].ID() {
+						return 5
+					}
+					return 0
+				},
+				AppSpecificWeight: 1,
+				DecayInterval:     time.Second,
+				DecayToZero:       0.01,
+			},
+			&pubsub.PeerScoreThresholds{
+				GossipThreshold:   -10,
+				PublishThreshold:  -100,
+				GraylistThreshold: -10000,
+			}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+	}
+	if err := hosts[1].Connect(ctx, hosts[0].Peerstore().PeerInfo(hosts[0].ID())); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(psubs[0])
+	addr, err := srv.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewManagementClient(conn)
+
+	remote, err := psubs[1].Join("ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteSub, err := remote.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 订阅流加入主题
+	stream, err := client.Subscribe(ctx, &pb.SubscribeRequest{Topic: "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	topics, err := client.ListTopics(ctx, &pb.ListTopicsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics.Topics) != 1 || topics.Topics[0] != "ops" {
+		t.Fatalf("expected the subscribed topic, got %v", topics.Topics)
+	}
+
+	peers, err := client.ListPeers(ctx, &pb.ListPeersRequest{Topic: "ops"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers.Peers) != 1 || peers.Peers[0] != hosts[1].ID().String() {
+		t.Fatalf("expected the remote peer, got %v", peers.Peers)
+	}
+
+	mesh, err := client.MeshState(ctx, &pb.MeshStateRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mesh.Topics) != 1 || len(mesh.Topics[0].Peers) != 1 || mesh.Topics[0].Peers[0] != hosts[1].ID().String() {
+		t.Fatalf("expected the remote peer in the mesh, got %v", mesh.Topics)
+	}
+
+	scores, err := client.PeerScores(ctx, &pb.PeerScoresRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores.Scores) != 1 || scores.Scores[0].Peer != hosts[1].ID().String() || scores.Scores[0].Score != 5 {
+		t.Fatalf("expected the remote peer score, got %v", scores.Scores)
+	}
+
+	// 远端发布的消息出现在订阅流中
+	if err := remote.Publish(ctx, []byte("status")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "status" || msg.Topic != "ops" || msg.From != hosts[1].ID().String() {
+		t.Fatalf("unexpected streamed message: %v", msg)
+	}
+
+	// 通过管理服务发布
+	if _, err := client.Publish(ctx, &pb.PublishRequest{Topic: "ops", Data: []byte("restart")}); err != nil {
+		t.Fatal(err)
+	}
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer tcancel()
+	got, err := remoteSub.Next(tctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.Data) != "restart" {
+		t.Fatalf("expected published message, got %q", got.Data)
+	}
+
+	if _, err := client.Publish(ctx, &pb.PublishRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for empty topic, got %v", err)
+	}
+
+	// 关闭服务会结束订阅流并释放主题
+	if err := srv.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[0].Join("ops"); err != nil {
+		t.Fatalf("expected management topic to be released: %s", err)
+	}
+}
+
+// TestManagementSubsystems 测试通过 gRPC 停止和恢复子系统
+func TestManagementSubsystems(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	ps, err := pubsub.NewGossipSub(ctx, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := NewServer(ps)
+	addr, err := srv.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	conn, err := grpc.NewClient(addr.String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewManagementClient(conn)
+
+	if _, err := client.SetSubsystem(ctx, &pb.SetSubsystemRequest{Name: "gossip", Running: false}); err != nil {
+		t.Fatal(err)
+	}
+	if !ps.SubsystemStopped(pubsub.SubsystemGossip) {
+		t.Fatal("expected gossip to be stopped")
+	}
+
+	list, err := client.ListSubsystems(ctx, &pb.ListSubsystemsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Subsystems) != 5 {
+		t.Fatalf("expected 5 subsystems, got %v", list.Subsystems)
+	}
+	for _, sub := range list.Subsystems {
+		if sub.Running != (sub.Name != "gossip") {
+			t.Fatalf("unexpected state for %s: running=%v", sub.Name, sub.Running)
+		}
+	}
+
+	if _, err := client.SetSubsystem(ctx, &pb.SetSubsystemRequest{Name: "gossip", Running: true}); err != nil {
+		t.Fatal(err)
+	}
+	if ps.SubsystemStopped(pubsub.SubsystemGossip) {
+		t.Fatal("expected gossip to be running")
+	}
+
+	if _, err := client.SetSubsystem(ctx, &pb.SetSubsystemRequest{Name: "nope"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for unknown subsystem, got %v", err)
+	}
+}
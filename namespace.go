@@ -0,0 +1,296 @@
+// 作用：同一 PubSub 实例中的多租户命名空间隔离。
+// 功能：Namespace 为主题名称加上命名空间前缀，限制命名空间可以加入的主题数以及本地发布的消息速率和字节速率；
+// 命名空间的主题只能通过该命名空间加入和订阅，直接通过 PubSub 或其他命名空间访问会被拒绝，使一个节点可以安全地承载多个应用。
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NamespaceSeparator 是命名空间前缀与主题名称之间的分隔符
+const NamespaceSeparator = "/"
+
+var (
+	// ErrNamespaceQuota 表示超出命名空间的主题数配额
+	ErrNamespaceQuota = errors.New("超出命名空间的主题数配额")
+	// ErrCrossNamespace 表示主题属于其他命名空间
+	ErrCrossNamespace = errors.New("主题属于其他命名空间")
+)
+
+// NamespaceQuota 是命名空间的配额，字段为 0 表示不限制
+type NamespaceQuota struct {
+	MaxTopics int     // 同时加入的主题数上限
+	MsgRate   float64 // 本地发布的每秒消息数，所有主题合计
+	MsgBurst  int     // 允许突发发布的消息数，为 0 时取消息速率向上取整，至少为 1
+	ByteRate  float64 // 本地发布的每秒负载字节数，所有主题合计
+	ByteBurst int     // 允许突发发布的字节数，为 0 时取字节速率与最大消息大小中的较大者
+}
+
+// Namespace 是 PubSub 实例中隔离的命名空间，由 PubSub.Namespace 创建
+type Namespace struct {
+	p     *PubSub
+	name  string
+	quota NamespaceQuota
+
+	joinMx sync.Mutex // 串行化主题数配额的检查和加入
+
+	mx          sync.Mutex
+	msgBucket   tokenBucket
+	bytesBucket tokenBucket
+}
+
+// Namespace 创建命名空间。命名空间的主题名称为命名空间名加上 NamespaceSeparator 和主题名。
+// 名称不能为空、不能包含分隔符，也不能已有同名的命名空间或已加入带有该前缀的主题。
+// 参数:
+//   - name: 命名空间名
+//   - quota: 命名空间的配额
+//
+// 返回值:
+//   - *Namespace: 命名空间
+//   - error: 错误信息，如果有的话
+func (p *PubSub) Namespace(name string, quota NamespaceQuota) (*Namespace, error) {
+	if name == "" || strings.Contains(name, NamespaceSeparator) {
+		logger.Warnf("无效的命名空间名: %q", name)
+		return nil, fmt.Errorf("无效的命名空间名: %q", name)
+	}
+	if quota.MaxTopics < 0 || quota.MsgRate < 0 || quota.MsgBurst < 0 || quota.ByteRate < 0 || quota.ByteBurst < 0 {
+		logger.Warnf("无效的命名空间配额: %+v", quota)
+		return nil, fmt.Errorf("无效的命名空间配额: %+v", quota)
+	}
+
+	p.nsMx.Lock()
+	defer p.nsMx.Unlock()
+	if _, ok := p.namespaces[name]; ok {
+		logger.Warnf("命名空间 %s 已存在", name)
+		return nil, fmt.Errorf("命名空间 %s 已存在", name)
+	}
+
+	ns := &Namespace{p: p, name: name, quota: quota}
+	if n := ns.joinedTopics(); n < 0 {
+		return nil, p.ctx.Err()
+	} else if n > 0 {
+		logger.Warnf("已加入命名空间 %s 前缀的主题", name)
+		return nil, fmt.Errorf("已加入命名空间 %s 前缀的主题", name)
+	}
+
+	if p.namespaces == nil {
+		p.namespaces = make(map[string]*Namespace)
+	}
+	p.namespaces[name] = ns
+	return ns, nil
+}
+
+// Name 返回命名空间名
+// 返回值:
+//   - string: 命名空间名
+func (ns *Namespace) Name() string {
+	return ns.name
+}
+
+// TopicName 返回主题在 PubSub 中的完整名称
+// 参数:
+//   - topic: 命名空间内的主题名
+//
+// 返回值:
+//   - string: 带命名空间前缀的主题名
+func (ns *Namespace) TopicName(topic string) string {
+	return ns.name + NamespaceSeparator + topic
+}
+
+// Join 加入命名空间内的主题，返回的主题句柄的发布受命名空间配额限制
+// 参数:
+//   - topic: 命名空间内的主题名
+//   - opts: 主题选项
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - error: 错误信息，如果有的话
+func (ns *Namespace) Join(topic string, opts ...TopicOpt) (*Topic, error) {
+	t, ok, err := ns.tryJoin(topic, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("topic already exists")
+	}
+	return t, nil
+}
+
+// Subscribe 订阅命名空间内的主题，主题尚未加入时先加入
+// 参数:
+//   - topic: 命名空间内的主题名
+//   - opts: 订阅选项
+//
+// 返回值:
+//   - *Subscription: 订阅
+//   - error: 错误信息，如果有的话
+func (ns *Namespace) Subscribe(topic string, opts ...SubOpt) (*Subscription, error) {
+	t, _, err := ns.tryJoin(topic)
+	if err != nil {
+		return nil, err
+	}
+	return t.Subscribe(opts...)
+}
+
+// Topics 返回命名空间内已加入的主题名，不带命名空间前缀
+// 返回值:
+//   - []string: 主题名列表
+func (ns *Namespace) Topics() []string {
+	out := make(chan []string, 1)
+	prefix := ns.TopicName("")
+	select {
+	case ns.p.eval <- func() {
+		var topics []string
+		for topic := range ns.p.myTopics {
+			if strings.HasPrefix(topic, prefix) {
+				topics = append(topics, strings.TrimPrefix(topic, prefix))
+			}
+		}
+		out <- topics
+	}:
+		return <-out
+	case <-ns.p.ctx.Done():
+		return nil
+	}
+}
+
+// tryJoin 检查主题数配额后加入命名空间内的主题
+// 参数:
+//   - topic: 命名空间内的主题名
+//   - opts: 主题选项
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - bool: 主题是否为新创建的
+//   - error: 错误信息，如果有的话
+func (ns *Namespace) tryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
+	ns.joinMx.Lock()
+	defer ns.joinMx.Unlock()
+
+	full := ns.TopicName(topic)
+	if ns.quota.MaxTopics > 0 && !ns.joined(full) {
+		n := ns.joinedTopics()
+		if n < 0 {
+			return nil, false, ns.p.ctx.Err()
+		}
+		if n >= ns.quota.MaxTopics {
+			logger.Warnf("命名空间 %s 已加入 %d 个主题", ns.name, n)
+			return nil, false, fmt.Errorf("%w: 命名空间 %s 最多加入 %d 个主题", ErrNamespaceQuota, ns.name, ns.quota.MaxTopics)
+		}
+	}
+
+	opts = append([]TopicOpt{func(t *Topic) error {
+		t.ns = ns
+		return nil
+	}}, opts...)
+	return ns.p.tryJoin(full, opts...)
+}
+
+// joined 返回主题是否已加入
+// 参数:
+//   - topic: 完整的主题名
+//
+// 返回值:
+//   - bool: 是否已加入
+func (ns *Namespace) joined(topic string) bool {
+	out := make(chan bool, 1)
+	select {
+	case ns.p.eval <- func() {
+		_, ok := ns.p.myTopics[topic]
+		out <- ok
+	}:
+		return <-out
+	case <-ns.p.ctx.Done():
+		return false
+	}
+}
+
+// joinedTopics 返回已加入的带有命名空间前缀的主题数
+// 返回值:
+//   - int: 主题数，PubSub 已关闭时返回 -1
+func (ns *Namespace) joinedTopics() int {
+	out := make(chan int, 1)
+	prefix := ns.TopicName("")
+	select {
+	case ns.p.eval <- func() {
+		n := 0
+		for topic := range ns.p.myTopics {
+			if strings.HasPrefix(topic, prefix) {
+				n++
+			}
+		}
+		out <- n
+	}:
+		return <-out
+	case <-ns.p.ctx.Done():
+		return -1
+	}
+}
+
+// allowPublish 按命名空间的消息速率和字节速率检查本地发布，nil 命名空间总是允许
+// 参数:
+//   - size: 负载字节数
+//   - now: 当前时间
+//
+// 返回值:
+//   - error: 超出配额时返回 ErrRateLimited
+func (ns *Namespace) allowPublish(size int, now time.Time) error {
+	if ns == nil || (ns.quota.MsgRate == 0 && ns.quota.ByteRate == 0) {
+		return nil
+	}
+
+	ns.mx.Lock()
+	defer ns.mx.Unlock()
+
+	q := ns.quota
+	if q.MsgRate > 0 {
+		burst := float64(q.MsgBurst)
+		if burst == 0 {
+			burst = math.Max(1, math.Ceil(q.MsgRate))
+		}
+		if !ns.msgBucket.take(1, q.MsgRate, burst, now) {
+			return fmt.Errorf("%w: 命名空间 %s 的消息速率", ErrRateLimited, ns.name)
+		}
+	}
+	if q.ByteRate > 0 {
+		burst := float64(q.ByteBurst)
+		if burst == 0 {
+			burst = math.Max(q.ByteRate, float64(ns.p.maxMessageSize))
+		}
+		if !ns.bytesBucket.take(float64(size), q.ByteRate, burst, now) {
+			if q.MsgRate > 0 {
+				ns.msgBucket.tokens++ // 退还消息令牌
+			}
+			return fmt.Errorf("%w: 命名空间 %s 的字节速率", ErrRateLimited, ns.name)
+		}
+	}
+	return nil
+}
+
+// checkNamespace 检查主题句柄是否可以访问主题：属于某个命名空间的主题只能通过该命名空间加入
+// 参数:
+//   - t: 主题句柄
+//
+// 返回值:
+//   - error: 主题属于其他命名空间时返回 ErrCrossNamespace
+func (p *PubSub) checkNamespace(t *Topic) error {
+	name, _, ok := strings.Cut(t.topic, NamespaceSeparator)
+	if !ok {
+		return nil
+	}
+
+	p.nsMx.RLock()
+	owner := p.namespaces[name]
+	p.nsMx.RUnlock()
+	if owner != nil && owner != t.ns {
+		logger.Warnf("主题 %s 属于命名空间 %s", t.topic, name)
+		return fmt.Errorf("%w: 主题 %s 属于命名空间 %s", ErrCrossNamespace, t.topic, name)
+	}
+	return nil
+}
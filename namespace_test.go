@@ -0,0 +1,113 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestNamespace 测试命名空间的主题前缀和跨命名空间隔离
+func TestNamespace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	if _, err := psubs[0].Namespace("a/b", NamespaceQuota{}); err == nil {
+		t.Fatal("expected error for a name containing the separator")
+	}
+	nsA, err := psubs[0].Namespace("appA", NamespaceQuota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[0].Namespace("appA", NamespaceQuota{}); err == nil {
+		t.Fatal("expected error for a duplicate namespace")
+	}
+	nsB, err := psubs[0].Namespace("appB", NamespaceQuota{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := nsA.Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topics := nsA.Topics(); len(topics) != 1 || topics[0] != "foo" {
+		t.Fatalf("expected namespace topics [foo], got %v", topics)
+	}
+	if topics := nsB.Topics(); len(topics) != 0 {
+		t.Fatalf("expected no topics in appB, got %v", topics)
+	}
+
+	// 直接通过 PubSub 访问命名空间的主题被拒绝
+	if _, err := psubs[0].Join(nsA.TopicName("bar")); !errors.Is(err, ErrCrossNamespace) {
+		t.Fatalf("expected ErrCrossNamespace, got %v", err)
+	}
+	if _, err := psubs[0].Subscribe(nsA.TopicName("foo")); !errors.Is(err, ErrCrossNamespace) {
+		t.Fatalf("expected ErrCrossNamespace, got %v", err)
+	}
+
+	// 远端节点以完整的主题名发布
+	topic, err := psubs[1].Join("appA/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	msg := []byte("hello tenant")
+	if err := topic.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+}
+
+// TestNamespaceQuota 测试命名空间的主题数和发布速率配额
+func TestNamespaceQuota(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0])
+	ns, err := ps.Namespace("tenant", NamespaceQuota{MaxTopics: 2, MsgRate: 1, MsgBurst: 2, ByteRate: 100, ByteBurst: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foo, err := ns.Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bar, err := ns.Join("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Join("baz"); !errors.Is(err, ErrNamespaceQuota) {
+		t.Fatalf("expected ErrNamespaceQuota, got %v", err)
+	}
+
+	// 字节速率
+	if err := foo.Publish(ctx, make([]byte, 200)); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited for bytes, got %v", err)
+	}
+
+	// 消息速率由命名空间内的所有主题共享
+	if err := foo.Publish(ctx, []byte("one")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bar.Publish(ctx, []byte("two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := bar.Publish(ctx, []byte("three")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited for messages, got %v", err)
+	}
+
+	// 关闭主题后释放主题数配额
+	if err := bar.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ns.Join("baz"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,6 +2,7 @@
 package pubsub
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -22,20 +23,27 @@ const (
 type Options struct {
 	mu sync.Mutex // 互斥锁，用于保护字段的并发访问
 
-	FollowupTime        time.Duration       // 跟随时间,用于控制消息传播延迟
-	GossipFactor        float64             // Gossip 因子,控制消息传播的概率
-	D                   int                 // GossipSub 主题网格的理想度数,每个节点维护的连接数
-	Dlo                 int                 // GossipSub 主题网格中保持的最少节点数,网格连接的下限
-	MaxPendingConns     int                 // 最大待处理连接数,限制并发连接请求数量
-	MaxMessageSize      int                 // 最大消息大小,限制单条消息的字节数
-	SignMessages        bool                // 是否签名消息,控制消息的安全性
-	ValidateMessages    bool                // 是否验证消息,控制消息的合法性检查
-	DirectPeers         []peer.AddrInfo     // 直连对等节点列表,保存需要直接连接的节点信息
-	HeartbeatInterval   time.Duration       // 心跳间隔,控制节点存活检测的频率
-	MaxTransmissionSize int                 // 最大传输大小,限制单次传输的字节数
-	LoadConfig          bool                // 是否加载配置选项,控制是否使用外部配置
-	PubSubMode          PubSubType          // 发布订阅模式,指定使用的协议类型
-	discovery           discovery.Discovery // Discovery服务,用于节点发现
+	FollowupTime          time.Duration          // 跟随时间,用于控制消息传播延迟
+	GossipFactor          float64                // Gossip 因子,控制消息传播的概率
+	D                     int                    // GossipSub 主题网格的理想度数,每个节点维护的连接数
+	Dlo                   int                    // GossipSub 主题网格中保持的最少节点数,网格连接的下限
+	MaxPendingConns       int                    // 最大待处理连接数,限制并发连接请求数量
+	MaxMessageSize        int                    // 最大消息大小,限制单条消息的字节数
+	SignaturePolicy       MessageSignaturePolicy // 消息签名策略,控制消息签名的生成和验证
+	DirectPeers           []peer.AddrInfo        // 直连对等节点列表,保存需要直接连接的节点信息
+	HeartbeatInterval     time.Duration          // 心跳间隔,控制节点存活检测的频率
+	MaxTransmissionSize   int                    // 最大传输大小,限制单次传输的字节数
+	HybridThreshold       int                    // 混合路由阈值,主题对等节点数低于该值时洪泛,0 表示不启用
+	LoadConfig            bool                   // 是否加载配置选项,控制是否使用外部配置
+	PubSubMode            PubSubType             // 发布订阅模式,指定使用的协议类型
+	ScoreThresholds       *PeerScoreThresholds   // 节点评分阈值,仅用于 GossipSub 模式,为 nil 时使用默认阈值
+	PeerOutboundQueueSize int                    // 每个对等节点的出站消息队列大小,0 表示使用默认值
+	NetworkSize           int                    // 预计的网络节点数,RandomSub 按其平方根计算扇出,0 表示只使用最小扇出
+	discovery             discovery.Discovery    // Discovery服务,用于节点发现
+
+	updateMx sync.Mutex         // 串行化运行时修改
+	live     *PubSub            // 运行中的 PubSub 实例,用于传播运行时修改
+	onChange func(ConfigChange) // 配置在运行时被修改后的回调
 }
 
 // NodeOption 定义了一个函数类型，用于配置PubSub
@@ -94,8 +102,8 @@ func DefaultOptions() *Options {
 		MaxMessageSize: 1024 * 1024, // 1MB，根据实际需求调整
 
 		// 在小规模可信网络中，可以考虑关闭签名和验证以提高性能
-		SignMessages:     false, // 小规模可信网络可以关闭
-		ValidateMessages: false, // 小规模可信网络可以关闭
+		// 需要匿名时使用 StrictNoSign，需要来源可信时使用 StrictSign
+		SignaturePolicy: LaxNoSign, // 小规模可信网络可以关闭
 
 		// 降低心跳间隔，加快节点状态更新
 		// 在小规模网络中，可以使用更频繁的心跳来保持连接状态
@@ -165,28 +173,23 @@ func WithSetMaxMessageSize(size int) NodeOption {
 	}
 }
 
-// WithSetSignMessages 设置是否签名消息
+// WithSetSignaturePolicy 设置消息签名策略
+// StrictSign 签名并要求验证签名；StrictNoSign 不签名、拒绝携带签名的消息，并省略 from 和 seqno 字段以保持匿名；
+// LaxSign 签名但仅在存在签名时验证。
 // 参数:
-//   - sign: 是否签名消息
+//   - policy: 要设置的消息签名策略
 //
 // 返回值:
 //   - NodeOption: 返回一个配置函数
-func WithSetSignMessages(sign bool) NodeOption {
+func WithSetSignaturePolicy(policy MessageSignaturePolicy) NodeOption {
 	return func(o *Options) error {
-		o.SignMessages = sign
-		return nil
-	}
-}
-
-// WithSetValidateMessages 设置是否验证消息
-// 参数:
-//   - validate: 是否验证消息
-//
-// 返回值:
-//   - NodeOption: 返回一个配置函数
-func WithSetValidateMessages(validate bool) NodeOption {
-	return func(o *Options) error {
-		o.ValidateMessages = validate
+		switch policy {
+		case StrictSign, StrictNoSign, LaxSign, LaxNoSign:
+		default:
+			logger.Warnf("未知的消息签名策略: %d", policy)
+			return fmt.Errorf("未知的消息签名策略: %d", policy)
+		}
+		o.SignaturePolicy = policy
 		return nil
 	}
 }
@@ -230,6 +233,19 @@ func WithSetMaxTransmissionSize(size int) NodeOption {
 	}
 }
 
+// WithSetHybridThreshold 设置混合路由阈值，仅用于 GossipSub 模式
+// 参数:
+//   - n: 主题对等节点数低于该值时洪泛，0 表示不启用
+//
+// 返回值:
+//   - NodeOption: 返回一个配置函数
+func WithSetHybridThreshold(n int) NodeOption {
+	return func(o *Options) error {
+		o.HybridThreshold = n
+		return nil
+	}
+}
+
 // WithSetD 设置 GossipSub 主题网格的理想度数
 // 参数:
 //   - d: 要设置的理想度数
@@ -282,6 +298,45 @@ func WithSetPubSubMode(mode PubSubType) NodeOption {
 	}
 }
 
+// WithSetScoreThresholds 设置 GossipSub 的节点评分阈值
+// 参数:
+//   - thresholds: 要设置的评分阈值，为 nil 时使用默认阈值
+//
+// 返回值:
+//   - NodeOption: 返回一个配置函数
+func WithSetScoreThresholds(thresholds *PeerScoreThresholds) NodeOption {
+	return func(o *Options) error {
+		o.ScoreThresholds = thresholds
+		return nil
+	}
+}
+
+// WithSetPeerOutboundQueueSize 设置每个对等节点的出站消息队列大小
+// 参数:
+//   - size: 要设置的队列大小，0 表示使用默认值
+//
+// 返回值:
+//   - NodeOption: 返回一个配置函数
+func WithSetPeerOutboundQueueSize(size int) NodeOption {
+	return func(o *Options) error {
+		o.PeerOutboundQueueSize = size
+		return nil
+	}
+}
+
+// WithSetNetworkSize 设置预计的网络节点数，仅用于 RandomSub 模式
+// 参数:
+//   - n: 预计的网络节点数，0 表示只使用最小扇出
+//
+// 返回值:
+//   - NodeOption: 返回一个配置函数
+func WithSetNetworkSize(n int) NodeOption {
+	return func(o *Options) error {
+		o.NetworkSize = n
+		return nil
+	}
+}
+
 // WithNodeDiscovery 设置 Discovery 服务
 // 参数:
 //   - d: 要设置的 Discovery 服务实例
@@ -344,22 +399,13 @@ func (o *Options) GetMaxMessageSize() int {
 	return o.MaxMessageSize
 }
 
-// GetSignMessages 获取是否签名消息
+// GetSignaturePolicy 获取消息签名策略
 // 返回值:
-//   - bool: 当前是否设置为签名消息
-func (o *Options) GetSignMessages() bool {
+//   - MessageSignaturePolicy: 当前设置的消息签名策略
+func (o *Options) GetSignaturePolicy() MessageSignaturePolicy {
 	o.mu.Lock()         // 加锁保护并发访问
 	defer o.mu.Unlock() // 函数结束时解锁
-	return o.SignMessages
-}
-
-// GetValidateMessages 获取是否验证消息
-// 返回值:
-//   - bool: 当前是否设置为验证消息
-func (o *Options) GetValidateMessages() bool {
-	o.mu.Lock()         // 加锁保护并发访问
-	defer o.mu.Unlock() // 函数结束时解锁
-	return o.ValidateMessages
+	return o.SignaturePolicy
 }
 
 // GetDirectPeers 获取直连对等节点列表
@@ -389,6 +435,15 @@ func (o *Options) GetMaxTransmissionSize() int {
 	return o.MaxTransmissionSize
 }
 
+// GetHybridThreshold 获取混合路由阈值
+// 返回值:
+//   - int: 当前设置的混合路由阈值
+func (o *Options) GetHybridThreshold() int {
+	o.mu.Lock()         // 加锁保护并发访问
+	defer o.mu.Unlock() // 函数结束时解锁
+	return o.HybridThreshold
+}
+
 // GetD 获取 GossipSub 主题网格的理想度数
 // 返回值:
 //   - int: 当前设置的理想度数
@@ -424,3 +479,30 @@ func (o *Options) GetPubSubMode() PubSubType {
 	defer o.mu.Unlock() // 函数结束时解锁
 	return o.PubSubMode
 }
+
+// GetScoreThresholds 获取节点评分阈值
+// 返回值:
+//   - *PeerScoreThresholds: 当前设置的评分阈值，未设置时为 nil
+func (o *Options) GetScoreThresholds() *PeerScoreThresholds {
+	o.mu.Lock()         // 加锁保护并发访问
+	defer o.mu.Unlock() // 函数结束时解锁
+	return o.ScoreThresholds
+}
+
+// GetPeerOutboundQueueSize 获取每个对等节点的出站消息队列大小
+// 返回值:
+//   - int: 当前设置的队列大小，0 表示使用默认值
+func (o *Options) GetPeerOutboundQueueSize() int {
+	o.mu.Lock()         // 加锁保护并发访问
+	defer o.mu.Unlock() // 函数结束时解锁
+	return o.PeerOutboundQueueSize
+}
+
+// GetNetworkSize 获取预计的网络节点数
+// 返回值:
+//   - int: 当前设置的网络节点数，0 表示未设置
+func (o *Options) GetNetworkSize() int {
+	o.mu.Lock()         // 加锁保护并发访问
+	defer o.mu.Unlock() // 函数结束时解锁
+	return o.NetworkSize
+}
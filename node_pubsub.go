@@ -5,7 +5,6 @@ package pubsub
 import (
 	"context"
 	"fmt"
-	"math"
 	"os"
 	"path/filepath"
 	"sync"
@@ -37,6 +36,7 @@ type NodePubSub struct {
 	startUp          int32                    // 启动状态，用原子操作来保证线程安全
 	subscribedTopics map[string]*Subscription // 已订阅的主题，存储所有当前节点订阅的主题
 	subscribeLock    sync.Mutex               // 订阅锁，用于保护订阅操作的并发访问
+	options          *Options                 // 节点配置，支持通过 Update 在运行时修改
 }
 
 // NewNodePubSub 创建并返回一个新的 NodePubSub 实例
@@ -55,6 +55,26 @@ func NewNodePubSub(ctx context.Context, host host.Host, opts ...NodeOption) (*No
 		logger.Warnf("应用选项失败: %v", err)
 		return nil, err
 	}
+	return NewNodePubSubWithOptions(ctx, host, options)
+}
+
+// NewNodePubSubWithOptions 使用已构建的配置创建 NodePubSub 实例，按 PubSubMode 创建 GossipSub、FloodSub 或 RandomSub 路由器。
+// 配置对象被实例持有，之后可以通过 Options.Update 在运行时修改。
+// 参数:
+//   - ctx: 上下文，用于控制PubSub实例的生命周期
+//   - host: dep2p主机，代表当前节点
+//   - options: 节点配置，为 nil 时使用 DefaultOptions
+//
+// 返回:
+//   - *NodePubSub: 新创建的NodePubSub实例
+//   - error: 如果配置无效或创建过程中出现错误，返回相应的错误信息
+func NewNodePubSubWithOptions(ctx context.Context, host host.Host, options *Options) (*NodePubSub, error) {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
 
 	// 创建可取消的上下文，用于控制PubSub及其子组件的生命周期
 	ctx, cancel := context.WithCancel(ctx)
@@ -106,43 +126,28 @@ func (pubsub *NodePubSub) startPubSub(options *Options) error {
 			return err
 		}
 
-		// 所有模式通用的基础配置选项
-		baseOpts := []Option{
-			WithEventTracer(tracer),                                   // 启用事件追踪，用于调试和监控
-			WithMessageSigning(options.SignMessages),                  // 配置消息签名选项
-			WithStrictSignatureVerification(options.ValidateMessages), // 配置签名验证严格程度
-			WithMaxMessageSize(options.MaxMessageSize),                // 设置最大消息大小限制
+		// 节点配置中的签名策略、消息大小和 GossipSub 参数等与通过其他构造函数创建的实例使用相同的转换
+		pubsubOpts = []Option{
+			WithEventTracer(tracer), // 启用事件追踪，用于调试和监控
+			AsOption(options),       // 应用节点配置
 		}
 
-		// 根据不同的发布订阅模式添加特定的配置选项
-		switch options.GetPubSubMode() {
-		case GossipSub:
-			// GossipSub 特定的参数配置
-			params := DefaultGossipSubParams() // 设置 GossipSub 参数
-
-			params.D = int(math.Max(2, float64(options.D))) // 设置每个节点维护的对等点数量，最小为2
-
-			params.Dlo = int(math.Max(1, float64(options.Dlo))) // 设置对等点数量的最小阈值，最小为1
-
-			// 设置心跳间隔，不超过1秒
-			if options.HeartbeatInterval > time.Second {
-				params.HeartbeatInterval = time.Second
-			} else {
-				params.HeartbeatInterval = options.HeartbeatInterval
-			}
-
-			// 设置消息请求的跟进时间，不超过2秒
-			if options.FollowupTime > 2*time.Second {
-				params.IWantFollowupTime = 2 * time.Second
-			} else {
-				params.IWantFollowupTime = options.FollowupTime
+		if options.GetPubSubMode() == GossipSub {
+			// 评分阈值，未设置时使用适合小规模网络的默认阈值
+			thresholds := options.GetScoreThresholds()
+			if thresholds == nil {
+				thresholds = &PeerScoreThresholds{
+					GossipThreshold:             -1, // Gossip消息传播的阈值
+					PublishThreshold:            -2, // 发布消息的阈值
+					GraylistThreshold:           -3, // 灰名单阈值
+					OpportunisticGraftThreshold: 1,  // 机会性嫁接的阈值
+				}
 			}
 
 			// GossipSub 特定的选项
-			gossipOpts := []Option{
-				WithPeerExchange(true),      // 启用对等节点交换
-				WithGossipSubParams(params), // 设置 GossipSub 协议参数
-				WithFloodPublish(true),      // 启用洪泛式消息发布
+			pubsubOpts = append(pubsubOpts,
+				WithPeerExchange(true), // 启用对等节点交换
+				WithFloodPublish(true), // 启用洪泛式消息发布
 				// 配置节点评分系统，仅用于 GossipSub
 				WithPeerScore(
 					&PeerScoreParams{
@@ -151,35 +156,25 @@ func (pubsub *NodePubSub) startPubSub(options *Options) error {
 						DecayInterval:    time.Second,                        // 分数衰减间隔
 						DecayToZero:      0.01,                               // 衰减到0的速率
 					},
-					&PeerScoreThresholds{
-						GossipThreshold:             -1, // Gossip消息传播的阈值
-						PublishThreshold:            -2, // 发布消息的阈值
-						GraylistThreshold:           -3, // 灰名单阈值
-						OpportunisticGraftThreshold: 1,  // 机会性嫁接的阈值
-					},
+					thresholds,
 				),
-			}
-			pubsubOpts = append(baseOpts, gossipOpts...)
-
-		case FloodSub:
-			// FloodSub 使用基础配置即可
-			pubsubOpts = baseOpts
-
-		case RandomSub:
-			// RandomSub 特定的配置（如果有的话）
-			pubsubOpts = baseOpts
-			// 可以在这里添加 RandomSub 特定的选项
+			)
 		}
 	} else {
 		// 如果不加载详细配置，只使用基本的消息大小限制
 		pubsubOpts = []Option{
 			WithMaxMessageSize(options.MaxMessageSize),
 		}
-	}
 
-	// 添加 Discovery 配置
-	if discovery := options.GetNodeDiscovery(); discovery != nil {
-		pubsubOpts = append(pubsubOpts, WithDiscovery(discovery))
+		// 添加出站队列大小配置
+		if size := options.GetPeerOutboundQueueSize(); size > 0 {
+			pubsubOpts = append(pubsubOpts, WithPeerOutboundQueueSize(size))
+		}
+
+		// 添加 Discovery 配置
+		if discovery := options.GetNodeDiscovery(); discovery != nil {
+			pubsubOpts = append(pubsubOpts, WithDiscovery(discovery))
+		}
 	}
 
 	// 根据配置的 PubSubMode 创建相应的发布订阅实例
@@ -193,7 +188,10 @@ func (pubsub *NodePubSub) startPubSub(options *Options) error {
 			logger.Info("flood-sub 服务已启动")
 		}
 	case RandomSub:
-		return fmt.Errorf("暂不支持RandomSub")
+		ps, err = NewRandomSub(pubsub.ctx, pubsub.host, options.GetNetworkSize(), pubsubOpts...)
+		if err == nil {
+			logger.Info("random-sub 服务已启动")
+		}
 	case GossipSub:
 		fallthrough
 	default:
@@ -208,6 +206,8 @@ func (pubsub *NodePubSub) startPubSub(options *Options) error {
 	}
 
 	pubsub.pubsub = ps
+	pubsub.options = options
+	options.bind(ps) // 之后通过 Options.Update 的修改会传播到运行中的实例
 	atomic.StoreInt32(&pubsub.startUp, 2)
 	return nil
 }
@@ -503,6 +503,13 @@ func (pubsub *NodePubSub) Pubsub() *PubSub {
 	return pubsub.pubsub
 }
 
+// Options 返回节点配置，可以通过 Options.Update 在运行时修改
+// 返回:
+//   - *Options: 当前NodePubSub实例使用的配置
+func (pubsub *NodePubSub) Options() *Options {
+	return pubsub.options
+}
+
 // ListPeers 返回我们在给定主题中连接到的对等点列表
 // 参数:
 //   - topic: 主题名称
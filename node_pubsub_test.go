@@ -0,0 +1,47 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+)
+
+// TestNodePubSubMode 测试按 PubSubMode 创建对应的路由器
+func TestNodePubSubMode(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	for i, mode := range []PubSubType{GossipSub, FloodSub, RandomSub} {
+		opts := DefaultOptions()
+		if err := opts.ApplyOptions(WithSetPubSubMode(mode), WithSetNetworkSize(100)); err != nil {
+			t.Fatal(err)
+		}
+		node, err := NewNodePubSubWithOptions(ctx, hosts[i], opts)
+		if err != nil {
+			t.Fatalf("mode %d: %s", mode, err)
+		}
+		if node.Options() != opts {
+			t.Fatalf("mode %d: expected node to hold the given options", mode)
+		}
+
+		switch rt := node.Pubsub().rt.(type) {
+		case *GossipSubRouter:
+			if mode != GossipSub {
+				t.Fatalf("mode %d: unexpected gossipsub router", mode)
+			}
+		case *FloodSubRouter:
+			if mode != FloodSub {
+				t.Fatalf("mode %d: unexpected floodsub router", mode)
+			}
+		case *RandomSubRouter:
+			if mode != RandomSub {
+				t.Fatalf("mode %d: unexpected randomsub router", mode)
+			}
+			if rt.size != 100 {
+				t.Fatalf("expected randomsub network size 100, got %d", rt.size)
+			}
+		default:
+			t.Fatalf("mode %d: unexpected router %T", mode, rt)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// 作用：节点配置与 PubSub 选项之间的适配。
+// 功能：AsOption 将 NodeOption 体系的 Options 转换为 PubSub 的 Option，使 MaxMessageSize、签名策略、
+// GossipSub 参数等配置同样作用于通过 NewGossipSub、NewFloodSub、NewRandomSub 等构造函数直接创建的实例；
+// NodePubSub 在加载详细配置时也通过它转换配置，两条路径的行为保持一致。
+
+package pubsub
+
+import (
+	"math"
+)
+
+// AsOption 将节点配置转换为 PubSub 的选项。选项应用时先校验配置，然后应用:
+// 签名策略（StrictNoSign 时省略作者并按内容生成消息 ID）、最大消息大小、最大传输大小、出站队列大小和 Discovery 服务；
+// 路由器为 randomsub 且设置了网络节点数时按其计算扇出；
+// 路由器为 gossipsub 时还在当前参数的基础上应用网格度数、心跳间隔、跟随时间、Gossip 因子、待处理连接数、
+// 直连对等节点和混合路由阈值，其他 GossipSub 参数保持不变。
+// 评分阈值需要与评分参数一起通过 WithPeerScore 设置，不由该选项应用。
+// 参数:
+//   - o: 节点配置
+//
+// 返回值:
+//   - Option: PubSub 的选项
+func AsOption(o *Options) Option {
+	return func(ps *PubSub) error {
+		if err := o.Validate(); err != nil {
+			return err
+		}
+
+		opts := []Option{
+			WithMessageSignaturePolicy(o.GetSignaturePolicy()), // 配置消息签名策略
+			WithMaxMessageSize(o.GetMaxMessageSize()),          // 设置最大消息大小限制
+		}
+		if o.GetSignaturePolicy() == StrictNoSign {
+			// 不签名时省略 from 和 seqno 字段以保持匿名，消息 ID 改为按内容生成
+			opts = append(opts, WithNoAuthor(), WithMessageIdFn(ContentMsgIdFn))
+		}
+		if size := o.GetMaxTransmissionSize(); size > 0 {
+			opts = append(opts, WithMaxTransmissionSize(size)) // 设置单个 RPC 帧的最大大小
+		}
+		if size := o.GetPeerOutboundQueueSize(); size > 0 {
+			opts = append(opts, WithPeerOutboundQueueSize(size))
+		}
+		if discovery := o.GetNodeDiscovery(); discovery != nil {
+			opts = append(opts, WithDiscovery(discovery))
+		}
+
+		if rs, ok := ps.rt.(*RandomSubRouter); ok {
+			if n := o.GetNetworkSize(); n > 0 {
+				rs.size = n // 按网络规模计算扇出
+			}
+		}
+		if gs, ok := ps.rt.(*GossipSubRouter); ok {
+			o.applyGossipSubParams(gs)
+			if peers := o.GetDirectPeers(); len(peers) > 0 {
+				opts = append(opts, WithDirectPeers(peers)) // 与直连对等节点保持连接并始终转发
+			}
+			if n := o.GetHybridThreshold(); n > 0 {
+				opts = append(opts, WithHybridThreshold(n)) // 小规模主题洪泛，大规模主题使用网格
+			}
+		}
+
+		for _, opt := range opts {
+			if err := opt(ps); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// applyGossipSubParams 在 gossipsub 路由器的当前参数上应用节点配置中的 GossipSub 参数，只能在路由器附加之前调用
+// 参数:
+//   - gs: gossipsub 路由器
+func (o *Options) applyGossipSubParams(gs *GossipSubRouter) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	gs.params.D = int(math.Max(2, float64(o.D)))     // 设置每个节点维护的对等点数量，最小为2
+	gs.params.Dlo = int(math.Max(1, float64(o.Dlo))) // 设置对等点数量的最小阈值，最小为1
+	gs.params.Dout = gs.outboundQuota()              // 出站配额必须小于 Dlo 且不超过 D/2，运行时修改按此校验
+	gs.params.HeartbeatInterval = o.HeartbeatInterval
+	gs.params.IWantFollowupTime = o.FollowupTime
+	gs.params.GossipFactor = o.GossipFactor
+	if o.MaxPendingConns > 0 {
+		gs.params.MaxPendingConnections = o.MaxPendingConns // 设置 PX 待处理连接数上限
+		gs.connect = make(chan connectInfo, o.MaxPendingConns)
+	}
+}
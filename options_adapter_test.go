@@ -0,0 +1,68 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestAsOption 测试节点配置通过 AsOption 作用于直接创建的实例
+func TestAsOption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := DefaultOptions()
+	err := opts.ApplyOptions(
+		WithSetMaxMessageSize(64<<10),
+		WithSetSignaturePolicy(StrictNoSign),
+		WithSetD(4),
+		WithSetDlo(3),
+		WithSetHeartbeatInterval(700*time.Millisecond),
+		WithSetFollowupTime(time.Second),
+		WithSetPeerOutboundQueueSize(48),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], AsOption(opts)),
+		getRandomsub(ctx, hosts[1], 10, AsOption(opts)),
+		getGossipsub(ctx, hosts[2], AsOption(opts)),
+	}
+	for i, ps := range psubs {
+		if ps.maxMessageSize != 64<<10 {
+			t.Fatalf("instance %d: expected max message size %d, got %d", i, 64<<10, ps.maxMessageSize)
+		}
+		if ps.signPolicy != StrictNoSign {
+			t.Fatalf("instance %d: expected StrictNoSign, got %d", i, ps.signPolicy)
+		}
+		if ps.peerOutboundQueueSize != 48 {
+			t.Fatalf("instance %d: expected outbound queue size 48, got %d", i, ps.peerOutboundQueueSize)
+		}
+	}
+
+	gs := psubs[2].rt.(*GossipSubRouter)
+	if gs.params.D != 4 || gs.params.Dlo != 3 || gs.params.HeartbeatInterval != 700*time.Millisecond {
+		t.Fatalf("unexpected gossipsub params: D=%d Dlo=%d heartbeat=%s", gs.params.D, gs.params.Dlo, gs.params.HeartbeatInterval)
+	}
+	if gs.params.Dhi != GossipSubDhi {
+		t.Fatalf("expected Dhi to be unchanged, got %d", gs.params.Dhi)
+	}
+}
+
+// TestAsOptionInvalid 测试无效的节点配置在构造时被拒绝
+func TestAsOptionInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := DefaultOptions()
+	if err := opts.ApplyOptions(WithSetMaxMessageSize(0)); err != nil {
+		t.Fatal(err)
+	}
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewFloodSub(ctx, hosts[0], AsOption(opts)); err == nil {
+		t.Fatal("expected error for invalid options")
+	}
+}
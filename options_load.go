@@ -0,0 +1,374 @@
+// 作用：从配置文件和环境变量加载节点配置。
+// 功能：LoadOptionsFromFile 读取 JSON 或 TOML 配置文件，LoadOptionsFromEnv 读取带前缀的环境变量，
+// 两者都生成 NodeOption 列表并启用 LoadConfig，使文件或环境变量中的详细配置在启动时生效，便于以声明方式批量配置节点。
+// TOML 只支持顶层的 key = value，值可以是字符串、数字、布尔值或字符串数组。
+
+package pubsub
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// configKey 是配置文件和环境变量中的一个配置项
+type configKey struct {
+	name  string                               // 配置文件中的键名，环境变量名为前缀加上大写的键名
+	parse func(raw string) (NodeOption, error) // 将文本值解析为配置函数
+}
+
+// configKeys 是支持的配置项，按应用顺序排列：预设配置最先应用，其他配置项覆盖预设中的值
+var configKeys = []configKey{
+	{"profile", parseProfile},
+	{"pubsub_mode", parsePubSubMode},
+	{"load_config", func(raw string) (NodeOption, error) {
+		v, err := strconv.ParseBool(raw)
+		return WithSetLoadConfig(v), err
+	}},
+	{"signature_policy", parseSignaturePolicy},
+	{"d", intOption(WithSetD)},
+	{"dlo", intOption(WithSetDlo)},
+	{"gossip_factor", func(raw string) (NodeOption, error) {
+		v, err := strconv.ParseFloat(raw, 64)
+		return WithSetGossipFactor(v), err
+	}},
+	{"heartbeat_interval", durationOption(WithSetHeartbeatInterval)},
+	{"follow_up_time", durationOption(WithSetFollowupTime)},
+	{"max_pending_conns", intOption(WithSetMaxPendingConns)},
+	{"max_message_size", intOption(WithSetMaxMessageSize)},
+	{"max_transmission_size", intOption(WithSetMaxTransmissionSize)},
+	{"hybrid_threshold", intOption(WithSetHybridThreshold)},
+	{"peer_outbound_queue_size", intOption(WithSetPeerOutboundQueueSize)},
+	{"network_size", intOption(WithSetNetworkSize)},
+	{"direct_peers", parseDirectPeers},
+}
+
+// LoadOptionsFromFile 从配置文件加载节点配置，按扩展名识别 JSON（.json）或 TOML（.toml）格式。
+// 返回的配置列表以 WithSetLoadConfig(true) 开头，文件中可以用 load_config 覆盖；未知的配置项返回错误。
+// 参数:
+//   - path: 配置文件路径
+//
+// 返回值:
+//   - []NodeOption: 配置函数列表
+//   - error: 错误信息，如果有的话
+func LoadOptionsFromFile(path string) ([]NodeOption, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		logger.Warnf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+
+	var values map[string]string
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		values, err = parseJSONConfig(data)
+	case ".toml":
+		values, err = parseTOMLConfig(data)
+	default:
+		logger.Warnf("不支持的配置文件格式: %s", ext)
+		return nil, fmt.Errorf("不支持的配置文件格式: %s", ext)
+	}
+	if err != nil {
+		logger.Warnf("解析配置文件 %s 失败: %v", path, err)
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return configOptions(values)
+}
+
+// LoadOptionsFromEnv 从环境变量加载节点配置，环境变量名为前缀加上大写的配置项名，
+// 例如前缀为 PUBSUB_ 时 PUBSUB_GOSSIP_FACTOR 对应 gossip_factor；数组值以逗号分隔。
+// 返回的配置列表以 WithSetLoadConfig(true) 开头，可以用 load_config 覆盖。
+// 参数:
+//   - prefix: 环境变量名前缀
+//
+// 返回值:
+//   - []NodeOption: 配置函数列表，没有相关的环境变量时只包含 WithSetLoadConfig(true)
+//   - error: 错误信息，如果有的话
+func LoadOptionsFromEnv(prefix string) ([]NodeOption, error) {
+	values := make(map[string]string)
+	for _, key := range configKeys {
+		if raw, ok := os.LookupEnv(prefix + strings.ToUpper(key.name)); ok {
+			values[key.name] = raw
+		}
+	}
+	return configOptions(values)
+}
+
+// configOptions 将配置项的文本值按 configKeys 的顺序转换为配置函数
+// 参数:
+//   - values: 配置项名到文本值的映射
+//
+// 返回值:
+//   - []NodeOption: 配置函数列表
+//   - error: 存在未知或无效的配置项时返回错误
+func configOptions(values map[string]string) ([]NodeOption, error) {
+	known := make(map[string]bool, len(configKeys))
+	for _, key := range configKeys {
+		known[key.name] = true
+	}
+	for name := range values {
+		if !known[name] {
+			logger.Warnf("未知的配置项: %s", name)
+			return nil, fmt.Errorf("未知的配置项: %s", name)
+		}
+	}
+
+	opts := []NodeOption{WithSetLoadConfig(true)}
+	for _, key := range configKeys {
+		raw, ok := values[key.name]
+		if !ok {
+			continue
+		}
+		opt, err := key.parse(strings.TrimSpace(raw))
+		if err != nil {
+			logger.Warnf("无效的配置项 %s: %v", key.name, err)
+			return nil, fmt.Errorf("无效的配置项 %s: %w", key.name, err)
+		}
+		opts = append(opts, opt)
+	}
+	return opts, nil
+}
+
+// parseJSONConfig 将 JSON 对象解析为配置项的文本值，字符串数组以逗号连接
+// 参数:
+//   - data: 文件内容
+//
+// 返回值:
+//   - map[string]string: 配置项名到文本值的映射
+//   - error: 错误信息，如果有的话
+func parseJSONConfig(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+	for name, v := range raw {
+		v = bytes.TrimSpace(v)
+		switch {
+		case len(v) > 0 && v[0] == '"':
+			var s string
+			if err := json.Unmarshal(v, &s); err != nil {
+				return nil, fmt.Errorf("配置项 %s: %w", name, err)
+			}
+			values[name] = s
+		case len(v) > 0 && v[0] == '[':
+			var list []string
+			if err := json.Unmarshal(v, &list); err != nil {
+				return nil, fmt.Errorf("配置项 %s: %w", name, err)
+			}
+			values[name] = strings.Join(list, ",")
+		default:
+			values[name] = string(v)
+		}
+	}
+	return values, nil
+}
+
+// parseTOMLConfig 解析 TOML 的子集：顶层的 key = value，值为字符串、数字、布尔值或字符串数组，
+// 支持 # 注释，不支持表和多行值
+// 参数:
+//   - data: 文件内容
+//
+// 返回值:
+//   - map[string]string: 配置项名到文本值的映射
+//   - error: 错误信息，如果有的话
+func parseTOMLConfig(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripTOMLComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("第 %d 行: 不支持表", lineNo)
+		}
+
+		name, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("第 %d 行: 缺少 =", lineNo)
+		}
+		name = strings.TrimSpace(name)
+		raw = strings.TrimSpace(raw)
+		if _, dup := values[name]; dup {
+			return nil, fmt.Errorf("第 %d 行: 重复的配置项 %s", lineNo, name)
+		}
+
+		switch {
+		case strings.HasPrefix(raw, "\""):
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: 无效的字符串 %s", lineNo, raw)
+			}
+			values[name] = s
+		case strings.HasPrefix(raw, "["):
+			if !strings.HasSuffix(raw, "]") {
+				return nil, fmt.Errorf("第 %d 行: 不支持多行数组", lineNo)
+			}
+			var list []string
+			for _, item := range strings.Split(raw[1:len(raw)-1], ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					continue // 允许末尾的逗号
+				}
+				s, err := strconv.Unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("第 %d 行: 数组只支持字符串元素", lineNo)
+				}
+				list = append(list, s)
+			}
+			values[name] = strings.Join(list, ",")
+		default:
+			values[name] = strings.ReplaceAll(raw, "_", "") // TOML 数字允许下划线分隔
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// stripTOMLComment 移除字符串之外的 # 注释
+// 参数:
+//   - line: 一行文本
+//
+// 返回值:
+//   - string: 去掉注释后的文本
+func stripTOMLComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++ // 跳过转义字符
+			}
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// intOption 返回解析整数配置项的函数
+// 参数:
+//   - set: 配置函数构造器
+//
+// 返回值:
+//   - func(string) (NodeOption, error): 解析函数
+func intOption(set func(int) NodeOption) func(string) (NodeOption, error) {
+	return func(raw string) (NodeOption, error) {
+		v, err := strconv.Atoi(raw)
+		return set(v), err
+	}
+}
+
+// durationOption 返回解析时间配置项的函数，值的格式与 time.ParseDuration 相同，例如 "500ms"
+// 参数:
+//   - set: 配置函数构造器
+//
+// 返回值:
+//   - func(string) (NodeOption, error): 解析函数
+func durationOption(set func(time.Duration) NodeOption) func(string) (NodeOption, error) {
+	return func(raw string) (NodeOption, error) {
+		v, err := time.ParseDuration(raw)
+		return set(v), err
+	}
+}
+
+// parseProfile 解析预设配置名: lan、global 或 mobile
+// 参数:
+//   - raw: 文本值
+//
+// 返回值:
+//   - NodeOption: 预设配置
+//   - error: 错误信息，如果有的话
+func parseProfile(raw string) (NodeOption, error) {
+	switch strings.ToLower(raw) {
+	case "lan":
+		return ProfileLAN, nil
+	case "global":
+		return ProfileGlobal, nil
+	case "mobile":
+		return ProfileMobile, nil
+	default:
+		return nil, fmt.Errorf("未知的预设配置: %s", raw)
+	}
+}
+
+// parsePubSubMode 解析发布订阅模式: gossipsub、floodsub 或 randomsub
+// 参数:
+//   - raw: 文本值
+//
+// 返回值:
+//   - NodeOption: 配置函数
+//   - error: 错误信息，如果有的话
+func parsePubSubMode(raw string) (NodeOption, error) {
+	switch strings.ToLower(raw) {
+	case "gossipsub":
+		return WithSetPubSubMode(GossipSub), nil
+	case "floodsub":
+		return WithSetPubSubMode(FloodSub), nil
+	case "randomsub":
+		return WithSetPubSubMode(RandomSub), nil
+	default:
+		return nil, fmt.Errorf("未知的发布订阅模式: %s", raw)
+	}
+}
+
+// parseSignaturePolicy 解析消息签名策略: strict_sign、strict_no_sign、lax_sign 或 lax_no_sign
+// 参数:
+//   - raw: 文本值
+//
+// 返回值:
+//   - NodeOption: 配置函数
+//   - error: 错误信息，如果有的话
+func parseSignaturePolicy(raw string) (NodeOption, error) {
+	switch strings.ToLower(raw) {
+	case "strict_sign":
+		return WithSetSignaturePolicy(StrictSign), nil
+	case "strict_no_sign":
+		return WithSetSignaturePolicy(StrictNoSign), nil
+	case "lax_sign":
+		return WithSetSignaturePolicy(LaxSign), nil
+	case "lax_no_sign":
+		return WithSetSignaturePolicy(LaxNoSign), nil
+	default:
+		return nil, fmt.Errorf("未知的消息签名策略: %s", raw)
+	}
+}
+
+// parseDirectPeers 解析以逗号分隔的直连对等节点地址，每个地址必须包含 /p2p/ 部分
+// 参数:
+//   - raw: 文本值
+//
+// 返回值:
+//   - NodeOption: 配置函数
+//   - error: 错误信息，如果有的话
+func parseDirectPeers(raw string) (NodeOption, error) {
+	var peers []peer.AddrInfo
+	for _, s := range strings.Split(raw, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		info, err := peer.AddrInfoFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的对等节点地址 %s: %w", s, err)
+		}
+		peers = append(peers, *info)
+	}
+	return WithSetDirectPeers(peers), nil
+}
@@ -0,0 +1,115 @@
+package pubsub
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoadOptionsFromFile 测试从 JSON 和 TOML 配置文件加载节点配置
+func TestLoadOptionsFromFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"node.json": `{
+	"profile": "global",
+	"pubsub_mode": "gossipsub",
+	"d": 8,
+	"gossip_factor": 0.3,
+	"heartbeat_interval": "700ms",
+	"signature_policy": "strict_sign",
+	"direct_peers": []
+}`,
+		"node.toml": `# 节点配置
+profile = "global"
+pubsub_mode = "gossipsub" # 行尾注释
+d = 8
+gossip_factor = 0.3
+heartbeat_interval = "700ms"
+signature_policy = "strict_sign"
+direct_peers = []
+`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		loaded, err := LoadOptionsFromFile(path)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		opts := DefaultOptions()
+		if err := opts.ApplyOptions(WithSetLoadConfig(false)); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.ApplyOptions(loaded...); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if !opts.GetLoadConfig() {
+			t.Fatalf("%s: expected loaded options to enable LoadConfig", name)
+		}
+		// 配置项覆盖预设中的值
+		if opts.GetD() != 8 || opts.GetDlo() != GossipSubDlo {
+			t.Fatalf("%s: expected D 8 and Dlo %d, got %d and %d", name, GossipSubDlo, opts.GetD(), opts.GetDlo())
+		}
+		if opts.GetGossipFactor() != 0.3 || opts.GetHeartbeatInterval() != 700*time.Millisecond {
+			t.Fatalf("%s: unexpected gossip factor %v or heartbeat interval %s", name, opts.GetGossipFactor(), opts.GetHeartbeatInterval())
+		}
+		if opts.GetSignaturePolicy() != StrictSign {
+			t.Fatalf("%s: expected StrictSign, got %d", name, opts.GetSignaturePolicy())
+		}
+	}
+}
+
+// TestLoadOptionsFromFileErrors 测试无效的配置文件
+func TestLoadOptionsFromFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown.json":  `{"no_such_key": 1}`,
+		"invalid.json":  `{"d": "many"}`,
+		"table.toml":    "[gossipsub]\nd = 8\n",
+		"duration.toml": "heartbeat_interval = 700\n",
+		"node.yaml":     "d: 8\n",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadOptionsFromFile(path); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+// TestLoadOptionsFromEnv 测试从环境变量加载节点配置
+func TestLoadOptionsFromEnv(t *testing.T) {
+	t.Setenv("TESTPUBSUB_PUBSUB_MODE", "floodsub")
+	t.Setenv("TESTPUBSUB_MAX_MESSAGE_SIZE", "2048")
+	t.Setenv("TESTPUBSUB_LOAD_CONFIG", "false")
+
+	loaded, err := LoadOptionsFromEnv("TESTPUBSUB_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	if err := opts.ApplyOptions(loaded...); err != nil {
+		t.Fatal(err)
+	}
+	if opts.GetPubSubMode() != FloodSub || opts.GetMaxMessageSize() != 2048 {
+		t.Fatalf("unexpected mode %d or max message size %d", opts.GetPubSubMode(), opts.GetMaxMessageSize())
+	}
+	if opts.GetLoadConfig() {
+		t.Fatal("expected load_config to override the default")
+	}
+
+	t.Setenv("TESTPUBSUB_GOSSIP_FACTOR", "high")
+	if _, err := LoadOptionsFromEnv("TESTPUBSUB_"); err == nil {
+		t.Fatal("expected error for invalid value")
+	}
+}
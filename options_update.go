@@ -0,0 +1,299 @@
+// 作用：节点配置的运行时修改。
+// 功能：Options.Update 在校验后将心跳间隔、跟随时间、Gossip 因子、网格度数和出站队列大小等字段的修改
+// 传播到运行中的路由器，并通过配置修改回调通知应用，无需重启节点即可调优；不支持运行时修改的字段被拒绝。
+
+package pubsub
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ConfigChange 是配置在运行时被修改的事件
+type ConfigChange struct {
+	Fields []string  // 被修改的字段名
+	Time   time.Time // 修改生效的时间
+}
+
+// optionField 描述 Options 的一个字段
+type optionField struct {
+	name  string
+	get   func(*Options) interface{}
+	apply func(*PubSub, *Options) // 在事件循环中将修改应用到运行中的实例，为 nil 表示不支持运行时修改
+}
+
+// optionFields 是参与运行时修改比较的字段
+var optionFields = []optionField{
+	{name: "HeartbeatInterval", get: func(o *Options) interface{} { return o.HeartbeatInterval }, apply: func(p *PubSub, o *Options) {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.setHeartbeatInterval(o.HeartbeatInterval)
+		}
+	}},
+	{name: "FollowupTime", get: func(o *Options) interface{} { return o.FollowupTime }, apply: func(p *PubSub, o *Options) {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.params.IWantFollowupTime = o.FollowupTime
+			gs.gossipTracer.setFollowUpTime(o.FollowupTime)
+		}
+	}},
+	{name: "GossipFactor", get: func(o *Options) interface{} { return o.GossipFactor }, apply: func(p *PubSub, o *Options) {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.params.GossipFactor = o.GossipFactor
+		}
+	}},
+	{name: "D", get: func(o *Options) interface{} { return o.D }, apply: func(p *PubSub, o *Options) {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.params.D = int(math.Max(2, float64(o.D))) // 与创建时相同，最小为2
+		}
+	}},
+	{name: "Dlo", get: func(o *Options) interface{} { return o.Dlo }, apply: func(p *PubSub, o *Options) {
+		if gs, ok := p.rt.(*GossipSubRouter); ok {
+			gs.params.Dlo = int(math.Max(1, float64(o.Dlo))) // 与创建时相同，最小为1
+		}
+	}},
+	{name: "PeerOutboundQueueSize", get: func(o *Options) interface{} { return o.PeerOutboundQueueSize }, apply: func(p *PubSub, o *Options) {
+		if o.PeerOutboundQueueSize > 0 {
+			p.peerOutboundQueueSize = o.PeerOutboundQueueSize // 只影响之后建立的出站队列
+		}
+	}},
+	{name: "MaxPendingConns", get: func(o *Options) interface{} { return o.MaxPendingConns }},
+	{name: "MaxMessageSize", get: func(o *Options) interface{} { return o.MaxMessageSize }},
+	{name: "MaxTransmissionSize", get: func(o *Options) interface{} { return o.MaxTransmissionSize }},
+	{name: "SignaturePolicy", get: func(o *Options) interface{} { return o.SignaturePolicy }},
+	{name: "DirectPeers", get: func(o *Options) interface{} { return o.DirectPeers }},
+	{name: "NetworkSize", get: func(o *Options) interface{} { return o.NetworkSize }},
+	{name: "HybridThreshold", get: func(o *Options) interface{} { return o.HybridThreshold }},
+	{name: "LoadConfig", get: func(o *Options) interface{} { return o.LoadConfig }},
+	{name: "PubSubMode", get: func(o *Options) interface{} { return o.PubSubMode }},
+	{name: "ScoreThresholds", get: func(o *Options) interface{} { return o.ScoreThresholds }},
+	{name: "Discovery", get: func(o *Options) interface{} { return o.discovery }},
+}
+
+// WithConfigChangeHandler 设置配置在运行时被修改后的回调，回调在 Update 返回前调用
+// 参数:
+//   - fn: 回调函数
+//
+// 返回值:
+//   - NodeOption: 返回一个配置函数
+func WithConfigChangeHandler(fn func(ConfigChange)) NodeOption {
+	return func(o *Options) error {
+		if fn == nil {
+			logger.Warnf("配置修改回调不能为空")
+			return fmt.Errorf("配置修改回调不能为空")
+		}
+		o.onChange = fn
+		return nil
+	}
+}
+
+// Update 在运行时修改配置。修改先应用到副本并通过校验，再按运行中的路由器参数校验（Dlo <= D <= Dhi，
+// Dout 小于 Dlo 且不超过 D/2），然后传播到运行中的路由器，最后调用配置修改回调。任何校验失败都不做任何修改。
+// 支持运行时修改的字段: HeartbeatInterval、FollowupTime、GossipFactor、D、Dlo 和 PeerOutboundQueueSize，
+// 修改其他字段会返回错误且不做任何修改。
+// 参数:
+//   - opts: 配置函数
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (o *Options) Update(opts ...NodeOption) error {
+	o.updateMx.Lock()
+	defer o.updateMx.Unlock()
+
+	prev := o.clone()
+	next := o.clone()
+	if err := next.ApplyOptions(opts...); err != nil {
+		return err
+	}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+
+	var changed []optionField
+	var static []string
+	for _, f := range optionFields {
+		if reflect.DeepEqual(f.get(prev), f.get(next)) {
+			continue
+		}
+		if f.apply == nil {
+			static = append(static, f.name)
+			continue
+		}
+		changed = append(changed, f)
+	}
+	if len(static) > 0 {
+		logger.Warnf("不支持运行时修改的配置: %s", strings.Join(static, ", "))
+		return fmt.Errorf("不支持运行时修改的配置: %s", strings.Join(static, ", "))
+	}
+
+	o.mu.Lock()
+	live := o.live
+	o.mu.Unlock()
+
+	if live != nil && len(changed) > 0 {
+		if err := live.applyOptions(next, changed); err != nil {
+			return err
+		}
+	}
+
+	o.mu.Lock()
+	o.HeartbeatInterval = next.HeartbeatInterval
+	o.FollowupTime = next.FollowupTime
+	o.GossipFactor = next.GossipFactor
+	o.D = next.D
+	o.Dlo = next.Dlo
+	o.PeerOutboundQueueSize = next.PeerOutboundQueueSize
+	o.onChange = next.onChange
+	onChange := o.onChange
+	o.mu.Unlock()
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	fields := make([]string, len(changed))
+	for i, f := range changed {
+		fields[i] = f.name
+	}
+	logger.Infof("配置已在运行时修改: %s", strings.Join(fields, ", "))
+	if onChange != nil {
+		onChange(ConfigChange{Fields: fields, Time: time.Now()})
+	}
+	return nil
+}
+
+// clone 返回配置的副本，不包括运行中的实例
+// 返回值:
+//   - *Options: 配置副本
+func (o *Options) clone() *Options {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return &Options{
+		FollowupTime:          o.FollowupTime,
+		GossipFactor:          o.GossipFactor,
+		D:                     o.D,
+		Dlo:                   o.Dlo,
+		MaxPendingConns:       o.MaxPendingConns,
+		MaxMessageSize:        o.MaxMessageSize,
+		SignaturePolicy:       o.SignaturePolicy,
+		DirectPeers:           o.DirectPeers,
+		HeartbeatInterval:     o.HeartbeatInterval,
+		MaxTransmissionSize:   o.MaxTransmissionSize,
+		HybridThreshold:       o.HybridThreshold,
+		LoadConfig:            o.LoadConfig,
+		PubSubMode:            o.PubSubMode,
+		ScoreThresholds:       o.ScoreThresholds,
+		PeerOutboundQueueSize: o.PeerOutboundQueueSize,
+		NetworkSize:           o.NetworkSize,
+		discovery:             o.discovery,
+		onChange:              o.onChange,
+	}
+}
+
+// bind 关联运行中的 PubSub 实例，之后的运行时修改会传播到该实例
+// 参数:
+//   - ps: PubSub 实例
+func (o *Options) bind(ps *PubSub) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.live = ps
+}
+
+// applyOptions 在事件循环中校验并应用运行时修改的配置
+// 参数:
+//   - o: 修改后的配置
+//   - fields: 被修改的字段
+//
+// 返回值:
+//   - error: 校验失败或 PubSub 已关闭时返回错误
+func (p *PubSub) applyOptions(o *Options, fields []optionField) error {
+	done := make(chan error, 1)
+	select {
+	case p.eval <- func() {
+		if err := p.checkOptions(o, fields); err != nil {
+			done <- err
+			return
+		}
+		for _, f := range fields {
+			f.apply(p, o)
+		}
+		done <- nil
+	}:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// checkOptions 将被修改的字段合并到运行中的 gossipsub 路由器参数上并校验，只从 processLoop 调用
+// 参数:
+//   - o: 修改后的配置
+//   - fields: 被修改的字段
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) checkOptions(o *Options, fields []optionField) error {
+	gs, ok := p.rt.(*GossipSubRouter)
+	if !ok {
+		return nil
+	}
+
+	params := gs.params
+	for _, f := range fields {
+		switch f.name {
+		case "HeartbeatInterval":
+			if gs.heartbeatReset == nil {
+				logger.Warnf("gossipsub 路由器不支持在运行时修改心跳间隔")
+				return fmt.Errorf("gossipsub 路由器不支持在运行时修改心跳间隔")
+			}
+		case "D":
+			params.D = int(math.Max(2, float64(o.D)))
+		case "Dlo":
+			params.Dlo = int(math.Max(1, float64(o.Dlo)))
+		}
+	}
+
+	if params.Dlo > params.D || params.D > params.Dhi {
+		logger.Warnf("网格度数必须满足 Dlo <= D <= Dhi，当前为 Dlo=%d, D=%d, Dhi=%d", params.Dlo, params.D, params.Dhi)
+		return fmt.Errorf("网格度数必须满足 Dlo <= D <= Dhi，当前为 Dlo=%d, D=%d, Dhi=%d", params.Dlo, params.D, params.Dhi)
+	}
+	if params.Dout >= params.Dlo || params.Dout > params.D/2 {
+		logger.Warnf("出站配额 Dout 必须小于 Dlo 且不超过 D/2，当前为 Dout=%d, Dlo=%d, D=%d", params.Dout, params.Dlo, params.D)
+		return fmt.Errorf("出站配额 Dout 必须小于 Dlo 且不超过 D/2，当前为 Dout=%d, Dlo=%d, D=%d", params.Dout, params.Dlo, params.D)
+	}
+	return nil
+}
+
+// setHeartbeatInterval 在运行时修改心跳间隔，下一次心跳按新的间隔调度
+// 参数:
+//   - interval: 新的心跳间隔
+func (gs *GossipSubRouter) setHeartbeatInterval(interval time.Duration) {
+	gs.params.HeartbeatInterval = interval
+	select {
+	case <-gs.heartbeatReset: // 丢弃尚未处理的旧请求
+	default:
+	}
+	select {
+	case gs.heartbeatReset <- interval:
+	default:
+	}
+}
+
+// setFollowUpTime 在运行时修改 IWANT 跟进时间，只影响之后记录的承诺
+// 参数:
+//   - d: 新的跟进时间
+func (gt *gossipTracer) setFollowUpTime(d time.Duration) {
+	if gt == nil {
+		return
+	}
+	gt.Lock()
+	defer gt.Unlock()
+	gt.followUpTime = d
+}
@@ -0,0 +1,126 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOptionsUpdate 测试运行时修改配置并传播到运行中的路由器
+func TestOptionsUpdate(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var changes []ConfigChange
+	hosts := getDefaultHosts(t, 1)
+	node, err := NewNodePubSub(ctx, hosts[0], WithConfigChangeHandler(func(c ConfigChange) {
+		changes = append(changes, c)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := node.Options()
+
+	err = opts.Update(WithSetHeartbeatInterval(200*time.Millisecond), WithSetGossipFactor(0.5), WithSetPeerOutboundQueueSize(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || len(changes[0].Fields) != 3 {
+		t.Fatalf("expected one change event with 3 fields, got %v", changes)
+	}
+	if opts.GetHeartbeatInterval() != 200*time.Millisecond || opts.GetGossipFactor() != 0.5 {
+		t.Fatal("expected options to be updated")
+	}
+
+	gs := node.Pubsub().rt.(*GossipSubRouter)
+	res := make(chan struct{})
+	node.Pubsub().eval <- func() {
+		defer close(res)
+		if gs.params.HeartbeatInterval != 200*time.Millisecond {
+			t.Errorf("expected heartbeat interval 200ms, got %s", gs.params.HeartbeatInterval)
+		}
+		if gs.params.GossipFactor != 0.5 {
+			t.Errorf("expected gossip factor 0.5, got %v", gs.params.GossipFactor)
+		}
+		if node.Pubsub().peerOutboundQueueSize != 64 {
+			t.Errorf("expected outbound queue size 64, got %d", node.Pubsub().peerOutboundQueueSize)
+		}
+	}
+	<-res
+
+	// 未修改任何字段时不发出事件
+	if err := opts.Update(WithSetGossipFactor(0.5)); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Fatalf("expected no change event, got %v", changes)
+	}
+
+	// 不支持运行时修改的字段和无效的配置被拒绝
+	if err := opts.Update(WithSetMaxMessageSize(1 << 10)); err == nil {
+		t.Fatal("expected error for static field")
+	}
+	if err := opts.Update(WithSetDlo(opts.GetD() + 1)); err == nil {
+		t.Fatal("expected error for invalid options")
+	}
+	if opts.GetMaxMessageSize() == 1<<10 || opts.GetDlo() > opts.GetD() {
+		t.Fatal("expected rejected updates to leave options unchanged")
+	}
+}
+
+// TestOptionsUpdateRouterParams 测试运行时修改按路由器的合并参数校验，校验失败时不修改配置和路由器
+func TestOptionsUpdateRouterParams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	node, err := NewNodePubSub(ctx, hosts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := node.Options()
+	ps := node.Pubsub()
+	gs := ps.rt.(*GossipSubRouter)
+
+	params := func() GossipSubParams {
+		res := make(chan GossipSubParams, 1)
+		ps.eval <- func() { res <- gs.params }
+		return <-res
+	}
+
+	if err := opts.Update(WithSetD(6), WithSetDlo(5)); err != nil {
+		t.Fatal(err)
+	}
+	if p := params(); p.D != 6 || p.Dlo != 5 || p.Dout >= p.Dlo || p.Dout > p.D/2 {
+		t.Fatalf("unexpected gossipsub params: D=%d Dlo=%d Dout=%d", p.D, p.Dlo, p.Dout)
+	}
+
+	// Dout 必须小于 Dlo
+	ps.eval <- func() { gs.params.Dout = 2 }
+	if err := opts.Update(WithSetDlo(2)); err == nil {
+		t.Fatal("expected error for Dlo not above Dout")
+	}
+	if opts.GetDlo() != 5 || params().Dlo != 5 {
+		t.Fatal("expected the rejected update to leave options and router unchanged")
+	}
+
+	// 没有心跳重置通道的路由器不支持修改心跳间隔
+	ps.eval <- func() { gs.heartbeatReset = nil }
+	if err := opts.Update(WithSetHeartbeatInterval(400 * time.Millisecond)); err == nil {
+		t.Fatal("expected error without a heartbeat reset channel")
+	}
+	if opts.GetHeartbeatInterval() == 400*time.Millisecond {
+		t.Fatal("expected the rejected update to leave options unchanged")
+	}
+}
+
+// TestOptionsUpdateUnbound 测试未关联运行实例时只修改配置
+func TestOptionsUpdateUnbound(t *testing.T) {
+	opts := DefaultOptions()
+	if err := opts.Update(WithSetD(3)); err != nil {
+		t.Fatal(err)
+	}
+	if opts.GetD() != 3 {
+		t.Fatalf("expected D 3, got %d", opts.GetD())
+	}
+}
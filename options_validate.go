@@ -0,0 +1,145 @@
+// 作用：节点配置选项的校验。
+// 功能：在构造 NodePubSub 时检查 Options 各字段的取值范围以及字段之间的一致性
+// （D 与 Dlo、心跳间隔与跟随时间及其上限、最大消息大小与最大传输大小、Gossip 因子的范围等），
+// 以结构化错误的形式返回所有问题，避免在调整 DefaultOptions 后以不合理的配置静默运行。
+
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// maxNodeHeartbeatInterval 是节点配置允许的最大心跳间隔，更长的间隔会使网格修复和 gossip 过慢
+	maxNodeHeartbeatInterval = time.Second
+	// maxNodeFollowupTime 是节点配置允许的最大 IWANT 跟进时间
+	maxNodeFollowupTime = 2 * time.Second
+)
+
+// OptionError 描述一个配置字段的问题
+type OptionError struct {
+	Field  string // 出问题的字段名
+	Reason string // 问题描述
+}
+
+// Error 实现 error 接口
+// 返回值:
+//   - string: 错误信息
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("无效的配置 %s: %s", e.Field, e.Reason)
+}
+
+// OptionErrors 是 Options.Validate 发现的所有配置问题
+type OptionErrors []*OptionError
+
+// Error 实现 error 接口
+// 返回值:
+//   - string: 以分号分隔的所有错误信息
+func (errs OptionErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Fields 返回出问题的字段名
+// 返回值:
+//   - []string: 字段名列表，按发现顺序排列
+func (errs OptionErrors) Fields() []string {
+	fields := make([]string, len(errs))
+	for i, err := range errs {
+		fields[i] = err.Field
+	}
+	return fields
+}
+
+// Validate 检查配置选项的取值范围和字段之间的一致性。
+// 仅对 GossipSub 模式检查网格度数、Gossip 因子和跟随时间等 GossipSub 专用字段。
+// 返回值:
+//   - error: 没有问题时为 nil，否则为 OptionErrors
+func (o *Options) Validate() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var errs OptionErrors
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, &OptionError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	switch o.PubSubMode {
+	case GossipSub, FloodSub, RandomSub:
+	default:
+		add("PubSubMode", "未知的发布订阅模式 %d", o.PubSubMode)
+	}
+	switch o.SignaturePolicy {
+	case StrictSign, StrictNoSign, LaxSign, LaxNoSign:
+	default:
+		add("SignaturePolicy", "未知的消息签名策略 %d", o.SignaturePolicy)
+	}
+
+	if o.MaxMessageSize <= 0 {
+		add("MaxMessageSize", "必须为正数，当前为 %d", o.MaxMessageSize)
+	}
+	if o.MaxTransmissionSize < 0 {
+		add("MaxTransmissionSize", "不能为负数，当前为 %d", o.MaxTransmissionSize)
+	} else if o.MaxTransmissionSize > 0 && o.MaxMessageSize > 0 && o.MaxTransmissionSize < o.MaxMessageSize {
+		add("MaxTransmissionSize", "不能小于 MaxMessageSize (%d)，当前为 %d", o.MaxMessageSize, o.MaxTransmissionSize)
+	}
+	if o.MaxPendingConns < 0 {
+		add("MaxPendingConns", "不能为负数，当前为 %d", o.MaxPendingConns)
+	}
+	if o.PeerOutboundQueueSize < 0 {
+		add("PeerOutboundQueueSize", "不能为负数，当前为 %d", o.PeerOutboundQueueSize)
+	}
+	if o.NetworkSize < 0 {
+		add("NetworkSize", "不能为负数，当前为 %d", o.NetworkSize)
+	}
+	if o.HybridThreshold < 0 {
+		add("HybridThreshold", "不能为负数，当前为 %d", o.HybridThreshold)
+	}
+
+	if o.PubSubMode == GossipSub {
+		if o.D <= 0 {
+			add("D", "必须为正数，当前为 %d", o.D)
+		} else if o.D > GossipSubDhi {
+			add("D", "不能大于 Dhi (%d)，当前为 %d", GossipSubDhi, o.D)
+		}
+		if o.Dlo <= 0 {
+			add("Dlo", "必须为正数，当前为 %d", o.Dlo)
+		} else if o.D > 0 && o.Dlo > o.D {
+			add("Dlo", "不能大于 D (%d)，当前为 %d", o.D, o.Dlo)
+		}
+		if o.GossipFactor <= 0 || o.GossipFactor > 1 {
+			add("GossipFactor", "必须在 (0, 1] 范围内，当前为 %v", o.GossipFactor)
+		}
+		if o.HeartbeatInterval <= 0 {
+			add("HeartbeatInterval", "必须为正数，当前为 %s", o.HeartbeatInterval)
+		} else if o.HeartbeatInterval > maxNodeHeartbeatInterval {
+			add("HeartbeatInterval", "不能大于 %s，当前为 %s", maxNodeHeartbeatInterval, o.HeartbeatInterval)
+		}
+		if o.FollowupTime <= 0 {
+			add("FollowupTime", "必须为正数，当前为 %s", o.FollowupTime)
+		} else if o.FollowupTime > maxNodeFollowupTime {
+			add("FollowupTime", "不能大于 %s，当前为 %s", maxNodeFollowupTime, o.FollowupTime)
+		} else if o.HeartbeatInterval > 0 && o.FollowupTime < o.HeartbeatInterval {
+			// 未兑现的承诺在心跳中检查，短于心跳间隔的跟随时间没有意义
+			add("FollowupTime", "不能小于 HeartbeatInterval (%s)，当前为 %s", o.HeartbeatInterval, o.FollowupTime)
+		}
+		if o.ScoreThresholds != nil {
+			if err := o.ScoreThresholds.validate(); err != nil {
+				add("ScoreThresholds", "%s", err)
+			}
+		}
+	} else if o.HybridThreshold > 0 {
+		add("HybridThreshold", "仅用于 GossipSub 模式")
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	logger.Warnf("配置选项校验失败: %v", errs)
+	return errs
+}
@@ -0,0 +1,70 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestOptionsValidate 测试配置选项的校验
+func TestOptionsValidate(t *testing.T) {
+	if err := DefaultOptions().Validate(); err != nil {
+		t.Fatalf("expected default options to be valid, got %s", err)
+	}
+
+	tcs := []struct {
+		name   string
+		opts   []NodeOption
+		fields []string
+	}{
+		{"dlo above d", []NodeOption{WithSetD(2), WithSetDlo(3)}, []string{"Dlo"}},
+		{"d above dhi", []NodeOption{WithSetD(GossipSubDhi + 1)}, []string{"D"}},
+		{"followup below heartbeat", []NodeOption{WithSetHeartbeatInterval(time.Second), WithSetFollowupTime(100 * time.Millisecond)}, []string{"FollowupTime"}},
+		{"heartbeat above limit", []NodeOption{WithSetHeartbeatInterval(2 * time.Second), WithSetFollowupTime(2 * time.Second)}, []string{"HeartbeatInterval"}},
+		{"followup above limit", []NodeOption{WithSetFollowupTime(3 * time.Second)}, []string{"FollowupTime"}},
+		{"transmission below message size", []NodeOption{WithSetMaxMessageSize(1 << 20), WithSetMaxTransmissionSize(1 << 10)}, []string{"MaxTransmissionSize"}},
+		{"gossip factor out of range", []NodeOption{WithSetGossipFactor(1.5)}, []string{"GossipFactor"}},
+		{"hybrid outside gossipsub", []NodeOption{WithSetPubSubMode(FloodSub), WithSetHybridThreshold(4)}, []string{"HybridThreshold"}},
+		{"multiple", []NodeOption{WithSetGossipFactor(0), WithSetMaxMessageSize(0)}, []string{"MaxMessageSize", "GossipFactor"}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			if err := opts.ApplyOptions(tc.opts...); err != nil {
+				t.Fatal(err)
+			}
+			err := opts.Validate()
+			var errs OptionErrors
+			if !errors.As(err, &errs) {
+				t.Fatalf("expected OptionErrors, got %v", err)
+			}
+			if !reflect.DeepEqual(errs.Fields(), tc.fields) {
+				t.Fatalf("expected invalid fields %v, got %v", tc.fields, errs.Fields())
+			}
+		})
+	}
+
+	// FloodSub 模式不检查 GossipSub 专用字段
+	opts := DefaultOptions()
+	if err := opts.ApplyOptions(WithSetPubSubMode(FloodSub), WithSetD(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatalf("expected floodsub options to be valid, got %s", err)
+	}
+}
+
+// TestNodePubSubInvalidOptions 测试构造时拒绝无效的配置
+func TestNodePubSubInvalidOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	_, err := NewNodePubSub(ctx, hosts[0], WithSetD(1), WithSetDlo(2))
+	var errs OptionErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected OptionErrors, got %v", err)
+	}
+}
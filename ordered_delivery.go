@@ -0,0 +1,256 @@
+// 作用：订阅的有序投递。
+// 功能：在投递给应用之前按序列号缓冲并重排消息，可以按发布者在主题内逐条递增的序列号分别保证顺序，
+// 也可以把序列号视为发布时间戳在所有发布者之间排序。缺失的消息最多等待一个重排窗口，
+// 之后跳过缺口；晚于窗口到达、已经无法按序投递的消息被丢弃。
+
+package pubsub
+
+import (
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// DefaultReorderWindow 是有序投递等待缺失消息的默认时间
+const DefaultReorderWindow = 500 * time.Millisecond
+
+// DeliveryOrder 表示订阅的投递顺序
+type DeliveryOrder int
+
+const (
+	// OrderBySender 表示每个发布者的消息按其在主题内的序列号递增投递，不同发布者之间不保证顺序。
+	// 全局序列号由发布者的所有主题共享，在单个主题内不连续，因此使用发布时写入的主题序列号
+	OrderBySender DeliveryOrder = iota + 1
+	// OrderByTimestamp 表示把序列号视为发布时间戳（默认序列号以纳秒时间初始化），在所有发布者之间排序投递
+	OrderByTimestamp
+)
+
+// WithOrderedDelivery 是一个订阅选项，按给定顺序重排消息后再投递。
+// 匿名模式下的序列号是随机的且不写入主题序列号，不能用于排序；没有所需序列号的消息立即投递。
+// 参数:
+//   - order: 投递顺序
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func WithOrderedDelivery(order DeliveryOrder) SubOpt {
+	return func(sub *Subscription) error {
+		if order != OrderBySender && order != OrderByTimestamp {
+			logger.Warnf("未知的投递顺序 %d", order)
+			return fmt.Errorf("未知的投递顺序 %d", order)
+		}
+		sub.order = order
+		if sub.reorderWindow == 0 {
+			sub.reorderWindow = DefaultReorderWindow
+		}
+		return nil
+	}
+}
+
+// WithReorderWindow 是一个订阅选项，设置有序投递等待缺失消息的时间，需要与 WithOrderedDelivery 一起使用
+// 参数:
+//   - window: 重排窗口
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func WithReorderWindow(window time.Duration) SubOpt {
+	return func(sub *Subscription) error {
+		if window <= 0 {
+			logger.Warnf("重排窗口必须为正数")
+			return fmt.Errorf("重排窗口必须为正数")
+		}
+		sub.reorderWindow = window
+		return nil
+	}
+}
+
+// startReorder 在 PubSub 的投递通道和应用读取的通道之间插入重排协程
+func (sub *Subscription) startReorder() {
+	if sub.order == 0 {
+		return
+	}
+	sub.out = make(chan *Message, cap(sub.ch))
+	go sub.reorder(newReorderBuffer(sub.order, sub.reorderWindow))
+}
+
+// reorder 重排订阅收到的消息，订阅关闭后丢弃尚未投递的消息
+// 参数:
+//   - r: 重排缓冲区
+func (sub *Subscription) reorder(r *reorderBuffer) {
+	defer close(sub.out)
+
+	tick := r.window / 4
+	if tick < time.Millisecond {
+		tick = time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-sub.ch:
+			if !ok {
+				return
+			}
+			r.push(msg, time.Now())
+		case <-ticker.C:
+		}
+
+		for _, msg := range r.pop(time.Now()) {
+			if sub.enqueue(sub.out, msg) != nil {
+				logger.Infof("无法递送消息到主题 %s 的有序订阅者; 订阅者处理速度过慢", sub.topic)
+			}
+		}
+	}
+}
+
+// pendingMsg 是等待重排的消息
+type pendingMsg struct {
+	msg   *Message  // 消息
+	seqno uint64    // 序列号
+	at    time.Time // 收到的时间
+}
+
+// senderQueue 是一个发布者的待投递消息
+type senderQueue struct {
+	next    uint64                 // 下一个要投递的序列号
+	started bool                   // 是否已经开始投递
+	pending map[uint64]*pendingMsg // 等待投递的消息
+}
+
+// pendingHeap 是按序列号排序的最小堆
+type pendingHeap []*pendingMsg
+
+func (h pendingHeap) Len() int            { return len(h) }
+func (h pendingHeap) Less(i, j int) bool  { return h[i].seqno < h[j].seqno }
+func (h pendingHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pendingHeap) Push(x interface{}) { *h = append(*h, x.(*pendingMsg)) }
+func (h *pendingHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// reorderBuffer 按投递顺序缓冲消息，只由订阅的重排协程使用
+type reorderBuffer struct {
+	order  DeliveryOrder
+	window time.Duration
+
+	ready   []*Message               // 可以立即投递的消息
+	senders map[peer.ID]*senderQueue // 按发布者排序时每个发布者的队列
+	pending pendingHeap              // 按时间戳排序时等待投递的消息
+	last    uint64                   // 按时间戳排序时最后投递的序列号
+	started bool                     // 按时间戳排序时是否已经开始投递
+}
+
+// newReorderBuffer 创建重排缓冲区
+// 参数:
+//   - order: 投递顺序
+//   - window: 重排窗口
+//
+// 返回值:
+//   - *reorderBuffer: 重排缓冲区
+func newReorderBuffer(order DeliveryOrder, window time.Duration) *reorderBuffer {
+	return &reorderBuffer{
+		order:   order,
+		window:  window,
+		senders: make(map[peer.ID]*senderQueue),
+	}
+}
+
+// push 缓冲一条消息
+// 参数:
+//   - msg: 消息
+//   - now: 收到的时间
+func (r *reorderBuffer) push(msg *Message, now time.Time) {
+	if r.order == OrderByTimestamp {
+		if len(msg.GetSeqno()) != 8 {
+			r.ready = append(r.ready, msg) // 没有序列号的消息无法排序
+			return
+		}
+		pm := &pendingMsg{msg: msg, seqno: binary.BigEndian.Uint64(msg.GetSeqno()), at: now}
+		if r.started && pm.seqno < r.last {
+			logger.Debugf("丢弃晚于重排窗口到达的消息 %s", msg.ID)
+			return
+		}
+		heap.Push(&r.pending, pm)
+		return
+	}
+
+	if msg.GetTopicSeqno() == 0 {
+		r.ready = append(r.ready, msg) // 没有主题序列号的消息无法排序
+		return
+	}
+	pm := &pendingMsg{msg: msg, seqno: msg.GetTopicSeqno(), at: now}
+
+	from := msg.GetFrom()
+	q, ok := r.senders[from]
+	if !ok {
+		q = &senderQueue{pending: make(map[uint64]*pendingMsg)}
+		r.senders[from] = q
+	}
+	if q.started && pm.seqno < q.next {
+		logger.Debugf("丢弃来自 %s 的晚于重排窗口到达的消息 %s", from, msg.ID)
+		return
+	}
+	q.pending[pm.seqno] = pm
+}
+
+// pop 返回当前可以按序投递的消息
+// 参数:
+//   - now: 当前时间
+//
+// 返回值:
+//   - []*Message: 按投递顺序排列的消息
+func (r *reorderBuffer) pop(now time.Time) []*Message {
+	out := r.ready
+	r.ready = nil
+
+	if r.order == OrderByTimestamp {
+		// 序列号最小的消息等待满一个窗口后，不会再有更早的消息
+		for r.pending.Len() > 0 && now.Sub(r.pending[0].at) >= r.window {
+			pm := heap.Pop(&r.pending).(*pendingMsg)
+			r.last = pm.seqno
+			r.started = true
+			out = append(out, pm.msg)
+		}
+		return out
+	}
+
+	for _, q := range r.senders {
+		for {
+			if pm, ok := q.pending[q.next]; ok && q.started {
+				delete(q.pending, q.next)
+				q.next++
+				out = append(out, pm.msg)
+				continue
+			}
+			if len(q.pending) == 0 {
+				break
+			}
+
+			// 缺失的消息已经等待满一个窗口，跳过缺口
+			var first *pendingMsg
+			oldest := now
+			for _, pm := range q.pending {
+				if first == nil || pm.seqno < first.seqno {
+					first = pm
+				}
+				if pm.at.Before(oldest) {
+					oldest = pm.at
+				}
+			}
+			if now.Sub(oldest) < r.window {
+				break
+			}
+			q.next = first.seqno
+			q.started = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// orderedMsg 创建带有发送者、序列号和相同主题序列号的测试消息
+func orderedMsg(from string, seqno uint64) *Message {
+	sn := make([]byte, 8)
+	binary.BigEndian.PutUint64(sn, seqno)
+	return &Message{Message: &pb.Message{From: []byte(from), Seqno: sn, TopicSeqno: seqno}}
+}
+
+// checkOrder 检查投递的消息的发送者和序列号
+func checkOrder(t *testing.T, got []*Message, want ...*Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(got))
+	}
+	for i := range got {
+		if string(got[i].From) != string(want[i].From) || binary.BigEndian.Uint64(got[i].Seqno) != binary.BigEndian.Uint64(want[i].Seqno) {
+			t.Fatalf("unexpected message at %d", i)
+		}
+	}
+}
+
+// TestReorderBySender 测试按发布者重排消息、跳过超时的缺口并丢弃迟到的消息
+func TestReorderBySender(t *testing.T) {
+	window := time.Second
+	r := newReorderBuffer(OrderBySender, window)
+	now := time.Now()
+
+	a2, a1, b7 := orderedMsg("a", 2), orderedMsg("a", 1), orderedMsg("b", 7)
+	r.push(a2, now)
+	r.push(a1, now)
+	r.push(b7, now)
+	checkOrder(t, r.pop(now)) // 首条消息等待一个窗口，以便更早的消息到达
+
+	now = now.Add(window)
+	got := r.pop(now)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(got))
+	}
+	var fromA []*Message
+	for _, msg := range got {
+		if string(msg.From) == "a" {
+			fromA = append(fromA, msg)
+		}
+	}
+	checkOrder(t, fromA, a1, a2)
+
+	// 连续的消息立即投递
+	a3 := orderedMsg("a", 3)
+	r.push(a3, now)
+	checkOrder(t, r.pop(now), a3)
+
+	// 缺口等待一个窗口后跳过
+	a5 := orderedMsg("a", 5)
+	r.push(a5, now)
+	checkOrder(t, r.pop(now.Add(window/2)))
+	checkOrder(t, r.pop(now.Add(window)), a5)
+
+	// 迟到的消息被丢弃
+	r.push(orderedMsg("a", 4), now.Add(window))
+	checkOrder(t, r.pop(now.Add(2*window)))
+}
+
+// TestReorderByTimestamp 测试按序列号时间戳在发布者之间排序，以及没有序列号的消息立即投递
+func TestReorderByTimestamp(t *testing.T) {
+	window := time.Second
+	r := newReorderBuffer(OrderByTimestamp, window)
+	now := time.Now()
+
+	a30, b10, c20 := orderedMsg("a", 30), orderedMsg("b", 10), orderedMsg("c", 20)
+	r.push(a30, now)
+	r.push(b10, now.Add(window/2))
+	r.push(c20, now.Add(window/2))
+
+	unordered := &Message{Message: &pb.Message{From: []byte("d")}}
+	r.push(unordered, now)
+	if got := r.pop(now.Add(window / 2)); len(got) != 1 || got[0] != unordered {
+		t.Fatal("expected the message without seqno to be delivered immediately")
+	}
+
+	checkOrder(t, r.pop(now.Add(window)))
+	checkOrder(t, r.pop(now.Add(window+window/2)), b10, c20, a30)
+
+	r.push(orderedMsg("b", 15), now.Add(2*window))
+	checkOrder(t, r.pop(now.Add(4*window)))
+}
+
+// TestOrderedDeliveryAcrossTopics 测试发布者交替在多个主题上发布时，按发布者有序投递不会因全局序列号的缺口而等待
+func TestOrderedDeliveryAcrossTopics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	window := 2 * time.Second
+	sub, err := psubs[1].Subscribe("a", WithOrderedDelivery(OrderBySender), WithReorderWindow(window))
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	topics := getTopics(psubs[:1], "a")
+	other, err := psubs[0].Join("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 5
+	for i := 0; i < count; i++ {
+		if err := topics[0].Publish(ctx, []byte(fmt.Sprintf("msg-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.Publish(ctx, []byte("other")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 只有第一条消息等待一个窗口，之后的消息连续投递
+	tctx, tcancel := context.WithTimeout(ctx, window+time.Second)
+	defer tcancel()
+	for i := 0; i < count; i++ {
+		msg, err := sub.Next(tctx)
+		if err != nil {
+			t.Fatalf("waiting for message %d: %s", i, err)
+		}
+		if want := fmt.Sprintf("msg-%d", i); string(msg.Data) != want {
+			t.Fatalf("expected %s, got %s", want, msg.Data)
+		}
+	}
+}
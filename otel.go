@@ -0,0 +1,241 @@
+// 作用：OpenTelemetry 追踪上下文传播。
+// 功能：发布时将调用方的追踪上下文注入消息元信息，接收节点从中提取并为验证和投递创建 span，
+// 使节点 A 上的发布与节点 B 上的验证和投递出现在同一个分布式追踪中。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+	"github.com/dep2p/pubsub/pb"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// otelInstrumentation 是创建 span 时使用的追踪器名称
+const otelInstrumentation = "github.com/dep2p/pubsub"
+
+// maxTracedMessages 是等待验证结果的消息 span 数上限，超过后结束最早的 span 以限制内存
+const maxTracedMessages = 4096
+
+// WithOTelPropagation 是一个选项，用于在消息元信息中传播 OpenTelemetry 追踪上下文。
+// 发布时会创建 pubsub.publish span 并将其上下文注入消息；接收到的消息在验证期间处于 pubsub.receive span 中，
+// 该 span 的父级是发布者的 span，并在消息投递或被拒绝时结束。span 由全局 TracerProvider 创建。
+// 参数:
+//   - propagator: 文本映射传播器，例如 propagation.TraceContext{}
+//
+// 返回值:
+//   - Option: 配置选项
+func WithOTelPropagation(propagator propagation.TextMapPropagator) Option {
+	return func(p *PubSub) error {
+		if propagator == nil {
+			logger.Warnf("追踪上下文传播器不能为空")
+			return fmt.Errorf("追踪上下文传播器不能为空")
+		}
+		p.otel = &otelTracer{
+			propagator: propagator,
+			tracer:     otel.Tracer(otelInstrumentation),
+			spans:      make(map[string]otelSpan),
+		}
+		return withInternalRawTracer(p.otel)(p)
+	}
+}
+
+// ExtractTraceContext 返回携带消息中发布者追踪上下文的上下文，订阅者可以用它继续同一个追踪。
+// 未启用追踪上下文传播或消息不携带追踪上下文时原样返回 ctx。
+// 参数:
+//   - ctx: 上下文
+//   - msg: 消息
+//
+// 返回值:
+//   - context.Context: 携带追踪上下文的上下文
+func (p *PubSub) ExtractTraceContext(ctx context.Context, msg *Message) context.Context {
+	if p.otel == nil {
+		return ctx
+	}
+	return p.otel.extract(ctx, msg.Message)
+}
+
+// startPublishSpan 为发布创建 span，未启用追踪上下文传播时返回不记录的 span
+// 参数:
+//   - ctx: 发布调用方的上下文
+//   - topic: 主题
+//
+// 返回值:
+//   - context.Context: 携带发布 span 的上下文
+//   - trace.Span: 发布 span
+func (p *PubSub) startPublishSpan(ctx context.Context, topic string) (context.Context, trace.Span) {
+	if p.otel == nil {
+		return ctx, noop.Span{}
+	}
+	return p.otel.tracer.Start(ctx, "pubsub.publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(attribute.String("pubsub.topic", topic)))
+}
+
+// injectTraceContext 将上下文中的追踪上下文注入消息元信息
+// 参数:
+//   - ctx: 携带发布 span 的上下文
+//   - m: 要发布的消息
+func (p *PubSub) injectTraceContext(ctx context.Context, m *pb.Message) {
+	if p.otel == nil {
+		return
+	}
+	carrier := propagation.MapCarrier{}
+	p.otel.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return // 调用方没有有效的追踪上下文
+	}
+	if m.Metadata == nil {
+		m.Metadata = &pb.MessageMetadata{}
+	}
+	m.Metadata.TraceContext = carrier
+}
+
+// otelSpan 是接收消息的 span 及其开始时间
+type otelSpan struct {
+	span  trace.Span
+	start time.Time
+}
+
+// otelTracer 从接收的消息中提取追踪上下文，并在验证期间记录 span
+type otelTracer struct {
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+
+	mx    sync.Mutex
+	spans map[string]otelSpan // 处于验证中的消息 ID 及其 span
+}
+
+var _ RawTracer = (*otelTracer)(nil)
+
+// extract 从消息元信息中提取追踪上下文
+// 参数:
+//   - ctx: 上下文
+//   - m: 消息
+//
+// 返回值:
+//   - context.Context: 携带追踪上下文的上下文
+func (t *otelTracer) extract(ctx context.Context, m *pb.Message) context.Context {
+	tc := m.GetMetadata().GetTraceContext()
+	if len(tc) == 0 {
+		return ctx
+	}
+	return t.propagator.Extract(ctx, propagation.MapCarrier(tc))
+}
+
+// finish 结束消息的 span
+// 参数:
+//   - msg: 离开验证管道的消息
+//
+// 返回值:
+//   - trace.Span: 消息的 span，未追踪时为 nil
+func (t *otelTracer) finish(msg *Message) trace.Span {
+	t.mx.Lock()
+	s, ok := t.spans[msg.ID]
+	delete(t.spans, msg.ID)
+	t.mx.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return s.span
+}
+
+// ValidateMessage 实现 RawTracer 接口
+func (t *otelTracer) ValidateMessage(msg *Message) {
+	ctx := t.extract(context.Background(), msg.Message)
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return // 只追踪携带追踪上下文的消息
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("pubsub.topic", msg.GetTopic()),
+		attribute.String("pubsub.message_id", msg.ID),
+		attribute.String("pubsub.received_from", msg.ReceivedFrom.String()),
+	}
+	if from := msg.GetFrom(); from != "" {
+		attrs = append(attrs, attribute.String("pubsub.from", from.String()))
+	}
+	_, span := t.tracer.Start(ctx, "pubsub.receive",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(attrs...))
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if len(t.spans) >= maxTracedMessages {
+		var oldest string
+		var oldestAt time.Time
+		for id, s := range t.spans {
+			if oldest == "" || s.start.Before(oldestAt) {
+				oldest, oldestAt = id, s.start
+			}
+		}
+		t.spans[oldest].span.End()
+		delete(t.spans, oldest)
+	}
+	t.spans[msg.ID] = otelSpan{span: span, start: time.Now()}
+}
+
+// DeliverMessage 实现 RawTracer 接口
+func (t *otelTracer) DeliverMessage(msg *Message) {
+	if span := t.finish(msg); span != nil {
+		span.AddEvent("delivered")
+		span.End()
+	}
+}
+
+// RejectMessage 实现 RawTracer 接口
+func (t *otelTracer) RejectMessage(msg *Message, reason string) {
+	if span := t.finish(msg); span != nil {
+		span.SetAttributes(attribute.String("pubsub.reject_reason", reason))
+		span.SetStatus(codes.Error, reason)
+		span.End()
+	}
+}
+
+// AddPeer 实现 RawTracer 接口
+func (t *otelTracer) AddPeer(p peer.ID, proto protocol.ID) {}
+
+// RemovePeer 实现 RawTracer 接口
+func (t *otelTracer) RemovePeer(p peer.ID) {}
+
+// Join 实现 RawTracer 接口
+func (t *otelTracer) Join(topic string) {}
+
+// Leave 实现 RawTracer 接口
+func (t *otelTracer) Leave(topic string) {}
+
+// Graft 实现 RawTracer 接口
+func (t *otelTracer) Graft(p peer.ID, topic string) {}
+
+// Prune 实现 RawTracer 接口
+func (t *otelTracer) Prune(p peer.ID, topic string) {}
+
+// DuplicateMessage 实现 RawTracer 接口
+func (t *otelTracer) DuplicateMessage(msg *Message) {}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (t *otelTracer) ThrottlePeer(p peer.ID) {}
+
+// RecvRPC 实现 RawTracer 接口
+func (t *otelTracer) RecvRPC(rpc *RPC) {}
+
+// SendRPC 实现 RawTracer 接口
+func (t *otelTracer) SendRPC(rpc *RPC, p peer.ID) {}
+
+// DropRPC 实现 RawTracer 接口
+func (t *otelTracer) DropRPC(rpc *RPC, p peer.ID) {}
+
+// UndeliverableMessage 实现 RawTracer 接口
+func (t *otelTracer) UndeliverableMessage(msg *Message) {}
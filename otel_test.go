@@ -0,0 +1,150 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TestOTelPropagation 测试发布和接收的 span 出现在同一个分布式追踪中
+func TestOTelPropagation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts, WithOTelPropagation(propagation.TraceContext{}))
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("traced")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	pctx, parent := otel.Tracer("test").Start(ctx, "request")
+	if err := topics[0].Publish(pctx, []byte("traced")); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	msg, err := subs[1].Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote := trace.SpanContextFromContext(psubs[1].ExtractTraceContext(ctx, msg))
+	if remote.TraceID() != parent.SpanContext().TraceID() {
+		t.Fatalf("expected the publisher's trace, got %s", remote.TraceID())
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	publish, receive := spans["pubsub.publish"], spans["pubsub.receive"]
+	if publish == nil || receive == nil {
+		t.Fatalf("expected publish and receive spans, got %v", spans)
+	}
+	if publish.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Fatal("expected the publish span to be a child of the caller's span")
+	}
+	if receive.Parent().SpanID() != publish.SpanContext().SpanID() || receive.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Fatal("expected the receive span to be a child of the remote publish span")
+	}
+	if remote.SpanID() != publish.SpanContext().SpanID() {
+		t.Fatal("expected the extracted context to point to the publish span")
+	}
+	if len(receive.Events()) != 1 || receive.Events()[0].Name != "delivered" {
+		t.Fatalf("expected a delivered event, got %v", receive.Events())
+	}
+}
+
+// TestTraceContextMarshalDeterministic 测试多个追踪上下文键按确定的顺序序列化
+func TestTraceContextMarshalDeterministic(t *testing.T) {
+	md := &pb.MessageMetadata{TraceContext: map[string]string{
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"tracestate":  "vendor=value",
+		"baggage":     "user=alice",
+		"x-extra":     "1",
+	}}
+	first, err := md.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		buf, err := md.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf, first) {
+			t.Fatal("expected trace context to marshal deterministically")
+		}
+	}
+}
+
+// TestOTelPropagationMultipleCarrierKeys 测试携带多个追踪上下文键的签名消息能通过签名验证
+func TestOTelPropagationMultipleCarrierKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	hosts := getDefaultHosts(t, 2)
+	prop := propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+	psubs := getGossipsubs(ctx, hosts, WithOTelPropagation(prop))
+
+	topics := getTopics(psubs, "traced")
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	bag, err := baggage.Parse("user=alice,tenant=acme,region=eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pctx, parent := otel.Tracer("test").Start(baggage.ContextWithBaggage(ctx, bag), "request")
+	defer parent.End()
+
+	for i := 0; i < 20; i++ {
+		data := []byte(fmt.Sprintf("traced-%d", i))
+		if err := topics[0].Publish(pctx, data); err != nil {
+			t.Fatal(err)
+		}
+		assertReceive(t, sub, data)
+	}
+}
@@ -0,0 +1,114 @@
+// 作用：网格的出站连接配额。
+// 功能：将 Dout 限制在协议要求的范围内（小于 Dlo 且不超过 D/2）后在心跳中执行，
+// 提供查询每个主题网格中出站对等节点和配额的接口，并可选地在连接管理器中保护网格的出站连接，防御入站日蚀攻击。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// outboundProtectTag 是保护网格出站连接使用的连接管理器标签
+const outboundProtectTag = "pubsub:<outbound>"
+
+// WithOutboundMeshProtection 是一个 gossipsub 路由器选项，在连接管理器中保护任一主题网格中具有出站连接的对等节点，
+// 防止入站连接挤占时连接管理器修剪这些连接
+// 返回值:
+//   - Option: 配置选项
+func WithOutboundMeshProtection() Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 gossipsub")
+			return fmt.Errorf("pubsub 路由器不是 gossipsub")
+		}
+
+		gs.outboundProtected = make(map[peer.ID]struct{})
+		return nil
+	}
+}
+
+// outboundQuota 返回实际执行的出站配额：Dout 不能达到 Dlo，也不能超过 D/2
+// 返回值:
+//   - int: 出站配额
+func (gs *GossipSubRouter) outboundQuota() int {
+	quota := gs.params.Dout
+	if quota > gs.params.Dlo-1 {
+		quota = gs.params.Dlo - 1
+	}
+	if quota > gs.params.D/2 {
+		quota = gs.params.D / 2
+	}
+	if quota < 0 {
+		quota = 0
+	}
+	return quota
+}
+
+// protectOutboundMesh 更新连接管理器中受保护的网格出站对等节点，在每次心跳后调用
+func (gs *GossipSubRouter) protectOutboundMesh() {
+	if gs.outboundProtected == nil {
+		return
+	}
+	cmgr := gs.p.host.ConnManager()
+
+	current := make(map[peer.ID]struct{})
+	for _, peers := range gs.mesh {
+		for p := range peers {
+			if gs.outbound[p] {
+				current[p] = struct{}{}
+			}
+		}
+	}
+	for p := range current {
+		if _, ok := gs.outboundProtected[p]; !ok {
+			cmgr.Protect(p, outboundProtectTag)
+		}
+	}
+	for p := range gs.outboundProtected {
+		if _, ok := current[p]; !ok {
+			cmgr.Unprotect(p, outboundProtectTag)
+		}
+	}
+	gs.outboundProtected = current
+}
+
+// MeshOutbound 返回主题网格中具有出站连接的对等节点以及实际执行的出站配额。
+// 非 gossipsub 路由器返回 nil 和 0。
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - []peer.ID: 网格中的出站对等节点
+//   - int: 出站配额
+func (p *PubSub) MeshOutbound(topic string) ([]peer.ID, int) {
+	type result struct {
+		peers []peer.ID
+		quota int
+	}
+	out := make(chan result, 1)
+	get := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- result{}
+			return
+		}
+		var peers []peer.ID
+		for pid := range gs.mesh[topic] {
+			if gs.outbound[pid] {
+				peers = append(peers, pid)
+			}
+		}
+		out <- result{peers: peers, quota: gs.outboundQuota()}
+	}
+
+	select {
+	case p.eval <- get:
+		r := <-out
+		return r.peers, r.quota
+	case <-p.ctx.Done():
+		return nil, 0
+	}
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestOutboundQuota 测试出站配额被限制在 Dlo-1 和 D/2 之内
+func TestOutboundQuota(t *testing.T) {
+	for _, tc := range []struct {
+		d, dlo, dout, expected int
+	}{
+		{6, 5, 2, 2},
+		{6, 5, 4, 3},
+		{8, 3, 4, 2},
+		{2, 1, 2, 0},
+	} {
+		gs := &GossipSubRouter{params: GossipSubParams{D: tc.d, Dlo: tc.dlo, Dout: tc.dout}}
+		if q := gs.outboundQuota(); q != tc.expected {
+			t.Fatalf("D=%d Dlo=%d Dout=%d: expected quota %d, got %d", tc.d, tc.dlo, tc.dout, tc.expected, q)
+		}
+	}
+}
+
+// TestMeshOutbound 测试查询网格中的出站对等节点
+func TestMeshOutbound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts, WithOutboundMeshProtection())
+	connect(t, hosts[0], hosts[1]) // hosts[0] 到 hosts[1] 是出站连接
+	connect(t, hosts[2], hosts[0]) // hosts[2] 到 hosts[0] 是入站连接
+
+	for _, ps := range psubs {
+		if _, err := ps.Subscribe("foo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(2 * time.Second) // 等待心跳构建网格
+
+	peers, quota := psubs[0].MeshOutbound("foo")
+	if quota != GossipSubDout {
+		t.Fatalf("expected quota %d, got %d", GossipSubDout, quota)
+	}
+	if len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("expected only %s as an outbound mesh peer, got %v", hosts[1].ID(), peers)
+	}
+
+	protected := make(chan bool, 1)
+	psubs[0].eval <- func() {
+		_, ok := psubs[0].rt.(*GossipSubRouter).outboundProtected[hosts[1].ID()]
+		protected <- ok
+	}
+	if !<-protected {
+		t.Fatal("expected the outbound mesh peer to be protected")
+	}
+
+	fs := getPubsub(ctx, getDefaultHosts(t, 1)[0])
+	if peers, quota := fs.MeshOutbound("foo"); peers != nil || quota != 0 {
+		t.Fatal("expected no outbound mesh peers for floodsub")
+	}
+}
@@ -0,0 +1,107 @@
+// 作用：订阅缓冲区的溢出策略与滞后通知。
+// 功能：订阅者处理过慢、缓冲区已满时，按订阅选择的策略丢弃最新或最旧的消息，
+// 并记录丢弃的数量；设置了滞后处理函数时，在订阅者下一次读取消息时通知其丢弃的数量。
+
+package pubsub
+
+import "fmt"
+
+// OverflowPolicy 表示订阅缓冲区已满时的处理策略
+type OverflowPolicy int
+
+const (
+	// DropNewest 表示缓冲区已满时丢弃新到达的消息，这是默认策略
+	DropNewest OverflowPolicy = iota
+	// DropOldest 表示缓冲区已满时丢弃缓冲区中最旧的消息，为新消息腾出空间
+	DropOldest
+)
+
+// LagHandler 在订阅者读取消息时被调用，参数为自上次通知以来因缓冲区溢出丢弃的消息数
+type LagHandler func(dropped uint64)
+
+// WithOverflow 是一个订阅选项，设置缓冲区已满时的处理策略
+// 参数:
+//   - policy: 溢出策略
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func WithOverflow(policy OverflowPolicy) SubOpt {
+	return func(sub *Subscription) error {
+		if policy != DropNewest && policy != DropOldest {
+			logger.Warnf("未知的溢出策略 %d", policy)
+			return fmt.Errorf("未知的溢出策略 %d", policy)
+		}
+		sub.overflow = policy
+		return nil
+	}
+}
+
+// WithLagHandler 是一个订阅选项，设置滞后处理函数。
+// 处理函数在调用 Next 的协程中、返回下一条消息之前被调用，因此不会阻塞事件循环。
+// 参数:
+//   - fn: 滞后处理函数
+//
+// 返回值:
+//   - SubOpt: 订阅选项
+func WithLagHandler(fn LagHandler) SubOpt {
+	return func(sub *Subscription) error {
+		if fn == nil {
+			logger.Warnf("滞后处理函数不能为空")
+			return fmt.Errorf("滞后处理函数不能为空")
+		}
+		sub.lagHandler = fn
+		return nil
+	}
+}
+
+// Dropped 返回订阅因缓冲区溢出丢弃的消息总数
+// 返回值:
+//   - uint64: 丢弃的消息数
+func (sub *Subscription) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// enqueue 按订阅的溢出策略将消息放入通道，通道只能有这一个发送方
+// 参数:
+//   - ch: 订阅通道
+//   - msg: 消息
+//
+// 返回值:
+//   - *Message: 被丢弃的消息，没有丢弃时为 nil
+func (sub *Subscription) enqueue(ch chan *Message, msg *Message) *Message {
+	select {
+	case ch <- msg:
+		return nil
+	default:
+	}
+
+	shed := msg
+	if sub.overflow == DropOldest {
+		select {
+		case shed = <-ch: // 丢弃最旧的消息
+		default:
+			shed = nil // 订阅者刚刚读走了消息
+		}
+		select {
+		case ch <- msg:
+		default:
+			shed = msg
+		}
+	}
+
+	if shed != nil {
+		sub.dropped.Add(1)
+		sub.lagged.Add(1)
+	}
+	return shed
+}
+
+// notifyLag 如果有尚未通知的丢弃消息，则调用滞后处理函数
+func (sub *Subscription) notifyLag() {
+	if sub.lagHandler == nil {
+		return
+	}
+	if n := sub.lagged.Swap(0); n > 0 {
+		sub.lagHandler(n)
+	}
+}
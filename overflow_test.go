@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestSubscriptionOverflow 测试订阅缓冲区溢出时的丢弃策略和滞后通知
+func TestSubscriptionOverflow(t *testing.T) {
+	ctx := context.Background()
+	mkMsg := func(i int) *Message {
+		return &Message{Message: &pb.Message{Data: []byte(fmt.Sprintf("msg%d", i))}}
+	}
+
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		want   []string
+	}{
+		{DropNewest, []string{"msg0", "msg1"}},
+		{DropOldest, []string{"msg2", "msg3"}},
+	} {
+		var lag []uint64
+		sub := &Subscription{ch: make(chan *Message, 2)}
+		for _, opt := range []SubOpt{WithOverflow(tc.policy), WithLagHandler(func(n uint64) { lag = append(lag, n) })} {
+			if err := opt(sub); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for i := 0; i < 4; i++ {
+			shed := sub.enqueue(sub.ch, mkMsg(i))
+			if (i < 2) != (shed == nil) {
+				t.Fatalf("policy %d: unexpected shed message %v at %d", tc.policy, shed, i)
+			}
+		}
+		if n := sub.Dropped(); n != 2 {
+			t.Fatalf("policy %d: expected 2 dropped messages, got %d", tc.policy, n)
+		}
+
+		for _, want := range tc.want {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(msg.Data) != want {
+				t.Fatalf("policy %d: expected %q, got %q", tc.policy, want, msg.Data)
+			}
+		}
+		if len(lag) != 1 || lag[0] != 2 {
+			t.Fatalf("policy %d: expected a single lag notification of 2, got %v", tc.policy, lag)
+		}
+	}
+
+	if err := WithOverflow(OverflowPolicy(42))(&Subscription{}); err == nil {
+		t.Fatal("expected error for an unknown overflow policy")
+	}
+}
@@ -0,0 +1,210 @@
+// 作用：按主题名称模式订阅。
+// 功能：订阅与模式（例如 chat/*）匹配的所有主题，本节点从对等节点的订阅公告中发现匹配的新主题时自动加入并订阅，
+// 所有主题的消息合并到一个订阅中；自动加入的主题数受上限约束，避免被大量主题公告耗尽资源。
+// 取消模式订阅时关闭由模式订阅自动加入、且不再被其他订阅使用的主题。
+
+package pubsub
+
+import (
+	"fmt"
+	"path"
+	"sync"
+)
+
+// DefaultMaxPatternTopics 是模式订阅默认最多自动加入的主题数
+const DefaultMaxPatternTopics = 64
+
+// patternOptions 是模式订阅的选项
+type patternOptions struct {
+	maxTopics int // 最多自动加入的主题数
+}
+
+// PatternOpt 是模式订阅的选项
+type PatternOpt func(o *patternOptions) error
+
+// WithMaxPatternTopics 设置模式订阅最多自动加入的主题数，达到上限后忽略新发现的匹配主题
+// 参数:
+//   - n: 主题数上限
+//
+// 返回值:
+//   - PatternOpt: 模式订阅选项
+func WithMaxPatternTopics(n int) PatternOpt {
+	return func(o *patternOptions) error {
+		if n <= 0 {
+			logger.Warnf("模式订阅的主题数上限必须为正数")
+			return fmt.Errorf("模式订阅的主题数上限必须为正数")
+		}
+		o.maxTopics = n
+		return nil
+	}
+}
+
+// PatternSubscription 是按主题名称模式的订阅，由 PubSub.SubscribePattern 创建。
+// 消息所属的主题由 msg.GetTopic() 给出。
+type PatternSubscription struct {
+	*MergedSubscription
+
+	pattern   string              // 主题名称模式
+	maxTopics int                 // 最多自动加入的主题数
+	matched   map[string]struct{} // 已匹配的主题，只在事件循环中访问
+	once      sync.Once           // 确保只取消一次
+
+	joinMx    sync.Mutex        // 保护以下字段
+	joined    map[string]*Topic // 已订阅的主题句柄，取消时关闭其中由模式订阅加入的主题
+	cancelled bool              // 是否已经取消
+}
+
+// SubscribePattern 订阅名称与模式匹配的所有主题。
+// 模式语法与 path.Match 相同，* 不匹配 /，例如 chat/* 匹配 chat/room 但不匹配 chat/room/1。
+// 创建时订阅已知的匹配主题，之后从对等节点的订阅公告中发现匹配的新主题时自动加入。
+// 参数:
+//   - pattern: 主题名称模式
+//   - opts: 模式订阅选项
+//
+// 返回值:
+//   - *PatternSubscription: 模式订阅
+//   - error: 错误信息，如果有的话
+func (p *PubSub) SubscribePattern(pattern string, opts ...PatternOpt) (*PatternSubscription, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		logger.Warnf("无效的主题模式 %q: %s", pattern, err)
+		return nil, fmt.Errorf("无效的主题模式 %q: %w", pattern, err)
+	}
+
+	o := &patternOptions{maxTopics: DefaultMaxPatternTopics}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	ps := &PatternSubscription{
+		MergedSubscription: newMergedSubscription(p, mergedBufferSize),
+		pattern:            pattern,
+		maxTopics:          o.maxTopics,
+		matched:            make(map[string]struct{}),
+		joined:             make(map[string]*Topic),
+	}
+	ps.live.Add(1) // 模式订阅本身持有一个引用，没有匹配主题时共享通道也保持打开，取消时释放
+
+	select {
+	case p.eval <- func() {
+		p.patterns[ps] = struct{}{}
+		for topic := range p.myTopics {
+			ps.offer(topic)
+		}
+		for topic := range p.topics {
+			ps.offer(topic)
+		}
+	}:
+	case <-p.ctx.Done():
+		return nil, p.ctx.Err()
+	}
+
+	return ps, nil
+}
+
+// Pattern 返回订阅的主题名称模式
+// 返回值:
+//   - string: 主题名称模式
+func (ps *PatternSubscription) Pattern() string {
+	return ps.pattern
+}
+
+// Cancel 停止自动加入新主题，取消所有已匹配主题的订阅，并关闭由模式订阅加入的主题。
+// 仍被其他订阅或事件处理程序使用的主题保持打开，由最后一个取消的模式订阅关闭。
+func (ps *PatternSubscription) Cancel() {
+	ps.once.Do(func() {
+		select {
+		case ps.p.eval <- func() { delete(ps.p.patterns, ps) }:
+		case <-ps.p.ctx.Done():
+		}
+
+		ps.MergedSubscription.Cancel()
+
+		ps.joinMx.Lock()
+		ps.cancelled = true
+		joined := ps.joined
+		ps.joined = nil
+		ps.joinMx.Unlock()
+		for _, t := range joined {
+			ps.leave(t)
+		}
+
+		ps.unref(ErrSubscriptionCancelled)
+	})
+}
+
+// leave 关闭由模式订阅加入的主题，主题仍被使用时保持打开
+// 参数:
+//   - t: 主题
+func (ps *PatternSubscription) leave(t *Topic) {
+	if !t.patternJoined.Load() {
+		return
+	}
+	if err := t.Close(); err != nil {
+		logger.Debugf("模式订阅 %s 未关闭主题 %s: %s", ps.pattern, t.topic, err)
+	}
+}
+
+// offer 如果主题与模式匹配且尚未达到上限，则在后台加入并订阅主题。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+func (ps *PatternSubscription) offer(topic string) {
+	if _, ok := ps.matched[topic]; ok {
+		return
+	}
+	if ok, _ := path.Match(ps.pattern, topic); !ok {
+		return
+	}
+	if len(ps.matched) >= ps.maxTopics {
+		logger.Warnf("模式订阅 %s 的主题数达到上限 %d; 忽略主题 %s", ps.pattern, ps.maxTopics, topic)
+		return
+	}
+
+	ps.matched[topic] = struct{}{}
+	go ps.join(topic) // 加入主题需要事件循环处理，不能在事件循环中等待
+}
+
+// join 加入并订阅匹配的主题，失败时释放占用的名额并关闭刚加入的主题
+// 参数:
+//   - topic: 主题
+func (ps *PatternSubscription) join(topic string) {
+	t, created, err := ps.p.tryJoin(topic)
+	if err == nil {
+		if created {
+			t.patternJoined.Store(true)
+		}
+		err = ps.add(topic)
+	}
+	if t != nil {
+		ps.joinMx.Lock()
+		cancelled := ps.cancelled
+		if err == nil && !cancelled {
+			ps.joined[topic] = t
+		}
+		ps.joinMx.Unlock()
+		if err != nil || cancelled {
+			ps.leave(t)
+		}
+	}
+	if err == nil || err == ErrSubscriptionCancelled {
+		return
+	}
+
+	logger.Debugf("模式订阅 %s 无法订阅主题 %s: %s", ps.pattern, topic, err)
+	select {
+	case ps.p.eval <- func() { delete(ps.matched, topic) }:
+	case <-ps.p.ctx.Done():
+	}
+}
+
+// matchPatterns 把新发现的主题提供给所有模式订阅。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+func (p *PubSub) matchPatterns(topic string) {
+	for ps := range p.patterns {
+		ps.offer(topic)
+	}
+}
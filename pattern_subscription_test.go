@@ -0,0 +1,97 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestSubscribePattern 测试模式订阅自动加入对等节点公告的匹配主题，并遵守主题数上限
+func TestSubscribePattern(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	if _, err := psubs[1].SubscribePattern("chat/["); err == nil {
+		t.Fatal("expected error for an invalid pattern")
+	}
+
+	ps, err := psubs[1].SubscribePattern("chat/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	capped, err := psubs[1].SubscribePattern("chat/*", WithMaxPatternTopics(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var topics []*Topic
+	for _, name := range []string{"chat/a", "chat/b", "other", "chat/a/b"} {
+		topic, err := psubs[0].Join(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(2 * time.Second)
+
+	if n := len(ps.Topics()); n != 2 {
+		t.Fatalf("expected 2 matched topics, got %v", ps.Topics())
+	}
+	if n := len(capped.Topics()); n != 1 {
+		t.Fatalf("expected the capped subscription to match 1 topic, got %v", capped.Topics())
+	}
+
+	for _, topic := range topics {
+		if err := topic.Publish(ctx, []byte(topic.String())); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := make(map[string]bool)
+	for i := 0; i < 2; i++ {
+		msg, err := ps.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != msg.GetTopic() {
+			t.Fatalf("unexpected message %q for topic %s", msg.Data, msg.GetTopic())
+		}
+		got[msg.GetTopic()] = true
+	}
+	if !got["chat/a"] || !got["chat/b"] {
+		t.Fatalf("expected messages for chat/a and chat/b, got %v", got)
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer tcancel()
+	if msg, err := ps.Next(tctx); err == nil {
+		t.Fatalf("expected no message for non-matching topics, got %q", msg.Data)
+	}
+
+	ps.Cancel()
+	if _, err := ps.Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected ErrSubscriptionCancelled after cancel, got %v", err)
+	}
+
+	// 最后一个模式订阅取消后，自动加入的主题都被关闭
+	capped.Cancel()
+	joined := make(chan []string, 1)
+	psubs[1].eval <- func() {
+		var names []string
+		for name := range psubs[1].myTopics {
+			names = append(names, name)
+		}
+		joined <- names
+	}
+	if names := <-joined; len(names) != 0 {
+		t.Fatalf("expected the auto-joined topics to be closed, got %v", names)
+	}
+}
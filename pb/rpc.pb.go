@@ -5,7 +5,9 @@ package pb
 
 import (
 	fmt "fmt"
+	_ "github.com/gogo/protobuf/gogoproto"
 	proto "github.com/gogo/protobuf/proto"
+	github_com_gogo_protobuf_sortkeys "github.com/gogo/protobuf/sortkeys"
 	io "io"
 	math "math"
 	math_bits "math/bits"
@@ -28,16 +30,22 @@ type MessageMetadata_MessageType int32
 const (
 	MessageMetadata_REQUEST  MessageMetadata_MessageType = 0
 	MessageMetadata_RESPONSE MessageMetadata_MessageType = 1
+	MessageMetadata_RELIABLE MessageMetadata_MessageType = 2
+	MessageMetadata_ACK      MessageMetadata_MessageType = 3
 )
 
 var MessageMetadata_MessageType_name = map[int32]string{
 	0: "REQUEST",
 	1: "RESPONSE",
+	2: "RELIABLE",
+	3: "ACK",
 }
 
 var MessageMetadata_MessageType_value = map[string]int32{
 	"REQUEST":  0,
 	"RESPONSE": 1,
+	"RELIABLE": 2,
+	"ACK":      3,
 }
 
 func (x MessageMetadata_MessageType) String() string {
@@ -45,7 +53,7 @@ func (x MessageMetadata_MessageType) String() string {
 }
 
 func (MessageMetadata_MessageType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{3, 0}
+	return fileDescriptor_77a6da22d6a3feb1, []int{2, 0}
 }
 
 // RPC 消息，用于定义订阅选项和消息发布
@@ -55,10 +63,12 @@ type RPC struct {
 	// 要发布的消息列表
 	Publish []*Message `protobuf:"bytes,2,rep,name=publish,proto3" json:"publish,omitempty"`
 	// 用于控制消息
-	Control              *ControlMessage `protobuf:"bytes,3,opt,name=control,proto3" json:"control,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Control *ControlMessage `protobuf:"bytes,3,opt,name=control,proto3" json:"control,omitempty"`
+	// 处于 Dandelion 茎阶段的消息，只转发给一个对等节点
+	Stem                 []*Message `protobuf:"bytes,4,rep,name=stem,proto3" json:"stem,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}   `json:"-"`
+	XXX_unrecognized     []byte     `json:"-"`
+	XXX_sizecache        int32      `json:"-"`
 }
 
 func (m *RPC) Reset()         { *m = RPC{} }
@@ -71,16 +81,12 @@ func (m *RPC) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *RPC) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_RPC.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *RPC) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_RPC.Merge(m, src)
@@ -115,6 +121,13 @@ func (m *RPC) GetControl() *ControlMessage {
 	return nil
 }
 
+func (m *RPC) GetStem() []*Message {
+	if m != nil {
+		return m.Stem
+	}
+	return nil
+}
+
 // SubOpts 消息，用于定义订阅或取消订阅的选项
 type RPC_SubOpts struct {
 	// 表示是否订阅或取消订阅
@@ -136,16 +149,12 @@ func (m *RPC_SubOpts) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *RPC_SubOpts) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_RPC_SubOpts.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *RPC_SubOpts) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_RPC_SubOpts.Merge(m, src)
@@ -194,16 +203,12 @@ func (m *Target) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *Target) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Target.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *Target) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_Target.Merge(m, src)
@@ -231,6 +236,95 @@ func (m *Target) GetReceived() bool {
 	return false
 }
 
+// MessageMetadata 用于定义消息的元信息
+type MessageMetadata struct {
+	// 消息ID，用于标识和跟踪请求与响应之间的关系
+	MessageID string                      `protobuf:"bytes,1,opt,name=messageID,proto3" json:"messageID,omitempty"`
+	Type      MessageMetadata_MessageType `protobuf:"varint,2,opt,name=type,proto3,enum=pb.MessageMetadata_MessageType" json:"type,omitempty"`
+	// 幂等令牌，由发布者提供，用于下游实现恰好一次处理
+	IdempotencyToken string `protobuf:"bytes,3,opt,name=idempotencyToken,proto3" json:"idempotencyToken,omitempty"`
+	// 分布式追踪上下文，由发布者的 OpenTelemetry 传播器注入
+	TraceContext map[string]string `protobuf:"bytes,4,rep,name=traceContext,proto3" json:"traceContext,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// 请求的回复主题，响应者将响应发布到该主题
+	ReplyTopic string `protobuf:"bytes,5,opt,name=replyTopic,proto3" json:"replyTopic,omitempty"`
+	// 发布者写入的发布时间（Unix 纳秒），为 0 表示未设置
+	Timestamp            int64    `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *MessageMetadata) Reset()         { *m = MessageMetadata{} }
+func (m *MessageMetadata) String() string { return proto.CompactTextString(m) }
+func (*MessageMetadata) ProtoMessage()    {}
+func (*MessageMetadata) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{2}
+}
+func (m *MessageMetadata) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *MessageMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
+	}
+	return b[:n], nil
+}
+func (m *MessageMetadata) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MessageMetadata.Merge(m, src)
+}
+func (m *MessageMetadata) XXX_Size() int {
+	return m.Size()
+}
+func (m *MessageMetadata) XXX_DiscardUnknown() {
+	xxx_messageInfo_MessageMetadata.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MessageMetadata proto.InternalMessageInfo
+
+func (m *MessageMetadata) GetMessageID() string {
+	if m != nil {
+		return m.MessageID
+	}
+	return ""
+}
+
+func (m *MessageMetadata) GetType() MessageMetadata_MessageType {
+	if m != nil {
+		return m.Type
+	}
+	return MessageMetadata_REQUEST
+}
+
+func (m *MessageMetadata) GetIdempotencyToken() string {
+	if m != nil {
+		return m.IdempotencyToken
+	}
+	return ""
+}
+
+func (m *MessageMetadata) GetTraceContext() map[string]string {
+	if m != nil {
+		return m.TraceContext
+	}
+	return nil
+}
+
+func (m *MessageMetadata) GetReplyTopic() string {
+	if m != nil {
+		return m.ReplyTopic
+	}
+	return ""
+}
+
+func (m *MessageMetadata) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
 // Message 消息，用于定义消息的结构
 type Message struct {
 	// 表示消息的发送者
@@ -248,32 +342,40 @@ type Message struct {
 	// 表示用于验证签名的公钥
 	Key []byte `protobuf:"bytes,7,opt,name=key,proto3" json:"key,omitempty"`
 	// 表示系统内的消息元信息，用于跟踪和标识消息
-	Metadata             *MessageMetadata `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
-	XXX_unrecognized     []byte           `json:"-"`
-	XXX_sizecache        int32            `json:"-"`
+	Metadata *MessageMetadata `protobuf:"bytes,8,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	// 验证器显式标记为转发的注解，不参与签名
+	Annotations []*Annotation `protobuf:"bytes,9,rep,name=annotations,proto3" json:"annotations,omitempty"`
+	// 应用定义的键值头部（内容类型、模式版本、应用头部等），参与签名
+	Headers map[string]string `protobuf:"bytes,10,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// 消息已经经过的跳数，由接收节点逐跳递增，不参与签名
+	Hops uint32 `protobuf:"varint,11,opt,name=hops,proto3" json:"hops,omitempty"`
+	// 发布者设置的最大跳数，为 0 表示不限制，参与签名
+	HopLimit uint32 `protobuf:"varint,12,opt,name=hopLimit,proto3" json:"hopLimit,omitempty"`
+	// 发布者设置的过期时间（Unix 纳秒），为 0 表示不过期，参与签名
+	Expires int64 `protobuf:"varint,13,opt,name=expires,proto3" json:"expires,omitempty"`
+	// 发布者在该主题内逐条递增的序列号，用于按发布者有序投递，为 0 表示未设置，参与签名
+	TopicSeqno           uint64   `protobuf:"varint,14,opt,name=topicSeqno,proto3" json:"topicSeqno,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *Message) Reset()         { *m = Message{} }
 func (m *Message) String() string { return proto.CompactTextString(m) }
 func (*Message) ProtoMessage()    {}
 func (*Message) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{2}
+	return fileDescriptor_77a6da22d6a3feb1, []int{3}
 }
 func (m *Message) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *Message) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_Message.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *Message) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_Message.Merge(m, src)
@@ -343,61 +445,100 @@ func (m *Message) GetMetadata() *MessageMetadata {
 	return nil
 }
 
-// MessageMetadata 用于定义消息的元信息
-type MessageMetadata struct {
-	// 消息ID，用于标识和跟踪请求与响应之间的关系
-	MessageID            string                      `protobuf:"bytes,1,opt,name=messageID,proto3" json:"messageID,omitempty"`
-	Type                 MessageMetadata_MessageType `protobuf:"varint,2,opt,name=type,proto3,enum=pb.MessageMetadata_MessageType" json:"type,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                    `json:"-"`
-	XXX_unrecognized     []byte                      `json:"-"`
-	XXX_sizecache        int32                       `json:"-"`
+func (m *Message) GetAnnotations() []*Annotation {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
 }
 
-func (m *MessageMetadata) Reset()         { *m = MessageMetadata{} }
-func (m *MessageMetadata) String() string { return proto.CompactTextString(m) }
-func (*MessageMetadata) ProtoMessage()    {}
-func (*MessageMetadata) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{3}
+func (m *Message) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
 }
-func (m *MessageMetadata) XXX_Unmarshal(b []byte) error {
+
+func (m *Message) GetHops() uint32 {
+	if m != nil {
+		return m.Hops
+	}
+	return 0
+}
+
+func (m *Message) GetHopLimit() uint32 {
+	if m != nil {
+		return m.HopLimit
+	}
+	return 0
+}
+
+func (m *Message) GetExpires() int64 {
+	if m != nil {
+		return m.Expires
+	}
+	return 0
+}
+
+func (m *Message) GetTopicSeqno() uint64 {
+	if m != nil {
+		return m.TopicSeqno
+	}
+	return 0
+}
+
+// Annotation 消息，表示验证器附加到消息上的注解
+type Annotation struct {
+	// 注解的键
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// 注解的值
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Annotation) Reset()         { *m = Annotation{} }
+func (m *Annotation) String() string { return proto.CompactTextString(m) }
+func (*Annotation) ProtoMessage()    {}
+func (*Annotation) Descriptor() ([]byte, []int) {
+	return fileDescriptor_77a6da22d6a3feb1, []int{4}
+}
+func (m *Annotation) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
-func (m *MessageMetadata) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_MessageMetadata.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+func (m *Annotation) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
-func (m *MessageMetadata) XXX_Merge(src proto.Message) {
-	xxx_messageInfo_MessageMetadata.Merge(m, src)
+func (m *Annotation) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Annotation.Merge(m, src)
 }
-func (m *MessageMetadata) XXX_Size() int {
+func (m *Annotation) XXX_Size() int {
 	return m.Size()
 }
-func (m *MessageMetadata) XXX_DiscardUnknown() {
-	xxx_messageInfo_MessageMetadata.DiscardUnknown(m)
+func (m *Annotation) XXX_DiscardUnknown() {
+	xxx_messageInfo_Annotation.DiscardUnknown(m)
 }
 
-var xxx_messageInfo_MessageMetadata proto.InternalMessageInfo
+var xxx_messageInfo_Annotation proto.InternalMessageInfo
 
-func (m *MessageMetadata) GetMessageID() string {
+func (m *Annotation) GetKey() string {
 	if m != nil {
-		return m.MessageID
+		return m.Key
 	}
 	return ""
 }
 
-func (m *MessageMetadata) GetType() MessageMetadata_MessageType {
+func (m *Annotation) GetValue() []byte {
 	if m != nil {
-		return m.Type
+		return m.Value
 	}
-	return MessageMetadata_REQUEST
+	return nil
 }
 
 // ControlMessage 消息，用于定义控制消息的结构
@@ -419,22 +560,18 @@ func (m *ControlMessage) Reset()         { *m = ControlMessage{} }
 func (m *ControlMessage) String() string { return proto.CompactTextString(m) }
 func (*ControlMessage) ProtoMessage()    {}
 func (*ControlMessage) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{4}
+	return fileDescriptor_77a6da22d6a3feb1, []int{5}
 }
 func (m *ControlMessage) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ControlMessage) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ControlMessage.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ControlMessage) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ControlMessage.Merge(m, src)
@@ -491,22 +628,18 @@ func (m *ControlIHave) Reset()         { *m = ControlIHave{} }
 func (m *ControlIHave) String() string { return proto.CompactTextString(m) }
 func (*ControlIHave) ProtoMessage()    {}
 func (*ControlIHave) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{5}
+	return fileDescriptor_77a6da22d6a3feb1, []int{6}
 }
 func (m *ControlIHave) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ControlIHave) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ControlIHave.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ControlIHave) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ControlIHave.Merge(m, src)
@@ -547,22 +680,18 @@ func (m *ControlIWant) Reset()         { *m = ControlIWant{} }
 func (m *ControlIWant) String() string { return proto.CompactTextString(m) }
 func (*ControlIWant) ProtoMessage()    {}
 func (*ControlIWant) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{6}
+	return fileDescriptor_77a6da22d6a3feb1, []int{7}
 }
 func (m *ControlIWant) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ControlIWant) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ControlIWant.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ControlIWant) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ControlIWant.Merge(m, src)
@@ -596,22 +725,18 @@ func (m *ControlGraft) Reset()         { *m = ControlGraft{} }
 func (m *ControlGraft) String() string { return proto.CompactTextString(m) }
 func (*ControlGraft) ProtoMessage()    {}
 func (*ControlGraft) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{7}
+	return fileDescriptor_77a6da22d6a3feb1, []int{8}
 }
 func (m *ControlGraft) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ControlGraft) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ControlGraft.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ControlGraft) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ControlGraft.Merge(m, src)
@@ -639,7 +764,9 @@ type ControlPrune struct {
 	// 要与之断开连接的节点信息列表
 	Peers []*PeerInfo `protobuf:"bytes,2,rep,name=peers,proto3" json:"peers,omitempty"`
 	// 表示断开连接的时间（回退时间）
-	Backoff              uint64   `protobuf:"varint,3,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	Backoff uint64 `protobuf:"varint,3,opt,name=backoff,proto3" json:"backoff,omitempty"`
+	// 表示修剪的原因，例如节点即将离开
+	Reason               string   `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -649,22 +776,18 @@ func (m *ControlPrune) Reset()         { *m = ControlPrune{} }
 func (m *ControlPrune) String() string { return proto.CompactTextString(m) }
 func (*ControlPrune) ProtoMessage()    {}
 func (*ControlPrune) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{8}
+	return fileDescriptor_77a6da22d6a3feb1, []int{9}
 }
 func (m *ControlPrune) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *ControlPrune) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_ControlPrune.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *ControlPrune) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_ControlPrune.Merge(m, src)
@@ -699,6 +822,13 @@ func (m *ControlPrune) GetBackoff() uint64 {
 	return 0
 }
 
+func (m *ControlPrune) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
 // PeerInfo 消息，用于定义节点信息的结构
 type PeerInfo struct {
 	// 表示节点的ID
@@ -714,22 +844,18 @@ func (m *PeerInfo) Reset()         { *m = PeerInfo{} }
 func (m *PeerInfo) String() string { return proto.CompactTextString(m) }
 func (*PeerInfo) ProtoMessage()    {}
 func (*PeerInfo) Descriptor() ([]byte, []int) {
-	return fileDescriptor_77a6da22d6a3feb1, []int{9}
+	return fileDescriptor_77a6da22d6a3feb1, []int{10}
 }
 func (m *PeerInfo) XXX_Unmarshal(b []byte) error {
 	return m.Unmarshal(b)
 }
 func (m *PeerInfo) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
-	if deterministic {
-		return xxx_messageInfo_PeerInfo.Marshal(b, m, deterministic)
-	} else {
-		b = b[:cap(b)]
-		n, err := m.MarshalToSizedBuffer(b)
-		if err != nil {
-			return nil, err
-		}
-		return b[:n], nil
+	b = b[:cap(b)]
+	n, err := m.MarshalToSizedBuffer(b)
+	if err != nil {
+		return nil, err
 	}
+	return b[:n], nil
 }
 func (m *PeerInfo) XXX_Merge(src proto.Message) {
 	xxx_messageInfo_PeerInfo.Merge(m, src)
@@ -762,8 +888,11 @@ func init() {
 	proto.RegisterType((*RPC)(nil), "pb.RPC")
 	proto.RegisterType((*RPC_SubOpts)(nil), "pb.RPC.SubOpts")
 	proto.RegisterType((*Target)(nil), "pb.Target")
-	proto.RegisterType((*Message)(nil), "pb.Message")
 	proto.RegisterType((*MessageMetadata)(nil), "pb.MessageMetadata")
+	proto.RegisterMapType((map[string]string)(nil), "pb.MessageMetadata.TraceContextEntry")
+	proto.RegisterType((*Message)(nil), "pb.Message")
+	proto.RegisterMapType((map[string]string)(nil), "pb.Message.HeadersEntry")
+	proto.RegisterType((*Annotation)(nil), "pb.Annotation")
 	proto.RegisterType((*ControlMessage)(nil), "pb.ControlMessage")
 	proto.RegisterType((*ControlIHave)(nil), "pb.ControlIHave")
 	proto.RegisterType((*ControlIWant)(nil), "pb.ControlIWant")
@@ -775,46 +904,63 @@ func init() {
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
 
 var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 609 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x7c, 0x94, 0xcd, 0x6e, 0xd3, 0x40,
-	0x10, 0xc7, 0xd9, 0xe6, 0xc3, 0xce, 0xc4, 0xb4, 0xd1, 0x82, 0x60, 0x55, 0xa1, 0x10, 0x59, 0x80,
-	0x22, 0x84, 0x82, 0xd4, 0x8a, 0x23, 0x07, 0x68, 0x23, 0xda, 0x43, 0xdb, 0x30, 0x29, 0xe2, 0x88,
-	0xd6, 0xce, 0x26, 0xb5, 0xda, 0xda, 0xcb, 0x7a, 0x53, 0xd4, 0x97, 0xe0, 0x59, 0x78, 0x0c, 0x8e,
-	0x1c, 0x78, 0x00, 0x54, 0xf1, 0x20, 0x68, 0x77, 0x6d, 0x27, 0x69, 0x80, 0xdb, 0xce, 0x7f, 0x7e,
-	0xb3, 0x33, 0xb3, 0x9e, 0x31, 0xb4, 0x94, 0x8c, 0x07, 0x52, 0x65, 0x3a, 0xa3, 0x1b, 0x32, 0x0a,
-	0x7f, 0x12, 0xa8, 0xe1, 0x68, 0x8f, 0xbe, 0x82, 0xbb, 0xf9, 0x3c, 0xca, 0x63, 0x95, 0x48, 0x9d,
-	0x64, 0x69, 0xce, 0x48, 0xaf, 0xd6, 0x6f, 0xef, 0x6c, 0x0d, 0x64, 0x34, 0xc0, 0xd1, 0xde, 0x60,
-	0x3c, 0x8f, 0x4e, 0xa4, 0xce, 0x71, 0x95, 0xa2, 0x4f, 0xc1, 0x93, 0xf3, 0xe8, 0x22, 0xc9, 0xcf,
-	0xd8, 0x86, 0x0d, 0x68, 0x9b, 0x80, 0x23, 0x91, 0xe7, 0x7c, 0x26, 0xb0, 0xf4, 0xd1, 0x17, 0xe0,
-	0xc5, 0x59, 0xaa, 0x55, 0x76, 0xc1, 0x6a, 0x3d, 0xd2, 0x6f, 0xef, 0x50, 0x83, 0xed, 0x39, 0xa9,
-	0xa2, 0x0b, 0x64, 0xfb, 0x0d, 0x78, 0x45, 0x3a, 0xfa, 0x08, 0x5a, 0x45, 0xc2, 0x48, 0x30, 0xd2,
-	0x23, 0x7d, 0x1f, 0x17, 0x02, 0x65, 0xe0, 0xe9, 0x4c, 0x26, 0x71, 0x32, 0x61, 0x1b, 0x3d, 0xd2,
-	0x6f, 0x61, 0x69, 0x86, 0xaf, 0xa1, 0x79, 0xca, 0xd5, 0x4c, 0x68, 0xfa, 0x10, 0x3c, 0x29, 0x84,
-	0xfa, 0x94, 0x4c, 0x6c, 0x7c, 0x80, 0x4d, 0x63, 0x1e, 0x4e, 0xe8, 0x36, 0xf8, 0x4a, 0xc4, 0x22,
-	0xb9, 0x12, 0x2e, 0xda, 0xc7, 0xca, 0x0e, 0x7f, 0x13, 0xf0, 0x8a, 0xb2, 0x28, 0x85, 0xfa, 0x54,
-	0x65, 0x97, 0x45, 0xb4, 0x3d, 0xd3, 0x27, 0xe0, 0x69, 0x7b, 0x7d, 0x5e, 0xb4, 0x0d, 0xa6, 0x1f,
-	0x97, 0x11, 0x4b, 0x97, 0x89, 0x9c, 0x70, 0xcd, 0x6d, 0xcb, 0x01, 0xda, 0x33, 0xbd, 0x0f, 0x8d,
-	0x5c, 0x7c, 0x4e, 0x33, 0x56, 0xb7, 0xa2, 0x33, 0x8c, 0x6a, 0x2b, 0x67, 0x0d, 0xdb, 0x86, 0x33,
-	0x6c, 0xf3, 0xc9, 0x2c, 0xe5, 0x7a, 0xae, 0x04, 0x6b, 0x5a, 0x7e, 0x21, 0xd0, 0x0e, 0xd4, 0xce,
-	0xc5, 0x35, 0xf3, 0xac, 0x6e, 0x8e, 0xf4, 0x25, 0xf8, 0x97, 0x42, 0x73, 0x9b, 0xd3, 0xb7, 0xcf,
-	0x7c, 0x6f, 0xe9, 0x6b, 0x1c, 0x15, 0x2e, 0xac, 0xa0, 0xf0, 0x2b, 0x81, 0xad, 0x5b, 0x5e, 0x93,
-	0xf4, 0xd2, 0x49, 0x87, 0xfb, 0xb6, 0xe7, 0x16, 0x2e, 0x04, 0xba, 0x0b, 0x75, 0x7d, 0x2d, 0x85,
-	0x7d, 0xb0, 0xcd, 0x9d, 0xc7, 0x7f, 0xb9, 0xbe, 0xb4, 0x4f, 0xaf, 0xa5, 0x40, 0x0b, 0x87, 0x7d,
-	0x68, 0x2f, 0x89, 0xb4, 0x0d, 0x1e, 0x0e, 0xdf, 0x7f, 0x18, 0x8e, 0x4f, 0x3b, 0x77, 0x68, 0x00,
-	0x3e, 0x0e, 0xc7, 0xa3, 0x93, 0xe3, 0xf1, 0xb0, 0x43, 0xc2, 0x6f, 0x04, 0x36, 0x57, 0xa7, 0x82,
-	0x3e, 0x83, 0x46, 0x72, 0xc6, 0xaf, 0x44, 0x31, 0x90, 0x9d, 0xa5, 0xc1, 0x39, 0x3c, 0xe0, 0x57,
-	0x02, 0x9d, 0xdb, 0x72, 0x5f, 0x78, 0xaa, 0x8b, 0x0f, 0xb2, 0xc2, 0x7d, 0xe4, 0xa9, 0x46, 0xe7,
-	0x36, 0xdc, 0x4c, 0xf1, 0xa9, 0x66, 0xb5, 0x35, 0xee, 0x9d, 0xd1, 0xd1, 0xb9, 0x0d, 0x27, 0xd5,
-	0x3c, 0x15, 0xac, 0xbe, 0xc6, 0x8d, 0x8c, 0x8e, 0xce, 0x1d, 0x1e, 0x40, 0xb0, 0x5c, 0x4e, 0x35,
-	0x93, 0xd5, 0xeb, 0x95, 0x26, 0xed, 0x02, 0x54, 0x0f, 0xe9, 0xe6, 0xa6, 0x85, 0x4b, 0x4a, 0x38,
-	0x58, 0xdc, 0x64, 0x0a, 0xbe, 0xc5, 0x93, 0x35, 0xbe, 0x5f, 0xf1, 0xb6, 0xf0, 0x7f, 0x67, 0x0e,
-	0xa7, 0x15, 0x69, 0x4b, 0xff, 0x4f, 0x8d, 0x21, 0x34, 0xcc, 0x7a, 0x94, 0x63, 0x1d, 0x98, 0xae,
-	0x47, 0x66, 0x5f, 0xd2, 0x69, 0x86, 0xce, 0x65, 0xa2, 0x23, 0x1e, 0x9f, 0x67, 0xd3, 0xa9, 0x9d,
-	0xec, 0x3a, 0x96, 0x66, 0x78, 0x0c, 0x7e, 0x09, 0xd3, 0x07, 0xe0, 0x16, 0x6d, 0x7f, 0x65, 0xed,
-	0xf6, 0xe9, 0x73, 0xe8, 0x98, 0x19, 0x16, 0x13, 0x43, 0xa2, 0x88, 0x33, 0xe5, 0xd6, 0x2f, 0xc0,
-	0x35, 0xfd, 0x6d, 0xf0, 0xfd, 0xa6, 0x4b, 0x7e, 0xdc, 0x74, 0xc9, 0xaf, 0x9b, 0x2e, 0x89, 0x9a,
-	0xf6, 0xaf, 0xb5, 0xfb, 0x27, 0x00, 0x00, 0xff, 0xff, 0xba, 0x29, 0xca, 0x75, 0xc2, 0x04, 0x00,
-	0x00,
+	// 884 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0x51, 0x8f, 0x22, 0x45,
+	0x10, 0x76, 0x18, 0x60, 0xa0, 0x98, 0xdd, 0xc3, 0xf6, 0xa2, 0x1d, 0x62, 0x38, 0x32, 0xf1, 0x0c,
+	0x31, 0x86, 0x33, 0x7b, 0x9a, 0x98, 0x4b, 0xcc, 0x65, 0x6f, 0x97, 0xb8, 0xc4, 0xbd, 0x3b, 0x6c,
+	0x30, 0x3e, 0x9a, 0x66, 0x28, 0x60, 0xb2, 0xcb, 0x74, 0xdb, 0xd3, 0xac, 0xc7, 0x8b, 0x7f, 0xc4,
+	0x3f, 0xe1, 0xcf, 0xf0, 0xd1, 0x47, 0x1f, 0xcd, 0xfe, 0x02, 0x5f, 0x7c, 0x37, 0xdd, 0x3d, 0x03,
+	0xb3, 0xb2, 0x6a, 0x7c, 0xeb, 0xfa, 0xea, 0xab, 0x9a, 0xea, 0xaf, 0xab, 0x6a, 0xa0, 0xa9, 0x64,
+	0x3c, 0x90, 0x4a, 0x68, 0x41, 0x2a, 0x72, 0xd6, 0x79, 0xb8, 0x14, 0x4b, 0x61, 0xcd, 0x27, 0xe6,
+	0xe4, 0x3c, 0xd1, 0x9f, 0x1e, 0xf8, 0x6c, 0x7c, 0x46, 0x3e, 0x83, 0xa3, 0x6c, 0x33, 0xcb, 0x62,
+	0x95, 0x48, 0x9d, 0x88, 0x34, 0xa3, 0x5e, 0xcf, 0xef, 0xb7, 0x4e, 0x1e, 0x0c, 0xe4, 0x6c, 0xc0,
+	0xc6, 0x67, 0x83, 0xc9, 0x66, 0xf6, 0x5a, 0xea, 0x8c, 0xdd, 0x65, 0x91, 0xc7, 0x10, 0xc8, 0xcd,
+	0xec, 0x3a, 0xc9, 0x56, 0xb4, 0x62, 0x03, 0x5a, 0x26, 0xe0, 0x25, 0x66, 0x19, 0x5f, 0x22, 0x2b,
+	0x7c, 0xe4, 0x63, 0x08, 0x62, 0x91, 0x6a, 0x25, 0xae, 0xa9, 0xdf, 0xf3, 0xfa, 0xad, 0x13, 0x62,
+	0x68, 0x67, 0x0e, 0xda, 0xb1, 0x73, 0x0a, 0x79, 0x04, 0xd5, 0x4c, 0xe3, 0x9a, 0x56, 0x0f, 0x33,
+	0x5a, 0x47, 0xe7, 0x14, 0x82, 0xbc, 0x1e, 0xf2, 0x3e, 0x34, 0xf3, 0x8a, 0x66, 0x48, 0xbd, 0x9e,
+	0xd7, 0x6f, 0xb0, 0x3d, 0x40, 0x28, 0x04, 0x5a, 0xc8, 0x24, 0x4e, 0xe6, 0xb4, 0xd2, 0xf3, 0xfa,
+	0x4d, 0x56, 0x98, 0xd1, 0x17, 0x50, 0x9f, 0x72, 0xb5, 0x44, 0x4d, 0xde, 0x83, 0x40, 0x22, 0xaa,
+	0xef, 0x92, 0xb9, 0x8d, 0x0f, 0x59, 0xdd, 0x98, 0xa3, 0x39, 0xe9, 0x40, 0x43, 0x61, 0x8c, 0xc9,
+	0x0d, 0xba, 0xe8, 0x06, 0xdb, 0xd9, 0xd1, 0x4f, 0x3e, 0x3c, 0xc8, 0x6b, 0x7a, 0x89, 0x9a, 0xcf,
+	0xb9, 0xe6, 0xa6, 0x94, 0xb5, 0x83, 0x46, 0xe7, 0x36, 0x55, 0x93, 0xed, 0x01, 0xf2, 0x14, 0xaa,
+	0x7a, 0x2b, 0xd1, 0x66, 0x3a, 0x3e, 0x79, 0x54, 0xba, 0x54, 0x91, 0xa0, 0xb0, 0xa7, 0x5b, 0x89,
+	0xcc, 0x92, 0xc9, 0x47, 0xd0, 0x4e, 0xe6, 0xb8, 0x96, 0x42, 0x63, 0x1a, 0x6f, 0xa7, 0xe2, 0x0a,
+	0x53, 0x2b, 0x60, 0x93, 0x1d, 0xe0, 0x64, 0x04, 0xa1, 0x56, 0x3c, 0x46, 0xa3, 0x2a, 0xbe, 0xd1,
+	0xb9, 0x7a, 0x8f, 0xef, 0xfb, 0xd0, 0xb4, 0xc4, 0x1b, 0xa6, 0x5a, 0x6d, 0xd9, 0x9d, 0x50, 0xd2,
+	0x05, 0x50, 0x28, 0xaf, 0xb7, 0x53, 0x23, 0x16, 0xad, 0xd9, 0x0f, 0x96, 0x10, 0x73, 0x53, 0x9d,
+	0xac, 0x31, 0xd3, 0x7c, 0x2d, 0x69, 0xbd, 0xe7, 0xf5, 0x7d, 0xb6, 0x07, 0x3a, 0xcf, 0xe1, 0xed,
+	0x83, 0x0f, 0x90, 0x36, 0xf8, 0x57, 0xb8, 0xcd, 0x65, 0x31, 0x47, 0xf2, 0x10, 0x6a, 0x37, 0xfc,
+	0x7a, 0x83, 0xf9, 0xcb, 0x38, 0xe3, 0x59, 0xe5, 0x73, 0x2f, 0x7a, 0x0e, 0xad, 0x92, 0x14, 0xa4,
+	0x05, 0x01, 0x1b, 0x7e, 0xfd, 0xcd, 0x70, 0x32, 0x6d, 0xbf, 0x45, 0x42, 0x68, 0xb0, 0xe1, 0x64,
+	0xfc, 0xfa, 0xd5, 0x64, 0xd8, 0xf6, 0x9c, 0x75, 0x39, 0x3a, 0x7d, 0x71, 0x39, 0x6c, 0x57, 0x48,
+	0x00, 0xfe, 0xe9, 0xd9, 0x57, 0x6d, 0x3f, 0xfa, 0xc3, 0x87, 0x20, 0xcf, 0x40, 0x08, 0x54, 0x17,
+	0x4a, 0xac, 0xf3, 0xb7, 0xb5, 0x67, 0xf2, 0x01, 0x04, 0xda, 0x3e, 0x7e, 0x96, 0x77, 0x2d, 0x18,
+	0x95, 0x5c, 0x3f, 0xb0, 0xc2, 0x65, 0x22, 0x8d, 0x5a, 0x56, 0xf0, 0x90, 0xd9, 0xb3, 0x29, 0x3a,
+	0xc3, 0xef, 0x53, 0x41, 0xab, 0x16, 0x74, 0x86, 0x41, 0x75, 0x49, 0xaa, 0x9a, 0x2e, 0x54, 0xca,
+	0x92, 0x65, 0xca, 0xf5, 0x46, 0xa1, 0x55, 0x29, 0x64, 0x7b, 0xa0, 0x10, 0x24, 0xb0, 0xb8, 0x39,
+	0x92, 0x27, 0xd0, 0x58, 0xe7, 0x2f, 0x44, 0x1b, 0x76, 0x4a, 0xde, 0xb9, 0xe7, 0xf1, 0xd8, 0x8e,
+	0x44, 0x3e, 0x81, 0x16, 0x4f, 0x53, 0xa1, 0xb9, 0x9b, 0xd8, 0xa6, 0xbd, 0xca, 0xb1, 0x89, 0x39,
+	0xdd, 0xc1, 0xac, 0x4c, 0x21, 0x27, 0x10, 0xac, 0x90, 0xcf, 0x51, 0x65, 0x14, 0x2c, 0x9b, 0x96,
+	0xbe, 0x30, 0xb8, 0x70, 0x2e, 0xd7, 0x11, 0x05, 0xd1, 0xc8, 0xb0, 0x12, 0x32, 0xa3, 0xad, 0x9e,
+	0xd7, 0x3f, 0x62, 0xf6, 0x6c, 0x46, 0x63, 0x25, 0xe4, 0x65, 0xb2, 0x4e, 0x34, 0x0d, 0x2d, 0xbe,
+	0xb3, 0xcd, 0xcc, 0xe1, 0x1b, 0x99, 0x28, 0xcc, 0xe8, 0x91, 0x6d, 0x8d, 0xc2, 0x34, 0x6d, 0x65,
+	0x95, 0x99, 0x58, 0x05, 0x8f, 0x7b, 0x5e, 0xbf, 0xca, 0x4a, 0x48, 0xe7, 0x19, 0x84, 0xe5, 0x12,
+	0xfe, 0x57, 0xcf, 0x7c, 0x0a, 0xb0, 0xbf, 0xf4, 0x7f, 0x45, 0x86, 0x79, 0x64, 0xf4, 0xb3, 0x07,
+	0xc7, 0x77, 0xb7, 0x10, 0xf9, 0x10, 0x6a, 0xc9, 0x8a, 0xdf, 0x60, 0xbe, 0x00, 0xdb, 0xa5, 0x45,
+	0x35, 0xba, 0xe0, 0x37, 0xc8, 0x9c, 0xdb, 0xf2, 0x7e, 0xe0, 0xa9, 0xa6, 0x95, 0x43, 0xde, 0xb7,
+	0x3c, 0xd5, 0xcc, 0xb9, 0x0d, 0x6f, 0xa9, 0xf8, 0x42, 0x53, 0xff, 0x80, 0xf7, 0xa5, 0xc1, 0x99,
+	0x73, 0x1b, 0x9e, 0x54, 0x9b, 0x14, 0x69, 0xf5, 0x80, 0x37, 0x36, 0x38, 0x73, 0xee, 0xe8, 0x02,
+	0xc2, 0x72, 0x39, 0xbb, 0x15, 0xb7, 0xdb, 0x39, 0x85, 0x69, 0xe4, 0xde, 0xad, 0x1f, 0xd7, 0xe8,
+	0x4d, 0x56, 0x42, 0xa2, 0x01, 0x84, 0xe5, 0x82, 0xff, 0xc6, 0xf7, 0x0e, 0xf8, 0x7d, 0x08, 0xcb,
+	0x85, 0xff, 0xf3, 0x97, 0xa3, 0x1f, 0x21, 0x2c, 0x97, 0xfe, 0x2f, 0x35, 0x46, 0x50, 0x33, 0xdb,
+	0xb6, 0x98, 0xc3, 0xd0, 0xdc, 0x7a, 0x6c, 0xd6, 0x6f, 0xba, 0x10, 0xcc, 0xb9, 0x4c, 0xf4, 0x8c,
+	0xc7, 0x57, 0x62, 0xb1, 0xb0, 0xa3, 0x58, 0x65, 0x85, 0x49, 0xde, 0x85, 0xba, 0x42, 0x9e, 0x89,
+	0xd4, 0x8e, 0x63, 0x93, 0xe5, 0x56, 0xf4, 0x0a, 0x1a, 0x45, 0x12, 0xc3, 0xb1, 0xfb, 0xfc, 0xfc,
+	0xce, 0x76, 0x3f, 0x37, 0xab, 0xd5, 0x0c, 0x23, 0xce, 0x0d, 0x93, 0x61, 0x2c, 0xd4, 0x3c, 0xef,
+	0x8d, 0x03, 0xfc, 0x45, 0xfb, 0x97, 0xdb, 0xae, 0xf7, 0xeb, 0x6d, 0xd7, 0xfb, 0xed, 0xb6, 0xeb,
+	0xfd, 0x7e, 0xdb, 0xf5, 0x66, 0x75, 0xfb, 0xf7, 0x7c, 0xfa, 0xd7, 0x00, 0xab, 0xd5, 0x99, 0x87,
+	0x64, 0x07, 0x00, 0x00,
 }
 
 func (m *RPC) Marshal() (dAtA []byte, err error) {
@@ -841,6 +987,20 @@ func (m *RPC) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.Stem) > 0 {
+		for iNdEx := len(m.Stem) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Stem[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x22
+		}
+	}
 	if m.Control != nil {
 		{
 			size, err := m.Control.MarshalToSizedBuffer(dAtA[:i])
@@ -972,7 +1132,7 @@ func (m *Target) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *Message) Marshal() (dAtA []byte, err error) {
+func (m *MessageMetadata) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -982,12 +1142,12 @@ func (m *Message) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *Message) MarshalTo(dAtA []byte) (int, error) {
+func (m *MessageMetadata) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *MessageMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -996,47 +1156,187 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.Metadata != nil {
-		{
-			size, err := m.Metadata.MarshalToSizedBuffer(dAtA[:i])
-			if err != nil {
-				return 0, err
-			}
-			i -= size
-			i = encodeVarintRpc(dAtA, i, uint64(size))
-		}
+	if m.Timestamp != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.Timestamp))
 		i--
-		dAtA[i] = 0x42
+		dAtA[i] = 0x30
 	}
-	if len(m.Key) > 0 {
-		i -= len(m.Key)
-		copy(dAtA[i:], m.Key)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.Key)))
+	if len(m.ReplyTopic) > 0 {
+		i -= len(m.ReplyTopic)
+		copy(dAtA[i:], m.ReplyTopic)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.ReplyTopic)))
 		i--
-		dAtA[i] = 0x3a
+		dAtA[i] = 0x2a
 	}
-	if len(m.Signature) > 0 {
-		i -= len(m.Signature)
-		copy(dAtA[i:], m.Signature)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.Signature)))
+	if len(m.TraceContext) > 0 {
+		keysForTraceContext := make([]string, 0, len(m.TraceContext))
+		for k := range m.TraceContext {
+			keysForTraceContext = append(keysForTraceContext, string(k))
+		}
+		github_com_gogo_protobuf_sortkeys.Strings(keysForTraceContext)
+		for iNdEx := len(keysForTraceContext) - 1; iNdEx >= 0; iNdEx-- {
+			v := m.TraceContext[string(keysForTraceContext[iNdEx])]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintRpc(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(keysForTraceContext[iNdEx])
+			copy(dAtA[i:], keysForTraceContext[iNdEx])
+			i = encodeVarintRpc(dAtA, i, uint64(len(keysForTraceContext[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintRpc(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x22
+		}
+	}
+	if len(m.IdempotencyToken) > 0 {
+		i -= len(m.IdempotencyToken)
+		copy(dAtA[i:], m.IdempotencyToken)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.IdempotencyToken)))
 		i--
-		dAtA[i] = 0x32
+		dAtA[i] = 0x1a
 	}
-	if len(m.Topic) > 0 {
-		i -= len(m.Topic)
-		copy(dAtA[i:], m.Topic)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.Topic)))
+	if m.Type != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.Type))
 		i--
-		dAtA[i] = 0x2a
+		dAtA[i] = 0x10
 	}
-	if len(m.Seqno) > 0 {
-		i -= len(m.Seqno)
-		copy(dAtA[i:], m.Seqno)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.Seqno)))
+	if len(m.MessageID) > 0 {
+		i -= len(m.MessageID)
+		copy(dAtA[i:], m.MessageID)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.MessageID)))
 		i--
-		dAtA[i] = 0x22
+		dAtA[i] = 0xa
 	}
-	if len(m.Data) > 0 {
+	return len(dAtA) - i, nil
+}
+
+func (m *Message) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Message) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.XXX_unrecognized != nil {
+		i -= len(m.XXX_unrecognized)
+		copy(dAtA[i:], m.XXX_unrecognized)
+	}
+	if m.TopicSeqno != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.TopicSeqno))
+		i--
+		dAtA[i] = 0x70
+	}
+	if m.Expires != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.Expires))
+		i--
+		dAtA[i] = 0x68
+	}
+	if m.HopLimit != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.HopLimit))
+		i--
+		dAtA[i] = 0x60
+	}
+	if m.Hops != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.Hops))
+		i--
+		dAtA[i] = 0x58
+	}
+	if len(m.Headers) > 0 {
+		keysForHeaders := make([]string, 0, len(m.Headers))
+		for k := range m.Headers {
+			keysForHeaders = append(keysForHeaders, string(k))
+		}
+		github_com_gogo_protobuf_sortkeys.Strings(keysForHeaders)
+		for iNdEx := len(keysForHeaders) - 1; iNdEx >= 0; iNdEx-- {
+			v := m.Headers[string(keysForHeaders[iNdEx])]
+			baseI := i
+			i -= len(v)
+			copy(dAtA[i:], v)
+			i = encodeVarintRpc(dAtA, i, uint64(len(v)))
+			i--
+			dAtA[i] = 0x12
+			i -= len(keysForHeaders[iNdEx])
+			copy(dAtA[i:], keysForHeaders[iNdEx])
+			i = encodeVarintRpc(dAtA, i, uint64(len(keysForHeaders[iNdEx])))
+			i--
+			dAtA[i] = 0xa
+			i = encodeVarintRpc(dAtA, i, uint64(baseI-i))
+			i--
+			dAtA[i] = 0x52
+		}
+	}
+	if len(m.Annotations) > 0 {
+		for iNdEx := len(m.Annotations) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Annotations[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintRpc(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x4a
+		}
+	}
+	if m.Metadata != nil {
+		{
+			size, err := m.Metadata.MarshalToSizedBuffer(dAtA[:i])
+			if err != nil {
+				return 0, err
+			}
+			i -= size
+			i = encodeVarintRpc(dAtA, i, uint64(size))
+		}
+		i--
+		dAtA[i] = 0x42
+	}
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Key)))
+		i--
+		dAtA[i] = 0x3a
+	}
+	if len(m.Signature) > 0 {
+		i -= len(m.Signature)
+		copy(dAtA[i:], m.Signature)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Signature)))
+		i--
+		dAtA[i] = 0x32
+	}
+	if len(m.Topic) > 0 {
+		i -= len(m.Topic)
+		copy(dAtA[i:], m.Topic)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Topic)))
+		i--
+		dAtA[i] = 0x2a
+	}
+	if len(m.Seqno) > 0 {
+		i -= len(m.Seqno)
+		copy(dAtA[i:], m.Seqno)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Seqno)))
+		i--
+		dAtA[i] = 0x22
+	}
+	if len(m.Data) > 0 {
 		i -= len(m.Data)
 		copy(dAtA[i:], m.Data)
 		i = encodeVarintRpc(dAtA, i, uint64(len(m.Data)))
@@ -1067,7 +1367,7 @@ func (m *Message) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func (m *MessageMetadata) Marshal() (dAtA []byte, err error) {
+func (m *Annotation) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
 	n, err := m.MarshalToSizedBuffer(dAtA[:size])
@@ -1077,12 +1377,12 @@ func (m *MessageMetadata) Marshal() (dAtA []byte, err error) {
 	return dAtA[:n], nil
 }
 
-func (m *MessageMetadata) MarshalTo(dAtA []byte) (int, error) {
+func (m *Annotation) MarshalTo(dAtA []byte) (int, error) {
 	size := m.Size()
 	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *MessageMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+func (m *Annotation) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	i := len(dAtA)
 	_ = i
 	var l int
@@ -1091,15 +1391,17 @@ func (m *MessageMetadata) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
-	if m.Type != 0 {
-		i = encodeVarintRpc(dAtA, i, uint64(m.Type))
+	if len(m.Value) > 0 {
+		i -= len(m.Value)
+		copy(dAtA[i:], m.Value)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Value)))
 		i--
-		dAtA[i] = 0x10
+		dAtA[i] = 0x12
 	}
-	if len(m.MessageID) > 0 {
-		i -= len(m.MessageID)
-		copy(dAtA[i:], m.MessageID)
-		i = encodeVarintRpc(dAtA, i, uint64(len(m.MessageID)))
+	if len(m.Key) > 0 {
+		i -= len(m.Key)
+		copy(dAtA[i:], m.Key)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Key)))
 		i--
 		dAtA[i] = 0xa
 	}
@@ -1326,6 +1628,13 @@ func (m *ControlPrune) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 		i -= len(m.XXX_unrecognized)
 		copy(dAtA[i:], m.XXX_unrecognized)
 	}
+	if len(m.Reason) > 0 {
+		i -= len(m.Reason)
+		copy(dAtA[i:], m.Reason)
+		i = encodeVarintRpc(dAtA, i, uint64(len(m.Reason)))
+		i--
+		dAtA[i] = 0x22
+	}
 	if m.Backoff != 0 {
 		i = encodeVarintRpc(dAtA, i, uint64(m.Backoff))
 		i--
@@ -1429,6 +1738,12 @@ func (m *RPC) Size() (n int) {
 		l = m.Control.Size()
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	if len(m.Stem) > 0 {
+		for _, e := range m.Stem {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1473,6 +1788,44 @@ func (m *Target) Size() (n int) {
 	return n
 }
 
+func (m *MessageMetadata) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.MessageID)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if m.Type != 0 {
+		n += 1 + sovRpc(uint64(m.Type))
+	}
+	l = len(m.IdempotencyToken)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if len(m.TraceContext) > 0 {
+		for k, v := range m.TraceContext {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovRpc(uint64(len(k))) + 1 + len(v) + sovRpc(uint64(len(v)))
+			n += mapEntrySize + 1 + sovRpc(uint64(mapEntrySize))
+		}
+	}
+	l = len(m.ReplyTopic)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sovRpc(uint64(m.Timestamp))
+	}
+	if m.XXX_unrecognized != nil {
+		n += len(m.XXX_unrecognized)
+	}
+	return n
+}
+
 func (m *Message) Size() (n int) {
 	if m == nil {
 		return 0
@@ -1513,24 +1866,51 @@ func (m *Message) Size() (n int) {
 		l = m.Metadata.Size()
 		n += 1 + l + sovRpc(uint64(l))
 	}
+	if len(m.Annotations) > 0 {
+		for _, e := range m.Annotations {
+			l = e.Size()
+			n += 1 + l + sovRpc(uint64(l))
+		}
+	}
+	if len(m.Headers) > 0 {
+		for k, v := range m.Headers {
+			_ = k
+			_ = v
+			mapEntrySize := 1 + len(k) + sovRpc(uint64(len(k))) + 1 + len(v) + sovRpc(uint64(len(v)))
+			n += mapEntrySize + 1 + sovRpc(uint64(mapEntrySize))
+		}
+	}
+	if m.Hops != 0 {
+		n += 1 + sovRpc(uint64(m.Hops))
+	}
+	if m.HopLimit != 0 {
+		n += 1 + sovRpc(uint64(m.HopLimit))
+	}
+	if m.Expires != 0 {
+		n += 1 + sovRpc(uint64(m.Expires))
+	}
+	if m.TopicSeqno != 0 {
+		n += 1 + sovRpc(uint64(m.TopicSeqno))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
 	return n
 }
 
-func (m *MessageMetadata) Size() (n int) {
+func (m *Annotation) Size() (n int) {
 	if m == nil {
 		return 0
 	}
 	var l int
 	_ = l
-	l = len(m.MessageID)
+	l = len(m.Key)
 	if l > 0 {
 		n += 1 + l + sovRpc(uint64(l))
 	}
-	if m.Type != 0 {
-		n += 1 + sovRpc(uint64(m.Type))
+	l = len(m.Value)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
 	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
@@ -1649,6 +2029,10 @@ func (m *ControlPrune) Size() (n int) {
 	if m.Backoff != 0 {
 		n += 1 + sovRpc(uint64(m.Backoff))
 	}
+	l = len(m.Reason)
+	if l > 0 {
+		n += 1 + l + sovRpc(uint64(l))
+	}
 	if m.XXX_unrecognized != nil {
 		n += len(m.XXX_unrecognized)
 	}
@@ -1814,6 +2198,40 @@ func (m *RPC) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Stem", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Stem = append(m.Stem, &Message{})
+			if err := m.Stem[len(m.Stem)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -1908,71 +2326,386 @@ func (m *RPC_SubOpts) Unmarshal(dAtA []byte) error {
 			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + intStringLen
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Topicid = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *Target) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: Target: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: Target: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field PeerId", wireType)
+			}
+			var byteLen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				byteLen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.PeerId = append(m.PeerId[:0], dAtA[iNdEx:postIndex]...)
+			if m.PeerId == nil {
+				m.PeerId = []byte{}
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Received", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Received = bool(v != 0)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRpc(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if (skippy < 0) || (iNdEx+skippy) < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *MessageMetadata) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRpc
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: MessageMetadata: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: MessageMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.MessageID = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			}
+			m.Type = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Type |= MessageMetadata_MessageType(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field IdempotencyToken", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.IdempotencyToken = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TraceContext", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Topicid = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipRpc(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if (skippy < 0) || (iNdEx+skippy) < 0 {
-				return ErrInvalidLengthRpc
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.XXX_unrecognized = append(m.XXX_unrecognized, dAtA[iNdEx:iNdEx+skippy]...)
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *Target) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowRpc
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= uint64(b&0x7F) << shift
-			if b < 0x80 {
-				break
+			if m.TraceContext == nil {
+				m.TraceContext = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRpc
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRpc
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthRpc
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRpc
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthRpc
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipRpc(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
 			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: Target: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: Target: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+			m.TraceContext[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 5:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PeerId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ReplyTopic", wireType)
 			}
-			var byteLen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -1982,31 +2715,29 @@ func (m *Target) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				byteLen |= int(b&0x7F) << shift
+				stringLen |= uint64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if byteLen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthRpc
 			}
-			postIndex := iNdEx + byteLen
+			postIndex := iNdEx + intStringLen
 			if postIndex < 0 {
 				return ErrInvalidLengthRpc
 			}
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.PeerId = append(m.PeerId[:0], dAtA[iNdEx:postIndex]...)
-			if m.PeerId == nil {
-				m.PeerId = []byte{}
-			}
+			m.ReplyTopic = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
+		case 6:
 			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Received", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Timestamp", wireType)
 			}
-			var v int
+			m.Timestamp = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -2016,12 +2747,11 @@ func (m *Target) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				v |= int(b&0x7F) << shift
+				m.Timestamp |= int64(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			m.Received = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -2345,6 +3075,243 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 9:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Annotations", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Annotations = append(m.Annotations, &Annotation{})
+			if err := m.Annotations[len(m.Annotations)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Headers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Headers == nil {
+				m.Headers = make(map[string]string)
+			}
+			var mapkey string
+			var mapvalue string
+			for iNdEx < postIndex {
+				entryPreIndex := iNdEx
+				var wire uint64
+				for shift := uint(0); ; shift += 7 {
+					if shift >= 64 {
+						return ErrIntOverflowRpc
+					}
+					if iNdEx >= l {
+						return io.ErrUnexpectedEOF
+					}
+					b := dAtA[iNdEx]
+					iNdEx++
+					wire |= uint64(b&0x7F) << shift
+					if b < 0x80 {
+						break
+					}
+				}
+				fieldNum := int32(wire >> 3)
+				if fieldNum == 1 {
+					var stringLenmapkey uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRpc
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapkey |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapkey := int(stringLenmapkey)
+					if intStringLenmapkey < 0 {
+						return ErrInvalidLengthRpc
+					}
+					postStringIndexmapkey := iNdEx + intStringLenmapkey
+					if postStringIndexmapkey < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if postStringIndexmapkey > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapkey = string(dAtA[iNdEx:postStringIndexmapkey])
+					iNdEx = postStringIndexmapkey
+				} else if fieldNum == 2 {
+					var stringLenmapvalue uint64
+					for shift := uint(0); ; shift += 7 {
+						if shift >= 64 {
+							return ErrIntOverflowRpc
+						}
+						if iNdEx >= l {
+							return io.ErrUnexpectedEOF
+						}
+						b := dAtA[iNdEx]
+						iNdEx++
+						stringLenmapvalue |= uint64(b&0x7F) << shift
+						if b < 0x80 {
+							break
+						}
+					}
+					intStringLenmapvalue := int(stringLenmapvalue)
+					if intStringLenmapvalue < 0 {
+						return ErrInvalidLengthRpc
+					}
+					postStringIndexmapvalue := iNdEx + intStringLenmapvalue
+					if postStringIndexmapvalue < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if postStringIndexmapvalue > l {
+						return io.ErrUnexpectedEOF
+					}
+					mapvalue = string(dAtA[iNdEx:postStringIndexmapvalue])
+					iNdEx = postStringIndexmapvalue
+				} else {
+					iNdEx = entryPreIndex
+					skippy, err := skipRpc(dAtA[iNdEx:])
+					if err != nil {
+						return err
+					}
+					if (skippy < 0) || (iNdEx+skippy) < 0 {
+						return ErrInvalidLengthRpc
+					}
+					if (iNdEx + skippy) > postIndex {
+						return io.ErrUnexpectedEOF
+					}
+					iNdEx += skippy
+				}
+			}
+			m.Headers[mapkey] = mapvalue
+			iNdEx = postIndex
+		case 11:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Hops", wireType)
+			}
+			m.Hops = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Hops |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 12:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HopLimit", wireType)
+			}
+			m.HopLimit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.HopLimit |= uint32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 13:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Expires", wireType)
+			}
+			m.Expires = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Expires |= int64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 14:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field TopicSeqno", wireType)
+			}
+			m.TopicSeqno = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.TopicSeqno |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -2367,7 +3334,7 @@ func (m *Message) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *MessageMetadata) Unmarshal(dAtA []byte) error {
+func (m *Annotation) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -2390,15 +3357,15 @@ func (m *MessageMetadata) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: MessageMetadata: wiretype end group for non-group")
+			return fmt.Errorf("proto: Annotation: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: MessageMetadata: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: Annotation: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field MessageID", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Key", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -2426,13 +3393,13 @@ func (m *MessageMetadata) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.MessageID = string(dAtA[iNdEx:postIndex])
+			m.Key = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
-			if wireType != 0 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Type", wireType)
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Value", wireType)
 			}
-			m.Type = 0
+			var byteLen int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowRpc
@@ -2442,11 +3409,26 @@ func (m *MessageMetadata) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				m.Type |= MessageMetadata_MessageType(b&0x7F) << shift
+				byteLen |= int(b&0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
+			if byteLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + byteLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Value = append(m.Value[:0], dAtA[iNdEx:postIndex]...)
+			if m.Value == nil {
+				m.Value = []byte{}
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
@@ -3051,6 +4033,38 @@ func (m *ControlPrune) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Reason", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRpc
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRpc
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Reason = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
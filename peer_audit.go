@@ -0,0 +1,257 @@
+// 作用：对等节点行为审计日志。
+// 功能：为每个对等节点在内存中保留最近的行为事件（消息被拒绝及原因、限流、行为惩罚、超大 RPC），
+// 每个对等节点的事件保存在固定大小的环形缓冲区中，并限制记录的对等节点数，
+// 无需启用完整的事件追踪即可事后回答"这个对等节点为什么被惩罚或封禁"。
+
+package pubsub
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+const (
+	// DefaultPeerAuditSize 是每个对等节点保留的默认审计事件数
+	DefaultPeerAuditSize = 64
+	// DefaultPeerAuditPeers 是默认记录审计事件的最大对等节点数，超出时丢弃最久没有事件的对等节点
+	DefaultPeerAuditPeers = 1024
+)
+
+// PeerAuditKind 是审计事件的类型
+type PeerAuditKind int
+
+const (
+	// PeerAuditReject 表示对等节点转发的消息被拒绝或忽略
+	PeerAuditReject PeerAuditKind = iota
+	// PeerAuditThrottle 表示对等节点被限流
+	PeerAuditThrottle
+	// PeerAuditPenalty 表示对等节点受到行为惩罚
+	PeerAuditPenalty
+	// PeerAuditOversizedRPC 表示对等节点发送的 RPC 超出最大传输大小
+	PeerAuditOversizedRPC
+)
+
+// String 返回审计事件类型的名称
+// 返回值:
+//   - string: 类型名称
+func (k PeerAuditKind) String() string {
+	switch k {
+	case PeerAuditReject:
+		return "reject"
+	case PeerAuditThrottle:
+		return "throttle"
+	case PeerAuditPenalty:
+		return "penalty"
+	case PeerAuditOversizedRPC:
+		return "oversized rpc"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(k))
+	}
+}
+
+// PeerAuditEvent 是一个对等节点的审计事件
+type PeerAuditEvent struct {
+	Time   time.Time     // 事件时间
+	Kind   PeerAuditKind // 事件类型
+	Topic  string        // 相关的主题，没有时为空
+	Reason string        // 拒绝或惩罚的原因，为 Reject* 或 Penalty* 常量
+	Count  int           // 惩罚次数或 RPC 大小限制，其他事件为 0
+}
+
+// peerAuditLog 是一个对等节点的审计事件环形缓冲区
+type peerAuditLog struct {
+	events []PeerAuditEvent
+	next   int       // 下一个事件写入的位置
+	last   time.Time // 最近一个事件的时间
+}
+
+// peerAudit 是记录对等节点行为审计事件的内部追踪器
+type peerAudit struct {
+	sync.Mutex
+	size     int // 每个对等节点保留的事件数
+	maxPeers int // 记录的最大对等节点数
+	self     peer.ID
+	peers    map[peer.ID]*peerAuditLog
+}
+
+// WithPeerAudit 是一个选项，设置对等节点审计日志的大小
+// 参数:
+//   - size: 每个对等节点保留的事件数
+//   - maxPeers: 记录的最大对等节点数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerAudit(size, maxPeers int) Option {
+	return func(ps *PubSub) error {
+		if size <= 0 || maxPeers <= 0 {
+			logger.Warnf("审计日志的大小必须为正数")
+			return fmt.Errorf("审计日志的大小必须为正数")
+		}
+		ps.audit.size = size
+		ps.audit.maxPeers = maxPeers
+		return nil
+	}
+}
+
+// newPeerAudit 创建对等节点审计追踪器
+// 参数:
+//   - self: 本节点 ID，不记录本节点的事件
+//
+// 返回值:
+//   - *peerAudit: 审计追踪器
+func newPeerAudit(self peer.ID) *peerAudit {
+	return &peerAudit{
+		size:     DefaultPeerAuditSize,
+		maxPeers: DefaultPeerAuditPeers,
+		self:     self,
+		peers:    make(map[peer.ID]*peerAuditLog),
+	}
+}
+
+// record 记录对等节点的审计事件
+// 参数:
+//   - p: 对等节点
+//   - evt: 审计事件
+func (pa *peerAudit) record(p peer.ID, evt PeerAuditEvent) {
+	if p == "" || p == pa.self {
+		return
+	}
+
+	pa.Lock()
+	defer pa.Unlock()
+
+	log, ok := pa.peers[p]
+	if !ok {
+		if len(pa.peers) >= pa.maxPeers {
+			pa.evictOldest()
+		}
+		log = &peerAuditLog{}
+		pa.peers[p] = log
+	}
+	log.last = evt.Time
+	if len(log.events) < pa.size {
+		log.events = append(log.events, evt)
+		return
+	}
+	log.events[log.next] = evt
+	log.next = (log.next + 1) % len(log.events)
+}
+
+// evictOldest 丢弃最久没有事件的对等节点。调用方必须持有锁
+func (pa *peerAudit) evictOldest() {
+	var oldest peer.ID
+	var last time.Time
+	for p, log := range pa.peers {
+		if oldest == "" || log.last.Before(last) {
+			oldest, last = p, log.last
+		}
+	}
+	delete(pa.peers, oldest)
+}
+
+// events 返回对等节点的审计事件，按时间从早到晚排列
+// 参数:
+//   - p: 对等节点
+//
+// 返回值:
+//   - []PeerAuditEvent: 审计事件
+func (pa *peerAudit) events(p peer.ID) []PeerAuditEvent {
+	pa.Lock()
+	defer pa.Unlock()
+
+	log, ok := pa.peers[p]
+	if !ok {
+		return nil
+	}
+	out := make([]PeerAuditEvent, 0, len(log.events))
+	out = append(out, log.events[log.next:]...)
+	out = append(out, log.events[:log.next]...)
+	return out
+}
+
+// PeerAudit 返回对等节点最近的行为审计事件，按时间从早到晚排列。
+// 对等节点断开连接后事件仍被保留，直到因记录的对等节点数超出限制而被丢弃。
+// 参数:
+//   - pid: 对等节点
+//
+// 返回值:
+//   - []PeerAuditEvent: 审计事件
+func (p *PubSub) PeerAudit(pid peer.ID) []PeerAuditEvent {
+	return p.audit.events(pid)
+}
+
+// penalize 对对等节点施加行为惩罚并记录惩罚原因。未启用对等节点评分时不做任何事
+// 参数:
+//   - p: 对等节点
+//   - count: 惩罚次数
+//   - reason: 惩罚原因
+func (gs *GossipSubRouter) penalize(p peer.ID, count int, reason string) {
+	if gs.score == nil {
+		return
+	}
+	gs.score.AddPenalty(p, count)
+	gs.tracer.PeerPenalty(p, count, reason)
+}
+
+// RejectMessage 实现 RawTracer 接口
+func (pa *peerAudit) RejectMessage(msg *Message, reason string) {
+	pa.record(msg.ReceivedFrom, PeerAuditEvent{Time: time.Now(), Kind: PeerAuditReject, Topic: msg.GetTopic(), Reason: reason})
+}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (pa *peerAudit) ThrottlePeer(p peer.ID) {
+	pa.record(p, PeerAuditEvent{Time: time.Now(), Kind: PeerAuditThrottle})
+}
+
+// PeerPenalty 实现 PeerPenaltyTracer 接口
+func (pa *peerAudit) PeerPenalty(p peer.ID, count int, reason string) {
+	pa.record(p, PeerAuditEvent{Time: time.Now(), Kind: PeerAuditPenalty, Reason: reason, Count: count})
+}
+
+// OversizedRPC 实现 OversizedRPCTracer 接口
+func (pa *peerAudit) OversizedRPC(p peer.ID, limit int) {
+	pa.record(p, PeerAuditEvent{Time: time.Now(), Kind: PeerAuditOversizedRPC, Count: limit})
+}
+
+// AddPeer 实现 RawTracer 接口
+func (pa *peerAudit) AddPeer(p peer.ID, proto protocol.ID) {}
+
+// RemovePeer 实现 RawTracer 接口
+func (pa *peerAudit) RemovePeer(p peer.ID) {}
+
+// Join 实现 RawTracer 接口
+func (pa *peerAudit) Join(topic string) {}
+
+// Leave 实现 RawTracer 接口
+func (pa *peerAudit) Leave(topic string) {}
+
+// Graft 实现 RawTracer 接口
+func (pa *peerAudit) Graft(p peer.ID, topic string) {}
+
+// Prune 实现 RawTracer 接口
+func (pa *peerAudit) Prune(p peer.ID, topic string) {}
+
+// ValidateMessage 实现 RawTracer 接口
+func (pa *peerAudit) ValidateMessage(msg *Message) {}
+
+// DeliverMessage 实现 RawTracer 接口
+func (pa *peerAudit) DeliverMessage(msg *Message) {}
+
+// DuplicateMessage 实现 RawTracer 接口
+func (pa *peerAudit) DuplicateMessage(msg *Message) {}
+
+// RecvRPC 实现 RawTracer 接口
+func (pa *peerAudit) RecvRPC(rpc *RPC) {}
+
+// SendRPC 实现 RawTracer 接口
+func (pa *peerAudit) SendRPC(rpc *RPC, p peer.ID) {}
+
+// DropRPC 实现 RawTracer 接口
+func (pa *peerAudit) DropRPC(rpc *RPC, p peer.ID) {}
+
+// UndeliverableMessage 实现 RawTracer 接口
+func (pa *peerAudit) UndeliverableMessage(msg *Message) {}
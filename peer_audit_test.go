@@ -0,0 +1,86 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestPeerAuditReject 测试被拒绝的消息记录在转发它的对等节点的审计日志中
+func TestPeerAuditReject(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	err := psubs[1].RegisterTopicValidator("foo", func(context.Context, peer.ID, *Message) bool {
+		return false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[1].Subscribe("foo"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	topics := getTopics(psubs[:1], "foo")
+	if err := topics[0].Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(500 * time.Millisecond)
+
+	events := psubs[1].PeerAudit(hosts[0].ID())
+	if len(events) != 1 {
+		t.Fatalf("expected 1 audit event, got %d", len(events))
+	}
+	if evt := events[0]; evt.Kind != PeerAuditReject || evt.Reason != RejectValidationFailed || evt.Topic != "foo" {
+		t.Fatalf("unexpected audit event: %+v", evt)
+	}
+	if events := psubs[0].PeerAudit(hosts[1].ID()); len(events) != 0 {
+		t.Fatalf("expected no audit events for a well-behaved peer, got %d", len(events))
+	}
+}
+
+// TestPeerAuditRing 测试审计日志保留每个对等节点最近的事件并限制记录的对等节点数
+func TestPeerAuditRing(t *testing.T) {
+	pa := newPeerAudit("self")
+	pa.size = 3
+	pa.maxPeers = 2
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		pa.PeerPenalty("a", i, PenaltyBrokenPromise)
+	}
+	events := pa.events("a")
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+	for i, evt := range events {
+		if evt.Kind != PeerAuditPenalty || evt.Count != i+2 {
+			t.Fatalf("unexpected event at %d: %+v", i, evt)
+		}
+	}
+
+	pa.ThrottlePeer("self")
+	if events := pa.events("self"); events != nil {
+		t.Fatal("expected no events for the local peer")
+	}
+
+	pa.record("b", PeerAuditEvent{Time: now.Add(time.Second), Kind: PeerAuditThrottle})
+	pa.record("a", PeerAuditEvent{Time: now.Add(2 * time.Second), Kind: PeerAuditThrottle})
+	pa.record("c", PeerAuditEvent{Time: now.Add(3 * time.Second), Kind: PeerAuditThrottle})
+	if events := pa.events("b"); events != nil {
+		t.Fatal("expected the least recently active peer to be evicted")
+	}
+	if len(pa.events("a")) != 3 || len(pa.events("c")) != 1 {
+		t.Fatal("expected recently active peers to be kept")
+	}
+	if s := PeerAuditOversizedRPC.String(); s != "oversized rpc" {
+		t.Fatalf("unexpected kind name %s", s)
+	}
+}
@@ -1,5 +1,5 @@
 // 作用：管理对等节点的连接和访问控制。
-// 功能：根据对等节点的行为和评分，决定是否允许与其通信。
+// 功能：根据对等节点的行为和评分，决定是否允许与其通信；适用于 gossipsub、floodsub 和 randomsub 路由器。
 
 package pubsub
 
@@ -156,7 +156,9 @@ type peerGaterStats struct {
 	deliver, duplicate, ignore, reject float64 // 各类消息的计数器
 }
 
-// WithPeerGater 是一个 gossipsub 路由器选项，用于启用反应性验证队列管理
+// WithPeerGater 是一个路由器选项，用于启用反应性验证队列管理：
+// 验证队列过载时，按对等节点的拒绝和忽略比例以一定概率丢弃其入站 RPC 的负载消息。
+// 支持 gossipsub、floodsub 和 randomsub 路由器。
 // 参数:
 //   - params: PeerGater 的参数
 //
@@ -164,28 +166,36 @@ type peerGaterStats struct {
 //   - Option: PubSub 的选项函数
 func WithPeerGater(params *PeerGaterParams) Option {
 	return func(ps *PubSub) error {
-		gs, ok := ps.rt.(*GossipSubRouter) // 将 PubSub 的路由器类型转换为 GossipSubRouter
-		if !ok {                           // 如果转换失败，返回错误信息
-			logger.Warnf("pubsub 路由器不是 gossipsub")
-			return fmt.Errorf("pubsub 路由器不是 gossipsub")
-		}
-
 		err := params.validate() // 验证参数的有效性
 		if err != nil {          // 如果验证失败，返回错误信息
 			logger.Warnf("peer gater 参数验证失败: %v", err)
 			return err
 		}
 
-		gs.gate = newPeerGater(ps.ctx, ps.host, params) // 创建新的 peerGater 实例，并赋值给 GossipSubRouter 的 gate 字段
+		gate := newPeerGater(ps.ctx, ps.host, params) // 创建新的 peerGater 实例
+
+		// 将 gate 交给路由器
+		switch rt := ps.rt.(type) {
+		case *GossipSubRouter:
+			rt.gate = gate
+		case *FloodSubRouter:
+			rt.gate = gate
+		case *RandomSubRouter:
+			rt.gate = gate
+		default:
+			logger.Warnf("pubsub 路由器不支持 peer gater")
+			return fmt.Errorf("pubsub 路由器不支持 peer gater")
+		}
 
 		// 挂钩 tracer
 		if ps.tracer != nil { // 如果已经存在 tracer
-			ps.tracer.raw = append(ps.tracer.raw, gs.gate) // 将新的 gate 添加到 tracer 的 raw 列表中
+			ps.tracer.addInternalRaw(gate) // 将新的 gate 添加到 tracer 的 raw 列表中
 		} else { // 如果不存在 tracer
 			ps.tracer = &pubsubTracer{ // 创建新的 pubsubTracer 实例，并赋值给 PubSub 的 tracer 字段
-				raw:   []RawTracer{gs.gate}, // 将新的 gate 添加到 raw 列表中
-				pid:   ps.host.ID(),         // 设置 tracer 的 pid 为本地主机的 ID
-				idGen: ps.idGen,             // 设置 tracer 的 idGen
+				raw:      []RawTracer{gate}, // 将新的 gate 添加到 raw 列表中
+				internal: []RawTracer{gate}, // 路由器内部使用的追踪器
+				pid:      ps.host.ID(),      // 设置 tracer 的 pid 为本地主机的 ID
+				idGen:    ps.idGen,          // 设置 tracer 的 idGen
 			}
 		}
 
@@ -510,6 +520,10 @@ func (pg *peerGater) RejectMessage(msg *Message, reason string) {
 // 参数:
 //   - msg: 消息
 func (pg *peerGater) DuplicateMessage(msg *Message) {
+	if msg.toleratedDuplicate {
+		return // 主题容忍度之内的重复不计入
+	}
+
 	pg.Lock()         // 加锁，确保在操作 pg 时没有其他 goroutine 并发访问
 	defer pg.Unlock() // 在函数返回前解锁，确保锁在函数结束时被释放
 
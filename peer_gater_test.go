@@ -146,3 +146,49 @@ func TestPeerGater(t *testing.T) {
 		t.Fatal("still have a stat record for peerA's ip")
 	}
 }
+
+// TestPeerGaterRouters 测试 PeerGater 可用于 floodsub 和 randomsub 路由器
+func TestPeerGaterRouters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	params := NewPeerGaterParams(.1, .9, .999)
+
+	fs := getPubsub(ctx, hosts[0], WithPeerGater(params))
+	frt := fs.rt.(*FloodSubRouter)
+	if frt.gate == nil {
+		t.Fatal("expected floodsub peer gater to be set")
+	}
+
+	rs := getRandomsub(ctx, hosts[1], 10, WithPeerGater(params))
+	rrt := rs.rt.(*RandomSubRouter)
+	if rrt.gate == nil {
+		t.Fatal("expected randomsub peer gater to be set")
+	}
+
+	// 验证队列未限流时接受所有消息
+	peerA := peer.ID("A")
+	if status := frt.AcceptFrom(peerA); status != AcceptAll {
+		t.Fatal("expected AcceptAll")
+	}
+	if status := rrt.AcceptFrom(peerA); status != AcceptAll {
+		t.Fatal("expected AcceptAll")
+	}
+
+	// 验证队列限流后，对等节点的拒绝比例决定接受状态
+	frt.gate.getIP = func(peer.ID) string { return "1.2.3.4" }
+	frt.gate.AddPeer(peerA, "")
+	frt.gate.RejectMessage(&Message{ReceivedFrom: peerA}, RejectValidationQueueFull)
+	msg := &Message{ReceivedFrom: peerA}
+	for i := 0; i < 100; i++ {
+		frt.gate.RejectMessage(msg, RejectValidationFailed)
+	}
+	throttled := false
+	for i := 0; !throttled && i < 1000; i++ {
+		throttled = frt.AcceptFrom(peerA) == AcceptControl
+	}
+	if !throttled {
+		t.Fatal("expected AcceptControl")
+	}
+}
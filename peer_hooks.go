@@ -0,0 +1,58 @@
+// 作用：对等节点生命周期回调。
+// 功能：在路由器添加或移除对等节点时通知应用程序，使应用程序维护的对等节点状态（如同步管理器）与路由器的对等节点追踪保持一致。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// peerHooks 保存对等节点生命周期回调
+type peerHooks struct {
+	onAdd    func(peer.ID, protocol.ID) // 对等节点加入路由器后调用
+	onRemove func(peer.ID)              // 对等节点从路由器移除后调用
+}
+
+// WithPeerLifecycleHooks 是一个选项，设置对等节点加入和离开路由器时的回调。
+// 回调在事件循环中紧随路由器的 AddPeer 和 RemovePeer 之后调用，顺序与路由器看到的一致，不能阻塞。
+// 其中一个回调可以为 nil。
+// 参数:
+//   - onAdd: 对等节点加入时的回调，参数为对等节点和路由器使用的协议
+//   - onRemove: 对等节点离开时的回调
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerLifecycleHooks(onAdd func(peer.ID, protocol.ID), onRemove func(peer.ID)) Option {
+	return func(ps *PubSub) error {
+		if onAdd == nil && onRemove == nil {
+			logger.Warnf("至少需要设置一个对等节点生命周期回调")
+			return fmt.Errorf("至少需要设置一个对等节点生命周期回调")
+		}
+		ps.peerHooks = peerHooks{onAdd: onAdd, onRemove: onRemove}
+		return nil
+	}
+}
+
+// addPeer 将对等节点添加到路由器并调用加入回调。只能在事件循环中调用。
+// 参数:
+//   - pid: 对等节点
+//   - proto: 路由器使用的协议
+func (p *PubSub) addPeer(pid peer.ID, proto protocol.ID) {
+	p.rt.AddPeer(pid, proto)
+	if p.peerHooks.onAdd != nil {
+		p.peerHooks.onAdd(pid, proto)
+	}
+}
+
+// removePeer 从路由器移除对等节点并调用离开回调。只能在事件循环中调用。
+// 参数:
+//   - pid: 对等节点
+func (p *PubSub) removePeer(pid peer.ID) {
+	p.rt.RemovePeer(pid)
+	if p.peerHooks.onRemove != nil {
+		p.peerHooks.onRemove(pid)
+	}
+}
@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// TestPeerLifecycleHooks 测试对等节点加入和离开路由器时调用回调
+func TestPeerLifecycleHooks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mx sync.Mutex
+	added := make(map[peer.ID]protocol.ID)
+	removed := make(map[peer.ID]bool)
+	onAdd := func(pid peer.ID, proto protocol.ID) {
+		mx.Lock()
+		defer mx.Unlock()
+		added[pid] = proto
+	}
+	onRemove := func(pid peer.ID) {
+		mx.Lock()
+		defer mx.Unlock()
+		removed[pid] = true
+	}
+
+	hosts := getDefaultHosts(t, 2)
+	getPubsub(ctx, hosts[0], WithPeerLifecycleHooks(onAdd, onRemove))
+	getPubsub(ctx, hosts[1])
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	mx.Lock()
+	proto, ok := added[hosts[1].ID()]
+	mx.Unlock()
+	if !ok {
+		t.Fatal("expected onAdd to be called")
+	}
+	if proto != FloodSubID {
+		t.Fatalf("expected protocol %s, got %s", FloodSubID, proto)
+	}
+
+	hosts[1].Close()
+	time.Sleep(time.Second)
+
+	mx.Lock()
+	defer mx.Unlock()
+	if !removed[hosts[1].ID()] {
+		t.Fatal("expected onRemove to be called")
+	}
+}
+
+// TestPeerLifecycleHooksValidation 测试回调全部为空时选项返回错误
+func TestPeerLifecycleHooksValidation(t *testing.T) {
+	if err := WithPeerLifecycleHooks(nil, nil)(&PubSub{}); err == nil {
+		t.Fatal("expected error for nil hooks")
+	}
+}
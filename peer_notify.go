@@ -53,7 +53,7 @@ func (ps *PubSub) watchForNewPeers(ctx context.Context) {
 	if ps.protoMatchFunc != nil {
 		var supportedProtocols []func(protocol.ID) bool
 		// 遍历每个协议，应用 protoMatchFunc
-		for _, proto := range ps.rt.Protocols() {
+		for _, proto := range ps.protocols() {
 			supportedProtocols = append(supportedProtocols, ps.protoMatchFunc(proto))
 		}
 		// 定义 supportsProtocol 函数，检查协议是否被支持
@@ -69,7 +69,7 @@ func (ps *PubSub) watchForNewPeers(ctx context.Context) {
 		// 如果没有 protoMatchFunc，使用默认的支持协议集合
 		supportedProtocols := make(map[protocol.ID]struct{})
 		// 遍历每个协议，将其添加到 supportedProtocols 集合中
-		for _, proto := range ps.rt.Protocols() {
+		for _, proto := range ps.protocols() {
 			supportedProtocols[proto] = struct{}{}
 		}
 		// 定义 supportsProtocol 函数，检查协议是否在 supportedProtocols 集合中
@@ -171,7 +171,7 @@ func (ps *PubSub) NotifyNewPeer(peer peer.ID) error {
 	if ps.protoMatchFunc != nil {
 		// 如果存在自定义的协议匹配函数，使用它来构建支持的协议列表
 		var supportedProtocols []func(protocol.ID) bool
-		for _, proto := range ps.rt.Protocols() {
+		for _, proto := range ps.protocols() {
 			supportedProtocols = append(supportedProtocols, ps.protoMatchFunc(proto))
 		}
 
@@ -187,7 +187,7 @@ func (ps *PubSub) NotifyNewPeer(peer peer.ID) error {
 	} else {
 		// 如果没有自定义匹配函数，使用简单的协议ID匹配
 		supportedProtocols := make(map[protocol.ID]struct{})
-		for _, proto := range ps.rt.Protocols() {
+		for _, proto := range ps.protocols() {
 			supportedProtocols[proto] = struct{}{}
 		}
 
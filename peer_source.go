@@ -0,0 +1,101 @@
+// 作用：对等节点来源。
+// 功能：允许用静态列表、HTTP 端点或集合点服务器等任意来源代替 discovery.Discovery 为主题提供对等节点，
+// 复用发现子系统的引导、退避和连接逻辑。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/discovery"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// PeerSource 在主题的对等节点不足时提供候选对等节点。
+// 返回的通道在没有更多对等节点或 ctx 取消时必须关闭。
+// 参数:
+//   - ctx: 上下文
+//   - topic: 主题在发现服务中使用的命名空间，默认为主题名称
+//   - limit: 最多返回的对等节点数，为 0 时不限制
+//
+// 返回值:
+//   - <-chan peer.AddrInfo: 候选对等节点
+type PeerSource func(ctx context.Context, topic string, limit int) <-chan peer.AddrInfo
+
+// peerSourceAdvertiseInterval 是对等节点来源的空广告的重试间隔
+const peerSourceAdvertiseInterval = time.Hour
+
+// peerSourceDiscovery 将 PeerSource 适配为发现服务。对等节点来源不支持广告，广告不做任何事
+type peerSourceDiscovery struct {
+	src  PeerSource         // 对等节点来源
+	opts []discovery.Option // 查找选项
+}
+
+// Advertise 实现 discovery.Advertiser 接口
+func (d *peerSourceDiscovery) Advertise(ctx context.Context, ns string, opts ...discovery.Option) (time.Duration, error) {
+	return peerSourceAdvertiseInterval, nil
+}
+
+// FindPeers 实现 discovery.Discoverer 接口
+func (d *peerSourceDiscovery) FindPeers(ctx context.Context, ns string, opts ...discovery.Option) (<-chan peer.AddrInfo, error) {
+	var options discovery.Options
+	if err := options.Apply(append(opts, d.opts...)...); err != nil {
+		return nil, err
+	}
+	return d.src(ctx, ns, options.Limit), nil
+}
+
+// WithPeerSource 使用对等节点来源代替发现服务引导主题。
+// 对等节点来源与 WithDiscovery 互斥；发现选项（例如 WithDiscoveryBootstrap 和 WithDiscoverConnector）同样适用。
+// 参数:
+//   - src: 对等节点来源
+//   - opts: 可选的发现配置
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerSource(src PeerSource, opts ...DiscoverOpt) Option {
+	return func(p *PubSub) error {
+		if src == nil {
+			logger.Warnf("对等节点来源不能为空")
+			return fmt.Errorf("对等节点来源不能为空")
+		}
+		if p.disc.discovery != nil {
+			logger.Warnf("已配置发现服务")
+			return fmt.Errorf("已配置发现服务")
+		}
+
+		discoverOpts := defaultDiscoverOptions()
+		for _, opt := range opts {
+			if err := opt(discoverOpts); err != nil {
+				return err
+			}
+		}
+
+		p.disc.discovery = &peerSourceDiscovery{src: src, opts: discoverOpts.opts}
+		p.disc.options = discoverOpts
+		return nil
+	}
+}
+
+// StaticPeerSource 返回始终提供给定对等节点的对等节点来源
+// 参数:
+//   - peers: 对等节点
+//
+// 返回值:
+//   - PeerSource: 对等节点来源
+func StaticPeerSource(peers ...peer.AddrInfo) PeerSource {
+	return func(ctx context.Context, topic string, limit int) <-chan peer.AddrInfo {
+		n := len(peers)
+		if limit > 0 && limit < n {
+			n = limit
+		}
+		ch := make(chan peer.AddrInfo, n)
+		for _, pi := range peers[:n] {
+			ch <- pi
+		}
+		close(ch)
+		return ch
+	}
+}
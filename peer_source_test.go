@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestPeerSource 测试主题的对等节点不足时从对等节点来源获取并连接对等节点
+func TestPeerSource(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+
+	var mx sync.Mutex
+	var requested []string
+	static := StaticPeerSource(*host.InfoFromHost(hosts[1]))
+	src := func(ctx context.Context, topic string, limit int) <-chan peer.AddrInfo {
+		mx.Lock()
+		requested = append(requested, topic)
+		mx.Unlock()
+		return static(ctx, topic, limit)
+	}
+
+	ps0 := getPubsub(ctx, hosts[0], WithPeerSource(src, WithDiscoveryBootstrap(1, 50*time.Millisecond, nil)))
+	ps1 := getPubsub(ctx, hosts[1])
+	for i, ps := range []*PubSub{ps0, ps1} {
+		var opts []TopicOpt
+		if i == 0 {
+			opts = append(opts, WithTopicDiscovery(true, "ns"))
+		}
+		topic, err := ps.Join("source", opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ps0.ListPeers("source")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the topic to be bootstrapped from the peer source")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	mx.Lock()
+	if len(requested) == 0 || requested[0] != "ns" {
+		t.Fatalf("expected the peer source to be queried with the discovery namespace, got %v", requested)
+	}
+	mx.Unlock()
+
+	if _, err := NewFloodSub(ctx, hosts[0], WithDiscovery(&dummyDiscovery{}), WithPeerSource(src)); err == nil {
+		t.Fatal("expected error when combining a peer source with discovery")
+	}
+	if _, err := NewFloodSub(ctx, hosts[0], WithPeerSource(nil)); err == nil {
+		t.Fatal("expected error for nil peer source")
+	}
+}
+
+// TestStaticPeerSource 测试静态对等节点来源遵守数量限制
+func TestStaticPeerSource(t *testing.T) {
+	src := StaticPeerSource(peer.AddrInfo{ID: "a"}, peer.AddrInfo{ID: "b"}, peer.AddrInfo{ID: "c"})
+
+	count := func(limit int) int {
+		n := 0
+		for range src(context.Background(), "topic", limit) {
+			n++
+		}
+		return n
+	}
+	if n := count(0); n != 3 {
+		t.Fatalf("expected all peers without a limit, got %d", n)
+	}
+	if n := count(2); n != 2 {
+		t.Fatalf("expected 2 peers with limit 2, got %d", n)
+	}
+}
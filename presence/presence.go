@@ -0,0 +1,413 @@
+// 作用：基于 gossip 的在线成员服务。
+// 功能：在专用主题上周期性地发布签名的在线记录，按最近一次心跳维护成员列表，超过存活时间未收到心跳的成员被视为离线；
+// 离开时发布离开记录，使其他成员立即移除本节点。记录携带签名的发布时间，超过存活时间的记录被视为重放而忽略，
+// 因此成员之间的时钟偏差应小于存活时间。
+
+package presence
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/peer"
+	logging "github.com/dep2p/log"
+	"github.com/dep2p/pubsub"
+)
+
+var logger = logging.Logger("pubsub-presence")
+
+const (
+	// DefaultInterval 是发布在线记录的默认间隔
+	DefaultInterval = 10 * time.Second
+	// DefaultTimeoutFactor 是默认存活时间相对于心跳间隔的倍数
+	DefaultTimeoutFactor = 3
+
+	// recordPrefix 是在线记录签名的前缀
+	recordPrefix = "dep2p-pubsub-presence:"
+	// maxMetaSize 是成员元数据的最大字节数
+	maxMetaSize = 64 << 10
+	// maxMembers 是跟踪的成员数上限，达到上限后忽略新成员的记录
+	maxMembers = 4096
+)
+
+// ErrClosed 表示在线服务已经离开主题
+var ErrClosed = errors.New("在线服务已关闭")
+
+// Member 是一个在线成员
+type Member struct {
+	ID       peer.ID   // 成员的对等节点 ID
+	Meta     []byte    // 成员发布的元数据
+	LastSeen time.Time // 最近一次收到心跳的时间
+}
+
+// Option 是在线服务的配置选项
+type Option func(p *Presence) error
+
+// WithInterval 是一个选项，用于设置发布在线记录的间隔，默认为 DefaultInterval
+// 参数:
+//   - interval: 心跳间隔
+//
+// 返回值:
+//   - Option: 配置选项
+func WithInterval(interval time.Duration) Option {
+	return func(p *Presence) error {
+		if interval <= 0 {
+			logger.Warnf("心跳间隔必须为正数")
+			return fmt.Errorf("心跳间隔必须为正数")
+		}
+		p.interval = interval
+		return nil
+	}
+}
+
+// WithTimeout 是一个选项，用于设置成员的存活时间，超过该时间未收到心跳的成员被视为离线。
+// 默认为心跳间隔的 DefaultTimeoutFactor 倍。
+// 参数:
+//   - timeout: 存活时间
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Presence) error {
+		if timeout <= 0 {
+			logger.Warnf("成员存活时间必须为正数")
+			return fmt.Errorf("成员存活时间必须为正数")
+		}
+		p.timeout = timeout
+		return nil
+	}
+}
+
+// Presence 在一个主题上维护在线成员列表，由 Join 创建
+type Presence struct {
+	ps       *pubsub.PubSub
+	topic    *pubsub.Topic
+	sub      *pubsub.Subscription
+	self     peer.ID
+	key      crypto.PrivKey
+	interval time.Duration
+	timeout  time.Duration
+
+	ctx    context.Context
+	cancel func()
+	wg     sync.WaitGroup
+
+	mx      sync.Mutex
+	meta    []byte
+	seq     uint64
+	members map[peer.ID]*member
+	closed  bool
+}
+
+// member 是成员的内部状态
+type member struct {
+	meta     []byte
+	seq      uint64 // 最近一条记录的序号，用于忽略重放和乱序的记录
+	lastSeen time.Time
+	left     bool // 成员已发布离开记录，保留到超时以忽略之前的记录
+}
+
+// record 是在线记录的编码格式
+type record struct {
+	Peer  string `json:"peer"`            // 成员的对等节点 ID
+	Seq   uint64 `json:"seq"`             // 单调递增的序号
+	Time  int64  `json:"time"`            // 发布时间，Unix 纳秒
+	Meta  []byte `json:"meta,omitempty"`  // 元数据
+	Leave bool   `json:"leave,omitempty"` // 是否为离开记录
+	Sig   []byte `json:"sig"`             // 成员身份密钥的签名
+}
+
+// Join 加入在线主题并开始发布本节点的在线记录。主题应专用于在线服务，不能在同一 PubSub 上再次加入。
+// 参数:
+//   - ps: PubSub 实例
+//   - topic: 在线主题
+//   - meta: 本节点的元数据
+//   - opts: 配置选项
+//
+// 返回值:
+//   - *Presence: 在线服务
+//   - error: 错误信息，如果有的话
+func Join(ps *pubsub.PubSub, topic string, meta []byte, opts ...Option) (*Presence, error) {
+	if ps == nil {
+		logger.Warnf("PubSub 实例不能为空")
+		return nil, fmt.Errorf("PubSub 实例不能为空")
+	}
+	if len(meta) > maxMetaSize {
+		logger.Warnf("成员元数据过大: %d 字节", len(meta))
+		return nil, fmt.Errorf("成员元数据过大: %d 字节", len(meta))
+	}
+
+	h := ps.Host()
+	key := h.Peerstore().PrivKey(h.ID())
+	if key == nil {
+		logger.Warnf("无法获取主机 %s 的私钥", h.ID())
+		return nil, fmt.Errorf("无法获取主机 %s 的私钥", h.ID())
+	}
+
+	p := &Presence{
+		ps:       ps,
+		self:     h.ID(),
+		key:      key,
+		interval: DefaultInterval,
+		meta:     append([]byte(nil), meta...),
+		seq:      uint64(time.Now().UnixNano()), // 重启后序号仍然递增
+		members:  make(map[peer.ID]*member),
+	}
+	for _, opt := range opts {
+		if err := opt(p); err != nil {
+			return nil, err
+		}
+	}
+	if p.timeout == 0 {
+		p.timeout = DefaultTimeoutFactor * p.interval
+	}
+
+	t, err := ps.Join(topic)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := t.Subscribe()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	p.topic, p.sub = t, sub
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	p.wg.Add(2)
+	go p.readLoop()
+	go p.heartbeatLoop()
+	return p, nil
+}
+
+// Members 返回当前在线的成员，包括本节点，按对等节点 ID 排序
+// 返回值:
+//   - []Member: 在线成员
+func (p *Presence) Members() []Member {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	now := time.Now()
+	p.expire(now)
+	out := []Member{{ID: p.self, Meta: append([]byte(nil), p.meta...), LastSeen: now}}
+	for id, m := range p.members {
+		if m.left {
+			continue
+		}
+		out = append(out, Member{ID: id, Meta: append([]byte(nil), m.meta...), LastSeen: m.lastSeen})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SetMeta 更新本节点的元数据并立即发布
+// 参数:
+//   - ctx: 上下文
+//   - meta: 元数据
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *Presence) SetMeta(ctx context.Context, meta []byte) error {
+	if len(meta) > maxMetaSize {
+		logger.Warnf("成员元数据过大: %d 字节", len(meta))
+		return fmt.Errorf("成员元数据过大: %d 字节", len(meta))
+	}
+	p.mx.Lock()
+	p.meta = append([]byte(nil), meta...)
+	p.mx.Unlock()
+
+	return p.announce(ctx, false)
+}
+
+// Leave 发布离开记录并离开在线主题
+// 参数:
+//   - ctx: 上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *Presence) Leave(ctx context.Context) error {
+	err := p.announce(ctx, true)
+
+	p.mx.Lock()
+	if p.closed {
+		p.mx.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	p.mx.Unlock()
+
+	p.cancel()
+	p.sub.Cancel()
+	p.wg.Wait()
+	if cerr := p.topic.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// heartbeatLoop 周期性地发布在线记录
+func (p *Presence) heartbeatLoop() {
+	defer p.wg.Done()
+
+	if err := p.announce(p.ctx, false); err != nil {
+		logger.Debugf("发布在线记录失败: %s", err)
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.announce(p.ctx, false); err != nil {
+				logger.Debugf("发布在线记录失败: %s", err)
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// readLoop 读取其他成员的在线记录
+func (p *Presence) readLoop() {
+	defer p.wg.Done()
+
+	for {
+		msg, err := p.sub.Next(p.ctx)
+		if err != nil {
+			return
+		}
+		if msg.GetFrom() == p.self {
+			continue
+		}
+		if err := p.handle(msg.GetFrom(), msg.GetData(), time.Now()); err != nil {
+			logger.Debugf("忽略来自 %s 的在线记录: %s", msg.ReceivedFrom, err)
+		}
+	}
+}
+
+// announce 签名并发布本节点的在线记录
+// 参数:
+//   - ctx: 上下文
+//   - leave: 是否为离开记录
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *Presence) announce(ctx context.Context, leave bool) error {
+	p.mx.Lock()
+	if p.closed {
+		p.mx.Unlock()
+		return ErrClosed
+	}
+	p.seq++
+	rec := &record{Peer: p.self.String(), Seq: p.seq, Time: time.Now().UnixNano(), Meta: p.meta, Leave: leave}
+	p.mx.Unlock()
+
+	sig, err := p.key.Sign(signingBytes(rec))
+	if err != nil {
+		return err
+	}
+	rec.Sig = sig
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return p.topic.Publish(ctx, data)
+}
+
+// handle 验证并应用一条在线记录。记录必须由其成员自己发布，且发布时间在存活时间之内。
+// 参数:
+//   - from: 消息的发布者
+//   - data: 编码后的记录
+//   - now: 当前时间
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *Presence) handle(from peer.ID, data []byte, now time.Time) error {
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return err
+	}
+	if len(rec.Meta) > maxMetaSize {
+		return fmt.Errorf("成员元数据过大: %d 字节", len(rec.Meta))
+	}
+	id, err := peer.Decode(rec.Peer)
+	if err != nil {
+		return err
+	}
+	if id == p.self {
+		return fmt.Errorf("记录声称来自本节点")
+	}
+	if id != from {
+		return fmt.Errorf("记录的成员 %s 与发布者 %s 不一致", id, from)
+	}
+	if age := now.Sub(time.Unix(0, rec.Time)); age > p.timeout || age < -p.timeout {
+		return fmt.Errorf("记录的发布时间超出存活时间: %s", age)
+	}
+	pub, err := id.ExtractPublicKey() // 成员的对等节点 ID 必须内嵌公钥
+	if err != nil {
+		return fmt.Errorf("无法从 %s 提取公钥: %w", id, err)
+	}
+	if ok, err := pub.Verify(signingBytes(&rec), rec.Sig); err != nil || !ok {
+		return fmt.Errorf("无效的记录签名")
+	}
+
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	m, ok := p.members[id]
+	if ok && rec.Seq <= m.seq {
+		return nil // 重放或乱序的记录
+	}
+	if !ok {
+		p.expire(now)
+		if len(p.members) >= maxMembers {
+			return fmt.Errorf("成员数已达上限 %d", maxMembers)
+		}
+		m = &member{}
+		p.members[id] = m
+	}
+	m.meta = rec.Meta
+	m.seq = rec.Seq
+	m.lastSeen = now
+	m.left = rec.Leave
+	return nil
+}
+
+// expire 移除超过存活时间未收到心跳的成员。调用方必须持有 p.mx
+// 参数:
+//   - now: 当前时间
+func (p *Presence) expire(now time.Time) {
+	for id, m := range p.members {
+		if now.Sub(m.lastSeen) > p.timeout {
+			delete(p.members, id)
+		}
+	}
+}
+
+// signingBytes 返回在线记录签名的字节
+// 参数:
+//   - rec: 在线记录
+//
+// 返回值:
+//   - []byte: 签名字节
+func signingBytes(rec *record) []byte {
+	out := []byte(recordPrefix)
+	out = binary.AppendUvarint(out, uint64(len(rec.Peer)))
+	out = append(out, rec.Peer...)
+	out = binary.BigEndian.AppendUint64(out, rec.Seq)
+	out = binary.BigEndian.AppendUint64(out, uint64(rec.Time))
+	if rec.Leave {
+		out = append(out, 1)
+	} else {
+		out = append(out, 0)
+	}
+	return append(out, rec.Meta...)
+}
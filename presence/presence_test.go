@@ -0,0 +1,172 @@
+package presence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p"
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/pubsub"
+)
+
+// getNetwork 创建 n 个相连的 floodsub 节点
+func getNetwork(t *testing.T, ctx context.Context, n int) ([]host.Host, []*pubsub.PubSub) {
+	var hosts []host.Host
+	var psubs []*pubsub.PubSub
+	for i := 0; i < n; i++ {
+		h, err := dep2p.New(dep2p.ResourceManager(&network.NullResourceManager{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { h.Close() })
+		ps, err := pubsub.NewFloodSub(ctx, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, h)
+		psubs = append(psubs, ps)
+	}
+	for i := 1; i < n; i++ {
+		if err := hosts[i].Connect(ctx, hosts[0].Peerstore().PeerInfo(hosts[0].ID())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hosts, psubs
+}
+
+// waitMembers 等待成员列表满足条件
+func waitMembers(t *testing.T, p *Presence, check func([]Member) bool) []Member {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		members := p.Members()
+		if check(members) {
+			return members
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected members %v", members)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// TestPresence 测试成员加入、更新元数据、离开和超时
+func TestPresence(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, psubs := getNetwork(t, ctx, 3)
+	var members []*Presence
+	for i, ps := range psubs {
+		p, err := Join(ps, "presence", []byte{byte(i)}, WithInterval(50*time.Millisecond), WithTimeout(300*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, p)
+	}
+
+	all := waitMembers(t, members[0], func(m []Member) bool { return len(m) == 3 })
+	for _, m := range all {
+		for i, h := range hosts {
+			if m.ID == h.ID() && (len(m.Meta) != 1 || m.Meta[0] != byte(i)) {
+				t.Fatalf("unexpected meta %v for member %d", m.Meta, i)
+			}
+		}
+	}
+
+	// 更新元数据
+	if err := members[1].SetMeta(ctx, []byte("busy")); err != nil {
+		t.Fatal(err)
+	}
+	waitMembers(t, members[0], func(ms []Member) bool {
+		m, ok := find(ms, hosts[1].ID())
+		return ok && string(m.Meta) == "busy"
+	})
+
+	// 离开的成员立即被移除
+	if err := members[1].Leave(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitMembers(t, members[0], func(ms []Member) bool {
+		_, ok := find(ms, hosts[1].ID())
+		return !ok && len(ms) == 2
+	})
+
+	// 停止心跳的成员在超时后被移除
+	members[2].cancel()
+	waitMembers(t, members[0], func(ms []Member) bool { return len(ms) == 1 && ms[0].ID == hosts[0].ID() })
+}
+
+// find 按 ID 查找成员
+func find(members []Member, id peer.ID) (Member, bool) {
+	for _, m := range members {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+// signedRecord 返回由 key 签名的编码后的在线记录
+func signedRecord(t *testing.T, key crypto.PrivKey, seq uint64, at time.Time) (peer.ID, []byte) {
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &record{Peer: id.String(), Seq: seq, Time: at.UnixNano()}
+	if rec.Sig, err = key.Sign(signingBytes(rec)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, data
+}
+
+// TestPresenceReplay 测试成员过期后重放的旧记录和由其他节点发布的记录被忽略
+func TestPresenceReplay(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherID, err := peer.IDFromPrivateKey(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Presence{self: otherID, timeout: time.Second, members: make(map[peer.ID]*member)}
+	start := time.Now()
+	id, data := signedRecord(t, key, 1, start)
+
+	if err := p.handle(otherID, data, start); err == nil {
+		t.Fatal("expected a record relayed by another publisher to be rejected")
+	}
+	if err := p.handle(id, data, start); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.members[id]; !ok {
+		t.Fatal("expected the member to be added")
+	}
+
+	// 成员过期后重放同一条记录不能使其重新上线
+	later := start.Add(2 * time.Second)
+	p.mx.Lock()
+	p.expire(later)
+	p.mx.Unlock()
+	if err := p.handle(id, data, later); err == nil {
+		t.Fatal("expected a replayed record to be rejected after the member expired")
+	}
+	if _, ok := p.members[id]; ok {
+		t.Fatal("expected the replayed record not to resurrect the member")
+	}
+}
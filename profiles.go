@@ -0,0 +1,132 @@
+// 作用：按网络规模预设的配置。
+// 功能：DefaultOptions 针对只有两三个节点的网络调优，不适合大规模部署。本文件提供局域网、公网和移动网络的命名预设，
+// 以及按预计节点数选择预设的 OptionsForNetworkSize，统一设置网格度数、心跳间隔、跟随时间、Gossip 因子和评分阈值。
+
+package pubsub
+
+import (
+	"time"
+)
+
+// networkProfile 是一组与网络规模相关的配置
+type networkProfile struct {
+	d                 int                  // 网格理想度数
+	dlo               int                  // 网格度数下限
+	heartbeatInterval time.Duration        // 心跳间隔
+	followupTime      time.Duration        // IWANT 跟随时间
+	gossipFactor      float64              // Gossip 因子
+	maxPendingConns   int                  // 最大待处理连接数
+	thresholds        *PeerScoreThresholds // 评分阈值
+}
+
+// option 返回应用该预设的配置函数。每次应用都复制评分阈值，避免多个 Options 共享同一实例。
+// 返回值:
+//   - NodeOption: 配置函数
+func (np networkProfile) option() NodeOption {
+	return func(o *Options) error {
+		thresholds := *np.thresholds
+		o.D = np.d
+		o.Dlo = np.dlo
+		o.HeartbeatInterval = np.heartbeatInterval
+		o.FollowupTime = np.followupTime
+		o.GossipFactor = np.gossipFactor
+		o.MaxPendingConns = np.maxPendingConns
+		o.ScoreThresholds = &thresholds
+		return nil
+	}
+}
+
+var (
+	// lanProfile 适用于几十个节点的低延迟局域网：较小的网格和较短的心跳间隔，快速传播
+	lanProfile = networkProfile{
+		d:                 6,
+		dlo:               4,
+		heartbeatInterval: 500 * time.Millisecond,
+		followupTime:      time.Second,
+		gossipFactor:      0.25,
+		maxPendingConns:   32,
+		thresholds: &PeerScoreThresholds{
+			GossipThreshold:             -10,
+			PublishThreshold:            -50,
+			GraylistThreshold:           -80,
+			AcceptPXThreshold:           10,
+			OpportunisticGraftThreshold: 1,
+		},
+	}
+
+	// globalProfile 适用于数百到上千个节点的公网：使用 GossipSub 的标准参数，跟进时间取节点配置的上限
+	globalProfile = networkProfile{
+		d:                 GossipSubD,
+		dlo:               GossipSubDlo,
+		heartbeatInterval: GossipSubHeartbeatInterval,
+		followupTime:      maxNodeFollowupTime,
+		gossipFactor:      GossipSubGossipFactor,
+		maxPendingConns:   128,
+		thresholds: &PeerScoreThresholds{
+			GossipThreshold:             -500,
+			PublishThreshold:            -1000,
+			GraylistThreshold:           -2500,
+			AcceptPXThreshold:           100,
+			OpportunisticGraftThreshold: 5,
+		},
+	}
+
+	// largeProfile 适用于上千个节点以上的公网：更大的网格提高可靠性，更低的 Gossip 因子控制 gossip 流量
+	largeProfile = networkProfile{
+		d:                 8,
+		dlo:               6,
+		heartbeatInterval: GossipSubHeartbeatInterval,
+		followupTime:      maxNodeFollowupTime,
+		gossipFactor:      0.15,
+		maxPendingConns:   256,
+		thresholds:        globalProfile.thresholds,
+	}
+
+	// mobileProfile 适用于带宽和电量受限的移动网络：较小的网格、节点配置允许的最长心跳间隔和较少的 gossip
+	mobileProfile = networkProfile{
+		d:                 4,
+		dlo:               3,
+		heartbeatInterval: maxNodeHeartbeatInterval,
+		followupTime:      maxNodeFollowupTime,
+		gossipFactor:      0.1,
+		maxPendingConns:   16,
+		thresholds:        lanProfile.thresholds,
+	}
+)
+
+var (
+	// ProfileLAN 是低延迟局域网的预设配置
+	ProfileLAN NodeOption = lanProfile.option()
+	// ProfileGlobal 是公网的预设配置，使用 GossipSub 的标准网格参数
+	ProfileGlobal NodeOption = globalProfile.option()
+	// ProfileMobile 是带宽和电量受限的移动网络的预设配置
+	ProfileMobile NodeOption = mobileProfile.option()
+)
+
+// OptionsForNetworkSize 按预计的网络节点数返回预设配置，并记录网络节点数供 RandomSub 计算扇出：
+// 不超过 3 个节点时保持 DefaultOptions，不超过 50 个节点时使用 ProfileLAN，
+// 不超过 1000 个节点时使用 ProfileGlobal，更大的网络使用更大的网格和更低的 Gossip 因子。
+// 参数:
+//   - n: 预计的网络节点数
+//
+// 返回值:
+//   - NodeOption: 配置函数
+func OptionsForNetworkSize(n int) NodeOption {
+	var profile NodeOption
+	switch {
+	case n <= 3:
+		profile = func(*Options) error { return nil }
+	case n <= 50:
+		profile = lanProfile.option()
+	case n <= 1000:
+		profile = globalProfile.option()
+	default:
+		profile = largeProfile.option()
+	}
+	return func(o *Options) error {
+		if err := profile(o); err != nil {
+			return err
+		}
+		return WithSetNetworkSize(n)(o)
+	}
+}
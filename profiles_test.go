@@ -0,0 +1,60 @@
+package pubsub
+
+import (
+	"testing"
+)
+
+// TestProfiles 测试预设配置都能通过校验
+func TestProfiles(t *testing.T) {
+	for name, profile := range map[string]NodeOption{
+		"lan":    ProfileLAN,
+		"global": ProfileGlobal,
+		"mobile": ProfileMobile,
+	} {
+		opts := DefaultOptions()
+		if err := opts.ApplyOptions(profile); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("expected profile %s to be valid, got %s", name, err)
+		}
+		if opts.ScoreThresholds == nil {
+			t.Fatalf("expected profile %s to set score thresholds", name)
+		}
+	}
+
+	// 每次应用都复制评分阈值
+	a, b := DefaultOptions(), DefaultOptions()
+	if err := a.ApplyOptions(ProfileGlobal); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ApplyOptions(ProfileGlobal); err != nil {
+		t.Fatal(err)
+	}
+	a.ScoreThresholds.GossipThreshold = -1
+	if b.ScoreThresholds.GossipThreshold == -1 {
+		t.Fatal("expected profiles to copy score thresholds")
+	}
+}
+
+// TestOptionsForNetworkSize 测试按网络规模选择预设配置
+func TestOptionsForNetworkSize(t *testing.T) {
+	def := DefaultOptions()
+	prevD := 0
+	for _, n := range []int{2, 20, 500, 10000} {
+		opts := DefaultOptions()
+		if err := opts.ApplyOptions(OptionsForNetworkSize(n)); err != nil {
+			t.Fatal(err)
+		}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("expected options for %d nodes to be valid, got %s", n, err)
+		}
+		if n <= 3 && (opts.D != def.D || opts.ScoreThresholds != nil) {
+			t.Fatalf("expected default options for %d nodes", n)
+		}
+		if opts.D < prevD {
+			t.Fatalf("expected mesh degree to grow with network size, got %d after %d", opts.D, prevD)
+		}
+		prevD = opts.D
+	}
+}
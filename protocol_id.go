@@ -0,0 +1,72 @@
+// 作用：自定义协议 ID 前缀。
+// 功能：在路由器的每个协议 ID 前加上前缀，使私有网络运行在独立的协议字符串下，与公共网络互不相通。
+// 前缀同样作用于快照、追赶补发和反熵等辅助协议。前缀只作用于线路上协商的协议，路由器、追踪器和特性测试函数看到的仍是不带前缀的协议 ID，
+// 因此各个 gossipsub 版本之间的特性协商与不带前缀时完全一致。
+
+package pubsub
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// WithProtocolID 是一个选项，为路由器的所有协议 ID 加上前缀。
+// 例如前缀为 /myapp 时，gossipsub 在线路上协商 /myapp/meshsub/1.1.0、/myapp/meshsub/1.0.0 和 /myapp/floodsub/1.0.0，
+// 并按路由器的协议顺序选择双方都支持的最高版本。
+// 参数:
+//   - prefix: 协议 ID 前缀，必须以 / 开头且不能以 / 结尾
+//
+// 返回值:
+//   - Option: 配置选项
+func WithProtocolID(prefix protocol.ID) Option {
+	return func(ps *PubSub) error {
+		s := string(prefix)
+		if len(s) < 2 || !strings.HasPrefix(s, "/") || strings.HasSuffix(s, "/") {
+			logger.Warnf("无效的协议 ID 前缀: %q", s)
+			return fmt.Errorf("无效的协议 ID 前缀: %q", s)
+		}
+		ps.protoPrefix = prefix
+		return nil
+	}
+}
+
+// protocols 返回在线路上协商的协议 ID 列表，即加上前缀的路由器协议
+// 返回值:
+//   - []protocol.ID: 协议 ID 列表
+func (p *PubSub) protocols() []protocol.ID {
+	protos := p.rt.Protocols()
+	if p.protoPrefix == "" {
+		return protos
+	}
+
+	out := make([]protocol.ID, 0, len(protos))
+	for _, id := range protos {
+		out = append(out, p.prefixed(id))
+	}
+	return out
+}
+
+// prefixed 为协议 ID 加上配置的前缀，路由器协议和快照、追赶、反熵等辅助协议都经由此处
+// 参数:
+//   - id: 不带前缀的协议 ID
+//
+// 返回值:
+//   - protocol.ID: 线路上协商的协议 ID，未配置前缀时原样返回
+func (p *PubSub) prefixed(id protocol.ID) protocol.ID {
+	return p.protoPrefix + id
+}
+
+// routerProtocol 将线路上协商的协议 ID 还原为路由器使用的协议 ID
+// 参数:
+//   - id: 线路上的协议 ID
+//
+// 返回值:
+//   - protocol.ID: 去掉前缀的协议 ID，没有前缀时原样返回
+func (p *PubSub) routerProtocol(id protocol.ID) protocol.ID {
+	if p.protoPrefix == "" {
+		return id
+	}
+	return protocol.ID(strings.TrimPrefix(string(id), string(p.protoPrefix)))
+}
@@ -0,0 +1,87 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// TestProtocolIDPrefix 测试带前缀的节点之间按 gossipsub 版本协商，且不与不带前缀的节点通信
+func TestProtocolIDPrefix(t *testing.T) {
+	p := &PubSub{}
+	for _, prefix := range []protocol.ID{"", "/", "myapp", "/myapp/"} {
+		if err := WithProtocolID(prefix)(p); err == nil {
+			t.Fatalf("expected error for prefix %q", prefix)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	h := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getGossipsub(ctx, h[0], WithProtocolID("/myapp")),
+		getGossipsub(ctx, h[1], WithProtocolID("/myapp"), WithGossipSubProtocols([]protocol.ID{GossipSubID_v10}, GossipSubDefaultFeatures)),
+		getGossipsub(ctx, h[2]),
+	}
+	connect(t, h[0], h[1])
+	connect(t, h[0], h[2])
+
+	var subs []*Subscription
+	for _, ps := range psubs {
+		sub, err := ps.Subscribe("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+	time.Sleep(2 * time.Second)
+
+	// 路由器看到的是不带前缀的协商版本
+	protos := make(chan map[peer.ID]protocol.ID, 1)
+	psubs[0].eval <- func() {
+		gs := psubs[0].rt.(*GossipSubRouter)
+		m := make(map[peer.ID]protocol.ID)
+		for pid, proto := range gs.peers {
+			m[pid] = proto
+		}
+		protos <- m
+	}
+	peers := <-protos
+	if peers[h[1].ID()] != GossipSubID_v10 {
+		t.Fatalf("expected %s to negotiate %s, got %q", h[1].ID(), GossipSubID_v10, peers[h[1].ID()])
+	}
+	if _, ok := peers[h[2].ID()]; ok {
+		t.Fatalf("expected no pubsub stream to the unprefixed peer %s", h[2].ID())
+	}
+
+	topic, err := psubs[0].Join("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("message")
+	if err := topic.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, subs[1], msg)
+
+	// 辅助协议同样带前缀注册
+	registered := make(map[protocol.ID]bool)
+	for _, id := range h[0].Mux().Protocols() {
+		registered[id] = true
+	}
+	for _, id := range []protocol.ID{SnapshotID, CatchUpID, AntiEntropyID} {
+		if !registered["/myapp"+id] || registered[id] {
+			t.Fatalf("expected %s to be registered only under the /myapp prefix", id)
+		}
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, time.Second)
+	defer tcancel()
+	if _, err := subs[2].Next(tctx); err == nil {
+		t.Fatal("expected the unprefixed peer not to receive the message")
+	}
+}
@@ -0,0 +1,82 @@
+// 作用：按角色限制主题的发布者。
+// 功能：为单写者或白名单写者主题提供以对等节点 ID 配置授权发布者的接口。路由器在消息进入验证管道之前
+// 拒绝发布者不在白名单中的消息，并将其计为转发者的无效消息投递；限制了发布者的主题只接受签名的消息。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// WithTopicPublishers 是一个选项，限制主题只接受给定对等节点发布的签名消息，包括本节点转发的消息。
+// 只有一个发布者时即为单写者主题。
+// 参数:
+//   - topic: 主题
+//   - ids: 授权发布者
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTopicPublishers(topic string, ids ...peer.ID) Option {
+	return func(p *PubSub) error {
+		if len(ids) == 0 {
+			logger.Warnf("主题 %s 至少需要一个授权发布者", topic)
+			return fmt.Errorf("主题 %s 至少需要一个授权发布者", topic)
+		}
+		p.setTopicPublishers(topic, publisherSet(ids))
+		return nil
+	}
+}
+
+// SetPublishers 限制主题只接受给定对等节点发布的签名消息；不传入对等节点则取消限制
+// 参数:
+//   - ids: 授权发布者
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetPublishers(ids ...peer.ID) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	t.p.setTopicPublishers(t.topic, publisherSet(ids))
+	return nil
+}
+
+// Publishers 返回主题的授权发布者
+// 返回值:
+//   - []peer.ID: 授权发布者，未限制发布者时为 nil
+func (t *Topic) Publishers() []peer.ID {
+	t.p.topicPublishersMx.RLock()
+	defer t.p.topicPublishersMx.RUnlock()
+
+	set, ok := t.p.topicPublishers[t.topic]
+	if !ok {
+		return nil
+	}
+	ids := make([]peer.ID, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// publisherSet 将对等节点列表转换为发布者集合
+// 参数:
+//   - ids: 对等节点列表
+//
+// 返回值:
+//   - map[peer.ID]struct{}: 发布者集合，列表为空时为 nil
+func publisherSet(ids []peer.ID) map[peer.ID]struct{} {
+	if len(ids) == 0 {
+		return nil
+	}
+	set := make(map[peer.ID]struct{}, len(ids))
+	for _, id := range ids {
+		set[id] = struct{}{}
+	}
+	return set
+}
@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// rejectReasonTracer 记录消息被拒绝的原因
+type rejectReasonTracer struct {
+	reasons chan string
+}
+
+// Trace 实现 EventTracer 接口
+func (t *rejectReasonTracer) Trace(evt *pb.TraceEvent) {
+	if evt.GetType() == pb.TraceEvent_REJECT_MESSAGE {
+		select {
+		case t.reasons <- evt.GetRejectMessage().GetReason():
+		default:
+		}
+	}
+}
+
+// TestTopicPublishers 测试路由器拒绝发布者不在白名单中的消息
+func TestTopicPublishers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	tracer := &rejectReasonTracer{reasons: make(chan string, 16)}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1]),
+		getPubsub(ctx, hosts[2], WithTopicPublishers("foobar", hosts[0].ID()), WithEventTracer(tracer)),
+	}
+	connect(t, hosts[0], hosts[2])
+	connect(t, hosts[1], hosts[2])
+
+	topics := getTopics(psubs, "foobar")
+	sub, err := topics[2].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if ids := topics[2].Publishers(); len(ids) != 1 || ids[0] != hosts[0].ID() {
+		t.Fatalf("unexpected publishers %v", ids)
+	}
+
+	if err := topics[1].Publish(ctx, []byte("intruder")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-tracer.reasons:
+		if reason != RejectUnauthorizedPublisher {
+			t.Fatalf("expected rejection %q, got %q", RejectUnauthorizedPublisher, reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the message to be rejected")
+	}
+	assertNeverReceives(t, sub, 100*time.Millisecond)
+
+	if err := topics[0].Publish(ctx, []byte("writer")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("writer"))
+
+	// 切换为另一个单写者
+	if err := topics[2].SetPublishers(hosts[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, []byte("old writer")); err != nil {
+		t.Fatal(err)
+	}
+	assertNeverReceives(t, sub, 200*time.Millisecond)
+	if err := topics[1].Publish(ctx, []byte("new writer")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("new writer"))
+}
@@ -0,0 +1,59 @@
+// 作用：仅发布模式。
+// 功能：为只产生数据的接入网关等节点提供轻量模式：仅发布的主题只通过 fanout 发布消息，
+// 不能订阅或中继，因此节点从不加入这些主题的网格，也不转发其他节点的消息。
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPublisherOnly 在订阅或中继仅发布的主题时返回
+var ErrPublisherOnly = errors.New("主题处于仅发布模式，不能订阅或中继")
+
+// publisherOnly 记录仅发布的主题
+type publisherOnly struct {
+	all    bool                // 所有主题都仅发布
+	topics map[string]struct{} // 仅发布的主题
+}
+
+// WithPublisherOnly 是一个选项，将节点设置为给定主题的仅发布者；不指定主题时所有主题都仅发布。
+// 仅发布的主题不能订阅或中继，节点只通过 fanout 向其发布消息。
+// 参数:
+//   - topics: 仅发布的主题
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPublisherOnly(topics ...string) Option {
+	return func(ps *PubSub) error {
+		po := &publisherOnly{all: len(topics) == 0, topics: make(map[string]struct{}, len(topics))}
+		for _, topic := range topics {
+			if topic == "" {
+				logger.Warnf("仅发布的主题不能为空")
+				return fmt.Errorf("仅发布的主题不能为空")
+			}
+			po.topics[topic] = struct{}{}
+		}
+		ps.publisherOnly = po
+		return nil
+	}
+}
+
+// isPublisherOnly 返回主题是否处于仅发布模式
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - bool: 主题是否仅发布
+func (p *PubSub) isPublisherOnly(topic string) bool {
+	po := p.publisherOnly
+	if po == nil {
+		return false
+	}
+	if po.all {
+		return true
+	}
+	_, ok := po.topics[topic]
+	return ok
+}
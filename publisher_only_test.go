@@ -0,0 +1,51 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestPublisherOnly 测试仅发布的节点能够发布消息，但不能订阅或中继，也不加入网格
+func TestPublisherOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0], WithPublisherOnly("foo")),
+		getGossipsub(ctx, hosts[1]),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); !errors.Is(err, ErrPublisherOnly) {
+		t.Fatalf("expected ErrPublisherOnly from Subscribe, got %v", err)
+	}
+	if _, err := topic.Relay(); !errors.Is(err, ErrPublisherOnly) {
+		t.Fatalf("expected ErrPublisherOnly from Relay, got %v", err)
+	}
+	if _, err := psubs[0].Subscribe("bar"); err != nil {
+		t.Fatalf("expected other topics to remain subscribable, got %v", err)
+	}
+
+	msg := []byte("hello")
+	if err := topic.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+
+	if peers := psubs[0].MeshPeers("foo"); len(peers) != 0 {
+		t.Fatalf("expected the publisher not to join the mesh, got %v", peers)
+	}
+}
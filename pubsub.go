@@ -5,6 +5,7 @@ package pubsub
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -17,7 +18,6 @@ import (
 
 	pb "github.com/dep2p/pubsub/pb"
 
-	"github.com/dep2p/go-dep2p/core/crypto"
 	"github.com/dep2p/go-dep2p/core/discovery"
 	"github.com/dep2p/go-dep2p/core/host"
 	"github.com/dep2p/go-dep2p/core/network"
@@ -74,6 +74,37 @@ type PubSub struct {
 	// 最大消息大小，全局适用于所有主题
 	maxMessageSize int // 允许的最大消息大小，适用于所有主题，防止消息过大导致的资源浪费或攻击
 
+	// 单个 RPC 帧的最大序列化大小，为 0 时与最大消息大小相同
+	maxTransmissionSize int
+
+	// 出站带宽整形，为 nil 时不限制
+	bandwidth *bandwidthShaper
+
+	// 出站流的空闲关闭与重连策略，零值保持流常开且不重试
+	streamPolicy streamPolicy
+
+	// 仅发布的主题，为 nil 时所有主题都可以订阅
+	publisherOnly *publisherOnly
+
+	// 消息的跳数和存活时间限制
+	ttl messageTTL
+
+	// 按主题的重复交付统计
+	dupStats *dupStats
+
+	// 对等节点行为审计日志
+	audit *peerAudit
+
+	// 允许交换消息的对等节点，为 nil 时允许所有对等节点
+	allowlist PeerAllowlist
+
+	// 启动时中继的主题
+	relayOnly []string
+
+	// 消息头部的限制
+	maxHeaders     int // 允许的最大头部条目数
+	maxHeadersSize int // 允许的头部键值总字节数
+
 	// 每个对等节点的出站消息队列大小
 	peerOutboundQueueSize int // 每个对等节点的出站消息队列大小，控制消息的并发发送量
 
@@ -130,11 +161,19 @@ type PubSub struct {
 	// 我们中继的主题集合
 	myRelays map[string]int // 当前节点中继的主题集合，用于管理消息中继
 
+	// 主题模式订阅
+	patterns map[*PatternSubscription]struct{} // 按主题名称模式自动加入匹配主题的订阅
+
 	// 我们感兴趣的主题集合
 	myTopics map[string]*Topic // 当前节点感兴趣的主题集合，用于跟踪关注的主题
 
+	nsMx       sync.RWMutex          // 保护 namespaces
+	namespaces map[string]*Namespace // 已创建的命名空间
+
 	// 跟踪每个对等节点订阅的主题
 	topics map[string]map[peer.ID]struct{} // 跟踪每个对等节点订阅的主题，用于管理对等节点的订阅情况
+	// 每个对等节点被跟踪的订阅数量
+	peerSubs map[peer.ID]int // 用于执行订阅过滤器的每个对等节点订阅上限
 
 	// 处理已验证的消息
 	sendMsg chan *Message // 处理已验证消息的通道，用于发送经过验证的消息
@@ -161,18 +200,62 @@ type PubSub struct {
 	inboundStreams map[peer.ID]network.Stream // 入站流集合，用于记录每个对等节点的入站流
 
 	// 已见消息缓存
-	seenMessages timecache.TimeCache // 已见消息缓存，用于存储已处理过的消息，防止重复处理
+	seenMessages SeenCache // 已见消息缓存，用于存储已处理过的消息，防止重复处理
+
+	// 内存预算
+	memBudget       *memoryBudget // 共享内存预算，为 nil 时不限制
+	memSeenCapacity int           // 内存预算划分给已见消息缓存的条目数
+	// 按主题的重复消息容忍度
+	dupTolerance *duplicateTolerance // 为 nil 时不容忍任何重复
+
+	// 从网络收到的自源消息的处理策略
+	selfOriginPolicy  SelfOriginPolicy     // 处理策略
+	selfOriginHandler SelfOriginHandler    // 异常事件处理函数
+	selfOriginQueue   chan SelfOriginEvent // 等待处理的异常事件，没有处理函数时为 nil
+
+	// 每个对等节点的入站速率限制
+	rateLimit *peerRateLimiter // 为 nil 时不限制
+
+	// 运行时停止的子系统
+	stoppedSubsystems map[Subsystem]struct{}
+
+	// 发送者匿名模式
+	anonymous   bool          // 发布的消息是否省略来源并使用随机序列号
+	mixingDelay time.Duration // 发布和转发消息前的最大随机延迟，为 0 时不延迟
+
+	// Dandelion 茎阶段的消息
+	stems map[string]*Message // 沿茎转发后尚未看到被传播的消息
+
+	// OpenTelemetry 追踪上下文传播
+	otel *otelTracer // 为 nil 时不传播追踪上下文
+
+	// 诊断
+	goroutines     goroutineCounters // 每个对等节点的 goroutine 计数
+	stallThreshold time.Duration     // 内部循环停滞的警告阈值，为 0 时不检测
+
+	// 主题级准入控制
+	topicAuth        map[string]TopicAuthorizer // 每个主题的授权函数
+	topicAuthPenalty int                        // 未授权的 SUBSCRIBE 或 GRAFT 计入的行为惩罚次数
 	// 已见消息缓存的存活时间
 	seenMsgTTL time.Duration // 已见消息缓存的存活时间，用于控制消息缓存的有效期
 	// 已见消息缓存的策略
 	seenMsgStrategy timecache.Strategy // 已见消息缓存的策略，用于定义消息缓存的行为
+	// 持久化已见消息缓存的日志文件路径
+	seenCachePath string // 为空时使用内存中的已见消息缓存
+
+	// 反熵同步参数
+	antiEntropy       *antiEntropyParams // 为 nil 时不启用周期同步
+	antiEntropyFPRate float64            // 布隆过滤器摘要的假阳性率，为 0 时发送完整的消息 ID 列表
+
+	// 主题发现重试和对等节点重新连接的退避策略
+	backoffStrategy BackoffStrategy // 为 nil 时使用各自的默认重试节奏
 
 	// 用于生成消息 ID 的生成器
 	idGen *msgIDGenerator // 消息 ID 生成器，用于生成唯一的消息标识符
 
-	// 用于签名消息的密钥，如果签名被禁用则为 nil
-	signKey crypto.PrivKey // 签名消息的私钥，用于对消息进行签名，确保消息的真实性
-	// 签名消息的源 ID，对应于 signKey，如果签名被禁用则为空。
+	// 用于签名消息的签名者，如果签名被禁用则为 nil
+	signer MessageSigner // 签名者，默认为主机 peerstore 中的私钥，也可以是硬件或远程签名者
+	// 签名消息的源 ID，对应于 signer，如果签名被禁用则为空。
 	// 如果为空，则消息中完全省略作者和序列号。
 	signID peer.ID // 签名消息的对等节点 ID，用于标识消息的签名者
 	// 严格模式在验证之前拒绝所有未签名的消息
@@ -184,22 +267,66 @@ type PubSub struct {
 	// 协议选择的匹配函数
 	protoMatchFunc ProtocolMatchFn // 协议选择的匹配函数，用于选择合适的协议处理消息
 
+	// 线路上协议 ID 的前缀，为空时使用路由器的协议 ID
+	protoPrefix protocol.ID
+
 	// 上下文，用于控制发布-订阅系统的生命周期
 	ctx context.Context // 发布-订阅系统的上下文，用于控制系统的生命周期
 
+	// 优雅关闭
+	cancel   context.CancelFunc // 取消发布-订阅系统的上下文
+	closing  atomic.Bool        // 是否已经开始关闭
+	loopDone chan struct{}      // 事件循环退出后关闭
+
 	// 应用程序特定的 RPC 检查器，用于在处理之前检查传入的 RPC。
 	// 检查器函数返回一个错误，指示是否应处理 RPC。
 	// 如果错误为 nil，则正常处理 RPC。如果错误为非 nil，则丢弃 RPC。
 	appSpecificRpcInspector func(peer.ID, *RPC) error // 应用程序特定的 RPC 检查器，用于在处理之前检查传入的 RPC
 
+	peerHooks peerHooks // 对等节点生命周期回调
+
 	// 回复通道保护锁
 	repliesMx sync.Mutex // 锁，用于保护 replies 的并发访问
 	// 保存每个消息 ID 对应的回复通道
-	replies map[string]chan []byte
+	replies map[string]chan *Message
 	// 等待回复的超时时间
 	timeout time.Duration
 	// 消息发送的重试次数
 	retry int
+
+	// 确认通道保护锁
+	acksMx sync.Mutex
+	// 保存每个可靠消息 ID 对应的确认通道
+	acks map[string]chan peer.ID
+	// 等待发布的可靠消息确认及其保护锁
+	ackQueueMx sync.Mutex
+	ackQueue   []ackRequest
+	ackQueued  map[string]struct{} // ackQueue 中的可靠消息 ID
+	ackSignal  chan struct{}       // 唤醒确认协程
+	// 已投递给本地订阅者的可靠消息 ID
+	reliableSeen *timecache.BoundedCache
+
+	// 消息存储，用于保留已加入主题的消息并提供追赶补发；如果为 nil，则不保存
+	store      MessageStore
+	storeQueue chan *StoredMessage // 等待写入消息存储的消息，由 storeLoop 在事件循环之外写入
+
+	// 幂等令牌日志，用于过滤已提交令牌的重复投递；如果为 nil，则不过滤
+	tokenJournal TokenJournal
+
+	// 快照提供者保护锁
+	snapshotMx sync.RWMutex
+	// 每个主题的快照提供者
+	snapshotProviders map[string]SnapshotProvider
+
+	// 密钥提供者保护锁
+	keyProvidersMx sync.RWMutex
+	// 每个加密主题的密钥提供者
+	keyProviders map[string]KeyProvider
+
+	// 授权发布者保护锁
+	topicPublishersMx sync.RWMutex
+	// 每个主题授权的发布者，由发布者公钥得出
+	topicPublishers map[string]map[peer.ID]struct{}
 }
 
 // PubSubRouter 是 PubSub 的消息路由组件
@@ -260,6 +387,17 @@ type Message struct {
 	ReceivedFrom  peer.ID     // 发送该消息的节点ID
 	ValidatorData interface{} // 验证器相关数据，可能包含验证消息的元数据
 	Local         bool        // 指示消息是否是本地生成的
+
+	annotations        *messageAnnotations // 验证器附加的本地注解，通过 annots 访问
+	toleratedDuplicate bool                // 重复交付是否在主题的容忍度之内
+	receivedPath       string              // 接收该消息的连接的远端地址
+	stem               bool                // 消息是否通过 Dandelion 茎阶段收到
+	catchUp            bool                // 消息是否通过追赶补发收到，不再转发
+	loopback           bool                // 本地发布的消息是否投递给本节点的订阅者
+	receivedProto      protocol.ID         // 收到该消息时对等节点使用的路由器协议
+	receivedAt         time.Time           // 收到该消息的时间
+	tokenCommitted     bool                // 消息携带的幂等令牌是否已提交，由验证管道查询
+	shard              erasureShard        // 本地发布的纠删码分片，路由器只发给一部分首跳对等节点
 }
 
 // GetFrom 获取消息的发送者
@@ -275,6 +413,21 @@ type RPC struct {
 
 	// from 是发送此消息的 peer ID，不会通过网络发送
 	from peer.ID
+
+	// budgeted 表示 RPC 入队时占用了内存预算，出队时需要释放
+	budgeted bool
+
+	// path 是接收此 RPC 的连接的远端地址，不会通过网络发送
+	path string
+
+	// proto 是接收此 RPC 的流的路由器协议，不会通过网络发送
+	proto protocol.ID
+
+	// received 是读取此 RPC 的时间，不会通过网络发送
+	received time.Time
+
+	// unshaped 表示 RPC 中的消息不受出站带宽整形限制（本地发布的消息或 IWANT 回复），不会通过网络发送
+	unshaped bool
 }
 
 // Option 是用于配置 PubSub 的选项函数类型
@@ -291,18 +444,25 @@ type Option func(*PubSub) error
 //   - *PubSub: 新的 PubSub 对象。
 //   - error: 如果有错误发生，返回错误。
 func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option) (*PubSub, error) {
+	// 派生可由 Close 取消的上下文
+	ctx, cancel := context.WithCancel(ctx)
+
 	// 初始化 PubSub 对象
 	ps := &PubSub{
 		host:                  h,                                                                 // dep2p 主机
 		ctx:                   ctx,                                                               // 上下文
+		cancel:                cancel,                                                            // 取消上下文
+		loopDone:              make(chan struct{}),                                               // 事件循环退出通知
 		rt:                    rt,                                                                // 路由器
 		val:                   newValidation(),                                                   // 验证模块
 		peerFilter:            DefaultPeerFilter,                                                 // 默认的 peer 过滤器
 		disc:                  &discover{},                                                       // 发现模块
 		maxMessageSize:        DefaultMaxMessageSize,                                             // 最大消息大小
+		maxHeaders:            DefaultMaxHeaders,                                                 // 最大头部条目数
+		maxHeadersSize:        DefaultMaxHeadersSize,                                             // 最大头部大小
 		peerOutboundQueueSize: 32,                                                                // 出站消息队列大小
 		signID:                h.ID(),                                                            // 签名 ID
-		signKey:               nil,                                                               // 签名密钥
+		signer:                nil,                                                               // 签名者
 		signPolicy:            StrictSign,                                                        // 签名策略
 		incoming:              make(chan *RPC, 32),                                               // 传入消息通道
 		newPeers:              make(chan struct{}, 1),                                            // 新 peer 通知通道
@@ -327,7 +487,12 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		myTopics:              make(map[string]*Topic),                                           // 我们感兴趣的主题
 		mySubs:                make(map[string]map[*Subscription]struct{}),                       // 我们的订阅
 		myRelays:              make(map[string]int),                                              // 我们的中继
+		patterns:              make(map[*PatternSubscription]struct{}),                           // 主题模式订阅
 		topics:                make(map[string]map[peer.ID]struct{}),                             // 主题到 peer 的映射
+		peerSubs:              make(map[peer.ID]int),                                             // 每个 peer 的订阅数量
+		stoppedSubsystems:     make(map[Subsystem]struct{}),                                      // 运行时停止的子系统
+		stems:                 make(map[string]*Message),                                         // Dandelion 茎阶段的消息
+		topicAuth:             make(map[string]TopicAuthorizer),                                  // 每个主题的授权函数
 		peers:                 make(map[peer.ID]chan *RPC),                                       // peer 到 RPC 通道的映射
 		inboundStreams:        make(map[peer.ID]network.Stream),                                  // inbound 流
 		blacklist:             NewMapBlacklist(),                                                 // 黑名单
@@ -336,11 +501,30 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		seenMsgStrategy:       TimeCacheStrategy,                                                 // 已看到消息的策略
 		idGen:                 newMsgIdGenerator(),                                               // 消息 ID 生成器
 		counter:               uint64(time.Now().UnixNano()),                                     // 计数器
-		replies:               make(map[string]chan []byte),                                      // 保存每个消息 ID 对应的回复通道
+		replies:               make(map[string]chan *Message),                                    // 保存每个消息 ID 对应的回复通道
 		timeout:               30 * time.Second,                                                  // 等待回复的超时时间
 		retry:                 3,                                                                 // 消息发送的重试次数
+		acks:                  make(map[string]chan peer.ID),                                     // 保存每个可靠消息 ID 对应的确认通道
+		ackQueued:             make(map[string]struct{}),                                         // 等待发布的可靠消息确认
+		ackSignal:             make(chan struct{}, 1),                                            // 唤醒确认协程
+		snapshotProviders:     make(map[string]SnapshotProvider),                                 // 每个主题的快照提供者
+		keyProviders:          make(map[string]KeyProvider),                                      // 每个加密主题的密钥提供者
+		topicPublishers:       make(map[string]map[peer.ID]struct{}),                             // 每个主题授权的发布者
+		audit:                 newPeerAudit(h.ID()),                                              // 对等节点行为审计日志
 	}
 
+	// 构造失败时取消上下文；事件循环启动之前还需停止自行创建的已见消息缓存，之后由事件循环退出时停止
+	var ok, ownSeen bool
+	defer func() {
+		if ok {
+			return
+		}
+		cancel()
+		if ownSeen {
+			ps.seenMessages.Done()
+		}
+	}()
+
 	// 应用所有选项配置
 	for _, opt := range opts {
 		err := opt(ps)
@@ -350,22 +534,65 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		}
 	}
 
+	// 挂钩重复交付统计和对等节点审计日志
+	ps.dupStats = newDupStats(h.ID())
+	if ps.tracer != nil {
+		ps.tracer.addInternalRaw(ps.dupStats, ps.audit)
+	} else {
+		ps.tracer = &pubsubTracer{
+			raw:      []RawTracer{ps.dupStats, ps.audit},
+			internal: []RawTracer{ps.dupStats, ps.audit},
+			pid:      h.ID(),
+			idGen:    ps.idGen,
+		}
+	}
+
 	// 检查签名策略是否必须签名
 	if ps.signPolicy.mustSign() {
 		// 如果签名策略要求消息必须签名，但签名 ID 未设置，则返回错误
 		if ps.signID == "" {
 			return nil, fmt.Errorf("strict signature usage enabled but message author was disabled")
 		}
-		// 从主机的 Peerstore 中获取对应签名 ID 的私钥
-		ps.signKey = ps.host.Peerstore().PrivKey(ps.signID)
-		if ps.signKey == nil {
-			// 如果无法获取签名私钥，则返回错误
-			return nil, fmt.Errorf("can't sign for peer %s: no private key", ps.signID)
+		if ps.signer == nil {
+			// 从主机的 Peerstore 中获取对应签名 ID 的私钥
+			key := ps.host.Peerstore().PrivKey(ps.signID)
+			if key == nil {
+				// 如果无法获取签名私钥，则返回错误
+				return nil, fmt.Errorf("can't sign for peer %s: no private key", ps.signID)
+			}
+			ps.signer = key
+		} else if err := checkSignerPublicKey(ps.signID, ps.signer); err != nil {
+			// 如果无法为外部签名者确定公钥，则返回错误
+			return nil, err
 		}
+	} else if ps.signer != nil {
+		// 在 WithMessageSigner 之后应用的选项关闭了签名，签名者会泄露已关闭的身份
+		logger.Warnf("签名策略不要求签名时不能设置消息签名者")
+		return nil, fmt.Errorf("签名策略不要求签名时不能设置消息签名者")
+	}
+
+	if err := ps.bandwidth.check(ps.maxMessageSize); err != nil {
+		return nil, err
+	}
+	if ps.antiEntropy != nil && ps.store == nil {
+		logger.Warnf("WithAntiEntropy 需要通过 WithMessageStore 配置消息存储")
+		return nil, fmt.Errorf("WithAntiEntropy 需要通过 WithMessageStore 配置消息存储")
 	}
 
-	// 初始化已看到消息的缓存
-	ps.seenMessages = timecache.NewTimeCacheWithStrategy(ps.seenMsgStrategy, ps.seenMsgTTL)
+	// 初始化已看到消息的缓存，除非通过 WithSeenCache 提供了自定义实现
+	if ps.seenMessages == nil {
+		cache, err := ps.newSeenCache()
+		if err != nil {
+			return nil, err
+		}
+		ps.seenMessages = cache
+		ownSeen = true
+	} else if ps.seenCachePath != "" {
+		logger.Warnf("WithPersistentSeenCache 不能与 WithSeenCache 同时使用")
+		return nil, fmt.Errorf("WithPersistentSeenCache 不能与 WithSeenCache 同时使用")
+	}
+	// 初始化已投递的可靠消息 ID 的缓存
+	ps.reliableSeen = timecache.NewBoundedTimeCache(timecache.Strategy_FirstSeen, reliableSeenTTL, reliableSeenCapacity)
 
 	// 启动发现模块
 	if err := ps.disc.Start(ps); err != nil {
@@ -377,7 +604,7 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 	rt.Attach(ps)
 
 	// 设置流处理器
-	for _, id := range rt.Protocols() {
+	for _, id := range ps.protocols() {
 		// 如果设置了协议匹配函数，使用该函数设置流处理器
 		if ps.protoMatchFunc != nil {
 			h.SetStreamHandlerMatch(id, ps.protoMatchFunc(id), ps.handleNewStream) // SetStreamHandlerMatch 允许使用自定义匹配函数，在协议 ID 匹配的基础上，进一步筛选是否应用处理器。
@@ -403,6 +630,15 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 		}
 	}
 
+	// 设置主题快照流处理器
+	h.SetStreamHandler(ps.prefixed(SnapshotID), ps.handleSnapshotStream)
+
+	// 设置追赶补发流处理器
+	h.SetStreamHandler(ps.prefixed(CatchUpID), ps.handleCatchUpStream)
+
+	// 设置反熵摘要交换流处理器
+	h.SetStreamHandler(ps.prefixed(AntiEntropyID), ps.handleAntiEntropyStream)
+
 	// 监视新 peer
 	go ps.watchForNewPeers(ctx)
 
@@ -411,7 +647,42 @@ func NewPubSub(ctx context.Context, h host.Host, rt PubSubRouter, opts ...Option
 
 	// 启动处理循环
 	go ps.processLoop(ctx)
+	ownSeen = false // 事件循环退出时停止已见消息缓存
+
+	// 启动确认发布协程
+	go ps.ackLoop()
+
+	// 启动消息存储写入协程
+	if ps.store != nil {
+		go ps.storeLoop()
+	}
+
+	// 启动自源消息异常事件处理协程
+	if ps.selfOriginQueue != nil {
+		go ps.selfOriginLoop()
+	}
+
+	// 检测内部循环停滞
+	if ps.stallThreshold > 0 {
+		go ps.watchStalls()
+	}
+
+	// 启动反熵同步
+	if ps.antiEntropy != nil {
+		go ps.antiEntropyLoop()
+	}
+
+	// 清理过期的黑名单条目
+	if b, ok := ps.blacklist.(*DecayingBlacklist); ok {
+		go ps.decayBlacklistLoop(b)
+	}
+
+	// 中继通过 WithRelayOnly 配置的主题
+	if err := ps.startRelays(); err != nil {
+		return nil, err
+	}
 
+	ok = true
 	return ps, nil
 }
 
@@ -437,6 +708,30 @@ func WithMessageIdFn(fn MsgIdFunction) Option {
 	}
 }
 
+// WithMessageIdFnForTopic 是一个选项，用于为特定主题自定义消息 ID 的计算方式，其余主题仍使用全局的消息 ID 函数。
+// 与主题选项 WithTopicMessageIdFn 不同，它在创建 PubSub 时生效，因此在加入主题之前收到的消息也会使用该函数去重，
+// 适用于桥接去重语义不同的协议（例如有的主题按内容哈希去重，有的按 (seqno, from) 去重）。
+// 参数:
+//   - topic: 主题。
+//   - fn: 自定义的消息 ID 函数。
+//
+// 返回值:
+//   - Option: 配置选项。
+func WithMessageIdFnForTopic(topic string, fn MsgIdFunction) Option {
+	return func(p *PubSub) error {
+		if topic == "" {
+			logger.Warnf("主题不能为空")
+			return fmt.Errorf("主题不能为空")
+		}
+		if fn == nil {
+			logger.Warnf("消息 ID 函数不能为空")
+			return fmt.Errorf("消息 ID 函数不能为空")
+		}
+		p.idGen.Set(topic, fn)
+		return nil
+	}
+}
+
 // PeerFilter 用于过滤 pubsub peers。对于给定的主题，它应该返回 true 表示接受。
 // 参数:
 //   - pid: peer 的 ID。
@@ -578,6 +873,11 @@ func WithBlacklist(b Blacklist) Option {
 //   - Option: 配置选项。
 func WithDiscovery(d discovery.Discovery, opts ...DiscoverOpt) Option {
 	return func(p *PubSub) error {
+		if p.disc.discovery != nil {
+			logger.Warnf("已配置发现服务")
+			return fmt.Errorf("已配置发现服务")
+		}
+
 		discoverOpts := defaultDiscoverOptions()
 		for _, opt := range opts {
 			err := opt(discoverOpts)
@@ -610,14 +910,25 @@ func WithEventTracer(tracer EventTracer) Option {
 }
 
 // WithRawTracer 添加一个原始追踪器到 pubsub 系统。
-// 可以使用多次调用选项添加多个追踪器。
+// 可以使用多次调用选项添加多个追踪器，每个追踪器可以通过 FilterEvents 只接收部分事件。
 // 参数:
 //   - tracer: 原始追踪器。
+//   - opts: 追踪器选项。
 //
 // 返回值:
 //   - Option: 配置选项。
-func WithRawTracer(tracer RawTracer) Option {
+func WithRawTracer(tracer RawTracer, opts ...RawTracerOpt) Option {
 	return func(p *PubSub) error {
+		var cfg rawTracerOptions
+		for _, opt := range opts {
+			if err := opt(&cfg); err != nil {
+				return err
+			}
+		}
+		if cfg.events != 0 {
+			tracer = &filteredTracer{tracer: tracer, events: cfg.events}
+		}
+
 		if p.tracer != nil {
 			p.tracer.raw = append(p.tracer.raw, tracer)
 		} else {
@@ -627,6 +938,29 @@ func WithRawTracer(tracer RawTracer) Option {
 	}
 }
 
+// withInternalRawTracer 添加一个内部使用的原始追踪器（例如连接管理器标记和追踪上下文传播），
+// 它不受 SubsystemMetrics 开关的影响。
+// 参数:
+//   - tracer: 原始追踪器。
+//
+// 返回值:
+//   - Option: 配置选项。
+func withInternalRawTracer(tracer RawTracer) Option {
+	return func(p *PubSub) error {
+		if p.tracer != nil {
+			p.tracer.addInternalRaw(tracer)
+		} else {
+			p.tracer = &pubsubTracer{
+				raw:      []RawTracer{tracer},
+				internal: []RawTracer{tracer},
+				pid:      p.host.ID(),
+				idGen:    p.idGen,
+			}
+		}
+		return nil
+	}
+}
+
 // WithMaxMessageSize 设置 pubsub 消息的全局最大消息大小。默认值是 1MiB (DefaultMaxMessageSize)。
 // 警告 #1：确保更改 floodsub (FloodSubID) 和 gossipsub (GossipSubID) 的默认协议前缀。
 // 警告 #2：减少默认的最大消息限制是可以的，但要确保您的应用程序消息不会超过新的限制。
@@ -681,6 +1015,30 @@ func WithSeenMessagesStrategy(strategy timecache.Strategy) Option {
 	}
 }
 
+// SeenCache 是已见消息缓存的接口，用于消息去重。
+// 可以通过 WithSeenCache 替换为有界内存的 ARC/LRU 缓存（例如 timecache.NewBoundedTimeCache），
+// 或多进程网关共享的外部缓存（例如 Redis）。实现必须是并发安全的。
+type SeenCache = timecache.TimeCache
+
+// WithSeenCache 使用自定义的已见消息缓存代替默认实现。
+// 设置后 WithSeenMessagesTTL 和 WithSeenMessagesStrategy 不再生效，过期策略由缓存实现决定；
+// PubSub 停止时会调用缓存的 Done 方法。
+// 参数:
+//   - cache: 已见消息缓存。
+//
+// 返回值:
+//   - Option: 配置选项。
+func WithSeenCache(cache SeenCache) Option {
+	return func(ps *PubSub) error {
+		if cache == nil {
+			logger.Warnf("已见消息缓存不能为空")
+			return fmt.Errorf("已见消息缓存不能为空")
+		}
+		ps.seenMessages = cache
+		return nil
+	}
+}
+
 // WithAppSpecificRpcInspector 设置一个钩子，用于在处理传入的 RPC 之前检查它们。
 // 检查器在处理已接受的 RPC 之前调用。如果检查器的错误为 nil，则按常规处理 RPC。否则，RPC 将被丢弃。
 // 参数:
@@ -733,6 +1091,7 @@ func (p *PubSub) processLoop(ctx context.Context) {
 		p.peers = nil         // 清空 peers
 		p.topics = nil        // 清空 topics
 		p.seenMessages.Done() // 标记 seenMessages 完成
+		close(p.loopDone)     // 通知事件循环已退出
 	}()
 
 	for { // 处理所有到达通道的输入
@@ -758,7 +1117,7 @@ func (p *PubSub) processLoop(ctx context.Context) {
 				continue
 			}
 
-			p.rt.AddPeer(pid, s.Protocol()) // 添加 peer 到路由器
+			p.addPeer(pid, p.routerProtocol(s.Protocol())) // 添加 peer 到路由器，路由器使用不带前缀的协议 ID
 
 		case pid := <-p.newPeerError: // 处理新 peer 错误事件
 			delete(p.peers, pid) // 删除发生错误的 peer
@@ -827,19 +1186,7 @@ func (p *PubSub) processLoop(ctx context.Context) {
 		case pid := <-p.blacklistPeer: // 处理黑名单 peer 请求
 			logger.Infof("将节点 %s 加入黑名单", pid) // 记录黑名单操作
 			p.blacklist.Add(pid)              // 添加到黑名单
-
-			ch, ok := p.peers[pid] // 检查 peer 是否存在
-			if ok {
-				close(ch)                       // 关闭黑名单 peer 的通道
-				delete(p.peers, pid)            // 从 peers 中删除
-				for t, tmap := range p.topics { // 遍历所有主题
-					if _, ok := tmap[pid]; ok {
-						delete(tmap, pid)     // 从主题中删除 peer
-						p.notifyLeave(t, pid) // 通知离开事件
-					}
-				}
-				p.rt.RemovePeer(pid) // 从路由器中移除 peer
-			}
+			p.evictPeer(pid)                  // 断开黑名单 peer 的会话
 
 		case <-ctx.Done(): // 处理上下文完成事件
 			logger.Info("pubsub 进程循环关闭") // 记录进程循环关闭
@@ -877,6 +1224,11 @@ func (p *PubSub) handlePendingPeers() {
 			continue                              // 如果在黑名单中，跳过
 		}
 
+		if !p.peerAllowed(pid) { // 检查 peer 是否在允许名单中
+			logger.Debugf("忽略不在允许名单中的节点 %s 的连接", pid)
+			continue
+		}
+
 		messages := make(chan *RPC, p.peerOutboundQueueSize) // 创建消息通道，大小为 peerOutboundQueueSize
 		messages <- p.getHelloPacket()                       // 发送 hello 包
 		go p.handleNewPeer(p.ctx, pid, messages)             // 启动新的 goroutine 处理新 peer
@@ -898,22 +1250,11 @@ func (p *PubSub) handleDeadPeers() {
 	p.peerDeadPrioLk.Unlock()                   // 解锁 peerDeadPrioLk
 
 	for pid := range deadPeers { // 遍历每个死亡的 peer
-		ch, ok := p.peers[pid] // 获取 peer 的消息通道
-		if !ok {               // 如果消息通道不存在
+		if _, ok := p.peers[pid]; !ok { // 如果消息通道不存在
 			continue // 跳过
 		}
 
-		close(ch)            // 关闭死亡 peer 的通道
-		delete(p.peers, pid) // 从 peers 中删除
-
-		for t, tmap := range p.topics { // 遍历所有主题
-			if _, ok := tmap[pid]; ok { // 检查主题中是否包含该 peer
-				delete(tmap, pid)     // 从主题中删除 peer
-				p.notifyLeave(t, pid) // 通知离开
-			}
-		}
-
-		p.rt.RemovePeer(pid) // 从路由器中移除 peer
+		p.evictPeer(pid) // 清除死亡 peer 的会话状态
 
 		if p.host.Network().Connectedness(pid) == network.Connected { // 如果 peer 仍然连接
 			backoffDelay, err := p.deadPeerBackoff.updateAndGet(pid) // 获取退避延迟时间
@@ -933,6 +1274,32 @@ func (p *PubSub) handleDeadPeers() {
 	}
 }
 
+// evictPeer 结束与 peer 的 pubsub 会话：关闭其消息通道，并清除主题成员、订阅计数、令牌桶和路由器中的状态。
+// 只从 processLoop 调用。
+// 参数:
+//   - pid: 要移除的 peer
+func (p *PubSub) evictPeer(pid peer.ID) {
+	ch, ok := p.peers[pid] // 获取 peer 的消息通道
+	if !ok {
+		return
+	}
+
+	p.releaseQueued(ch)  // 释放队列中剩余 RPC 的内存预算
+	close(ch)            // 关闭 peer 的通道
+	delete(p.peers, pid) // 从 peers 中删除
+
+	for t, tmap := range p.topics { // 遍历所有主题
+		if _, ok := tmap[pid]; ok { // 检查主题中是否包含该 peer
+			delete(tmap, pid)     // 从主题中删除 peer
+			p.notifyLeave(t, pid) // 通知离开
+		}
+	}
+	delete(p.peerSubs, pid)     // 清除 peer 的订阅计数
+	p.rateLimit.removePeer(pid) // 清除 peer 的令牌桶
+
+	p.removePeer(pid) // 从路由器中移除 peer
+}
+
 // handleAddTopic 添加一个主题的跟踪器。
 // 只从 processLoop 调用。
 // 参数:
@@ -993,7 +1360,8 @@ func (p *PubSub) handleRemoveSubscription(sub *Subscription) {
 	delete(subs, sub)                  // 从订阅列表中删除订阅
 
 	if len(subs) == 0 {
-		delete(p.mySubs, sub.topic) // 如果订阅列表为空，删除主题订阅
+		delete(p.mySubs, sub.topic)                                   // 如果订阅列表为空，删除主题订阅
+		p.notifyTopicEvent(sub.topic, LocalUnsubscribed, p.host.ID()) // 通知本节点停止订阅
 
 		// 仅当没有更多订阅和中继时停止广告
 		if p.myRelays[sub.topic] == 0 {
@@ -1025,6 +1393,9 @@ func (p *PubSub) handleAddSubscription(req *addSubReq) {
 	if subs == nil {
 		p.mySubs[sub.topic] = make(map[*Subscription]struct{})
 	}
+	if len(subs) == 0 {
+		p.notifyTopicEvent(sub.topic, LocalSubscribed, p.host.ID()) // 通知本节点开始订阅
+	}
 
 	sub.cancelCh = p.cancelCh // 设置订阅的取消通道
 
@@ -1051,19 +1422,16 @@ func (p *PubSub) handleAddRelay(req *addRelayReq) {
 		p.rt.Join(topic)        // 加入主题
 	}
 
-	// 标志用于防止多次调用取消函数
-	isCancelled := false
+	// 防止多次调用取消函数，取消函数可以被并发调用
+	var once sync.Once
 
 	relayCancelFunc := func() {
-		if isCancelled {
-			return
-		}
-
-		select {
-		case p.rmRelay <- topic: // 移除中继
-			isCancelled = true
-		case <-p.ctx.Done(): // 上下文完成
-		}
+		once.Do(func() {
+			select {
+			case p.rmRelay <- topic: // 移除中继
+			case <-p.ctx.Done(): // 上下文完成
+			}
+		})
 	}
 
 	req.resp <- relayCancelFunc // 返回中继取消函数
@@ -1175,24 +1543,53 @@ func (p *PubSub) doAnnounceRetry(pid peer.ID, topic string, sub bool) {
 // 参数:
 //   - msg: 要通知的消息
 func (p *PubSub) notifySubs(msg *Message) {
+	// 加密主题只投递解密后的副本
+	msg, ok := p.decryptMessage(msg)
+	if !ok {
+		return
+	}
+
 	// 如果消息具有元数据且为响应类型
 	if msg.Metadata != nil && msg.Metadata.Type == pb.MessageMetadata_RESPONSE {
 		p.handleResponse(msg)
 		return
 	}
 
+	// 如果消息是可靠消息的确认，则分发给等待的发布者
+	if msg.Metadata != nil && msg.Metadata.Type == pb.MessageMetadata_ACK {
+		p.handleAck(msg)
+		return
+	}
+
 	topic := msg.GetTopic() // 获取消息的主题
+
+	// 如果消息携带的幂等令牌已提交，则不再投递给订阅者
+	if msg.tokenCommitted {
+		logger.Debugf("消息 %s 的幂等令牌已提交，跳过投递", msg.ID)
+		return
+	}
+
+	// 确认可靠消息，重复收到的可靠消息不再投递
+	if msg.Metadata != nil && msg.Metadata.Type == pb.MessageMetadata_RELIABLE && !p.acknowledge(msg) {
+		logger.Debugf("可靠消息 %s 已投递，跳过投递", msg.Metadata.MessageID)
+		return
+	}
+
 	subs := p.mySubs[topic] // 获取主题的所有订阅
 	for f := range subs {
-		// 检查消息是否来自订阅者自己
-		if msg.ReceivedFrom == p.host.ID() {
+		// 检查消息是否来自订阅者自己，除非发布时选择了回送
+		if msg.ReceivedFrom == p.host.ID() && !msg.loopback {
 			continue // 如果是，跳过这个订阅者
 		}
 
-		select {
-		case f.ch <- msg: // 发送消息给订阅者
-		default:
-			p.tracer.UndeliverableMessage(msg) // 追踪未能递送的消息
+		// 跳过订阅的过滤函数不接受的消息
+		if !f.accept(msg) {
+			continue
+		}
+
+		// 按订阅的溢出策略发送消息给订阅者
+		if shed := f.enqueue(f.ch, msg); shed != nil {
+			p.tracer.UndeliverableMessage(shed) // 追踪未能递送的消息
 			logger.Infof("无法递送消息到主题 %s 的订阅者; 订阅者处理速度过慢", topic)
 		}
 	}
@@ -1213,7 +1610,7 @@ func (p *PubSub) handleResponse(msg *Message) {
 	replyChan, ok := p.replies[msg.Metadata.MessageID]
 	if ok {
 		select {
-		case replyChan <- msg.Data: // 将响应消息的数据发送到通道
+		case replyChan <- msg: // 将响应消息发送到通道
 			// 如果成功发送数据到通道，删除这个通道，以忽略后续响应
 			delete(p.replies, msg.Metadata.MessageID)
 		default:
@@ -1224,6 +1621,25 @@ func (p *PubSub) handleResponse(msg *Message) {
 	p.repliesMx.Unlock()
 }
 
+// registerReply 注册等待消息 ID 的响应的回复通道
+// 参数:
+//   - id: 请求的消息 ID
+//   - ch: 回复通道，只接收第一个响应
+func (p *PubSub) registerReply(id string, ch chan *Message) {
+	p.repliesMx.Lock()
+	defer p.repliesMx.Unlock()
+	p.replies[id] = ch
+}
+
+// unregisterReply 注销消息 ID 的回复通道，之后到达的响应被丢弃
+// 参数:
+//   - id: 请求的消息 ID
+func (p *PubSub) unregisterReply(id string) {
+	p.repliesMx.Lock()
+	defer p.repliesMx.Unlock()
+	delete(p.replies, id)
+}
+
 // seenMessage 返回我们之前是否已经看到此消息
 // 参数:
 //   - id: 消息 ID
@@ -1293,6 +1709,14 @@ func (p *PubSub) notifyLeave(topic string, pid peer.ID) {
 // 参数:
 //   - rpc: 传入的 RPC 消息指针
 func (p *PubSub) handleIncomingRPC(rpc *RPC) {
+	// 丢弃不在允许名单中的 peer 的 RPC，并断开其会话，使其离开 peers 和 mesh
+	if !p.peerAllowed(rpc.from) {
+		logger.Debugf("peer %s 不在允许名单中; 丢弃 RPC 并断开会话", rpc.from)
+		p.evictPeer(rpc.from)
+		p.resetInboundStream(rpc.from)
+		return
+	}
+
 	// 通过应用程序特定的验证（如果有）。
 	if p.appSpecificRpcInspector != nil {
 		// 检查 RPC 是否被外部检查器允许
@@ -1303,6 +1727,14 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 		}
 	}
 
+	// 检查 peer 的 RPC 速率限制
+	now := time.Now()
+	if !p.rateLimit.allowRPC(rpc.from, now) {
+		logger.Debugf("peer %s 超出 RPC 速率限制; 丢弃 RPC", rpc.from)
+		p.penalizeRateLimit(rpc.from)
+		return
+	}
+
 	// 追踪接收到的 RPC，用于调试或监控目的
 	p.tracer.RecvRPC(rpc)
 
@@ -1320,6 +1752,12 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 		}
 	}
 
+	// 获取每个 peer 的订阅数量上限
+	maxSubs := 0
+	if p.subFilter != nil {
+		maxSubs = maxSubscriptionsPerPeer(p.subFilter)
+	}
+
 	// 遍历所有订阅选项，更新订阅状态
 	for _, subopt := range subs {
 		t := subopt.GetTopicid() // 获取订阅的主题 ID
@@ -1327,15 +1765,28 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 		if subopt.GetSubscribe() {
 			// 如果是订阅请求，处理新的订阅
 			tmap, ok := p.topics[t] // 获取当前主题的订阅者集合
+			if !p.authorized(t, rpc.from) {
+				// 如果 peer 无权加入该主题，忽略订阅
+				logger.Debugf("peer %s 无权订阅主题 %s; 忽略订阅", rpc.from, t)
+				p.penalizeUnauthorized(rpc.from)
+				continue
+			}
+			if _, subscribed := tmap[rpc.from]; !subscribed && maxSubs > 0 && p.peerSubs[rpc.from] >= maxSubs {
+				// 如果 peer 的订阅数量已达到上限，忽略新的订阅
+				logger.Debugf("peer %s 的订阅数量达到上限 %d; 忽略主题 %s 的订阅", rpc.from, maxSubs, t)
+				continue
+			}
 			if !ok {
 				// 如果该主题没有订阅者，创建一个新的订阅者集合
 				tmap = make(map[peer.ID]struct{})
 				p.topics[t] = tmap
+				p.matchPatterns(t) // 新发现的主题可能匹配模式订阅
 			}
 
 			if _, ok = tmap[rpc.from]; !ok {
 				// 如果 peer 尚未订阅该主题，将其加入订阅者集合
 				tmap[rpc.from] = struct{}{}
+				p.peerSubs[rpc.from]++
 				if topic, ok := p.myTopics[t]; ok {
 					// 如果该主题存在于当前节点的主题集合中，发送通知
 					peer := rpc.from
@@ -1352,6 +1803,10 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 			if _, ok := tmap[rpc.from]; ok {
 				// 如果 peer 已订阅该主题，将其从订阅者集合中删除
 				delete(tmap, rpc.from)
+				p.peerSubs[rpc.from]--
+				if p.peerSubs[rpc.from] <= 0 {
+					delete(p.peerSubs, rpc.from)
+				}
 				p.notifyLeave(t, rpc.from) // 通知其他节点该 peer 已离开
 			}
 		}
@@ -1366,15 +1821,23 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 
 	case AcceptControl:
 		// 如果路由器只接受控制消息，忽略负载消息
-		if len(rpc.GetPublish()) > 0 {
-			logger.Debugf("peer %s 被路由器限制; 忽略 %d 个负载消息", rpc.from, len(rpc.GetPublish()))
+		if n := len(rpc.GetPublish()) + len(rpc.GetStem()); n > 0 {
+			logger.Debugf("peer %s 被路由器限制; 忽略 %d 个负载消息", rpc.from, n)
 		}
 		// 对该 peer 进行流量限制，防止滥用
 		p.tracer.ThrottlePeer(rpc.from)
 
 	case AcceptAll:
+		// 检查 peer 的消息字节速率限制，超出时忽略负载消息
+		pubs, stems := rpc.GetPublish(), rpc.GetStem()
+		if n := len(pubs) + len(stems); n > 0 && !p.rateLimit.allowBytes(rpc.from, publishSize(pubs)+publishSize(stems), p.maxMessageSize, now) {
+			logger.Debugf("peer %s 超出消息字节速率限制; 忽略 %d 个负载消息", rpc.from, n)
+			p.penalizeRateLimit(rpc.from)
+			break
+		}
+
 		// 如果路由器接受所有消息，处理发布的消息
-		for _, pmsg := range rpc.GetPublish() {
+		for _, pmsg := range pubs {
 			// 检查消息是否属于已订阅的主题，或是否可以中继消息
 			if !(p.subscribedToMsg(pmsg) || p.canRelayMsg(pmsg)) {
 				// 如果未订阅或无法中继，忽略该消息
@@ -1382,8 +1845,37 @@ func (p *PubSub) handleIncomingRPC(rpc *RPC) {
 				continue
 			}
 
+			// 消息经过了一跳
+			p.countHop(pmsg)
+
 			// 推送消息到消息处理队列
-			p.pushMsg(&Message{pmsg, "", rpc.from, nil, false})
+			p.pushMsg(&Message{
+				Message:       pmsg,
+				ReceivedFrom:  rpc.from,
+				receivedPath:  rpc.path,
+				receivedProto: rpc.proto,
+				receivedAt:    rpc.received,
+				annotations:   new(messageAnnotations),
+			})
+		}
+
+		// 处理 Dandelion 茎阶段的消息
+		for _, pmsg := range stems {
+			if !(p.subscribedToMsg(pmsg) || p.canRelayMsg(pmsg)) {
+				logger.Debug("接收到我们未订阅主题的茎消息; 忽略消息")
+				continue
+			}
+			p.countHop(pmsg)
+
+			p.pushMsg(&Message{
+				Message:       pmsg,
+				ReceivedFrom:  rpc.from,
+				receivedPath:  rpc.path,
+				stem:          true,
+				receivedProto: rpc.proto,
+				receivedAt:    rpc.received,
+				annotations:   new(messageAnnotations),
+			})
 		}
 	}
 
@@ -1401,6 +1893,22 @@ func DefaultMsgIdFn(pmsg *pb.Message) string {
 	return string(pmsg.GetFrom()) + string(pmsg.GetSeqno())
 }
 
+// ContentMsgIdFn 根据主题和消息内容返回消息 ID，用于省略了 from 和 seqno 字段的匿名消息。
+// 同一主题中内容相同的消息会被视为重复。主题带有长度前缀，使主题和内容的边界不会产生歧义。
+// 参数:
+//   - pmsg: 传入的消息
+//
+// 返回值:
+//   - string: 消息内容的哈希
+func ContentMsgIdFn(pmsg *pb.Message) string {
+	topic := pmsg.GetTopic()
+	h := sha256.New()
+	h.Write(binary.AppendUvarint(nil, uint64(len(topic))))
+	h.Write([]byte(topic))
+	h.Write(pmsg.GetData())
+	return string(h.Sum(nil))
+}
+
 // DefaultPeerFilter 接受所有主题的所有 peers
 // 参数:
 //   - pid: peer ID
@@ -1445,13 +1953,35 @@ func (p *PubSub) pushMsg(msg *Message) {
 		return
 	}
 
+	// 拒绝头部超出限制的消息
+	if err := p.checkHeaders(msg.GetHeaders()); err != nil {
+		logger.Debugf("丢弃来自 %s 的消息: %s", src, err)
+		p.tracer.RejectMessage(msg, RejectHeadersTooLarge)
+		return
+	}
+
+	// 拒绝超出主题大小限制的消息
+	if t, ok := p.myTopics[msg.GetTopic()]; ok {
+		if err := t.checkMessageSize(msg.Message); err != nil {
+			logger.Debugf("丢弃来自 %s 的消息: %s", src, err)
+			p.tracer.RejectMessage(msg, RejectMessageTooLarge)
+			return
+		}
+	}
+
+	// 在用户验证器之前拒绝发布者不在主题授权发布者之中的消息，签名随后在验证管道中验证
+	if !p.authorizedPublisher(msg) {
+		logger.Debugf("发布者 %s 未被授权；丢弃来自 %s 的消息", peer.ID(msg.GetFrom()), src)
+		p.tracer.RejectMessage(msg, RejectUnauthorizedPublisher)
+		return
+	}
+
 	// 拒绝声称是从我们自己发出的但实际上是转发的消息
 	// 如果消息声称来自本节点，但实际是由其他节点转发的，则丢弃消息
 	self := p.host.ID()
 	if peer.ID(msg.GetFrom()) == self && src != self {
-		logger.Debugf("丢弃声称来自自己但由 %s 转发的消息", src)
-		// 记录被拒绝的消息
-		p.tracer.RejectMessage(msg, RejectSelfOrigin)
+		// 按自源消息策略处理
+		p.handleSelfOrigin(msg)
 		return
 	}
 
@@ -1460,7 +1990,9 @@ func (p *PubSub) pushMsg(msg *Message) {
 	id := p.idGen.ID(msg)
 	if p.seenMessage(id) {
 		// 如果消息是重复的，记录此操作
-		p.tracer.DuplicateMessage(msg)
+		p.traceDuplicate(id, msg)
+		// 茎阶段的消息被正常传播回来后，不再需要在超时后自行传播
+		p.observeFluff(id, msg)
 		return
 	}
 
@@ -1521,12 +2053,15 @@ func (p *PubSub) publishMessage(msg *Message) {
 	// 通知 tracer 已投递消息
 	p.tracer.DeliverMessage(msg)
 
+	// 保存消息以便为其他节点提供追赶补发
+	p.storeMessage(msg)
+
 	// 如果没有设置目标节点，直接通知订阅者，并继续转发消息
 	if msg.GetTargets() == nil || len(msg.GetTargets()) == 0 {
 		p.notifySubs(msg) // 通知所有订阅者
-		// 如果消息不是本地的，调用路由器发布消息
-		if !msg.Local {
-			p.rt.Publish(msg) // 转发消息
+		// 如果消息不是本地的，也不是追赶补发的，调用路由器发布消息
+		if !msg.Local && !msg.catchUp {
+			p.routeMessage(msg) // 转发消息
 		}
 		return
 	}
@@ -1560,8 +2095,8 @@ func (p *PubSub) publishMessage(msg *Message) {
 	}
 
 	// 如果消息不是本地的，并且存在未接收的目标节点，继续转发消息
-	if !msg.Local && (!currentNodeIsTarget || !allTargetsReceived) {
-		p.rt.Publish(msg) // 转发消息
+	if !msg.Local && !msg.catchUp && (!currentNodeIsTarget || !allTargetsReceived) {
+		p.routeMessage(msg) // 转发消息
 	}
 }
 
@@ -1583,6 +2118,23 @@ type TopicOptions struct{}
 // TopicOpt 主题选项函数类型
 type TopicOpt func(t *Topic) error
 
+// WithTopicDiscovery 配置主题的发现：enabled 为 false 时不在发现服务中广告和查找该主题，
+// 避免敏感主题被外部枚举；ns 非空时使用该命名空间代替主题名称（例如主题名称的哈希）。
+// 此选项仅在 PubSub 也使用 WithDiscovery 时有用。
+// 参数:
+//   - enabled: 是否启用主题的发现
+//   - ns: 发现服务中使用的命名空间，为空时使用主题名称
+//
+// 返回值:
+//   - TopicOpt: 主题选项
+func WithTopicDiscovery(enabled bool, ns string) TopicOpt {
+	return func(t *Topic) error {
+		t.noDiscovery = !enabled
+		t.discoveryNS = ns
+		return nil
+	}
+}
+
 // WithTopicMessageIdFn 设置自定义 MsgIdFunction 用于生成消息 ID
 // 参数:
 //   - msgId: 消息 ID 函数
@@ -1638,6 +2190,7 @@ func (p *PubSub) tryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
 		topic:       topic,                                 // 主题名称
 		evtHandlers: make(map[*TopicEventHandler]struct{}), // 事件处理函数
 	}
+	t.seq.Store(uint64(time.Now().UnixNano())) // 与全局计数器一样以纳秒时间初始化，重新加入后仍然递增
 
 	// 应用主题选项。
 	for _, opt := range opts {
@@ -1646,6 +2199,11 @@ func (p *PubSub) tryJoin(topic string, opts ...TopicOpt) (*Topic, bool, error) {
 		}
 	}
 
+	// 属于命名空间的主题只能通过该命名空间加入。
+	if err := p.checkNamespace(t); err != nil {
+		return nil, false, err
+	}
+
 	// 发送一个请求给 PubSub 实例，要求加入该主题。
 	resp := make(chan *Topic, 1)
 	select {
@@ -1694,6 +2252,7 @@ func (p *PubSub) Subscribe(topic string, opts ...SubOpt) (*Subscription, error)
 
 // WithBufferSize 是一个订阅选项，用于自定义订阅输出缓冲区的大小。
 // 默认长度为 32，但可以配置以避免消费者读取速度不够快时丢失消息。
+// 缓冲区已满时的处理策略由 WithOverflow 设置。
 func WithBufferSize(size int) SubOpt {
 	return func(sub *Subscription) error {
 		sub.ch = make(chan *Message, size)
@@ -1758,6 +2317,126 @@ func (p *PubSub) ListPeers(topic string) []peer.ID {
 	return <-out
 }
 
+// BackoffUntil 返回对等节点在给定主题上的回退到期时间。
+// 在到期之前，我们不会尝试将该对等节点 GRAFT 到主题网格中。
+// 参数:
+//   - topic: 主题
+//   - p: 对等节点 ID
+//
+// 返回值:
+//   - time.Time: 回退到期时间；如果没有回退或路由器不是 gossipsub，返回零值
+func (p *PubSub) BackoffUntil(topic string, pid peer.ID) time.Time {
+	out := make(chan time.Time, 1)
+	get := func() {
+		gs, ok := p.rt.(*GossipSubRouter) // 只有 gossipsub 路由器维护回退状态
+		if !ok {
+			out <- time.Time{}
+			return
+		}
+		out <- gs.backoff[topic][pid] // 不存在时返回零值
+	}
+
+	select {
+	case p.eval <- get:
+		return <-out
+	case <-p.ctx.Done():
+		return time.Time{}
+	}
+}
+
+// MeshPeers 返回给定主题的网格对等节点。
+// 只有 gossipsub 路由器维护网格，其他路由器返回 nil。
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - []peer.ID: 网格对等节点列表
+func (p *PubSub) MeshPeers(topic string) []peer.ID {
+	out := make(chan []peer.ID, 1)
+	get := func() {
+		gs, ok := p.rt.(*GossipSubRouter) // 只有 gossipsub 路由器维护网格
+		if !ok {
+			out <- nil
+			return
+		}
+		var peers []peer.ID
+		for pid := range gs.mesh[topic] {
+			peers = append(peers, pid)
+		}
+		out <- peers
+	}
+
+	select {
+	case p.eval <- get:
+		return <-out
+	case <-p.ctx.Done():
+		return nil
+	}
+}
+
+// PeerScores 返回所有已知对等节点的当前分数。
+// 只有启用了对等节点评分的 gossipsub 路由器维护分数，其他情况返回 nil。
+// 返回值:
+//   - map[peer.ID]float64: 对等节点分数
+func (p *PubSub) PeerScores() map[peer.ID]float64 {
+	out := make(chan map[peer.ID]float64, 1)
+	get := func() {
+		gs, ok := p.rt.(*GossipSubRouter) // 只有 gossipsub 路由器维护分数
+		if !ok || gs.score == nil {
+			out <- nil
+			return
+		}
+		gs.score.Lock()
+		defer gs.score.Unlock()
+		scores := make(map[peer.ID]float64, len(gs.score.peerStats))
+		for pid := range gs.score.peerStats {
+			scores[pid] = gs.score.score(pid)
+		}
+		out <- scores
+	}
+
+	select {
+	case p.eval <- get:
+		return <-out
+	case <-p.ctx.Done():
+		return nil
+	}
+}
+
+// CacheStats 表示消息缓存的统计信息
+type CacheStats struct {
+	Messages    int    // 当前缓存的消息数
+	IWantHits   uint64 // 服务 IWANT 时在缓存中找到的消息数
+	IWantMisses uint64 // 服务 IWANT 时缓存中已不存在的消息数
+}
+
+// CacheStats 返回消息缓存的统计信息，可用于调整 WithGossipHistory 的窗口大小。
+// 只有 gossipsub 路由器维护消息缓存，其他路由器返回零值。
+// 返回值:
+//   - CacheStats: 消息缓存统计信息
+func (p *PubSub) CacheStats() CacheStats {
+	out := make(chan CacheStats, 1)
+	get := func() {
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			out <- CacheStats{}
+			return
+		}
+		out <- CacheStats{
+			Messages:    len(gs.mcache.msgs),
+			IWantHits:   gs.iwantHits,
+			IWantMisses: gs.iwantMisses,
+		}
+	}
+
+	select {
+	case p.eval <- get:
+		return <-out
+	case <-p.ctx.Done():
+		return CacheStats{}
+	}
+}
+
 // BlacklistPeer 将一个对等节点列入黑名单；所有来自此对等节点的消息将无条件丢弃。
 func (p *PubSub) BlacklistPeer(pid peer.ID) {
 	select {
@@ -2,12 +2,14 @@ package pubsub
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/dep2p/go-dep2p"
 	"github.com/dep2p/go-dep2p/core/host"
 	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/pubsub/timecache"
 )
 
 // getDefaultHosts 创建并返回指定数量的 dep2p 主机。
@@ -83,3 +85,67 @@ func TestPubSubRemovesBlacklistedPeer(t *testing.T) {
 	cancel()
 	time.Sleep(time.Millisecond * 100)
 }
+
+// countingSeenCache 记录 Add 调用次数的已见消息缓存
+type countingSeenCache struct {
+	SeenCache
+	adds atomic.Int32
+	done atomic.Bool
+}
+
+func (c *countingSeenCache) Add(id string) bool {
+	c.adds.Add(1)
+	return c.SeenCache.Add(id)
+}
+
+func (c *countingSeenCache) Done() {
+	c.done.Store(true)
+	c.SeenCache.Done()
+}
+
+// TestWithSeenCache 测试使用自定义的已见消息缓存进行去重
+func TestWithSeenCache(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	cache := &countingSeenCache{SeenCache: timecache.NewBoundedTimeCache(timecache.Strategy_FirstSeen, time.Minute, 16)}
+
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1], WithSeenCache(cache)),
+	}
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(2 * time.Second)
+
+	if err := topic.Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sub.Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.adds.Load() == 0 {
+		t.Fatal("expected custom seen cache to be used")
+	}
+
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if !cache.done.Load() {
+		t.Fatal("expected custom seen cache to be released on shutdown")
+	}
+
+	if err := WithSeenCache(nil)(&PubSub{}); err == nil {
+		t.Fatal("expected error for nil seen cache")
+	}
+}
@@ -5,7 +5,9 @@ package pubsub
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"sort"
 
 	"github.com/dep2p/go-dep2p/core/host"
 	"github.com/dep2p/go-dep2p/core/peer"
@@ -40,6 +42,97 @@ func NewRandomSub(ctx context.Context, h host.Host, size int, opts ...Option) (*
 	return NewPubSub(ctx, h, rt, opts...)
 }
 
+// WithRandomSubMinFanout 是一个选项，设置 RandomSub 每条消息至少转发的对等节点数，默认为 RandomSubD
+// 参数:
+//   - n: 最小扇出
+//
+// 返回值:
+//   - Option: 配置选项
+func WithRandomSubMinFanout(n int) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 randomsub")
+			return fmt.Errorf("pubsub 路由器不是 randomsub")
+		}
+		if n <= 0 {
+			logger.Warnf("最小扇出必须为正数")
+			return fmt.Errorf("最小扇出必须为正数")
+		}
+		rs.minFanout = n
+		return nil
+	}
+}
+
+// WithRandomSubTopicSize 是一个选项，覆盖给定主题的网络大小。
+// 主题的扇出为该大小的平方根，最少为最小扇出；未覆盖的主题使用 NewRandomSub 的 size 参数。
+// 参数:
+//   - topic: 主题
+//   - size: 主题的网络大小
+//
+// 返回值:
+//   - Option: 配置选项
+func WithRandomSubTopicSize(topic string, size int) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 randomsub")
+			return fmt.Errorf("pubsub 路由器不是 randomsub")
+		}
+		if size <= 0 {
+			logger.Warnf("主题 %s 的网络大小必须为正数", topic)
+			return fmt.Errorf("主题 %s 的网络大小必须为正数", topic)
+		}
+		if rs.topicSize == nil {
+			rs.topicSize = make(map[string]int)
+		}
+		rs.topicSize[topic] = size
+		return nil
+	}
+}
+
+// WithRandomSubBias 是一个选项，设置 RandomSub 选择对等节点的方式。
+// byScore 为 true 时优先选择分数最高的对等节点，分数相同的对等节点随机选择；为 false 时均匀随机选择。
+// 分数由 WithRandomSubScore 提供，未提供时所有对等节点的分数都为 0。
+// 参数:
+//   - byScore: 是否按分数选择
+//
+// 返回值:
+//   - Option: 配置选项
+func WithRandomSubBias(byScore bool) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 randomsub")
+			return fmt.Errorf("pubsub 路由器不是 randomsub")
+		}
+		rs.byScore = byScore
+		return nil
+	}
+}
+
+// WithRandomSubScore 是一个选项，设置按分数选择对等节点时使用的评分函数。评分函数在事件循环中调用，不能阻塞。
+// 参数:
+//   - score: 评分函数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithRandomSubScore(score func(peer.ID) float64) Option {
+	return func(ps *PubSub) error {
+		rs, ok := ps.rt.(*RandomSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 randomsub")
+			return fmt.Errorf("pubsub 路由器不是 randomsub")
+		}
+		if score == nil {
+			logger.Warnf("评分函数不能为空")
+			return fmt.Errorf("评分函数不能为空")
+		}
+		rs.score = score
+		return nil
+	}
+}
+
 // RandomSubRouter 是一个实现随机传播策略的路由器。
 // 对于每条消息，它选择网络大小平方根个对等节点，最少为 RandomSubD，并将消息转发给它们。
 type RandomSubRouter struct {
@@ -47,6 +140,63 @@ type RandomSubRouter struct {
 	peers  map[peer.ID]protocol.ID // 对等节点映射
 	size   int                     // 网络大小
 	tracer *pubsubTracer           // 跟踪器
+
+	minFanout int                   // 最小扇出，为 0 时使用 RandomSubD
+	topicSize map[string]int        // 每个主题覆盖的网络大小
+	byScore   bool                  // 是否按分数选择对等节点
+	score     func(peer.ID) float64 // 按分数选择时使用的评分函数
+	gate      *peerGater            // 对等节点门控，为 nil 时接受所有消息
+}
+
+// fanout 返回最小扇出
+// 返回值:
+//   - int: 最小扇出
+func (rs *RandomSubRouter) fanout() int {
+	if rs.minFanout > 0 {
+		return rs.minFanout
+	}
+	return RandomSubD
+}
+
+// target 返回主题的每条消息转发的 RandomSub 对等节点数
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - int: 目标对等节点数
+func (rs *RandomSubRouter) target(topic string) int {
+	size, ok := rs.topicSize[topic]
+	if !ok {
+		size = rs.size
+	}
+	target := rs.fanout()
+	// 计算平方根，如果大于目标值，则更新目标值
+	if sqrt := int(math.Ceil(math.Sqrt(float64(size)))); sqrt > target {
+		target = sqrt
+	}
+	return target
+}
+
+// selectPeers 从候选对等节点中选择 n 个，按分数选择时优先选择分数最高的
+// 参数:
+//   - peers: 候选对等节点，会被重新排序
+//   - n: 选择的数量
+//
+// 返回值:
+//   - []peer.ID: 选中的对等节点
+func (rs *RandomSubRouter) selectPeers(peers []peer.ID, n int) []peer.ID {
+	shufflePeers(peers)
+	if rs.byScore && rs.score != nil {
+		scores := make(map[peer.ID]float64, len(peers))
+		for _, p := range peers {
+			scores[p] = rs.score(p)
+		}
+		// 稳定排序保持分数相同的对等节点的随机顺序
+		sort.SliceStable(peers, func(i, j int) bool {
+			return scores[peers[i]] > scores[peers[j]]
+		})
+	}
+	return peers[:n]
 }
 
 // Protocols 返回路由器支持的协议列表
@@ -112,9 +262,9 @@ func (rs *RandomSubRouter) EnoughPeers(topic string, suggested int) bool {
 		}
 	}
 
-	// 如果建议的对等节点数为 0，使用默认值 RandomSubD
+	// 如果建议的对等节点数为 0，使用最小扇出
 	if suggested == 0 {
-		suggested = RandomSubD
+		suggested = rs.fanout()
 	}
 
 	// 如果 floodsub 和 randomsub 对等节点总数大于或等于建议的对等节点数，返回 true
@@ -122,8 +272,8 @@ func (rs *RandomSubRouter) EnoughPeers(topic string, suggested int) bool {
 		return true
 	}
 
-	// 如果 randomsub 对等节点数大于或等于 RandomSubD，返回 true
-	if rsPeers >= RandomSubD {
+	// 如果 randomsub 对等节点数大于或等于最小扇出，返回 true
+	if rsPeers >= rs.fanout() {
 		return true
 	}
 
@@ -133,12 +283,12 @@ func (rs *RandomSubRouter) EnoughPeers(topic string, suggested int) bool {
 
 // AcceptFrom 在处理控制信息或将消息推送到验证管道之前，对每个传入消息调用此方法
 // 参数:
-//   - peer.ID: 对等节点 ID
+//   - p: 对等节点 ID
 //
 // 返回值:
 //   - AcceptStatus: 接受状态
-func (rs *RandomSubRouter) AcceptFrom(peer.ID) AcceptStatus {
-	return AcceptAll
+func (rs *RandomSubRouter) AcceptFrom(p peer.ID) AcceptStatus {
+	return rs.gate.AcceptFrom(p) // 由对等节点门控决定，未启用时接受所有消息
 }
 
 // HandleRPC 处理控制消息
@@ -184,24 +334,16 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 		}
 	}
 
-	// 如果随机选取的节点数量超过预定值
-	if len(rspeers) > RandomSubD {
-		// 目标值设定为 RandomSubD
-		target := RandomSubD
-		// 计算平方根，如果大于目标值，则更新目标值
-		sqrt := int(math.Ceil(math.Sqrt(float64(rs.size))))
-		if sqrt > target {
-			target = sqrt
-		}
+	// 如果随机选取的节点数量超过最小扇出
+	if len(rspeers) > rs.fanout() {
+		// 目标值为主题网络大小的平方根，最少为最小扇出
+		target := rs.target(topic)
 		// 如果目标值超过随机选取的节点数量，更新目标值为随机选取的节点数量
 		if target > len(rspeers) {
 			target = len(rspeers)
 		}
-		// 将随机选取的节点映射转换为列表并打乱顺序
-		xpeers := peerMapToList(rspeers)
-		shufflePeers(xpeers)
 		// 选择目标值数量的节点
-		xpeers = xpeers[:target]
+		xpeers := rs.selectPeers(peerMapToList(rspeers), target)
 		// 将选中的节点添加到 tosend 映射中
 		for _, p := range xpeers {
 			tosend[p] = struct{}{}
@@ -224,6 +366,13 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 			continue
 		}
 
+		// 如果内存预算不足，记录丢弃操作
+		if !rs.p.reserveRPC(out) {
+			logger.Warnf("丢弃发送到对等节点 %s 的消息: 内存预算不足", p)
+			rs.tracer.DropRPC(out, p)
+			continue
+		}
+
 		// 尝试向节点发送消息
 		select {
 		case mch <- out:
@@ -231,6 +380,7 @@ func (rs *RandomSubRouter) Publish(msg *Message) {
 			rs.tracer.SendRPC(out, p)
 		default:
 			// 如果消息队列满了，记录丢弃操作
+			rs.p.releaseRPC(out)
 			logger.Warnf("丢弃发送到对等节点 %s 的消息: 队列已满", p)
 			rs.tracer.DropRPC(out, p)
 		}
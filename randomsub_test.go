@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/peer"
 )
 
 // getRandomsub 创建并返回一个带有随机订阅的 PubSub 实例。
@@ -257,3 +258,57 @@ func TestRandomsubEnoughPeers(t *testing.T) {
 		t.Fatal("expected enough peers")
 	}
 }
+
+// TestRandomsubDegreeOptions 测试最小扇出、主题网络大小覆盖和按分数选择
+func TestRandomsubDegreeOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	scores := make(map[peer.ID]float64)
+	psub := getRandomsub(ctx, hosts[0], 16,
+		WithRandomSubMinFanout(3),
+		WithRandomSubTopicSize("big", 100),
+		WithRandomSubBias(true),
+		WithRandomSubScore(func(p peer.ID) float64 { return scores[p] }),
+	)
+	rs := psub.rt.(*RandomSubRouter)
+
+	if n := rs.target("small"); n != 4 {
+		t.Fatalf("expected target 4 for the default size, got %d", n)
+	}
+	if n := rs.target("big"); n != 10 {
+		t.Fatalf("expected target 10 for the overridden size, got %d", n)
+	}
+	rs.size = 4
+	if n := rs.target("small"); n != 3 {
+		t.Fatalf("expected the min fanout as target, got %d", n)
+	}
+
+	var peers []peer.ID
+	for i := 0; i < 10; i++ {
+		p := peer.ID(fmt.Sprintf("peer-%d", i))
+		scores[p] = float64(i)
+		peers = append(peers, p)
+	}
+	selected := rs.selectPeers(peers, 3)
+	for i, p := range selected {
+		if want := peer.ID(fmt.Sprintf("peer-%d", 9-i)); p != want {
+			t.Fatalf("expected %s at position %d, got %s", want, i, p)
+		}
+	}
+}
+
+// TestRandomsubDegreeOptionsInvalid 测试无效的 RandomSub 选项
+func TestRandomsubDegreeOptionsInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	if _, err := NewRandomSub(ctx, hosts[0], 10, WithRandomSubMinFanout(0)); err == nil {
+		t.Fatal("expected an error for a zero min fanout")
+	}
+	if _, err := NewFloodSub(ctx, hosts[1], WithRandomSubBias(true)); err == nil {
+		t.Fatal("expected an error for a non-randomsub router")
+	}
+}
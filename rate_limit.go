@@ -0,0 +1,165 @@
+// 作用：每个对等节点的入站速率限制。
+// 功能：以令牌桶限制单个对等节点每秒发送的 RPC 数量和消息字节数，
+// 避免单个高速对等节点占满验证管道；超出限制的 RPC 或消息被丢弃，并在 gossipsub 中计入行为惩罚。
+
+package pubsub
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// peerRateLimiter 记录每个对等节点的令牌桶。只从 processLoop 访问。
+type peerRateLimiter struct {
+	msgsPerSec  float64 // 每秒允许的 RPC 数量，0 表示不限制
+	bytesPerSec float64 // 每秒允许的消息字节数，0 表示不限制
+
+	buckets map[peer.ID]*rateBucket
+}
+
+// rateBucket 是单个对等节点的令牌桶
+type rateBucket struct {
+	msgs  tokenBucket // RPC 令牌
+	bytes tokenBucket // 字节令牌
+}
+
+// tokenBucket 是按固定速率补充的令牌桶
+type tokenBucket struct {
+	tokens float64   // 剩余的令牌
+	last   time.Time // 上次补充令牌的时间
+}
+
+// take 补充令牌后消耗 n 个令牌，并返回令牌是否足够。新的令牌桶从满桶开始。
+// 参数:
+//   - n: 消耗的令牌数
+//   - rate: 每秒补充的令牌数
+//   - burst: 令牌桶的容量
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 令牌是否足够
+func (b *tokenBucket) take(n, rate, burst float64, now time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = burst
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+	}
+	b.last = now
+
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// WithPeerRateLimit 是一个选项，用于限制每个对等节点的入站速率。
+// 超出 RPC 速率的 RPC 被整体丢弃；超出字节速率时丢弃该 RPC 中的消息，但仍然处理订阅和控制信息。
+// 使用 gossipsub 路由器时，每次超限都计入一次行为惩罚。
+// 参数:
+//   - msgsPerSec: 每秒允许的 RPC 数量，0 表示不限制
+//   - bytesPerSec: 每秒允许的消息字节数，0 表示不限制
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerRateLimit(msgsPerSec, bytesPerSec float64) Option {
+	return func(p *PubSub) error {
+		if msgsPerSec < 0 || bytesPerSec < 0 || (msgsPerSec == 0 && bytesPerSec == 0) {
+			logger.Warnf("无效的对等节点速率限制: %v/%v", msgsPerSec, bytesPerSec)
+			return fmt.Errorf("无效的对等节点速率限制: %v/%v", msgsPerSec, bytesPerSec)
+		}
+
+		p.rateLimit = &peerRateLimiter{
+			msgsPerSec:  msgsPerSec,
+			bytesPerSec: bytesPerSec,
+			buckets:     make(map[peer.ID]*rateBucket),
+		}
+		return nil
+	}
+}
+
+// bucket 返回对等节点的令牌桶
+// 参数:
+//   - p: 对等节点 ID
+//
+// 返回值:
+//   - *rateBucket: 令牌桶
+func (rl *peerRateLimiter) bucket(p peer.ID) *rateBucket {
+	b, ok := rl.buckets[p]
+	if !ok {
+		b = new(rateBucket)
+		rl.buckets[p] = b
+	}
+	return b
+}
+
+// allowRPC 消耗一个 RPC 令牌，并返回是否在速率限制之内。nil 限制器总是返回 true。
+// 参数:
+//   - p: 对等节点 ID
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否允许
+func (rl *peerRateLimiter) allowRPC(p peer.ID, now time.Time) bool {
+	if rl == nil || rl.msgsPerSec == 0 {
+		return true
+	}
+	return rl.bucket(p).msgs.take(1, rl.msgsPerSec, math.Max(rl.msgsPerSec, 1), now)
+}
+
+// allowBytes 消耗 n 个字节令牌，并返回是否在速率限制之内。nil 限制器总是返回 true。
+// 字节令牌桶的容量至少为 maxMessageSize，使得最大的单条消息也能通过。
+// 参数:
+//   - p: 对等节点 ID
+//   - n: 消息字节数
+//   - maxMessageSize: 允许的最大消息大小
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否允许
+func (rl *peerRateLimiter) allowBytes(p peer.ID, n, maxMessageSize int, now time.Time) bool {
+	if rl == nil || rl.bytesPerSec == 0 {
+		return true
+	}
+	burst := math.Max(rl.bytesPerSec, float64(maxMessageSize))
+	return rl.bucket(p).bytes.take(float64(n), rl.bytesPerSec, burst, now)
+}
+
+// removePeer 删除对等节点的令牌桶。nil 限制器不做任何事。
+// 参数:
+//   - p: 对等节点 ID
+func (rl *peerRateLimiter) removePeer(p peer.ID) {
+	if rl == nil {
+		return
+	}
+	delete(rl.buckets, p)
+}
+
+// publishSize 返回 RPC 中所有消息的字节数
+// 参数:
+//   - msgs: 消息列表
+//
+// 返回值:
+//   - int: 字节数
+func publishSize(msgs []*pb.Message) int {
+	size := 0
+	for _, msg := range msgs {
+		size += msg.Size()
+	}
+	return size
+}
+
+// penalizeRateLimit 记录对等节点超出速率限制，使用 gossipsub 路由器时计入一次行为惩罚
+// 参数:
+//   - pid: 对等节点 ID
+func (p *PubSub) penalizeRateLimit(pid peer.ID) {
+	p.tracer.ThrottlePeer(pid)
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.penalize(pid, 1, PenaltyRateLimit)
+	}
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestPeerRateLimit 测试每个对等节点的 RPC 和字节令牌桶
+func TestPeerRateLimit(t *testing.T) {
+	p := &PubSub{}
+	if err := WithPeerRateLimit(2, 100)(p); err != nil {
+		t.Fatal(err)
+	}
+	rl := p.rateLimit
+
+	now := time.Now()
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	// 新的对等节点从满桶开始
+	for i := 0; i < 2; i++ {
+		if !rl.allowRPC(peerA, now) {
+			t.Fatalf("expected RPC %d to be allowed", i+1)
+		}
+	}
+	if rl.allowRPC(peerA, now) {
+		t.Fatal("expected third RPC to be rate limited")
+	}
+	if !rl.allowRPC(peerB, now) {
+		t.Fatal("expected RPC from another peer to be allowed")
+	}
+	// 半秒之后补充一个令牌
+	if !rl.allowRPC(peerA, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected RPC to be allowed after refill")
+	}
+
+	// 字节令牌桶的容量至少为最大消息大小
+	if !rl.allowBytes(peerA, 150, 150, now) {
+		t.Fatal("expected message of max size to be allowed")
+	}
+	if rl.allowBytes(peerA, 50, 150, now) {
+		t.Fatal("expected bytes over the limit to be rejected")
+	}
+	if !rl.allowBytes(peerA, 50, 150, now.Add(time.Second)) {
+		t.Fatal("expected bytes to be allowed after refill")
+	}
+
+	rl.removePeer(peerA)
+	if _, ok := rl.buckets[peerA]; ok {
+		t.Fatal("expected bucket to be removed")
+	}
+
+	// nil 限制器总是允许
+	var none *peerRateLimiter
+	if !none.allowRPC(peerA, now) || !none.allowBytes(peerA, 1<<30, 0, now) {
+		t.Fatal("expected nil limiter to allow everything")
+	}
+}
+
+// TestPeerRateLimitOption 测试速率限制选项的参数验证
+func TestPeerRateLimitOption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	if _, err := NewGossipSub(ctx, hosts[0], WithPeerRateLimit(0, 0)); err == nil {
+		t.Error("expected error for disabled rate limit")
+	}
+	if _, err := NewGossipSub(ctx, hosts[1], WithPeerRateLimit(-1, 100)); err == nil {
+		t.Error("expected error for negative rate limit")
+	}
+	ps := getGossipsub(ctx, hosts[2], WithPeerRateLimit(10, 0))
+	if ps.rateLimit == nil || ps.rateLimit.bytesPerSec != 0 {
+		t.Fatal("expected RPC-only rate limit to be enabled")
+	}
+}
@@ -0,0 +1,152 @@
+// 作用：发布就绪等待。
+// 功能：发布前等待路由器报告足够的主题或网格对等节点，避免刚加入主题后发布的消息发往空网格；
+// 提供可组合的 RouterReady 判断函数。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// readyPollInterval 是等待路由器就绪时的检查间隔
+const readyPollInterval = 200 * time.Millisecond
+
+// WithReadyStateWait 返回一个发布选项，阻塞发布直到 ready 报告路由器已就绪。
+// 与 WithReadiness 不同，即使启用了发现机制，也会在引导发现之后继续等待路由器就绪；
+// 上下文结束前仍未就绪或判断函数返回错误时返回错误，消息不会被发布。
+// 参数:
+//   - ready: 判断路由器是否就绪的函数，可以用 AllReady 和 AnyReady 组合
+//
+// 返回值:
+//   - PubOpt: 发布选项
+func WithReadyStateWait(ready RouterReady) PubOpt {
+	return func(pub *PublishOptions) error {
+		if ready == nil {
+			logger.Warnf("就绪判断函数不能为空")
+			return fmt.Errorf("就绪判断函数不能为空")
+		}
+		pub.ready = ready
+		pub.waitReady = true
+		return nil
+	}
+}
+
+// MinMeshSize 返回一个 RouterReady，在主题的网格中至少有 size 个对等节点时就绪。
+// 未加入的主题按扇出对等节点计数：已有扇出集合时取其大小，否则取发布时会选入扇出的对等节点数。
+// 没有网格的路由器退化为 MinTopicSize。
+// 参数:
+//   - size: 网格对等节点数
+//
+// 返回值:
+//   - RouterReady: 就绪判断函数
+func MinMeshSize(size int) RouterReady {
+	return func(rt PubSubRouter, topic string) (bool, error) {
+		gs, ok := rt.(*GossipSubRouter)
+		if !ok {
+			return rt.EnoughPeers(topic, size), nil
+		}
+		if gmap, ok := gs.mesh[topic]; ok {
+			return len(gmap) >= size, nil
+		}
+		if gmap := gs.fanout[topic]; len(gmap) > 0 {
+			return len(gmap) >= size, nil
+		}
+		// 与 Publish 选择扇出对等节点的条件一致
+		peers := gs.getPeers(topic, gs.params.D, func(p peer.ID) bool {
+			_, direct := gs.direct[p]
+			return !direct && gs.score.Score(p) >= gs.publishThreshold
+		})
+		return len(peers) >= size, nil
+	}
+}
+
+// AllReady 返回一个 RouterReady，在所有给定条件都就绪时就绪，任一条件返回错误时返回该错误
+// 参数:
+//   - conds: 就绪判断函数
+//
+// 返回值:
+//   - RouterReady: 就绪判断函数
+func AllReady(conds ...RouterReady) RouterReady {
+	return func(rt PubSubRouter, topic string) (bool, error) {
+		for _, cond := range conds {
+			ready, err := cond(rt, topic)
+			if err != nil || !ready {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// AnyReady 返回一个 RouterReady，在任一给定条件就绪时就绪，任一条件返回错误时返回该错误
+// 参数:
+//   - conds: 就绪判断函数
+//
+// 返回值:
+//   - RouterReady: 就绪判断函数
+func AnyReady(conds ...RouterReady) RouterReady {
+	return func(rt PubSubRouter, topic string) (bool, error) {
+		for _, cond := range conds {
+			ready, err := cond(rt, topic)
+			if err != nil {
+				return false, err
+			}
+			if ready {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// waitReady 在事件循环中周期性地检查路由器是否就绪，直到就绪或上下文结束。
+// 非严格模式下判断函数返回的错误视为尚未就绪并继续重试，与 WithReadiness 原有行为一致；
+// 严格模式下（WithReadyStateWait）立即返回该错误。
+// 参数:
+//   - ctx: 上下文
+//   - ready: 就绪判断函数
+//   - strict: 判断函数返回错误时是否立即失败
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) waitReady(ctx context.Context, ready RouterReady, strict bool) error {
+	var ticker *time.Ticker
+	for { // 循环检查路由器是否准备好发布消息
+		type result struct {
+			done bool
+			err  error
+		}
+		res := make(chan result, 1) // 创建结果通道
+		select {
+		case t.p.eval <- func() { // 发送评估函数到评估通道
+			done, err := ready(t.p.rt, t.topic)
+			res <- result{done, err} // 将评估结果发送到结果通道
+		}:
+			r := <-res
+			if r.err != nil && strict {
+				return fmt.Errorf("检查路由器是否就绪失败: %w", r.err)
+			}
+			if r.done && r.err == nil { // 如果准备好了
+				return nil
+			}
+		case <-t.p.ctx.Done(): // 如果全局上下文完成
+			return t.p.ctx.Err() // 返回全局上下文错误
+		case <-ctx.Done(): // 如果操作上下文完成
+			return ctx.Err() // 返回操作上下文错误
+		}
+		if ticker == nil { // 如果计时器尚未初始化
+			ticker = time.NewTicker(readyPollInterval)
+			defer ticker.Stop() // 确保在返回前停止计时器
+		}
+
+		select {
+		case <-ticker.C: // 定期触发检查
+		case <-ctx.Done(): // 如果操作上下文完成
+			return fmt.Errorf("路由器未准备好: %w", ctx.Err()) // 返回上下文错误
+		}
+	}
+}
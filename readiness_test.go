@@ -0,0 +1,148 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRouterReadyCombinators 测试就绪判断函数的组合
+func TestRouterReadyCombinators(t *testing.T) {
+	yes := func(PubSubRouter, string) (bool, error) { return true, nil }
+	no := func(PubSubRouter, string) (bool, error) { return false, nil }
+	boom := errors.New("boom")
+	fail := func(PubSubRouter, string) (bool, error) { return false, boom }
+
+	for i, tc := range []struct {
+		ready RouterReady
+		want  bool
+		err   error
+	}{
+		{AllReady(), true, nil},
+		{AllReady(yes, yes), true, nil},
+		{AllReady(yes, no), false, nil},
+		{AllReady(yes, fail), false, boom},
+		{AnyReady(), false, nil},
+		{AnyReady(no, yes), true, nil},
+		{AnyReady(no, no), false, nil},
+		{AnyReady(fail, yes), false, boom},
+		{AnyReady(no, AllReady(yes, yes)), true, nil},
+	} {
+		got, err := tc.ready(nil, "foobar")
+		if got != tc.want || err != tc.err {
+			t.Fatalf("case %d: expected (%v, %v), got (%v, %v)", i, tc.want, tc.err, got, err)
+		}
+	}
+}
+
+// TestPublishReadyStateWait 测试发布等待网格中有足够的对等节点
+func TestPublishReadyStateWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer tcancel()
+	if err := topics[0].Publish(tctx, []byte("early"), WithReadyStateWait(MinMeshSize(1))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the publish to time out without mesh peers, got %v", err)
+	}
+
+	connectAll(t, hosts)
+	if err := topics[0].Publish(ctx, []byte("ready"), WithReadyStateWait(AllReady(MinTopicSize(1), MinMeshSize(1)))); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "ready" {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
+
+// TestPublishReadyFanout 测试未订阅的主题按扇出对等节点判断 MinMeshSize
+func TestPublishReadyFanout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	sub, err := psubs[1].Subscribe("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	connectAll(t, hosts)
+
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer tcancel()
+	if err := topic.Publish(tctx, []byte("fanout"), WithReadyStateWait(MinMeshSize(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(tctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "fanout" {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
+
+// TestPublishReadinessRetriesOnError 测试 WithReadiness 在判断函数出错时继续重试，WithReadyStateWait 则立即失败
+func TestPublishReadinessRetriesOnError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	boom := errors.New("boom")
+	flaky := func() RouterReady {
+		calls := 0
+		return func(PubSubRouter, string) (bool, error) {
+			calls++
+			if calls == 1 {
+				return false, boom
+			}
+			return true, nil
+		}
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer tcancel()
+	if err := topic.Publish(tctx, []byte("retry"), WithReadiness(flaky())); err != nil {
+		t.Fatalf("expected WithReadiness to retry after an error, got %v", err)
+	}
+	if err := topic.Publish(tctx, []byte("strict"), WithReadyStateWait(flaky())); !errors.Is(err, boom) {
+		t.Fatalf("expected WithReadyStateWait to fail with %v, got %v", boom, err)
+	}
+}
@@ -0,0 +1,62 @@
+// 作用：仅中继节点模式。
+// 功能：专用的中继或骨干节点在启动时即中继给定主题，加入主题的网格并转发流量而无需任何本地订阅；
+// 中继按引用计数，并提供查询主题当前中继引用数的接口。
+
+package pubsub
+
+import (
+	"fmt"
+)
+
+// WithRelayOnly 是一个选项，使节点在启动后立即中继给定的主题。中继在 PubSub 的整个生命周期内保持，
+// 与通过 Topic.Relay 添加的中继一起计入引用计数。
+// 参数:
+//   - topics: 要中继的主题
+//
+// 返回值:
+//   - Option: 配置选项
+func WithRelayOnly(topics ...string) Option {
+	return func(ps *PubSub) error {
+		if len(topics) == 0 {
+			logger.Warnf("至少需要一个中继的主题")
+			return fmt.Errorf("至少需要一个中继的主题")
+		}
+		for _, topic := range topics {
+			if ps.isPublisherOnly(topic) {
+				logger.Warnf("主题 %s 处于仅发布模式，不能中继", topic)
+				return fmt.Errorf("主题 %s 处于仅发布模式，不能中继", topic)
+			}
+		}
+		ps.relayOnly = append(ps.relayOnly, topics...)
+		return nil
+	}
+}
+
+// startRelays 中继通过 WithRelayOnly 配置的主题，在处理循环启动后调用
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) startRelays() error {
+	for _, topic := range p.relayOnly {
+		t, err := p.Join(topic)
+		if err != nil {
+			return err
+		}
+		if _, err := t.Relay(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RelayRefs 返回主题当前的中继引用数
+// 返回值:
+//   - int: 中继引用数
+func (t *Topic) RelayRefs() int {
+	out := make(chan int, 1)
+	select {
+	case t.p.eval <- func() { out <- t.p.myRelays[t.topic] }:
+		return <-out
+	case <-t.p.ctx.Done():
+		return 0
+	}
+}
@@ -0,0 +1,78 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestRelayOnly 测试仅中继的节点在没有本地订阅的情况下转发主题上的消息
+func TestRelayOnly(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0]),
+		getGossipsub(ctx, hosts[1], WithRelayOnly("foo")),
+		getGossipsub(ctx, hosts[2]),
+	}
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	topic, err := psubs[1].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := topic.RelayRefs(); refs != 1 {
+		t.Fatalf("expected 1 relay ref, got %d", refs)
+	}
+
+	topics := getTopics(psubs[:1], "foo")
+	sub, err := psubs[2].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	msg := []byte("hello")
+	if err := topics[0].Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+
+	// 额外的中继与配置的中继一起计数，并发取消只生效一次
+	relayCancel, err := topic.Relay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs := topic.RelayRefs(); refs != 2 {
+		t.Fatalf("expected 2 relay refs, got %d", refs)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			relayCancel()
+		}()
+	}
+	wg.Wait()
+
+	if refs := topic.RelayRefs(); refs != 1 {
+		t.Fatalf("expected 1 relay ref after cancel, got %d", refs)
+	}
+}
+
+// TestRelayOnlyInvalid 测试无效的仅中继配置
+func TestRelayOnlyInvalid(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	if _, err := NewGossipSub(ctx, hosts[0], WithRelayOnly()); err == nil {
+		t.Fatal("expected an error without topics")
+	}
+}
@@ -0,0 +1,274 @@
+// 作用：至少一次投递。
+// 功能：可靠发布的消息携带可靠消息 ID，收到消息并投递给本地订阅者的节点在主题上发布签名的确认；
+// 发布者按间隔重新发布消息，直到收到足够数量的不同订阅者的确认或截止时间到达。
+// 订阅者对同一可靠消息 ID 只投递一次，重复收到时重新发布确认，以弥补丢失的确认；
+// 确认按消息 ID 合并后由单个协程依次发布。
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultAckTimeout 是可靠发布等待确认的默认截止时间
+	DefaultAckTimeout = 10 * time.Second
+
+	// DefaultRepublishInterval 是可靠发布未收到足够确认时重新发布的默认间隔
+	DefaultRepublishInterval = 2 * time.Second
+
+	// reliableSeenTTL 是订阅者记录已投递的可靠消息 ID 的时间窗口
+	reliableSeenTTL = 2 * time.Minute
+
+	// reliableSeenCapacity 是订阅者记录的已投递可靠消息 ID 的最大数量
+	reliableSeenCapacity = 4096
+
+	// reliableAckQueueSize 是等待发布的确认的最大数量，超出时丢弃的确认在发布者重新发布后再次发送
+	reliableAckQueueSize = reliableSeenCapacity
+)
+
+// ErrAckQuorumNotReached 表示可靠发布在截止时间之前没有收到足够的确认
+var ErrAckQuorumNotReached = errors.New("截止时间之前未收到足够的确认")
+
+// reliableOptions 是可靠发布的选项
+type reliableOptions struct {
+	acks     int           // 需要的确认数量
+	timeout  time.Duration // 等待确认的截止时间
+	interval time.Duration // 重新发布的间隔
+}
+
+// ReliableOpt 是可靠发布的选项
+type ReliableOpt func(ro *reliableOptions) error
+
+// WaitForAcks 设置可靠发布需要的不同订阅者的确认数量和等待确认的截止时间
+// 参数:
+//   - n: 需要的确认数量
+//   - timeout: 等待确认的截止时间
+//
+// 返回值:
+//   - ReliableOpt: 可靠发布选项
+func WaitForAcks(n int, timeout time.Duration) ReliableOpt {
+	return func(ro *reliableOptions) error {
+		if n <= 0 {
+			logger.Warnf("确认数量必须为正数")
+			return fmt.Errorf("确认数量必须为正数")
+		}
+		if timeout <= 0 {
+			logger.Warnf("确认截止时间必须为正数")
+			return fmt.Errorf("确认截止时间必须为正数")
+		}
+		ro.acks = n
+		ro.timeout = timeout
+		return nil
+	}
+}
+
+// WithRepublishInterval 设置可靠发布未收到足够确认时重新发布的间隔
+// 参数:
+//   - interval: 重新发布的间隔
+//
+// 返回值:
+//   - ReliableOpt: 可靠发布选项
+func WithRepublishInterval(interval time.Duration) ReliableOpt {
+	return func(ro *reliableOptions) error {
+		if interval <= 0 {
+			logger.Warnf("重新发布间隔必须为正数")
+			return fmt.Errorf("重新发布间隔必须为正数")
+		}
+		ro.interval = interval
+		return nil
+	}
+}
+
+// PublishReliable 发布需要订阅者确认的消息，并按间隔重新发布，直到收到足够数量的不同订阅者的确认。
+// 确认是订阅者在主题上发布的普通消息，因此只有携带发送者的（签名）确认才会被计数；
+// 发布期间本节点会中继主题，以便接收确认。每次重新发布都是新的消息，订阅者按可靠消息 ID 去重。
+// 参数:
+//   - ctx: 上下文
+//   - data: 要发布的数据
+//   - opts: 可靠发布选项，默认需要 1 个确认
+//
+// 返回值:
+//   - error: 截止时间之前未收到足够的确认时返回 ErrAckQuorumNotReached
+func (t *Topic) PublishReliable(ctx context.Context, data []byte, opts ...ReliableOpt) error {
+	ro := &reliableOptions{
+		acks:     1,
+		timeout:  DefaultAckTimeout,
+		interval: DefaultRepublishInterval,
+	}
+	for _, opt := range opts {
+		if err := opt(ro); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ro.timeout)
+	defer cancel()
+
+	// 中继主题，确保确认能够到达本节点
+	relay, err := t.Relay()
+	if err != nil {
+		return err
+	}
+	defer relay()
+
+	id := uuid.New().String()
+	ackCh := make(chan peer.ID, 32)
+	t.p.registerAck(id, ackCh)
+	defer t.p.unregisterAck(id)
+
+	acked := make(map[peer.ID]struct{})
+	ticker := time.NewTicker(ro.interval)
+	defer ticker.Stop()
+
+	for {
+		err := t.Publish(ctx, data, WithMessageMetadata(id, pb.MessageMetadata_RELIABLE))
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+	wait:
+		for {
+			select {
+			case pid := <-ackCh:
+				acked[pid] = struct{}{}
+				if len(acked) >= ro.acks {
+					return nil
+				}
+			case <-ticker.C:
+				logger.Debugf("可靠消息 %s 只收到 %d/%d 个确认，重新发布", id, len(acked), ro.acks)
+				break wait
+			case <-ctx.Done():
+				return fmt.Errorf("%w: 收到 %d/%d 个确认", ErrAckQuorumNotReached, len(acked), ro.acks)
+			}
+		}
+	}
+}
+
+// ackRequest 是等待发布的可靠消息确认
+type ackRequest struct {
+	topic *Topic // 确认发布到的主题
+	id    string // 可靠消息 ID
+}
+
+// acknowledge 为投递给本地订阅者的可靠消息发布确认。
+// 重复收到的消息说明发布者没有收到之前的确认，因此不再投递但重新确认。
+// 只从 processLoop 调用。
+// 参数:
+//   - msg: 可靠消息
+//
+// 返回值:
+//   - bool: 消息是否首次收到，重复收到的消息不再投递
+func (p *PubSub) acknowledge(msg *Message) bool {
+	topic := msg.GetTopic()
+	if msg.ReceivedFrom == p.host.ID() || len(p.mySubs[topic]) == 0 {
+		return true // 没有本地订阅者接收消息，不确认
+	}
+	t, ok := p.myTopics[topic]
+	if !ok {
+		return true
+	}
+
+	id := msg.Metadata.MessageID
+	first := p.reliableSeen.Add(id)
+	p.queueAck(ackRequest{topic: t, id: id})
+	return first
+}
+
+// queueAck 将确认交给确认协程发布。发布会等待处理循环，因此不能在处理循环中直接发布；
+// 同一消息 ID 尚未发布的确认只保留一个
+// 参数:
+//   - req: 确认
+func (p *PubSub) queueAck(req ackRequest) {
+	p.ackQueueMx.Lock()
+	defer p.ackQueueMx.Unlock()
+
+	if _, ok := p.ackQueued[req.id]; ok {
+		return
+	}
+	if len(p.ackQueue) >= reliableAckQueueSize {
+		// 发布者重新发布消息时会再次确认
+		logger.Debugf("等待发布的确认过多，暂不确认可靠消息 %s", req.id)
+		return
+	}
+	p.ackQueue = append(p.ackQueue, req)
+	p.ackQueued[req.id] = struct{}{}
+
+	select {
+	case p.ackSignal <- struct{}{}:
+	default:
+	}
+}
+
+// ackLoop 依次发布等待发布的确认
+func (p *PubSub) ackLoop() {
+	for {
+		select {
+		case <-p.ackSignal:
+			p.ackQueueMx.Lock()
+			reqs := p.ackQueue
+			p.ackQueue = nil
+			p.ackQueued = make(map[string]struct{})
+			p.ackQueueMx.Unlock()
+
+			for _, req := range reqs {
+				if err := req.topic.Publish(p.ctx, nil, WithMessageMetadata(req.id, pb.MessageMetadata_ACK)); err != nil {
+					logger.Debugf("发布可靠消息 %s 的确认失败: %s", req.id, err)
+				}
+			}
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// handleAck 将确认的发送者分发给等待该可靠消息的发布者
+// 参数:
+//   - msg: 确认消息
+func (p *PubSub) handleAck(msg *Message) {
+	from := msg.GetFrom()
+	if msg.Metadata.MessageID == "" || from == "" {
+		logger.Debugf("忽略缺少消息 ID 或发送者的确认")
+		return
+	}
+
+	p.acksMx.Lock()
+	ch, ok := p.acks[msg.Metadata.MessageID]
+	p.acksMx.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case ch <- from:
+	default:
+		// 发布者处理确认过慢，丢弃的确认会在重新发布后再次到达
+	}
+}
+
+// registerAck 注册等待可靠消息确认的通道
+// 参数:
+//   - id: 可靠消息 ID
+//   - ch: 接收确认发送者的通道
+func (p *PubSub) registerAck(id string, ch chan peer.ID) {
+	p.acksMx.Lock()
+	defer p.acksMx.Unlock()
+	p.acks[id] = ch
+}
+
+// unregisterAck 注销可靠消息的确认通道，之后到达的确认被丢弃
+// 参数:
+//   - id: 可靠消息 ID
+func (p *PubSub) unregisterAck(id string) {
+	p.acksMx.Lock()
+	defer p.acksMx.Unlock()
+	delete(p.acks, id)
+}
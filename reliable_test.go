@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestPublishReliable 测试可靠发布等待确认法定数量，以及订阅者对重新发布的消息只投递一次
+func TestPublishReliable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getPubsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	var subs []*Subscription
+	for _, ps := range psubs[1:] {
+		topic, err := ps.Join("reliable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		subs = append(subs, sub)
+	}
+
+	topic, err := psubs[0].Join("reliable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.PublishReliable(ctx, nil, WaitForAcks(0, time.Second)); err == nil {
+		t.Fatal("expected error for a non-positive ack count")
+	}
+	time.Sleep(time.Second)
+
+	if err := topic.PublishReliable(ctx, []byte("first"), WaitForAcks(3, 5*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有 3 个订阅者，无法达到 4 个确认；重新发布的消息只投递一次
+	err = topic.PublishReliable(ctx, []byte("second"), WaitForAcks(4, time.Second), WithRepublishInterval(200*time.Millisecond))
+	if !errors.Is(err, ErrAckQuorumNotReached) {
+		t.Fatalf("expected ErrAckQuorumNotReached, got %v", err)
+	}
+
+	for _, sub := range subs {
+		for _, want := range []string{"first", "second"} {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(msg.Data) != want {
+				t.Fatalf("expected %q, got %q", want, msg.Data)
+			}
+		}
+
+		nctx, ncancel := context.WithTimeout(ctx, 300*time.Millisecond)
+		if msg, err := sub.Next(nctx); err == nil {
+			t.Fatalf("unexpected redelivery of %q", msg.Data)
+		}
+		ncancel()
+	}
+}
+
+// TestAcknowledgeOnce 测试订阅者对同一可靠消息 ID 只投递一次，重复收到时重新确认
+func TestAcknowledgeOnce(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	topic, err := psubs[0].Join("reliable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	other, err := psubs[1].Join("reliable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay, err := other.Relay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer relay()
+
+	acks := make(chan peer.ID, 8)
+	psubs[1].registerAck("reliable-id", acks)
+	defer psubs[1].unregisterAck("reliable-id")
+	time.Sleep(time.Second)
+
+	// 同一可靠消息 ID 的重新发布只投递一次，但每次都重新确认
+	acknowledge := func() bool {
+		result := make(chan bool, 1)
+		psubs[0].eval <- func() {
+			result <- psubs[0].acknowledge(&Message{
+				Message: &pb.Message{
+					Topic:    "reliable",
+					Metadata: &pb.MessageMetadata{MessageID: "reliable-id", Type: pb.MessageMetadata_RELIABLE},
+				},
+				ReceivedFrom: hosts[1].ID(),
+			})
+		}
+		return <-result
+	}
+	expectAck := func() {
+		select {
+		case pid := <-acks:
+			if pid != hosts[0].ID() {
+				t.Fatalf("expected an ack from %s, got %s", hosts[0].ID(), pid)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected an ack")
+		}
+	}
+
+	if !acknowledge() {
+		t.Fatal("expected the first copy to be delivered")
+	}
+	expectAck()
+	if acknowledge() {
+		t.Fatal("expected the duplicate not to be delivered")
+	}
+	expectAck()
+}
@@ -0,0 +1,397 @@
+// 作用：基于主题的请求/响应。
+// 功能：在主题之上实现关联的请求和响应：请求携带关联 ID 和请求者的回复主题，响应者将响应发布到回复主题；
+// 请求者只接收每个请求的第一个响应，超时或迟到的响应被丢弃，响应者对重复的请求只处理一次。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+	"github.com/dep2p/pubsub/timecache"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRequestTimeout 是上下文没有截止时间时请求的默认超时时间
+	DefaultRequestTimeout = 30 * time.Second
+
+	// reqRespSeenCapacity 是响应者记录的已处理请求 ID 的最大数量
+	reqRespSeenCapacity = 4096
+
+	// ReplyTopicPrefix 是请求者回复主题的前缀，响应者只向具有该前缀的回复主题发布响应
+	ReplyTopicPrefix = "/reqresp/reply/"
+
+	// reqRespMaxReplyTopics 是响应者同时为发布响应而加入的回复主题的最大数量
+	reqRespMaxReplyTopics = 256
+)
+
+// Response 是请求的响应
+type Response struct {
+	Data []byte  // 响应数据
+	From peer.ID // 响应者，匿名模式下为空
+}
+
+// RequestHandler 处理请求并返回响应数据，返回错误时不发送响应
+type RequestHandler func(ctx context.Context, req *Message) ([]byte, error)
+
+// ReqRespOpt 是请求/响应的选项
+type ReqRespOpt func(rr *ReqResp) error
+
+// WithRequestTimeout 设置上下文没有截止时间时请求的超时时间，同时也是响应者对重复请求去重的时间窗口
+// 参数:
+//   - timeout: 超时时间
+//
+// 返回值:
+//   - ReqRespOpt: 请求/响应选项
+func WithRequestTimeout(timeout time.Duration) ReqRespOpt {
+	return func(rr *ReqResp) error {
+		if timeout <= 0 {
+			logger.Warnf("请求超时时间必须为正数")
+			return fmt.Errorf("请求超时时间必须为正数")
+		}
+		rr.timeout = timeout
+		return nil
+	}
+}
+
+// ReqResp 在主题之上实现关联的请求和响应
+type ReqResp struct {
+	ps         *PubSub
+	timeout    time.Duration           // 请求的默认超时时间
+	replyTopic string                  // 本实例的回复主题
+	seen       *timecache.BoundedCache // 已处理的请求 ID
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mx      sync.Mutex
+	topics  map[string]*Topic        // 加入的请求主题和本实例的回复主题
+	served  map[string]*Subscription // 提供服务的主题的订阅
+	replies map[string]*replyTopic   // 为发布响应而临时加入的请求者回复主题
+	relay   RelayCancelFunc          // 回复主题的中继
+	closed  bool
+}
+
+// NewReqResp 创建请求/响应实例，并加入本实例的回复主题。
+// 请求和提供服务的主题在首次使用时加入，因此不能已被应用程序加入。
+// 参数:
+//   - ps: PubSub 实例
+//   - opts: 请求/响应选项
+//
+// 返回值:
+//   - *ReqResp: 请求/响应实例
+//   - error: 错误信息，如果有的话
+func NewReqResp(ps *PubSub, opts ...ReqRespOpt) (*ReqResp, error) {
+	if ps == nil {
+		logger.Warnf("PubSub 实例不能为空")
+		return nil, fmt.Errorf("PubSub 实例不能为空")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rr := &ReqResp{
+		ps:         ps,
+		timeout:    DefaultRequestTimeout,
+		replyTopic: fmt.Sprintf("%s%s/%s", ReplyTopicPrefix, ps.host.ID(), uuid.New().String()),
+		ctx:        ctx,
+		cancel:     cancel,
+		topics:     make(map[string]*Topic),
+		served:     make(map[string]*Subscription),
+		replies:    make(map[string]*replyTopic),
+	}
+	for _, opt := range opts {
+		if err := opt(rr); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	rr.seen = timecache.NewBoundedTimeCache(timecache.Strategy_FirstSeen, rr.timeout, reqRespSeenCapacity)
+
+	// 中继回复主题以接收发往本实例的响应，响应在投递给订阅者之前按关联 ID 分发
+	rr.mx.Lock()
+	defer rr.mx.Unlock()
+	t, err := rr.topic(rr.replyTopic)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	rr.relay, err = t.Relay()
+	if err != nil {
+		t.Close()
+		cancel()
+		logger.Warnf("中继回复主题失败: %s", err)
+		return nil, fmt.Errorf("中继回复主题失败: %w", err)
+	}
+
+	return rr, nil
+}
+
+// Request 向主题发布请求并等待第一个响应
+// 参数:
+//   - ctx: 上下文，没有截止时间时使用默认超时时间
+//   - topic: 请求主题
+//   - payload: 请求数据
+//
+// 返回值:
+//   - *Response: 响应
+//   - error: 错误信息，如果有的话
+func (rr *ReqResp) Request(ctx context.Context, topic string, payload []byte) (*Response, error) {
+	rr.mx.Lock()
+	t, err := rr.topic(topic)
+	rr.mx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rr.timeout)
+		defer cancel()
+	}
+
+	id := uuid.New().String()
+	replyCh := make(chan *Message, 1)
+	rr.ps.registerReply(id, replyCh)
+	defer rr.ps.unregisterReply(id)
+
+	err = t.Publish(ctx, payload, WithMessageMetadata(id, pb.MessageMetadata_REQUEST), WithReplyTopic(rr.replyTopic))
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case msg := <-replyCh:
+		return &Response{Data: msg.Data, From: msg.GetFrom()}, nil
+	case <-ctx.Done():
+		logger.Debugf("等待主题 %s 的请求 %s 的响应超时", topic, id)
+		return nil, fmt.Errorf("等待响应超时: %w", ctx.Err())
+	case <-rr.ctx.Done():
+		return nil, ErrTopicClosed
+	}
+}
+
+// Serve 订阅主题并用 handler 处理收到的请求，将响应发布到请求的回复主题
+// 参数:
+//   - topic: 提供服务的主题
+//   - handler: 请求处理函数，可能被并发调用
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (rr *ReqResp) Serve(topic string, handler RequestHandler) error {
+	if handler == nil {
+		logger.Warnf("请求处理函数不能为空")
+		return fmt.Errorf("请求处理函数不能为空")
+	}
+
+	rr.mx.Lock()
+	defer rr.mx.Unlock()
+
+	if _, ok := rr.served[topic]; ok {
+		logger.Warnf("主题 %s 已在提供服务", topic)
+		return fmt.Errorf("主题 %s 已在提供服务", topic)
+	}
+	t, err := rr.topic(topic)
+	if err != nil {
+		return err
+	}
+	sub, err := t.Subscribe()
+	if err != nil {
+		logger.Warnf("订阅主题 %s 失败: %s", topic, err)
+		return fmt.Errorf("订阅主题 %s 失败: %w", topic, err)
+	}
+	rr.served[topic] = sub
+
+	rr.wg.Add(1)
+	go rr.serve(sub, handler)
+	return nil
+}
+
+// Close 停止提供服务，取消正在等待的请求，并关闭加入的主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (rr *ReqResp) Close() error {
+	rr.mx.Lock()
+	if rr.closed {
+		rr.mx.Unlock()
+		return nil
+	}
+	rr.closed = true
+	rr.mx.Unlock()
+
+	rr.cancel()
+	rr.wg.Wait()
+
+	rr.mx.Lock()
+	defer rr.mx.Unlock()
+	for topic, sub := range rr.served {
+		sub.Cancel()
+		delete(rr.served, topic)
+	}
+	rr.relay()
+	var err error
+	for _, t := range rr.topics {
+		if cerr := t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// topic 返回主题句柄，首次使用时加入主题。调用方必须持有 rr.mx
+// 参数:
+//   - name: 主题名称
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - error: 错误信息，如果有的话
+func (rr *ReqResp) topic(name string) (*Topic, error) {
+	if rr.closed {
+		return nil, ErrTopicClosed
+	}
+	if t, ok := rr.topics[name]; ok {
+		return t, nil
+	}
+
+	t, err := rr.ps.Join(name)
+	if err != nil {
+		logger.Warnf("加入主题 %s 失败: %s", name, err)
+		return nil, fmt.Errorf("加入主题 %s 失败: %w", name, err)
+	}
+	rr.topics[name] = t
+	return t, nil
+}
+
+// serve 接收订阅中的请求并并发处理，直到实例关闭
+// 参数:
+//   - sub: 提供服务的主题的订阅
+//   - handler: 请求处理函数
+func (rr *ReqResp) serve(sub *Subscription, handler RequestHandler) {
+	defer rr.wg.Done()
+
+	for {
+		msg, err := sub.Next(rr.ctx)
+		if err != nil {
+			return
+		}
+
+		md := msg.GetMetadata()
+		if md == nil || md.Type != pb.MessageMetadata_REQUEST || md.MessageID == "" || md.ReplyTopic == "" {
+			continue // 不是请求
+		}
+		if !validReplyTopic(md.ReplyTopic) {
+			logger.Debugf("忽略回复主题 %s 无效的请求 %s", md.ReplyTopic, md.MessageID)
+			continue
+		}
+		if !rr.seen.Add(md.MessageID) {
+			logger.Debugf("忽略重复的请求 %s", md.MessageID)
+			continue
+		}
+
+		rr.wg.Add(1)
+		go rr.handle(msg, handler)
+	}
+}
+
+// handle 处理一个请求并将响应发布到请求的回复主题
+// 参数:
+//   - msg: 请求
+//   - handler: 请求处理函数
+func (rr *ReqResp) handle(msg *Message, handler RequestHandler) {
+	defer rr.wg.Done()
+
+	md := msg.GetMetadata()
+	data, err := handler(rr.ctx, msg)
+	if err != nil {
+		logger.Debugf("处理请求 %s 失败: %s", md.MessageID, err)
+		return
+	}
+
+	t, err := rr.acquireReply(md.ReplyTopic)
+	if err != nil {
+		logger.Debugf("无法响应请求 %s: %s", md.MessageID, err)
+		return
+	}
+	defer rr.releaseReply(md.ReplyTopic)
+
+	if err := t.Publish(rr.ctx, data, WithMessageMetadata(md.MessageID, pb.MessageMetadata_RESPONSE)); err != nil {
+		logger.Debugf("发布请求 %s 的响应失败: %s", md.MessageID, err)
+	}
+}
+
+// replyTopic 是为发布响应而加入的请求者回复主题
+type replyTopic struct {
+	t    *Topic // 主题句柄
+	refs int    // 正在使用该主题发布响应的请求数
+}
+
+// validReplyTopic 判断请求的回复主题是否具有 ReqResp 实例生成的前缀
+// 参数:
+//   - topic: 回复主题
+//
+// 返回值:
+//   - bool: 回复主题是否有效
+func validReplyTopic(topic string) bool {
+	return len(topic) > len(ReplyTopicPrefix) && strings.HasPrefix(topic, ReplyTopicPrefix)
+}
+
+// acquireReply 返回发布响应的回复主题，首次使用时加入主题。
+// 同时加入的回复主题数量有上限，最后一个使用者释放后关闭主题。
+// 参数:
+//   - name: 回复主题
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - error: 错误信息，如果有的话
+func (rr *ReqResp) acquireReply(name string) (*Topic, error) {
+	rr.mx.Lock()
+	defer rr.mx.Unlock()
+
+	if rr.closed {
+		return nil, ErrTopicClosed
+	}
+	if t, ok := rr.topics[name]; ok {
+		return t, nil // 请求来自本实例
+	}
+	if r, ok := rr.replies[name]; ok {
+		r.refs++
+		return r.t, nil
+	}
+	if len(rr.replies) >= reqRespMaxReplyTopics {
+		return nil, fmt.Errorf("同时加入的回复主题已达上限 %d", reqRespMaxReplyTopics)
+	}
+
+	t, err := rr.ps.Join(name)
+	if err != nil {
+		return nil, fmt.Errorf("加入回复主题 %s 失败: %w", name, err)
+	}
+	rr.replies[name] = &replyTopic{t: t, refs: 1}
+	return t, nil
+}
+
+// releaseReply 释放回复主题，最后一个使用者释放后关闭主题
+// 参数:
+//   - name: 回复主题
+func (rr *ReqResp) releaseReply(name string) {
+	rr.mx.Lock()
+	defer rr.mx.Unlock()
+
+	r, ok := rr.replies[name]
+	if !ok {
+		return
+	}
+	r.refs--
+	if r.refs > 0 {
+		return
+	}
+	delete(rr.replies, name)
+	if err := r.t.Close(); err != nil {
+		logger.Debugf("关闭回复主题 %s 失败: %s", name, err)
+	}
+}
@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TestReqResp 测试请求只接收第一个响应，以及响应者对重复请求只处理一次
+func TestReqResp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 4)
+	psubs := getPubsubs(ctx, hosts)
+	connectAll(t, hosts)
+
+	client, err := NewReqResp(psubs[0], WithRequestTimeout(5*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var handled atomic.Int32
+	for _, ps := range psubs[1:3] {
+		server, err := NewReqResp(ps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer server.Close()
+		err = server.Serve("echo", func(ctx context.Context, req *Message) ([]byte, error) {
+			handled.Add(1)
+			return append([]byte("echo: "), req.Data...), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := server.Serve("echo", func(context.Context, *Message) ([]byte, error) { return nil, nil }); err == nil {
+			t.Fatal("expected error when serving a topic twice")
+		}
+	}
+	time.Sleep(time.Second)
+
+	resp, err := client.Request(ctx, "echo", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data) != "echo: hello" {
+		t.Fatalf("unexpected response %q", resp.Data)
+	}
+	if resp.From != hosts[1].ID() && resp.From != hosts[2].ID() {
+		t.Fatalf("unexpected responder %s", resp.From)
+	}
+
+	// 没有响应者的请求超时
+	tctx, tcancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer tcancel()
+	if _, err := client.Request(tctx, "nobody", []byte("hello")); err == nil {
+		t.Fatal("expected the request to time out")
+	}
+
+	// 重复的请求只被处理一次
+	time.Sleep(500 * time.Millisecond)
+	handled.Store(0)
+	raw, err := psubs[3].Join("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dupReply := ReplyTopicPrefix + hosts[3].ID().String() + "/dup"
+	for i := 0; i < 2; i++ {
+		err := raw.Publish(ctx, []byte("dup"), WithMessageMetadata("dup-id", pb.MessageMetadata_REQUEST), WithReplyTopic(dupReply))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second)
+	if n := handled.Load(); n != 2 {
+		t.Fatalf("expected each server to handle the duplicated request once, got %d", n)
+	}
+
+	// 回复主题不是 ReqResp 生成的请求被忽略，响应者不会加入任意主题
+	handled.Store(0)
+	for i, reply := range []string{"/app/topic", ReplyTopicPrefix} {
+		err := raw.Publish(ctx, []byte("bad"), WithMessageMetadata(fmt.Sprintf("bad-%d", i), pb.MessageMetadata_REQUEST), WithReplyTopic(reply))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Second)
+	if n := handled.Load(); n != 0 {
+		t.Fatalf("expected requests with foreign reply topics to be ignored, got %d handled", n)
+	}
+
+	// 发布响应后关闭临时加入的回复主题
+	for _, ps := range psubs[1:3] {
+		res := make(chan bool, 1)
+		ps.eval <- func() {
+			_, foreign := ps.myTopics["/app/topic"]
+			_, reply := ps.myTopics[dupReply]
+			res <- foreign || reply
+		}
+		if <-res {
+			t.Fatal("expected the server to hold no reply topics after responding")
+		}
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Request(ctx, "echo", nil); err != ErrTopicClosed {
+		t.Fatalf("expected ErrTopicClosed after close, got %v", err)
+	}
+}
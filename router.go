@@ -0,0 +1,143 @@
+// 作用：自定义路由器的插件接口。
+// 功能：允许外部包实现 Router 接口并通过 NewPubSubWithRouter 构造 PubSub，无需修改本包即可实现新的路由策略；
+// 提供路由器在事件循环中查询主题对等节点和发送 RPC 的方法，并为自定义路由器自动追踪对等节点和主题事件。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// Router 是自定义路由器需要实现的接口。
+// 除 Protocols 和 Attach 外，所有方法都在 PubSub 的事件循环中调用，不能阻塞，
+// 可以在其中调用 RouterTopicPeers 和 RouterSendRPC。
+type Router = PubSubRouter
+
+// NewPubSubWithRouter 使用自定义路由器创建 PubSub。
+// 对于自定义路由器，PubSub 负责向追踪器报告对等节点的加入和离开以及主题的加入和离开，路由器只需追踪自己发送的 RPC。
+// 参数:
+//   - ctx: 上下文
+//   - h: 主机
+//   - router: 路由器
+//   - opts: 选项
+//
+// 返回值:
+//   - *PubSub: PubSub 对象
+//   - error: 错误信息，如果有的话
+func NewPubSubWithRouter(ctx context.Context, h host.Host, router Router, opts ...Option) (*PubSub, error) {
+	if router == nil {
+		logger.Warnf("路由器不能为空")
+		return nil, fmt.Errorf("路由器不能为空")
+	}
+	if len(router.Protocols()) == 0 {
+		logger.Warnf("路由器必须至少支持一个协议")
+		return nil, fmt.Errorf("路由器必须至少支持一个协议")
+	}
+
+	switch router.(type) {
+	case *GossipSubRouter, *FloodSubRouter, *RandomSubRouter:
+		// 内置路由器自行追踪事件，并且选项需要对其做类型断言
+		return NewPubSub(ctx, h, router, opts...)
+	default:
+		return NewPubSub(ctx, h, &pluginRouter{Router: router}, opts...)
+	}
+}
+
+// pluginRouter 包装自定义路由器，在调用路由器之前追踪对等节点和主题事件
+type pluginRouter struct {
+	Router
+	tracer *pubsubTracer
+}
+
+// Attach 实现 PubSubRouter 接口
+func (r *pluginRouter) Attach(p *PubSub) {
+	r.tracer = p.tracer
+	r.Router.Attach(p)
+}
+
+// AddPeer 实现 PubSubRouter 接口
+func (r *pluginRouter) AddPeer(p peer.ID, proto protocol.ID) {
+	r.tracer.AddPeer(p, proto)
+	r.Router.AddPeer(p, proto)
+}
+
+// RemovePeer 实现 PubSubRouter 接口
+func (r *pluginRouter) RemovePeer(p peer.ID) {
+	r.tracer.RemovePeer(p)
+	r.Router.RemovePeer(p)
+}
+
+// Join 实现 PubSubRouter 接口
+func (r *pluginRouter) Join(topic string) {
+	r.tracer.Join(topic)
+	r.Router.Join(topic)
+}
+
+// Leave 实现 PubSubRouter 接口
+func (r *pluginRouter) Leave(topic string) {
+	r.tracer.Leave(topic)
+	r.Router.Leave(topic)
+}
+
+// Host 返回 PubSub 使用的主机
+// 返回值:
+//   - host.Host: 主机
+func (p *PubSub) Host() host.Host {
+	return p.host
+}
+
+// RouterTopicPeers 返回已知订阅了主题的对等节点。只能在路由器方法中（即事件循环中）调用。
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - []peer.ID: 对等节点列表
+func (p *PubSub) RouterTopicPeers(topic string) []peer.ID {
+	tmap := p.topics[topic]
+	peers := make([]peer.ID, 0, len(tmap))
+	for pid := range tmap {
+		peers = append(peers, pid)
+	}
+	return peers
+}
+
+// RouterSendRPC 将 RPC 放入对等节点的出站队列，并按出站带宽和内存预算整形。只能在路由器方法中（即事件循环中）调用。
+// 超出最大传输大小的 RPC 在写入时被拆分。
+// 参数:
+//   - pid: 对等节点
+//   - out: RPC
+//
+// 返回值:
+//   - bool: RPC 是否已放入队列
+func (p *PubSub) RouterSendRPC(pid peer.ID, out *RPC) bool {
+	mch, ok := p.peers[pid]
+	if !ok {
+		return false
+	}
+
+	out, ok = p.shapeRPC(out)
+	if !ok {
+		return false
+	}
+	if !p.reserveRPC(out) {
+		logger.Infof("丢弃消息到对等节点 %s: 内存预算不足", pid)
+		p.tracer.DropRPC(out, pid)
+		return false
+	}
+
+	select {
+	case mch <- out:
+		p.tracer.SendRPC(out, pid)
+		return true
+	default:
+		p.releaseRPC(out)
+		logger.Infof("丢弃消息到对等节点 %s: 队列已满", pid)
+		p.tracer.DropRPC(out, pid)
+		return false
+	}
+}
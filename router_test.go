@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// echoRouter 是测试用的自定义路由器，将消息转发给主题的所有对等节点
+type echoRouter struct {
+	p     *PubSub
+	peers map[peer.ID]protocol.ID
+}
+
+func (r *echoRouter) Protocols() []protocol.ID                     { return []protocol.ID{"/echosub/1.0.0"} }
+func (r *echoRouter) Attach(p *PubSub)                             { r.p = p }
+func (r *echoRouter) AddPeer(pid peer.ID, proto protocol.ID)       { r.peers[pid] = proto }
+func (r *echoRouter) RemovePeer(pid peer.ID)                       { delete(r.peers, pid) }
+func (r *echoRouter) EnoughPeers(topic string, suggested int) bool { return true }
+func (r *echoRouter) AcceptFrom(peer.ID) AcceptStatus              { return AcceptAll }
+func (r *echoRouter) HandleRPC(*RPC)                               {}
+func (r *echoRouter) Join(string)                                  {}
+func (r *echoRouter) Leave(string)                                 {}
+
+func (r *echoRouter) Publish(msg *Message) {
+	for _, pid := range r.p.RouterTopicPeers(msg.GetTopic()) {
+		if pid == msg.ReceivedFrom {
+			continue
+		}
+		r.p.RouterSendRPC(pid, &RPC{RPC: pb.RPC{Publish: []*pb.Message{msg.Message}}})
+	}
+}
+
+// TestNewPubSubWithRouter 测试使用自定义路由器构造的 PubSub 能够收发消息
+func TestNewPubSubWithRouter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	if _, err := NewPubSubWithRouter(ctx, hosts[0], nil); err == nil {
+		t.Fatal("expected error for a nil router")
+	}
+
+	var psubs []*PubSub
+	var routers []*echoRouter
+	for _, h := range hosts {
+		r := &echoRouter{peers: make(map[peer.ID]protocol.ID)}
+		ps, err := NewPubSubWithRouter(ctx, h, r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		psubs = append(psubs, ps)
+		routers = append(routers, r)
+	}
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("hello")
+	if err := topic.Publish(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, msg)
+
+	protos := make(chan protocol.ID, 1)
+	psubs[0].eval <- func() { protos <- routers[0].peers[hosts[1].ID()] }
+	if proto := <-protos; proto != "/echosub/1.0.0" {
+		t.Fatalf("expected the custom router to see its protocol, got %q", proto)
+	}
+}
@@ -0,0 +1,157 @@
+// 作用：负载模式验证。
+// 功能：为主题注册负载模式验证器，在默认验证器和主题验证器之前同步运行，
+// 不符合模式的消息被拒绝，并与其他验证失败一样计入发送方的无效消息评分惩罚。
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/gogo/protobuf/proto"
+)
+
+// SchemaValidator 检查消息负载是否符合模式
+type SchemaValidator interface {
+	// Validate 在负载不符合模式时返回错误
+	Validate(data []byte) error
+}
+
+// SchemaValidatorFunc 将函数适配为 SchemaValidator
+type SchemaValidatorFunc func(data []byte) error
+
+// Validate 实现 SchemaValidator 接口
+func (f SchemaValidatorFunc) Validate(data []byte) error { return f(data) }
+
+// JSONType 表示 JSON 值的类型
+type JSONType string
+
+// JSON 值的类型常量
+const (
+	JSONString  JSONType = "string"  // 字符串
+	JSONNumber  JSONType = "number"  // 数字
+	JSONBoolean JSONType = "boolean" // 布尔值
+	JSONObject  JSONType = "object"  // 对象
+	JSONArray   JSONType = "array"   // 数组
+	JSONNull    JSONType = "null"    // 空值
+)
+
+// JSONSchema 是 JSON 对象模式的一个子集：负载必须是 JSON 对象，
+// 必需属性必须存在，声明了类型的属性必须匹配类型
+type JSONSchema struct {
+	Properties           map[string]JSONType // 属性及其类型
+	Required             []string            // 必需的属性
+	AdditionalProperties bool                // 是否允许未声明的属性
+}
+
+// Validate 实现 SchemaValidator 接口
+func (s *JSONSchema) Validate(data []byte) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("负载不是 JSON 对象: %w", err)
+	}
+
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("缺少必需的属性 %s", name)
+		}
+	}
+	for name, raw := range obj {
+		typ, ok := s.Properties[name]
+		if !ok {
+			if !s.AdditionalProperties {
+				return fmt.Errorf("未声明的属性 %s", name)
+			}
+			continue
+		}
+		if got := jsonTypeOf(raw); got != typ {
+			return fmt.Errorf("属性 %s 的类型为 %s，期望 %s", name, got, typ)
+		}
+	}
+	return nil
+}
+
+// jsonTypeOf 返回 JSON 值的类型
+// 参数:
+//   - raw: 合法的 JSON 值
+//
+// 返回值:
+//   - JSONType: 值的类型
+func jsonTypeOf(raw json.RawMessage) JSONType {
+	for _, c := range raw {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '"':
+			return JSONString
+		case '{':
+			return JSONObject
+		case '[':
+			return JSONArray
+		case 't', 'f':
+			return JSONBoolean
+		case 'n':
+			return JSONNull
+		default:
+			return JSONNumber
+		}
+	}
+	return JSONNull
+}
+
+// ProtoSchema 要求负载能够按给定的 protobuf 消息类型解码
+type ProtoSchema struct {
+	newMsg func() proto.Message // 创建用于解码的空消息
+}
+
+// NewProtoSchema 创建 protobuf 模式验证器
+// 参数:
+//   - newMsg: 创建用于解码的空消息
+//
+// 返回值:
+//   - *ProtoSchema: protobuf 模式验证器
+func NewProtoSchema(newMsg func() proto.Message) *ProtoSchema {
+	return &ProtoSchema{newMsg: newMsg}
+}
+
+// Validate 实现 SchemaValidator 接口
+func (s *ProtoSchema) Validate(data []byte) error {
+	return proto.Unmarshal(data, s.newMsg())
+}
+
+// WithSchemaValidator 为主题注册负载模式验证器，每个主题只能有一个模式验证器
+// 参数:
+//   - topic: 主题名称
+//   - schema: 模式验证器
+//
+// 返回值:
+//   - Option: 配置选项
+func WithSchemaValidator(topic string, schema SchemaValidator) Option {
+	return func(ps *PubSub) error {
+		if schema == nil {
+			logger.Warnf("模式验证器不能为空")
+			return fmt.Errorf("模式验证器不能为空")
+		}
+		if _, ok := ps.val.schemaVals[topic]; ok {
+			logger.Warnf("主题 %s 存在重复的模式验证器", topic)
+			return fmt.Errorf("主题 %s 存在重复的模式验证器", topic)
+		}
+
+		validate := func(_ context.Context, src peer.ID, msg *Message) ValidationResult {
+			if err := schema.Validate(msg.Data); err != nil {
+				logger.Debugf("来自 %s 的消息不符合主题 %s 的模式: %s", src, topic, err)
+				return ValidationReject
+			}
+			return ValidationAccept
+		}
+		val, err := ps.val.makeValidator(&addValReq{topic: topic, validate: validate, inline: true})
+		if err != nil {
+			return err
+		}
+
+		ps.val.schemaVals[topic] = val
+		return nil
+	}
+}
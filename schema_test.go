@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/gogo/protobuf/proto"
+)
+
+// TestJSONSchema 测试 JSON 对象模式的必需属性、属性类型和未声明属性检查
+func TestJSONSchema(t *testing.T) {
+	schema := &JSONSchema{
+		Properties: map[string]JSONType{"name": JSONString, "count": JSONNumber, "tags": JSONArray},
+		Required:   []string{"name"},
+	}
+
+	for data, ok := range map[string]bool{
+		`{"name":"a","count":1,"tags":[]}`: true,
+		`{"name":"a"}`:                     true,
+		`{"count":1}`:                      false,
+		`{"name":1}`:                       false,
+		`{"name":"a","extra":true}`:        false,
+		`["name"]`:                         false,
+		`not json`:                         false,
+	} {
+		if err := schema.Validate([]byte(data)); (err == nil) != ok {
+			t.Fatalf("unexpected result for %s: %v", data, err)
+		}
+	}
+
+	schema.AdditionalProperties = true
+	if err := schema.Validate([]byte(`{"name":"a","extra":true}`)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestProtoSchema 测试 protobuf 模式验证器
+func TestProtoSchema(t *testing.T) {
+	schema := NewProtoSchema(func() proto.Message { return new(pb.Annotation) })
+	data, err := (&pb.Annotation{Key: "k"}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate([]byte{0xff}); err == nil {
+		t.Fatal("expected error for malformed protobuf")
+	}
+}
+
+// TestSchemaValidator 测试不符合模式的消息在应用验证器之前被拒绝
+func TestSchemaValidator(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	schema := &JSONSchema{Properties: map[string]JSONType{"name": JSONString}, Required: []string{"name"}}
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1], WithSchemaValidator("foo", schema)),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	var validated int
+	err := psubs[1].RegisterTopicValidator("foo", func(context.Context, peer.ID, *Message) bool {
+		validated++
+		return true
+	}, WithValidatorInline(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range []string{`{"name":1}`, `{}`, `{"name":"ok"}`} {
+		if err := topic.Publish(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != `{"name":"ok"}` {
+		t.Fatalf("unexpected message %s", msg.Data)
+	}
+	if validated != 1 {
+		t.Fatalf("expected the topic validator to run only for the conforming message, ran %d times", validated)
+	}
+
+	if _, err := NewFloodSub(ctx, getDefaultHosts(t, 1)[0], WithSchemaValidator("foo", schema), WithSchemaValidator("foo", schema)); err == nil {
+		t.Fatal("expected error for a duplicate schema validator")
+	}
+}
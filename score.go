@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -23,8 +24,18 @@ type peerStats struct {
 	expire           time.Time              // 断开连接的对等节点的分数统计信息过期时间
 	topics           map[string]*topicStats // 每个主题的统计信息
 	ips              []string               // IP 跟踪信息，存储为字符串以便处理
+	observedIPs      map[string]time.Time   // 观察到的全部 IP 及最近一次观察到的时间
+	paths            map[string]*pathStats  // 每个连接地址的交付统计信息
 	ipWhitelist      map[string]bool        // IP 白名单缓存
 	behaviourPenalty float64                // 行为模式处罚（由路由器应用）
+	iwantFulfilled   float64                // 及时响应的 IWANT 请求计数（由路由器应用）
+	iwantUnfulfilled float64                // 未响应的 IWANT 请求计数（由路由器应用）
+}
+
+// pathStats 包含对等节点经由某个连接地址的交付统计信息
+type pathStats struct {
+	firstMessageDeliveries     float64 // 首次消息交付数
+	duplicateMessageDeliveries float64 // 重复消息交付数
 }
 
 // topicStats 包含主题的统计信息
@@ -104,6 +115,16 @@ type PeerScoreSnapshot struct {
 	AppSpecificScore   float64                        // 应用程序特定的分数
 	IPColocationFactor float64                        // IP 同位因素
 	BehaviourPenalty   float64                        // 行为模式处罚
+	IWantFulfilled     float64                        // 及时响应的 IWANT 请求计数
+	IWantUnfulfilled   float64                        // 未响应的 IWANT 请求计数
+	IPs                []string                       // 参与 IP 同位计算的全部 IP
+	Paths              map[string]*PathScoreSnapshot  // 每个连接地址的交付快照
+}
+
+// PathScoreSnapshot 包含对等节点经由某个连接地址的交付快照
+type PathScoreSnapshot struct {
+	FirstMessageDeliveries     float64 // 首次消息交付数
+	DuplicateMessageDeliveries float64 // 重复消息交付数
 }
 
 // TopicScoreSnapshot 包含主题分数快照
@@ -339,6 +360,17 @@ func (ps *peerScore) score(p peer.ID) float64 {
 		score += p7 * ps.params.BehaviourPenaltyWeight
 	}
 
+	// P8: IWANT 响应奖励
+	p8 := pstats.iwantFulfilled
+	if p8 > ps.params.IWantFulfillmentCap {
+		p8 = ps.params.IWantFulfillmentCap
+	}
+	score += p8 * ps.params.IWantFulfillmentWeight
+
+	// P8b: IWANT 未响应惩罚
+	p8b := pstats.iwantUnfulfilled
+	score += p8b * ps.params.IWantUnfulfilledWeight
+
 	return score
 }
 
@@ -418,6 +450,28 @@ func (ps *peerScore) AddPenalty(p peer.ID, count int) {
 	pstats.behaviourPenalty += float64(count)
 }
 
+// AddIWantFulfillment 记录对等节点对我们 IWANT 请求的响应情况
+// 参数:
+//   - p: peer.ID，对等节点 ID
+//   - fulfilled: int，及时响应的请求数
+//   - unfulfilled: int，未响应的请求数
+func (ps *peerScore) AddIWantFulfillment(p peer.ID, fulfilled, unfulfilled int) {
+	if ps == nil {
+		return
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	pstats, ok := ps.peerStats[p]
+	if !ok {
+		return
+	}
+
+	pstats.iwantFulfilled += float64(fulfilled)
+	pstats.iwantUnfulfilled += float64(unfulfilled)
+}
+
 // background 处理定期维护任务
 // 参数:
 //   - ctx: context.Context，上下文
@@ -515,6 +569,18 @@ func (ps *peerScore) inspectScoresExtended() {
 		pss.AppSpecificScore = ps.params.AppSpecificScore(p) // 应用特定分数
 		pss.IPColocationFactor = ps.ipColocationFactor(p)    // IP 合作因素
 		pss.BehaviourPenalty = pstats.behaviourPenalty       // 行为惩罚
+		pss.IWantFulfilled = pstats.iwantFulfilled           // 及时响应的 IWANT 请求计数
+		pss.IWantUnfulfilled = pstats.iwantUnfulfilled       // 未响应的 IWANT 请求计数
+		pss.IPs = append([]string(nil), pstats.ips...)       // 参与 IP 同位计算的全部 IP
+		if len(pstats.paths) > 0 {
+			pss.Paths = make(map[string]*PathScoreSnapshot, len(pstats.paths))
+			for addr, st := range pstats.paths {
+				pss.Paths[addr] = &PathScoreSnapshot{
+					FirstMessageDeliveries:     st.firstMessageDeliveries,
+					DuplicateMessageDeliveries: st.duplicateMessageDeliveries,
+				}
+			}
+		}
 		scores[p] = pss
 	}
 	ps.Unlock()
@@ -578,6 +644,16 @@ func (ps *peerScore) refreshScores() {
 		if pstats.behaviourPenalty < ps.params.DecayToZero {
 			pstats.behaviourPenalty = 0
 		}
+
+		// 衰减 P8 计数器
+		pstats.iwantFulfilled *= ps.params.IWantFulfillmentDecay
+		if pstats.iwantFulfilled < ps.params.DecayToZero {
+			pstats.iwantFulfilled = 0
+		}
+		pstats.iwantUnfulfilled *= ps.params.IWantFulfillmentDecay
+		if pstats.iwantUnfulfilled < ps.params.DecayToZero {
+			pstats.iwantUnfulfilled = 0
+		}
 	}
 }
 
@@ -589,9 +665,8 @@ func (ps *peerScore) refreshIPs() {
 	// 对等节点的 IP 可能会更改，因此定期刷新
 	for p, pstats := range ps.peerStats {
 		if pstats.connected {
-			ips := ps.getIPs(p)
-			ps.setIPs(p, ips, pstats.ips)
-			pstats.ips = ips
+			ps.updateIPs(p, pstats)
+			ps.prunePaths(p, pstats)
 		}
 	}
 }
@@ -623,9 +698,7 @@ func (ps *peerScore) AddPeer(p peer.ID, proto protocol.ID) {
 
 	// 标记节点为已连接
 	pstats.connected = true
-	ips := ps.getIPs(p)
-	ps.setIPs(p, ips, pstats.ips)
-	pstats.ips = ips
+	ps.updateIPs(p, pstats)
 }
 
 // RemovePeer 移除节点。
@@ -753,6 +826,7 @@ func (ps *peerScore) DeliverMessage(msg *Message) {
 	defer ps.Unlock()
 
 	ps.markFirstMessageDelivery(msg.ReceivedFrom, msg)
+	ps.markPathDelivery(msg, false)
 
 	drec := ps.deliveries.getRecord(ps.idGen.ID(msg))
 
@@ -782,7 +856,7 @@ func (ps *peerScore) RejectMessage(msg *Message, reason string) {
 
 	switch reason {
 	// 这些消息被认为是无效的，需要惩罚发送这些消息的节点
-	case RejectMissingSignature, RejectInvalidSignature, RejectUnexpectedSignature, RejectUnexpectedAuthInfo, RejectSelfOrigin:
+	case RejectMissingSignature, RejectInvalidSignature, RejectUnexpectedSignature, RejectUnexpectedAuthInfo, RejectSelfOrigin, RejectHeadersTooLarge, RejectMessageTooLarge, RejectUnauthorizedPublisher:
 		ps.markInvalidMessageDelivery(msg.ReceivedFrom, msg)
 		return
 
@@ -834,6 +908,8 @@ func (ps *peerScore) DuplicateMessage(msg *Message) {
 	ps.Lock()
 	defer ps.Unlock()
 
+	ps.markPathDelivery(msg, true)
+
 	// 获取消息交付记录
 	drec := ps.deliveries.getRecord(ps.idGen.ID(msg))
 
@@ -852,7 +928,12 @@ func (ps *peerScore) DuplicateMessage(msg *Message) {
 	case deliveryValid:
 		// 标记节点交付时间，只计入一次重复交付
 		drec.peers[msg.ReceivedFrom] = struct{}{}
-		ps.markDuplicateMessageDelivery(msg.ReceivedFrom, msg, drec.validated)
+		validated := drec.validated
+		if msg.toleratedDuplicate {
+			// 主题容忍度之内的重复不受交付窗口限制
+			validated = time.Time{}
+		}
+		ps.markDuplicateMessageDelivery(msg.ReceivedFrom, msg, validated)
 
 	case deliveryInvalid:
 		// 不再跟踪交付时间
@@ -1113,6 +1194,95 @@ func (ps *peerScore) getIPs(p peer.ID) []string {
 	return res
 }
 
+// updateIPs 记录对等节点当前连接的 IP，并用保留期内观察到的全部 IP 更新 IP 同位跟踪，
+// 避免对等节点通过在多个 IP 之间轮换来规避同位惩罚。
+// 参数:
+// - p: 节点 ID。
+// - pstats: 节点统计信息。
+func (ps *peerScore) updateIPs(p peer.ID, pstats *peerStats) {
+	ips := pstats.observeIPs(ps.getIPs(p), time.Now(), ps.params.RetainScore)
+	ps.setIPs(p, ips, pstats.ips)
+	pstats.ips = ips
+}
+
+// observeIPs 记录当前观察到的 IP，并返回保留期内观察到的全部 IP。
+// 参数:
+// - current: 当前连接的 IP 地址列表。
+// - now: 当前时间。
+// - retain: 未再观察到的 IP 的保留时间。
+// 返回值:
+// - []string: 保留期内观察到的全部 IP 地址。
+func (pstats *peerStats) observeIPs(current []string, now time.Time, retain time.Duration) []string {
+	if pstats.observedIPs == nil {
+		pstats.observedIPs = make(map[string]time.Time)
+	}
+	for _, ip := range current {
+		pstats.observedIPs[ip] = now
+	}
+
+	ips := make([]string, 0, len(pstats.observedIPs))
+	for ip, seen := range pstats.observedIPs {
+		if now.Sub(seen) > retain {
+			delete(pstats.observedIPs, ip)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+
+	return ips
+}
+
+// markPathDelivery 记录对等节点经由某个连接地址的消息交付。
+// 参数:
+// - msg: 交付的消息。
+// - duplicate: 是否为重复交付。
+func (ps *peerScore) markPathDelivery(msg *Message, duplicate bool) {
+	if msg.receivedPath == "" {
+		return
+	}
+
+	pstats, ok := ps.peerStats[msg.ReceivedFrom]
+	if !ok {
+		return
+	}
+
+	if pstats.paths == nil {
+		pstats.paths = make(map[string]*pathStats)
+	}
+	st, ok := pstats.paths[msg.receivedPath]
+	if !ok {
+		st = &pathStats{}
+		pstats.paths[msg.receivedPath] = st
+	}
+
+	if duplicate {
+		st.duplicateMessageDeliveries++
+	} else {
+		st.firstMessageDeliveries++
+	}
+}
+
+// prunePaths 移除对等节点已关闭的连接地址的交付统计信息。
+// 参数:
+// - p: 节点 ID。
+// - pstats: 节点统计信息。
+func (ps *peerScore) prunePaths(p peer.ID, pstats *peerStats) {
+	if ps.host == nil || len(pstats.paths) == 0 {
+		return
+	}
+
+	open := make(map[string]struct{})
+	for _, c := range ps.host.Network().ConnsToPeer(p) {
+		open[c.RemoteMultiaddr().String()] = struct{}{}
+	}
+	for addr := range pstats.paths {
+		if _, ok := open[addr]; !ok {
+			delete(pstats.paths, addr)
+		}
+	}
+}
+
 // setIPs 添加新 IP 地址并移除过期的 IP 地址。
 // 参数:
 // - p: 节点 ID。
@@ -76,6 +76,10 @@ type PeerScoreParams struct {
 	BehaviourPenaltyWeight      float64                      // 行为模式处罚的权重
 	BehaviourPenaltyThreshold   float64                      // 行为模式处罚的阈值
 	BehaviourPenaltyDecay       float64                      // 行为模式处罚的衰减
+	IWantFulfillmentWeight      float64                      // 及时响应 IWANT 请求的奖励权重，必须为非负值
+	IWantFulfillmentCap         float64                      // 及时响应 IWANT 请求计数器的上限
+	IWantUnfulfilledWeight      float64                      // 未响应 IWANT 请求的惩罚权重，必须为非正值
+	IWantFulfillmentDecay       float64                      // IWANT 响应计数器的衰减
 	DecayInterval               time.Duration                // 参数计数器的衰减间隔
 	DecayToZero                 float64                      // 计数器值低于该值时被视为 0
 	RetainScore                 time.Duration                // 断开连接的对等节点记住计数器的时间
@@ -145,6 +149,12 @@ func (p *PeerScoreParams) validate() error {
 			return fmt.Errorf("BehaviourPenaltyThreshold 无效: %f", p.BehaviourPenaltyThreshold)
 		}
 	}
+	// IWANT 响应评分是可选的，只有设置了权重时才验证
+	if p.IWantFulfillmentWeight != 0 || p.IWantUnfulfilledWeight != 0 {
+		if err := p.validateIWantFulfillmentParams(); err != nil {
+			return err
+		}
+	}
 	// 如果没有跳过原子验证，或者 DecayInterval 或 DecayToZero 不为 0
 	if !p.SkipAtomicValidation || p.DecayInterval != 0 || p.DecayToZero != 0 {
 		// 验证 DecayInterval 是否小于 1 秒
@@ -161,6 +171,33 @@ func (p *PeerScoreParams) validate() error {
 	return nil // 所有验证通过，返回 nil 表示没有错误
 }
 
+// validateIWantFulfillmentParams 验证 IWANT 响应评分参数
+// 返回值:
+//   - error: 错误信息
+func (p *PeerScoreParams) validateIWantFulfillmentParams() error {
+	// 验证 IWantFulfillmentWeight 是否小于 0 或无效
+	if p.IWantFulfillmentWeight < 0 || isInvalidNumber(p.IWantFulfillmentWeight) {
+		logger.Warnf("IWantFulfillmentWeight 无效: %f", p.IWantFulfillmentWeight)
+		return fmt.Errorf("IWantFulfillmentWeight 无效: %f", p.IWantFulfillmentWeight)
+	}
+	// 验证 IWantFulfillmentCap 是否小于等于 0 或无效
+	if p.IWantFulfillmentWeight != 0 && (p.IWantFulfillmentCap <= 0 || isInvalidNumber(p.IWantFulfillmentCap)) {
+		logger.Warnf("IWantFulfillmentCap 无效: %f", p.IWantFulfillmentCap)
+		return fmt.Errorf("IWantFulfillmentCap 无效: %f", p.IWantFulfillmentCap)
+	}
+	// 验证 IWantUnfulfilledWeight 是否大于 0 或无效
+	if p.IWantUnfulfilledWeight > 0 || isInvalidNumber(p.IWantUnfulfilledWeight) {
+		logger.Warnf("IWantUnfulfilledWeight 无效: %f", p.IWantUnfulfilledWeight)
+		return fmt.Errorf("IWantUnfulfilledWeight 无效: %f", p.IWantUnfulfilledWeight)
+	}
+	// 验证 IWantFulfillmentDecay 是否不在 (0, 1) 区间内或无效
+	if p.IWantFulfillmentDecay <= 0 || p.IWantFulfillmentDecay >= 1 || isInvalidNumber(p.IWantFulfillmentDecay) {
+		logger.Warnf("IWantFulfillmentDecay 无效: %f", p.IWantFulfillmentDecay)
+		return fmt.Errorf("IWantFulfillmentDecay 无效: %f", p.IWantFulfillmentDecay)
+	}
+	return nil
+}
+
 // TopicScoreParams 包含用于控制主题分数的参数
 type TopicScoreParams struct {
 	SkipAtomicValidation            bool          // 是否允许仅设置某些参数而不是所有参数
@@ -742,6 +742,40 @@ func TestPeerScoreParamsValidation_ValidParams_SkipAtomicValidation(t *testing.T
 	})
 }
 
+// TestPeerScoreParamsValidation_IWantFulfillment 测试 IWANT 响应评分参数的验证。
+func TestPeerScoreParamsValidation_IWantFulfillment(t *testing.T) {
+	newParams := func() *PeerScoreParams {
+		return &PeerScoreParams{
+			SkipAtomicValidation:   true,
+			DecayInterval:          time.Second,
+			DecayToZero:            0.01,
+			IWantFulfillmentWeight: 1,
+			IWantFulfillmentCap:    10,
+			IWantUnfulfilledWeight: -1,
+			IWantFulfillmentDecay:  0.9,
+		}
+	}
+
+	if err := newParams().validate(); err != nil {
+		t.Fatalf("expected validation success, got: %s", err)
+	}
+
+	invalid := []func(p *PeerScoreParams){
+		func(p *PeerScoreParams) { p.IWantFulfillmentWeight = -1 },        // 奖励权重为负
+		func(p *PeerScoreParams) { p.IWantFulfillmentCap = 0 },            // 上限为 0
+		func(p *PeerScoreParams) { p.IWantUnfulfilledWeight = 1 },         // 惩罚权重为正
+		func(p *PeerScoreParams) { p.IWantFulfillmentDecay = 1 },          // 衰减不在 (0, 1) 区间
+		func(p *PeerScoreParams) { p.IWantFulfillmentDecay = math.NaN() }, // 衰减无效
+	}
+	for i, set := range invalid {
+		params := newParams()
+		set(params)
+		if params.validate() == nil {
+			t.Fatalf("expected validation error for case %d", i)
+		}
+	}
+}
+
 // TestScoreParameterDecay 测试 ScoreParameterDecay 函数。
 func TestScoreParameterDecay(t *testing.T) {
 	decay1hr := ScoreParameterDecay(time.Hour) // 计算一小时的衰减
@@ -802,6 +802,86 @@ func TestScoreIPColocationWhitelist(t *testing.T) {
 
 }
 
+func TestScoreIPColocationObservedIPs(t *testing.T) {
+	params := &PeerScoreParams{
+		AppSpecificScore:            func(peer.ID) float64 { return 0 },
+		IPColocationFactorThreshold: 1,
+		IPColocationFactorWeight:    -1,
+		RetainScore:                 time.Minute,
+		Topics:                      make(map[string]*TopicScoreParams),
+	}
+
+	peerA := peer.ID("A")
+	peerB := peer.ID("B")
+
+	ps := newPeerScore(params)
+	ps.AddPeer(peerA, "myproto")
+	ps.AddPeer(peerB, "myproto")
+
+	// peer A rotates from 1.2.3.4 to 2.3.4.5 while peer B sits on 1.2.3.4;
+	// the old address is still counted against A within the retention period
+	now := time.Now()
+	observe := func(p peer.ID, at time.Time, current ...string) {
+		pstats := ps.peerStats[p]
+		ips := pstats.observeIPs(current, at, params.RetainScore)
+		ps.setIPs(p, ips, pstats.ips)
+		pstats.ips = ips
+	}
+	observe(peerA, now, "1.2.3.4")
+	observe(peerA, now.Add(time.Second), "2.3.4.5")
+	observe(peerB, now.Add(time.Second), "1.2.3.4")
+
+	if ips := ps.peerStats[peerA].ips; len(ips) != 2 {
+		t.Fatalf("expected peer A to have 2 observed IPs, got %v", ips)
+	}
+	if score := ps.Score(peerB); score != -1 {
+		t.Fatalf("expected peer B to be penalized for colocation, got %f", score)
+	}
+
+	// once the retention period passes the old address is dropped
+	observe(peerA, now.Add(2*time.Minute), "2.3.4.5")
+	if ips := ps.peerStats[peerA].ips; len(ips) != 1 || ips[0] != "2.3.4.5" {
+		t.Fatalf("expected peer A to have only its current IP, got %v", ips)
+	}
+	if score := ps.Score(peerB); score != 0 {
+		t.Fatalf("expected peer B to have score 0.0, got %f", score)
+	}
+}
+
+func TestScorePathDeliveries(t *testing.T) {
+	mytopic := "mytopic"
+	params := &PeerScoreParams{
+		AppSpecificScore: func(peer.ID) float64 { return 0 },
+		Topics:           make(map[string]*TopicScoreParams),
+	}
+	peerA := peer.ID("A")
+
+	ps := newPeerScore(params)
+	ps.AddPeer(peerA, "myproto")
+
+	// deliver the same messages from peer A over two paths
+	for i := 0; i < 10; i++ {
+		pbMsg := makeTestMessage(i)
+		pbMsg.Topic = mytopic
+		first := Message{ReceivedFrom: peerA, Message: pbMsg, receivedPath: "/ip4/1.2.3.4/tcp/4001"}
+		dup := Message{ReceivedFrom: peerA, Message: pbMsg, receivedPath: "/ip6/::1/udp/4001/quic-v1"}
+		ps.ValidateMessage(&first)
+		ps.DeliverMessage(&first)
+		ps.DuplicateMessage(&dup)
+	}
+
+	paths := ps.peerStats[peerA].paths
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(paths))
+	}
+	if st := paths["/ip4/1.2.3.4/tcp/4001"]; st.firstMessageDeliveries != 10 || st.duplicateMessageDeliveries != 0 {
+		t.Fatalf("unexpected stats for tcp path: %+v", st)
+	}
+	if st := paths["/ip6/::1/udp/4001/quic-v1"]; st.firstMessageDeliveries != 0 || st.duplicateMessageDeliveries != 10 {
+		t.Fatalf("unexpected stats for quic path: %+v", st)
+	}
+}
+
 func TestScoreBehaviourPenalty(t *testing.T) {
 	params := &PeerScoreParams{
 		AppSpecificScore:       func(peer.ID) float64 { return 0 },
@@ -858,6 +938,50 @@ func TestScoreBehaviourPenalty(t *testing.T) {
 	}
 }
 
+func TestScoreIWantFulfillment(t *testing.T) {
+	params := &PeerScoreParams{
+		AppSpecificScore:       func(peer.ID) float64 { return 0 },
+		IWantFulfillmentWeight: 1,
+		IWantFulfillmentCap:    10,
+		IWantUnfulfilledWeight: -2,
+		IWantFulfillmentDecay:  0.5,
+	}
+
+	peerA := peer.ID("A")
+
+	var ps *peerScore
+
+	// AddIWantFulfillment on a nil peerScore
+	ps.AddIWantFulfillment(peerA, 1, 1)
+
+	ps = newPeerScore(params)
+
+	// non-existent peer
+	ps.AddIWantFulfillment(peerA, 1, 0)
+	if aScore := ps.Score(peerA); aScore != 0 {
+		t.Errorf("expected peer score to be 0, got %f", aScore)
+	}
+
+	ps.AddPeer(peerA, "myproto")
+
+	ps.AddIWantFulfillment(peerA, 4, 0)
+	if aScore := ps.Score(peerA); aScore != 4 {
+		t.Errorf("expected peer score to be 4, got %f", aScore)
+	}
+
+	// the positive component is capped
+	ps.AddIWantFulfillment(peerA, 20, 1)
+	if aScore := ps.Score(peerA); aScore != 8 {
+		t.Errorf("expected peer score to be 8, got %f", aScore)
+	}
+
+	// decay: fulfilled 24 -> 12 (capped at 10), unfulfilled 1 -> 0.5
+	ps.refreshScores()
+	if aScore := ps.Score(peerA); aScore != 9 {
+		t.Errorf("expected peer score to be 9, got %f", aScore)
+	}
+}
+
 func TestScoreRetention(t *testing.T) {
 	// Create parameters with reasonable default values
 	mytopic := "mytopic"
@@ -0,0 +1,400 @@
+// 作用：基于文件的持久化已见消息缓存。
+// 功能：已见消息 ID 和首次看到的时间先缓冲在内存中，由后台协程批量追加写入日志文件，内存中保留未过期的 ID；重新打开时从日志恢复
+// SeenMsgTTL 之内的 ID，使重启的节点不会重复处理最近看到的消息。过期的记录超过保留的记录时，后台协程基于保留 ID 的快照重写日志，
+// 重写期间不阻塞事件循环。
+
+package pubsub
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dep2p/pubsub/timecache"
+)
+
+const (
+	// seenLogMaxRecord 是单条已见消息记录的最大字节数，用于识别损坏的记录
+	seenLogMaxRecord = 64 << 10
+
+	// seenLogMinCompact 是触发日志重写的最少过期记录数
+	seenLogMinCompact = 1024
+
+	// seenLogFlushSize 是立即唤醒后台协程写入日志的缓冲字节数
+	seenLogFlushSize = 64 << 10
+)
+
+var (
+	// seenLogSweepInterval 是清理过期 ID 的间隔时间
+	seenLogSweepInterval = time.Minute
+
+	// seenLogFlushInterval 是后台协程将缓冲的记录写入日志的间隔时间
+	seenLogFlushInterval = 100 * time.Millisecond
+)
+
+// FileSeenCache 是基于文件的持久化已见消息缓存，按首次看到的时间过期
+type FileSeenCache struct {
+	path string        // 日志文件路径
+	ttl  time.Duration // 消息 ID 的存活时间
+
+	mx       sync.Mutex
+	f        *os.File             // 日志文件，关闭后为 nil
+	m        map[string]time.Time // 消息 ID 到首次看到时间的映射
+	records  int                  // 日志文件中的记录数，包括已过期的记录
+	pending  []byte               // 尚未写入日志的记录
+	npending int                  // pending 中的记录数
+
+	writeMx sync.Mutex    // 串行化日志写入，先于 mx 获取
+	flushCh chan struct{} // 唤醒后台协程写入日志
+
+	done func() // 停止后台协程
+}
+
+// 确保 FileSeenCache 实现了 SeenCache 接口
+var _ SeenCache = (*FileSeenCache)(nil)
+
+// NewFileSeenCache 创建基于文件的已见消息缓存，并从已有的日志恢复存活时间之内的消息 ID
+// 参数:
+//   - path: 日志文件路径，所在目录不存在时创建
+//   - ttl: 消息 ID 的存活时间
+//
+// 返回值:
+//   - *FileSeenCache: 已见消息缓存
+//   - error: 错误信息
+func NewFileSeenCache(path string, ttl time.Duration) (*FileSeenCache, error) {
+	if ttl <= 0 {
+		logger.Warnf("已见消息缓存的存活时间必须为正数")
+		return nil, fmt.Errorf("已见消息缓存的存活时间必须为正数")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	tc := &FileSeenCache{
+		path:    path,
+		ttl:     ttl,
+		m:       make(map[string]time.Time),
+		flushCh: make(chan struct{}, 1),
+	}
+	if err := tc.load(time.Now()); err != nil {
+		return nil, err
+	}
+
+	ctx, done := context.WithCancel(context.Background())
+	tc.done = done
+	go tc.background(ctx)
+
+	return tc, nil
+}
+
+// Add 将消息 ID 添加到缓存，记录由后台协程批量写入日志
+// 参数:
+//   - s: 消息 ID
+//
+// 返回值:
+//   - bool: 消息 ID 之前是否不在缓存中
+func (tc *FileSeenCache) Add(s string) bool {
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+
+	now := time.Now()
+	if seen, ok := tc.m[s]; ok && now.Sub(seen) <= tc.ttl {
+		return false
+	}
+	tc.m[s] = now
+
+	if tc.f != nil {
+		tc.pending = append(tc.pending, encodeSeenRecord(s, now)...)
+		tc.npending++
+		if len(tc.pending) >= seenLogFlushSize {
+			select {
+			case tc.flushCh <- struct{}{}:
+			default:
+			}
+		}
+	}
+	return true
+}
+
+// Has 检查消息 ID 是否在缓存中
+// 参数:
+//   - s: 消息 ID
+//
+// 返回值:
+//   - bool: 消息 ID 是否在缓存中
+func (tc *FileSeenCache) Has(s string) bool {
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+
+	seen, ok := tc.m[s]
+	return ok && time.Since(seen) <= tc.ttl
+}
+
+// Done 停止后台协程，写入缓冲的记录并关闭日志文件
+func (tc *FileSeenCache) Done() {
+	tc.done()
+	tc.flush()
+
+	tc.writeMx.Lock()
+	defer tc.writeMx.Unlock()
+	tc.mx.Lock()
+	defer tc.mx.Unlock()
+	if tc.f != nil {
+		tc.f.Close()
+		tc.f = nil
+	}
+}
+
+// load 从日志恢复未过期的消息 ID，末尾不完整的记录被丢弃，随后重写日志
+// 参数:
+//   - now: 当前时间
+//
+// 返回值:
+//   - error: 错误信息
+func (tc *FileSeenCache) load(now time.Time) error {
+	f, err := os.Open(tc.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		r := bufio.NewReader(f)
+		for {
+			id, seen, err := readSeenRecord(r)
+			if err != nil {
+				if err != io.EOF {
+					logger.Warnf("丢弃已见消息日志 %s 中不完整的记录: %s", tc.path, err)
+				}
+				break
+			}
+			if now.Sub(seen) <= tc.ttl {
+				tc.m[id] = seen
+			}
+		}
+		f.Close()
+	}
+
+	return tc.compact(tc.m, 0, 0)
+}
+
+// background 定期将缓冲的记录写入日志、清理过期的消息 ID，并在过期记录过多时重写日志
+// 参数:
+//   - ctx: 上下文，用于控制后台协程的生命周期
+func (tc *FileSeenCache) background(ctx context.Context) {
+	ticker := time.NewTicker(seenLogSweepInterval)
+	defer ticker.Stop()
+	flushTicker := time.NewTicker(seenLogFlushInterval)
+	defer flushTicker.Stop()
+
+	for {
+		select {
+		case <-flushTicker.C:
+			tc.flush()
+		case <-tc.flushCh:
+			tc.flush()
+		case now := <-ticker.C:
+			tc.sweep(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flush 将缓冲的记录追加写入日志，写入期间不持有 tc.mx，不阻塞 Add
+func (tc *FileSeenCache) flush() {
+	tc.writeMx.Lock()
+	defer tc.writeMx.Unlock()
+
+	tc.mx.Lock()
+	buf, n, f := tc.pending, tc.npending, tc.f
+	tc.pending, tc.npending = nil, 0
+	tc.mx.Unlock()
+
+	if len(buf) == 0 || f == nil {
+		return
+	}
+	if _, err := f.Write(buf); err != nil {
+		logger.Warnf("写入已见消息日志 %s 失败: %s", tc.path, err)
+		return
+	}
+
+	tc.mx.Lock()
+	tc.records += n
+	tc.mx.Unlock()
+}
+
+// sweep 清理过期的消息 ID，过期记录超过保留的记录时重写日志。
+// 重写基于保留 ID 的快照进行，写文件期间不持有 tc.mx，不阻塞 Add 和 Has
+// 参数:
+//   - now: 当前时间
+func (tc *FileSeenCache) sweep(now time.Time) {
+	tc.writeMx.Lock()
+	defer tc.writeMx.Unlock()
+	tc.mx.Lock()
+
+	for id, seen := range tc.m {
+		if now.Sub(seen) > tc.ttl {
+			delete(tc.m, id)
+		}
+	}
+
+	stale := tc.records - len(tc.m)
+	if tc.f == nil || stale < seenLogMinCompact || stale < len(tc.m) {
+		tc.mx.Unlock()
+		return
+	}
+	snapshot := make(map[string]time.Time, len(tc.m))
+	for id, seen := range tc.m {
+		snapshot[id] = seen
+	}
+	// 快照已包含当前缓冲的记录；持有 writeMx 期间缓冲区只会在末尾追加
+	pending, npending := len(tc.pending), tc.npending
+	tc.mx.Unlock()
+
+	if err := tc.compact(snapshot, pending, npending); err != nil {
+		logger.Warnf("重写已见消息日志 %s 失败: %s", tc.path, err)
+	}
+}
+
+// compact 只用快照中的消息 ID 重写日志并重新打开以追加写入，随后丢弃已包含在快照中的缓冲记录。
+// 调用方必须持有 tc.writeMx 或独占缓存，写文件期间不持有 tc.mx
+// 参数:
+//   - m: 保留的消息 ID 快照
+//   - pending: 已包含在快照中的缓冲字节数
+//   - npending: 已包含在快照中的缓冲记录数
+//
+// 返回值:
+//   - error: 错误信息
+func (tc *FileSeenCache) compact(m map[string]time.Time, pending, npending int) error {
+	tmp := tc.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for id, seen := range m {
+		if _, err := w.Write(encodeSeenRecord(id, seen)); err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, tc.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	out, err := os.OpenFile(tc.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		// 旧文件已被替换，继续写入只会丢失记录
+		tc.mx.Lock()
+		old := tc.f
+		tc.f = nil
+		tc.mx.Unlock()
+		if old != nil {
+			old.Close()
+		}
+		return err
+	}
+
+	tc.mx.Lock()
+	old := tc.f
+	tc.f = out
+	tc.records = len(m)
+	tc.pending = append([]byte(nil), tc.pending[pending:]...)
+	tc.npending -= npending
+	tc.mx.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// encodeSeenRecord 编码已见消息记录。
+// 记录格式：记录长度（uvarint）| 首次看到的时间（8 字节纳秒）| 消息 ID。
+// 参数:
+//   - id: 消息 ID
+//   - seen: 首次看到的时间
+//
+// 返回值:
+//   - []byte: 日志记录
+func encodeSeenRecord(id string, seen time.Time) []byte {
+	out := binary.AppendUvarint(nil, uint64(8+len(id)))
+	out = binary.BigEndian.AppendUint64(out, uint64(seen.UnixNano()))
+	return append(out, id...)
+}
+
+// readSeenRecord 读取一条已见消息记录
+// 参数:
+//   - r: 日志读取器
+//
+// 返回值:
+//   - string: 消息 ID
+//   - time.Time: 首次看到的时间
+//   - error: 没有更多记录时返回 io.EOF
+func readSeenRecord(r *bufio.Reader) (string, time.Time, error) {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if size < 8 || size > seenLogMaxRecord {
+		return "", time.Time{}, fmt.Errorf("无效的记录长度 %d", size)
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", time.Time{}, io.ErrUnexpectedEOF
+	}
+	seen := time.Unix(0, int64(binary.BigEndian.Uint64(body)))
+	return string(body[8:]), seen, nil
+}
+
+// WithPersistentSeenCache 是一个选项，使用基于文件的已见消息缓存，使节点重启后
+// 不会重复处理 SeenMsgTTL 之内已经看到的消息。缓存按首次看到的时间过期，WithSeenMessagesStrategy 不再生效；
+// 不能与 WithSeenCache 同时使用。
+// 参数:
+//   - path: 日志文件路径
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPersistentSeenCache(path string) Option {
+	return func(ps *PubSub) error {
+		if path == "" {
+			logger.Warnf("已见消息缓存的文件路径不能为空")
+			return fmt.Errorf("已见消息缓存的文件路径不能为空")
+		}
+		ps.seenCachePath = path
+		return nil
+	}
+}
+
+// newSeenCache 创建默认的已见消息缓存
+// 返回值:
+//   - SeenCache: 已见消息缓存
+//   - error: 错误信息
+func (p *PubSub) newSeenCache() (SeenCache, error) {
+	switch {
+	case p.seenCachePath != "":
+		return NewFileSeenCache(p.seenCachePath, p.seenMsgTTL)
+	case p.memBudget != nil:
+		return p.newBudgetedSeenCache(), nil
+	default:
+		return timecache.NewTimeCacheWithStrategy(p.seenMsgStrategy, p.seenMsgTTL), nil
+	}
+}
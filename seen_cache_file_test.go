@@ -0,0 +1,176 @@
+package pubsub
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestFileSeenCacheReopen 测试重新打开后恢复存活时间之内的消息 ID，并丢弃过期的 ID 和不完整的记录
+func TestFileSeenCacheReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seen", "ids.log")
+	tc, err := NewFileSeenCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tc.Add("a") || !tc.Add("b") {
+		t.Fatal("expected new ids to be added")
+	}
+	if tc.Add("a") {
+		t.Fatal("expected duplicate id to be rejected")
+	}
+	tc.Done()
+
+	// 模拟写入过程中崩溃留下的不完整记录
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(encodeSeenRecord("c", time.Now())[:4])
+	f.Close()
+
+	tc, err = NewFileSeenCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tc.Has("a") || !tc.Has("b") {
+		t.Fatal("expected ids to survive reopen")
+	}
+	if tc.Has("c") {
+		t.Fatal("expected incomplete record to be dropped")
+	}
+	if tc.Add("b") {
+		t.Fatal("expected recovered id to be rejected")
+	}
+	tc.Done()
+
+	time.Sleep(20 * time.Millisecond)
+	tc, err = NewFileSeenCache(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Done()
+	if tc.Has("a") || tc.Has("b") {
+		t.Fatal("expected ids older than the ttl to expire")
+	}
+}
+
+// TestFileSeenCacheCompact 测试过期记录过多时重写日志
+func TestFileSeenCacheCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.log")
+	tc, err := NewFileSeenCache(path, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Done()
+
+	for i := 0; i < 2*seenLogMinCompact; i++ {
+		tc.Add(string(rune(i)))
+	}
+	tc.flush()
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	tc.Add("fresh")
+	tc.sweep(time.Now())
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Fatalf("expected log to shrink after compaction: %d -> %d", before.Size(), after.Size())
+	}
+	if !tc.Has("fresh") {
+		t.Fatal("expected live id to survive compaction")
+	}
+
+	// 重写后缓冲的记录仍写入新的日志
+	tc.Add("late")
+	tc.Done()
+	reopened, err := NewFileSeenCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Done()
+	if !reopened.Has("fresh") || !reopened.Has("late") {
+		t.Fatal("expected ids added around compaction to be persisted")
+	}
+	if reopened.records != 2 {
+		t.Fatalf("expected 2 records after compaction, got %d", reopened.records)
+	}
+}
+
+// TestFileSeenCacheBufferedWrites 测试 Add 只缓冲记录，由后台协程写入日志
+func TestFileSeenCacheBufferedWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.log")
+	tc, err := NewFileSeenCache(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Done()
+
+	tc.Add("a")
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("expected Add not to write the log, got %d bytes", fi.Size())
+	}
+
+	time.Sleep(5 * seenLogFlushInterval)
+	fi, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(encodeSeenRecord("a", time.Now()))); fi.Size() != want {
+		t.Fatalf("expected background flush to write %d bytes, got %d", want, fi.Size())
+	}
+}
+
+// TestPersistentSeenCacheOption 测试重启后的节点不会重复投递已经看到的消息
+func TestPersistentSeenCacheOption(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	path := filepath.Join(t.TempDir(), "ids.log")
+	hosts := getDefaultHosts(t, 1)
+	ps, err := NewFloodSub(ctx, hosts[0], WithPersistentSeenCache(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ps.seenMessages.(*FileSeenCache); !ok {
+		t.Fatalf("expected a file seen cache, got %T", ps.seenMessages)
+	}
+	ps.markSeen("msg")
+	ps.seenMessages.Done()
+
+	tc, err := NewFileSeenCache(path, TimeCacheDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tc.Done()
+	if !tc.Has("msg") {
+		t.Fatal("expected seen id to be persisted")
+	}
+
+	_, err = NewFloodSub(ctx, hosts[0], WithPersistentSeenCache(path), WithSeenCache(tc))
+	if err == nil {
+		t.Fatal("expected error when combined with WithSeenCache")
+	}
+
+	// 构造失败时不留下打开的缓存
+	other := filepath.Join(t.TempDir(), "other.log")
+	if _, err := NewFloodSub(ctx, hosts[0], WithPersistentSeenCache(other), WithAntiEntropy(time.Second, 0)); err == nil {
+		t.Fatal("expected error for anti-entropy without a message store")
+	}
+	if _, err := os.Stat(other); !os.IsNotExist(err) {
+		t.Fatalf("expected no seen cache to be opened, got %v", err)
+	}
+}
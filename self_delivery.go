@@ -0,0 +1,48 @@
+// 作用：本地发布的消息是否投递给本节点的订阅者。
+// 功能：默认情况下本节点发布的消息不会回送给本节点自己的订阅，只发送给网络；
+// 可以按主题或按每次发布选择回送，使需要在同一进程内观察自己消息的应用无需自行转发。
+// 仅在本地发布（WithLocalPublication(true)）的消息总是投递给本节点的订阅者。
+
+package pubsub
+
+// WithDeliverToSelf 返回一个发布选项，设置这次发布的消息是否投递给本节点的订阅者，覆盖主题的设置
+// 参数:
+//   - deliver: 是否投递给本节点的订阅者
+//
+// 返回值:
+//   - PubOpt: 发布选项
+func WithDeliverToSelf(deliver bool) PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.loopback = &deliver
+		return nil
+	}
+}
+
+// WithTopicDeliverToSelf 是一个主题选项，设置在该主题上发布的消息默认是否投递给本节点的订阅者
+// 参数:
+//   - deliver: 是否投递给本节点的订阅者
+//
+// 返回值:
+//   - TopicOpt: 主题选项
+func WithTopicDeliverToSelf(deliver bool) TopicOpt {
+	return func(t *Topic) error {
+		t.deliverToSelf = deliver
+		return nil
+	}
+}
+
+// loopback 判断本地发布的消息是否投递给本节点的订阅者
+// 参数:
+//   - pub: 发布选项
+//
+// 返回值:
+//   - bool: 是否投递给本节点的订阅者
+func (t *Topic) loopback(pub *PublishOptions) bool {
+	if pub.local {
+		return true // 仅在本地发布的消息只能投递给本节点的订阅者
+	}
+	if pub.loopback != nil {
+		return *pub.loopback
+	}
+	return t.deliverToSelf
+}
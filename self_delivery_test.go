@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestDeliverToSelf 测试按主题和按发布选择是否把本地发布的消息投递给本节点的订阅者
+func TestDeliverToSelf(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	expect := func(sub *Subscription, want string) {
+		t.Helper()
+		tctx, tcancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer tcancel()
+		msg, err := sub.Next(tctx)
+		if want == "" {
+			if err == nil {
+				t.Fatalf("expected no local delivery, got %q", msg.Data)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(msg.Data) != want {
+			t.Fatalf("expected %q, got %q", want, msg.Data)
+		}
+	}
+
+	plain, err := ps.Join("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := plain.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := plain.Publish(ctx, []byte("default")); err != nil {
+		t.Fatal(err)
+	}
+	expect(sub, "")
+	if err := plain.Publish(ctx, []byte("looped"), WithDeliverToSelf(true)); err != nil {
+		t.Fatal(err)
+	}
+	expect(sub, "looped")
+	if err := plain.Publish(ctx, []byte("local"), WithLocalPublication(true)); err != nil {
+		t.Fatal(err)
+	}
+	expect(sub, "local")
+
+	loop, err := ps.Join("loop", WithTopicDeliverToSelf(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	loopSub, err := loop.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loop.Publish(ctx, []byte("topic default")); err != nil {
+		t.Fatal(err)
+	}
+	expect(loopSub, "topic default")
+	if err := loop.Publish(ctx, []byte("override"), WithDeliverToSelf(false)); err != nil {
+		t.Fatal(err)
+	}
+	expect(loopSub, "")
+}
@@ -0,0 +1,110 @@
+// 作用：自源消息处理策略。
+// 功能：配置如何处理由本节点发布、却经网格环路从对等节点收到的消息：
+// 作为无效消息拒绝（默认）、静默丢弃、计为重复，或作为异常事件连同转发路径上报，用于发现桥接配置错误。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// SelfOriginPolicy 描述如何处理从网络收到的自源消息
+type SelfOriginPolicy uint8
+
+const (
+	// SelfOriginReject 以 RejectSelfOrigin 拒绝消息，并惩罚转发的对等节点（默认）
+	SelfOriginReject SelfOriginPolicy = iota
+	// SelfOriginDrop 静默丢弃消息，不通知追踪器
+	SelfOriginDrop
+	// SelfOriginDuplicate 将消息计为重复消息
+	SelfOriginDuplicate
+	// SelfOriginAnomaly 丢弃消息，并将其作为异常事件连同转发路径交给处理函数
+	SelfOriginAnomaly
+)
+
+// SelfOriginEvent 描述一次从网络收到的自源消息
+type SelfOriginEvent struct {
+	Message      *Message // 收到的消息
+	ReceivedFrom peer.ID  // 转发该消息的对等节点
+	Path         string   // 接收该消息的连接的远端地址
+}
+
+// SelfOriginHandler 处理自源消息异常事件，由单个后台 goroutine 依次调用；
+// 处理不及时导致队列已满时，新的事件会被丢弃
+type SelfOriginHandler func(SelfOriginEvent)
+
+const (
+	// selfOriginQueueSize 是等待处理的自源消息异常事件队列的容量
+	selfOriginQueueSize = 64
+)
+
+// WithSelfOriginPolicy 设置从网络收到的自源消息的处理策略。
+// 参数:
+//   - policy: 处理策略
+//   - handler: 异常事件处理函数，策略为 SelfOriginAnomaly 时必须提供
+//
+// 返回值:
+//   - Option: 配置选项
+func WithSelfOriginPolicy(policy SelfOriginPolicy, handler SelfOriginHandler) Option {
+	return func(p *PubSub) error {
+		if policy > SelfOriginAnomaly {
+			logger.Warnf("未知的自源消息处理策略: %d", policy)
+			return fmt.Errorf("未知的自源消息处理策略: %d", policy)
+		}
+		if policy == SelfOriginAnomaly && handler == nil {
+			logger.Warnf("自源消息异常处理函数不能为空")
+			return fmt.Errorf("自源消息异常处理函数不能为空")
+		}
+		p.selfOriginPolicy = policy
+		p.selfOriginHandler = handler
+		if handler != nil {
+			p.selfOriginQueue = make(chan SelfOriginEvent, selfOriginQueueSize)
+		}
+		return nil
+	}
+}
+
+// handleSelfOrigin 按策略处理从网络收到的自源消息
+// 参数:
+//   - msg: 收到的消息
+func (p *PubSub) handleSelfOrigin(msg *Message) {
+	switch p.selfOriginPolicy {
+	case SelfOriginDrop:
+		logger.Debugf("静默丢弃由 %s 转发的自源消息", msg.ReceivedFrom)
+
+	case SelfOriginDuplicate:
+		logger.Debugf("将由 %s 转发的自源消息计为重复", msg.ReceivedFrom)
+		p.traceDuplicate(p.idGen.ID(msg), msg)
+
+	case SelfOriginAnomaly:
+		logger.Warnf("收到由 %s 经 %s 转发的自源消息; 可能存在桥接配置错误", msg.ReceivedFrom, msg.receivedPath)
+		evt := SelfOriginEvent{
+			Message:      msg,
+			ReceivedFrom: msg.ReceivedFrom,
+			Path:         msg.receivedPath,
+		}
+		select {
+		case p.selfOriginQueue <- evt:
+		default:
+			logger.Debugf("自源消息异常事件队列已满; 丢弃由 %s 转发的消息的事件", msg.ReceivedFrom)
+		}
+
+	default:
+		logger.Debugf("丢弃声称来自自己但由 %s 转发的消息", msg.ReceivedFrom)
+		p.tracer.RejectMessage(msg, RejectSelfOrigin)
+	}
+}
+
+// selfOriginLoop 依次将队列中的自源消息异常事件交给处理函数
+func (p *PubSub) selfOriginLoop() {
+	for {
+		select {
+		case evt := <-p.selfOriginQueue:
+			p.selfOriginHandler(evt)
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
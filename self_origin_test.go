@@ -0,0 +1,154 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// selfOriginTraceRecorder 记录追踪事件的类型
+type selfOriginTraceRecorder struct {
+	mx     sync.Mutex
+	events []pb.TraceEvent_Type
+}
+
+func (r *selfOriginTraceRecorder) Trace(evt *pb.TraceEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, evt.GetType())
+}
+
+func (r *selfOriginTraceRecorder) count(typ pb.TraceEvent_Type) int {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	n := 0
+	for _, evt := range r.events {
+		if evt == typ {
+			n++
+		}
+	}
+	return n
+}
+
+// TestSelfOriginPolicy 测试从网络收到的自源消息的各种处理策略
+func TestSelfOriginPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 5)
+
+	// 模拟对等节点转发回来的自源消息
+	loopback := func(ps *PubSub, seq byte) {
+		msg := &Message{
+			Message: &pb.Message{
+				Data:  []byte("looped"),
+				Topic: "test",
+				From:  []byte(hosts[0].ID()),
+				Seqno: []byte{0, 0, 0, 0, 0, 0, 0, seq},
+				// 签名在验证管道中才会检查
+				Signature: []byte("signature"),
+			},
+			ReceivedFrom: hosts[1].ID(),
+			receivedPath: "/ip4/1.2.3.4/tcp/4001",
+		}
+		done := make(chan struct{})
+		ps.eval <- func() {
+			defer close(done)
+			ps.pushMsg(msg)
+		}
+		<-done
+	}
+
+	newPubSub := func(h int, opts ...Option) (*PubSub, *selfOriginTraceRecorder) {
+		rec := &selfOriginTraceRecorder{}
+		opts = append(opts, WithEventTracer(rec))
+		ps, err := NewFloodSub(ctx, hosts[h], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps, rec
+	}
+
+	// 默认策略以无效消息拒绝
+	ps, rec := newPubSub(0)
+	loopback(ps, 1)
+	if rec.count(pb.TraceEvent_REJECT_MESSAGE) != 1 {
+		t.Fatal("expected self-origin message to be rejected by default")
+	}
+
+	// 计为重复
+	ps, rec = newPubSub(0, WithSelfOriginPolicy(SelfOriginDuplicate, nil))
+	loopback(ps, 2)
+	if rec.count(pb.TraceEvent_DUPLICATE_MESSAGE) != 1 || rec.count(pb.TraceEvent_REJECT_MESSAGE) != 0 {
+		t.Fatal("expected self-origin message to be counted as duplicate")
+	}
+
+	// 静默丢弃
+	ps, rec = newPubSub(0, WithSelfOriginPolicy(SelfOriginDrop, nil))
+	loopback(ps, 3)
+	if rec.count(pb.TraceEvent_DUPLICATE_MESSAGE) != 0 || rec.count(pb.TraceEvent_REJECT_MESSAGE) != 0 {
+		t.Fatal("expected self-origin message to be dropped silently")
+	}
+
+	// 作为异常事件上报
+	events := make(chan SelfOriginEvent, 1)
+	ps, _ = newPubSub(0, WithSelfOriginPolicy(SelfOriginAnomaly, func(evt SelfOriginEvent) {
+		events <- evt
+	}))
+	loopback(ps, 4)
+	select {
+	case evt := <-events:
+		if evt.ReceivedFrom != hosts[1].ID() || evt.Path != "/ip4/1.2.3.4/tcp/4001" {
+			t.Fatalf("unexpected anomaly event %+v", evt)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected anomaly event")
+	}
+
+	// 异常策略必须提供处理函数
+	if _, err := NewFloodSub(ctx, hosts[1], WithSelfOriginPolicy(SelfOriginAnomaly, nil)); err == nil {
+		t.Fatal("expected error for anomaly policy without handler")
+	}
+}
+
+// TestSelfOriginAnomalyBounded 测试处理函数阻塞时异常事件不会无限堆积
+func TestSelfOriginAnomalyBounded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	ps, err := NewFloodSub(ctx, hosts[0], WithSelfOriginPolicy(SelfOriginAnomaly, func(SelfOriginEvent) {
+		<-release
+		handled.Add(1)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const sent = 2 * selfOriginQueueSize
+	done := make(chan struct{})
+	ps.eval <- func() {
+		defer close(done)
+		for i := 0; i < sent; i++ {
+			ps.handleSelfOrigin(&Message{Message: &pb.Message{Topic: "test"}})
+		}
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected blocked handler not to stall the event loop")
+	}
+
+	close(release)
+	time.Sleep(100 * time.Millisecond)
+	if n := handled.Load(); n < selfOriginQueueSize || n > selfOriginQueueSize+1 {
+		t.Fatalf("expected at most %d anomaly events to be handled, got %d", selfOriginQueueSize+1, n)
+	}
+}
@@ -0,0 +1,267 @@
+// 作用：主题分片。
+// 功能：将键映射到 N 个分片主题之一，按键发布消息，并订阅本节点负责的分片集合；
+// 负责的分片集合变化时自动订阅新增的分片并退订移除的分片，适用于水平分区的工作负载（例如证明子网）。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// ShardedTopic 将消息按键分布到一组分片主题
+type ShardedTopic struct {
+	ps     *PubSub
+	prefix string     // 分片主题名称的前缀
+	shards int        // 分片数
+	opts   []TopicOpt // 加入分片主题时使用的选项
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mx     sync.Mutex
+	topics map[int]*Topic        // 已加入的分片主题，用于发布和订阅
+	subs   map[int]*Subscription // 负责的分片的订阅
+	closed bool
+
+	out chan *Message // 所有负责的分片收到的消息
+}
+
+// NewShardedTopic 创建分片主题，分片 i 的主题名称为 "prefix/i"。
+// 分片主题在首次发布或订阅时加入，因此不能已被应用程序加入。
+// 参数:
+//   - ps: PubSub 实例
+//   - prefix: 分片主题名称的前缀
+//   - shards: 分片数
+//   - opts: 加入分片主题时使用的选项
+//
+// 返回值:
+//   - *ShardedTopic: 分片主题
+//   - error: 错误信息，如果有的话
+func NewShardedTopic(ps *PubSub, prefix string, shards int, opts ...TopicOpt) (*ShardedTopic, error) {
+	if ps == nil {
+		logger.Warnf("PubSub 实例不能为空")
+		return nil, fmt.Errorf("PubSub 实例不能为空")
+	}
+	if prefix == "" {
+		logger.Warnf("分片主题的前缀不能为空")
+		return nil, fmt.Errorf("分片主题的前缀不能为空")
+	}
+	if shards <= 0 {
+		logger.Warnf("分片数必须为正数")
+		return nil, fmt.Errorf("分片数必须为正数")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ShardedTopic{
+		ps:     ps,
+		prefix: prefix,
+		shards: shards,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		topics: make(map[int]*Topic),
+		subs:   make(map[int]*Subscription),
+		out:    make(chan *Message, 32),
+	}, nil
+}
+
+// ShardTopic 返回分片的主题名称
+// 参数:
+//   - shard: 分片
+//
+// 返回值:
+//   - string: 主题名称
+func (st *ShardedTopic) ShardTopic(shard int) string {
+	return fmt.Sprintf("%s/%d", st.prefix, shard)
+}
+
+// Shard 返回键所属的分片
+// 参数:
+//   - key: 键
+//
+// 返回值:
+//   - int: 分片
+func (st *ShardedTopic) Shard(key []byte) int {
+	h := fnv.New64a()
+	h.Write(key)
+	return int(h.Sum64() % uint64(st.shards))
+}
+
+// Publish 将消息发布到键所属的分片
+// 参数:
+//   - ctx: 上下文
+//   - key: 键
+//   - data: 消息数据
+//   - opts: 发布选项
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (st *ShardedTopic) Publish(ctx context.Context, key []byte, data []byte, opts ...PubOpt) error {
+	st.mx.Lock()
+	t, err := st.topic(st.Shard(key))
+	st.mx.Unlock()
+	if err != nil {
+		return err
+	}
+	return t.Publish(ctx, data, opts...)
+}
+
+// SetShards 将本节点负责的分片集合设置为 shards，订阅新增的分片并退订移除的分片
+// 参数:
+//   - shards: 负责的分片
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (st *ShardedTopic) SetShards(shards ...int) error {
+	owned := make(map[int]struct{}, len(shards))
+	for _, shard := range shards {
+		if shard < 0 || shard >= st.shards {
+			logger.Warnf("无效的分片: %d", shard)
+			return fmt.Errorf("无效的分片: %d", shard)
+		}
+		owned[shard] = struct{}{}
+	}
+
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	if st.closed {
+		return ErrTopicClosed
+	}
+
+	for shard, sub := range st.subs {
+		if _, ok := owned[shard]; !ok {
+			sub.Cancel()
+			delete(st.subs, shard)
+		}
+	}
+
+	for shard := range owned {
+		if _, ok := st.subs[shard]; ok {
+			continue
+		}
+		t, err := st.topic(shard)
+		if err != nil {
+			return err
+		}
+		sub, err := t.Subscribe()
+		if err != nil {
+			logger.Warnf("订阅分片主题 %s 失败: %s", t, err)
+			return fmt.Errorf("订阅分片主题 %s 失败: %w", t, err)
+		}
+		st.subs[shard] = sub
+
+		st.wg.Add(1)
+		go st.forward(sub)
+	}
+
+	return nil
+}
+
+// Shards 返回本节点负责的分片
+// 返回值:
+//   - []int: 按升序排列的分片
+func (st *ShardedTopic) Shards() []int {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+
+	shards := make([]int, 0, len(st.subs))
+	for shard := range st.subs {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// Next 返回负责的分片收到的下一条消息
+// 参数:
+//   - ctx: 上下文
+//
+// 返回值:
+//   - *Message: 消息
+//   - error: 错误信息，如果有的话
+func (st *ShardedTopic) Next(ctx context.Context) (*Message, error) {
+	select {
+	case msg := <-st.out:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-st.ctx.Done():
+		return nil, ErrTopicClosed
+	}
+}
+
+// Close 退订所有分片并关闭已加入的分片主题
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (st *ShardedTopic) Close() error {
+	st.mx.Lock()
+	if st.closed {
+		st.mx.Unlock()
+		return nil
+	}
+	st.closed = true
+	for shard, sub := range st.subs {
+		sub.Cancel()
+		delete(st.subs, shard)
+	}
+	var err error
+	for _, t := range st.topics {
+		if cerr := t.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	st.mx.Unlock()
+
+	st.cancel()
+	st.wg.Wait()
+	return err
+}
+
+// topic 返回分片的主题句柄，首次使用时加入主题。调用方必须持有 st.mx
+// 参数:
+//   - shard: 分片
+//
+// 返回值:
+//   - *Topic: 主题句柄
+//   - error: 错误信息，如果有的话
+func (st *ShardedTopic) topic(shard int) (*Topic, error) {
+	if st.closed {
+		return nil, ErrTopicClosed
+	}
+	if t, ok := st.topics[shard]; ok {
+		return t, nil
+	}
+
+	name := st.ShardTopic(shard)
+	t, err := st.ps.Join(name, st.opts...)
+	if err != nil {
+		logger.Warnf("加入分片主题 %s 失败: %s", name, err)
+		return nil, fmt.Errorf("加入分片主题 %s 失败: %w", name, err)
+	}
+	st.topics[shard] = t
+	return t, nil
+}
+
+// forward 将分片订阅收到的消息转发到合并的消息通道，直到订阅取消或分片主题关闭
+// 参数:
+//   - sub: 分片订阅
+func (st *ShardedTopic) forward(sub *Subscription) {
+	defer st.wg.Done()
+
+	for {
+		msg, err := sub.Next(st.ctx)
+		if err != nil {
+			return
+		}
+		select {
+		case st.out <- msg:
+		case <-st.ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestShardedTopic 测试按键发布到分片，以及负责的分片集合变化时自动订阅和退订
+func TestShardedTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	pub, err := NewShardedTopic(psubs[0], "shards", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	sharded, err := NewShardedTopic(psubs[1], "shards", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sharded.Close()
+
+	key := []byte("validator-42")
+	shard := sharded.Shard(key)
+	if pub.Shard(key) != shard {
+		t.Fatal("expected the shard of a key to be deterministic")
+	}
+	other := (shard + 1) % 8
+
+	if err := sharded.SetShards(shard); err != nil {
+		t.Fatal(err)
+	}
+	if shards := sharded.Shards(); len(shards) != 1 || shards[0] != shard {
+		t.Fatalf("expected to own shard %d, got %v", shard, shards)
+	}
+	time.Sleep(time.Second)
+
+	if err := pub.Publish(ctx, key, []byte("owned")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sharded.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "owned" || msg.GetTopic() != sharded.ShardTopic(shard) {
+		t.Fatalf("unexpected message %q on topic %s", msg.Data, msg.GetTopic())
+	}
+
+	// 移除分片后不再收到该分片的消息
+	if err := sharded.SetShards(other); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if err := pub.Publish(ctx, key, []byte("left")); err != nil {
+		t.Fatal(err)
+	}
+	tctx, tcancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer tcancel()
+	if msg, err := sharded.Next(tctx); err == nil {
+		t.Fatalf("expected no message after leaving the shard, got %q", msg.Data)
+	}
+
+	if err := sharded.SetShards(8); err == nil {
+		t.Fatal("expected error for out of range shard")
+	}
+	if _, err := NewShardedTopic(psubs[0], "shards", 0); err == nil {
+		t.Fatal("expected error for non-positive shard count")
+	}
+
+	if err := sharded.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sharded.Next(ctx); err != ErrTopicClosed {
+		t.Fatalf("expected ErrTopicClosed after close, got %v", err)
+	}
+	if err := sharded.SetShards(shard); err != ErrTopicClosed {
+		t.Fatalf("expected ErrTopicClosed after close, got %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+// 作用：优雅关闭 PubSub。
+// 功能：进程退出前取消所有主题的订阅和中继，使路由器向网格对等节点发送带退避的 PRUNE 并宣布取消订阅，
+// 等待出站队列清空后停止事件循环、心跳和验证工作协程，并等待每个对等节点的读写协程退出。
+
+package pubsub
+
+import (
+	"context"
+	"time"
+)
+
+// Close 优雅地关闭 PubSub：取消所有主题的订阅和中继并发送取消订阅公告和 PRUNE，
+// 等待出站队列清空，然后停止所有后台协程。只有在关闭完成或上下文结束时才返回；
+// 上下文结束时跳过剩余的等待，PubSub 仍然被停止，并返回上下文的错误。重复调用直接返回 nil。
+// 参数:
+//   - ctx: 限定关闭时间的上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) Close(ctx context.Context) error {
+	if !p.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// 离开所有主题，路由器会向网格对等节点发送带退避的 PRUNE
+	err := p.leaveAllTopics(ctx)
+	if err == nil {
+		err = p.flushOutbound(ctx, "")
+	}
+	if p.ctx.Err() != nil {
+		err = nil // 父上下文已经停止了 PubSub，没有可排空的内容
+	}
+
+	// 停止事件循环、心跳和验证工作协程；事件循环退出时关闭所有出站队列
+	p.cancel()
+	for _, id := range p.protocols() {
+		p.host.RemoveStreamHandler(id)
+	}
+	p.host.RemoveStreamHandler(p.prefixed(SnapshotID))
+	p.host.RemoveStreamHandler(p.prefixed(CatchUpID))
+	p.host.RemoveStreamHandler(p.prefixed(AntiEntropyID))
+
+	select {
+	case <-p.loopDone:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// 对方不会再收到我们的读取，重置入站流使读取协程退出
+	p.inboundStreamsMx.Lock()
+	for _, s := range p.inboundStreams {
+		s.Reset()
+	}
+	p.inboundStreamsMx.Unlock()
+
+	ticker := time.NewTicker(closeDrainPollInterval)
+	defer ticker.Stop()
+	for p.goroutines.inbound.Load() > 0 || p.goroutines.outbound.Load() > 0 || p.goroutines.watchers.Load() > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if err != nil {
+		logger.Warnf("关闭 PubSub 时未能完成排空: %s", err)
+	}
+	return err
+}
+
+// leaveAllTopics 取消所有主题的订阅和中继
+// 参数:
+//   - ctx: 限定等待时间的上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) leaveAllTopics(ctx context.Context) error {
+	done := make(chan struct{})
+	leave := func() {
+		defer close(done)
+		topics := make(map[string]struct{}, len(p.mySubs)+len(p.myRelays))
+		for topic := range p.mySubs {
+			topics[topic] = struct{}{}
+		}
+		for topic := range p.myRelays {
+			topics[topic] = struct{}{}
+		}
+		for topic := range topics {
+			p.handleLeaveTopic(topic)
+		}
+	}
+
+	select {
+	case p.eval <- leave:
+		<-done
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
@@ -0,0 +1,85 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPubSubClose 测试优雅关闭时宣布取消订阅、关闭订阅并停止所有对等节点协程
+func TestPubSubClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	if peers := topics[1].ListPeers(); len(peers) != 1 {
+		t.Fatalf("expected 1 topic peer, got %d", len(peers))
+	}
+
+	cctx, ccancel := context.WithTimeout(ctx, 5*time.Second)
+	defer ccancel()
+	if err := psubs[0].Close(cctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := psubs[0].Close(cctx); err != nil {
+		t.Fatalf("expected a repeated close to succeed, got %s", err)
+	}
+
+	if _, err := subs[0].Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected ErrSubscriptionCancelled after close, got %v", err)
+	}
+	g := &psubs[0].goroutines
+	if n := g.inbound.Load() + g.outbound.Load() + g.watchers.Load(); n != 0 {
+		t.Fatalf("expected all peer goroutines to exit, got %d", n)
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	if peers := topics[1].ListPeers(); len(peers) != 0 {
+		t.Fatalf("expected the remote peer to see the unsubscription, got %v", peers)
+	}
+	if _, err := psubs[0].Join("other"); err == nil {
+		t.Fatal("expected join to fail after close")
+	}
+}
+
+// TestPubSubCloseRemovesStreamHandlers 测试关闭后不再处理辅助协议的流
+func TestPubSubCloseRemovesStreamHandlers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0])
+
+	cctx, ccancel := context.WithTimeout(ctx, 5*time.Second)
+	defer ccancel()
+	if err := ps.Close(cctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range hosts[0].Mux().Protocols() {
+		switch id {
+		case SnapshotID, CatchUpID, AntiEntropyID:
+			t.Fatalf("expected the %s handler to be removed on close", id)
+		}
+	}
+}
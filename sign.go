@@ -67,6 +67,8 @@ func verifyMessageSignature(m *pb.Message) error {
 	xm := *m
 	xm.Signature = nil
 	xm.Key = nil
+	xm.Annotations = nil       // 注解由转发节点逐跳附加，不参与签名
+	xm.Hops = 0                // 跳数由接收节点逐跳递增，不参与签名
 	bytes, err := xm.Marshal() // 序列化消息
 	if err != nil {
 		logger.Warnf("序列化消息失败: %s", err) // 序列化消息失败
@@ -131,15 +133,76 @@ func messagePubKey(m *pb.Message) (crypto.PubKey, error) {
 	return pubk, nil
 }
 
+// MessageSigner 对本地发布的消息签名。
+// crypto.PrivKey 实现了该接口；使用硬件或远程密钥管理时，可以实现该接口而无需把私钥交给 pubsub 实例。
+// 如果签名者的对等节点 ID 没有内嵌公钥（例如 RSA 密钥），签名者还必须实现 GetPublic() crypto.PubKey。
+type MessageSigner interface {
+	// Sign 对消息字节签名并返回签名
+	Sign(msg []byte) ([]byte, error)
+}
+
+// signerPublicKey 是能够提供公钥的签名者
+type signerPublicKey interface {
+	GetPublic() crypto.PubKey
+}
+
+// WithMessageSigner 是一个选项，用于设置对消息签名的签名者，替代主机 peerstore 中的私钥。
+// 签名者必须持有消息作者（默认为主机 ID，参见 WithMessageAuthor）对应的私钥；
+// 只能在签名策略要求签名时使用，不能与不签名的策略或匿名模式同时使用。
+// 参数:
+//   - signer: 签名者
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMessageSigner(signer MessageSigner) Option {
+	return func(p *PubSub) error {
+		if signer == nil {
+			logger.Warnf("消息签名者不能为空")
+			return fmt.Errorf("消息签名者不能为空")
+		}
+		if !p.signPolicy.mustSign() {
+			logger.Warnf("签名策略不要求签名时不能设置消息签名者")
+			return fmt.Errorf("签名策略不要求签名时不能设置消息签名者")
+		}
+		p.signer = signer
+		return nil
+	}
+}
+
+// checkSignerPublicKey 检查是否能够为签名者确定公钥：公钥内嵌在对等节点 ID 中，或签名者能够提供公钥。
+// 参数:
+// - pid: 签名者的 peer.ID
+// - signer: 签名者
+// 返回值:
+// - error: 错误信息，如果有的话
+func checkSignerPublicKey(pid peer.ID, signer MessageSigner) error {
+	if pk, _ := pid.ExtractPublicKey(); pk != nil {
+		return nil
+	}
+	sp, ok := signer.(signerPublicKey)
+	if !ok {
+		logger.Warnf("对等节点 %s 的 ID 没有内嵌公钥，签名者必须提供公钥", pid)
+		return fmt.Errorf("对等节点 %s 的 ID 没有内嵌公钥，签名者必须提供公钥", pid)
+	}
+	if pk := sp.GetPublic(); pk == nil || !pid.MatchesPublicKey(pk) {
+		logger.Warnf("签名者的公钥与对等节点 %s 不匹配", pid)
+		return fmt.Errorf("签名者的公钥与对等节点 %s 不匹配", pid)
+	}
+	return nil
+}
+
 // signMessage 为消息生成签名。
 // 参数:
 // - pid: 消息的 peer.ID
-// - key: 签名用的私钥
+// - signer: 签名者
 // - m: 要签名的 pb.Message 指针
 // 返回值:
 // - error: 错误信息，如果有的话
-func signMessage(pid peer.ID, key crypto.PrivKey, m *pb.Message) error {
-	bytes, err := m.Marshal() // 序列化消息
+func signMessage(pid peer.ID, signer MessageSigner, m *pb.Message) error {
+	xm := *m
+	xm.Annotations = nil       // 注解由转发节点逐跳附加，不参与签名
+	xm.Hops = 0                // 跳数由接收节点逐跳递增，不参与签名
+	bytes, err := xm.Marshal() // 序列化消息
 	if err != nil {
 		logger.Warnf("序列化消息失败: %s", err) // 序列化消息失败
 		return err
@@ -147,7 +210,7 @@ func signMessage(pid peer.ID, key crypto.PrivKey, m *pb.Message) error {
 
 	bytes = withSignPrefix(bytes) // 添加签名前缀
 
-	sig, err := key.Sign(bytes) // 生成签名
+	sig, err := signer.Sign(bytes) // 生成签名
 	if err != nil {
 		logger.Warnf("生成签名失败: %s", err) // 生成签名失败
 		return err
@@ -157,7 +220,12 @@ func signMessage(pid peer.ID, key crypto.PrivKey, m *pb.Message) error {
 
 	pk, _ := pid.ExtractPublicKey() // 提取公钥
 	if pk == nil {
-		pubk, err := crypto.MarshalPublicKey(key.GetPublic()) // 编码公钥
+		sp, ok := signer.(signerPublicKey)
+		if !ok {
+			logger.Warnf("签名者没有提供公钥") // 签名者没有提供公钥
+			return fmt.Errorf("签名者没有提供公钥")
+		}
+		pubk, err := crypto.MarshalPublicKey(sp.GetPublic()) // 编码公钥
 		if err != nil {
 			logger.Warnf("编码签名密钥失败: %s", err) // 编码签名密钥失败
 			return err
@@ -1,7 +1,9 @@
 package pubsub
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	pb "github.com/dep2p/pubsub/pb"
 
@@ -50,3 +52,140 @@ func testSignVerify(t *testing.T, privk crypto.PrivKey) {
 		t.Fatal(err) // 如果验证签名失败，则记录错误并终止测试
 	}
 }
+
+// TestSignaturePolicyOption 测试节点配置中的消息签名策略
+func TestSignaturePolicyOption(t *testing.T) {
+	opts := DefaultOptions()
+	if opts.GetSignaturePolicy() != LaxNoSign {
+		t.Fatalf("expected default policy LaxNoSign, got %d", opts.GetSignaturePolicy())
+	}
+	if err := opts.ApplyOptions(WithSetSignaturePolicy(StrictNoSign)); err != nil {
+		t.Fatal(err)
+	}
+	if opts.GetSignaturePolicy() != StrictNoSign {
+		t.Fatalf("expected policy StrictNoSign, got %d", opts.GetSignaturePolicy())
+	}
+	if err := opts.ApplyOptions(WithSetSignaturePolicy(MessageSignaturePolicy(8))); err == nil {
+		t.Fatal("expected error for unknown signature policy")
+	}
+}
+
+// TestContentMsgIdFn 测试按内容生成的消息 ID
+func TestContentMsgIdFn(t *testing.T) {
+	a := &pb.Message{Topic: "test", Data: []byte("hello")}
+	b := &pb.Message{Topic: "test", Data: []byte("hello"), Seqno: []byte{1}}
+	c := &pb.Message{Topic: "other", Data: []byte("hello")}
+
+	if ContentMsgIdFn(a) != ContentMsgIdFn(b) {
+		t.Fatal("expected message ID to depend only on topic and data")
+	}
+	if ContentMsgIdFn(a) == ContentMsgIdFn(c) {
+		t.Fatal("expected different topics to produce different message IDs")
+	}
+
+	// 主题与内容的边界不同的消息不会产生相同的 ID
+	d := &pb.Message{Topic: "ab", Data: []byte("c")}
+	e := &pb.Message{Topic: "a", Data: []byte("bc")}
+	if ContentMsgIdFn(d) == ContentMsgIdFn(e) {
+		t.Fatal("expected the topic/data boundary to affect the message ID")
+	}
+}
+
+// remoteSigner 模拟持有私钥的远程签名者，只暴露签名操作
+type remoteSigner struct {
+	key   crypto.PrivKey
+	calls int
+}
+
+// Sign 实现 MessageSigner 接口
+func (s *remoteSigner) Sign(msg []byte) ([]byte, error) {
+	s.calls++
+	return s.key.Sign(msg)
+}
+
+// TestMessageSigner 测试使用外部签名者签名的消息能够通过验证
+func TestMessageSigner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	signer := &remoteSigner{key: hosts[0].Peerstore().PrivKey(hosts[0].ID())}
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithMessageSigner(signer)),
+		getPubsub(ctx, hosts[1]),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Publish(ctx, []byte("signed remotely")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "signed remotely" || msg.GetFrom() != hosts[0].ID() {
+		t.Fatalf("unexpected message %v", msg)
+	}
+	if signer.calls != 1 {
+		t.Fatalf("expected signer to be called once, got %d", signer.calls)
+	}
+
+	// 签名者不能为空
+	if _, err := NewFloodSub(ctx, hosts[1], WithMessageSigner(nil)); err == nil {
+		t.Fatal("expected error for nil signer")
+	}
+}
+
+// TestMessageSignerRequiresSigning 测试签名策略不要求签名或启用匿名模式时不能设置签名者
+func TestMessageSignerRequiresSigning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	signer := &remoteSigner{key: hosts[0].Peerstore().PrivKey(hosts[0].ID())}
+
+	for name, opts := range map[string][]Option{
+		"strict no sign":  {WithMessageSignaturePolicy(StrictNoSign), WithMessageSigner(signer)},
+		"lax no sign":     {WithMessageSignaturePolicy(LaxNoSign), WithMessageSigner(signer)},
+		"policy after":    {WithMessageSigner(signer), WithMessageSignaturePolicy(StrictNoSign)},
+		"anonymous":       {WithAnonymousPublishing(true), WithMessageSigner(signer)},
+		"anonymous after": {WithMessageSigner(signer), WithAnonymousPublishing(true)},
+	} {
+		if _, err := NewFloodSub(ctx, hosts[0], opts...); err == nil {
+			t.Fatalf("%s: expected error for signer without signing", name)
+		}
+	}
+	if signer.calls != 0 {
+		t.Fatalf("expected signer not to be called, got %d calls", signer.calls)
+	}
+}
+
+// TestSignerPublicKey 测试对等节点 ID 没有内嵌公钥时签名者必须提供公钥
+func TestSignerPublicKey(t *testing.T) {
+	privk, _, err := crypto.GenerateKeyPair(crypto.RSA, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(privk.GetPublic())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkSignerPublicKey(id, &remoteSigner{key: privk}); err == nil {
+		t.Fatal("expected error for signer without public key")
+	}
+	if err := checkSignerPublicKey(id, privk); err != nil {
+		t.Fatal(err)
+	}
+}
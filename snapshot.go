@@ -0,0 +1,232 @@
+// 作用：主题快照扩展。
+// 功能：允许应用为主题注册快照提供者，新加入的节点可以通过 pubsub 流向任意主题成员请求快照以引导状态。
+
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+	"github.com/dep2p/go-dep2p/p2plib/msgio"
+)
+
+const (
+	// SnapshotID 是主题快照请求/响应使用的协议 ID
+	SnapshotID = protocol.ID("/dep2p/pubsub/snapshot/1.0.0")
+
+	// snapshotStatusOK 表示快照响应成功
+	snapshotStatusOK byte = 0
+	// snapshotStatusError 表示快照响应失败，负载为错误信息
+	snapshotStatusError byte = 1
+)
+
+var (
+	// SnapshotTimeout 是单次快照请求的超时时间
+	SnapshotTimeout = 10 * time.Second
+
+	// ErrNoSnapshotPeers 表示没有可以请求快照的对等节点
+	ErrNoSnapshotPeers = errors.New("没有可以请求快照的对等节点")
+)
+
+// SnapshotProvider 由应用实现，返回主题当前状态的摘要对象（例如成员列表）。
+// 快照大小不能超过 PubSub 的最大消息大小。
+// 参数:
+//   - ctx: 上下文
+//   - topic: 主题
+//   - from: 请求快照的对等节点
+//
+// 返回值:
+//   - []byte: 快照数据
+//   - error: 错误信息
+type SnapshotProvider func(ctx context.Context, topic string, from peer.ID) ([]byte, error)
+
+// SetSnapshotProvider 为主题注册快照提供者；传入 nil 则取消注册。
+// 主题关闭后快照提供者会被自动移除。
+// 参数:
+//   - provider: 快照提供者
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetSnapshotProvider(provider SnapshotProvider) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	t.p.setSnapshotProvider(t.topic, provider)
+	return nil
+}
+
+// RequestSnapshot 向主题成员请求快照。
+// 如果没有指定对等节点，则依次尝试当前主题中已连接的对等节点，直到有一个成功返回。
+// 参数:
+//   - ctx: 上下文
+//   - peers: 可选的目标对等节点列表
+//
+// 返回值:
+//   - []byte: 快照数据
+//   - error: 错误信息，如果有的话
+func (t *Topic) RequestSnapshot(ctx context.Context, peers ...peer.ID) ([]byte, error) {
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
+		return nil, ErrTopicClosed
+	}
+
+	if len(peers) == 0 {
+		peers = t.p.ListPeers(t.topic)
+	}
+	if len(peers) == 0 {
+		return nil, ErrNoSnapshotPeers
+	}
+
+	var lastErr error
+	for _, pid := range peers {
+		data, err := t.p.requestSnapshot(ctx, pid, t.topic)
+		if err == nil {
+			return data, nil
+		}
+		logger.Debugf("从 %s 请求主题 %s 的快照失败: %s", pid, t.topic, err)
+		lastErr = err
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	return nil, lastErr
+}
+
+// setSnapshotProvider 设置主题的快照提供者
+// 参数:
+//   - topic: 主题
+//   - provider: 快照提供者，为 nil 时移除
+func (p *PubSub) setSnapshotProvider(topic string, provider SnapshotProvider) {
+	p.snapshotMx.Lock()
+	defer p.snapshotMx.Unlock()
+
+	if provider == nil {
+		delete(p.snapshotProviders, topic)
+		return
+	}
+	p.snapshotProviders[topic] = provider
+}
+
+// getSnapshotProvider 获取主题的快照提供者
+// 参数:
+//   - topic: 主题
+//
+// 返回值:
+//   - SnapshotProvider: 快照提供者，不存在时为 nil
+func (p *PubSub) getSnapshotProvider(topic string) SnapshotProvider {
+	p.snapshotMx.RLock()
+	defer p.snapshotMx.RUnlock()
+
+	return p.snapshotProviders[topic]
+}
+
+// requestSnapshot 通过快照协议向对等节点请求主题快照
+// 参数:
+//   - ctx: 上下文
+//   - pid: 对等节点 ID
+//   - topic: 主题
+//
+// 返回值:
+//   - []byte: 快照数据
+//   - error: 错误信息
+func (p *PubSub) requestSnapshot(ctx context.Context, pid peer.ID, topic string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, SnapshotTimeout)
+	defer cancel()
+
+	s, err := p.host.NewStream(ctx, pid, p.prefixed(SnapshotID))
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		s.SetDeadline(deadline)
+	}
+
+	w := msgio.NewVarintWriter(s)
+	if err := w.WriteMsg([]byte(topic)); err != nil {
+		s.Reset()
+		return nil, err
+	}
+	s.CloseWrite() // 请求已发送完毕
+
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize+1)
+	resp, err := r.ReadMsg()
+	if err != nil {
+		s.Reset()
+		return nil, err
+	}
+	defer r.ReleaseMsg(resp)
+
+	if len(resp) == 0 {
+		return nil, fmt.Errorf("无效的快照响应")
+	}
+
+	data := make([]byte, len(resp)-1)
+	copy(data, resp[1:])
+
+	if resp[0] != snapshotStatusOK {
+		return nil, fmt.Errorf("对等节点 %s 拒绝快照请求: %s", pid, string(data))
+	}
+
+	return data, nil
+}
+
+// handleSnapshotStream 处理快照请求流
+// 参数:
+//   - s: 网络流
+func (p *PubSub) handleSnapshotStream(s network.Stream) {
+	defer s.Close()
+
+	s.SetDeadline(time.Now().Add(SnapshotTimeout))
+
+	from := s.Conn().RemotePeer()
+	r := msgio.NewVarintReaderSize(s, p.maxMessageSize)
+	req, err := r.ReadMsg()
+	if err != nil {
+		logger.Debugf("从 %s 读取快照请求失败: %s", from, err)
+		s.Reset()
+		return
+	}
+	topic := string(req)
+	r.ReleaseMsg(req)
+
+	resp := []byte{snapshotStatusOK}
+	provider := p.getSnapshotProvider(topic)
+	switch {
+	case provider == nil:
+		resp = append([]byte{snapshotStatusError}, fmt.Sprintf("主题 %s 没有快照提供者", topic)...)
+	case !p.servesTopic(from, topic):
+		resp = append([]byte{snapshotStatusError}, fmt.Sprintf("未授权请求主题 %s 的快照", topic)...)
+	default:
+		ctx, cancel := context.WithTimeout(p.ctx, SnapshotTimeout)
+		data, err := provider(ctx, topic, from)
+		cancel()
+		switch {
+		case err != nil:
+			resp = append([]byte{snapshotStatusError}, err.Error()...)
+		case len(data) > p.maxMessageSize:
+			resp = append([]byte{snapshotStatusError}, "快照超过最大消息大小"...)
+		default:
+			resp = append(resp, data...)
+		}
+	}
+
+	w := msgio.NewVarintWriter(s)
+	if err := w.WriteMsg(resp); err != nil {
+		logger.Debugf("向 %s 写入快照响应失败: %s", from, err)
+		s.Reset()
+	}
+}
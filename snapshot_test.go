@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestTopicSnapshot 测试通过快照协议向主题成员请求快照
+func TestTopicSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	// 只有节点 1 提供快照
+	want := []byte("members: a, b, c")
+	err := topics[1].SetSnapshotProvider(func(ctx context.Context, topic string, from peer.ID) ([]byte, error) {
+		if from != hosts[0].ID() {
+			return nil, fmt.Errorf("unexpected requester %s", from)
+		}
+		return want, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	// 指定没有提供者的节点时返回错误
+	if _, err := topics[0].RequestSnapshot(ctx, hosts[2].ID()); err == nil {
+		t.Fatal("expected error when requesting snapshot from peer without provider")
+	}
+
+	// 未指定节点时尝试所有主题成员
+	got, err := topics[0].RequestSnapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected snapshot %q, got %q", want, got)
+	}
+
+	// 移除提供者后请求失败
+	if err := topics[1].SetSnapshotProvider(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].RequestSnapshot(ctx, hosts[1].ID()); err == nil {
+		t.Fatal("expected error after provider removal")
+	}
+}
+
+// TestTopicSnapshotRequiresSubscription 测试未订阅主题的节点无法请求快照
+func TestTopicSnapshotRequiresSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	if _, err := topics[1].Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	err := topics[1].SetSnapshotProvider(func(ctx context.Context, topic string, from peer.ID) ([]byte, error) {
+		return []byte("state"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	if _, err := topics[0].RequestSnapshot(ctx, hosts[1].ID()); err == nil {
+		t.Fatal("expected error when requesting snapshot without subscribing")
+	}
+
+	if _, err := topics[0].Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	if _, err := topics[0].RequestSnapshot(ctx, hosts[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,255 @@
+// 作用：出站流的复用与重连策略。
+// 功能：出站流空闲超过设定时间后被关闭，下一次发送时重新打开；写入失败时按指数退避重新打开流并重试，
+// 重试用尽后调用写入失败回调并按原有流程将对等节点视为死亡。默认不关闭空闲流、不重试，与之前的行为一致。
+
+package pubsub
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/dep2p/go-dep2p/multiformats/varint"
+	pool "github.com/dep2p/go-dep2p/p2plib/buffer/pool"
+
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+const (
+	// DefaultStreamRetryBackoff 是重新打开出站流的默认初始退避时间
+	DefaultStreamRetryBackoff = 100 * time.Millisecond
+	// DefaultStreamRetryMaxBackoff 是重新打开出站流的默认最大退避时间
+	DefaultStreamRetryMaxBackoff = 5 * time.Second
+)
+
+// PeerWriteFailureHandler 在向对等节点写入 RPC 永久失败（重试用尽）时被调用
+type PeerWriteFailureHandler func(p peer.ID, err error)
+
+// streamPolicy 是出站流的生命周期策略
+type streamPolicy struct {
+	idleTimeout time.Duration           // 空闲超时，为 0 时不关闭空闲流
+	maxRetries  int                     // 写入失败后的最大重试次数
+	backoff     time.Duration           // 初始退避时间
+	maxBackoff  time.Duration           // 最大退避时间
+	onFailure   PeerWriteFailureHandler // 写入永久失败的回调
+}
+
+// WithStreamIdleTimeout 是一个选项，在出站流空闲超过给定时间后关闭它，下一次发送时重新打开
+// 参数:
+//   - timeout: 空闲超时
+//
+// 返回值:
+//   - Option: 配置选项
+func WithStreamIdleTimeout(timeout time.Duration) Option {
+	return func(ps *PubSub) error {
+		if timeout <= 0 {
+			logger.Warnf("出站流的空闲超时必须为正数")
+			return fmt.Errorf("出站流的空闲超时必须为正数")
+		}
+		ps.streamPolicy.idleTimeout = timeout
+		return nil
+	}
+}
+
+// WithStreamRetry 是一个选项，写入失败时按指数退避重新打开出站流并重试，最多重试 maxRetries 次
+// 参数:
+//   - maxRetries: 最大重试次数
+//   - backoff: 初始退避时间，为 0 时使用 DefaultStreamRetryBackoff
+//   - maxBackoff: 最大退避时间，为 0 时使用 DefaultStreamRetryMaxBackoff
+//
+// 返回值:
+//   - Option: 配置选项
+func WithStreamRetry(maxRetries int, backoff, maxBackoff time.Duration) Option {
+	return func(ps *PubSub) error {
+		if maxRetries <= 0 || backoff < 0 || maxBackoff < 0 {
+			logger.Warnf("无效的出站流重试参数")
+			return fmt.Errorf("无效的出站流重试参数")
+		}
+		if backoff == 0 {
+			backoff = DefaultStreamRetryBackoff
+		}
+		if maxBackoff == 0 {
+			maxBackoff = DefaultStreamRetryMaxBackoff
+		}
+		if maxBackoff < backoff {
+			logger.Warnf("最大退避时间不能小于初始退避时间")
+			return fmt.Errorf("最大退避时间不能小于初始退避时间")
+		}
+		ps.streamPolicy.maxRetries = maxRetries
+		ps.streamPolicy.backoff = backoff
+		ps.streamPolicy.maxBackoff = maxBackoff
+		return nil
+	}
+}
+
+// WithPeerWriteFailureHandler 是一个选项，设置写入 RPC 永久失败时的回调。
+// 回调在对等节点的写入协程中调用，不能阻塞。
+// 参数:
+//   - fn: 回调函数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithPeerWriteFailureHandler(fn PeerWriteFailureHandler) Option {
+	return func(ps *PubSub) error {
+		if fn == nil {
+			logger.Warnf("写入失败回调不能为空")
+			return fmt.Errorf("写入失败回调不能为空")
+		}
+		ps.streamPolicy.onFailure = fn
+		return nil
+	}
+}
+
+// streamWriter 按流策略向一个对等节点写入 RPC，只由该对等节点的写入协程使用
+type streamWriter struct {
+	p     *PubSub
+	pid   peer.ID
+	proto protocol.ID // 重新打开流时使用的协议
+
+	s       network.Stream // 当前的出站流，空闲关闭后为 nil
+	retired *atomic.Bool   // 当前流的退役标记
+}
+
+// write 写入 RPC，失败时按流策略重新打开流并重试
+// 参数:
+//   - ctx: 上下文
+//   - rpc: RPC 消息
+//
+// 返回值:
+//   - error: 重试用尽后的错误
+func (w *streamWriter) write(ctx context.Context, rpc *RPC) error {
+	policy := w.p.streamPolicy
+	backoff := policy.backoff
+
+	var err error
+	for attempt := 0; attempt <= policy.maxRetries; attempt++ {
+		if attempt > 0 {
+			logger.Debugf("写入消息到 %s 失败: %s; %s 后重试", w.pid, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			if backoff *= 2; backoff > policy.maxBackoff {
+				backoff = policy.maxBackoff
+			}
+		}
+
+		if w.s == nil {
+			if err = w.open(ctx); err != nil {
+				continue
+			}
+		}
+		if err = writeRPC(w.s, rpc); err == nil {
+			return nil
+		}
+		if policy.maxRetries > 0 {
+			w.retire() // 重试前替换失败的流
+		}
+	}
+	return err
+}
+
+// open 重新打开出站流
+// 参数:
+//   - ctx: 上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (w *streamWriter) open(ctx context.Context) error {
+	s, err := w.p.host.NewStream(ctx, w.pid, w.proto)
+	if err != nil {
+		return err
+	}
+	w.s = s
+	w.retired = new(atomic.Bool)
+	go w.p.handlePeerDead(s, w.retired)
+	return nil
+}
+
+// retire 主动关闭当前流，不通知节点死亡
+func (w *streamWriter) retire() {
+	if w.s == nil {
+		return
+	}
+	w.retired.Store(true)
+	w.s.Close()
+	w.s = nil
+}
+
+// fail 处理写入永久失败：调用回调，重置流并通知节点死亡
+// 参数:
+//   - err: 写入错误
+func (w *streamWriter) fail(err error) {
+	logger.Debugf("写入消息到 %s 失败: %s", w.pid, err)
+	if fn := w.p.streamPolicy.onFailure; fn != nil {
+		fn(w.pid, err)
+	}
+	if w.s != nil {
+		w.s.Reset() // 重置流，由 handlePeerDead 通知节点死亡
+		w.s = nil
+		return
+	}
+	w.p.notifyPeerDead(w.pid)
+}
+
+// close 关闭当前流
+func (w *streamWriter) close() {
+	if w.s != nil {
+		w.s.Close()
+	}
+}
+
+// idleTimer 创建空闲计时器，未设置空闲超时时计时器永不触发
+// 返回值:
+//   - *time.Timer: 空闲计时器
+func (w *streamWriter) idleTimer() *time.Timer {
+	t := time.NewTimer(w.p.streamPolicy.idleTimeout)
+	if w.p.streamPolicy.idleTimeout == 0 {
+		t.Stop()
+	}
+	return t
+}
+
+// resetIdle 在写入后重新开始空闲计时
+// 参数:
+//   - t: 空闲计时器
+func (w *streamWriter) resetIdle(t *time.Timer) {
+	if w.p.streamPolicy.idleTimeout == 0 {
+		return
+	}
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(w.p.streamPolicy.idleTimeout)
+}
+
+// writeRPC 将 RPC 以变长整数长度前缀写入流
+// 参数:
+//   - s: 网络流
+//   - rpc: RPC 消息
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func writeRPC(s network.Stream, rpc *RPC) error {
+	size := uint64(rpc.Size()) // 获取RPC消息的大小
+
+	buf := pool.Get(varint.UvarintSize(size) + int(size)) // 从池中获取缓冲区
+	defer pool.Put(buf)                                   // 使用完毕后将缓冲区放回池中
+
+	n := binary.PutUvarint(buf, size) // 将消息大小编码为变长整数并写入缓冲区
+	_, err := rpc.MarshalTo(buf[n:])  // 将RPC消息序列化到缓冲区
+	if err != nil {
+		return err // 如果序列化出错，返回错误
+	}
+
+	_, err = s.Write(buf) // 将缓冲区中的数据写入网络流
+	return err            // 返回写入操作的错误（如果有）
+}
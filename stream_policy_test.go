@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestStreamPolicyOptions 测试出站流策略选项的参数校验与默认值
+func TestStreamPolicyOptions(t *testing.T) {
+	p := &PubSub{}
+	if err := WithStreamIdleTimeout(0)(p); err == nil {
+		t.Fatal("expected error for a non-positive idle timeout")
+	}
+	if err := WithStreamRetry(0, 0, 0)(p); err == nil {
+		t.Fatal("expected error for a non-positive retry count")
+	}
+	if err := WithStreamRetry(3, time.Second, time.Millisecond)(p); err == nil {
+		t.Fatal("expected error for a max backoff below the base backoff")
+	}
+	if err := WithPeerWriteFailureHandler(nil)(p); err == nil {
+		t.Fatal("expected error for a nil failure handler")
+	}
+
+	if err := WithStreamRetry(3, 0, 0)(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.streamPolicy.backoff != DefaultStreamRetryBackoff || p.streamPolicy.maxBackoff != DefaultStreamRetryMaxBackoff {
+		t.Fatalf("expected default backoffs, got %s and %s", p.streamPolicy.backoff, p.streamPolicy.maxBackoff)
+	}
+}
+
+// TestStreamIdleTimeoutReopen 测试空闲关闭的出站流在下一次发送时重新打开，且对等节点不被视为死亡
+func TestStreamIdleTimeoutReopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithStreamIdleTimeout(100*time.Millisecond)),
+		getPubsub(ctx, hosts[1]),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	sub, err := psubs[1].Subscribe("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		time.Sleep(500 * time.Millisecond) // 等待出站流空闲关闭
+
+		if err := topic.Publish(ctx, []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		nctx, ncancel := context.WithTimeout(ctx, 5*time.Second)
+		msg, err := sub.Next(nctx)
+		ncancel()
+		if err != nil {
+			t.Fatalf("message %d was not delivered after the idle stream was closed: %s", i, err)
+		}
+		if string(msg.Data) != "hello" {
+			t.Fatalf("unexpected message %q", msg.Data)
+		}
+	}
+
+	if peers := topic.ListPeers(); len(peers) != 1 || peers[0] != hosts[1].ID() {
+		t.Fatalf("expected the peer to stay alive, got %v", peers)
+	}
+}
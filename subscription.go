@@ -6,6 +6,8 @@ package pubsub
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Subscription 处理特定主题订阅的详细信息。
@@ -17,6 +19,18 @@ type Subscription struct {
 	ctx      context.Context      // 上下文，用于取消操作
 	err      error                // 错误信息
 	once     sync.Once            // 确保某些操作只执行一次的机制
+	catchUp  bool                 // 订阅后是否请求追赶补发
+	filter   MessageFilter        // 消息过滤函数
+	merged   *MergedSubscription  // 所属的合并订阅，通道由合并订阅共享
+
+	order         DeliveryOrder // 投递顺序，为 0 时不重排
+	reorderWindow time.Duration // 重排窗口
+	out           chan *Message // 有序投递时应用读取的通道
+
+	overflow   OverflowPolicy // 缓冲区已满时的处理策略
+	lagHandler LagHandler     // 滞后处理函数
+	dropped    atomic.Uint64  // 因缓冲区溢出丢弃的消息总数
+	lagged     atomic.Uint64  // 尚未通知滞后处理函数的丢弃消息数
 }
 
 // Topic 返回与订阅关联的主题字符串。
@@ -33,11 +47,17 @@ func (sub *Subscription) Topic() string {
 // - *Message: 下一条消息，如果有的话
 // - error: 错误信息，如果有的话
 func (sub *Subscription) Next(ctx context.Context) (*Message, error) {
+	ch := sub.ch
+	if sub.out != nil { // 有序投递时从重排后的通道读取
+		ch = sub.out
+	}
+
 	select {
-	case msg, ok := <-sub.ch: // 从消息通道读取消息
+	case msg, ok := <-ch: // 从消息通道读取消息
 		if !ok { // 如果通道已关闭
 			return msg, sub.err // 返回消息和错误信息
 		}
+		sub.notifyLag() // 通知滞后处理函数丢弃的消息数
 		return msg, nil // 返回消息和空错误信息
 	case <-ctx.Done(): // 如果上下文已取消
 		return nil, ctx.Err() // 返回空消息和上下文错误
@@ -56,6 +76,10 @@ func (sub *Subscription) Cancel() {
 // 确保该操作只执行一次。
 func (sub *Subscription) close() {
 	sub.once.Do(func() {
+		if sub.merged != nil { // 共享通道由合并订阅在最后一个订阅关闭时关闭
+			sub.merged.release(sub.err)
+			return
+		}
 		close(sub.ch) // 关闭消息通道
 	})
 }
@@ -36,6 +36,14 @@ type SubscriptionFilter interface {
 	FilterIncomingSubscriptions(from peer.ID, subs []*pb.RPC_SubOpts) ([]*pb.RPC_SubOpts, error)
 }
 
+// PeerSubscriptionLimiter 是订阅过滤器可以选择实现的接口，用于限制每个对等节点被跟踪的订阅总数。
+// 与 WrapLimitSubscriptionFilter 限制单个 RPC 中的订阅数量不同，它限制对等节点通过多个 RPC 累积的订阅，
+// 超出上限的新订阅通知会被忽略。
+type PeerSubscriptionLimiter interface {
+	// MaxSubscriptionsPerPeer 返回每个对等节点被跟踪的订阅数量上限，0 表示不限制
+	MaxSubscriptionsPerPeer() int
+}
+
 // WithSubscriptionFilter 是一个 pubsub 选项，用于指定感兴趣主题的订阅过滤器。
 // 参数:
 // - subFilter: 要应用的 SubscriptionFilter
@@ -195,6 +203,13 @@ func (f *limitSubscriptionFilter) CanSubscribe(topic string) bool {
 	return f.filter.CanSubscribe(topic)
 }
 
+// MaxSubscriptionsPerPeer 返回内部过滤器的每个对等节点订阅数量上限
+// 返回值:
+// - int: 订阅数量上限，0 表示不限制
+func (f *limitSubscriptionFilter) MaxSubscriptionsPerPeer() int {
+	return maxSubscriptionsPerPeer(f.filter)
+}
+
 // FilterIncomingSubscriptions 过滤传入的订阅，仅保留感兴趣的订阅，并返回过滤后的订阅列表。
 // 如果订阅数量超过限制，则返回错误。
 // 参数:
@@ -211,3 +226,62 @@ func (f *limitSubscriptionFilter) FilterIncomingSubscriptions(from peer.ID, subs
 
 	return f.filter.FilterIncomingSubscriptions(from, subs)
 }
+
+// WrapPeerLimitSubscriptionFilter 包装一个订阅过滤器，限制每个对等节点被跟踪的订阅总数。
+// 参数:
+// - filter: 内部使用的 SubscriptionFilter
+// - limit: 每个对等节点的订阅数量上限
+// 返回值:
+// - SubscriptionFilter: 包装后的订阅过滤器
+func WrapPeerLimitSubscriptionFilter(filter SubscriptionFilter, limit int) SubscriptionFilter {
+	return &peerLimitSubscriptionFilter{filter: filter, limit: limit}
+}
+
+// peerLimitSubscriptionFilter 是一个结构体，保存订阅过滤器和每个对等节点的订阅数量上限
+type peerLimitSubscriptionFilter struct {
+	filter SubscriptionFilter // 内部使用的订阅过滤器
+	limit  int                // 每个对等节点的订阅数量上限
+}
+
+// 确保 peerLimitSubscriptionFilter 实现了 SubscriptionFilter 和 PeerSubscriptionLimiter 接口
+var _ SubscriptionFilter = (*peerLimitSubscriptionFilter)(nil)
+var _ PeerSubscriptionLimiter = (*peerLimitSubscriptionFilter)(nil)
+
+// CanSubscribe 返回 true 如果可以通过内部过滤器订阅主题
+// 参数:
+// - topic: 要检查的主题
+// 返回值:
+// - bool: 是否允许订阅该主题
+func (f *peerLimitSubscriptionFilter) CanSubscribe(topic string) bool {
+	return f.filter.CanSubscribe(topic)
+}
+
+// FilterIncomingSubscriptions 使用内部过滤器过滤传入的订阅
+// 参数:
+// - from: 订阅来源的 peer.ID
+// - subs: 包含订阅通知的 RPC_SubOpts 列表
+// 返回值:
+// - []*pb.RPC_SubOpts: 过滤后的订阅列表
+// - error: 错误信息，如果有的话
+func (f *peerLimitSubscriptionFilter) FilterIncomingSubscriptions(from peer.ID, subs []*pb.RPC_SubOpts) ([]*pb.RPC_SubOpts, error) {
+	return f.filter.FilterIncomingSubscriptions(from, subs)
+}
+
+// MaxSubscriptionsPerPeer 返回每个对等节点的订阅数量上限
+// 返回值:
+// - int: 订阅数量上限
+func (f *peerLimitSubscriptionFilter) MaxSubscriptionsPerPeer() int {
+	return f.limit
+}
+
+// maxSubscriptionsPerPeer 返回订阅过滤器的每个对等节点订阅数量上限
+// 参数:
+// - filter: 订阅过滤器
+// 返回值:
+// - int: 订阅数量上限，0 表示不限制
+func maxSubscriptionsPerPeer(filter SubscriptionFilter) int {
+	if l, ok := filter.(PeerSubscriptionLimiter); ok {
+		return l.MaxSubscriptionsPerPeer()
+	}
+	return 0
+}
@@ -246,3 +246,42 @@ func TestSubscriptionFilterRPC(t *testing.T) {
 		t.Fatal("expected no subscription for test1")
 	}
 }
+
+// TestSubscriptionFilterPeerLimit 测试每个对等节点的订阅数量上限
+func TestSubscriptionFilterPeerLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	filter := WrapPeerLimitSubscriptionFilter(NewRegexpSubscriptionFilter(regexp.MustCompile("^test")), 2)
+	if limit := maxSubscriptionsPerPeer(WrapLimitSubscriptionFilter(filter, 10)); limit != 2 {
+		t.Fatalf("expected wrapped filter to keep the per-peer limit, got %d", limit)
+	}
+
+	hosts := getDefaultHosts(t, 2)
+	ps1 := getPubsub(ctx, hosts[0], WithSubscriptionFilter(filter))
+	ps2 := getPubsub(ctx, hosts[1])
+
+	_ = mustSubscribe(t, ps2, "test1")
+	_ = mustSubscribe(t, ps2, "test2")
+	_ = mustSubscribe(t, ps2, "test3")
+
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	var tracked, count int
+	ready := make(chan struct{})
+	ps1.eval <- func() {
+		for _, tmap := range ps1.topics {
+			if _, ok := tmap[hosts[1].ID()]; ok {
+				tracked++
+			}
+		}
+		count = ps1.peerSubs[hosts[1].ID()]
+		ready <- struct{}{}
+	}
+	<-ready
+
+	if tracked != 2 || count != 2 {
+		t.Fatalf("expected 2 tracked subscriptions, got %d (count %d)", tracked, count)
+	}
+}
@@ -0,0 +1,155 @@
+// 作用：子系统的运行时开关。
+// 功能：在不拆除整个 PubSub 的情况下单独停止或恢复各个子系统（gossip 发出、PX 处理、发现广告、事件追踪和指标），
+// 让运维人员可以在事故中（例如 gossip 风暴）有针对性地缓解问题，同时保持消息投递。
+
+package pubsub
+
+import (
+	"fmt"
+)
+
+// Subsystem 标识一个可以在运行时停止的子系统
+type Subsystem int
+
+const (
+	// SubsystemGossip 是 gossipsub 的 IHAVE gossip 发出；停止后网格转发不受影响
+	SubsystemGossip Subsystem = iota
+	// SubsystemPX 是对收到的 PRUNE 中 PX 对等节点的处理（gossipsub）
+	SubsystemPX
+	// SubsystemDiscovery 是在发现服务中广告订阅的主题；恢复时重新广告所有仍然订阅的主题
+	SubsystemDiscovery
+	// SubsystemTracing 是通过 WithEventTracer 配置的事件追踪
+	SubsystemTracing
+	// SubsystemMetrics 是通过 WithRawTracer 配置的应用程序低级追踪器；路由器内部的评分、门控、连接管理器标记和追踪上下文传播不受影响
+	SubsystemMetrics
+)
+
+// String 返回子系统的名称
+// 返回值:
+//   - string: 子系统名称
+func (s Subsystem) String() string {
+	switch s {
+	case SubsystemGossip:
+		return "gossip"
+	case SubsystemPX:
+		return "px"
+	case SubsystemDiscovery:
+		return "discovery"
+	case SubsystemTracing:
+		return "tracing"
+	case SubsystemMetrics:
+		return "metrics"
+	default:
+		return fmt.Sprintf("subsystem(%d)", int(s))
+	}
+}
+
+// StopSubsystem 在运行时停止一个子系统，直到调用 StartSubsystem。停止已经停止的子系统没有效果。
+// 参数:
+//   - s: 子系统
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) StopSubsystem(s Subsystem) error {
+	return p.setSubsystem(s, false)
+}
+
+// StartSubsystem 恢复被 StopSubsystem 停止的子系统。恢复正在运行的子系统没有效果。
+// 参数:
+//   - s: 子系统
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) StartSubsystem(s Subsystem) error {
+	return p.setSubsystem(s, true)
+}
+
+// SubsystemStopped 返回子系统是否被 StopSubsystem 停止
+// 参数:
+//   - s: 子系统
+//
+// 返回值:
+//   - bool: 子系统是否已停止
+func (p *PubSub) SubsystemStopped(s Subsystem) bool {
+	out := make(chan bool, 1)
+	select {
+	case p.eval <- func() {
+		_, stopped := p.stoppedSubsystems[s]
+		out <- stopped
+	}:
+		return <-out
+	case <-p.ctx.Done():
+		return false
+	}
+}
+
+// setSubsystem 停止或恢复一个子系统
+// 参数:
+//   - s: 子系统
+//   - running: 是否运行
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) setSubsystem(s Subsystem, running bool) error {
+	if s < SubsystemGossip || s > SubsystemMetrics {
+		logger.Warnf("未知的子系统: %s", s)
+		return fmt.Errorf("未知的子系统: %s", s)
+	}
+
+	out := make(chan error, 1)
+	select {
+	case p.eval <- func() { out <- p.doSetSubsystem(s, running) }:
+		return <-out
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+}
+
+// doSetSubsystem 停止或恢复一个子系统。只从 processLoop 调用。
+// 参数:
+//   - s: 子系统
+//   - running: 是否运行
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) doSetSubsystem(s Subsystem, running bool) error {
+	switch s {
+	case SubsystemGossip, SubsystemPX:
+		gs, ok := p.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("发布订阅路由器不是 gossipsub 类型")
+			return fmt.Errorf("发布订阅路由器不是 gossipsub 类型")
+		}
+		if s == SubsystemGossip {
+			gs.gossipStopped = !running
+		} else {
+			gs.pxStopped = !running
+		}
+
+	case SubsystemDiscovery:
+		if running {
+			p.disc.resumeAdvertising()
+		} else {
+			p.disc.pauseAdvertising()
+		}
+
+	case SubsystemTracing:
+		if p.tracer != nil {
+			p.tracer.tracingStopped.Store(!running)
+		}
+
+	case SubsystemMetrics:
+		if p.tracer != nil {
+			p.tracer.metricsStopped.Store(!running)
+		}
+	}
+
+	if running {
+		delete(p.stoppedSubsystems, s)
+		logger.Infof("子系统 %s 已恢复", s)
+	} else {
+		p.stoppedSubsystems[s] = struct{}{}
+		logger.Infof("子系统 %s 已停止", s)
+	}
+	return nil
+}
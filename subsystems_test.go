@@ -0,0 +1,159 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// throttleRecorder 记录被限制的对等节点的低级追踪器
+type throttleRecorder struct {
+	RawTracer // 未实现的方法不会被调用
+
+	mx    sync.Mutex
+	peers []peer.ID
+}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (r *throttleRecorder) ThrottlePeer(p peer.ID) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.peers = append(r.peers, p)
+}
+
+// eventCounter 按类型统计追踪事件
+type eventCounter struct {
+	mx     sync.Mutex
+	counts map[pb.TraceEvent_Type]int
+}
+
+// Trace 实现 EventTracer 接口
+func (c *eventCounter) Trace(evt *pb.TraceEvent) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.counts[evt.GetType()]++
+}
+
+func (c *eventCounter) count(typ pb.TraceEvent_Type) int {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	return c.counts[typ]
+}
+
+// TestSubsystemMetrics 测试停止指标子系统时只跳过应用程序的低级追踪器
+func TestSubsystemMetrics(t *testing.T) {
+	user := &throttleRecorder{}
+	internal := &throttleRecorder{}
+	tr := &pubsubTracer{raw: []RawTracer{user}}
+	tr.addInternalRaw(internal)
+
+	tr.metricsStopped.Store(true)
+	tr.ThrottlePeer("a")
+	if len(user.peers) != 0 {
+		t.Fatal("expected application tracer to be skipped while metrics are stopped")
+	}
+	if len(internal.peers) != 1 {
+		t.Fatal("expected internal tracer to keep running while metrics are stopped")
+	}
+
+	tr.metricsStopped.Store(false)
+	tr.ThrottlePeer("b")
+	if len(user.peers) != 1 || len(internal.peers) != 2 {
+		t.Fatal("expected all tracers to run after metrics are restarted")
+	}
+}
+
+// TestSubsystemMetricsKeepsTagTracer 测试连接管理器标记追踪器注册为内部追踪器，不受指标开关影响
+func TestSubsystemMetricsKeepsTagTracer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getGossipsub(ctx, hosts[0])
+	gs := ps.rt.(*GossipSubRouter)
+
+	found := false
+	for _, tracer := range ps.tracer.internal {
+		if tracer == RawTracer(gs.tagTracer) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("expected the tag tracer to be registered as an internal tracer")
+	}
+}
+
+// TestSubsystemStopStart 测试在运行时停止和恢复子系统
+func TestSubsystemStopStart(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	events := &eventCounter{counts: make(map[pb.TraceEvent_Type]int)}
+	ps := getGossipsub(ctx, hosts[0], WithEventTracer(events))
+	gs := ps.rt.(*GossipSubRouter)
+
+	topic, err := ps.Join("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 停止事件追踪后发布不产生事件
+	if err := ps.StopSubsystem(SubsystemTracing); err != nil {
+		t.Fatal(err)
+	}
+	if !ps.SubsystemStopped(SubsystemTracing) {
+		t.Fatal("expected tracing to be stopped")
+	}
+	if err := topic.Publish(ctx, []byte("quiet")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.StartSubsystem(SubsystemTracing); err != nil {
+		t.Fatal(err)
+	}
+	if n := events.count(pb.TraceEvent_PUBLISH_MESSAGE); n != 0 {
+		t.Fatalf("expected no publish events while tracing is stopped, got %d", n)
+	}
+	if err := topic.Publish(ctx, []byte("traced")); err != nil {
+		t.Fatal(err)
+	}
+	if n := events.count(pb.TraceEvent_PUBLISH_MESSAGE); n != 1 {
+		t.Fatalf("expected one publish event after tracing is restarted, got %d", n)
+	}
+
+	// 停止 gossip 和 PX
+	if err := ps.StopSubsystem(SubsystemGossip); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.StopSubsystem(SubsystemPX); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	ps.eval <- func() {
+		defer close(done)
+		if !gs.gossipStopped || !gs.pxStopped {
+			t.Error("expected gossip and PX to be stopped")
+		}
+	}
+	<-done
+	if err := ps.StartSubsystem(SubsystemGossip); err != nil {
+		t.Fatal(err)
+	}
+	if ps.SubsystemStopped(SubsystemGossip) || !ps.SubsystemStopped(SubsystemPX) {
+		t.Fatal("expected only PX to remain stopped")
+	}
+
+	// 未知的子系统返回错误
+	if err := ps.StopSubsystem(Subsystem(42)); err == nil {
+		t.Fatal("expected error for unknown subsystem")
+	}
+
+	// gossip 子系统只适用于 gossipsub
+	fs := getPubsub(ctx, hosts[1])
+	if err := fs.StopSubsystem(SubsystemGossip); err == nil {
+		t.Fatal("expected error for gossip subsystem on floodsub")
+	}
+}
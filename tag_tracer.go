@@ -27,8 +27,14 @@ var (
 
 	// GossipSubConnTagMessageDeliveryCap 是用于跟踪消息传递的连接管理器标签的最大值。
 	GossipSubConnTagMessageDeliveryCap = 15
+
+	// GossipSubConnTagDirectFactor 是启用 WithConnManagerTagging 时直接对等节点标签值相对于网格标签值的倍数。
+	GossipSubConnTagDirectFactor = 50
 )
 
+// directTag 是直接对等节点使用的连接管理器标签
+const directTag = "pubsub:<direct>"
+
 // tagTracer 是一个内部跟踪器，它根据对等节点的行为对对等节点连接应用连接管理器标签。
 // 我们出于以下原因对对等节点的连接进行标记：
 //   - 直接连接的对等节点被标记为 GossipSubConnTagValueDirectPeer（默认值为 1000）。
@@ -45,6 +51,9 @@ type tagTracer struct {
 	decaying map[string]connmgr.DecayingTag // 衰减标签的映射
 	direct   map[peer.ID]struct{}           // 直接对等节点的集合
 
+	meshTag  int                 // 网格对等节点的标签值，0 表示只保护不打标签
+	graceTag connmgr.DecayingTag // 离开网格的对等节点在衰减期内保留的标签，为 nil 时立即移除
+
 	// 消息 ID 映射到在消息完成验证之前传递消息但不是第一个传递的对等节点集合
 	nearFirst map[string]map[peer.ID]struct{}
 }
@@ -90,7 +99,10 @@ func (t *tagTracer) tagPeerIfDirect(p peer.ID) {
 
 	_, direct := t.direct[p]
 	if direct {
-		t.cmgr.Protect(p, "pubsub:<direct>")
+		t.cmgr.Protect(p, directTag)
+		if t.meshTag > 0 {
+			t.cmgr.TagPeer(p, directTag, t.meshTag*GossipSubConnTagDirectFactor)
+		}
 	}
 }
 
@@ -101,6 +113,9 @@ func (t *tagTracer) tagPeerIfDirect(p peer.ID) {
 func (t *tagTracer) tagMeshPeer(p peer.ID, topic string) {
 	tag := topicTag(topic)
 	t.cmgr.Protect(p, tag)
+	if t.meshTag > 0 {
+		t.cmgr.TagPeer(p, tag, t.meshTag)
+	}
 }
 
 // untagMeshPeer 取消标记网状网络中的对等节点。
@@ -110,6 +125,52 @@ func (t *tagTracer) tagMeshPeer(p peer.ID, topic string) {
 func (t *tagTracer) untagMeshPeer(p peer.ID, topic string) {
 	tag := topicTag(topic)
 	t.cmgr.Unprotect(p, tag)
+	if t.meshTag == 0 {
+		return
+	}
+	t.cmgr.UntagPeer(p, tag)
+	if t.graceTag != nil {
+		// 在衰减期内保留标签值，避免刚离开网格的对等节点立即被修剪
+		if err := t.graceTag.Bump(p, t.meshTag); err != nil {
+			logger.Debugf("增加网格衰减标签失败: %s", err)
+		}
+	}
+}
+
+// WithConnManagerTagging 是一个 gossipsub 路由器选项，除保护网格和直接对等节点外，还在连接管理器中为其打上标签：
+// 网格对等节点在每个主题上的标签值为 baseTag，直接对等节点为 baseTag × GossipSubConnTagDirectFactor。
+// decay 为正数且连接管理器支持衰减标签时，离开网格的对等节点在 decay 时间内保留 baseTag 的标签值。
+// 参数:
+//   - baseTag: 网格对等节点的标签值
+//   - decay: 离开网格后保留标签的时间，0 表示立即移除
+//
+// 返回值:
+//   - Option: 配置选项
+func WithConnManagerTagging(baseTag int, decay time.Duration) Option {
+	return func(ps *PubSub) error {
+		gs, ok := ps.rt.(*GossipSubRouter)
+		if !ok {
+			logger.Warnf("pubsub 路由器不是 gossipsub")
+			return fmt.Errorf("pubsub 路由器不是 gossipsub")
+		}
+		if baseTag <= 0 || decay < 0 {
+			logger.Warnf("无效的连接管理器标签参数: %d, %s", baseTag, decay)
+			return fmt.Errorf("无效的连接管理器标签参数: %d, %s", baseTag, decay)
+		}
+
+		t := gs.tagTracer
+		t.meshTag = baseTag
+		if decay > 0 && t.decayer != nil && t.graceTag == nil {
+			tag, err := t.decayer.RegisterDecayingTag("pubsub-mesh-grace", decay,
+				connmgr.DecayExpireWhenInactive(decay), connmgr.BumpOverwrite())
+			if err != nil {
+				logger.Warnf("无法创建网格衰减标签: %s", err)
+				return err
+			}
+			t.graceTag = tag
+		}
+		return nil
+	}
 }
 
 // topicTag 返回用于标记主题的标签字符串。
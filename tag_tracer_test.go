@@ -256,3 +256,55 @@ func tagExists(mgr connmgri.ConnManager, p peer.ID, tag string) bool {
 	_, exists := info.Tags[tag]
 	return exists
 }
+
+// TestTagTracerMeshTagValues 测试 WithConnManagerTagging 为网格和直接对等节点打标签，并在离开网格后保留衰减标签
+func TestTagTracerMeshTagValues(t *testing.T) {
+	cmgr, err := connmgr.NewConnManager(5, 10, connmgr.WithGracePeriod(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt := newTagTracer(cmgr)
+	ps := &PubSub{rt: &GossipSubRouter{tagTracer: tt}}
+	if err := WithConnManagerTagging(0, 0)(ps); err == nil {
+		t.Fatal("expected error for a non-positive tag value")
+	}
+	if err := WithConnManagerTagging(20, time.Minute)(ps); err != nil {
+		t.Fatal(err)
+	}
+
+	tagValue := func(p peer.ID, tag string) (int, bool) {
+		info := cmgr.GetTagInfo(p)
+		if info == nil {
+			return 0, false
+		}
+		v, ok := info.Tags[tag]
+		return v, ok
+	}
+
+	p := peer.ID("a-peer")
+	topic := "a-topic"
+	tt.Join(topic)
+	tt.Graft(p, topic)
+	if v, _ := tagValue(p, "pubsub:"+topic); v != 20 {
+		t.Fatalf("expected mesh tag value 20, got %d", v)
+	}
+
+	tt.Prune(p, topic)
+	if _, ok := tagValue(p, "pubsub:"+topic); ok {
+		t.Fatal("expected the mesh tag to be removed")
+	}
+	deadline := time.Now().Add(time.Second)
+	for v, _ := tagValue(p, "pubsub-mesh-grace"); v != 20; v, _ = tagValue(p, "pubsub-mesh-grace") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the former mesh peer to keep a grace tag")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	d := peer.ID("direct-peer")
+	tt.direct = map[peer.ID]struct{}{d: {}}
+	tt.AddPeer(d, GossipSubID_v11)
+	if v, _ := tagValue(d, "pubsub:<direct>"); v != 20*GossipSubConnTagDirectFactor {
+		t.Fatalf("expected direct tag value %d, got %d", 20*GossipSubConnTagDirectFactor, v)
+	}
+}
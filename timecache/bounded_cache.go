@@ -0,0 +1,132 @@
+package timecache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// BoundedCache 是一个容量受限的时间缓存。
+// 除了按 TTL 过期外，当条目数超过容量时会淘汰队尾的条目，从而限制繁忙主题下的内存占用。
+// FirstSeen 策略下访问不改变顺序，按插入顺序先进先出淘汰；LastSeen 策略下命中会移到队首，淘汰最久未使用的条目。
+type BoundedCache struct {
+	lk       sync.Mutex               // 互斥锁，用于保护缓存数据
+	strategy Strategy                 // 过期策略
+	ttl      time.Duration            // 消息的存活时间
+	capacity int                      // 最大条目数
+	order    *list.List               // 按淘汰顺序排列的条目，队尾最先被淘汰
+	items    map[string]*list.Element // 消息到条目的映射
+}
+
+// boundedEntry 表示 BoundedCache 中的条目
+type boundedEntry struct {
+	id     string    // 消息 ID
+	expiry time.Time // 过期时间
+}
+
+// 确保 BoundedCache 实现了 TimeCache 接口
+var _ TimeCache = (*BoundedCache)(nil)
+
+// NewBoundedTimeCache 创建一个容量受限的时间缓存
+// 参数:
+// - strategy: 使用的过期策略
+// - ttl: 条目的存活时间
+// - capacity: 最大条目数，小于等于 0 时视为 1
+// 返回值：
+// - *BoundedCache: 新的 BoundedCache 实例
+func NewBoundedTimeCache(strategy Strategy, ttl time.Duration, capacity int) *BoundedCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+
+	return &BoundedCache{
+		strategy: strategy,
+		ttl:      ttl,
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Done 释放缓存资源；BoundedCache 在访问时惰性清理，没有后台协程
+func (tc *BoundedCache) Done() {}
+
+// Len 返回缓存中的条目数（可能包含尚未清理的过期条目）
+// 返回值:
+// - int: 条目数
+func (tc *BoundedCache) Len() int {
+	tc.lk.Lock()
+	defer tc.lk.Unlock()
+
+	return tc.order.Len()
+}
+
+// Has 检查消息是否存在于缓存中
+// 参数:
+// - s: 消息字符串
+// 返回值:
+// - bool: 消息是否存在
+func (tc *BoundedCache) Has(s string) bool {
+	tc.lk.Lock()
+	defer tc.lk.Unlock()
+
+	return tc.lookup(s, time.Now()) != nil
+}
+
+// Add 将消息添加到缓存中，必要时淘汰队尾的条目
+// 参数:
+// - s: 消息字符串
+// 返回值:
+// - bool: 是否成功添加消息（如果消息已存在，返回 false）
+func (tc *BoundedCache) Add(s string) bool {
+	tc.lk.Lock()
+	defer tc.lk.Unlock()
+
+	now := time.Now()
+	if tc.lookup(s, now) != nil {
+		return false
+	}
+
+	tc.items[s] = tc.order.PushFront(&boundedEntry{id: s, expiry: now.Add(tc.ttl)})
+
+	// 超出容量时从队尾淘汰：FirstSeen 下为最早插入的条目，LastSeen 下为最久未使用的条目
+	for tc.order.Len() > tc.capacity {
+		tc.remove(tc.order.Back())
+	}
+
+	return true
+}
+
+// lookup 查找未过期的条目，过期条目会被删除；LastSeen 策略下命中时延长过期时间
+// 参数:
+// - s: 消息字符串
+// - now: 当前时间
+// 返回值:
+// - *list.Element: 命中的条目，不存在时为 nil
+func (tc *BoundedCache) lookup(s string, now time.Time) *list.Element {
+	e, ok := tc.items[s]
+	if !ok {
+		return nil
+	}
+
+	entry := e.Value.(*boundedEntry)
+	if entry.expiry.Before(now) {
+		tc.remove(e)
+		return nil
+	}
+
+	if tc.strategy == Strategy_LastSeen {
+		entry.expiry = now.Add(tc.ttl)
+		tc.order.MoveToFront(e)
+	}
+
+	return e
+}
+
+// remove 删除条目
+// 参数:
+// - e: 要删除的条目
+func (tc *BoundedCache) remove(e *list.Element) {
+	tc.order.Remove(e)
+	delete(tc.items, e.Value.(*boundedEntry).id)
+}
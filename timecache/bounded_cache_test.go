@@ -0,0 +1,88 @@
+package timecache
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBoundedCacheEvictsOldest(t *testing.T) {
+	tc := NewBoundedTimeCache(Strategy_FirstSeen, time.Minute, 3)
+
+	for i := 0; i < 5; i++ {
+		if !tc.Add(fmt.Sprint(i)) {
+			t.Fatalf("expected key %d to be added", i)
+		}
+	}
+
+	if tc.Len() != 3 {
+		t.Fatalf("expected 3 entries, got %d", tc.Len())
+	}
+	for i := 0; i < 2; i++ {
+		if tc.Has(fmt.Sprint(i)) {
+			t.Fatalf("expected key %d to be evicted", i)
+		}
+	}
+	for i := 2; i < 5; i++ {
+		if !tc.Has(fmt.Sprint(i)) {
+			t.Fatalf("expected key %d to be present", i)
+		}
+	}
+	if tc.Add("4") {
+		t.Fatal("expected duplicate add to return false")
+	}
+}
+
+func TestBoundedCacheFirstSeenFIFO(t *testing.T) {
+	tc := NewBoundedTimeCache(Strategy_FirstSeen, time.Minute, 2)
+	tc.Add("a")
+	tc.Add("b")
+
+	// FirstSeen 下访问不改变淘汰顺序
+	if !tc.Has("a") {
+		t.Fatal("should have a")
+	}
+
+	tc.Add("c")
+	if tc.Has("a") {
+		t.Fatal("expected a to be evicted as first inserted")
+	}
+	if !tc.Has("b") || !tc.Has("c") {
+		t.Fatal("expected b and c to be present")
+	}
+}
+
+func TestBoundedCacheExpire(t *testing.T) {
+	tc := NewBoundedTimeCache(Strategy_FirstSeen, 100*time.Millisecond, 10)
+	tc.Add("test")
+
+	time.Sleep(200 * time.Millisecond)
+	if tc.Has("test") {
+		t.Fatal("should have expired")
+	}
+	if !tc.Add("test") {
+		t.Fatal("expected expired key to be added again")
+	}
+}
+
+func TestBoundedCacheLastSeen(t *testing.T) {
+	tc := NewBoundedTimeCache(Strategy_LastSeen, 300*time.Millisecond, 2)
+	tc.Add("a")
+	tc.Add("b")
+
+	// 访问 a 使其成为最近使用的条目，并延长其过期时间
+	time.Sleep(200 * time.Millisecond)
+	if !tc.Has("a") {
+		t.Fatal("should have a")
+	}
+
+	tc.Add("c")
+	if tc.Has("b") {
+		t.Fatal("expected b to be evicted as least recently used")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if !tc.Has("a") {
+		t.Fatal("expected a to be kept alive by last seen strategy")
+	}
+}
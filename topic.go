@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/dep2p/pubsub/pb"
@@ -36,8 +37,42 @@ type Topic struct {
 	evtHandlerMux sync.RWMutex                    // 事件处理程序的读写锁
 	evtHandlers   map[*TopicEventHandler]struct{} // 事件处理程序的集合
 
-	mux    sync.RWMutex // 主题的读写锁
-	closed bool         // 主题是否已关闭
+	mux      sync.RWMutex // 主题的读写锁
+	closed   bool         // 主题是否已关闭
+	draining bool         // 主题是否正在排空
+
+	closing    atomic.Bool   // 主题是否正在以排空模式关闭
+	publishing atomic.Int32  // 正在进行的本地发布数
+	maxMsgSize atomic.Int64  // 主题的最大消息大小，为 0 时使用全局限制
+	seq        atomic.Uint64 // 主题内的发布序列号计数器
+
+	patternJoined atomic.Bool // 主题是否由模式订阅自动加入，模式订阅取消时关闭
+
+	pubLimit atomic.Pointer[publishLimiter] // 本地发布速率限制，为 nil 时不限制
+	pubKey   atomic.Pointer[topicKey]       // 主题发布密钥，为 nil 时使用主机身份发布
+
+	ns *Namespace // 主题所属的命名空间，为 nil 时不属于任何命名空间
+
+	noDiscovery bool   // 是否不通过发现服务广告和查找主题
+	discoveryNS string // 发现服务中使用的命名空间，为空时使用主题名称
+
+	dandelion *dandelionParams // Dandelion 参数，为 nil 时不启用
+
+	deliverToSelf bool // 在该主题上发布的消息默认是否投递给本节点的订阅者
+}
+
+// discoveryNamespace 返回主题在发现服务中使用的命名空间
+// 返回值:
+// - string: 命名空间
+// - bool: 主题是否启用了发现
+func (t *Topic) discoveryNamespace() (string, bool) {
+	if t.noDiscovery {
+		return "", false
+	}
+	if t.discoveryNS != "" {
+		return t.discoveryNS, true
+	}
+	return t.topic, true
 }
 
 // String 返回与 t 关联的主题。
@@ -113,6 +148,7 @@ func (t *Topic) EventHandler(opts ...TopicEventHandlerOpt) (*TopicEventHandler,
 		err:      nil,                         // 初始化错误为空
 		evtLog:   make(map[peer.ID]EventType), // 初始化事件日志
 		evtLogCh: make(chan struct{}, 1),      // 创建事件日志通道，带缓冲
+		eventsCh: make(chan struct{}, 1),      // 创建生命周期事件通道，带缓冲
 	}
 
 	for _, opt := range opts { // 遍历所有事件处理程序选项并应用
@@ -154,8 +190,15 @@ func (t *Topic) sendNotification(evt PeerEvent) {
 	t.evtHandlerMux.RLock()         // 加读锁，确保并发安全
 	defer t.evtHandlerMux.RUnlock() // 在函数返回前解锁
 
+	// 同时作为生命周期事件投递
+	lifecycle := TopicEvent{Type: PeerJoined, Peer: evt.Peer, Time: time.Now()}
+	if evt.Type == PeerLeave {
+		lifecycle.Type = PeerLeft
+	}
+
 	for h := range t.evtHandlers { // 遍历所有事件处理程序
 		h.sendNotification(evt) // 向每个事件处理程序发送通知
+		h.pushEvent(lifecycle)
 	}
 }
 
@@ -173,6 +216,9 @@ func (t *Topic) Subscribe(opts ...SubOpt) (*Subscription, error) {
 		logger.Warnf("主题已关闭")      // 如果主题已关闭，返回错误
 		return nil, ErrTopicClosed // 如果主题已关闭，返回错误
 	}
+	if t.p.isPublisherOnly(t.topic) {
+		return nil, ErrPublisherOnly // 仅发布的主题不能订阅
+	}
 
 	sub := &Subscription{
 		topic: t.topic, // 设置订阅的主题
@@ -194,7 +240,9 @@ func (t *Topic) Subscribe(opts ...SubOpt) (*Subscription, error) {
 
 	out := make(chan *Subscription, 1) // 创建一个输出通道，用于接收创建的订阅
 
-	t.p.disc.Discover(sub.topic) // 通过发现机制发现订阅的主题
+	if ns, ok := t.discoveryNamespace(); ok {
+		t.p.disc.Discover(sub.topic, ns) // 通过发现机制发现订阅的主题
+	}
 
 	select {
 	case t.p.addSub <- &addSubReq{
@@ -206,7 +254,12 @@ func (t *Topic) Subscribe(opts ...SubOpt) (*Subscription, error) {
 		return nil, t.p.ctx.Err()
 	}
 
-	return <-out, nil // 返回创建的订阅
+	sub = <-out        // 获取创建的订阅
+	sub.startReorder() // 如果需要有序投递，则启动重排协程
+	if sub.catchUp {   // 如果需要追赶补发，则在后台等待主题成员后请求
+		go t.catchUpOnJoin()
+	}
+	return sub, nil // 返回创建的订阅
 }
 
 // Relay 启用主题的消息中继并返回引用取消函数。
@@ -222,10 +275,15 @@ func (t *Topic) Relay() (RelayCancelFunc, error) {
 		logger.Warnf("主题已关闭")      // 如果主题已关闭，返回错误
 		return nil, ErrTopicClosed // 如果主题已关闭，返回错误
 	}
+	if t.p.isPublisherOnly(t.topic) {
+		return nil, ErrPublisherOnly // 仅发布的主题不能中继
+	}
 
 	out := make(chan RelayCancelFunc, 1) // 创建一个输出通道，用于接收取消中继函数
 
-	t.p.disc.Discover(t.topic) // 通过发现机制发现主题
+	if ns, ok := t.discoveryNamespace(); ok {
+		t.p.disc.Discover(t.topic, ns) // 通过发现机制发现主题
+	}
 
 	select {
 	case t.p.addRelay <- &addRelayReq{
@@ -249,16 +307,24 @@ type ProvideKey func() (crypto.PrivKey, peer.ID)
 // PublishOptions 表示发布选项。
 type PublishOptions struct {
 	ready     RouterReady        // 准备好路由的回调函数
+	waitReady bool               // 是否必须等到路由器准备好才发布
 	customKey ProvideKey         // 自定义密钥的提供函数
 	local     bool               // 是否为本地发布
+	loopback  *bool              // 是否投递给本节点的订阅者，为 nil 时使用主题的设置
 	targetMap []peer.ID          // 目标节点列表
 	metadata  MessageMetadataOpt // 消息元信息
+	token     string             // 幂等令牌
+	annos     []*pb.Annotation   // 随消息转发的注解
+	headers   map[string]string  // 参与签名的消息头部
+	timestamp bool               // 是否在元信息中写入发布时间
+	shard     erasureShard       // 纠删码分片在首跳对等节点中的位置
 }
 
 // MessageMetadataOpt 表示消息元信息的选项。
 type MessageMetadataOpt struct {
-	messageID string                         // 消息ID
-	msgType   pb.MessageMetadata_MessageType // 消息类型（请求或响应）
+	messageID  string                         // 消息ID
+	msgType    pb.MessageMetadata_MessageType // 消息类型（请求或响应）
+	replyTopic string                         // 请求的回复主题
 }
 
 // PubOpt 定义发布选项的类型。
@@ -367,23 +433,21 @@ func (t *Topic) PublishWithReply(ctx context.Context, data []byte, targetNodes .
 
 	// 生成消息ID和回复通道
 	msgID := uuid.New().String()
-	replyChan := make(chan []byte, 1)
+	replyChan := make(chan *Message, 1)
 	logger.Infof("生成消息ID: %s", msgID)
 
 	// 注册回复通道并确保清理
-	t.mux.Lock()
-	if t.closed {
-		t.mux.Unlock()
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
 		logger.Error("主题已关闭")
 		return nil, ErrTopicClosed
 	}
-	t.p.replies[msgID] = replyChan
-	t.mux.Unlock()
+	t.p.registerReply(msgID, replyChan)
 
 	defer func() {
-		t.mux.Lock()
-		delete(t.p.replies, msgID)
-		t.mux.Unlock()
+		t.p.unregisterReply(msgID)
 		logger.Infof("清理消息ID: %s 的回复通道", msgID)
 	}()
 
@@ -472,7 +536,8 @@ func (t *Topic) PublishWithReply(ctx context.Context, data []byte, targetNodes .
 	// 等待响应
 	logger.Info("等待响应...")
 	select {
-	case reply := <-replyChan:
+	case msg := <-replyChan:
+		reply := msg.Data
 		if len(reply) == 0 {
 			logger.Error("收到空响应")
 			return nil, fmt.Errorf("收到空响应")
@@ -500,14 +565,44 @@ func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error
 		return ErrTopicClosed // 如果主题已关闭，返回错误
 	}
 
+	// 先计入正在进行的发布再检查排空状态，使排空关闭不会错过这次发布
+	t.publishing.Add(1)
+	defer t.publishing.Add(-1)
+	if t.draining || t.closing.Load() {
+		return ErrTopicDraining // 如果主题正在排空，返回错误
+	}
+
+	// 检查本地发布速率限制
+	if !t.allowPublish(time.Now()) {
+		logger.Debugf("主题 %s 超出发布速率限制", t.topic)
+		return ErrRateLimited
+	}
+	if err := t.ns.allowPublish(len(data), time.Now()); err != nil {
+		logger.Debugf("主题 %s 发布失败: %s", t.topic, err)
+		return err
+	}
+
 	// 确保 data 不为空
 	// TODO:暂时注释，后面再优化
 	// if len(data) == 0 {
 	// 	return fmt.Errorf("消息数据不能为空")
 	// }
 
-	pid := t.p.signID  // 获取发布者的对等节点 ID
-	key := t.p.signKey // 获取发布者的签名密钥
+	// 如果启用了追踪上下文传播，则为发布创建 span
+	ctx, span := t.p.startPublishSpan(ctx, t.topic)
+	defer span.End()
+
+	// 如果主题启用了加密，则加密负载
+	data, err := t.p.encryptPayload(t.topic, data)
+	if err != nil {
+		return err
+	}
+
+	pid := t.p.signID    // 获取发布者的对等节点 ID
+	signer := t.p.signer // 获取发布者的签名者
+	if key := t.pubKey.Load(); key != nil {
+		pid, signer = key.id, key.priv // 使用主题发布密钥代替主机身份
+	}
 
 	pub := &PublishOptions{}   // 初始化发布选项
 	for _, opt := range opts { // 遍历所有发布选项并应用
@@ -529,64 +624,83 @@ func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error
 	}
 
 	if pub.metadata.messageID != "" {
-		// 如果消息是请求或响应类型，则必须设置 messageID
-		if pub.metadata.msgType == pb.MessageMetadata_REQUEST || pub.metadata.msgType == pb.MessageMetadata_RESPONSE {
+		// 如果消息是请求、响应、可靠消息或确认类型，则必须设置 messageID
+		switch pub.metadata.msgType {
+		case pb.MessageMetadata_REQUEST, pb.MessageMetadata_RESPONSE, pb.MessageMetadata_RELIABLE, pb.MessageMetadata_ACK:
 			if pub.metadata.messageID == "" {
 				logger.Warnf("消息类型为请求或响应时，必须设置 messageID")
 				return fmt.Errorf("消息类型为请求或响应时，必须设置 messageID")
 			}
 			m.Metadata = &pb.MessageMetadata{
-				MessageID: pub.metadata.messageID,
-				Type:      pb.MessageMetadata_MessageType(pub.metadata.msgType),
+				MessageID:  pub.metadata.messageID,
+				Type:       pb.MessageMetadata_MessageType(pub.metadata.msgType),
+				ReplyTopic: pub.metadata.replyTopic,
 			}
 		}
 	}
 
+	// 如果设置了幂等令牌，则将其写入消息元信息
+	if pub.token != "" {
+		if m.Metadata == nil {
+			m.Metadata = &pb.MessageMetadata{}
+		}
+		m.Metadata.IdempotencyToken = pub.token
+	}
+	if pub.timestamp {
+		stampTimestamp(m, time.Now()) // 发布时间参与签名
+	}
+	m.Annotations = pub.annos // 随消息转发的注解
+
+	// 头部参与签名，因此需在签名前写入并检查大小
+	if len(pub.headers) > 0 {
+		if err := t.p.checkHeaders(pub.headers); err != nil {
+			logger.Warnf("消息头部无效: %s", err)
+			return err
+		}
+		m.Headers = pub.headers
+	}
+
+	// 注入追踪上下文，元信息参与签名，因此需在签名前完成
+	t.p.injectTraceContext(ctx, m)
+
+	// 跳数和存活时间限制参与签名，因此需在签名前写入
+	t.p.stampTTL(m, time.Now())
+
 	if pid != "" { // 如果存在对等节点 ID
 		m.From = []byte(pid)      // 设置发送者的对等节点 ID
 		m.Seqno = t.p.nextSeqno() // 获取并设置消息序列号
+		if !t.p.anonymous {
+			m.TopicSeqno = t.seq.Add(1) // 主题内的序列号，有序投递依赖其逐条递增
+		}
 	}
-	if key != nil { // 如果存在签名密钥
-		m.From = []byte(pid)            // 再次设置发送者的对等节点 ID（确保存在）
-		err := signMessage(pid, key, m) // 签署消息
+	if t.p.anonymous { // 匿名模式下使用随机序列号，避免通过消息 ID 关联发布者
+		m.Seqno = randomSeqno()
+	}
+	if signer != nil { // 如果存在签名者
+		m.From = []byte(pid)               // 再次设置发送者的对等节点 ID（确保存在）
+		err := signMessage(pid, signer, m) // 签署消息
 		if err != nil {
 			logger.Warnf("签署消息失败: %s", err) // 如果签署消息出错，返回错误
 			return err                      // 如果签署消息出错，返回错误
 		}
 	}
 
+	// 检查主题的最大消息大小
+	if err := t.checkMessageSize(m); err != nil {
+		logger.Warnf("发布消息失败: %s", err)
+		return err
+	}
+
 	// 如果设置了 ready 回调函数，则处理准备操作
 	if pub.ready != nil {
-		if t.p.disc.discovery != nil { // 如果启用了发现机制
-			t.p.disc.Bootstrap(ctx, t.topic, pub.ready) // 引导发现机制
-		} else {
-			var ticker *time.Ticker
-		readyLoop:
-			for { // 循环检查路由器是否准备好发布消息
-				res := make(chan bool, 1) // 创建结果通道
-				select {
-				case t.p.eval <- func() { // 发送评估函数到评估通道
-					done, _ := pub.ready(t.p.rt, t.topic)
-					res <- done // 将评估结果发送到结果通道
-				}:
-					if <-res { // 如果准备好了
-						break readyLoop // 跳出循环
-					}
-				case <-t.p.ctx.Done(): // 如果全局上下文完成
-					return t.p.ctx.Err() // 返回全局上下文错误
-				case <-ctx.Done(): // 如果操作上下文完成
-					return ctx.Err() // 返回操作上下文错误
-				}
-				if ticker == nil { // 如果计时器尚未初始化
-					ticker = time.NewTicker(200 * time.Millisecond) // 每200毫秒检查一次
-					defer ticker.Stop()                             // 确保在返回前停止计时器
-				}
-
-				select {
-				case <-ticker.C: // 每200毫秒触发一次检查
-				case <-ctx.Done(): // 如果操作上下文完成
-					return fmt.Errorf("路由器未准备好: %w", ctx.Err()) // 返回上下文错误
-				}
+		bootstrapped := false
+		if ns, ok := t.discoveryNamespace(); ok && t.p.disc.discovery != nil { // 如果启用了发现机制
+			t.p.disc.Bootstrap(ctx, t.topic, ns, pub.ready) // 引导发现机制
+			bootstrapped = true
+		}
+		if !bootstrapped || pub.waitReady { // 没有发现机制或要求严格等待时轮询路由器
+			if err := t.waitReady(ctx, pub.ready, pub.waitReady); err != nil {
+				return err
 			}
 		}
 	}
@@ -600,11 +714,13 @@ func (t *Topic) Publish(ctx context.Context, data []byte, opts ...PubOpt) error
 	// 推送本地消息到验证模块
 	return t.p.val.PushLocal(
 		&Message{
-			m,             // 消息内容
-			"",            // 主题，当前为空
-			t.p.host.ID(), // 发送者的对等节点 ID
-			nil,           // 序列号，当前为空
-			pub.local,     // 是否为本地发布
+			Message:      m,               // 消息内容
+			ReceivedFrom: t.p.host.ID(),   // 本地发布的接收者为本节点
+			Local:        pub.local,       // 是否为本地发布
+			loopback:     t.loopback(pub), // 是否投递给本节点的订阅者
+			receivedAt:   time.Now(),      // 发布时间
+			annotations:  new(messageAnnotations),
+			shard:        pub.shard, // 纠删码分片只发给一部分首跳对等节点
 		})
 }
 
@@ -688,15 +804,65 @@ func WithTargetMap(targets []peer.ID) PubOpt {
 func WithMessageMetadata(messageID string, msgType pb.MessageMetadata_MessageType) PubOpt {
 	return func(pub *PublishOptions) error {
 		pub.metadata = MessageMetadataOpt{
-			messageID: messageID,
-			msgType:   msgType,
+			messageID:  messageID,
+			msgType:    msgType,
+			replyTopic: pub.metadata.replyTopic,
 		} // 设置消息元信息
 		return nil // 返回 nil 表示没有错误
 	}
 }
 
+// WithReplyTopic 设置请求消息的回复主题，响应者将响应发布到该主题。
+// 需要与 WithMessageMetadata 一起使用。
+// 参数:
+// - topic: string 类型，表示回复主题。
+// 返回值:
+// - PubOpt: 返回一个发布选项函数，用于设置 PublishOptions 中的回复主题。
+func WithReplyTopic(topic string) PubOpt {
+	return func(pub *PublishOptions) error {
+		pub.metadata.replyTopic = topic // 设置回复主题
+		return nil                      // 返回 nil 表示没有错误
+	}
+}
+
+// WithIdempotencyToken 设置消息的幂等令牌。
+// 令牌随消息元信息一起传播，订阅者可以通过 Message.IdempotencyToken 获取，
+// 并结合 TokenJournal 实现恰好一次处理。
+// 参数:
+// - token: string 类型，表示幂等令牌，不能为空。
+// 返回值:
+// - PubOpt: 返回一个发布选项函数，用于设置 PublishOptions 中的幂等令牌。
+func WithIdempotencyToken(token string) PubOpt {
+	return func(pub *PublishOptions) error {
+		if token == "" {
+			logger.Warnf("幂等令牌不能为空")
+			return fmt.Errorf("幂等令牌不能为空")
+		}
+		pub.token = token // 设置幂等令牌
+		return nil        // 返回 nil 表示没有错误
+	}
+}
+
+// WithForwardAnnotation 为消息附加一个随消息转发的注解。
+// 注解不参与签名，接收方应将其视为未认证的信息。
+// 参数:
+// - key: string 类型，表示注解的键，不能为空。
+// - value: []byte 类型，表示注解的值。
+// 返回值:
+// - PubOpt: 返回一个发布选项函数，用于设置 PublishOptions 中的转发注解。
+func WithForwardAnnotation(key string, value []byte) PubOpt {
+	return func(pub *PublishOptions) error {
+		if key == "" {
+			logger.Warnf("注解的键不能为空")
+			return fmt.Errorf("注解的键不能为空")
+		}
+		pub.annos = append(pub.annos, &pb.Annotation{Key: key, Value: value})
+		return nil
+	}
+}
+
 // Close 关闭主题。返回错误，除非没有活动的事件处理程序或订阅。
-// 如果主题已经关闭，则不会返回错误。
+// 如果主题已经关闭，则不会返回错误。需要先排空主题时使用 CloseDrain。
 // 返回值:
 // - error: 错误信息，如果有的话。
 func (t *Topic) Close() error {
@@ -717,7 +883,9 @@ func (t *Topic) Close() error {
 	err := <-req.resp // 从响应通道接收错误信息
 
 	if err == nil {
-		t.closed = true // 如果没有错误，标记主题为已关闭
+		t.closed = true                       // 如果没有错误，标记主题为已关闭
+		t.p.setSnapshotProvider(t.topic, nil) // 移除主题的快照提供者
+		t.p.setKeyProvider(t.topic, nil)      // 移除主题的密钥提供者
 	}
 
 	return err // 返回错误信息
@@ -752,6 +920,11 @@ type TopicEventHandler struct {
 	evtLogMx sync.Mutex            // 事件日志的互斥锁
 	evtLog   map[peer.ID]EventType // 事件日志
 	evtLogCh chan struct{}         // 事件日志的信号通道
+
+	eventsMx sync.Mutex    // 生命周期事件队列的互斥锁
+	events   []TopicEvent  // 尚未读取的生命周期事件
+	dropped  uint64        // 因队列已满丢弃的生命周期事件数
+	eventsCh chan struct{} // 生命周期事件队列的信号通道
 }
 
 // TopicEventHandlerOpt 定义了一个用于设置 TopicEventHandler 选项的函数类型。
@@ -0,0 +1,169 @@
+// 作用：主题级准入控制。
+// 功能：为私有主题注册授权函数，在收到远端对等节点的 SUBSCRIBE 和 GRAFT 时检查，
+// 未授权的订阅被忽略、GRAFT 被 PRUNE，并可选地计入行为惩罚；相比消息验证器，不必先接收再丢弃消息。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// TopicAuthorizer 判断对等节点是否有权加入主题
+// 参数:
+//   - pid: 对等节点 ID
+//
+// 返回值:
+//   - bool: 是否授权
+type TopicAuthorizer func(pid peer.ID) bool
+
+// WithTopicAuth 是一个选项，用于在创建时为主题注册授权函数，使得在加入主题之前连接的对等节点也受准入控制。
+// 参数:
+//   - topic: 主题
+//   - auth: 授权函数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTopicAuth(topic string, auth TopicAuthorizer) Option {
+	return func(p *PubSub) error {
+		if topic == "" {
+			logger.Warnf("主题不能为空")
+			return fmt.Errorf("主题不能为空")
+		}
+		if auth == nil {
+			logger.Warnf("主题授权函数不能为空")
+			return fmt.Errorf("主题授权函数不能为空")
+		}
+		p.topicAuth[topic] = auth
+		return nil
+	}
+}
+
+// WithTopicAuthPenalty 是一个选项，用于设置未授权的 SUBSCRIBE 或 GRAFT 计入的行为惩罚次数（gossipsub），默认不惩罚。
+// 参数:
+//   - penalty: 行为惩罚次数
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTopicAuthPenalty(penalty int) Option {
+	return func(p *PubSub) error {
+		if penalty < 0 {
+			logger.Warnf("无效的主题授权惩罚: %d", penalty)
+			return fmt.Errorf("无效的主题授权惩罚: %d", penalty)
+		}
+		p.topicAuthPenalty = penalty
+		return nil
+	}
+}
+
+// SetAuthorizer 为主题设置授权函数；传入 nil 则取消准入控制。
+// 授权函数在事件循环中调用，不能阻塞。设置后立即撤销已订阅但未授权的对等节点。
+// 参数:
+//   - auth: 授权函数
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetAuthorizer(auth TopicAuthorizer) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	done := make(chan struct{})
+	select {
+	case t.p.eval <- func() {
+		defer close(done)
+		t.p.setTopicAuth(t.topic, auth)
+	}:
+		<-done
+		return nil
+	case <-t.p.ctx.Done():
+		return t.p.ctx.Err()
+	}
+}
+
+// setTopicAuth 设置主题的授权函数，并撤销未授权的对等节点。只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+//   - auth: 授权函数，为 nil 时移除
+func (p *PubSub) setTopicAuth(topic string, auth TopicAuthorizer) {
+	if auth == nil {
+		delete(p.topicAuth, topic)
+		return
+	}
+	p.topicAuth[topic] = auth
+
+	gs, _ := p.rt.(*GossipSubRouter)
+	for pid := range p.topics[topic] {
+		if auth(pid) {
+			continue
+		}
+
+		logger.Debugf("撤销未授权 peer %s 的主题 %s 订阅", pid, topic)
+		delete(p.topics[topic], pid)
+		p.peerSubs[pid]--
+		if p.peerSubs[pid] <= 0 {
+			delete(p.peerSubs, pid)
+		}
+		p.notifyLeave(topic, pid)
+
+		if gs != nil {
+			gs.revokePeer(pid, topic)
+		}
+	}
+}
+
+// authorized 检查对等节点是否有权加入主题，未注册授权函数的主题对所有对等节点开放。只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+//   - pid: 对等节点 ID
+//
+// 返回值:
+//   - bool: 是否授权
+func (p *PubSub) authorized(topic string, pid peer.ID) bool {
+	auth, ok := p.topicAuth[topic]
+	return !ok || auth(pid)
+}
+
+// penalizeUnauthorized 对未授权的 SUBSCRIBE 或 GRAFT 计入行为惩罚（gossipsub）。只从 processLoop 调用。
+// 参数:
+//   - pid: 对等节点 ID
+func (p *PubSub) penalizeUnauthorized(pid peer.ID) {
+	if p.topicAuthPenalty == 0 {
+		return
+	}
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.penalize(pid, p.topicAuthPenalty, PenaltyUnauthorized)
+	}
+}
+
+// revokePeer 将未授权的对等节点移出主题的网格和 fanout，网格对等节点会收到不带 PX 的 PRUNE。
+// 只从 processLoop 调用。
+// 参数:
+//   - p: 对等节点 ID
+//   - topic: 主题
+func (gs *GossipSubRouter) revokePeer(p peer.ID, topic string) {
+	delete(gs.fanout[topic], p)
+
+	peers, ok := gs.mesh[topic]
+	if !ok {
+		return
+	}
+	if _, inMesh := peers[p]; !inMesh {
+		return
+	}
+
+	logger.Debugf("撤销: 从网格中移除未授权的对等节点 %s 到主题 %s", p, topic)
+	gs.tracer.Prune(p, topic)
+	gs.p.notifyTopicEvent(topic, MeshPruned, p)
+	delete(peers, p)
+
+	prune := gs.makePrune(p, topic, false, false)
+	out := rpcWithControl(nil, nil, nil, nil, []*pb.ControlPrune{prune})
+	gs.sendRPC(p, out)
+
+	gs.addBackoff(p, topic, false)
+}
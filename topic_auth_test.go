@@ -0,0 +1,103 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestTopicAuthorizer 测试主题授权函数对 SUBSCRIBE 和 GRAFT 的准入控制
+func TestTopicAuthorizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := getGossipsubs(ctx, hosts)
+
+	allowed := hosts[1].ID()
+	topics := make([]*Topic, len(psubs))
+	for i, ps := range psubs {
+		topic, err := ps.Join("private")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics[i] = topic
+	}
+	if err := topics[0].SetAuthorizer(func(pid peer.ID) bool { return pid == allowed }); err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range topics {
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[0], hosts[2])
+
+	// 等待订阅传播和网格建立
+	time.Sleep(2 * time.Second)
+
+	inMesh := func(ps *PubSub, pid peer.ID) bool {
+		gs := ps.rt.(*GossipSubRouter)
+		res := make(chan bool, 1)
+		ps.eval <- func() {
+			_, ok := gs.mesh["private"][pid]
+			res <- ok
+		}
+		return <-res
+	}
+
+	peers := psubs[0].ListPeers("private")
+	if len(peers) != 1 || peers[0] != allowed {
+		t.Fatalf("expected only the authorized peer to be subscribed, got %v", peers)
+	}
+	if !inMesh(psubs[0], allowed) {
+		t.Fatal("expected authorized peer to be in the mesh")
+	}
+	if inMesh(psubs[2], hosts[0].ID()) {
+		t.Fatal("expected GRAFT from unauthorized peer to be pruned")
+	}
+
+	// 收紧授权后撤销已订阅的对等节点
+	if err := topics[0].SetAuthorizer(func(peer.ID) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	if peers := psubs[0].ListPeers("private"); len(peers) != 0 {
+		t.Fatalf("expected subscriptions to be revoked, got %v", peers)
+	}
+	if inMesh(psubs[0], allowed) {
+		t.Fatal("expected revoked peer to be removed from the mesh")
+	}
+
+	// 取消准入控制
+	if err := topics[0].SetAuthorizer(nil); err != nil {
+		t.Fatal(err)
+	}
+	res := make(chan bool, 1)
+	psubs[0].eval <- func() { res <- psubs[0].authorized("private", hosts[2].ID()) }
+	if !<-res {
+		t.Fatal("expected all peers to be authorized without an authorizer")
+	}
+}
+
+// TestTopicAuthOptions 测试主题准入控制选项的参数验证
+func TestTopicAuthOptions(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 3)
+	if _, err := NewGossipSub(ctx, hosts[0], WithTopicAuth("private", nil)); err == nil {
+		t.Error("expected error for nil authorizer")
+	}
+	if _, err := NewGossipSub(ctx, hosts[1], WithTopicAuthPenalty(-1)); err == nil {
+		t.Error("expected error for negative penalty")
+	}
+
+	ps := getGossipsub(ctx, hosts[2], WithTopicAuth("private", func(peer.ID) bool { return false }), WithTopicAuthPenalty(2))
+	if ps.topicAuth["private"] == nil || ps.topicAuthPenalty != 2 {
+		t.Fatal("expected topic authorizer and penalty to be configured")
+	}
+}
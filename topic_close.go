@@ -0,0 +1,122 @@
+// 作用：排空模式关闭主题。
+// 功能：关闭主题前停止接受本地发布，等待正在进行的发布和验证完成，
+// 把已排队的出站消息发送出去，然后取消订阅并发送取消订阅公告，避免关闭主题时静默丢弃排队中的消息。
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// closeDrainPollInterval 是排空关闭时检查发布、验证和出站队列状态的间隔
+const closeDrainPollInterval = 10 * time.Millisecond
+
+// CloseDrain 以排空模式关闭主题：立即停止接受本地发布，等待正在进行的发布和该主题正在验证的消息完成，
+// 等待该主题对等节点的出站队列清空，然后取消主题的所有订阅和中继并发送取消订阅公告，最后关闭主题。
+// 等待受上下文约束；上下文结束时跳过剩余的等待，主题仍然被关闭，并返回上下文的错误。
+// 参数:
+//   - ctx: 限定排空时间的上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) CloseDrain(ctx context.Context) error {
+	if ctx == nil {
+		logger.Warnf("排空上下文不能为空")
+		return fmt.Errorf("排空上下文不能为空")
+	}
+
+	t.mux.RLock()
+	closed := t.closed
+	t.mux.RUnlock()
+	if closed {
+		return nil
+	}
+
+	t.closing.Store(true)
+
+	// 等待正在进行的本地发布和正在验证的消息，然后把它们发送出去
+	drainErr := t.p.waitUntil(ctx, func() bool {
+		return t.publishing.Load() == 0 && t.p.val.pending(t.topic) == 0
+	})
+	if drainErr == nil {
+		drainErr = t.p.flushOutbound(ctx, t.topic)
+	}
+
+	// 取消订阅和中继会宣布离开主题，再等待公告发送给该主题的对等节点
+	if err := t.p.leaveTopic(t.topic); err != nil {
+		return err
+	}
+	if drainErr == nil {
+		drainErr = t.p.flushOutbound(ctx, t.topic)
+	}
+
+	if err := t.Close(); err != nil {
+		t.closing.Store(false) // 主题仍然打开，恢复接受发布
+		return err
+	}
+	if drainErr != nil {
+		logger.Warnf("主题 %s 排空未完成即关闭: %s", t.topic, drainErr)
+	}
+	return drainErr
+}
+
+// flushOutbound 等待已验证的消息被事件循环处理，并等待对等节点的出站队列清空
+// 参数:
+//   - ctx: 限定等待时间的上下文
+//   - topic: 只等待订阅该主题的对等节点，为空时等待所有对等节点
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (p *PubSub) flushOutbound(ctx context.Context, topic string) error {
+	return p.waitUntil(ctx, func() bool {
+		idle := make(chan bool, 1)
+		select {
+		case p.eval <- func() {
+			if len(p.sendMsg) > 0 {
+				idle <- false
+				return
+			}
+			for pid, q := range p.peers {
+				if topic != "" {
+					if _, ok := p.topics[topic][pid]; !ok {
+						continue
+					}
+				}
+				if len(q) > 0 {
+					idle <- false
+					return
+				}
+			}
+			idle <- true
+		}:
+			return <-idle
+		case <-p.ctx.Done():
+			return true
+		}
+	})
+}
+
+// waitUntil 周期性地检查条件，直到条件满足或上下文结束
+// 参数:
+//   - ctx: 限定等待时间的上下文
+//   - cond: 检查的条件
+//
+// 返回值:
+//   - error: 上下文结束时返回上下文的错误
+func (p *PubSub) waitUntil(ctx context.Context, cond func() bool) error {
+	ticker := time.NewTicker(closeDrainPollInterval)
+	defer ticker.Stop()
+
+	for !cond() {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+	}
+	return nil
+}
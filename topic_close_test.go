@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestTopicCloseDrain 测试排空模式关闭主题时等待正在进行的发布完成并发送出去
+func TestTopicCloseDrain(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 本地发布的验证是同步的，慢验证器使发布在关闭时仍在进行中
+	validating := make(chan struct{})
+	err = psubs[0].RegisterTopicValidator("foobar", func(context.Context, peer.ID, *Message) bool {
+		close(validating)
+		time.Sleep(300 * time.Millisecond)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	connectAll(t, hosts)
+	time.Sleep(time.Second)
+
+	published := make(chan error, 1)
+	go func() {
+		published <- topics[0].Publish(ctx, []byte("last words"))
+	}()
+	<-validating
+
+	if err := topics[0].CloseDrain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-published; err != nil {
+		t.Fatalf("expected the in-flight publish to succeed, got %s", err)
+	}
+	if err := topics[0].Publish(ctx, []byte("too late")); err != ErrTopicClosed {
+		t.Fatalf("expected ErrTopicClosed after close, got %v", err)
+	}
+
+	// 本地订阅随主题关闭被取消
+	if _, err := local.Next(ctx); err != ErrSubscriptionCancelled {
+		t.Fatalf("expected ErrSubscriptionCancelled after close, got %v", err)
+	}
+
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer tcancel()
+	msg, err := sub.Next(tctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "last words" {
+		t.Fatalf("unexpected message %q", msg.Data)
+	}
+}
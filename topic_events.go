@@ -0,0 +1,147 @@
+// 作用：主题生命周期事件。
+// 功能：通过主题事件处理程序按发生顺序投递类型化的生命周期事件，包括对等节点加入和离开、
+// 网格 GRAFT 和 PRUNE，以及本节点开始和停止订阅，使应用无需轮询 ListPeers 即可响应网格成员变化。
+
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TopicEventQueueSize 是每个事件处理程序缓存的未读生命周期事件数，队列满时丢弃最旧的事件
+const TopicEventQueueSize = 256
+
+// TopicEventType 表示主题生命周期事件的类型
+type TopicEventType int
+
+const (
+	// PeerJoined 表示对等节点订阅了主题
+	PeerJoined TopicEventType = iota + 1
+	// PeerLeft 表示对等节点取消订阅了主题或断开了连接
+	PeerLeft
+	// MeshGrafted 表示对等节点被加入主题的网格
+	MeshGrafted
+	// MeshPruned 表示对等节点被移出主题的网格
+	MeshPruned
+	// LocalSubscribed 表示本节点开始订阅主题，即创建了第一个本地订阅
+	LocalSubscribed
+	// LocalUnsubscribed 表示本节点停止订阅主题，即最后一个本地订阅被取消
+	LocalUnsubscribed
+)
+
+// String 返回事件类型的名称
+// 返回值:
+//   - string: 事件类型的名称
+func (t TopicEventType) String() string {
+	switch t {
+	case PeerJoined:
+		return "PeerJoined"
+	case PeerLeft:
+		return "PeerLeft"
+	case MeshGrafted:
+		return "MeshGrafted"
+	case MeshPruned:
+		return "MeshPruned"
+	case LocalSubscribed:
+		return "LocalSubscribed"
+	case LocalUnsubscribed:
+		return "LocalUnsubscribed"
+	default:
+		return "Unknown"
+	}
+}
+
+// TopicEvent 是主题生命周期事件
+type TopicEvent struct {
+	Type TopicEventType // 事件类型
+	Peer peer.ID        // 相关的对等节点，本地订阅事件为本节点
+	Time time.Time      // 事件发生的时间
+}
+
+// NextEvent 返回下一个生命周期事件，事件按发生顺序投递。
+// 与 NextPeerEvent 相互独立，两者可以同时使用。
+// 参数:
+//   - ctx: 上下文，用于控制操作
+//
+// 返回值:
+//   - TopicEvent: 下一个生命周期事件
+//   - error: 错误信息，如果有的话
+func (t *TopicEventHandler) NextEvent(ctx context.Context) (TopicEvent, error) {
+	for {
+		t.eventsMx.Lock()
+		if len(t.events) > 0 {
+			evt := t.events[0]
+			t.events[0] = TopicEvent{}
+			t.events = t.events[1:]
+			if len(t.events) > 0 {
+				select {
+				case t.eventsCh <- struct{}{}:
+				default:
+				}
+			}
+			t.eventsMx.Unlock()
+			return evt, nil
+		}
+		t.eventsMx.Unlock()
+
+		select {
+		case <-t.eventsCh:
+		case <-ctx.Done():
+			return TopicEvent{}, ctx.Err()
+		}
+	}
+}
+
+// DroppedEvents 返回因未及时读取而丢弃的生命周期事件数
+// 返回值:
+//   - uint64: 丢弃的事件数
+func (t *TopicEventHandler) DroppedEvents() uint64 {
+	t.eventsMx.Lock()
+	defer t.eventsMx.Unlock()
+	return t.dropped
+}
+
+// pushEvent 将生命周期事件加入队列，队列满时丢弃最旧的事件
+// 参数:
+//   - evt: 生命周期事件
+func (t *TopicEventHandler) pushEvent(evt TopicEvent) {
+	t.eventsMx.Lock()
+	if len(t.events) >= TopicEventQueueSize {
+		t.events = t.events[1:]
+		t.dropped++
+	}
+	t.events = append(t.events, evt)
+	t.eventsMx.Unlock()
+
+	select {
+	case t.eventsCh <- struct{}{}:
+	default:
+	}
+}
+
+// sendEvent 向主题的所有事件处理程序发送生命周期事件
+// 参数:
+//   - evt: 生命周期事件
+func (t *Topic) sendEvent(evt TopicEvent) {
+	t.evtHandlerMux.RLock()
+	defer t.evtHandlerMux.RUnlock()
+
+	for h := range t.evtHandlers {
+		h.pushEvent(evt)
+	}
+}
+
+// notifyTopicEvent 如果本节点加入了主题，则向其事件处理程序发送生命周期事件。
+// 只从 processLoop 调用。
+// 参数:
+//   - topic: 主题
+//   - typ: 事件类型
+//   - pid: 相关的对等节点
+func (p *PubSub) notifyTopicEvent(topic string, typ TopicEventType, pid peer.ID) {
+	if t, ok := p.myTopics[topic]; ok {
+		t.sendEvent(TopicEvent{Type: typ, Peer: pid, Time: time.Now()})
+	}
+}
@@ -0,0 +1,108 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// nextTopicEvent 读取下一个生命周期事件并检查其类型和对等节点
+func nextTopicEvent(ctx context.Context, t *testing.T, h *TopicEventHandler, typ TopicEventType, pid string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	for {
+		evt, err := h.NextEvent(ctx)
+		if err != nil {
+			t.Fatalf("waiting for %s: %s", typ, err)
+		}
+		if evt.Type == typ {
+			if string(evt.Peer) != pid {
+				t.Fatalf("expected %s for peer %s, got %s", typ, pid, evt.Peer)
+			}
+			return
+		}
+	}
+}
+
+// TestTopicMeshPrunedOnDisconnect 测试断开连接时从网格中移除的对等节点产生 MeshPruned 事件
+func TestTopicMeshPrunedOnDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	topic, err := psubs[0].Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := topic.EventHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topic.Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := psubs[1].Subscribe("foobar"); err != nil {
+		t.Fatal(err)
+	}
+	remote := string(hosts[1].ID())
+
+	connect(t, hosts[0], hosts[1])
+	nextTopicEvent(ctx, t, h, MeshGrafted, remote)
+
+	if err := hosts[0].Network().ClosePeer(hosts[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	nextTopicEvent(ctx, t, h, MeshPruned, remote)
+}
+
+// TestTopicLifecycleEvents 测试主题事件处理程序投递本地订阅、对等节点加入离开和网格变化事件
+func TestTopicLifecycleEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foobar")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+
+	h, err := topics[0].EventHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, remote := string(hosts[0].ID()), string(hosts[1].ID())
+
+	sub, err := topics[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextTopicEvent(ctx, t, h, LocalSubscribed, self)
+
+	remoteSub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	nextTopicEvent(ctx, t, h, PeerJoined, remote)
+	nextTopicEvent(ctx, t, h, MeshGrafted, remote)
+
+	remoteSub.Cancel()
+	nextTopicEvent(ctx, t, h, PeerLeft, remote)
+
+	sub.Cancel()
+	nextTopicEvent(ctx, t, h, LocalUnsubscribed, self)
+
+	if n := h.DroppedEvents(); n != 0 {
+		t.Fatalf("expected no dropped events, got %d", n)
+	}
+}
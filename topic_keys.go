@@ -0,0 +1,167 @@
+// 作用：主题发布密钥和授权发布者。
+// 功能：允许使用主题专用的密钥对代替主机身份发布消息，接收方按配置的授权发布者公钥集合验证签名者，
+// 用于只有少数发布者合法的广播主题。发布者不在集合中或消息未签名时，路由器在验证之前拒绝消息并惩罚转发者。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// topicKey 是主题发布密钥
+type topicKey struct {
+	priv crypto.PrivKey // 私钥
+	id   peer.ID        // 由公钥得出的发布者 ID
+}
+
+// SetPublishKey 设置主题的发布密钥，之后在该主题上发布的消息以该密钥签名，消息的发布者为密钥对应的 ID；
+// 传入 nil 则恢复使用主机身份发布。需要签名策略 StrictSign 或 LaxSign。
+// 参数:
+//   - priv: 主题发布私钥
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetPublishKey(priv crypto.PrivKey) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	if priv == nil {
+		t.pubKey.Store(nil)
+		return nil
+	}
+	if !t.p.signPolicy.mustSign() {
+		logger.Warnf("主题发布密钥需要启用消息签名")
+		return fmt.Errorf("主题发布密钥需要启用消息签名")
+	}
+	id, err := peer.IDFromPrivateKey(priv)
+	if err != nil {
+		logger.Warnf("无法从主题发布密钥得出发布者 ID: %s", err)
+		return fmt.Errorf("无法从主题发布密钥得出发布者 ID: %w", err)
+	}
+
+	t.pubKey.Store(&topicKey{priv: priv, id: id})
+	return nil
+}
+
+// PublisherID 返回在主题上发布消息时使用的发布者 ID
+// 返回值:
+//   - peer.ID: 设置了发布密钥时为密钥对应的 ID，否则为主机的签名 ID
+func (t *Topic) PublisherID() peer.ID {
+	if key := t.pubKey.Load(); key != nil {
+		return key.id
+	}
+	return t.p.signID
+}
+
+// WithTopicPublisherKeys 是一个选项，限制主题只接受由给定公钥签名的消息，包括本节点转发的消息
+// 参数:
+//   - topic: 主题
+//   - keys: 授权发布者的公钥
+//
+// 返回值:
+//   - Option: 配置选项
+func WithTopicPublisherKeys(topic string, keys ...crypto.PubKey) Option {
+	return func(p *PubSub) error {
+		if len(keys) == 0 {
+			logger.Warnf("主题 %s 至少需要一个授权发布者公钥", topic)
+			return fmt.Errorf("主题 %s 至少需要一个授权发布者公钥", topic)
+		}
+		ids, err := publisherIDs(keys)
+		if err != nil {
+			return err
+		}
+		p.setTopicPublishers(topic, ids)
+		return nil
+	}
+}
+
+// SetPublisherKeys 限制主题只接受由给定公钥签名的消息；不传入公钥则取消限制
+// 参数:
+//   - keys: 授权发布者的公钥
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetPublisherKeys(keys ...crypto.PubKey) error {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	ids, err := publisherIDs(keys)
+	if err != nil {
+		return err
+	}
+	t.p.setTopicPublishers(t.topic, ids)
+	return nil
+}
+
+// publisherIDs 由公钥得出发布者 ID 集合
+// 参数:
+//   - keys: 公钥列表
+//
+// 返回值:
+//   - map[peer.ID]struct{}: 发布者 ID 集合，没有公钥时为 nil
+//   - error: 错误信息，如果有的话
+func publisherIDs(keys []crypto.PubKey) (map[peer.ID]struct{}, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	ids := make(map[peer.ID]struct{}, len(keys))
+	for _, key := range keys {
+		if key == nil {
+			logger.Warnf("授权发布者公钥不能为空")
+			return nil, fmt.Errorf("授权发布者公钥不能为空")
+		}
+		id, err := peer.IDFromPublicKey(key)
+		if err != nil {
+			logger.Warnf("无法从公钥得出发布者 ID: %s", err)
+			return nil, fmt.Errorf("无法从公钥得出发布者 ID: %w", err)
+		}
+		ids[id] = struct{}{}
+	}
+	return ids, nil
+}
+
+// setTopicPublishers 设置主题的授权发布者
+// 参数:
+//   - topic: 主题
+//   - ids: 发布者 ID 集合，为 nil 时取消限制
+func (p *PubSub) setTopicPublishers(topic string, ids map[peer.ID]struct{}) {
+	p.topicPublishersMx.Lock()
+	defer p.topicPublishersMx.Unlock()
+
+	if ids == nil {
+		delete(p.topicPublishers, topic)
+		return
+	}
+	p.topicPublishers[topic] = ids
+}
+
+// authorizedPublisher 检查消息的发布者是否为主题的授权发布者，未限制发布者的主题接受所有消息。
+// 限制了发布者的主题只接受签名的消息；这里只检查消息声称的发布者，签名由验证管道验证。
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - bool: 是否授权
+func (p *PubSub) authorizedPublisher(msg *Message) bool {
+	p.topicPublishersMx.RLock()
+	defer p.topicPublishersMx.RUnlock()
+
+	ids, ok := p.topicPublishers[msg.GetTopic()]
+	if !ok {
+		return true
+	}
+	if msg.Signature == nil {
+		return false
+	}
+	_, ok = ids[peer.ID(msg.GetFrom())]
+	return ok
+}
@@ -0,0 +1,103 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/crypto"
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestTopicPublishKey 测试以主题发布密钥发布的消息被接受，其他发布者的消息被拒绝
+func TestTopicPublishKey(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	priv, pub, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID, err := peer.IDFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := getDefaultHosts(t, 3)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0]),
+		getPubsub(ctx, hosts[1]),
+		getPubsub(ctx, hosts[2], WithTopicPublisherKeys("foobar", pub)),
+	}
+	connect(t, hosts[0], hosts[2])
+	connect(t, hosts[1], hosts[2])
+
+	topics := getTopics(psubs, "foobar")
+	sub, err := topics[2].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if err := topics[0].SetPublishKey(priv); err != nil {
+		t.Fatal(err)
+	}
+	if topics[0].PublisherID() != keyID {
+		t.Fatalf("expected publisher %s, got %s", keyID, topics[0].PublisherID())
+	}
+
+	// 未授权的发布者
+	if err := topics[1].Publish(ctx, []byte("forged")); err != nil {
+		t.Fatal(err)
+	}
+	assertNeverReceives(t, sub, 200*time.Millisecond)
+
+	// 以主题发布密钥发布
+	if err := topics[0].Publish(ctx, []byte("legit")); err != nil {
+		t.Fatal(err)
+	}
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "legit" || msg.GetFrom() != keyID {
+		t.Fatalf("unexpected message %q from %s", msg.Data, msg.GetFrom())
+	}
+
+	// 本节点不是授权发布者，本地发布被拒绝
+	var verr ValidationError
+	if err := topics[2].Publish(ctx, []byte("local")); !errors.As(err, &verr) || verr.Reason != RejectUnauthorizedPublisher {
+		t.Fatalf("expected unauthorized publisher error, got %v", err)
+	}
+
+	// 取消限制后接受所有发布者
+	if err := topics[2].SetPublisherKeys(); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[1].Publish(ctx, []byte("open")); err != nil {
+		t.Fatal(err)
+	}
+	assertReceive(t, sub, []byte("open"))
+}
+
+// TestTopicPublishKeyRequiresSigning 测试未启用签名时不能设置主题发布密钥
+func TestTopicPublishKeyRequiresSigning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	priv, _, err := crypto.GenerateKeyPair(crypto.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0], WithMessageSignaturePolicy(StrictNoSign), WithNoAuthor())
+	topic, err := ps.Join("foobar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.SetPublishKey(priv); err == nil {
+		t.Fatal("expected error without message signing")
+	}
+}
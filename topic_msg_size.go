@@ -0,0 +1,65 @@
+// 作用：主题的最大消息大小。
+// 功能：允许为单个主题设置比全局限制更小的最大消息大小，在本地发布和接收消息时都会检查；
+// 发送超大消息的对等节点与发送其他无效消息一样受到评分惩罚。
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// ErrMessageTooLarge 表示消息超出主题的最大消息大小
+var ErrMessageTooLarge = errors.New("消息超出主题的最大消息大小")
+
+// SetMaxMessageSize 设置主题的最大消息大小（序列化后的字节数，包含签名等元信息），覆盖全局的最大消息大小。
+// 传入 0 时恢复使用全局限制。限制只对本节点加入的主题生效，不能超过全局的最大消息大小。
+// 参数:
+//   - bytes: 最大消息大小
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetMaxMessageSize(bytes int) error {
+	if bytes < 0 || bytes > t.p.maxMessageSize {
+		logger.Warnf("主题的最大消息大小必须在 0 到 %d 之间", t.p.maxMessageSize)
+		return fmt.Errorf("主题的最大消息大小必须在 0 到 %d 之间", t.p.maxMessageSize)
+	}
+
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	t.maxMsgSize.Store(int64(bytes))
+	return nil
+}
+
+// MaxMessageSize 返回主题生效的最大消息大小
+// 返回值:
+//   - int: 最大消息大小
+func (t *Topic) MaxMessageSize() int {
+	if n := t.maxMsgSize.Load(); n > 0 {
+		return int(n)
+	}
+	return t.p.maxMessageSize
+}
+
+// checkMessageSize 检查消息是否超出主题的最大消息大小
+// 参数:
+//   - m: 消息
+//
+// 返回值:
+//   - error: 超出限制时返回 ErrMessageTooLarge
+func (t *Topic) checkMessageSize(m *pb.Message) error {
+	limit := t.maxMsgSize.Load()
+	if limit == 0 {
+		return nil
+	}
+	if size := m.Size(); int64(size) > limit {
+		return fmt.Errorf("%w: 大小 %d, 限制 %d", ErrMessageTooLarge, size, limit)
+	}
+	return nil
+}
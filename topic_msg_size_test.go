@@ -0,0 +1,72 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestTopicMaxMessageSize 测试主题的最大消息大小在发布和接收时都生效
+func TestTopicMaxMessageSize(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	var topics []*Topic
+	for _, ps := range psubs {
+		topic, err := ps.Join("foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	if err := topics[0].SetMaxMessageSize(-1); err == nil {
+		t.Fatal("expected error for a negative size")
+	}
+	if err := topics[0].SetMaxMessageSize(DefaultMaxMessageSize + 1); err == nil {
+		t.Fatal("expected error for a size above the global limit")
+	}
+	if topics[0].MaxMessageSize() != DefaultMaxMessageSize {
+		t.Fatal("expected the global limit by default")
+	}
+
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	// 发布方的限制
+	if err := topics[0].SetMaxMessageSize(512); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, make([]byte, 1024)); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+
+	// 接收方的限制
+	if err := topics[0].SetMaxMessageSize(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[1].SetMaxMessageSize(512); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	if err := topics[0].Publish(ctx, []byte("small")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := sub.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "small" {
+		t.Fatalf("expected the oversized message to be rejected, got %d bytes", len(msg.Data))
+	}
+}
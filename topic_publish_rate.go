@@ -0,0 +1,72 @@
+// 作用：主题的本地发布速率限制。
+// 功能：以令牌桶限制本节点在单个主题上的发布速率，超出时 Publish 立即返回 ErrRateLimited，
+// 防止有缺陷的本地生产者向网络泛洪，适用于在同一进程中承载多个租户的场景。
+
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited 表示超出主题的本地发布速率限制
+var ErrRateLimited = errors.New("超出主题的发布速率限制")
+
+// publishLimiter 是主题的本地发布令牌桶
+type publishLimiter struct {
+	mx     sync.Mutex
+	rate   float64 // 每秒允许发布的消息数
+	burst  float64 // 令牌桶的容量
+	bucket tokenBucket
+}
+
+// SetPublishRateLimit 设置本节点在主题上的发布速率限制，超出时 Publish 返回 ErrRateLimited。
+// 传入的速率为 0 时取消限制。每次设置都重新开始计算，令牌桶从满桶开始。
+// 参数:
+//   - rate: 每秒允许发布的消息数
+//   - burst: 允许突发发布的消息数，为 0 时取速率向上取整，至少为 1
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (t *Topic) SetPublishRateLimit(rate float64, burst int) error {
+	if rate < 0 || burst < 0 || math.IsNaN(rate) || math.IsInf(rate, 0) {
+		logger.Warnf("无效的发布速率限制: 速率 %v, 突发 %d", rate, burst)
+		return fmt.Errorf("无效的发布速率限制: 速率 %v, 突发 %d", rate, burst)
+	}
+
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	if t.closed {
+		return ErrTopicClosed
+	}
+
+	if rate == 0 {
+		t.pubLimit.Store(nil)
+		return nil
+	}
+	if burst == 0 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	t.pubLimit.Store(&publishLimiter{rate: rate, burst: float64(burst)})
+	return nil
+}
+
+// allowPublish 从发布令牌桶中消耗一个令牌，未设置限制时总是返回 true
+// 参数:
+//   - now: 当前时间
+//
+// 返回值:
+//   - bool: 是否允许发布
+func (t *Topic) allowPublish(now time.Time) bool {
+	pl := t.pubLimit.Load()
+	if pl == nil {
+		return true
+	}
+
+	pl.mx.Lock()
+	defer pl.mx.Unlock()
+	return pl.bucket.take(1, pl.rate, pl.burst, now)
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestTopicPublishRateLimit 测试主题的本地发布速率限制
+func TestTopicPublishRateLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 1)
+	ps := getPubsub(ctx, hosts[0])
+	topic, err := ps.Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ps.Join("bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := topic.SetPublishRateLimit(-1, 0); err == nil {
+		t.Fatal("expected error for a negative rate")
+	}
+	if err := topic.SetPublishRateLimit(10, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := topic.Publish(ctx, []byte("burst")); err != nil {
+			t.Fatalf("publish %d: %s", i, err)
+		}
+	}
+	if err := topic.Publish(ctx, []byte("limited")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+
+	// 其他主题不受影响
+	for i := 0; i < 5; i++ {
+		if err := other.Publish(ctx, []byte("unlimited")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 令牌按速率补充
+	time.Sleep(150 * time.Millisecond)
+	if err := topic.Publish(ctx, []byte("refilled")); err != nil {
+		t.Fatal(err)
+	}
+
+	// 取消限制
+	if err := topic.SetPublishRateLimit(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := topic.Publish(ctx, []byte("unlimited")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
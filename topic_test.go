@@ -1049,6 +1049,68 @@ func TestWithTopicMsgIdFunction(t *testing.T) {
 	}
 }
 
+// TestWithMessageIdFnForTopic 测试在创建 PubSub 时为特定主题设置消息 ID 函数
+func TestWithMessageIdFnForTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const topicA, topicB = "foobarA", "foobarB"
+
+	contentID := func(pmsg *pb.Message) string {
+		hash := sha1.Sum(pmsg.Data)
+		return string(hash[:])
+	}
+
+	hosts := getDefaultHosts(t, 2)
+	pubsubs := getPubsubs(ctx, hosts, WithMessageIdFnForTopic(topicB, contentID))
+	connectAll(t, hosts)
+
+	topicsA := getTopics(pubsubs, topicA) // 使用全局消息 ID 函数
+	topicsB := getTopics(pubsubs, topicB) // 使用主题消息 ID 函数
+
+	payload := []byte("pubsub rocks")
+
+	subA, err := topicsA[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subB, err := topicsB[0].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := topicsA[1].Publish(ctx, payload, WithReadiness(MinTopicSize(1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := topicsB[1].Publish(ctx, payload, WithReadiness(MinTopicSize(1))); err != nil {
+		t.Fatal(err)
+	}
+
+	msgA, err := subA.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgB, err := subB.Next(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if msgA.ID != DefaultMsgIdFn(msgA.Message) {
+		t.Fatal("expected default msg id for topic without custom function")
+	}
+	if msgB.ID != contentID(msgB.Message) {
+		t.Fatal("expected per-topic msg id function to be used")
+	}
+
+	ps := &PubSub{idGen: newMsgIdGenerator()}
+	if err := WithMessageIdFnForTopic("", contentID)(ps); err == nil {
+		t.Fatal("expected error for empty topic")
+	}
+	if err := WithMessageIdFnForTopic(topicA, nil)(ps); err == nil {
+		t.Fatal("expected error for nil function")
+	}
+}
+
 func TestWithLocalPublication(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -4,6 +4,7 @@
 package pubsub
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/dep2p/go-dep2p/core/peer"
@@ -56,12 +57,55 @@ type RawTracer interface {
 	UndeliverableMessage(msg *Message)
 }
 
+// OpportunisticGraftTracer 是 RawTracer 的可选扩展接口。
+// 实现了该接口的低级追踪器会在机会性 GRAFT 触发时收到通知。
+type OpportunisticGraftTracer interface {
+	// OpportunisticGraft 在主题网格的中位评分低于阈值并机会性 GRAFT 对等节点时调用。
+	OpportunisticGraft(topic string, medianScore float64, peers []peer.ID)
+}
+
+// OversizedRPCTracer 是 RawTracer 的可选扩展接口。
+// 实现了该接口的低级追踪器会在收到超出最大传输大小的 RPC 时收到通知。
+type OversizedRPCTracer interface {
+	// OversizedRPC 在对等节点发送的 RPC 超出最大传输大小、流被重置时调用。
+	OversizedRPC(p peer.ID, limit int)
+}
+
 // pubsubTracer 结构体，用于管理追踪器。
 type pubsubTracer struct {
-	tracer EventTracer     // 事件追踪器
-	raw    []RawTracer     // 低级追踪器数组
-	pid    peer.ID         // 节点 ID
-	idGen  *msgIDGenerator // 消息 ID 生成器
+	tracer   EventTracer     // 事件追踪器
+	raw      []RawTracer     // 低级追踪器数组
+	internal []RawTracer     // 路由器内部使用的低级追踪器（评分、gossip 跟踪、门控），同时包含在 raw 中
+	pid      peer.ID         // 节点 ID
+	idGen    *msgIDGenerator // 消息 ID 生成器
+
+	tracingStopped atomic.Bool // 停止事件追踪
+	metricsStopped atomic.Bool // 停止应用程序的低级追踪器，内部追踪器不受影响
+}
+
+// addInternalRaw 添加路由器内部使用的低级追踪器
+// 参数:
+//   - tracers: 低级追踪器
+func (t *pubsubTracer) addInternalRaw(tracers ...RawTracer) {
+	t.raw = append(t.raw, tracers...)
+	t.internal = append(t.internal, tracers...)
+}
+
+// rawTracers 返回当前需要调用的低级追踪器
+// 返回值:
+//   - []RawTracer: 低级追踪器数组
+func (t *pubsubTracer) rawTracers() []RawTracer {
+	if t.metricsStopped.Load() {
+		return t.internal
+	}
+	return t.raw
+}
+
+// tracing 返回是否需要记录事件
+// 返回值:
+//   - bool: 是否需要记录事件
+func (t *pubsubTracer) tracing() bool {
+	return t.tracer != nil && !t.tracingStopped.Load()
 }
 
 // PublishMessage 方法记录发布消息的事件。
@@ -70,7 +114,7 @@ func (t *pubsubTracer) PublishMessage(msg *Message) {
 		return
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -95,7 +139,7 @@ func (t *pubsubTracer) ValidateMessage(msg *Message) {
 	}
 
 	if msg.ReceivedFrom != t.pid {
-		for _, tr := range t.raw {
+		for _, tr := range t.rawTracers() {
 			tr.ValidateMessage(msg) // 调用所有低级追踪器的 ValidateMessage 方法
 		}
 	}
@@ -111,12 +155,12 @@ func (t *pubsubTracer) RejectMessage(msg *Message, reason string) {
 	}
 
 	if msg.ReceivedFrom != t.pid {
-		for _, tr := range t.raw {
+		for _, tr := range t.rawTracers() {
 			tr.RejectMessage(msg, reason) // 调用所有低级追踪器的 RejectMessage 方法
 		}
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -145,12 +189,12 @@ func (t *pubsubTracer) DuplicateMessage(msg *Message) {
 	}
 
 	if msg.ReceivedFrom != t.pid {
-		for _, tr := range t.raw {
+		for _, tr := range t.rawTracers() {
 			tr.DuplicateMessage(msg) // 调用所有低级追踪器的 DuplicateMessage 方法
 		}
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -178,12 +222,12 @@ func (t *pubsubTracer) DeliverMessage(msg *Message) {
 	}
 
 	if msg.ReceivedFrom != t.pid {
-		for _, tr := range t.raw {
+		for _, tr := range t.rawTracers() {
 			tr.DeliverMessage(msg) // 调用所有低级追踪器的 DeliverMessage 方法
 		}
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -211,11 +255,11 @@ func (t *pubsubTracer) AddPeer(p peer.ID, proto protocol.ID) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.AddPeer(p, proto) // 调用所有低级追踪器的 AddPeer 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -242,11 +286,11 @@ func (t *pubsubTracer) RemovePeer(p peer.ID) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.RemovePeer(p) // 调用所有低级追踪器的 RemovePeer 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -271,11 +315,11 @@ func (t *pubsubTracer) RecvRPC(rpc *RPC) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.RecvRPC(rpc) // 调用所有低级追踪器的 RecvRPC 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -302,11 +346,11 @@ func (t *pubsubTracer) SendRPC(rpc *RPC, p peer.ID) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.SendRPC(rpc, p) // 调用所有低级追踪器的 SendRPC 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -333,11 +377,11 @@ func (t *pubsubTracer) DropRPC(rpc *RPC, p peer.ID) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.DropRPC(rpc, p) // 调用所有低级追踪器的 DropRPC 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -363,7 +407,7 @@ func (t *pubsubTracer) UndeliverableMessage(msg *Message) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.UndeliverableMessage(msg) // 调用所有低级追踪器的 UndeliverableMessage 方法
 	}
 }
@@ -463,11 +507,11 @@ func (t *pubsubTracer) Join(topic string) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.Join(topic) // 调用所有低级追踪器的 Join 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -492,11 +536,11 @@ func (t *pubsubTracer) Leave(topic string) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.Leave(topic) // 调用所有低级追踪器的 Leave 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -522,11 +566,11 @@ func (t *pubsubTracer) Graft(p peer.ID, topic string) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.Graft(p, topic) // 调用所有低级追踪器的 Graft 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -553,11 +597,11 @@ func (t *pubsubTracer) Prune(p peer.ID, topic string) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.Prune(p, topic) // 调用所有低级追踪器的 Prune 方法
 	}
 
-	if t.tracer == nil {
+	if !t.tracing() {
 		return
 	}
 
@@ -583,7 +627,64 @@ func (t *pubsubTracer) ThrottlePeer(p peer.ID) {
 		return
 	}
 
-	for _, tr := range t.raw {
+	for _, tr := range t.rawTracers() {
 		tr.ThrottlePeer(p) // 调用所有低级追踪器的 ThrottlePeer 方法
 	}
 }
+
+// OpportunisticGraft 方法记录机会性 GRAFT 事件。
+// 参数:
+//   - topic: 主题
+//   - medianScore: 触发时网格对等节点的中位评分
+//   - peers: 被机会性 GRAFT 的对等节点
+func (t *pubsubTracer) OpportunisticGraft(topic string, medianScore float64, peers []peer.ID) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.rawTracers() {
+		if ogt, ok := tr.(OpportunisticGraftTracer); ok {
+			ogt.OpportunisticGraft(topic, medianScore, peers) // 只通知实现了扩展接口的低级追踪器
+		}
+	}
+}
+
+// OversizedRPC 方法记录收到超出最大传输大小的 RPC 的事件。
+// 参数:
+//   - p: 发送 RPC 的对等节点 ID
+//   - limit: 最大传输大小
+func (t *pubsubTracer) OversizedRPC(p peer.ID, limit int) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.rawTracers() {
+		if ort, ok := tr.(OversizedRPCTracer); ok {
+			ort.OversizedRPC(p, limit) // 只通知实现了扩展接口的低级追踪器
+		}
+	}
+}
+
+// PeerPenaltyTracer 是 RawTracer 的可选扩展接口。
+// 实现了该接口的低级追踪器会在对等节点受到行为惩罚时收到通知。
+type PeerPenaltyTracer interface {
+	// PeerPenalty 在对等节点受到行为惩罚时调用，reason 是一个命名字符串 Penalty*。
+	PeerPenalty(p peer.ID, count int, reason string)
+}
+
+// PeerPenalty 方法记录对等节点受到行为惩罚的事件。
+// 参数:
+//   - p: 受到惩罚的对等节点 ID
+//   - count: 惩罚次数
+//   - reason: 惩罚原因
+func (t *pubsubTracer) PeerPenalty(p peer.ID, count int, reason string) {
+	if t == nil {
+		return
+	}
+
+	for _, tr := range t.rawTracers() {
+		if ppt, ok := tr.(PeerPenaltyTracer); ok {
+			ppt.PeerPenalty(p, count, reason) // 只通知实现了扩展接口的低级追踪器
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// 作用：远程追踪收集器。
+// 功能：在收集节点上接收 RemoteTracer 发送的追踪事件批次，并转发给本地的事件追踪器（例如 JSON 或 protobuf 文件追踪器），
+// 以便集中记录多个生产节点的网格行为。
+
+package pubsub
+
+import (
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/dep2p/go-dep2p/core/host"
+	"github.com/dep2p/go-dep2p/core/network"
+	"github.com/dep2p/go-dep2p/p2plib/msgio/protoio"
+	pb "github.com/dep2p/pubsub/pb"
+)
+
+// maxTraceBatchSize 是收集器接受的单个追踪事件批次的最大大小
+const maxTraceBatchSize = 1 << 24
+
+// TraceCollector 接收远程节点通过 RemoteTracer 发送的追踪事件，并转发给本地追踪器
+type TraceCollector struct {
+	host host.Host   // 本地主机
+	sink EventTracer // 接收事件的本地追踪器
+
+	wg      sync.WaitGroup              // 正在处理的流
+	mx      sync.Mutex                  // 保护 streams 和 closed
+	streams map[network.Stream]struct{} // 打开的追踪流
+	closed  bool                        // 收集器是否已关闭
+}
+
+// NewTraceCollector 创建追踪收集器，并在主机上注册远程追踪协议的处理器
+// 参数:
+//   - h: 收集节点的主机
+//   - sink: 接收事件的本地追踪器，可能被多个流并发调用
+//
+// 返回值:
+//   - *TraceCollector: 追踪收集器
+func NewTraceCollector(h host.Host, sink EventTracer) *TraceCollector {
+	c := &TraceCollector{
+		host:    h,
+		sink:    sink,
+		streams: make(map[network.Stream]struct{}),
+	}
+	h.SetStreamHandler(RemoteTracerProtoID, c.handleStream)
+	return c
+}
+
+// Close 注销协议处理器，重置所有打开的追踪流并等待处理结束
+func (c *TraceCollector) Close() {
+	c.host.RemoveStreamHandler(RemoteTracerProtoID)
+
+	c.mx.Lock()
+	c.closed = true
+	for s := range c.streams {
+		s.Reset()
+	}
+	c.mx.Unlock()
+
+	c.wg.Wait()
+}
+
+// handleStream 解码一个远程追踪流中的事件批次并转发给本地追踪器
+// 参数:
+//   - s: 远程追踪流
+func (c *TraceCollector) handleStream(s network.Stream) {
+	c.mx.Lock()
+	if c.closed {
+		c.mx.Unlock()
+		s.Reset()
+		return
+	}
+	c.streams[s] = struct{}{}
+	c.wg.Add(1)
+	c.mx.Unlock()
+
+	defer func() {
+		c.mx.Lock()
+		delete(c.streams, s)
+		c.mx.Unlock()
+		c.wg.Done()
+	}()
+
+	gzr, err := gzip.NewReader(s)
+	if err != nil {
+		logger.Debugf("打开来自 %s 的追踪流时出错: %s", s.Conn().RemotePeer(), err)
+		s.Reset()
+		return
+	}
+
+	r := protoio.NewDelimitedReader(gzr, maxTraceBatchSize)
+	var batch pb.TraceEventBatch
+	for {
+		batch.Reset()
+		if err := r.ReadMsg(&batch); err != nil {
+			if err != io.EOF {
+				logger.Debugf("读取来自 %s 的追踪事件批次时出错: %s", s.Conn().RemotePeer(), err)
+				s.Reset()
+			} else {
+				s.Close()
+			}
+			return
+		}
+
+		for _, evt := range batch.GetBatch() {
+			c.sink.Trace(evt)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+// 作用：低级追踪器的事件过滤。
+// 功能：注册低级追踪器时可以只订阅部分事件，使指标和调试追踪器可以同时存在，
+// 而不需要由一个包装器手动分发和过滤事件。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// RawEvent 标识一个 RawTracer 事件
+type RawEvent int
+
+// RawTracer 事件，与 RawTracer 及其扩展接口的方法一一对应
+const (
+	AddPeer              RawEvent = iota // 添加对等节点
+	RemovePeer                           // 移除对等节点
+	Join                                 // 加入主题
+	Leave                                // 离开主题
+	Graft                                // GRAFT 对等节点到 mesh
+	Prune                                // 从 mesh 中 PRUNE 对等节点
+	ValidateMessage                      // 开始验证消息
+	DeliverMessage                       // 投递消息
+	RejectMessage                        // 拒绝消息
+	DuplicateMessage                     // 重复消息
+	ThrottlePeer                         // 限流对等节点
+	RecvRPC                              // 接收 RPC
+	SendRPC                              // 发送 RPC
+	DropRPC                              // 丢弃出站 RPC
+	UndeliverableMessage                 // 消息无法投递给订阅者
+	OpportunisticGraft                   // 机会性 GRAFT
+	OversizedRPC                         // 收到超出大小限制的 RPC
+	PeerPenalty                          // 对等节点受到行为惩罚
+
+	numRawEvents // 事件数量，不是有效事件
+)
+
+// RawTracerOpt 是注册低级追踪器的选项
+type RawTracerOpt func(*rawTracerOptions) error
+
+// rawTracerOptions 是注册低级追踪器的配置
+type rawTracerOptions struct {
+	events uint32 // 订阅的事件位图，为 0 时订阅所有事件
+}
+
+// FilterEvents 是一个选项，使低级追踪器只接收给定的事件
+// 参数:
+//   - events: 要接收的事件
+//
+// 返回值:
+//   - RawTracerOpt: 低级追踪器选项
+func FilterEvents(events ...RawEvent) RawTracerOpt {
+	return func(opts *rawTracerOptions) error {
+		if len(events) == 0 {
+			logger.Warnf("事件过滤器至少需要一个事件")
+			return fmt.Errorf("事件过滤器至少需要一个事件")
+		}
+		for _, evt := range events {
+			if evt < 0 || evt >= numRawEvents {
+				logger.Warnf("未知的追踪事件: %d", evt)
+				return fmt.Errorf("未知的追踪事件: %d", evt)
+			}
+			opts.events |= 1 << evt
+		}
+		return nil
+	}
+}
+
+// filteredTracer 只把订阅的事件转发给低级追踪器
+type filteredTracer struct {
+	tracer RawTracer
+	events uint32
+}
+
+var _ RawTracer = (*filteredTracer)(nil)
+var _ OpportunisticGraftTracer = (*filteredTracer)(nil)
+var _ OversizedRPCTracer = (*filteredTracer)(nil)
+var _ PeerPenaltyTracer = (*filteredTracer)(nil)
+
+// has 返回是否订阅了事件
+// 参数:
+//   - evt: 事件
+//
+// 返回值:
+//   - bool: 是否订阅
+func (t *filteredTracer) has(evt RawEvent) bool {
+	return t.events&(1<<evt) != 0
+}
+
+// AddPeer 实现 RawTracer 接口
+func (t *filteredTracer) AddPeer(p peer.ID, proto protocol.ID) {
+	if t.has(AddPeer) {
+		t.tracer.AddPeer(p, proto)
+	}
+}
+
+// RemovePeer 实现 RawTracer 接口
+func (t *filteredTracer) RemovePeer(p peer.ID) {
+	if t.has(RemovePeer) {
+		t.tracer.RemovePeer(p)
+	}
+}
+
+// Join 实现 RawTracer 接口
+func (t *filteredTracer) Join(topic string) {
+	if t.has(Join) {
+		t.tracer.Join(topic)
+	}
+}
+
+// Leave 实现 RawTracer 接口
+func (t *filteredTracer) Leave(topic string) {
+	if t.has(Leave) {
+		t.tracer.Leave(topic)
+	}
+}
+
+// Graft 实现 RawTracer 接口
+func (t *filteredTracer) Graft(p peer.ID, topic string) {
+	if t.has(Graft) {
+		t.tracer.Graft(p, topic)
+	}
+}
+
+// Prune 实现 RawTracer 接口
+func (t *filteredTracer) Prune(p peer.ID, topic string) {
+	if t.has(Prune) {
+		t.tracer.Prune(p, topic)
+	}
+}
+
+// ValidateMessage 实现 RawTracer 接口
+func (t *filteredTracer) ValidateMessage(msg *Message) {
+	if t.has(ValidateMessage) {
+		t.tracer.ValidateMessage(msg)
+	}
+}
+
+// DeliverMessage 实现 RawTracer 接口
+func (t *filteredTracer) DeliverMessage(msg *Message) {
+	if t.has(DeliverMessage) {
+		t.tracer.DeliverMessage(msg)
+	}
+}
+
+// RejectMessage 实现 RawTracer 接口
+func (t *filteredTracer) RejectMessage(msg *Message, reason string) {
+	if t.has(RejectMessage) {
+		t.tracer.RejectMessage(msg, reason)
+	}
+}
+
+// DuplicateMessage 实现 RawTracer 接口
+func (t *filteredTracer) DuplicateMessage(msg *Message) {
+	if t.has(DuplicateMessage) {
+		t.tracer.DuplicateMessage(msg)
+	}
+}
+
+// ThrottlePeer 实现 RawTracer 接口
+func (t *filteredTracer) ThrottlePeer(p peer.ID) {
+	if t.has(ThrottlePeer) {
+		t.tracer.ThrottlePeer(p)
+	}
+}
+
+// RecvRPC 实现 RawTracer 接口
+func (t *filteredTracer) RecvRPC(rpc *RPC) {
+	if t.has(RecvRPC) {
+		t.tracer.RecvRPC(rpc)
+	}
+}
+
+// SendRPC 实现 RawTracer 接口
+func (t *filteredTracer) SendRPC(rpc *RPC, p peer.ID) {
+	if t.has(SendRPC) {
+		t.tracer.SendRPC(rpc, p)
+	}
+}
+
+// DropRPC 实现 RawTracer 接口
+func (t *filteredTracer) DropRPC(rpc *RPC, p peer.ID) {
+	if t.has(DropRPC) {
+		t.tracer.DropRPC(rpc, p)
+	}
+}
+
+// UndeliverableMessage 实现 RawTracer 接口
+func (t *filteredTracer) UndeliverableMessage(msg *Message) {
+	if t.has(UndeliverableMessage) {
+		t.tracer.UndeliverableMessage(msg)
+	}
+}
+
+// OpportunisticGraft 实现 OpportunisticGraftTracer 接口，仅当被包装的追踪器实现了该接口时转发
+func (t *filteredTracer) OpportunisticGraft(topic string, medianScore float64, peers []peer.ID) {
+	if !t.has(OpportunisticGraft) {
+		return
+	}
+	if og, ok := t.tracer.(OpportunisticGraftTracer); ok {
+		og.OpportunisticGraft(topic, medianScore, peers)
+	}
+}
+
+// OversizedRPC 实现 OversizedRPCTracer 接口，仅当被包装的追踪器实现了该接口时转发
+func (t *filteredTracer) OversizedRPC(p peer.ID, limit int) {
+	if !t.has(OversizedRPC) {
+		return
+	}
+	if ort, ok := t.tracer.(OversizedRPCTracer); ok {
+		ort.OversizedRPC(p, limit)
+	}
+}
+
+// PeerPenalty 实现 PeerPenaltyTracer 接口，仅当被包装的追踪器实现了该接口时转发
+func (t *filteredTracer) PeerPenalty(p peer.ID, count int, reason string) {
+	if !t.has(PeerPenalty) {
+		return
+	}
+	if ppt, ok := t.tracer.(PeerPenaltyTracer); ok {
+		ppt.PeerPenalty(p, count, reason)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -340,3 +341,278 @@ func TestRemoteTracer(t *testing.T) {
 	// 检查追踪事件统计
 	mrt.check(t)
 }
+
+// opportunisticGraftRecorder 记录机会性 GRAFT 事件的低级追踪器
+type opportunisticGraftRecorder struct {
+	RawTracer // 未实现的方法不会被调用
+
+	mx     sync.Mutex
+	topics []string
+	peers  []peer.ID
+}
+
+// OpportunisticGraft 实现 OpportunisticGraftTracer 接口
+func (r *opportunisticGraftRecorder) OpportunisticGraft(topic string, medianScore float64, peers []peer.ID) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.topics = append(r.topics, topic)
+	r.peers = append(r.peers, peers...)
+}
+
+// TestOpportunisticGraftTracer 测试机会性 GRAFT 事件只分发给实现了扩展接口的低级追踪器
+func TestOpportunisticGraftTracer(t *testing.T) {
+	rec := &opportunisticGraftRecorder{}
+	tr := &pubsubTracer{raw: []RawTracer{rec, &gossipTracer{}}}
+
+	peers := []peer.ID{"a", "b"}
+	tr.OpportunisticGraft("test", -1, peers)
+
+	if len(rec.topics) != 1 || rec.topics[0] != "test" {
+		t.Fatalf("expected one event for topic test, got %v", rec.topics)
+	}
+	if len(rec.peers) != 2 {
+		t.Fatalf("expected 2 grafted peers, got %d", len(rec.peers))
+	}
+
+	// nil 追踪器不应 panic
+	var nilTracer *pubsubTracer
+	nilTracer.OpportunisticGraft("test", -1, peers)
+}
+
+// statsTracer 统计收到的追踪事件
+type statsTracer struct {
+	mx sync.Mutex
+	ts traceStats
+}
+
+// Trace 实现 EventTracer 接口
+func (st *statsTracer) Trace(evt *pb.TraceEvent) {
+	st.mx.Lock()
+	defer st.mx.Unlock()
+	st.ts.process(evt)
+}
+
+// TestTraceCollector 测试收集器接收远程追踪器发送的事件
+func TestTraceCollector(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	sink := &statsTracer{}
+	collector := NewTraceCollector(hosts[0], sink)
+	defer collector.Close()
+
+	tracer, err := NewRemoteTracer(ctx, hosts[1], peer.AddrInfo{ID: hosts[0].ID(), Addrs: hosts[0].Addrs()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 被追踪的节点与另一个节点交换有效和无效的消息
+	peers := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, peers[0], WithEventTracer(tracer)),
+		getGossipsub(ctx, peers[1]),
+	}
+	var topics []*Topic
+	for _, ps := range psubs {
+		ps.RegisterTopicValidator("test", func(ctx context.Context, p peer.ID, msg *Message) bool {
+			return string(msg.Data) != "invalid!"
+		})
+		topic, err := ps.Join("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := topic.Subscribe(); err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+	}
+	connect(t, peers[0], peers[1])
+	time.Sleep(time.Second)
+
+	topics[0].Publish(ctx, []byte("message 0"))
+	topics[1].Publish(ctx, []byte("message 1"))
+	topics[0].Publish(ctx, []byte("invalid!"))
+	time.Sleep(time.Second)
+	tracer.Close()
+	time.Sleep(time.Second)
+
+	sink.mx.Lock()
+	defer sink.mx.Unlock()
+	if sink.ts.publish == 0 || sink.ts.deliver == 0 || sink.ts.reject == 0 || sink.ts.join == 0 {
+		t.Fatalf("expected publish, deliver, reject and join events, got %+v", sink.ts)
+	}
+}
+
+// TestRotatingJSONTracer 测试按大小轮转的 JSON 追踪器
+func TestRotatingJSONTracer(t *testing.T) {
+	if _, err := NewRotatingJSONTracer(filepath.Join(t.TempDir(), "trace.json"), 0, 1); err == nil {
+		t.Fatal("expected error for non-positive max size")
+	}
+
+	file := filepath.Join(t.TempDir(), "trace.json")
+	tracer, err := NewRotatingJSONTracer(file, 256, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		tracer.Trace(&pb.TraceEvent{Type: pb.TraceEvent_JOIN, Join: &pb.TraceEvent_Join{Topic: fmt.Sprintf("topic-%d", i)}})
+	}
+	time.Sleep(100 * time.Millisecond)
+	tracer.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// 当前文件和两个备份都存在且不超过最大大小，更旧的备份被删除
+	for _, name := range []string{file, file + ".1", file + ".2"} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() == 0 || fi.Size() > 256 {
+			t.Fatalf("unexpected size %d for %s", fi.Size(), name)
+		}
+	}
+	if _, err := os.Stat(file + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most 2 backups, got %v", err)
+	}
+
+	// 最后的事件位于当前文件，且每行都是完整的事件
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var last pb.TraceEvent
+	dec := json.NewDecoder(f)
+	for {
+		var evt pb.TraceEvent
+		if err := dec.Decode(&evt); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+		last = evt
+	}
+	if last.GetJoin().GetTopic() != "topic-49" {
+		t.Fatalf("expected the last event in the current file, got %q", last.GetJoin().GetTopic())
+	}
+}
+
+// eventRecorder 记录收到的事件的低级追踪器，未实现的方法被调用时会 panic
+type eventRecorder struct {
+	RawTracer // 过滤掉的事件不会被调用
+
+	mx     sync.Mutex
+	events []RawEvent
+}
+
+// record 记录一个事件
+func (r *eventRecorder) record(evt RawEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, evt)
+}
+
+// recorded 返回记录的事件
+func (r *eventRecorder) recorded() []RawEvent {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return append([]RawEvent(nil), r.events...)
+}
+
+// Join 实现 RawTracer 接口
+func (r *eventRecorder) Join(topic string) { r.record(Join) }
+
+// DeliverMessage 实现 RawTracer 接口
+func (r *eventRecorder) DeliverMessage(msg *Message) { r.record(DeliverMessage) }
+
+// RejectMessage 实现 RawTracer 接口
+func (r *eventRecorder) RejectMessage(msg *Message, reason string) { r.record(RejectMessage) }
+
+// TestRawTracerFilter 测试多个低级追踪器各自只接收过滤后的事件
+func TestRawTracerFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	delivered := &eventRecorder{}
+	joined := &eventRecorder{}
+	og := &opportunisticGraftRecorder{}
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getGossipsub(ctx, hosts[0],
+			WithRawTracer(delivered, FilterEvents(DeliverMessage, RejectMessage)),
+			WithRawTracer(joined, FilterEvents(Join)),
+			WithRawTracer(og, FilterEvents(OpportunisticGraft))),
+		getGossipsub(ctx, hosts[1]),
+	}
+
+	var topics []*Topic
+	var subs []*Subscription
+	for _, ps := range psubs {
+		topic, err := ps.Join("filter")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sub, err := topic.Subscribe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		topics = append(topics, topic)
+		subs = append(subs, sub)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(time.Second)
+
+	if err := topics[1].Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := subs[0].Next(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if evts := delivered.recorded(); len(evts) != 1 || evts[0] != DeliverMessage {
+		t.Fatalf("expected a single deliver event, got %v", evts)
+	}
+	if evts := joined.recorded(); len(evts) != 1 || evts[0] != Join {
+		t.Fatalf("expected a single join event, got %v", evts)
+	}
+
+	// 机会性 GRAFT 只转发给实现了扩展接口的追踪器
+	psubs[0].tracer.OpportunisticGraft("filter", -1, []peer.ID{hosts[1].ID()})
+	if len(og.topics) != 1 {
+		t.Fatalf("expected one opportunistic graft event, got %v", og.topics)
+	}
+
+	// 超大 RPC 事件同样按过滤器转发
+	oversized := &oversizedRPCRecorder{}
+	tr := &pubsubTracer{raw: []RawTracer{
+		&filteredTracer{tracer: oversized, events: 1 << OversizedRPC},
+		&filteredTracer{tracer: oversized, events: 1 << Join},
+	}}
+	tr.OversizedRPC(hosts[1].ID(), 1024)
+	if len(oversized.peers) != 1 {
+		t.Fatalf("expected one oversized RPC event, got %v", oversized.peers)
+	}
+
+	// 行为惩罚事件同样按过滤器转发
+	audit := newPeerAudit(hosts[0].ID())
+	tr = &pubsubTracer{raw: []RawTracer{
+		&filteredTracer{tracer: audit, events: 1 << PeerPenalty},
+		&filteredTracer{tracer: audit, events: 1 << Join},
+	}}
+	tr.PeerPenalty(hosts[1].ID(), 1, PenaltyBrokenPromise)
+	if evts := audit.events(hosts[1].ID()); len(evts) != 1 {
+		t.Fatalf("expected one peer penalty event, got %v", evts)
+	}
+
+	if _, err := NewGossipSub(ctx, hosts[0], WithRawTracer(delivered, FilterEvents())); err == nil {
+		t.Fatal("expected error for empty event filter")
+	}
+	if _, err := NewGossipSub(ctx, hosts[0], WithRawTracer(delivered, FilterEvents(RawEvent(100)))); err == nil {
+		t.Fatal("expected error for unknown event")
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -31,17 +32,32 @@ var MinTraceBatchSize = 16
 
 // 拒绝消息的原因常量
 const (
-	RejectBlacklstedPeer      = "blacklisted peer"        // 被列入黑名单的对等节点
-	RejectBlacklistedSource   = "blacklisted source"      // 被列入黑名单的来源
-	RejectMissingSignature    = "missing signature"       // 缺少签名
-	RejectUnexpectedSignature = "unexpected signature"    // 意外的签名
-	RejectUnexpectedAuthInfo  = "unexpected auth info"    // 意外的身份验证信息
-	RejectInvalidSignature    = "invalid signature"       // 无效的签名
-	RejectValidationQueueFull = "validation queue full"   // 验证队列已满
-	RejectValidationThrottled = "validation throttled"    // 验证被限制
-	RejectValidationFailed    = "validation failed"       // 验证失败
-	RejectValidationIgnored   = "validation ignored"      // 验证被忽略
-	RejectSelfOrigin          = "self originated message" // 自己发起的消息
+	RejectBlacklstedPeer        = "blacklisted peer"        // 被列入黑名单的对等节点
+	RejectBlacklistedSource     = "blacklisted source"      // 被列入黑名单的来源
+	RejectMissingSignature      = "missing signature"       // 缺少签名
+	RejectUnexpectedSignature   = "unexpected signature"    // 意外的签名
+	RejectUnexpectedAuthInfo    = "unexpected auth info"    // 意外的身份验证信息
+	RejectInvalidSignature      = "invalid signature"       // 无效的签名
+	RejectValidationQueueFull   = "validation queue full"   // 验证队列已满
+	RejectValidationThrottled   = "validation throttled"    // 验证被限制
+	RejectValidationFailed      = "validation failed"       // 验证失败
+	RejectValidationIgnored     = "validation ignored"      // 验证被忽略
+	RejectSelfOrigin            = "self originated message" // 自己发起的消息
+	RejectHeadersTooLarge       = "headers too large"       // 消息头部超出限制
+	RejectMessageTooLarge       = "message too large"       // 消息超出主题的大小限制
+	RejectUnauthorizedPublisher = "unauthorized publisher"  // 发布者不在主题的授权发布者之中
+)
+
+// 行为惩罚的原因常量
+const (
+	PenaltyGraftBackoff  = "graft during backoff"      // 在回退期内 GRAFT
+	PenaltyGraftFlood    = "graft flood"               // 回退期结束前过早 GRAFT
+	PenaltyGraftRate     = "graft rate exceeded"       // GRAFT 过于频繁
+	PenaltyBrokenPromise = "broken iwant promise"      // 未遵守 IWANT 请求
+	PenaltyPeerIDChurn   = "peer id churn"             // 同一 IP 上对等节点 ID 更换过快
+	PenaltyRateLimit     = "rate limit exceeded"       // 超出消息速率限制
+	PenaltyUnauthorized  = "unauthorized topic access" // 未授权访问主题
+	PenaltyOversizedRPC  = "oversized rpc"             // RPC 超出最大传输大小
 )
 
 // basicTracer 是一个基本的追踪器，存储和管理追踪事件
@@ -224,6 +240,104 @@ func (t *PBTracer) doWrite() {
 // 确保 PBTracer 实现了 EventTracer 接口
 var _ EventTracer = (*PBTracer)(nil)
 
+// NewRotatingJSONTracer 创建一个新的 JSONTracer，将追踪信息写入按大小轮转的文件。
+// 文件超过 maxSize 字节时重命名为 file.1，已有的备份依次后移，超过 maxBackups 的最旧备份被删除。
+// 参数:
+//   - file: 文件路径
+//   - maxSize: 单个文件的最大字节数
+//   - maxBackups: 保留的备份文件数，为 0 时轮转只截断当前文件
+//
+// 返回值：
+//   - *JSONTracer: JSONTracer 对象
+//   - error: 错误信息
+func NewRotatingJSONTracer(file string, maxSize int64, maxBackups int) (*JSONTracer, error) {
+	if maxSize <= 0 {
+		logger.Warnf("追踪文件的最大大小必须为正数")
+		return nil, fmt.Errorf("追踪文件的最大大小必须为正数")
+	}
+	if maxBackups < 0 {
+		logger.Warnf("追踪文件的备份数不能为负数")
+		return nil, fmt.Errorf("追踪文件的备份数不能为负数")
+	}
+
+	w := &rotatingFile{path: file, maxSize: maxSize, maxBackups: maxBackups}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	tr := &JSONTracer{w: w, basicTracer: basicTracer{ch: make(chan struct{}, 1)}}
+	go tr.doWrite()
+
+	return tr, nil
+}
+
+// rotatingFile 是一个按大小轮转的文件写入器，只由追踪器的写入协程使用
+type rotatingFile struct {
+	path       string   // 当前文件路径
+	maxSize    int64    // 单个文件的最大字节数
+	maxBackups int      // 保留的备份文件数
+	f          *os.File // 当前文件
+	size       int64    // 当前文件已写入的字节数
+}
+
+// open 截断并打开当前文件
+// 返回值：
+//   - error: 错误信息
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.size = 0
+	return nil
+}
+
+// rotate 关闭当前文件，将其和已有备份依次后移，然后打开新文件
+// 返回值：
+//   - error: 错误信息
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	if r.maxBackups > 0 {
+		for i := r.maxBackups - 1; i > 0; i-- {
+			// 备份可能还不存在，忽略重命名错误
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	}
+	return r.open()
+}
+
+// Write 写入数据，写入前如果当前文件将超过最大大小则先轮转。
+// 每个追踪事件由一次 Write 写入，因此事件不会跨文件。
+// 参数:
+//   - p: 要写入的数据
+//
+// 返回值：
+//   - int: 写入的字节数
+//   - error: 错误信息
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close 关闭当前文件
+// 返回值：
+//   - error: 错误信息
+func (r *rotatingFile) Close() error {
+	return r.f.Close()
+}
+
 // RemoteTracerProtoID 是远程追踪协议的 ID
 const RemoteTracerProtoID = protocol.ID("/dep2p/pubsub/tracer/1.0.0")
 
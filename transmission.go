@@ -0,0 +1,75 @@
+// 作用：RPC 的最大传输大小。
+// 功能：限制单个 RPC 帧的序列化大小。出站 RPC 超出限制时，依次按消息、订阅和控制信息拆分为多个帧，
+// 单个元素仍然超出限制时丢弃该帧；入站 RPC 超出限制时重置流，通知追踪器并计入行为惩罚。
+
+package pubsub
+
+import (
+	"fmt"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// WithMaxTransmissionSize 设置单个 RPC 帧的最大序列化大小，默认与最大消息大小相同。
+// 实际生效的限制不会小于最大消息大小，以保证最大的单条消息也能传输。
+// 参数:
+//   - size: 最大传输大小
+//
+// 返回值:
+//   - Option: 配置选项
+func WithMaxTransmissionSize(size int) Option {
+	return func(ps *PubSub) error {
+		if size <= 0 {
+			logger.Warnf("最大传输大小必须为正数")
+			return fmt.Errorf("最大传输大小必须为正数")
+		}
+		ps.maxTransmissionSize = size
+		return nil
+	}
+}
+
+// transmissionLimit 返回生效的最大传输大小
+// 返回值:
+//   - int: 最大传输大小
+func (p *PubSub) transmissionLimit() int {
+	if p.maxTransmissionSize > p.maxMessageSize {
+		return p.maxTransmissionSize
+	}
+	return p.maxMessageSize
+}
+
+// splitRPC 将超出最大传输大小的 RPC 拆分为多个帧，丢弃单个元素仍然超出限制的帧
+// 参数:
+//   - rpc: 出站 RPC
+//   - pid: 接收方的对等节点 ID
+//
+// 返回值:
+//   - []*RPC: 要写入的帧
+func (p *PubSub) splitRPC(rpc *RPC, pid peer.ID) []*RPC {
+	limit := p.transmissionLimit()
+	if rpc.Size() <= limit {
+		return []*RPC{rpc}
+	}
+
+	var frames []*RPC
+	for _, frame := range appendOrMergeRPC(nil, limit, *rpc) {
+		if size := frame.Size(); size > limit {
+			logger.Debugf("丢弃发往 %s 的超大 RPC 帧. 大小: %d, 限制: %d", pid, size, limit)
+			p.tracer.DropRPC(frame, pid)
+			continue
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// rejectOversizedRPC 记录对等节点发送了超出最大传输大小的 RPC，使用 gossipsub 路由器时计入一次行为惩罚
+// 参数:
+//   - pid: 对等节点 ID
+func (p *PubSub) rejectOversizedRPC(pid peer.ID) {
+	logger.Debugf("对等节点 %s 发送的 RPC 超出最大传输大小 %d", pid, p.transmissionLimit())
+	p.tracer.OversizedRPC(pid, p.transmissionLimit())
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		gs.penalize(pid, 1, PenaltyOversizedRPC)
+	}
+}
@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// TestSplitRPC 测试超出最大传输大小的 RPC 被拆分为多个帧，单条超大消息被丢弃
+func TestSplitRPC(t *testing.T) {
+	p := &PubSub{maxMessageSize: 100}
+	if err := WithMaxTransmissionSize(0)(p); err == nil {
+		t.Fatal("expected error for a non-positive transmission size")
+	}
+	if err := WithMaxTransmissionSize(50)(p); err != nil {
+		t.Fatal(err)
+	}
+	if p.transmissionLimit() != 100 {
+		t.Fatal("expected the limit to be at least the max message size")
+	}
+	if err := WithMaxTransmissionSize(200)(p); err != nil {
+		t.Fatal(err)
+	}
+
+	small := &RPC{RPC: pb.RPC{Publish: []*pb.Message{{Data: make([]byte, 10)}}}}
+	if frames := p.splitRPC(small, "peer"); len(frames) != 1 || frames[0] != small {
+		t.Fatal("expected a small RPC to be sent as is")
+	}
+
+	var msgs []*pb.Message
+	for i := 0; i < 10; i++ {
+		msgs = append(msgs, &pb.Message{Data: make([]byte, 60)})
+	}
+	msgs = append(msgs, &pb.Message{Data: make([]byte, 300)})
+	big := &RPC{RPC: pb.RPC{
+		Publish: msgs,
+		Control: &pb.ControlMessage{Graft: []*pb.ControlGraft{{TopicID: "foo"}}},
+	}}
+
+	frames := p.splitRPC(big, "peer")
+	published, grafts := 0, 0
+	for _, frame := range frames {
+		if frame.Size() > 200 {
+			t.Fatalf("frame of %d bytes exceeds the limit", frame.Size())
+		}
+		published += len(frame.Publish)
+		grafts += len(frame.GetControl().GetGraft())
+	}
+	if published != 10 || grafts != 1 {
+		t.Fatalf("expected 10 messages and 1 graft across frames, got %d and %d", published, grafts)
+	}
+}
+
+// oversizedRPCRecorder 记录超大 RPC 事件的低级追踪器
+type oversizedRPCRecorder struct {
+	RawTracer // 其余方法由内嵌的追踪器处理
+
+	mx    sync.Mutex
+	peers []peer.ID
+}
+
+// OversizedRPC 实现 OversizedRPCTracer 接口
+func (r *oversizedRPCRecorder) OversizedRPC(p peer.ID, limit int) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.peers = append(r.peers, p)
+}
+
+// TestOversizedInboundRPC 测试收到超出最大传输大小的 RPC 时通知追踪器
+func TestOversizedInboundRPC(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rec := &oversizedRPCRecorder{RawTracer: newGossipTracer()}
+	hosts := getDefaultHosts(t, 2)
+	psubs := []*PubSub{
+		getPubsub(ctx, hosts[0], WithMaxMessageSize(4<<20)),
+		getPubsub(ctx, hosts[1], WithRawTracer(rec)),
+	}
+	connect(t, hosts[0], hosts[1])
+
+	if _, err := psubs[1].Subscribe("foo"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	topic, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := topic.Publish(ctx, make([]byte, 2<<20)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+
+	rec.mx.Lock()
+	defer rec.mx.Unlock()
+	if len(rec.peers) == 0 || rec.peers[0] != hosts[0].ID() {
+		t.Fatalf("expected an oversized RPC event from %s, got %v", hosts[0].ID(), rec.peers)
+	}
+}
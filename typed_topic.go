@@ -0,0 +1,242 @@
+// 作用：类型化主题。
+// 功能：在 Topic 之上提供泛型的发布和订阅接口，按编解码器自动编码和解码消息负载；
+// 解码在验证阶段完成，无法解码的消息被拒绝，并计入发送方的无效消息评分惩罚。
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/gogo/protobuf/proto"
+)
+
+// Codec 定义类型化主题的负载编解码器，CBOR 等其他格式可以通过实现此接口接入
+type Codec[T any] interface {
+	// ContentType 返回编码的内容类型，随消息头部 content-type 发布
+	ContentType() string
+	// Encode 将值编码为消息负载
+	Encode(v T) ([]byte, error)
+	// Decode 将消息负载解码为值
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec 使用 encoding/json 编解码负载
+type JSONCodec[T any] struct{}
+
+// ContentType 实现 Codec 接口
+func (JSONCodec[T]) ContentType() string { return "application/json" }
+
+// Encode 实现 Codec 接口
+func (JSONCodec[T]) Encode(v T) ([]byte, error) { return json.Marshal(v) }
+
+// Decode 实现 Codec 接口
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+	return v, err
+}
+
+// ProtoCodec 使用 protobuf 编解码负载
+type ProtoCodec[T proto.Message] struct {
+	newMsg func() T // 创建用于解码的空消息
+}
+
+// NewProtoCodec 创建 protobuf 编解码器
+// 参数:
+//   - newMsg: 创建用于解码的空消息，例如 func() *pb.Message { return new(pb.Message) }
+//
+// 返回值:
+//   - *ProtoCodec[T]: protobuf 编解码器
+func NewProtoCodec[T proto.Message](newMsg func() T) *ProtoCodec[T] {
+	return &ProtoCodec[T]{newMsg: newMsg}
+}
+
+// ContentType 实现 Codec 接口
+func (c *ProtoCodec[T]) ContentType() string { return "application/protobuf" }
+
+// Encode 实现 Codec 接口
+func (c *ProtoCodec[T]) Encode(v T) ([]byte, error) { return proto.Marshal(v) }
+
+// Decode 实现 Codec 接口
+func (c *ProtoCodec[T]) Decode(data []byte) (T, error) {
+	v := c.newMsg()
+	err := proto.Unmarshal(data, v)
+	return v, err
+}
+
+// TypedValidator 验证解码后的值，在负载成功解码后调用
+type TypedValidator[T any] func(ctx context.Context, from peer.ID, v T) ValidationResult
+
+// TypedMessage 是类型化订阅收到的消息
+type TypedMessage[T any] struct {
+	*Message   // 原始消息
+	Value    T // 解码后的值
+}
+
+// TypedTopic 是按编解码器自动编码和解码负载的主题句柄，由 JoinTyped 创建
+type TypedTopic[T any] struct {
+	*Topic
+	codec     Codec[T]
+	validator atomic.Pointer[TypedValidator[T]]
+}
+
+// JoinTyped 加入主题并返回类型化主题句柄。
+// 类型化主题为主题注册解码验证器，因此主题上不能再注册其他验证器，应用验证请使用 SetValidator。
+// 参数:
+//   - ps: PubSub 实例
+//   - topic: 主题名称
+//   - codec: 负载编解码器
+//   - opts: 主题选项
+//
+// 返回值:
+//   - *TypedTopic[T]: 类型化主题句柄
+//   - error: 错误信息，如果有的话
+func JoinTyped[T any](ps *PubSub, topic string, codec Codec[T], opts ...TopicOpt) (*TypedTopic[T], error) {
+	if codec == nil {
+		logger.Warnf("编解码器不能为空")
+		return nil, fmt.Errorf("编解码器不能为空")
+	}
+
+	t, err := ps.Join(topic, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	tt := &TypedTopic[T]{Topic: t, codec: codec}
+	if err := ps.RegisterTopicValidator(topic, tt.validate); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return tt, nil
+}
+
+// SetValidator 设置解码后调用的应用验证器，传入 nil 时移除
+// 参数:
+//   - v: 类型化验证器
+func (tt *TypedTopic[T]) SetValidator(v TypedValidator[T]) {
+	if v == nil {
+		tt.validator.Store(nil)
+		return
+	}
+	tt.validator.Store(&v)
+}
+
+// Publish 编码值并发布到主题
+// 参数:
+//   - ctx: 上下文
+//   - v: 要发布的值
+//   - opts: 发布选项
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (tt *TypedTopic[T]) Publish(ctx context.Context, v T, opts ...PubOpt) error {
+	data, err := tt.codec.Encode(v)
+	if err != nil {
+		logger.Warnf("编码消息失败: %s", err)
+		return err
+	}
+	opts = append([]PubOpt{WithHeader(HeaderContentType, tt.codec.ContentType())}, opts...)
+	return tt.Topic.Publish(ctx, data, opts...)
+}
+
+// Subscribe 订阅主题，返回的通道在上下文取消或订阅结束后关闭
+// 参数:
+//   - ctx: 上下文，取消后停止订阅
+//   - opts: 订阅选项
+//
+// 返回值:
+//   - <-chan TypedMessage[T]: 解码后的消息通道
+//   - error: 错误信息，如果有的话
+func (tt *TypedTopic[T]) Subscribe(ctx context.Context, opts ...SubOpt) (<-chan TypedMessage[T], error) {
+	sub, err := tt.Topic.Subscribe(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan TypedMessage[T], cap(sub.ch))
+	go func() {
+		defer close(out)
+		defer sub.Cancel()
+
+		for {
+			msg, err := sub.Next(ctx)
+			if err != nil {
+				return
+			}
+			v, ok := msg.ValidatorData.(T)
+			if !ok {
+				// 验证器总是设置解码后的值，这里只是防御
+				if v, err = tt.codec.Decode(msg.Data); err != nil {
+					logger.Debugf("解码主题 %s 的消息 %s 失败: %s", tt.topic, msg.ID, err)
+					continue
+				}
+			}
+			select {
+			case out <- TypedMessage[T]{Message: msg, Value: v}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// Close 关闭主题并移除解码验证器
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (tt *TypedTopic[T]) Close() error {
+	if err := tt.Topic.Close(); err != nil {
+		return err
+	}
+	tt.unregister()
+	return nil
+}
+
+// CloseDrain 以排空模式关闭主题并移除解码验证器，见 Topic.CloseDrain
+// 参数:
+//   - ctx: 限定排空时间的上下文
+//
+// 返回值:
+//   - error: 错误信息，如果有的话
+func (tt *TypedTopic[T]) CloseDrain(ctx context.Context) error {
+	if err := tt.Topic.CloseDrain(ctx); err != nil {
+		return err
+	}
+	tt.unregister()
+	return nil
+}
+
+// unregister 移除主题的解码验证器
+func (tt *TypedTopic[T]) unregister() {
+	if err := tt.p.UnregisterTopicValidator(tt.topic); err != nil {
+		logger.Debugf("移除主题 %s 的解码验证器失败: %s", tt.topic, err)
+	}
+}
+
+// validate 解码消息负载，无法解码的消息被拒绝；解码后的值保存在 ValidatorData 中供订阅使用
+// 参数:
+//   - ctx: 上下文
+//   - from: 转发消息的对等节点
+//   - msg: 消息
+//
+// 返回值:
+//   - ValidationResult: 验证结果
+func (tt *TypedTopic[T]) validate(ctx context.Context, from peer.ID, msg *Message) ValidationResult {
+	v, err := tt.codec.Decode(msg.Data)
+	if err != nil {
+		logger.Debugf("拒绝来自 %s 的无法解码的消息: %s", from, err)
+		return ValidationReject
+	}
+
+	if val := tt.validator.Load(); val != nil {
+		if res := (*val)(ctx, from, v); res != ValidationAccept {
+			return res
+		}
+	}
+	msg.ValidatorData = v
+	return ValidationAccept
+}
@@ -0,0 +1,99 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/dep2p/pubsub/pb"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+)
+
+// typedPayload 是类型化主题测试使用的负载
+type typedPayload struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TestTypedTopic 测试类型化主题的编码、解码，以及无法解码和被应用验证器拒绝的消息不会投递
+func TestTypedTopic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getPubsubs(ctx, hosts)
+
+	raw, err := psubs[0].Join("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed, err := JoinTyped[typedPayload](psubs[1], "foo", JSONCodec[typedPayload]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed.SetValidator(func(_ context.Context, _ peer.ID, v typedPayload) ValidationResult {
+		if v.Count < 0 {
+			return ValidationReject
+		}
+		return ValidationAccept
+	})
+
+	ch, err := typed.Subscribe(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	connect(t, hosts[0], hosts[1])
+	time.Sleep(100 * time.Millisecond)
+
+	for _, data := range []string{`not json`, `{"name":"negative","count":-1}`, `{"name":"ok","count":3}`} {
+		if err := raw.Publish(ctx, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case msg := <-ch:
+		if msg.Value.Name != "ok" || msg.Value.Count != 3 {
+			t.Fatalf("unexpected typed message %+v", msg.Value)
+		}
+		if msg.GetFrom() != hosts[0].ID() {
+			t.Fatal("expected the original message to be available")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for typed message")
+	}
+
+	select {
+	case msg := <-ch:
+		t.Fatalf("unexpected extra typed message %+v", msg.Value)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if err := typed.Close(); err == nil {
+		t.Fatal("expected close to fail with an active subscription")
+	}
+	cancel()
+	if _, ok := <-ch; ok {
+		t.Fatal("expected typed channel to be closed after the context is cancelled")
+	}
+}
+
+// TestProtoCodec 测试 protobuf 编解码器
+func TestProtoCodec(t *testing.T) {
+	codec := NewProtoCodec(func() *pb.Annotation { return new(pb.Annotation) })
+	data, err := codec.Encode(&pb.Annotation{Key: "k", Value: []byte("v")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := codec.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Key != "k" || string(v.Value) != "v" {
+		t.Fatalf("unexpected decoded value %+v", v)
+	}
+	if _, err := codec.Decode([]byte{0xff}); err == nil {
+		t.Fatal("expected error for malformed protobuf")
+	}
+}
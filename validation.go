@@ -68,6 +68,9 @@ type validation struct {
 	// defaultVals 跟踪适用于所有主题的默认验证器
 	defaultVals []*validatorImpl
 
+	// schemaVals 跟踪每个主题的负载模式验证器，在其他验证器之前运行
+	schemaVals map[string]*validatorImpl
+
 	// validateQ 是验证管道的前端
 	validateQ chan *validateReq
 
@@ -76,19 +79,25 @@ type validation struct {
 
 	// validateWorkers 是同步验证工作线程的数量
 	validateWorkers int
+
+	// inflightMx 保护正在验证的消息计数
+	inflightMx sync.Mutex
+	// inflight 跟踪每个主题正在验证的消息数
+	inflight map[string]int
 }
 
 // validateReq 表示验证请求
 type validateReq struct {
-	vals []*validatorImpl // 验证器列表
-	src  peer.ID          // 消息来源的对等节点 ID
-	msg  *Message         // 要验证的消息
+	vals []*validatorImpl  // 验证器列表
+	vc   ValidationContext // 消息来源的上下文
+	msg  *Message          // 要验证的消息
+	size int64             // 消息占用的内存预算
 }
 
 // validatorImpl 表示主题验证器
 type validatorImpl struct {
 	topic            string        // 验证器所属的主题
-	validate         ValidatorEx   // 验证函数
+	validate         ValidatorCtx  // 验证函数
 	validateTimeout  time.Duration // 验证超时时间
 	validateThrottle chan struct{} // 验证节流通道
 	validateInline   bool          // 是否内联验证
@@ -114,9 +123,11 @@ type rmValReq struct {
 func newValidation() *validation {
 	return &validation{
 		topicVals:        make(map[string]*validatorImpl),                   // 初始化主题验证器映射
+		schemaVals:       make(map[string]*validatorImpl),                   // 初始化模式验证器映射
 		validateQ:        make(chan *validateReq, defaultValidateQueueSize), // 初始化验证请求队列
 		validateThrottle: make(chan struct{}, defaultValidateThrottle),      // 初始化验证节流
 		validateWorkers:  runtime.NumCPU(),                                  // 设置同步验证工作线程数为 CPU 数量
+		inflight:         make(map[string]int),                              // 初始化正在验证的消息计数
 	}
 }
 
@@ -164,34 +175,45 @@ func (v *validation) AddValidator(req *addValReq) {
 //   - *validatorImpl 创建的验证器
 //   - error 错误信息
 func (v *validation) makeValidator(req *addValReq) (*validatorImpl, error) {
-	// 将简单的 Validator 转换为扩展的 ValidatorEx
-	makeValidatorEx := func(v Validator) ValidatorEx {
-		return func(ctx context.Context, p peer.ID, msg *Message) ValidationResult {
-			if v(ctx, p, msg) { // 调用简单的 Validator 函数
+	// 将简单的 Validator 转换为 ValidatorCtx
+	makeValidatorCtx := func(v Validator) ValidatorCtx {
+		return func(ctx context.Context, vc ValidationContext, msg *Message) ValidationResult {
+			if v(ctx, vc.From, msg) { // 调用简单的 Validator 函数
 				return ValidationAccept // 如果验证通过，返回 ValidationAccept
 			}
 			return ValidationReject // 如果验证失败，返回 ValidationReject
 		}
 	}
+	// 将扩展的 ValidatorEx 转换为 ValidatorCtx
+	makeValidatorEx := func(v ValidatorEx) ValidatorCtx {
+		return func(ctx context.Context, vc ValidationContext, msg *Message) ValidationResult {
+			return v(ctx, vc.From, msg)
+		}
+	}
 
-	var validator ValidatorEx         // 声明扩展的 ValidatorEx 类型的验证器
+	var validator ValidatorCtx        // 声明 ValidatorCtx 类型的验证器
 	switch v := req.validate.(type) { // 根据请求中的验证器类型进行转换
 	case func(ctx context.Context, p peer.ID, msg *Message) bool:
-		validator = makeValidatorEx(Validator(v)) // 将简单的 Validator 转换为 ValidatorEx
+		validator = makeValidatorCtx(Validator(v)) // 将简单的 Validator 转换为 ValidatorCtx
 	case Validator:
-		validator = makeValidatorEx(v) // 将 Validator 转换为 ValidatorEx
+		validator = makeValidatorCtx(v) // 将 Validator 转换为 ValidatorCtx
 
 	case func(ctx context.Context, p peer.ID, msg *Message) ValidationResult:
-		validator = ValidatorEx(v) // 如果已经是 ValidatorEx 类型，则直接赋值
+		validator = makeValidatorEx(ValidatorEx(v)) // 将 ValidatorEx 转换为 ValidatorCtx
 	case ValidatorEx:
-		validator = v // 如果已经是 ValidatorEx 类型，则直接赋值
+		validator = makeValidatorEx(v) // 将 ValidatorEx 转换为 ValidatorCtx
+
+	case func(ctx context.Context, vc ValidationContext, msg *Message) ValidationResult:
+		validator = ValidatorCtx(v) // 如果已经是 ValidatorCtx 类型，则直接赋值
+	case ValidatorCtx:
+		validator = v // 如果已经是 ValidatorCtx 类型，则直接赋值
 
 	default: // 如果验证器类型未知，返回错误
 		topic := req.topic // 获取请求中的主题
 		if req.topic == "" {
 			topic = "(default)" // 如果主题为空，设置为默认值
 		}
-		return nil, fmt.Errorf("主题 %s 的验证器类型未知；必须是 Validator 或 ValidatorEx 的实例", topic)
+		return nil, fmt.Errorf("主题 %s 的验证器类型未知；必须是 Validator、ValidatorEx 或 ValidatorCtx 的实例", topic)
 	}
 
 	val := &validatorImpl{
@@ -245,8 +267,14 @@ func (v *validation) PushLocal(msg *Message) error {
 		return err // 如果签名检查失败，返回错误
 	}
 
-	vals := v.getValidators(msg)                         // 获取消息的验证器
-	return v.validate(vals, msg.ReceivedFrom, msg, true) // 执行验证，返回验证结果
+	// 本节点不是主题的授权发布者时拒绝发布
+	if !v.p.authorizedPublisher(msg) {
+		v.tracer.RejectMessage(msg, RejectUnauthorizedPublisher)
+		return ValidationError{Reason: RejectUnauthorizedPublisher}
+	}
+
+	vals := v.getValidators(msg)                                        // 获取消息的验证器
+	return v.validate(vals, v.p.localValidationContext(msg), msg, true) // 执行验证，返回验证结果
 }
 
 // Push 将消息推送到验证管道中
@@ -259,10 +287,23 @@ func (v *validation) PushLocal(msg *Message) error {
 func (v *validation) Push(src peer.ID, msg *Message) bool {
 	vals := v.getValidators(msg) // 获取消息的验证器
 
-	if len(vals) > 0 || msg.Signature != nil { // 如果存在验证器或消息有签名
+	if len(vals) > 0 || msg.Signature != nil || v.p.needsTokenCheck(msg) { // 如果存在验证器、消息有签名或需要查询令牌日志
+		var size int64
+		if v.p.memBudget != nil {
+			size = int64(msg.Size())
+			if !v.p.memBudget.reserve(size, memClassValidation) {
+				logger.Debugf("内存预算不足；丢弃来自 %s 的消息", src)
+				v.tracer.RejectMessage(msg, RejectValidationQueueFull) // 记录消息被拒绝的原因
+				return false
+			}
+		}
+
+		v.track(msg.GetTopic(), 1) // 计入正在验证的消息
 		select {
-		case v.validateQ <- &validateReq{vals, src, msg}: // 将验证请求推送到验证队列
+		case v.validateQ <- &validateReq{vals, v.p.validationContext(src, msg), msg, size}: // 将验证请求推送到验证队列
 		default:
+			v.track(msg.GetTopic(), -1)                            // 没有进入验证队列
+			v.p.memBudget.release(size)                            // 释放预留的内存预算
 			logger.Debugf("消息验证节流；丢弃来自 %s 的消息", src)               // 验证队列已满，丢弃消息
 			v.tracer.RejectMessage(msg, RejectValidationQueueFull) // 记录消息被拒绝的原因
 		}
@@ -272,6 +313,33 @@ func (v *validation) Push(src peer.ID, msg *Message) bool {
 	return true // 消息可以立即转发
 }
 
+// track 调整主题正在验证的消息数
+// 参数:
+//   - topic: string 主题
+//   - delta: int 变化量
+func (v *validation) track(topic string, delta int) {
+	v.inflightMx.Lock()
+	defer v.inflightMx.Unlock()
+
+	if n := v.inflight[topic] + delta; n > 0 {
+		v.inflight[topic] = n
+	} else {
+		delete(v.inflight, topic)
+	}
+}
+
+// pending 返回主题正在验证的消息数
+// 参数:
+//   - topic: string 主题
+//
+// 返回值：
+//   - int 正在验证的消息数
+func (v *validation) pending(topic string) int {
+	v.inflightMx.Lock()
+	defer v.inflightMx.Unlock()
+	return v.inflight[topic]
+}
+
 // getValidators 返回适用于给定消息的所有验证器
 // 参数:
 //   - msg: *Message 要获取验证器的消息
@@ -282,11 +350,14 @@ func (v *validation) getValidators(msg *Message) []*validatorImpl {
 	v.mx.Lock()         // 加锁以防止并发修改
 	defer v.mx.Unlock() // 在函数返回前解锁
 
+	topic := msg.GetTopic() // 获取消息的主题
+
 	var vals []*validatorImpl
+	if val, ok := v.schemaVals[topic]; ok {
+		vals = append(vals, val) // 模式验证器最先运行
+	}
 	vals = append(vals, v.defaultVals...) // 添加默认验证器
 
-	topic := msg.GetTopic() // 获取消息的主题
-
 	val, ok := v.topicVals[topic] // 检查主题是否有验证器
 	if !ok {
 		return vals // 如果没有主题验证器，返回默认验证器列表
@@ -300,7 +371,9 @@ func (v *validation) validateWorker() {
 	for {
 		select {
 		case req := <-v.validateQ: // 从验证队列中接收验证请求
-			v.validate(req.vals, req.src, req.msg, false) // 执行验证
+			v.validate(req.vals, req.vc, req.msg, false) // 执行验证
+			v.p.memBudget.release(req.size)              // 释放消息占用的内存预算
+			v.track(req.msg.GetTopic(), -1)              // 验证完成
 		case <-v.p.ctx.Done(): // 如果上下文已关闭，退出循环
 			return
 		}
@@ -310,13 +383,14 @@ func (v *validation) validateWorker() {
 // validate 执行验证，只有在所有验证器成功时才发送消息
 // 参数:
 //   - vals: []*validatorImpl 验证器列表
-//   - src: peer.ID 消息来源的对等节点 ID
+//   - vc: ValidationContext 消息来源的上下文
 //   - msg: *Message 要验证的消息
 //   - synchronous: bool 是否同步验证
 //
 // 返回值：
 //   - error 验证错误信息
-func (v *validation) validate(vals []*validatorImpl, src peer.ID, msg *Message, synchronous bool) error {
+func (v *validation) validate(vals []*validatorImpl, vc ValidationContext, msg *Message, synchronous bool) error {
+	src := vc.From
 	// 如果启用了签名验证但禁用了签名，则接收消息时 Signature 应为 nil
 	if msg.Signature != nil {
 		if !v.validateSignature(msg) { // 验证消息签名
@@ -329,12 +403,15 @@ func (v *validation) validate(vals []*validatorImpl, src peer.ID, msg *Message,
 	// 现在我们已经验证了签名，可以标记消息为已看到，避免多次调用用户验证器
 	id := v.p.idGen.ID(msg) // 生成消息的唯一 ID
 	if !v.p.markSeen(id) {  // 标记消息为已看到
-		v.tracer.DuplicateMessage(msg) // 记录重复消息
-		return nil                     // 返回 nil 表示消息已处理
+		v.p.traceDuplicate(id, msg) // 记录重复消息
+		return nil                  // 返回 nil 表示消息已处理
 	} else {
 		v.tracer.ValidateMessage(msg) // 记录消息验证成功
 	}
 
+	// 在验证管道中查询令牌日志，避免可能较慢的日志阻塞事件循环
+	msg.tokenCommitted = v.p.tokenCommitted(msg)
+
 	var inline, async []*validatorImpl // 声明内联和异步验证器列表
 	for _, val := range vals {         // 遍历所有验证器
 		if val.validateInline || synchronous {
@@ -348,7 +425,7 @@ func (v *validation) validate(vals []*validatorImpl, src peer.ID, msg *Message,
 	result := ValidationAccept // 初始化验证结果为接受
 loop:
 	for _, val := range inline { // 遍历所有内联验证器
-		switch val.validateMsg(v.p.ctx, src, msg) { // 执行验证
+		switch val.validateMsg(v.p.ctx, vc, msg) { // 执行验证
 		case ValidationAccept:
 		case ValidationReject:
 			result = ValidationReject // 验证失败，更新结果
@@ -368,9 +445,11 @@ loop:
 	if len(async) > 0 { // 如果存在异步验证器
 		select {
 		case v.validateThrottle <- struct{}{}: // 发送节流信号
-			go func() { // 启动新的 goroutine 执行异步验证
-				v.doValidateTopic(async, src, msg, result) // 执行异步验证
-				<-v.validateThrottle                       // 验证完成后释放节流信号
+			v.track(msg.GetTopic(), 1) // 计入正在异步验证的消息
+			go func() {                // 启动新的 goroutine 执行异步验证
+				v.doValidateTopic(async, vc, msg, result) // 执行异步验证
+				<-v.validateThrottle                      // 验证完成后释放节流信号
+				v.track(msg.GetTopic(), -1)               // 异步验证完成
 			}()
 		default:
 			logger.Debugf("消息验证节流；丢弃来自 %s 的消息", src)               // 验证节流，丢弃消息
@@ -412,11 +491,12 @@ func (v *validation) validateSignature(msg *Message) bool {
 // doValidateTopic 执行主题验证
 // 参数:
 //   - vals: []*validatorImpl 验证器列表
-//   - src: peer.ID 消息来源的对等节点 ID
+//   - vc: ValidationContext 消息来源的上下文
 //   - msg: *Message 要验证的消息
 //   - r: ValidationResult 验证结果
-func (v *validation) doValidateTopic(vals []*validatorImpl, src peer.ID, msg *Message, r ValidationResult) {
-	result := v.validateTopic(vals, src, msg) // 执行主题验证
+func (v *validation) doValidateTopic(vals []*validatorImpl, vc ValidationContext, msg *Message, r ValidationResult) {
+	src := vc.From
+	result := v.validateTopic(vals, vc, msg) // 执行主题验证
 
 	if result == ValidationAccept && r != ValidationAccept {
 		result = r // 使用之前的验证结果更新当前结果
@@ -445,14 +525,14 @@ func (v *validation) doValidateTopic(vals []*validatorImpl, src peer.ID, msg *Me
 // validateTopic 执行主题验证
 // 参数:
 //   - vals: []*validatorImpl 验证器列表
-//   - src: peer.ID 消息来源的对等节点 ID
+//   - vc: ValidationContext 消息来源的上下文
 //   - msg: *Message 要验证的消息
 //
 // 返回值：
 //   - ValidationResult 验证结果
-func (v *validation) validateTopic(vals []*validatorImpl, src peer.ID, msg *Message) ValidationResult {
+func (v *validation) validateTopic(vals []*validatorImpl, vc ValidationContext, msg *Message) ValidationResult {
 	if len(vals) == 1 { // 如果只有一个验证器
-		return v.validateSingleTopic(vals[0], src, msg) // 单一验证器的快速路径
+		return v.validateSingleTopic(vals[0], vc, msg) // 单一验证器的快速路径
 	}
 
 	ctx, cancel := context.WithCancel(v.p.ctx) // 创建可取消的上下文
@@ -467,13 +547,13 @@ func (v *validation) validateTopic(vals []*validatorImpl, src peer.ID, msg *Mess
 		select {
 		case val.validateThrottle <- struct{}{}: // 发送节流信号
 			go func(val *validatorImpl) { // 启动新的 goroutine 执行验证
-				rch <- val.validateMsg(ctx, src, msg) // 执行验证并发送结果到通道
-				<-val.validateThrottle                // 释放节流信号
+				rch <- val.validateMsg(ctx, vc, msg) // 执行验证并发送结果到通道
+				<-val.validateThrottle               // 释放节流信号
 			}(val)
 
 		default:
-			logger.Debugf("验证节流；丢弃来自 %s 的消息", src) // 验证节流，记录日志
-			rch <- validationThrottled             // 发送节流结果到通道
+			logger.Debugf("验证节流；丢弃来自 %s 的消息", vc.From) // 验证节流，记录日志
+			rch <- validationThrottled                 // 发送节流结果到通道
 		}
 	}
 
@@ -500,33 +580,33 @@ loop:
 // validateSingleTopic 执行单一验证器的验证
 // 参数:
 //   - val: *validatorImpl 验证器
-//   - src: peer.ID 消息来源的对等节点 ID
+//   - vc: ValidationContext 消息来源的上下文
 //   - msg: *Message 要验证的消息
 //
 // 返回值：
 //   - ValidationResult 验证结果
-func (v *validation) validateSingleTopic(val *validatorImpl, src peer.ID, msg *Message) ValidationResult {
+func (v *validation) validateSingleTopic(val *validatorImpl, vc ValidationContext, msg *Message) ValidationResult {
 	select {
 	case val.validateThrottle <- struct{}{}: // 发送节流信号
-		res := val.validateMsg(v.p.ctx, src, msg) // 执行验证并获取结果
-		<-val.validateThrottle                    // 释放节流信号
-		return res                                // 返回验证结果
+		res := val.validateMsg(v.p.ctx, vc, msg) // 执行验证并获取结果
+		<-val.validateThrottle                   // 释放节流信号
+		return res                               // 返回验证结果
 
 	default:
-		logger.Debugf("验证节流；丢弃来自 %s 的消息", src) // 验证节流，记录日志
-		return validationThrottled             // 返回节流结果
+		logger.Debugf("验证节流；丢弃来自 %s 的消息", vc.From) // 验证节流，记录日志
+		return validationThrottled                 // 返回节流结果
 	}
 }
 
 // validateMsg 执行验证器的验证
 // 参数:
 //   - ctx: context.Context 上下文
-//   - src: peer.ID 消息来源的对等节点 ID
+//   - vc: ValidationContext 消息来源的上下文
 //   - msg: *Message 要验证的消息
 //
 // 返回值：
 //   - ValidationResult 验证结果
-func (val *validatorImpl) validateMsg(ctx context.Context, src peer.ID, msg *Message) ValidationResult {
+func (val *validatorImpl) validateMsg(ctx context.Context, vc ValidationContext, msg *Message) ValidationResult {
 	start := time.Now() // 记录开始时间
 	defer func() {
 		logger.Debugf("验证完成；耗时 %s", time.Since(start)) // 输出验证耗时
@@ -538,8 +618,8 @@ func (val *validatorImpl) validateMsg(ctx context.Context, src peer.ID, msg *Mes
 		defer cancel()                                              // 在函数返回前取消上下文
 	}
 
-	r := val.validate(ctx, src, msg) // 执行验证并获取结果
-	switch r {                       // 根据验证结果返回相应的值
+	r := val.validate(ctx, vc, msg) // 执行验证并获取结果
+	switch r {                      // 根据验证结果返回相应的值
 	case ValidationAccept:
 		fallthrough
 	case ValidationReject:
@@ -0,0 +1,71 @@
+// 作用：带对等节点上下文的验证器。
+// 功能：在消息进入验证管道时记录转发者的上下文（接收协议、是否为网格或直接对等节点、当前评分和到达时间），
+// 并通过 ValidatorCtx 传给验证器，使验证器可以根据转发者的状态做出决策。
+
+package pubsub
+
+import (
+	"context"
+	"time"
+
+	"github.com/dep2p/go-dep2p/core/peer"
+	"github.com/dep2p/go-dep2p/core/protocol"
+)
+
+// ValidationContext 是消息进入验证管道时转发者的上下文
+type ValidationContext struct {
+	From     peer.ID     // 转发该消息的对等节点，本地发布时为本节点
+	Protocol protocol.ID // 转发者使用的路由器协议，本地发布或追赶补发时为空
+	Mesh     bool        // 转发者是否在消息主题的网格中，仅 gossipsub 设置
+	Direct   bool        // 转发者是否为直接对等节点，仅 gossipsub 设置
+	Score    float64     // 转发者当前的评分，未启用评分时为 0
+	Received time.Time   // 消息到达的时间
+	Local    bool        // 消息是否由本节点发布
+}
+
+// ValidatorCtx 是带转发者上下文的验证函数，返回枚举决策。
+// 可以和 Validator、ValidatorEx 一样通过 RegisterTopicValidator 或 WithDefaultValidator 注册。
+type ValidatorCtx func(context.Context, ValidationContext, *Message) ValidationResult
+
+// validationContext 返回远程消息的验证上下文。只能在事件循环中调用。
+// 参数:
+//   - src: 转发该消息的对等节点
+//   - msg: 消息
+//
+// 返回值:
+//   - ValidationContext: 验证上下文
+func (p *PubSub) validationContext(src peer.ID, msg *Message) ValidationContext {
+	vc := ValidationContext{
+		From:     src,
+		Protocol: msg.receivedProto,
+		Received: msg.receivedAt,
+	}
+	if vc.Received.IsZero() {
+		vc.Received = time.Now()
+	}
+
+	if gs, ok := p.rt.(*GossipSubRouter); ok {
+		_, vc.Mesh = gs.mesh[msg.GetTopic()][src]
+		_, vc.Direct = gs.direct[src]
+		vc.Score = gs.score.Score(src)
+	}
+	return vc
+}
+
+// localValidationContext 返回本地发布的消息的验证上下文
+// 参数:
+//   - msg: 消息
+//
+// 返回值:
+//   - ValidationContext: 验证上下文
+func (p *PubSub) localValidationContext(msg *Message) ValidationContext {
+	received := msg.receivedAt
+	if received.IsZero() {
+		received = time.Now()
+	}
+	return ValidationContext{
+		From:     msg.ReceivedFrom,
+		Received: received,
+		Local:    true,
+	}
+}
@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestValidatorCtx 测试验证器收到转发者的协议、网格状态和到达时间
+func TestValidatorCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts := getDefaultHosts(t, 2)
+	psubs := getGossipsubs(ctx, hosts)
+	connect(t, hosts[0], hosts[1])
+
+	const topic = "foobar"
+	contexts := make([]chan ValidationContext, len(psubs))
+	for i, ps := range psubs {
+		ch := make(chan ValidationContext, 1)
+		contexts[i] = ch
+		err := ps.RegisterTopicValidator(topic, func(_ context.Context, vc ValidationContext, _ *Message) ValidationResult {
+			ch <- vc
+			return ValidationAccept
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	topics := getTopics(psubs, topic)
+	sub, err := topics[1].Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := topics[0].Subscribe(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 等待网格建立
+	time.Sleep(2 * time.Second)
+
+	start := time.Now()
+	if err := topics[0].Publish(ctx, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	local := <-contexts[0]
+	if !local.Local || local.From != hosts[0].ID() || local.Protocol != "" {
+		t.Fatalf("unexpected local validation context: %+v", local)
+	}
+
+	select {
+	case vc := <-contexts[1]:
+		if vc.Local || vc.From != hosts[0].ID() {
+			t.Fatalf("unexpected remote validation context: %+v", vc)
+		}
+		if vc.Protocol != GossipSubID_v11 {
+			t.Fatalf("expected protocol %s, got %s", GossipSubID_v11, vc.Protocol)
+		}
+		if !vc.Mesh || vc.Direct {
+			t.Fatalf("expected a mesh peer that is not direct: %+v", vc)
+		}
+		if vc.Received.Before(start) || vc.Received.After(time.Now()) {
+			t.Fatalf("unexpected arrival time %s", vc.Received)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for validation")
+	}
+
+	assertReceive(t, sub, []byte("hello"))
+}